@@ -19,11 +19,15 @@
 package acsclient
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/wsclient"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/cihub/seelog"
 )
@@ -45,9 +49,39 @@ func New(url string, cfg *config.Config, credentialProvider *credentials.Credent
 	cs.RequestHandlers = make(map[string]wsclient.RequestHandler)
 	cs.TypeDecoder = NewACSDecoder()
 	cs.RWTimeout = rwTimeout
+	cs.MetricsEndpoint = metrics.ACSEndpoint
+	cs.UnrecognizedMessageHandler = cs.ackUnrecognizedMessage
 	return cs
 }
 
+// unrecognizedMessage is the part of the messages of types the agent doesn't
+// recognize that's needed to acknowledge them
+type unrecognizedMessage struct {
+	MessageID            string `json:"messageId"`
+	ClusterArn           string `json:"clusterArn"`
+	ContainerInstanceArn string `json:"containerInstanceArn"`
+}
+
+// ackUnrecognizedMessage acknowledges the messages of types the agent doesn't
+// recognize, which are sent by newer versions of ACS, so that they aren't
+// resent to the agent
+func (cs *clientServer) ackUnrecognizedMessage(messageType string, message json.RawMessage) {
+	var unrecognized unrecognizedMessage
+	if err := json.Unmarshal(message, &unrecognized); err != nil || unrecognized.MessageID == "" {
+		seelog.Infof("Not acknowledging message of unrecognized type %s without a message id", messageType)
+		return
+	}
+	err := cs.MakeRequest(&ecsacs.AckRequest{
+		Cluster:           aws.String(unrecognized.ClusterArn),
+		ContainerInstance: aws.String(unrecognized.ContainerInstanceArn),
+		MessageId:         aws.String(unrecognized.MessageID),
+	})
+	if err != nil {
+		seelog.Warnf("Error acknowledging message of unrecognized type %s, message id %s: %v",
+			messageType, unrecognized.MessageID, err)
+	}
+}
+
 // Serve begins serving requests using previously registered handlers (see
 // AddRequestHandler). All request handlers should be added prior to making this
 // call as unhandled requests will be discarded.
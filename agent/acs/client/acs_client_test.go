@@ -228,6 +228,42 @@ func TestClosingConnection(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestUnrecognizedMessageAcked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := mock_wsconn.NewMockWebsocketConn(ctrl)
+	conn.EXPECT().SetReadDeadline(gomock.Any()).Return(nil).Times(2)
+	gomock.InOrder(
+		conn.EXPECT().ReadMessage().Return(websocket.TextMessage,
+			[]byte(`{"type":"FutureMessage","message":{"messageId":"123","clusterArn":"cluster","containerInstanceArn":"instance"}}`),
+			nil),
+		conn.EXPECT().ReadMessage().Return(0, nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}),
+	)
+	// Invoked when acknowledging the message and when closing the connection
+	conn.EXPECT().SetWriteDeadline(gomock.Any()).Return(nil).Times(2)
+	var writes []byte
+	conn.EXPECT().WriteMessage(gomock.Any(), gomock.Any()).Do(func(_ int, data []byte) {
+		writes = data
+	})
+	conn.EXPECT().Close()
+	cs := testCS(conn)
+	defer cs.Close()
+
+	assert.Equal(t, io.EOF, cs.Serve())
+
+	msg := &wsclient.RequestMessage{}
+	assert.NoError(t, json.Unmarshal(writes, msg))
+	assert.Equal(t, "AckRequest", msg.Type)
+	ack := &ecsacs.AckRequest{}
+	assert.NoError(t, json.Unmarshal(msg.Message, ack))
+	assert.Equal(t, &ecsacs.AckRequest{
+		Cluster:           aws.String("cluster"),
+		ContainerInstance: aws.String("instance"),
+		MessageId:         aws.String("123"),
+	}, ack)
+}
+
 func TestConnect(t *testing.T) {
 	closeWS := make(chan bool)
 	server, serverChan, requestChan, serverErr, err := startMockAcsServer(t, closeWS)
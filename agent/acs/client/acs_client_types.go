@@ -38,6 +38,7 @@ func init() {
 		ecsacs.StageUpdateMessage{},
 		ecsacs.IAMRoleCredentialsMessage{},
 		ecsacs.IAMRoleCredentialsAckRequest{},
+		ecsacs.IAMRoleCredentialsRefreshRequest{},
 		ecsacs.ServerException{},
 		ecsacs.BadRequestException{},
 		ecsacs.InvalidClusterException{},
@@ -46,6 +47,9 @@ func init() {
 		ecsacs.InactiveInstanceException{},
 		ecsacs.ErrorMessage{},
 		ecsacs.AttachTaskNetworkInterfacesMessage{},
+		ecsacs.TaskManifestMessage{},
+		ecsacs.TaskManifestAckRequest{},
+		ecsacs.InstanceStatusMessage{},
 	}
 }
 
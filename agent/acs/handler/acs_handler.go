@@ -33,6 +33,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
@@ -40,6 +41,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/wsclient"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -67,6 +69,10 @@ const (
 	inactiveInstanceExceptionPrefix = "InactiveInstanceException:"
 )
 
+// errStaleConnection is returned when the connection to ACS is abandoned
+// after it went without any message for too long
+var errStaleConnection = errors.New("acs: no message received on the connection for too long")
+
 // Session defines an interface for handler's long-lived connection with ACS.
 type Session interface {
 	Start() error
@@ -90,6 +96,9 @@ type session struct {
 	cancel                          context.CancelFunc
 	backoff                         utils.Backoff
 	resources                       sessionResources
+	connectionStatus                *ConnectionStatus
+	sequenceNumbers                 *SequenceNumbers
+	instanceStatus                  *InstanceStatus
 	_heartbeatTimeout               time.Duration
 	_heartbeatJitter                time.Duration
 	_inactiveInstanceReconnectDelay time.Duration
@@ -143,7 +152,10 @@ func NewSession(ctx context.Context,
 	stateManager statemanager.StateManager,
 	taskEngine engine.TaskEngine,
 	credentialsManager rolecredentials.Manager,
-	taskHandler *eventhandler.TaskHandler) Session {
+	taskHandler *eventhandler.TaskHandler,
+	connectionStatus *ConnectionStatus,
+	sequenceNumbers *SequenceNumbers,
+	instanceStatus *InstanceStatus) Session {
 	resources := newSessionResources(credentialsProvider)
 	backoff := utils.NewSimpleBackoff(connectionBackoffMin, connectionBackoffMax,
 		connectionBackoffJitter, connectionBackoffMultiplier)
@@ -164,6 +176,9 @@ func NewSession(ctx context.Context,
 		cancel:                          cancel,
 		backoff:                         backoff,
 		resources:                       resources,
+		connectionStatus:                connectionStatus,
+		sequenceNumbers:                 sequenceNumbers,
+		instanceStatus:                  instanceStatus,
 		_heartbeatTimeout:               heartbeatTimeout,
 		_heartbeatJitter:                heartbeatJitter,
 		_inactiveInstanceReconnectDelay: inactiveInstanceReconnectDelay,
@@ -258,7 +273,7 @@ func (acsSession *session) startACSSession(client wsclient.ClientServer) error {
 	cfg := acsSession.agentConfig
 
 	refreshCredsHandler := newRefreshCredentialsHandler(acsSession.ctx, cfg.Cluster, acsSession.containerInstanceARN,
-		client, acsSession.credentialsManager, acsSession.taskEngine)
+		client, acsSession.credentialsManager, acsSession.taskEngine, cfg.TaskCredentialsRefreshWindow)
 	defer refreshCredsHandler.clearAcks()
 	refreshCredsHandler.start()
 	defer refreshCredsHandler.stop()
@@ -273,12 +288,40 @@ func (acsSession *session) startACSSession(client wsclient.ClientServer) error {
 		client,
 		acsSession.state,
 		acsSession.stateManager,
+		acsSession.ecsClient,
+		acsSession.taskHandler,
 	)
 	eniAttachHandler.start()
 	defer eniAttachHandler.stop()
 
 	client.AddRequestHandler(eniAttachHandler.handlerFunc())
 
+	// Add handler to reconcile the tasks with the task manifest
+	taskManifestHandler := newTaskManifestHandler(
+		acsSession.ctx,
+		cfg.Cluster,
+		acsSession.containerInstanceARN,
+		client,
+		acsSession.taskEngine,
+	)
+	taskManifestHandler.start()
+	defer taskManifestHandler.stop()
+
+	client.AddRequestHandler(taskManifestHandler.handlerFunc())
+
+	// Add handler to record whether the instance is draining
+	instanceStatusHandler := newInstanceStatusHandler(
+		acsSession.ctx,
+		cfg.Cluster,
+		acsSession.containerInstanceARN,
+		client,
+		acsSession.instanceStatus,
+	)
+	instanceStatusHandler.start()
+	defer instanceStatusHandler.stop()
+
+	client.AddRequestHandler(instanceStatusHandler.handlerFunc())
+
 	// Add request handler for handling payload messages from ACS
 	payloadHandler := newPayloadRequestHandler(
 		acsSession.ctx,
@@ -290,7 +333,9 @@ func (acsSession *session) startACSSession(client wsclient.ClientServer) error {
 		acsSession.stateManager,
 		refreshCredsHandler,
 		acsSession.credentialsManager,
-		acsSession.taskHandler)
+		acsSession.taskHandler,
+		acsSession.sequenceNumbers,
+		acsSession.instanceStatus)
 	// Clear the acks channel on return because acks of messageids don't have any value across sessions
 	defer payloadHandler.clearAcks()
 	payloadHandler.start()
@@ -309,10 +354,15 @@ func (acsSession *session) startACSSession(client wsclient.ClientServer) error {
 		return err
 	}
 	seelog.Info("Connected to ACS endpoint")
+	metrics.SetConnected(metrics.ACSEndpoint, true)
+	defer metrics.SetConnected(metrics.ACSEndpoint, false)
+	acsSession.connectionStatus.connected()
 	// Start inactivity timer for closing the connection
-	timer := newDisconnectionTimer(client, acsSession.heartbeatTimeout(), acsSession.heartbeatJitter())
+	staleConnection := make(chan struct{}, 1)
+	timer := newDisconnectionTimer(client, acsSession.heartbeatTimeout(), acsSession.heartbeatJitter(),
+		staleConnection)
 	// Any message from the server resets the disconnect timeout
-	client.SetAnyRequestHandler(anyMessageHandler(timer, client))
+	client.SetAnyRequestHandler(anyMessageHandler(timer, client, acsSession.connectionStatus))
 	defer timer.Stop()
 
 	acsSession.resources.connectedToACS()
@@ -343,6 +393,11 @@ func (acsSession *session) startACSSession(client wsclient.ClientServer) error {
 			// client.Serve returns an error. This can happen when the
 			// the connection is closed by ACS or the agent
 			return err
+		case <-staleConnection:
+			// A half-open connection is only noticed by client.Serve once
+			// the TCP keepalive fails, which can take hours. Reconnect
+			// with backoff without waiting for it
+			return errStaleConnection
 		}
 	}
 }
@@ -421,10 +476,18 @@ func acsWsURL(endpoint, cluster, containerInstanceArn string, taskEngine engine.
 }
 
 // newDisconnectionTimer creates a new time object, with a callback to
-// disconnect from ACS on inactivity
-func newDisconnectionTimer(client wsclient.ClientServer, timeout time.Duration, jitter time.Duration) ttime.Timer {
+// disconnect from ACS on inactivity. The stale channel is notified when the
+// callback runs, as closing a half-open connection can block
+func newDisconnectionTimer(client wsclient.ClientServer,
+	timeout time.Duration,
+	jitter time.Duration,
+	stale chan<- struct{}) ttime.Timer {
 	timer := time.AfterFunc(utils.AddJitter(timeout, jitter), func() {
 		seelog.Warn("ACS Connection hasn't had any activity for too long; closing connection")
+		select {
+		case stale <- struct{}{}:
+		default:
+		}
 		if err := client.Close(); err != nil {
 			seelog.Warnf("Error disconnecting: %v", err)
 		}
@@ -436,9 +499,10 @@ func newDisconnectionTimer(client wsclient.ClientServer, timeout time.Duration,
 
 // anyMessageHandler handles any server message. Any server message means the
 // connection is active and thus the heartbeat disconnect should not occur
-func anyMessageHandler(timer ttime.Timer, client wsclient.ClientServer) func(interface{}) {
+func anyMessageHandler(timer ttime.Timer, client wsclient.ClientServer, status *ConnectionStatus) func(interface{}) {
 	return func(interface{}) {
 		seelog.Debug("ACS activity occurred")
+		status.messageReceived(time.Now())
 		// Reset read deadline as there's activity on the channel
 		if err := client.SetReadDeadline(time.Now().Add(wsRWTimeout)); err != nil {
 			seelog.Warnf("Unable to extend read deadline for ACS connection: %v", err)
@@ -30,6 +30,7 @@ import (
 
 	"context"
 
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	"github.com/aws/amazon-ecs-agent/agent/api/mocks"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
@@ -43,6 +44,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/utils/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/version"
 	"github.com/aws/amazon-ecs-agent/agent/wsclient"
 	"github.com/aws/amazon-ecs-agent/agent/wsclient/mock"
@@ -210,7 +212,7 @@ func TestHandlerReconnectsOnConnectErrors(t *testing.T) {
 
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
 	mockWsClient.EXPECT().SetAnyRequestHandler(gomock.Any()).AnyTimes()
@@ -238,6 +240,9 @@ func TestHandlerReconnectsOnConnectErrors(t *testing.T) {
 		ctx:                  ctx,
 		cancel:               cancel,
 		resources:            &mockSessionResources{mockWsClient},
+		connectionStatus:     NewConnectionStatus(),
+		sequenceNumbers:      NewSequenceNumbers(),
+		instanceStatus:       NewInstanceStatus(),
 		_heartbeatTimeout:    20 * time.Millisecond,
 		_heartbeatJitter:     10 * time.Millisecond,
 	}
@@ -348,7 +353,7 @@ func TestHandlerReconnectsWithoutBackoffOnEOFError(t *testing.T) {
 
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	deregisterInstanceEventStream := eventstream.NewEventStream("DeregisterContainerInstance", ctx)
 	deregisterInstanceEventStream.StartListening()
@@ -383,6 +388,9 @@ func TestHandlerReconnectsWithoutBackoffOnEOFError(t *testing.T) {
 		ctx:                             ctx,
 		cancel:                          cancel,
 		resources:                       &mockSessionResources{mockWsClient},
+		connectionStatus:                NewConnectionStatus(),
+		sequenceNumbers:                 NewSequenceNumbers(),
+		instanceStatus:                  NewInstanceStatus(),
 		_heartbeatTimeout:               20 * time.Millisecond,
 		_heartbeatJitter:                10 * time.Millisecond,
 		_inactiveInstanceReconnectDelay: inactiveInstanceReconnectDelay,
@@ -411,7 +419,7 @@ func TestHandlerReconnectsWithBackoffOnNonEOFError(t *testing.T) {
 
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	deregisterInstanceEventStream := eventstream.NewEventStream("DeregisterContainerInstance", ctx)
 	deregisterInstanceEventStream.StartListening()
@@ -446,6 +454,9 @@ func TestHandlerReconnectsWithBackoffOnNonEOFError(t *testing.T) {
 		ctx:                           ctx,
 		cancel:                        cancel,
 		resources:                     &mockSessionResources{mockWsClient},
+		connectionStatus:              NewConnectionStatus(),
+		sequenceNumbers:               NewSequenceNumbers(),
+		instanceStatus:                NewInstanceStatus(),
 		_heartbeatTimeout:             20 * time.Millisecond,
 		_heartbeatJitter:              10 * time.Millisecond,
 	}
@@ -473,7 +484,7 @@ func TestHandlerGeneratesDeregisteredInstanceEvent(t *testing.T) {
 
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	deregisterInstanceEventStream := eventstream.NewEventStream("DeregisterContainerInstance", ctx)
 
@@ -505,6 +516,9 @@ func TestHandlerGeneratesDeregisteredInstanceEvent(t *testing.T) {
 		ctx:                             ctx,
 		cancel:                          cancel,
 		resources:                       &mockSessionResources{mockWsClient},
+		connectionStatus:                NewConnectionStatus(),
+		sequenceNumbers:                 NewSequenceNumbers(),
+		instanceStatus:                  NewInstanceStatus(),
 		_heartbeatTimeout:               20 * time.Millisecond,
 		_heartbeatJitter:                10 * time.Millisecond,
 		_inactiveInstanceReconnectDelay: inactiveInstanceReconnectDelay,
@@ -533,7 +547,7 @@ func TestHandlerReconnectDelayForInactiveInstanceError(t *testing.T) {
 
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	deregisterInstanceEventStream := eventstream.NewEventStream("DeregisterContainerInstance", ctx)
 	deregisterInstanceEventStream.StartListening()
@@ -574,6 +588,9 @@ func TestHandlerReconnectDelayForInactiveInstanceError(t *testing.T) {
 		ctx:                             ctx,
 		cancel:                          cancel,
 		resources:                       &mockSessionResources{mockWsClient},
+		connectionStatus:                NewConnectionStatus(),
+		sequenceNumbers:                 NewSequenceNumbers(),
+		instanceStatus:                  NewInstanceStatus(),
 		_heartbeatTimeout:               20 * time.Millisecond,
 		_heartbeatJitter:                10 * time.Millisecond,
 		_inactiveInstanceReconnectDelay: inactiveInstanceReconnectDelay,
@@ -601,7 +618,7 @@ func TestHandlerReconnectsOnServeErrors(t *testing.T) {
 
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
 	mockWsClient.EXPECT().SetAnyRequestHandler(gomock.Any()).AnyTimes()
@@ -631,6 +648,9 @@ func TestHandlerReconnectsOnServeErrors(t *testing.T) {
 		ctx:                  ctx,
 		cancel:               cancel,
 		resources:            &mockSessionResources{mockWsClient},
+		connectionStatus:     NewConnectionStatus(),
+		sequenceNumbers:      NewSequenceNumbers(),
+		instanceStatus:       NewInstanceStatus(),
 		_heartbeatTimeout:    20 * time.Millisecond,
 		_heartbeatJitter:     10 * time.Millisecond,
 	}
@@ -657,7 +677,7 @@ func TestHandlerStopsWhenContextIsCancelled(t *testing.T) {
 
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
 	mockWsClient.EXPECT().SetAnyRequestHandler(gomock.Any()).AnyTimes()
@@ -682,6 +702,9 @@ func TestHandlerStopsWhenContextIsCancelled(t *testing.T) {
 		ctx:                  ctx,
 		cancel:               cancel,
 		resources:            &mockSessionResources{mockWsClient},
+		connectionStatus:     NewConnectionStatus(),
+		sequenceNumbers:      NewSequenceNumbers(),
+		instanceStatus:       NewInstanceStatus(),
 		_heartbeatTimeout:    20 * time.Millisecond,
 		_heartbeatJitter:     10 * time.Millisecond,
 	}
@@ -706,7 +729,7 @@ func TestHandlerReconnectsOnDiscoverPollEndpointError(t *testing.T) {
 	ecsClient := mock_api.NewMockECSClient(ctrl)
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
 	mockWsClient.EXPECT().SetAnyRequestHandler(gomock.Any()).AnyTimes()
@@ -736,6 +759,9 @@ func TestHandlerReconnectsOnDiscoverPollEndpointError(t *testing.T) {
 		ctx:                  ctx,
 		cancel:               cancel,
 		resources:            &mockSessionResources{mockWsClient},
+		connectionStatus:     NewConnectionStatus(),
+		sequenceNumbers:      NewSequenceNumbers(),
+		instanceStatus:       NewInstanceStatus(),
 		_heartbeatTimeout:    20 * time.Millisecond,
 		_heartbeatJitter:     10 * time.Millisecond,
 	}
@@ -775,7 +801,7 @@ func TestConnectionIsClosedOnIdle(t *testing.T) {
 	ecsClient := mock_api.NewMockECSClient(ctrl)
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 	defer cancel()
 
 	wait := sync.WaitGroup{}
@@ -808,6 +834,9 @@ func TestConnectionIsClosedOnIdle(t *testing.T) {
 		ctx:                  context.Background(),
 		backoff:              utils.NewSimpleBackoff(connectionBackoffMin, connectionBackoffMax, connectionBackoffJitter, connectionBackoffMultiplier),
 		resources:            &mockSessionResources{},
+		connectionStatus:     NewConnectionStatus(),
+		sequenceNumbers:      NewSequenceNumbers(),
+		instanceStatus:       NewInstanceStatus(),
 		_heartbeatTimeout:    20 * time.Millisecond,
 		_heartbeatJitter:     10 * time.Millisecond,
 	}
@@ -818,6 +847,71 @@ func TestConnectionIsClosedOnIdle(t *testing.T) {
 	<-connectionClosed
 }
 
+// TestStaleConnectionIsAbandoned tests if the session stops waiting for the
+// connection to ACS once it's idle, even if closing it doesn't stop it from
+// being served, as happens with half-open connections
+func TestStaleConnectionIsAbandoned(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+	taskEngine.EXPECT().Version().Return("Docker: 1.5.0", nil).AnyTimes()
+
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	stateManager := statemanager.NewNoopStateManager()
+	ctx, cancel := context.WithCancel(context.Background())
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
+	defer cancel()
+
+	halfOpen := make(chan struct{})
+	defer close(halfOpen)
+	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
+	mockWsClient.EXPECT().SetAnyRequestHandler(gomock.Any()).AnyTimes()
+	mockWsClient.EXPECT().AddRequestHandler(gomock.Any()).AnyTimes()
+	mockWsClient.EXPECT().Connect().Return(nil)
+	mockWsClient.EXPECT().Serve().Do(func() {
+		<-halfOpen
+	}).Return(io.EOF)
+	mockWsClient.EXPECT().Close().Return(nil)
+	connectionStatus := NewConnectionStatus()
+	acsSession := session{
+		containerInstanceARN: "myArn",
+		credentialsProvider:  testCreds,
+		agentConfig:          testConfig,
+		taskEngine:           taskEngine,
+		ecsClient:            ecsClient,
+		stateManager:         stateManager,
+		taskHandler:          taskHandler,
+		ctx:                  context.Background(),
+		backoff:              utils.NewSimpleBackoff(connectionBackoffMin, connectionBackoffMax, connectionBackoffJitter, connectionBackoffMultiplier),
+		resources:            &mockSessionResources{},
+		connectionStatus:     connectionStatus,
+		sequenceNumbers:      NewSequenceNumbers(),
+		instanceStatus:       NewInstanceStatus(),
+		_heartbeatTimeout:    20 * time.Millisecond,
+		_heartbeatJitter:     10 * time.Millisecond,
+	}
+
+	assert.Equal(t, errStaleConnection, acsSession.startACSSession(mockWsClient))
+	assert.Equal(t, 0, connectionStatus.Reconnects())
+}
+
+// TestAnyMessageHandlerRecordsMessages tests if messages received from ACS
+// are recorded in the connection status
+func TestAnyMessageHandlerRecordsMessages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
+	mockWsClient.EXPECT().SetReadDeadline(gomock.Any()).Return(nil)
+	timer := mock_ttime.NewMockTimer(ctrl)
+	timer.EXPECT().Reset(gomock.Any())
+	connectionStatus := NewConnectionStatus()
+
+	before := time.Now()
+	anyMessageHandler(timer, mockWsClient, connectionStatus)(&ecsacs.HeartbeatMessage{})
+	assert.False(t, connectionStatus.LastMessageReceivedAt().Before(before))
+}
+
 func TestHandlerDoesntLeakGoroutines(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -825,7 +919,7 @@ func TestHandlerDoesntLeakGoroutines(t *testing.T) {
 	ecsClient := mock_api.NewMockECSClient(ctrl)
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	closeWS := make(chan bool)
 	server, serverIn, requests, errs, err := startMockAcsServer(t, closeWS)
@@ -863,6 +957,9 @@ func TestHandlerDoesntLeakGoroutines(t *testing.T) {
 			_heartbeatTimeout:    1 * time.Second,
 			backoff:              utils.NewSimpleBackoff(connectionBackoffMin, connectionBackoffMax, connectionBackoffJitter, connectionBackoffMultiplier),
 			resources:            newSessionResources(testCreds),
+			connectionStatus:     NewConnectionStatus(),
+			sequenceNumbers:      NewSequenceNumbers(),
+			instanceStatus:       NewInstanceStatus(),
 			credentialsManager:   rolecredentials.NewManager(),
 		}
 		acsSession.Start()
@@ -909,7 +1006,7 @@ func TestStartSessionHandlesRefreshCredentialsMessages(t *testing.T) {
 	ecsClient := mock_api.NewMockECSClient(ctrl)
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 	closeWS := make(chan bool)
 	server, serverIn, requestsChan, errChan, err := startMockAcsServer(t, closeWS)
 	if err != nil {
@@ -946,6 +1043,9 @@ func TestStartSessionHandlesRefreshCredentialsMessages(t *testing.T) {
 			taskEngine,
 			credentialsManager,
 			taskHandler,
+			NewConnectionStatus(),
+			NewSequenceNumbers(),
+			NewInstanceStatus(),
 		)
 		acsSession.Start()
 		// StartSession should never return unless the context is canceled
@@ -1030,7 +1130,7 @@ func TestHandlerReconnectsCorrectlySetsSendCredentialsURLParameter(t *testing.T)
 	ecsClient := mock_api.NewMockECSClient(ctrl)
 	stateManager := statemanager.NewNoopStateManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	mockWsClient := mock_wsclient.NewMockClientServer(ctrl)
 
@@ -1062,6 +1162,9 @@ func TestHandlerReconnectsCorrectlySetsSendCredentialsURLParameter(t *testing.T)
 		taskHandler:          taskHandler,
 		ctx:                  ctx,
 		resources:            resources,
+		connectionStatus:     NewConnectionStatus(),
+		sequenceNumbers:      NewSequenceNumbers(),
+		instanceStatus:       NewInstanceStatus(),
 		backoff:              utils.NewSimpleBackoff(connectionBackoffMin, connectionBackoffMax, connectionBackoffJitter, connectionBackoffMultiplier),
 		_heartbeatTimeout:    20 * time.Millisecond,
 		_heartbeatJitter:     10 * time.Millisecond,
@@ -17,8 +17,10 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/api"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/wsclient"
 	"github.com/aws/aws-sdk-go/aws"
@@ -29,6 +31,12 @@ import (
 	"context"
 )
 
+const (
+	// eniAttachmentResendInterval is the interval at which the attached status
+	// of ENI attachments, which hasn't been acknowledged by ECS, is sent again
+	eniAttachmentResendInterval = 20 * time.Second
+)
+
 // attachENIHandler represents the ENI attach operation for the ACS client
 type attachENIHandler struct {
 	messageBuffer     chan *ecsacs.AttachTaskNetworkInterfacesMessage
@@ -39,6 +47,9 @@ type attachENIHandler struct {
 	containerInstance *string
 	acsClient         wsclient.ClientServer
 	state             dockerstate.TaskEngineState
+	ecsClient         api.ECSClient
+	taskHandler       *eventhandler.TaskHandler
+	resendInterval    time.Duration
 }
 
 // newAttachENIHandler returns an instance of the attachENIHandler struct
@@ -47,7 +58,9 @@ func newAttachENIHandler(ctx context.Context,
 	containerInstanceArn string,
 	acsClient wsclient.ClientServer,
 	taskEngineState dockerstate.TaskEngineState,
-	saver statemanager.Saver) attachENIHandler {
+	saver statemanager.Saver,
+	ecsClient api.ECSClient,
+	taskHandler *eventhandler.TaskHandler) attachENIHandler {
 
 	// Create a cancelable context from the parent context
 	derivedContext, cancel := context.WithCancel(ctx)
@@ -60,19 +73,28 @@ func newAttachENIHandler(ctx context.Context,
 		acsClient:         acsClient,
 		state:             taskEngineState,
 		saver:             saver,
+		ecsClient:         ecsClient,
+		taskHandler:       taskHandler,
+		resendInterval:    eniAttachmentResendInterval,
 	}
 }
 
 // handlerFunc returns a function to enqueue requests onto attachENIHandler buffer
 func (attachENIHandler *attachENIHandler) handlerFunc() func(message *ecsacs.AttachTaskNetworkInterfacesMessage) {
 	return func(message *ecsacs.AttachTaskNetworkInterfacesMessage) {
-		attachENIHandler.messageBuffer <- message
+		select {
+		case attachENIHandler.messageBuffer <- message:
+		case <-attachENIHandler.ctx.Done():
+			// The handler is stopped, the message is dropped
+		}
 	}
 }
 
-// start invokes handleMessages to ack each enqueued request
+// start invokes handleMessages to ack each enqueued request and starts
+// monitoring the acknowledgement of the ENI attachments
 func (attachENIHandler *attachENIHandler) start() {
 	go attachENIHandler.handleMessages()
+	go attachENIHandler.monitorAttachments()
 }
 
 // stop is used to invoke a cancellation function
@@ -94,6 +116,57 @@ func (attachENIHandler *attachENIHandler) handleMessages() {
 	}
 }
 
+// monitorAttachments periodically sends the attached status of the ENI
+// attachments, which hasn't been acknowledged by ECS, again
+func (handler *attachENIHandler) monitorAttachments() {
+	ticker := time.NewTicker(handler.resendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			handler.resendUnackedAttachments()
+		case <-handler.ctx.Done():
+			return
+		}
+	}
+}
+
+// resendUnackedAttachments sends the attached status of the ENI attachments
+// again until ECS acknowledges it, by sending down the task of the attachment.
+// The attachments that expired without being acknowledged are no longer
+// tracked, and the attachments whose attached status was rejected by ECS, or is
+// yet to be sent, aren't sent again
+func (handler *attachENIHandler) resendUnackedAttachments() {
+	for _, eniAttachment := range handler.state.AllENIAttachments() {
+		if eniAttachment.IsAcked() {
+			continue
+		}
+		if _, ok := handler.state.TaskByArn(eniAttachment.TaskARN); ok {
+			seelog.Infof("ENI attachment acknowledged: %s", eniAttachment.String())
+			eniAttachment.SetAcked()
+			eniAttachment.StopAckTimer()
+			continue
+		}
+		if eniAttachment.HasExpired() {
+			seelog.Infof("ENI attachment expired without being acknowledged; removing ENI attachment record: %s",
+				eniAttachment.String())
+			eniAttachment.StopAckTimer()
+			handler.state.RemoveENIAttachment(eniAttachment.MACAddress)
+			continue
+		}
+		if eniAttachment.IsSubmissionRejected() || !eniAttachment.IsSent() {
+			continue
+		}
+		seelog.Infof("ENI attachment hasn't been acknowledged; sending attached status again: %s",
+			eniAttachment.String())
+		handler.taskHandler.AddStateChangeEvent(api.TaskStateChange{
+			TaskARN:    eniAttachment.TaskARN,
+			Attachment: eniAttachment,
+		}, handler.ecsClient)
+	}
+}
+
 // handleSingleMessage acks the message received
 func (handler *attachENIHandler) handleSingleMessage(message *ecsacs.AttachTaskNetworkInterfacesMessage) error {
 	receivedAt := time.Now()
@@ -140,6 +213,7 @@ func (handler *attachENIHandler) addENIAttachmentToState(message *ecsacs.AttachT
 		AttachmentARN:    attachmentARN,
 		AttachStatusSent: false,
 		MACAddress:       mac,
+		IPV6Addresses:    eniIPV6Addresses(message.ElasticNetworkInterfaces[0]),
 		// Stop tracking the eni attachment after timeout
 		ExpiresAt: receivedAt.Add(time.Duration(aws.Int64Value(message.WaitTimeoutMs)) * time.Millisecond),
 	}
@@ -153,6 +227,15 @@ func (handler *attachENIHandler) addENIAttachmentToState(message *ecsacs.AttachT
 	return nil
 }
 
+// eniIPV6Addresses returns the ipv6 addresses of the eni in the attach message
+func eniIPV6Addresses(acseni *ecsacs.ElasticNetworkInterface) []string {
+	var addresses []string
+	for _, ipv6 := range acseni.Ipv6Addresses {
+		addresses = append(addresses, aws.StringValue(ipv6.Address))
+	}
+	return addresses
+}
+
 // ackTimeoutHandler remove ENI attachment from agent state after the ENI ack timeout
 type ackTimeoutHandler struct {
 	mac   string
@@ -22,9 +22,14 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/api"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	"github.com/aws/amazon-ecs-agent/agent/api/mocks"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/wsclient/mock"
 	"github.com/aws/aws-sdk-go/aws"
@@ -218,7 +223,7 @@ func TestENIAckSingleMessage(t *testing.T) {
 
 	ctx := context.TODO()
 	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
-	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, taskEngineState, manager)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, taskEngineState, manager, nil, nil)
 
 	var ackSent sync.WaitGroup
 	ackSent.Add(1)
@@ -232,6 +237,7 @@ func TestENIAckSingleMessage(t *testing.T) {
 			eniattachment, ok := taskEngineState.ENIByMac(randomMAC)
 			assert.True(t, ok)
 			assert.Equal(t, taskArn, eniattachment.TaskARN)
+			assert.Empty(t, eniattachment.IPV6Addresses)
 			eniAttachHandler.stop()
 		}).Return(nil),
 	)
@@ -272,7 +278,7 @@ func TestENIAckSingleMessageDuplicateENIAttachmentMessageStartsTimer(t *testing.
 
 	ctx := context.TODO()
 	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
-	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, mockState, manager)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, mockState, manager, nil, nil)
 
 	// Set expiresAt to a value in the past
 	expiresAt := time.Unix(time.Now().Unix()-1, 0)
@@ -323,7 +329,7 @@ func TestENIAckHappyPath(t *testing.T) {
 	manager := mock_statemanager.NewMockStateManager(ctrl)
 
 	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
-	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, taskEngineState, manager)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, taskEngineState, manager, nil, nil)
 
 	var ackSent sync.WaitGroup
 	ackSent.Add(1)
@@ -369,7 +375,7 @@ func TestENIAckTimeout(t *testing.T) {
 	manager := mock_statemanager.NewMockStateManager(ctrl)
 
 	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
-	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, taskEngineState, manager)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, taskEngineState, manager, nil, nil)
 	mockNetInterface1 := ecsacs.ElasticNetworkInterface{
 		Ec2Id:         aws.String("1"),
 		MacAddress:    aws.String(randomMAC),
@@ -406,7 +412,7 @@ func TestENIAckWithinTimeout(t *testing.T) {
 	manager := mock_statemanager.NewMockStateManager(ctrl)
 
 	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
-	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, taskEngineState, manager)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, mockWSClient, taskEngineState, manager, nil, nil)
 	mockNetInterface1 := ecsacs.ElasticNetworkInterface{
 		Ec2Id:         aws.String("1"),
 		MacAddress:    aws.String(randomMAC),
@@ -433,3 +439,163 @@ func TestENIAckWithinTimeout(t *testing.T) {
 
 	assert.Len(t, taskEngineState.(*dockerstate.DockerTaskEngineState).AllENIAttachments(), 1)
 }
+
+// TestENIAttachmentResentUntilAcknowledged tests that the attached status of an
+// ENI attachment is sent again, as a task state change, until ECS acknowledges
+// it by sending down the task of the attachment
+func TestENIAttachmentResentUntilAcknowledged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	taskEngineState := dockerstate.NewTaskEngineState()
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	taskHandler := eventhandler.NewTaskHandler(ctx, statemanager.NewNoopStateManager(), taskEngineState, ecsClient, nil)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, nil, taskEngineState,
+		statemanager.NewNoopStateManager(), ecsClient, taskHandler)
+
+	eniAttachment := &apieni.ENIAttachment{
+		TaskARN:       taskArn,
+		AttachmentARN: "attachmentarn",
+		MACAddress:    randomMAC,
+		Status:        apieni.ENIAttached,
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	// The attached status was sent, but its ack was lost
+	eniAttachment.SetSentStatus()
+	taskEngineState.AddENIAttachment(eniAttachment)
+
+	submitted := make(chan struct{})
+	ecsClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+		assert.Equal(t, taskArn, change.TaskARN)
+		assert.Equal(t, eniAttachment, change.Attachment)
+		close(submitted)
+	}).Return(nil)
+
+	eniAttachHandler.resendUnackedAttachments()
+	<-submitted
+	for taskHandler.GetEventStats().Submitted != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The task of the attachment is sent down, no other submission is
+	// expected for the attachment
+	taskEngineState.AddTask(&apitask.Task{Arn: taskArn})
+	for i := 0; i < 3; i++ {
+		eniAttachHandler.resendUnackedAttachments()
+	}
+	assert.True(t, eniAttachment.IsAcked())
+	assert.Equal(t, uint64(1), taskHandler.GetEventStats().Enqueued)
+}
+
+// TestENIAttachmentNotResentBeforeSubmitted tests that the attached status of
+// an ENI attachment isn't sent again while it's yet to be submitted
+func TestENIAttachmentNotResentBeforeSubmitted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	taskEngineState := dockerstate.NewTaskEngineState()
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	taskHandler := eventhandler.NewTaskHandler(ctx, statemanager.NewNoopStateManager(), taskEngineState, ecsClient, nil)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, nil, taskEngineState,
+		statemanager.NewNoopStateManager(), ecsClient, taskHandler)
+
+	taskEngineState.AddENIAttachment(&apieni.ENIAttachment{
+		TaskARN:    taskArn,
+		MACAddress: randomMAC,
+		Status:     apieni.ENIAttached,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	})
+
+	eniAttachHandler.resendUnackedAttachments()
+	assert.Equal(t, uint64(0), taskHandler.GetEventStats().Enqueued)
+}
+
+// TestENIAttachmentNotResentWhenRejected tests that the attached status of an
+// ENI attachment is never sent again once ECS has rejected it
+func TestENIAttachmentNotResentWhenRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	taskEngineState := dockerstate.NewTaskEngineState()
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	taskHandler := eventhandler.NewTaskHandler(ctx, statemanager.NewNoopStateManager(), taskEngineState, ecsClient, nil)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, nil, taskEngineState,
+		statemanager.NewNoopStateManager(), ecsClient, taskHandler)
+
+	eniAttachment := &apieni.ENIAttachment{
+		TaskARN:    taskArn,
+		MACAddress: randomMAC,
+		Status:     apieni.ENIAttached,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}
+	eniAttachment.SetSubmissionRejected()
+	taskEngineState.AddENIAttachment(eniAttachment)
+
+	eniAttachHandler.resendUnackedAttachments()
+	assert.Equal(t, uint64(0), taskHandler.GetEventStats().Enqueued)
+}
+
+// TestENIAttachmentNotResentWhenAcknowledged tests that the attached status of
+// an ENI attachment isn't sent again once its task has been sent down by ECS
+func TestENIAttachmentNotResentWhenAcknowledged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	taskEngineState := dockerstate.NewTaskEngineState()
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	taskHandler := eventhandler.NewTaskHandler(ctx, statemanager.NewNoopStateManager(), taskEngineState, ecsClient, nil)
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, nil, taskEngineState,
+		statemanager.NewNoopStateManager(), ecsClient, taskHandler)
+
+	eniAttachment := &apieni.ENIAttachment{
+		TaskARN:    taskArn,
+		MACAddress: randomMAC,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}
+	eniAttachment.SetSentStatus()
+	taskEngineState.AddENIAttachment(eniAttachment)
+	taskEngineState.AddTask(&apitask.Task{Arn: taskArn})
+
+	eniAttachHandler.resendUnackedAttachments()
+	assert.True(t, eniAttachment.IsAcked())
+	assert.Equal(t, uint64(0), taskHandler.GetEventStats().Enqueued)
+}
+
+// TestENIAttachmentRemovedWhenExpiredWithoutAcknowledgement tests that an ENI
+// attachment which expired before being acknowledged is no longer tracked
+func TestENIAttachmentRemovedWhenExpiredWithoutAcknowledgement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	taskEngineState := dockerstate.NewTaskEngineState()
+	eniAttachHandler := newAttachENIHandler(ctx, clusterName, containerInstanceArn, nil, taskEngineState,
+		statemanager.NewNoopStateManager(), nil, nil)
+
+	taskEngineState.AddENIAttachment(&apieni.ENIAttachment{
+		TaskARN:          taskArn,
+		AttachStatusSent: true,
+		MACAddress:       randomMAC,
+		ExpiresAt:        time.Now().Add(-time.Second),
+	})
+
+	eniAttachHandler.resendUnackedAttachments()
+	_, ok := taskEngineState.ENIByMac(randomMAC)
+	assert.False(t, ok)
+}
+
+func TestENIIPV6Addresses(t *testing.T) {
+	acseni := &ecsacs.ElasticNetworkInterface{
+		Ipv6Addresses: []*ecsacs.IPv6AddressAssignment{
+			{Address: aws.String("2001:db8::2")},
+		},
+	}
+	assert.Equal(t, []string{"2001:db8::2"}, eniIPV6Addresses(acseni))
+	assert.Nil(t, eniIPV6Addresses(&ecsacs.ElasticNetworkInterface{}))
+}
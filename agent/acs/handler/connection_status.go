@@ -0,0 +1,69 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnectionStatus records the activity of the connections of the session to
+// ACS, so that it can be observed from outside of the session
+type ConnectionStatus struct {
+	lastMessageReceivedAt time.Time
+	connections           int
+	lock                  sync.RWMutex
+}
+
+// NewConnectionStatus returns a ConnectionStatus without any connection
+func NewConnectionStatus() *ConnectionStatus {
+	return &ConnectionStatus{}
+}
+
+// LastMessageReceivedAt returns when the last message, heartbeats included,
+// was received from ACS. It's the zero time if no message was received
+func (status *ConnectionStatus) LastMessageReceivedAt() time.Time {
+	status.lock.RLock()
+	defer status.lock.RUnlock()
+
+	return status.lastMessageReceivedAt
+}
+
+// Reconnects returns how many times the session connected to ACS again after
+// its first connection
+func (status *ConnectionStatus) Reconnects() int {
+	status.lock.RLock()
+	defer status.lock.RUnlock()
+
+	if status.connections == 0 {
+		return 0
+	}
+	return status.connections - 1
+}
+
+// connected records a new connection to ACS
+func (status *ConnectionStatus) connected() {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	status.connections++
+}
+
+// messageReceived records a message received from ACS
+func (status *ConnectionStatus) messageReceived(at time.Time) {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	status.lastMessageReceivedAt = at
+}
@@ -0,0 +1,35 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionStatusReconnects(t *testing.T) {
+	status := NewConnectionStatus()
+	assert.Equal(t, 0, status.Reconnects())
+	assert.True(t, status.LastMessageReceivedAt().IsZero())
+
+	status.connected()
+	assert.Equal(t, 0, status.Reconnects(), "the first connection isn't a reconnect")
+
+	status.connected()
+	status.connected()
+	assert.Equal(t, 2, status.Reconnects())
+}
@@ -0,0 +1,46 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"sync"
+)
+
+// InstanceStatus records whether ACS is draining the container instance, in
+// which case the payloads of new tasks are refused
+type InstanceStatus struct {
+	draining bool
+	lock     sync.RWMutex
+}
+
+// NewInstanceStatus returns an InstanceStatus of an active container instance
+func NewInstanceStatus() *InstanceStatus {
+	return &InstanceStatus{}
+}
+
+// Draining returns true if ACS is draining the container instance
+func (status *InstanceStatus) Draining() bool {
+	status.lock.RLock()
+	defer status.lock.RUnlock()
+
+	return status.draining
+}
+
+// setDraining records whether ACS is draining the container instance
+func (status *InstanceStatus) setDraining(draining bool) {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	status.draining = draining
+}
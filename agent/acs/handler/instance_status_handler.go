@@ -0,0 +1,155 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"context"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/wsclient"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// instanceStatusActive is the status of container instances that accept
+	// new tasks
+	instanceStatusActive = "ACTIVE"
+	// instanceStatusDraining is the status of container instances being
+	// drained, which don't accept new tasks
+	instanceStatusDraining = "DRAINING"
+	// instanceDrainingNackReason is the reason of the nacks of the payloads
+	// with new tasks received while the instance is draining
+	instanceDrainingNackReason = "container instance is draining, refusing new tasks"
+)
+
+// instanceStatusHandler records the status of the container instance sent by
+// ACS when the instance is drained or made active again
+type instanceStatusHandler struct {
+	messageBuffer     chan *ecsacs.InstanceStatusMessage
+	ctx               context.Context
+	cancel            context.CancelFunc
+	cluster           *string
+	containerInstance *string
+	acsClient         wsclient.ClientServer
+	instanceStatus    *InstanceStatus
+}
+
+// newInstanceStatusHandler returns an instance of the instanceStatusHandler struct
+func newInstanceStatusHandler(ctx context.Context,
+	cluster string,
+	containerInstanceArn string,
+	acsClient wsclient.ClientServer,
+	instanceStatus *InstanceStatus) instanceStatusHandler {
+
+	// Create a cancelable context from the parent context
+	derivedContext, cancel := context.WithCancel(ctx)
+	return instanceStatusHandler{
+		messageBuffer:     make(chan *ecsacs.InstanceStatusMessage),
+		ctx:               derivedContext,
+		cancel:            cancel,
+		cluster:           aws.String(cluster),
+		containerInstance: aws.String(containerInstanceArn),
+		acsClient:         acsClient,
+		instanceStatus:    instanceStatus,
+	}
+}
+
+// handlerFunc returns a function to enqueue requests onto the instanceStatusHandler buffer
+func (handler *instanceStatusHandler) handlerFunc() func(message *ecsacs.InstanceStatusMessage) {
+	return func(message *ecsacs.InstanceStatusMessage) {
+		select {
+		case handler.messageBuffer <- message:
+		case <-handler.ctx.Done():
+			// The handler is stopped, the message is dropped
+		}
+	}
+}
+
+// start invokes handleMessages to record the status of each enqueued message
+func (handler *instanceStatusHandler) start() {
+	go handler.handleMessages()
+}
+
+// stop is used to invoke a cancellation function
+func (handler *instanceStatusHandler) stop() {
+	handler.cancel()
+}
+
+// handleMessages handles each message one at a time
+func (handler *instanceStatusHandler) handleMessages() {
+	for {
+		select {
+		case message := <-handler.messageBuffer:
+			if err := handler.handleSingleMessage(message); err != nil {
+				seelog.Warnf("Unable to handle instance status message [%s]: %v", message.String(), err)
+			}
+		case <-handler.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleSingleMessage records whether the container instance is draining and
+// acks the message
+func (handler *instanceStatusHandler) handleSingleMessage(message *ecsacs.InstanceStatusMessage) error {
+	if err := validateInstanceStatusMessage(message); err != nil {
+		return errors.Wrapf(err,
+			"instance status handler: error validating InstanceStatus message received from ECS")
+	}
+
+	draining := aws.StringValue(message.Status) == instanceStatusDraining
+	if draining != handler.instanceStatus.Draining() {
+		seelog.Infof("Instance status handler: container instance is now %s", aws.StringValue(message.Status))
+	}
+	handler.instanceStatus.setDraining(draining)
+
+	if err := handler.acsClient.MakeRequest(&ecsacs.AckRequest{
+		Cluster:           message.ClusterArn,
+		ContainerInstance: message.ContainerInstanceArn,
+		MessageId:         message.MessageId,
+	}); err != nil {
+		return errors.Wrapf(err, "instance status handler: unable to ack message with messageId: %s",
+			aws.StringValue(message.MessageId))
+	}
+	return nil
+}
+
+// validateInstanceStatusMessage performs validation checks on the
+// InstanceStatusMessage
+func validateInstanceStatusMessage(message *ecsacs.InstanceStatusMessage) error {
+	if message == nil {
+		return errors.Errorf("instance status handler validation: empty InstanceStatus message received from ECS")
+	}
+
+	if aws.StringValue(message.MessageId) == "" {
+		return errors.Errorf("instance status handler validation: message id not set in InstanceStatus message received from ECS")
+	}
+
+	if aws.StringValue(message.ClusterArn) == "" {
+		return errors.Errorf("instance status handler validation: clusterArn not set in InstanceStatus message received from ECS")
+	}
+
+	if aws.StringValue(message.ContainerInstanceArn) == "" {
+		return errors.Errorf("instance status handler validation: containerInstanceArn not set in InstanceStatus message received from ECS")
+	}
+
+	status := aws.StringValue(message.Status)
+	if status != instanceStatusActive && status != instanceStatusDraining {
+		return errors.Errorf("instance status handler validation: unknown status %q in InstanceStatus message received from ECS", status)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/wsclient/mock"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const instanceStatusMessageId = "instanceStatus"
+
+func TestInstanceStatusMessageValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		message *ecsacs.InstanceStatusMessage
+	}{
+		{"empty message", nil},
+		{"no messageId", &ecsacs.InstanceStatusMessage{
+			ClusterArn:           aws.String(clusterName),
+			ContainerInstanceArn: aws.String(containerInstanceArn),
+			Status:               aws.String(instanceStatusDraining),
+		}},
+		{"no clusterArn", &ecsacs.InstanceStatusMessage{
+			MessageId:            aws.String(instanceStatusMessageId),
+			ContainerInstanceArn: aws.String(containerInstanceArn),
+			Status:               aws.String(instanceStatusDraining),
+		}},
+		{"no containerInstanceArn", &ecsacs.InstanceStatusMessage{
+			MessageId:  aws.String(instanceStatusMessageId),
+			ClusterArn: aws.String(clusterName),
+			Status:     aws.String(instanceStatusDraining),
+		}},
+		{"unknown status", &ecsacs.InstanceStatusMessage{
+			MessageId:            aws.String(instanceStatusMessageId),
+			ClusterArn:           aws.String(clusterName),
+			ContainerInstanceArn: aws.String(containerInstanceArn),
+			Status:               aws.String("INACTIVE"),
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Error(t, validateInstanceStatusMessage(tc.message))
+		})
+	}
+}
+
+func TestInstanceStatusDrainingAndActive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
+	instanceStatus := NewInstanceStatus()
+	handler := newInstanceStatusHandler(context.TODO(), clusterName, containerInstanceArn, mockWSClient, instanceStatus)
+
+	mockWSClient.EXPECT().MakeRequest(&ecsacs.AckRequest{
+		Cluster:           aws.String(clusterName),
+		ContainerInstance: aws.String(containerInstanceArn),
+		MessageId:         aws.String(instanceStatusMessageId),
+	}).Times(2)
+
+	err := handler.handleSingleMessage(&ecsacs.InstanceStatusMessage{
+		MessageId:            aws.String(instanceStatusMessageId),
+		ClusterArn:           aws.String(clusterName),
+		ContainerInstanceArn: aws.String(containerInstanceArn),
+		Status:               aws.String(instanceStatusDraining),
+	})
+	assert.NoError(t, err)
+	assert.True(t, instanceStatus.Draining())
+
+	err = handler.handleSingleMessage(&ecsacs.InstanceStatusMessage{
+		MessageId:            aws.String(instanceStatusMessageId),
+		ClusterArn:           aws.String(clusterName),
+		ContainerInstanceArn: aws.String(containerInstanceArn),
+		Status:               aws.String(instanceStatusActive),
+	})
+	assert.NoError(t, err)
+	assert.False(t, instanceStatus.Draining())
+}
+
+func TestInstanceStatusInvalidMessageKeepsStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
+	instanceStatus := NewInstanceStatus()
+	instanceStatus.setDraining(true)
+	handler := newInstanceStatusHandler(context.TODO(), clusterName, containerInstanceArn, mockWSClient, instanceStatus)
+
+	err := handler.handleSingleMessage(&ecsacs.InstanceStatusMessage{
+		MessageId:            aws.String(instanceStatusMessageId),
+		ClusterArn:           aws.String(clusterName),
+		ContainerInstanceArn: aws.String(containerInstanceArn),
+		Status:               aws.String("INACTIVE"),
+	})
+	assert.Error(t, err)
+	assert.True(t, instanceStatus.Draining())
+}
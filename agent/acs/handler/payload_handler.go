@@ -14,12 +14,15 @@ package handler
 
 import (
 	"fmt"
+	"strings"
 
 	"context"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	apiappmesh "github.com/aws/amazon-ecs-agent/agent/api/appmesh"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
@@ -29,6 +32,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/wsclient"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
 )
 
 // payloadRequestHandler represents the payload operation for the ACS client
@@ -36,7 +40,7 @@ type payloadRequestHandler struct {
 	// messageBuffer is used to process PayloadMessages received from the server
 	messageBuffer chan *ecsacs.PayloadMessage
 	// ackRequest is used to send acks to the backend
-	ackRequest  chan string
+	ackRequest  chan *ecsacs.AckRequest
 	ctx         context.Context
 	taskEngine  engine.TaskEngine
 	ecsClient   api.ECSClient
@@ -49,6 +53,8 @@ type payloadRequestHandler struct {
 	acsClient            wsclient.ClientServer
 	refreshHandler       refreshCredentialsHandler
 	credentialsManager   credentials.Manager
+	sequenceNumbers      *SequenceNumbers
+	instanceStatus       *InstanceStatus
 }
 
 // newPayloadRequestHandler returns a new payloadRequestHandler object
@@ -62,12 +68,14 @@ func newPayloadRequestHandler(
 	saver statemanager.Saver,
 	refreshHandler refreshCredentialsHandler,
 	credentialsManager credentials.Manager,
-	taskHandler *eventhandler.TaskHandler) payloadRequestHandler {
+	taskHandler *eventhandler.TaskHandler,
+	sequenceNumbers *SequenceNumbers,
+	instanceStatus *InstanceStatus) payloadRequestHandler {
 	// Create a cancelable context from the parent context
 	derivedContext, cancel := context.WithCancel(ctx)
 	return payloadRequestHandler{
 		messageBuffer:        make(chan *ecsacs.PayloadMessage, payloadMessageBufferSize),
-		ackRequest:           make(chan string, payloadMessageBufferSize),
+		ackRequest:           make(chan *ecsacs.AckRequest, payloadMessageBufferSize),
 		taskEngine:           taskEngine,
 		ecsClient:            ecsClient,
 		saver:                saver,
@@ -79,6 +87,8 @@ func newPayloadRequestHandler(
 		acsClient:            acsClient,
 		refreshHandler:       refreshHandler,
 		credentialsManager:   credentialsManager,
+		sequenceNumbers:      sequenceNumbers,
+		instanceStatus:       instanceStatus,
 	}
 }
 
@@ -86,7 +96,11 @@ func newPayloadRequestHandler(
 func (payloadHandler *payloadRequestHandler) handlerFunc() func(payload *ecsacs.PayloadMessage) {
 	// return a function that just enqueues PayloadMessages into the message buffer
 	return func(payload *ecsacs.PayloadMessage) {
-		payloadHandler.messageBuffer <- payload
+		select {
+		case payloadHandler.messageBuffer <- payload:
+		case <-payloadHandler.ctx.Done():
+			// The handler is stopped, the message is dropped
+		}
 	}
 }
 
@@ -108,24 +122,29 @@ func (payloadHandler *payloadRequestHandler) stop() {
 func (payloadHandler *payloadRequestHandler) sendAcks() {
 	for {
 		select {
-		case mid := <-payloadHandler.ackRequest:
-			payloadHandler.ackMessageId(mid)
+		case ack := <-payloadHandler.ackRequest:
+			payloadHandler.ackMessage(ack)
 		case <-payloadHandler.ctx.Done():
 			return
 		}
 	}
 }
 
-// ackMessageId sends an AckRequest for a message id
-func (payloadHandler *payloadRequestHandler) ackMessageId(messageID string) {
-	seelog.Debugf("Acking payload message id: %s", messageID)
-	err := payloadHandler.acsClient.MakeRequest(&ecsacs.AckRequest{
-		Cluster:           aws.String(payloadHandler.cluster),
-		ContainerInstance: aws.String(payloadHandler.containerInstanceArn),
-		MessageId:         aws.String(messageID),
-	})
+// ackMessage sends an AckRequest for a payload message
+func (payloadHandler *payloadRequestHandler) ackMessage(ack *ecsacs.AckRequest) {
+	seelog.Debugf("Acking payload message id: %s", aws.StringValue(ack.MessageId))
+	err := payloadHandler.acsClient.MakeRequest(ack)
+	if err != nil {
+		seelog.Warnf("Error 'ack'ing request with messageID: %s, error: %v", aws.StringValue(ack.MessageId), err)
+	}
+}
+
+// nackMessage sends a NackRequest for a payload message
+func (payloadHandler *payloadRequestHandler) nackMessage(nack *ecsacs.NackRequest) {
+	seelog.Infof("Nacking payload message id: %s, reason: %s", aws.StringValue(nack.MessageId), aws.StringValue(nack.Reason))
+	err := payloadHandler.acsClient.MakeRequest(nack)
 	if err != nil {
-		seelog.Warnf("Error 'ack'ing request with messageID: %s, error: %v", messageID, err)
+		seelog.Warnf("Error 'nack'ing request with messageID: %s, error: %v", aws.StringValue(nack.MessageId), err)
 	}
 }
 
@@ -150,7 +169,26 @@ func (payloadHandler *payloadRequestHandler) handleSingleMessage(payload *ecsacs
 		return fmt.Errorf("received a payload with no message id")
 	}
 	seelog.Debugf("Received payload message, message id: %s", aws.StringValue(payload.MessageId))
-	credentialsAcks, allTasksHandled := payloadHandler.addPayloadTasks(payload)
+	if payload.SeqNum != nil &&
+		!payloadHandler.sequenceNumbers.isNewPayload(payloadHandler.containerInstanceArn, aws.Int64Value(payload.SeqNum)) {
+		seelog.Infof("Dropping payload message with message id: %s and sequence number: %d, a newer payload was already processed",
+			aws.StringValue(payload.MessageId), aws.Int64Value(payload.SeqNum))
+		// Ack the payload anyway, so that ACS stops resending it
+		go func() {
+			payloadHandler.ackRequest <- &ecsacs.AckRequest{
+				Cluster:           aws.String(payloadHandler.cluster),
+				ContainerInstance: aws.String(payloadHandler.containerInstanceArn),
+				MessageId:         payload.MessageId,
+			}
+		}()
+		return nil
+	}
+	credentialsAcks, failedTasks, refusedTasks, allTasksHandled := payloadHandler.addPayloadTasks(payload)
+	// Payloads with refused tasks aren't recorded, so that they're handled
+	// again if ACS sends them once the instance is active
+	if allTasksHandled && len(refusedTasks) == 0 && payload.SeqNum != nil {
+		payloadHandler.sequenceNumbers.recordPayload(payloadHandler.containerInstanceArn, aws.Int64Value(payload.SeqNum))
+	}
 	// save the state of tasks we know about after passing them to the task engine
 	err := payloadHandler.saver.Save()
 	if err != nil {
@@ -167,18 +205,48 @@ func (payloadHandler *payloadRequestHandler) handleSingleMessage(payload *ecsacs
 		for _, credentialsAck := range credentialsAcks {
 			payloadHandler.refreshHandler.ackMessage(credentialsAck)
 		}
-		payloadHandler.ackRequest <- *payload.MessageId
+		if len(refusedTasks) != 0 {
+			payloadHandler.nackMessage(&ecsacs.NackRequest{
+				Cluster:           aws.String(payloadHandler.cluster),
+				ContainerInstance: aws.String(payloadHandler.containerInstanceArn),
+				MessageId:         payload.MessageId,
+				Reason: aws.String(fmt.Sprintf("%s: %s",
+					instanceDrainingNackReason, strings.Join(refusedTasks, ", "))),
+			})
+			return
+		}
+		payloadHandler.ackRequest <- &ecsacs.AckRequest{
+			Cluster:           aws.String(payloadHandler.cluster),
+			ContainerInstance: aws.String(payloadHandler.containerInstanceArn),
+			FailedTasks:       failedTasks,
+			MessageId:         payload.MessageId,
+		}
 	}()
 
 	return nil
 }
 
 // addPayloadTasks does validation on each task and, for all valid ones, adds
-// it to the task engine. It returns a bool indicating if it could add every
-// task to the taskEngine and a slice of credential ack requests
-func (payloadHandler *payloadRequestHandler) addPayloadTasks(payload *ecsacs.PayloadMessage) ([]*ecsacs.IAMRoleCredentialsAckRequest, bool) {
+// it to the task engine. The tasks that failed are reported as stopped and
+// returned alongside the reason of their failure. While the instance is
+// draining, the tasks to be started that the engine doesn't manage yet are
+// refused and their arns returned. It returns a bool indicating if every task
+// was either added to the taskEngine, reported as failed or refused and a
+// slice of credential ack requests
+func (payloadHandler *payloadRequestHandler) addPayloadTasks(payload *ecsacs.PayloadMessage) ([]*ecsacs.IAMRoleCredentialsAckRequest, []*ecsacs.TaskFailure, []string, bool) {
 	// verify that we were able to work with all tasks in this payload so we know whether to ack the whole thing or not
 	allTasksOK := true
+	var failedTasks []*ecsacs.TaskFailure
+	failTask := func(task *ecsacs.Task, reason string) {
+		failure := payloadHandler.handleFailedTask(task, reason, payload)
+		if failure == nil {
+			allTasksOK = false
+			return
+		}
+		failedTasks = append(failedTasks, failure)
+	}
+	var refusedTasks []string
+	draining := payloadHandler.instanceStatus.Draining()
 
 	validTasks := make([]*apitask.Task, 0, len(payload.Tasks))
 	for _, task := range payload.Tasks {
@@ -187,12 +255,31 @@ func (payloadHandler *payloadRequestHandler) addPayloadTasks(payload *ecsacs.Pay
 			allTasksOK = false
 			continue
 		}
+		if payload.SeqNum != nil && !payloadHandler.sequenceNumbers.isNewTaskDesiredStatus(
+			payloadHandler.containerInstanceArn, aws.StringValue(task.Arn), aws.Int64Value(payload.SeqNum)) {
+			seelog.Infof("Skipping task %s of payload message with message id: %s, its desired status was set by a newer payload",
+				aws.StringValue(task.Arn), aws.StringValue(payload.MessageId))
+			continue
+		}
 		apiTask, err := apitask.TaskFromACS(task, payload)
 		if err != nil {
-			payloadHandler.handleUnrecognizedTask(task, err, payload)
-			allTasksOK = false
+			failTask(task, UnrecognizedTaskError{err}.Error())
 			continue
 		}
+		if draining && !apiTask.GetDesiredStatus().Terminal() {
+			if _, ok := payloadHandler.taskEngine.GetTaskByArn(apiTask.Arn); !ok {
+				seelog.Infof("Refusing new task %s of payload message with message id: %s, the container instance is draining",
+					apiTask.Arn, aws.StringValue(payload.MessageId))
+				refusedTasks = append(refusedTasks, apiTask.Arn)
+				continue
+			}
+		}
+		if !apiTask.GetDesiredStatus().Terminal() {
+			if err := apiTask.Validate(); err != nil {
+				failTask(task, err.Error())
+				continue
+			}
+		}
 		if task.RoleCredentials != nil {
 			// The payload from ACS for the task has credentials for the
 			// task. Add those to the credentials manager and set the
@@ -204,8 +291,7 @@ func (payloadHandler *payloadRequestHandler) addPayloadTasks(payload *ecsacs.Pay
 					IAMRoleCredentials: taskIAMRoleCredentials,
 				})
 			if err != nil {
-				payloadHandler.handleUnrecognizedTask(task, err, payload)
-				allTasksOK = false
+				failTask(task, UnrecognizedTaskError{err}.Error())
 				continue
 			}
 			apiTask.SetCredentialsID(taskIAMRoleCredentials.CredentialsID)
@@ -215,13 +301,46 @@ func (payloadHandler *payloadRequestHandler) addPayloadTasks(payload *ecsacs.Pay
 		if len(task.ElasticNetworkInterfaces) != 0 {
 			eni, err := apieni.ENIFromACS(task.ElasticNetworkInterfaces)
 			if err != nil {
-				payloadHandler.handleUnrecognizedTask(task, err, payload)
-				allTasksOK = false
+				failTask(task, UnrecognizedTaskError{err}.Error())
 				continue
 			}
 
 			apiTask.SetTaskENI(eni)
 		}
+		if task.ProxyConfiguration != nil {
+			appMesh, err := apiappmesh.AppMeshFromACS(task.ProxyConfiguration)
+			if err != nil {
+				failTask(task, apierrors.NewInvalidTaskError(apiTask.Arn, err).Error())
+				continue
+			}
+			// Traffic is only redirected in the network namespace of tasks
+			// using the awsvpc network mode
+			if apiTask.GetTaskENI() == nil {
+				failTask(task, apierrors.NewInvalidTaskError(apiTask.Arn,
+					errors.New("app mesh: proxy configuration requires the awsvpc network mode")).Error())
+				continue
+			}
+			if _, ok := apiTask.ContainerByName(appMesh.ContainerName); !ok {
+				failTask(task, apierrors.NewInvalidTaskError(apiTask.Arn,
+					errors.Errorf("app mesh: unknown proxy container %s", appMesh.ContainerName)).Error())
+				continue
+			}
+			apiTask.SetAppMesh(appMesh)
+		}
+		if task.EgressBandwidthLimit != nil {
+			// The traffic is only shaped on the eni of tasks using the awsvpc
+			// network mode
+			if aws.Int64Value(task.EgressBandwidthLimit) <= 0 {
+				failTask(task, apierrors.NewInvalidTaskError(apiTask.Arn, errors.Errorf(
+					"egress bandwidth limit: invalid limit %d", aws.Int64Value(task.EgressBandwidthLimit))).Error())
+				continue
+			}
+			if apiTask.GetTaskENI() == nil {
+				failTask(task, apierrors.NewInvalidTaskError(apiTask.Arn,
+					errors.New("egress bandwidth limit: limit requires the awsvpc network mode")).Error())
+				continue
+			}
+		}
 		if task.ExecutionRoleCredentials != nil {
 			// The payload message contains execution credentials for the task.
 			// Add the credentials to the credentials manager and set the
@@ -233,8 +352,7 @@ func (payloadHandler *payloadRequestHandler) addPayloadTasks(payload *ecsacs.Pay
 					IAMRoleCredentials: taskExecutionIAMRoleCredentials,
 				})
 			if err != nil {
-				payloadHandler.handleUnrecognizedTask(task, err, payload)
-				allTasksOK = false
+				failTask(task, UnrecognizedTaskError{err}.Error())
 				continue
 			}
 			apiTask.SetExecutionRoleCredentialsID(taskExecutionIAMRoleCredentials.CredentialsID)
@@ -255,7 +373,7 @@ func (payloadHandler *payloadRequestHandler) addPayloadTasks(payload *ecsacs.Pay
 	// Construct a slice with credentials acks from all tasks
 	var credentialsAcks []*ecsacs.IAMRoleCredentialsAckRequest
 	credentialsAcks = append(stoppedTasksCredentialsAcks, newTasksCredentialsAcks...)
-	return credentialsAcks, allTasksOK
+	return credentialsAcks, failedTasks, refusedTasks, allTasksOK
 }
 
 // addTasks adds the tasks to the task engine based on the skipAddTask condition
@@ -268,6 +386,10 @@ func (payloadHandler *payloadRequestHandler) addTasks(payload *ecsacs.PayloadMes
 			continue
 		}
 		payloadHandler.taskEngine.AddTask(task)
+		if payload.SeqNum != nil {
+			payloadHandler.sequenceNumbers.recordTaskDesiredStatus(payloadHandler.containerInstanceArn,
+				task.Arn, aws.Int64Value(payload.SeqNum))
+		}
 
 		ackCredentials := func(id string, description string) {
 			ack, err := payloadHandler.ackCredentials(payload.MessageId, id)
@@ -321,22 +443,24 @@ func isTaskStatusNotStopped(status apitaskstatus.TaskStatus) bool {
 	return status != apitaskstatus.TaskStopped
 }
 
-// handleUnrecognizedTask handles unrecognized tasks by sending 'stopped' with
-// a suitable reason to the backend
-func (payloadHandler *payloadRequestHandler) handleUnrecognizedTask(task *ecsacs.Task, err error, payload *ecsacs.PayloadMessage) {
-	seelog.Warnf("Received unexpected acs message, messageID: %s, task: %v, err: %v",
-		aws.StringValue(payload.MessageId), aws.StringValue(task.Arn), err)
+// handleFailedTask handles tasks that couldn't be added to the task engine by
+// sending 'stopped' with the reason of the failure to the backend. It returns
+// the failure to report in the ack of the payload, which is nil for tasks
+// without an arn
+func (payloadHandler *payloadRequestHandler) handleFailedTask(task *ecsacs.Task, reason string, payload *ecsacs.PayloadMessage) *ecsacs.TaskFailure {
+	seelog.Warnf("Unable to add task from acs message, messageID: %s, task: %v, reason: %s",
+		aws.StringValue(payload.MessageId), aws.StringValue(task.Arn), reason)
 
 	if aws.StringValue(task.Arn) == "" {
 		seelog.Criticalf("Received task with no arn, messageId: %s", aws.StringValue(payload.MessageId))
-		return
+		return nil
 	}
 
 	// Only need to stop the task; it brings down the containers too.
 	taskEvent := api.TaskStateChange{
 		TaskARN: *task.Arn,
 		Status:  apitaskstatus.TaskStopped,
-		Reason:  UnrecognizedTaskError{err}.Error(),
+		Reason:  reason,
 		// The real task cannot be extracted from payload message, so we send an empty task.
 		// This is necessary because the task handler will not send an event whose
 		// Task is nil.
@@ -344,6 +468,10 @@ func (payloadHandler *payloadRequestHandler) handleUnrecognizedTask(task *ecsacs
 	}
 
 	payloadHandler.taskHandler.AddStateChangeEvent(taskEvent, payloadHandler.ecsClient)
+	return &ecsacs.TaskFailure{
+		Arn:    task.Arn,
+		Reason: aws.String(reason),
+	}
 }
 
 // clearAcks drains the ack request channel
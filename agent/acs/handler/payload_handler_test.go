@@ -18,6 +18,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"sync"
 	"testing"
@@ -27,6 +29,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/api/mocks"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/engine/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
@@ -37,6 +40,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -67,7 +71,7 @@ func setup(t *testing.T) *testHelper {
 	stateManager := statemanager.NewNoopStateManager()
 	credentialsManager := credentials.NewManager()
 	ctx, cancel := context.WithCancel(context.Background())
-	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil)
+	taskHandler := eventhandler.NewTaskHandler(ctx, stateManager, nil, nil, nil)
 
 	handler := newPayloadRequestHandler(
 		ctx,
@@ -79,7 +83,9 @@ func setup(t *testing.T) *testHelper {
 		stateManager,
 		refreshCredentialsHandler{},
 		credentialsManager,
-		taskHandler)
+		taskHandler,
+		NewSequenceNumbers(),
+		NewInstanceStatus())
 
 	return &testHelper{
 		ctrl:               ctrl,
@@ -200,6 +206,287 @@ func TestHandlePayloadMessageAckedWhenTaskAdded(t *testing.T) {
 	assert.Equal(t, addedTask, expectedTask, "received task is not expected")
 }
 
+// TestHandlePayloadMessageOutOfOrderAcrossRestart tests that a payload which
+// is delivered after a newer one, with the agent restarting in between, is
+// acked without reverting the desired status set by the newer payload
+func TestHandlePayloadMessageOutOfOrderAcrossRestart(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "payload_handler_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	cfg := &config.Config{DataDir: dataDir}
+
+	// The newer payload stops the task
+	tester := setup(t)
+	defer tester.ctrl.Finish()
+	sequenceNumbers := NewSequenceNumbers()
+	stateManager, err := statemanager.NewStateManager(cfg,
+		statemanager.AddSaveable("ACSSequenceNumbers", sequenceNumbers))
+	require.NoError(t, err)
+	tester.payloadHandler.saver = stateManager
+	tester.payloadHandler.sequenceNumbers = sequenceNumbers
+
+	tester.mockTaskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		assert.Equal(t, apitaskstatus.TaskStopped, task.GetDesiredStatus())
+	})
+	tester.mockWsClient.EXPECT().MakeRequest(gomock.Any()).Do(func(ackRequest *ecsacs.AckRequest) {
+		assert.Equal(t, "newer", aws.StringValue(ackRequest.MessageId))
+		tester.cancel()
+	})
+	go tester.payloadHandler.start()
+	err = tester.payloadHandler.handleSingleMessage(&ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{{
+			Arn:           aws.String("t1"),
+			DesiredStatus: aws.String("STOPPED"),
+		}},
+		MessageId: aws.String("newer"),
+		SeqNum:    aws.Int64(2),
+	})
+	require.NoError(t, err)
+	<-tester.ctx.Done()
+	require.NoError(t, stateManager.ForceSave())
+
+	// The agent restarts, and the older payload starting the task is
+	// delivered
+	restarted := setup(t)
+	defer restarted.ctrl.Finish()
+	restoredSequenceNumbers := NewSequenceNumbers()
+	restoredStateManager, err := statemanager.NewStateManager(cfg,
+		statemanager.AddSaveable("ACSSequenceNumbers", restoredSequenceNumbers))
+	require.NoError(t, err)
+	require.NoError(t, restoredStateManager.Load())
+	restarted.payloadHandler.saver = restoredStateManager
+	restarted.payloadHandler.sequenceNumbers = restoredSequenceNumbers
+
+	restarted.mockTaskEngine.EXPECT().AddTask(gomock.Any()).Times(0)
+	restarted.mockWsClient.EXPECT().MakeRequest(gomock.Any()).Do(func(ackRequest *ecsacs.AckRequest) {
+		assert.Equal(t, "older", aws.StringValue(ackRequest.MessageId))
+		restarted.cancel()
+	})
+	go restarted.payloadHandler.start()
+	err = restarted.payloadHandler.handleSingleMessage(&ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{{
+			Arn:           aws.String("t1"),
+			DesiredStatus: aws.String("RUNNING"),
+		}},
+		MessageId: aws.String("older"),
+		SeqNum:    aws.Int64(1),
+	})
+	require.NoError(t, err)
+	<-restarted.ctx.Done()
+}
+
+// TestHandlePayloadMessageSkipsTasksSetByNewerPayload tests that the tasks of
+// a payload are skipped when their desired status was set by a newer payload
+// that wasn't processed entirely
+func TestHandlePayloadMessageSkipsTasksSetByNewerPayload(t *testing.T) {
+	tester := setup(t)
+	defer tester.ctrl.Finish()
+
+	tester.mockTaskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		assert.Equal(t, "t2", task.Arn)
+	})
+	tester.mockWsClient.EXPECT().MakeRequest(gomock.Any()).Do(func(ackRequest *ecsacs.AckRequest) {
+		tester.cancel()
+	})
+	tester.payloadHandler.sequenceNumbers.recordTaskDesiredStatus(containerInstanceArn, "t1", 3)
+
+	go tester.payloadHandler.start()
+	err := tester.payloadHandler.handleSingleMessage(&ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{
+			{
+				Arn:           aws.String("t1"),
+				DesiredStatus: aws.String("RUNNING"),
+			},
+			{
+				Arn:           aws.String("t2"),
+				DesiredStatus: aws.String("RUNNING"),
+			},
+		},
+		MessageId: aws.String(payloadMessageId),
+		SeqNum:    aws.Int64(2),
+	})
+	require.NoError(t, err)
+	<-tester.ctx.Done()
+}
+
+// TestHandlePayloadMessageRefusesNewTasksWhileDraining tests that the new tasks
+// of a payload are refused while the instance is draining, and the payload
+// nacked, while the tasks to be stopped and the tasks already managed by the
+// engine are still added
+func TestHandlePayloadMessageRefusesNewTasksWhileDraining(t *testing.T) {
+	tester := setup(t)
+	defer tester.ctrl.Finish()
+	tester.payloadHandler.instanceStatus.setDraining(true)
+
+	tester.mockTaskEngine.EXPECT().GetTaskByArn("t1").Return(nil, false)
+	tester.mockTaskEngine.EXPECT().GetTaskByArn("t2").Return(&apitask.Task{Arn: "t2"}, true)
+	var addedTasks []string
+	tester.mockTaskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		addedTasks = append(addedTasks, task.Arn)
+	}).Times(2)
+	tester.mockWsClient.EXPECT().MakeRequest(gomock.Any()).Do(func(nackRequest *ecsacs.NackRequest) {
+		assert.Equal(t, payloadMessageId, aws.StringValue(nackRequest.MessageId))
+		assert.Contains(t, aws.StringValue(nackRequest.Reason), "t1")
+		tester.cancel()
+	})
+
+	go tester.payloadHandler.start()
+	err := tester.payloadHandler.handleSingleMessage(&ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{
+			{
+				Arn:           aws.String("t1"),
+				DesiredStatus: aws.String("RUNNING"),
+			},
+			{
+				Arn:           aws.String("t2"),
+				DesiredStatus: aws.String("RUNNING"),
+			},
+			{
+				Arn:           aws.String("t3"),
+				DesiredStatus: aws.String("STOPPED"),
+			},
+		},
+		MessageId: aws.String(payloadMessageId),
+		SeqNum:    aws.Int64(1),
+	})
+	require.NoError(t, err)
+	<-tester.ctx.Done()
+	assert.ElementsMatch(t, []string{"t2", "t3"}, addedTasks)
+	// The payload isn't recorded as processed, so that it can be handled
+	// again once the instance is active
+	assert.True(t, tester.payloadHandler.sequenceNumbers.isNewPayload(containerInstanceArn, 1))
+}
+
+// TestHandlePayloadMessageAcceptsNewTasksOnceActive tests that the new tasks of
+// payloads are added again once the instance is no longer draining
+func TestHandlePayloadMessageAcceptsNewTasksOnceActive(t *testing.T) {
+	tester := setup(t)
+	defer tester.ctrl.Finish()
+	tester.payloadHandler.instanceStatus.setDraining(true)
+	tester.payloadHandler.instanceStatus.setDraining(false)
+
+	tester.mockTaskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		assert.Equal(t, "t1", task.Arn)
+	})
+	tester.mockWsClient.EXPECT().MakeRequest(gomock.Any()).Do(func(ackRequest *ecsacs.AckRequest) {
+		assert.Equal(t, payloadMessageId, aws.StringValue(ackRequest.MessageId))
+		tester.cancel()
+	})
+
+	go tester.payloadHandler.start()
+	err := tester.payloadHandler.handleSingleMessage(&ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{
+			{
+				Arn:           aws.String("t1"),
+				DesiredStatus: aws.String("RUNNING"),
+			},
+		},
+		MessageId: aws.String(payloadMessageId),
+	})
+	require.NoError(t, err)
+	<-tester.ctx.Done()
+}
+
+// TestHandlePayloadMessageReportsInvalidTasks tests that the valid tasks of a
+// payload message are added when other tasks fail validation, and that the
+// invalid tasks are reported as stopped and listed in the ack
+func TestHandlePayloadMessageReportsInvalidTasks(t *testing.T) {
+	tester := setup(t)
+	defer tester.ctrl.Finish()
+
+	mockECSACSClient := mock_api.NewMockECSClient(tester.ctrl)
+	tester.payloadHandler.taskHandler = eventhandler.NewTaskHandler(tester.ctx, tester.payloadHandler.saver, nil, mockECSACSClient, nil)
+
+	var addedTask *apitask.Task
+	tester.mockTaskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		addedTask = task
+	}).Times(1)
+
+	var stateChanges sync.WaitGroup
+	stateChanges.Add(5)
+	stoppedReasons := make(map[string]string)
+	var stoppedReasonsLock sync.Mutex
+	mockECSACSClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+		assert.Equal(t, apitaskstatus.TaskStopped, change.Status)
+		stoppedReasonsLock.Lock()
+		stoppedReasons[change.TaskARN] = change.Reason
+		stoppedReasonsLock.Unlock()
+		stateChanges.Done()
+	}).Return(nil).Times(5)
+
+	var ackRequested *ecsacs.AckRequest
+	tester.mockWsClient.EXPECT().MakeRequest(gomock.Any()).Do(func(ackRequest *ecsacs.AckRequest) {
+		ackRequested = ackRequest
+		tester.cancel()
+	}).Times(1)
+
+	go tester.payloadHandler.start()
+
+	err := tester.payloadHandler.handleSingleMessage(&ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{
+			{
+				Arn: aws.String("valid"),
+			},
+			{
+				Arn: aws.String("badNetworkMode"),
+				Containers: []*ecsacs.Container{{
+					Name: aws.String("c1"),
+					DockerConfig: &ecsacs.DockerConfig{
+						HostConfig: aws.String(`{"NetworkMode":"unknown"}`),
+					},
+				}},
+			},
+			{
+				Arn: aws.String("badMountPath"),
+				Containers: []*ecsacs.Container{{
+					Name: aws.String("c1"),
+					MountPoints: []*ecsacs.MountPoint{{
+						SourceVolume: aws.String("volume"),
+					}},
+				}},
+			},
+			{
+				Arn:        aws.String("proxyWithoutENI"),
+				Containers: []*ecsacs.Container{{Name: aws.String("envoy")}},
+				ProxyConfiguration: &ecsacs.ProxyConfiguration{
+					Type:          aws.String("APPMESH"),
+					ContainerName: aws.String("envoy"),
+					Properties: map[string]*string{
+						"IgnoredUID":      aws.String("1337"),
+						"ProxyEgressPort": aws.String("15001"),
+					},
+				},
+			},
+			{
+				Arn:                  aws.String("bandwidthLimitWithoutENI"),
+				EgressBandwidthLimit: aws.Int64(100),
+			},
+			{
+				Arn:                  aws.String("badBandwidthLimit"),
+				EgressBandwidthLimit: aws.Int64(-1),
+			},
+		},
+		MessageId: aws.String(payloadMessageId),
+	})
+	assert.NoError(t, err)
+
+	<-tester.ctx.Done()
+	stateChanges.Wait()
+	assert.Equal(t, "valid", addedTask.Arn)
+	assert.Equal(t, payloadMessageId, aws.StringValue(ackRequested.MessageId))
+	failedTasks := make(map[string]string)
+	for _, failure := range ackRequested.FailedTasks {
+		failedTasks[aws.StringValue(failure.Arn)] = aws.StringValue(failure.Reason)
+	}
+	assert.Len(t, failedTasks, 5)
+	assert.Contains(t, failedTasks["badNetworkMode"], "unknown network mode")
+	assert.Contains(t, failedTasks["badMountPath"], "requires a container path")
+	assert.Contains(t, failedTasks["proxyWithoutENI"], "requires the awsvpc network mode")
+	assert.Contains(t, failedTasks["bandwidthLimitWithoutENI"], "requires the awsvpc network mode")
+	assert.Contains(t, failedTasks["badBandwidthLimit"], "invalid limit")
+	assert.Equal(t, failedTasks, stoppedReasons)
+}
+
 // TestHandlePayloadMessageCredentialsAckedWhenTaskAdded tests if the handler generates
 // an ack after processing a payload message when the payload message contains a task
 // with an IAM Role. It also tests if the credentials ack is generated
@@ -228,7 +515,7 @@ func TestHandlePayloadMessageCredentialsAckedWhenTaskAdded(t *testing.T) {
 		}),
 	)
 
-	refreshCredsHandler := newRefreshCredentialsHandler(tester.ctx, clusterName, containerInstanceArn, tester.mockWsClient, tester.credentialsManager, tester.mockTaskEngine)
+	refreshCredsHandler := newRefreshCredentialsHandler(tester.ctx, clusterName, containerInstanceArn, tester.mockWsClient, tester.credentialsManager, tester.mockTaskEngine, credentialsRefreshWindow)
 	defer refreshCredsHandler.clearAcks()
 	refreshCredsHandler.start()
 	tester.payloadHandler.refreshHandler = refreshCredsHandler
@@ -319,7 +606,7 @@ func TestAddPayloadTaskAddsNonStoppedTasksAfterStoppedTasks(t *testing.T) {
 		MessageId: aws.String(payloadMessageId),
 	}
 
-	_, ok := tester.payloadHandler.addPayloadTasks(payloadMessage)
+	_, _, _, ok := tester.payloadHandler.addPayloadTasks(payloadMessage)
 	assert.True(t, ok)
 	assert.Len(t, tasksAddedToEngine, 2)
 
@@ -416,7 +703,7 @@ func TestPayloadBufferHandlerWithCredentials(t *testing.T) {
 		}),
 	)
 
-	refreshCredsHandler := newRefreshCredentialsHandler(tester.ctx, clusterName, containerInstanceArn, tester.mockWsClient, tester.credentialsManager, tester.mockTaskEngine)
+	refreshCredsHandler := newRefreshCredentialsHandler(tester.ctx, clusterName, containerInstanceArn, tester.mockWsClient, tester.credentialsManager, tester.mockTaskEngine, credentialsRefreshWindow)
 	defer refreshCredsHandler.clearAcks()
 	refreshCredsHandler.start()
 	tester.payloadHandler.refreshHandler = refreshCredsHandler
@@ -538,7 +825,7 @@ func TestAddPayloadTaskAddsExecutionRoles(t *testing.T) {
 			tester.cancel()
 		}),
 	)
-	refreshCredsHandler := newRefreshCredentialsHandler(tester.ctx, clusterName, containerInstanceArn, tester.mockWsClient, tester.credentialsManager, tester.mockTaskEngine)
+	refreshCredsHandler := newRefreshCredentialsHandler(tester.ctx, clusterName, containerInstanceArn, tester.mockWsClient, tester.credentialsManager, tester.mockTaskEngine, credentialsRefreshWindow)
 	defer refreshCredsHandler.clearAcks()
 	refreshCredsHandler.start()
 
@@ -666,6 +953,60 @@ func TestPayloadHandlerAddedENIToTask(t *testing.T) {
 	assert.Equal(t, aws.StringValue(expectedENI.Ipv6Addresses[0].Address), taskeni.IPV6Addresses[0].Address)
 }
 
+func TestPayloadHandlerAddedAppMeshToTask(t *testing.T) {
+	tester := setup(t)
+	defer tester.ctrl.Finish()
+
+	var addedTask *apitask.Task
+	tester.mockTaskEngine.EXPECT().AddTask(gomock.Any()).Do(
+		func(task *apitask.Task) {
+			addedTask = task
+		})
+
+	payloadMessage := &ecsacs.PayloadMessage{
+		Tasks: []*ecsacs.Task{
+			{
+				Arn:        aws.String("arn"),
+				Containers: []*ecsacs.Container{{Name: aws.String("envoy")}},
+				ElasticNetworkInterfaces: []*ecsacs.ElasticNetworkInterface{
+					{
+						AttachmentArn: aws.String("arn"),
+						Ec2Id:         aws.String("ec2id"),
+						Ipv4Addresses: []*ecsacs.IPv4AddressAssignment{
+							{
+								Primary:        aws.Bool(true),
+								PrivateAddress: aws.String("ipv4"),
+							},
+						},
+						MacAddress: aws.String("mac"),
+					},
+				},
+				ProxyConfiguration: &ecsacs.ProxyConfiguration{
+					Type:          aws.String("APPMESH"),
+					ContainerName: aws.String("envoy"),
+					Properties: map[string]*string{
+						"IgnoredUID":       aws.String("1337"),
+						"ProxyIngressPort": aws.String("15000"),
+						"ProxyEgressPort":  aws.String("15001"),
+						"AppPorts":         aws.String("8080"),
+					},
+				},
+			},
+		},
+		MessageId: aws.String(payloadMessageId),
+	}
+
+	err := tester.payloadHandler.handleSingleMessage(payloadMessage)
+	assert.NoError(t, err)
+
+	appMesh := addedTask.GetAppMesh()
+	require.NotNil(t, appMesh)
+	assert.Equal(t, "envoy", appMesh.ContainerName)
+	assert.Equal(t, "1337", appMesh.IgnoredUID)
+	assert.Equal(t, "15001", appMesh.ProxyEgressPort)
+	assert.Equal(t, []string{"8080"}, appMesh.AppPorts)
+}
+
 func TestPayloadHandlerAddedECRAuthData(t *testing.T) {
 	tester := setup(t)
 	defer tester.ctrl.Finish()
@@ -756,7 +1097,7 @@ func TestPayloadHandlerAddedASMAuthData(t *testing.T) {
 	assert.Equal(t, aws.StringValue(expected.AsmAuthData.CredentialsParameter), actual.ASMAuthData.CredentialsParameter)
 }
 
-func TestHandleUnrecognizedTask(t *testing.T) {
+func TestHandleFailedTask(t *testing.T) {
 	tester := setup(t)
 	defer tester.ctrl.Finish()
 
@@ -768,7 +1109,7 @@ func TestHandleUnrecognizedTask(t *testing.T) {
 	}
 
 	mockECSACSClient := mock_api.NewMockECSClient(tester.ctrl)
-	taskHandler := eventhandler.NewTaskHandler(tester.ctx, tester.payloadHandler.saver, nil, mockECSACSClient)
+	taskHandler := eventhandler.NewTaskHandler(tester.ctx, tester.payloadHandler.saver, nil, mockECSACSClient, nil)
 	tester.payloadHandler.taskHandler = taskHandler
 
 	wait := &sync.WaitGroup{}
@@ -779,6 +1120,7 @@ func TestHandleUnrecognizedTask(t *testing.T) {
 		wait.Done()
 	})
 
-	tester.payloadHandler.handleUnrecognizedTask(ecsacsTask, errors.New("test error"), payloadMessage)
+	failure := tester.payloadHandler.handleFailedTask(ecsacsTask, UnrecognizedTaskError{errors.New("test error")}.Error(), payloadMessage)
 	wait.Wait()
+	assert.Equal(t, arn, aws.StringValue(failure.Arn))
 }
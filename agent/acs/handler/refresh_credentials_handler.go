@@ -14,6 +14,7 @@ package handler
 
 import (
 	"fmt"
+	"time"
 
 	"context"
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
@@ -24,6 +25,12 @@ import (
 	"github.com/cihub/seelog"
 )
 
+const (
+	// credentialsExpirationCheckInterval is the interval at which the
+	// credentials are checked for their upcoming expiration
+	credentialsExpirationCheckInterval = time.Minute
+)
+
 // refreshCredentialsHandler represents the refresh credentials operation for the ACS client
 type refreshCredentialsHandler struct {
 	// messageBuffer is used to process IAMRoleCredentialsMessages received from the server
@@ -38,10 +45,13 @@ type refreshCredentialsHandler struct {
 	acsClient          wsclient.ClientServer
 	credentialsManager credentials.Manager
 	taskEngine         engine.TaskEngine
+	// refreshWindow is how long before their expiration the refresh of
+	// credentials, which haven't been refreshed by ACS, is requested
+	refreshWindow time.Duration
 }
 
 // newRefreshCredentialsHandler returns a new refreshCredentialsHandler object
-func newRefreshCredentialsHandler(ctx context.Context, cluster string, containerInstanceArn string, acsClient wsclient.ClientServer, credentialsManager credentials.Manager, taskEngine engine.TaskEngine) refreshCredentialsHandler {
+func newRefreshCredentialsHandler(ctx context.Context, cluster string, containerInstanceArn string, acsClient wsclient.ClientServer, credentialsManager credentials.Manager, taskEngine engine.TaskEngine, refreshWindow time.Duration) refreshCredentialsHandler {
 	// Create a cancelable context from the parent context
 	derivedContext, cancel := context.WithCancel(ctx)
 	return refreshCredentialsHandler{
@@ -54,6 +64,7 @@ func newRefreshCredentialsHandler(ctx context.Context, cluster string, container
 		acsClient:          acsClient,
 		credentialsManager: credentialsManager,
 		taskEngine:         taskEngine,
+		refreshWindow:      refreshWindow,
 	}
 }
 
@@ -61,16 +72,22 @@ func newRefreshCredentialsHandler(ctx context.Context, cluster string, container
 func (refreshHandler *refreshCredentialsHandler) handlerFunc() func(message *ecsacs.IAMRoleCredentialsMessage) {
 	// return a function that just enqueues IAMRoleCredentials messages into the message buffer
 	return func(message *ecsacs.IAMRoleCredentialsMessage) {
-		refreshHandler.messageBuffer <- message
+		select {
+		case refreshHandler.messageBuffer <- message:
+		case <-refreshHandler.ctx.Done():
+			// The handler is stopped, the message is dropped
+		}
 	}
 }
 
 // start invokes go routines to:
 // 1. handle messages in the refresh credentials message buffer
 // 2. handle ack requests to be sent to ACS
+// 3. request the refresh of credentials about to expire
 func (refreshHandler *refreshCredentialsHandler) start() {
 	go refreshHandler.handleMessages()
 	go refreshHandler.sendAcks()
+	go refreshHandler.monitorCredentialsExpiration()
 }
 
 // stop cancels the context being used by the refresh credentials handler. This is used
@@ -100,6 +117,44 @@ func (refreshHandler *refreshCredentialsHandler) ackMessage(ack *ecsacs.IAMRoleC
 	seelog.Debugf("Acking credentials message: %s", ack.String())
 }
 
+// monitorCredentialsExpiration periodically requests the refresh of the
+// credentials about to expire
+func (refreshHandler *refreshCredentialsHandler) monitorCredentialsExpiration() {
+	ticker := time.NewTicker(credentialsExpirationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			refreshHandler.requestExpiringCredentialsRefresh(time.Now())
+		case <-refreshHandler.ctx.Done():
+			return
+		}
+	}
+}
+
+// requestExpiringCredentialsRefresh asks ACS to refresh the credentials that
+// expire within the refresh window, which ACS should have refreshed by then.
+// The request is sent again at every check until the credentials are refreshed
+func (refreshHandler *refreshCredentialsHandler) requestExpiringCredentialsRefresh(now time.Time) {
+	for _, status := range refreshHandler.credentialsManager.GetCredentialsStatus() {
+		if !status.ExpiresWithin(now, refreshHandler.refreshWindow) {
+			continue
+		}
+		seelog.Warnf("Credentials of task %s expire in %s without having been refreshed since %s; requesting refresh, credentialsId: %s",
+			status.TaskARN, status.ExpiresAt.Sub(now).String(), status.RefreshedAt.Format(time.RFC3339), status.CredentialsID)
+		err := refreshHandler.acsClient.MakeRequest(&ecsacs.IAMRoleCredentialsRefreshRequest{
+			CredentialsId: aws.String(status.CredentialsID),
+			Expiration:    aws.String(status.ExpiresAt.Format(time.RFC3339)),
+			RoleType:      aws.String(status.RoleType),
+			TaskArn:       aws.String(status.TaskARN),
+		})
+		if err != nil {
+			seelog.Warnf("Error requesting the refresh of credentials with credentialsId: %s, error: %v", status.CredentialsID, err)
+		}
+	}
+}
+
 // handleMessages processes refresh credentials messages in the buffer in-order
 func (refreshHandler *refreshCredentialsHandler) handleMessages() {
 	for {
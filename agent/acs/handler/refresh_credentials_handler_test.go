@@ -17,6 +17,7 @@ package handler
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"context"
 
@@ -41,6 +42,8 @@ const (
 	sessionToken      = "token"
 	credentialsId     = "credsid"
 	roleType          = "TaskExecution"
+
+	credentialsRefreshWindow = 10 * time.Minute
 )
 
 var expectedAck = &ecsacs.IAMRoleCredentialsAckRequest{
@@ -169,7 +172,7 @@ func TestInvalidCredentialsMessageNotAcked(t *testing.T) {
 	credentialsManager := credentials.NewManager()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := newRefreshCredentialsHandler(ctx, cluster, containerInstance, nil, credentialsManager, nil)
+	handler := newRefreshCredentialsHandler(ctx, cluster, containerInstance, nil, credentialsManager, nil, credentialsRefreshWindow)
 
 	// Start a goroutine to listen for acks. Cancelling the context stops the goroutine
 	go func() {
@@ -206,7 +209,7 @@ func TestCredentialsMessageNotAckedWhenTaskNotFound(t *testing.T) {
 	taskEngine.EXPECT().GetTaskByArn(taskArn).Return(nil, false)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := newRefreshCredentialsHandler(ctx, cluster, containerInstance, nil, credentialsManager, taskEngine)
+	handler := newRefreshCredentialsHandler(ctx, cluster, containerInstance, nil, credentialsManager, taskEngine, credentialsRefreshWindow)
 
 	// Start a goroutine to listen for acks. Cancelling the context stops the goroutine
 	go func() {
@@ -249,7 +252,7 @@ func TestHandleRefreshMessageAckedWhenCredentialsUpdated(t *testing.T) {
 	// Return a task from the engine for GetTaskByArn
 	taskEngine.EXPECT().GetTaskByArn(taskArn).Return(&apitask.Task{}, true)
 
-	handler := newRefreshCredentialsHandler(ctx, clusterName, containerInstanceArn, mockWsClient, credentialsManager, taskEngine)
+	handler := newRefreshCredentialsHandler(ctx, clusterName, containerInstanceArn, mockWsClient, credentialsManager, taskEngine, credentialsRefreshWindow)
 	go handler.sendAcks()
 
 	// test adding a credentials message without the MessageId field
@@ -295,7 +298,7 @@ func TestRefreshCredentialsHandler(t *testing.T) {
 	// Return a task from the engine for GetTaskByArn
 	taskEngine.EXPECT().GetTaskByArn(taskArn).Return(&apitask.Task{}, true)
 
-	handler := newRefreshCredentialsHandler(ctx, clusterName, containerInstanceArn, mockWsClient, credentialsManager, taskEngine)
+	handler := newRefreshCredentialsHandler(ctx, clusterName, containerInstanceArn, mockWsClient, credentialsManager, taskEngine, credentialsRefreshWindow)
 	go handler.start()
 
 	handler.messageBuffer <- message
@@ -316,3 +319,37 @@ func TestRefreshCredentialsHandler(t *testing.T) {
 		t.Errorf("Mismatch between expected credentials and credentials for task. Expected: %v, got: %v", expectedCredentials, creds)
 	}
 }
+
+// TestRequestExpiringCredentialsRefresh tests that the refresh of the
+// credentials expiring within the refresh window is requested
+func TestRequestExpiringCredentialsRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	credentialsManager := credentials.NewManager()
+	for id, expiresIn := range map[string]time.Duration{
+		"expiring": 5 * time.Minute,
+		"valid":    time.Hour,
+	} {
+		credentialsManager.SetTaskCredentials(credentials.TaskIAMRoleCredentials{
+			ARN: taskArn,
+			IAMRoleCredentials: credentials.IAMRoleCredentials{
+				CredentialsID: id,
+				Expiration:    now.Add(expiresIn).UTC().Format(time.RFC3339),
+				RoleType:      credentials.ApplicationRoleType,
+			},
+		})
+	}
+	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
+	handler := newRefreshCredentialsHandler(context.TODO(), clusterName, containerInstanceArn, mockWSClient,
+		credentialsManager, nil, credentialsRefreshWindow)
+
+	mockWSClient.EXPECT().MakeRequest(&ecsacs.IAMRoleCredentialsRefreshRequest{
+		CredentialsId: aws.String("expiring"),
+		Expiration:    aws.String(now.Add(5 * time.Minute).UTC().Format(time.RFC3339)),
+		RoleType:      aws.String(credentials.ApplicationRoleType),
+		TaskArn:       aws.String(taskArn),
+	}).Return(nil)
+	handler.requestExpiringCredentialsRefresh(now)
+}
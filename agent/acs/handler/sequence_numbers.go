@@ -0,0 +1,143 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// SequenceNumbers tracks the sequence numbers of the payloads processed from
+// ACS, for the container instance and for the desired status of each task. It
+// is saved in the state file so that payloads which are replayed or delivered
+// out of order after the agent restarts aren't processed over newer ones
+type SequenceNumbers struct {
+	// containerInstanceArn is the container instance the sequence numbers
+	// were received for. They're forgotten when the instance changes
+	containerInstanceArn string
+	// payload is the sequence number of the last payload that was processed
+	// entirely
+	payload int64
+	// tasks maps the arns of tasks to the sequence number of the payload
+	// their desired status was last set by. Only the sequence numbers newer
+	// than payload are kept, the older ones are covered by it
+	tasks map[string]int64
+	lock  sync.RWMutex
+}
+
+// sequenceNumbersJSON is the json representation of SequenceNumbers
+type sequenceNumbersJSON struct {
+	ContainerInstanceArn string           `json:"ContainerInstanceArn,omitempty"`
+	Payload              int64            `json:"Payload,omitempty"`
+	Tasks                map[string]int64 `json:"Tasks,omitempty"`
+}
+
+// NewSequenceNumbers returns a SequenceNumbers without any sequence number
+func NewSequenceNumbers() *SequenceNumbers {
+	return &SequenceNumbers{
+		tasks: make(map[string]int64),
+	}
+}
+
+// isNewPayload returns true if no payload with the same or a newer sequence
+// number was processed entirely for the container instance
+func (seqNums *SequenceNumbers) isNewPayload(containerInstanceArn string, seqNum int64) bool {
+	seqNums.lock.Lock()
+	defer seqNums.lock.Unlock()
+
+	seqNums.resetUnsafe(containerInstanceArn)
+	return seqNum > seqNums.payload
+}
+
+// isNewTaskDesiredStatus returns true if the desired status of the task wasn't
+// set by a payload with the same or a newer sequence number
+func (seqNums *SequenceNumbers) isNewTaskDesiredStatus(containerInstanceArn string, taskArn string, seqNum int64) bool {
+	seqNums.lock.Lock()
+	defer seqNums.lock.Unlock()
+
+	seqNums.resetUnsafe(containerInstanceArn)
+	return seqNum > seqNums.payload && seqNum > seqNums.tasks[taskArn]
+}
+
+// recordTaskDesiredStatus records that the desired status of the task was set
+// by the payload with the sequence number
+func (seqNums *SequenceNumbers) recordTaskDesiredStatus(containerInstanceArn string, taskArn string, seqNum int64) {
+	seqNums.lock.Lock()
+	defer seqNums.lock.Unlock()
+
+	seqNums.resetUnsafe(containerInstanceArn)
+	if seqNum > seqNums.payload && seqNum > seqNums.tasks[taskArn] {
+		seqNums.tasks[taskArn] = seqNum
+	}
+}
+
+// recordPayload records that the payload with the sequence number was
+// processed entirely
+func (seqNums *SequenceNumbers) recordPayload(containerInstanceArn string, seqNum int64) {
+	seqNums.lock.Lock()
+	defer seqNums.lock.Unlock()
+
+	seqNums.resetUnsafe(containerInstanceArn)
+	if seqNum <= seqNums.payload {
+		return
+	}
+	seqNums.payload = seqNum
+	for taskArn, taskSeqNum := range seqNums.tasks {
+		if taskSeqNum <= seqNum {
+			delete(seqNums.tasks, taskArn)
+		}
+	}
+}
+
+// resetUnsafe forgets the sequence numbers if they were received for another
+// container instance, as the sequence numbers of ACS are per instance
+func (seqNums *SequenceNumbers) resetUnsafe(containerInstanceArn string) {
+	if seqNums.containerInstanceArn == containerInstanceArn {
+		return
+	}
+	seqNums.containerInstanceArn = containerInstanceArn
+	seqNums.payload = 0
+	seqNums.tasks = make(map[string]int64)
+}
+
+// MarshalJSON marshals the sequence numbers to be saved in the state file
+func (seqNums *SequenceNumbers) MarshalJSON() ([]byte, error) {
+	seqNums.lock.RLock()
+	defer seqNums.lock.RUnlock()
+
+	return json.Marshal(&sequenceNumbersJSON{
+		ContainerInstanceArn: seqNums.containerInstanceArn,
+		Payload:              seqNums.payload,
+		Tasks:                seqNums.tasks,
+	})
+}
+
+// UnmarshalJSON loads the sequence numbers saved in the state file
+func (seqNums *SequenceNumbers) UnmarshalJSON(b []byte) error {
+	var restored sequenceNumbersJSON
+	if err := json.Unmarshal(b, &restored); err != nil {
+		return err
+	}
+
+	seqNums.lock.Lock()
+	defer seqNums.lock.Unlock()
+
+	seqNums.containerInstanceArn = restored.ContainerInstanceArn
+	seqNums.payload = restored.Payload
+	seqNums.tasks = make(map[string]int64)
+	for taskArn, seqNum := range restored.Tasks {
+		seqNums.tasks[taskArn] = seqNum
+	}
+	return nil
+}
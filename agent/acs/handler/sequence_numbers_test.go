@@ -0,0 +1,78 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequenceNumbersPayload(t *testing.T) {
+	seqNums := NewSequenceNumbers()
+	assert.True(t, seqNums.isNewPayload("instance", 1))
+
+	seqNums.recordPayload("instance", 2)
+	assert.False(t, seqNums.isNewPayload("instance", 1))
+	assert.False(t, seqNums.isNewPayload("instance", 2))
+	assert.True(t, seqNums.isNewPayload("instance", 3))
+
+	// Recording an older payload doesn't move the sequence number back
+	seqNums.recordPayload("instance", 1)
+	assert.False(t, seqNums.isNewPayload("instance", 2))
+}
+
+func TestSequenceNumbersTaskDesiredStatus(t *testing.T) {
+	seqNums := NewSequenceNumbers()
+	seqNums.recordTaskDesiredStatus("instance", "t1", 3)
+	assert.False(t, seqNums.isNewTaskDesiredStatus("instance", "t1", 2))
+	assert.False(t, seqNums.isNewTaskDesiredStatus("instance", "t1", 3))
+	assert.True(t, seqNums.isNewTaskDesiredStatus("instance", "t1", 4))
+	assert.True(t, seqNums.isNewTaskDesiredStatus("instance", "t2", 2))
+
+	// The sequence numbers of tasks covered by the payload sequence number
+	// are forgotten
+	seqNums.recordPayload("instance", 3)
+	assert.Empty(t, seqNums.tasks)
+	assert.False(t, seqNums.isNewTaskDesiredStatus("instance", "t1", 3))
+}
+
+func TestSequenceNumbersResetForNewContainerInstance(t *testing.T) {
+	seqNums := NewSequenceNumbers()
+	seqNums.recordPayload("instance", 5)
+	seqNums.recordTaskDesiredStatus("instance", "t1", 6)
+
+	assert.True(t, seqNums.isNewPayload("other-instance", 1))
+	assert.True(t, seqNums.isNewTaskDesiredStatus("other-instance", "t1", 1))
+}
+
+func TestSequenceNumbersJSON(t *testing.T) {
+	seqNums := NewSequenceNumbers()
+	seqNums.recordPayload("instance", 5)
+	seqNums.recordTaskDesiredStatus("instance", "t1", 7)
+
+	data, err := json.Marshal(seqNums)
+	require.NoError(t, err)
+	restored := NewSequenceNumbers()
+	require.NoError(t, json.Unmarshal(data, restored))
+
+	assert.False(t, restored.isNewPayload("instance", 5))
+	assert.True(t, restored.isNewPayload("instance", 6))
+	assert.False(t, restored.isNewTaskDesiredStatus("instance", "t1", 6))
+	assert.True(t, restored.isNewTaskDesiredStatus("instance", "t1", 8))
+}
@@ -0,0 +1,186 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"context"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/wsclient"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// taskNotInManifestReason is the reason recorded for the tasks stopped
+	// because they are missing from the task manifest
+	taskNotInManifestReason = "task no longer present in backend manifest"
+)
+
+// taskManifestHandler reconciles the tasks managed by the engine with the task
+// manifest, the list of all the tasks ACS expects the instance to run, which is
+// sent after the agent reconnects
+type taskManifestHandler struct {
+	messageBuffer     chan *ecsacs.TaskManifestMessage
+	ctx               context.Context
+	cancel            context.CancelFunc
+	cluster           *string
+	containerInstance *string
+	acsClient         wsclient.ClientServer
+	taskEngine        engine.TaskEngine
+}
+
+// newTaskManifestHandler returns an instance of the taskManifestHandler struct
+func newTaskManifestHandler(ctx context.Context,
+	cluster string,
+	containerInstanceArn string,
+	acsClient wsclient.ClientServer,
+	taskEngine engine.TaskEngine) taskManifestHandler {
+
+	// Create a cancelable context from the parent context
+	derivedContext, cancel := context.WithCancel(ctx)
+	return taskManifestHandler{
+		messageBuffer:     make(chan *ecsacs.TaskManifestMessage),
+		ctx:               derivedContext,
+		cancel:            cancel,
+		cluster:           aws.String(cluster),
+		containerInstance: aws.String(containerInstanceArn),
+		acsClient:         acsClient,
+		taskEngine:        taskEngine,
+	}
+}
+
+// handlerFunc returns a function to enqueue requests onto the taskManifestHandler buffer
+func (handler *taskManifestHandler) handlerFunc() func(message *ecsacs.TaskManifestMessage) {
+	return func(message *ecsacs.TaskManifestMessage) {
+		select {
+		case handler.messageBuffer <- message:
+		case <-handler.ctx.Done():
+			// The handler is stopped, the message is dropped
+		}
+	}
+}
+
+// start invokes handleMessages to reconcile the tasks with each enqueued manifest
+func (handler *taskManifestHandler) start() {
+	go handler.handleMessages()
+}
+
+// stop is used to invoke a cancellation function
+func (handler *taskManifestHandler) stop() {
+	handler.cancel()
+}
+
+// handleMessages handles each message one at a time
+func (handler *taskManifestHandler) handleMessages() {
+	for {
+		select {
+		case message := <-handler.messageBuffer:
+			if err := handler.handleSingleMessage(message); err != nil {
+				seelog.Warnf("Unable to handle task manifest message [%s]: %v", message.String(), err)
+			}
+		case <-handler.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleSingleMessage stops the tasks missing from the manifest and acks the
+// message with the tasks of the manifest that the engine doesn't manage, so
+// that ACS sends their payloads again
+func (handler *taskManifestHandler) handleSingleMessage(message *ecsacs.TaskManifestMessage) error {
+	if err := validateTaskManifestMessage(message); err != nil {
+		return errors.Wrapf(err,
+			"task manifest handler: error validating TaskManifest message received from ECS")
+	}
+
+	manifestTaskARNs := make(map[string]struct{})
+	for _, taskARN := range message.TaskArns {
+		manifestTaskARNs[aws.StringValue(taskARN)] = struct{}{}
+	}
+	tasks, err := handler.taskEngine.ListTasks()
+	if err != nil {
+		return errors.Wrapf(err, "task manifest handler: unable to list the tasks of the engine")
+	}
+
+	knownTaskARNs := make(map[string]struct{})
+	var staleTaskARNs []string
+	for _, task := range tasks {
+		knownTaskARNs[task.Arn] = struct{}{}
+		if _, ok := manifestTaskARNs[task.Arn]; ok {
+			continue
+		}
+		if task.GetDesiredStatus().Terminal() {
+			continue
+		}
+		// Tasks received in payloads sent after the manifest was generated
+		// are missing from it, but aren't stale
+		if message.Timeline != nil && task.StartSequenceNumber > aws.Int64Value(message.Timeline) {
+			seelog.Debugf("Task manifest handler: task [%s] started after the manifest, sequence number %d is newer than %d",
+				task.Arn, task.StartSequenceNumber, aws.Int64Value(message.Timeline))
+			continue
+		}
+		staleTaskARNs = append(staleTaskARNs, task.Arn)
+	}
+	if len(staleTaskARNs) != 0 {
+		seelog.Infof("Task manifest handler: stopping tasks missing from the task manifest: %v", staleTaskARNs)
+		handler.taskEngine.StopTasks(staleTaskARNs, apireason.ReasonCodeTaskNotInManifest, taskNotInManifestReason)
+	}
+
+	var unknownTaskARNs []*string
+	for _, taskARN := range message.TaskArns {
+		if _, ok := knownTaskARNs[aws.StringValue(taskARN)]; !ok {
+			unknownTaskARNs = append(unknownTaskARNs, taskARN)
+		}
+	}
+	if len(unknownTaskARNs) != 0 {
+		seelog.Infof("Task manifest handler: %d tasks of the task manifest are unknown", len(unknownTaskARNs))
+	}
+
+	if err := handler.acsClient.MakeRequest(&ecsacs.TaskManifestAckRequest{
+		Cluster:           message.ClusterArn,
+		ContainerInstance: message.ContainerInstanceArn,
+		MessageId:         message.MessageId,
+		UnknownTaskArns:   unknownTaskARNs,
+	}); err != nil {
+		return errors.Wrapf(err, "task manifest handler: unable to ack message with messageId: %s",
+			aws.StringValue(message.MessageId))
+	}
+	return nil
+}
+
+// validateTaskManifestMessage performs validation checks on the
+// TaskManifestMessage
+func validateTaskManifestMessage(message *ecsacs.TaskManifestMessage) error {
+	if message == nil {
+		return errors.Errorf("task manifest handler validation: empty TaskManifest message received from ECS")
+	}
+
+	if aws.StringValue(message.MessageId) == "" {
+		return errors.Errorf("task manifest handler validation: message id not set in TaskManifest message received from ECS")
+	}
+
+	if aws.StringValue(message.ClusterArn) == "" {
+		return errors.Errorf("task manifest handler validation: clusterArn not set in TaskManifest message received from ECS")
+	}
+
+	if aws.StringValue(message.ContainerInstanceArn) == "" {
+		return errors.Errorf("task manifest handler validation: containerInstanceArn not set in TaskManifest message received from ECS")
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/engine/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/wsclient/mock"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const taskManifestMessageId = "manifest"
+
+func TestTaskManifestMessageValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		message *ecsacs.TaskManifestMessage
+	}{
+		{"empty message", nil},
+		{"no messageId", &ecsacs.TaskManifestMessage{
+			ClusterArn:           aws.String(clusterName),
+			ContainerInstanceArn: aws.String(containerInstanceArn),
+		}},
+		{"no clusterArn", &ecsacs.TaskManifestMessage{
+			MessageId:            aws.String(taskManifestMessageId),
+			ContainerInstanceArn: aws.String(containerInstanceArn),
+		}},
+		{"no containerInstanceArn", &ecsacs.TaskManifestMessage{
+			MessageId:  aws.String(taskManifestMessageId),
+			ClusterArn: aws.String(clusterName),
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Error(t, validateTaskManifestMessage(tc.message))
+		})
+	}
+}
+
+func TestTaskManifestStopsStaleTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
+	handler := newTaskManifestHandler(context.TODO(), clusterName, containerInstanceArn, mockWSClient, taskEngine)
+
+	tasks := []*apitask.Task{
+		{Arn: "listed", DesiredStatusUnsafe: apitaskstatus.TaskRunning, StartSequenceNumber: 2},
+		{Arn: "stale", DesiredStatusUnsafe: apitaskstatus.TaskRunning, StartSequenceNumber: 3},
+		{Arn: "newer", DesiredStatusUnsafe: apitaskstatus.TaskRunning, StartSequenceNumber: 6},
+		{Arn: "stopping", DesiredStatusUnsafe: apitaskstatus.TaskStopped, StartSequenceNumber: 1},
+	}
+	gomock.InOrder(
+		taskEngine.EXPECT().ListTasks().Return(tasks, nil),
+		taskEngine.EXPECT().StopTasks([]string{"stale"}, apireason.ReasonCodeTaskNotInManifest, taskNotInManifestReason),
+		mockWSClient.EXPECT().MakeRequest(&ecsacs.TaskManifestAckRequest{
+			Cluster:           aws.String(clusterName),
+			ContainerInstance: aws.String(containerInstanceArn),
+			MessageId:         aws.String(taskManifestMessageId),
+			UnknownTaskArns:   []*string{aws.String("unknown")},
+		}),
+	)
+
+	err := handler.handleSingleMessage(&ecsacs.TaskManifestMessage{
+		MessageId:            aws.String(taskManifestMessageId),
+		ClusterArn:           aws.String(clusterName),
+		ContainerInstanceArn: aws.String(containerInstanceArn),
+		TaskArns:             []*string{aws.String("listed"), aws.String("unknown")},
+		Timeline:             aws.Int64(5),
+	})
+	assert.NoError(t, err)
+}
+
+func TestTaskManifestNoStaleTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+	mockWSClient := mock_wsclient.NewMockClientServer(ctrl)
+	handler := newTaskManifestHandler(context.TODO(), clusterName, containerInstanceArn, mockWSClient, taskEngine)
+
+	tasks := []*apitask.Task{
+		{Arn: "listed", DesiredStatusUnsafe: apitaskstatus.TaskRunning},
+	}
+	taskEngine.EXPECT().ListTasks().Return(tasks, nil)
+	mockWSClient.EXPECT().MakeRequest(gomock.Any()).Do(func(ack *ecsacs.TaskManifestAckRequest) {
+		assert.Equal(t, taskManifestMessageId, aws.StringValue(ack.MessageId))
+		assert.Empty(t, ack.UnknownTaskArns)
+	})
+
+	err := handler.handleSingleMessage(&ecsacs.TaskManifestMessage{
+		MessageId:            aws.String(taskManifestMessageId),
+		ClusterArn:           aws.String(clusterName),
+		ContainerInstanceArn: aws.String(containerInstanceArn),
+		TaskArns:             []*string{aws.String("listed")},
+	})
+	assert.NoError(t, err)
+}
@@ -56,6 +56,8 @@ type AckRequest struct {
 
 	ContainerInstance *string `locationName:"containerInstance" type:"string"`
 
+	FailedTasks []*TaskFailure `locationName:"failedTasks" type:"list"`
+
 	MessageId *string `locationName:"messageId" type:"string"`
 }
 
@@ -180,10 +182,18 @@ func (s CloseMessage) GoString() string {
 type Container struct {
 	_ struct{} `type:"structure"`
 
+	CapAdd []*string `locationName:"capAdd" type:"list"`
+
+	CapDrop []*string `locationName:"capDrop" type:"list"`
+
 	Command []*string `locationName:"command" type:"list"`
 
 	Cpu *int64 `locationName:"cpu" type:"integer"`
 
+	DependsOn []*ContainerDependency `locationName:"dependsOn" type:"list"`
+
+	Devices []*Device `locationName:"devices" type:"list"`
+
 	DockerConfig *DockerConfig `locationName:"dockerConfig" type:"structure"`
 
 	EntryPoint []*string `locationName:"entryPoint" type:"list"`
@@ -192,16 +202,26 @@ type Container struct {
 
 	Essential *bool `locationName:"essential" type:"boolean"`
 
+	GpuCount *int64 `locationName:"gpuCount" type:"integer"`
+
+	HealthCheck *HealthCheck `locationName:"healthCheck" type:"structure"`
+
 	HealthCheckType *string `locationName:"healthCheckType" type:"string" enum:"HealthCheckType"`
 
 	Image *string `locationName:"image" type:"string"`
 
+	ImagePullBehavior *string `locationName:"imagePullBehavior" type:"string" enum:"ImagePullBehavior"`
+
+	InitProcessEnabled *bool `locationName:"initProcessEnabled" type:"boolean"`
+
 	Links []*string `locationName:"links" type:"list"`
 
 	LogsAuthStrategy *string `locationName:"logsAuthStrategy" type:"string" enum:"AuthStrategy"`
 
 	Memory *int64 `locationName:"memory" type:"integer"`
 
+	MemorySwappiness *int64 `locationName:"memorySwappiness" type:"integer"`
+
 	MountPoints []*MountPoint `locationName:"mountPoints" type:"list"`
 
 	Name *string `locationName:"name" type:"string"`
@@ -210,10 +230,28 @@ type Container struct {
 
 	PortMappings []*PortMapping `locationName:"portMappings" type:"list"`
 
+	ReadonlyRootFilesystem *bool `locationName:"readonlyRootFilesystem" type:"boolean"`
+
 	RegistryAuthentication *RegistryAuthenticationData `locationName:"registryAuthentication" type:"structure"`
 
+	RestartPolicy *ContainerRestartPolicy `locationName:"restartPolicy" type:"structure"`
+
 	Secrets []*Secret `locationName:"secrets" type:"list"`
 
+	ShmSize *int64 `locationName:"shmSize" type:"integer"`
+
+	StartTimeout *int64 `locationName:"startTimeout" type:"integer"`
+
+	StopSignal *string `locationName:"stopSignal" type:"string"`
+
+	StopTimeout *int64 `locationName:"stopTimeout" type:"integer"`
+
+	SystemControls map[string]*string `locationName:"systemControls" type:"map"`
+
+	Tmpfs []*Tmpfs `locationName:"tmpfs" type:"list"`
+
+	Ulimits []*Ulimit `locationName:"ulimits" type:"list"`
+
 	VolumesFrom []*VolumeFrom `locationName:"volumesFrom" type:"list"`
 }
 
@@ -227,6 +265,64 @@ func (s Container) GoString() string {
 	return s.String()
 }
 
+type ContainerDependency struct {
+	_ struct{} `type:"structure"`
+
+	Condition *string `locationName:"condition" type:"string" enum:"ContainerCondition"`
+
+	ContainerName *string `locationName:"containerName" type:"string"`
+}
+
+// String returns the string representation
+func (s ContainerDependency) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s ContainerDependency) GoString() string {
+	return s.String()
+}
+
+type ContainerRestartPolicy struct {
+	_ struct{} `type:"structure"`
+
+	Attempts *int64 `locationName:"attempts" type:"integer"`
+
+	IgnoredExitCodes []*int64 `locationName:"ignoredExitCodes" type:"list"`
+
+	RestartAttemptPeriod *int64 `locationName:"restartAttemptPeriod" type:"integer"`
+}
+
+// String returns the string representation
+func (s ContainerRestartPolicy) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s ContainerRestartPolicy) GoString() string {
+	return s.String()
+}
+
+type Device struct {
+	_ struct{} `type:"structure"`
+
+	ContainerPath *string `locationName:"containerPath" type:"string"`
+
+	HostPath *string `locationName:"hostPath" type:"string"`
+
+	Permissions *string `locationName:"permissions" type:"string"`
+}
+
+// String returns the string representation
+func (s Device) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s Device) GoString() string {
+	return s.String()
+}
+
 type DockerConfig struct {
 	_ struct{} `type:"structure"`
 
@@ -313,6 +409,8 @@ type ElasticNetworkInterface struct {
 	PrivateDnsName *string `locationName:"privateDnsName" type:"string"`
 
 	SubnetGatewayIpv4Address *string `locationName:"subnetGatewayIpv4Address" type:"string"`
+
+	SubnetGatewayIpv6Address *string `locationName:"subnetGatewayIpv6Address" type:"string"`
 }
 
 // String returns the string representation
@@ -371,6 +469,30 @@ func (s ErrorOutput) GoString() string {
 	return s.String()
 }
 
+type HealthCheck struct {
+	_ struct{} `type:"structure"`
+
+	Command []*string `locationName:"command" type:"list"`
+
+	Interval *int64 `locationName:"interval" type:"integer"`
+
+	Retries *int64 `locationName:"retries" type:"integer"`
+
+	StartPeriod *int64 `locationName:"startPeriod" type:"integer"`
+
+	Timeout *int64 `locationName:"timeout" type:"integer"`
+}
+
+// String returns the string representation
+func (s HealthCheck) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s HealthCheck) GoString() string {
+	return s.String()
+}
+
 type HeartbeatInput struct {
 	_ struct{} `type:"structure"`
 
@@ -501,6 +623,28 @@ func (s IAMRoleCredentialsMessage) GoString() string {
 	return s.String()
 }
 
+type IAMRoleCredentialsRefreshRequest struct {
+	_ struct{} `type:"structure"`
+
+	CredentialsId *string `locationName:"credentialsId" type:"string"`
+
+	Expiration *string `locationName:"expiration" type:"string"`
+
+	RoleType *string `locationName:"roleType" type:"string"`
+
+	TaskArn *string `locationName:"taskArn" type:"string"`
+}
+
+// String returns the string representation
+func (s IAMRoleCredentialsRefreshRequest) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s IAMRoleCredentialsRefreshRequest) GoString() string {
+	return s.String()
+}
+
 type IPv4AddressAssignment struct {
 	_ struct{} `type:"structure"`
 
@@ -551,6 +695,28 @@ func (s InactiveInstanceException) GoString() string {
 	return s.String()
 }
 
+type InstanceStatusMessage struct {
+	_ struct{} `type:"structure"`
+
+	ClusterArn *string `locationName:"clusterArn" type:"string"`
+
+	ContainerInstanceArn *string `locationName:"containerInstanceArn" type:"string"`
+
+	MessageId *string `locationName:"messageId" type:"string"`
+
+	Status *string `locationName:"status" type:"string" enum:"InstanceStatus"`
+}
+
+// String returns the string representation
+func (s InstanceStatusMessage) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s InstanceStatusMessage) GoString() string {
+	return s.String()
+}
+
 type InvalidClusterException struct {
 	_ struct{} `type:"structure"`
 
@@ -588,6 +754,8 @@ type MountPoint struct {
 
 	ContainerPath *string `locationName:"containerPath" type:"string"`
 
+	Propagation *string `locationName:"propagation" type:"string" enum:"MountPropagation"`
+
 	ReadOnly *bool `locationName:"readOnly" type:"boolean"`
 
 	SourceVolume *string `locationName:"sourceVolume" type:"string"`
@@ -845,6 +1013,26 @@ func (s PortMapping) GoString() string {
 	return s.String()
 }
 
+type ProxyConfiguration struct {
+	_ struct{} `type:"structure"`
+
+	ContainerName *string `locationName:"containerName" type:"string"`
+
+	Properties map[string]*string `locationName:"properties" type:"map"`
+
+	Type *string `locationName:"type" type:"string"`
+}
+
+// String returns the string representation
+func (s ProxyConfiguration) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s ProxyConfiguration) GoString() string {
+	return s.String()
+}
+
 type RefreshTaskIAMRoleCredentialsInput struct {
 	_ struct{} `type:"structure"`
 
@@ -1018,14 +1206,22 @@ type Task struct {
 
 	Arn *string `locationName:"arn" type:"string"`
 
+	BlockInstanceMetadata *bool `locationName:"blockInstanceMetadata" type:"boolean"`
+
+	CleanupWaitDurationSeconds *int64 `locationName:"cleanupWaitDurationSeconds" type:"long"`
+
 	Containers []*Container `locationName:"containers" type:"list"`
 
 	Cpu *float64 `locationName:"cpu" type:"double"`
 
 	DesiredStatus *string `locationName:"desiredStatus" type:"string"`
 
+	EgressBandwidthLimit *int64 `locationName:"egressBandwidthLimit" type:"integer"`
+
 	ElasticNetworkInterfaces []*ElasticNetworkInterface `locationName:"elasticNetworkInterfaces" type:"list"`
 
+	EphemeralStorageLimit *int64 `locationName:"ephemeralStorageLimit" type:"integer"`
+
 	ExecutionRoleCredentials *IAMRoleCredentials `locationName:"executionRoleCredentials" type:"structure"`
 
 	Family *string `locationName:"family" type:"string"`
@@ -1038,8 +1234,12 @@ type Task struct {
 
 	PidMode *string `locationName:"pidMode" type:"string"`
 
+	ProxyConfiguration *ProxyConfiguration `locationName:"proxyConfiguration" type:"structure"`
+
 	RoleCredentials *IAMRoleCredentials `locationName:"roleCredentials" type:"structure"`
 
+	StoppedReason *string `locationName:"stoppedReason" type:"string"`
+
 	TaskDefinitionAccountId *string `locationName:"taskDefinitionAccountId" type:"string"`
 
 	Version *string `locationName:"version" type:"string"`
@@ -1057,6 +1257,112 @@ func (s Task) GoString() string {
 	return s.String()
 }
 
+type TaskFailure struct {
+	_ struct{} `type:"structure"`
+
+	Arn *string `locationName:"arn" type:"string"`
+
+	Reason *string `locationName:"reason" type:"string"`
+}
+
+// String returns the string representation
+func (s TaskFailure) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s TaskFailure) GoString() string {
+	return s.String()
+}
+
+type TaskManifestAckRequest struct {
+	_ struct{} `type:"structure"`
+
+	Cluster *string `locationName:"cluster" type:"string"`
+
+	ContainerInstance *string `locationName:"containerInstance" type:"string"`
+
+	MessageId *string `locationName:"messageId" type:"string"`
+
+	UnknownTaskArns []*string `locationName:"unknownTaskArns" type:"list"`
+}
+
+// String returns the string representation
+func (s TaskManifestAckRequest) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s TaskManifestAckRequest) GoString() string {
+	return s.String()
+}
+
+type TaskManifestMessage struct {
+	_ struct{} `type:"structure"`
+
+	ClusterArn *string `locationName:"clusterArn" type:"string"`
+
+	ContainerInstanceArn *string `locationName:"containerInstanceArn" type:"string"`
+
+	GeneratedAt *int64 `locationName:"generatedAt" type:"long"`
+
+	MessageId *string `locationName:"messageId" type:"string"`
+
+	TaskArns []*string `locationName:"taskArns" type:"list"`
+
+	Timeline *int64 `locationName:"timeline" type:"long"`
+}
+
+// String returns the string representation
+func (s TaskManifestMessage) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s TaskManifestMessage) GoString() string {
+	return s.String()
+}
+
+type Tmpfs struct {
+	_ struct{} `type:"structure"`
+
+	ContainerPath *string `locationName:"containerPath" type:"string"`
+
+	MountOptions []*string `locationName:"mountOptions" type:"list"`
+
+	Size *int64 `locationName:"size" type:"integer"`
+}
+
+// String returns the string representation
+func (s Tmpfs) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s Tmpfs) GoString() string {
+	return s.String()
+}
+
+type Ulimit struct {
+	_ struct{} `type:"structure"`
+
+	Hard *int64 `locationName:"hard" type:"integer"`
+
+	Name *string `locationName:"name" type:"string"`
+
+	Soft *int64 `locationName:"soft" type:"integer"`
+}
+
+// String returns the string representation
+func (s Ulimit) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s Ulimit) GoString() string {
+	return s.String()
+}
+
 type UpdateFailureInput struct {
 	_ struct{} `type:"structure"`
 
@@ -0,0 +1,139 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package agenthealth monitors the health of the agent process and of its
+// connection to the docker daemon
+package agenthealth
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/cihub/seelog"
+)
+
+const (
+	// sampleInterval is how often the health of the agent is sampled
+	sampleInterval = 30 * time.Second
+	// pingTimeout bounds each ping of the docker daemon
+	pingTimeout = 10 * time.Second
+	// degradedPingFailures is the number of consecutive failed pings of the
+	// docker daemon after which the agent is degraded
+	degradedPingFailures = 3
+)
+
+// Health is a sample of the health of the agent
+type Health struct {
+	// Timestamp is when the health was sampled, the zero time before the
+	// first sample
+	Timestamp time.Time `json:"timestamp"`
+	// Goroutines is the number of goroutines of the agent
+	Goroutines int `json:"goroutines"`
+	// HeapInUseBytes is the size of the heap spans in use
+	HeapInUseBytes uint64 `json:"heapInUseBytes"`
+	// GCPauseTotalMs is the total time the garbage collector stopped the
+	// agent since it started
+	GCPauseTotalMs float64 `json:"gcPauseTotalMs"`
+	// NumGC is the number of garbage collections since the agent started
+	NumGC uint32 `json:"numGC"`
+	// DockerPingLatencyMs is the round trip time of the last successful ping
+	// of the docker daemon
+	DockerPingLatencyMs float64 `json:"dockerPingLatencyMs"`
+	// DockerPingFailures is the number of consecutive failed pings of the
+	// docker daemon
+	DockerPingFailures int `json:"dockerPingFailures"`
+	// DockerPingError is the error of the last ping if it failed
+	DockerPingError string `json:"dockerPingError,omitempty"`
+	// Degraded is true while the docker daemon is unreachable
+	Degraded bool `json:"degraded"`
+}
+
+// Monitor periodically samples the health of the agent
+type Monitor struct {
+	client dockerapi.DockerClient
+	lock   sync.RWMutex
+	health Health
+}
+
+// NewMonitor returns a Monitor pinging the docker daemon with the client
+func NewMonitor(client dockerapi.DockerClient) *Monitor {
+	return &Monitor{client: client}
+}
+
+// Start samples the health of the agent until the context is canceled
+func (monitor *Monitor) Start(ctx context.Context) {
+	monitor.sample(ctx)
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			monitor.sample(ctx)
+		}
+	}
+}
+
+func (monitor *Monitor) sample(ctx context.Context) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	pingStart := time.Now()
+	pingErr := monitor.client.Ping(ctx, pingTimeout)
+	pingLatency := time.Since(pingStart)
+
+	monitor.lock.Lock()
+	defer monitor.lock.Unlock()
+
+	health := &monitor.health
+	health.Timestamp = time.Now()
+	health.Goroutines = runtime.NumGoroutine()
+	health.HeapInUseBytes = memStats.HeapInuse
+	health.GCPauseTotalMs = float64(memStats.PauseTotalNs) / float64(time.Millisecond)
+	health.NumGC = memStats.NumGC
+	if pingErr != nil {
+		health.DockerPingFailures++
+		health.DockerPingError = pingErr.Error()
+		seelog.Warnf("Agent health: unable to ping docker, %d consecutive failures: %v",
+			health.DockerPingFailures, pingErr)
+	} else {
+		health.DockerPingFailures = 0
+		health.DockerPingError = ""
+		health.DockerPingLatencyMs = float64(pingLatency) / float64(time.Millisecond)
+	}
+
+	degraded := health.DockerPingFailures >= degradedPingFailures
+	if degraded && !health.Degraded {
+		seelog.Errorf("Agent health: docker is unreachable, the agent is degraded")
+	} else if !degraded && health.Degraded {
+		seelog.Infof("Agent health: docker is reachable again")
+	}
+	health.Degraded = degraded
+}
+
+// Health returns the last sample of the health of the agent
+func (monitor *Monitor) Health() Health {
+	monitor.lock.RLock()
+	defer monitor.lock.RUnlock()
+
+	return monitor.health
+}
+
+// DockerReachable returns false once pings of the docker daemon failed
+// consecutively, until a ping succeeds
+func (monitor *Monitor) DockerReachable() bool {
+	return !monitor.Health().Degraded
+}
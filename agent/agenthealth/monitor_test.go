@@ -0,0 +1,75 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package agenthealth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorSample(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	monitor := NewMonitor(client)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	assert.True(t, monitor.DockerReachable())
+
+	client.EXPECT().Ping(gomock.Any(), pingTimeout).Return(nil)
+	monitor.sample(ctx)
+	health := monitor.Health()
+	assert.False(t, health.Timestamp.IsZero())
+	assert.NotZero(t, health.Goroutines)
+	assert.NotZero(t, health.HeapInUseBytes)
+	assert.Zero(t, health.DockerPingFailures)
+	assert.False(t, health.Degraded)
+}
+
+func TestMonitorDegradedAfterConsecutivePingFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	monitor := NewMonitor(client)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	client.EXPECT().Ping(gomock.Any(), gomock.Any()).Return(errors.New("unreachable")).Times(degradedPingFailures)
+	for i := 1; i < degradedPingFailures; i++ {
+		monitor.sample(ctx)
+		assert.True(t, monitor.DockerReachable(), "the agent shouldn't be degraded after %d failures", i)
+	}
+	monitor.sample(ctx)
+	health := monitor.Health()
+	assert.True(t, health.Degraded)
+	assert.Equal(t, degradedPingFailures, health.DockerPingFailures)
+	assert.Equal(t, "unreachable", health.DockerPingError)
+	assert.False(t, monitor.DockerReachable())
+
+	// A successful ping ends the degradation
+	client.EXPECT().Ping(gomock.Any(), gomock.Any()).Return(nil)
+	monitor.sample(ctx)
+	health = monitor.Health()
+	assert.False(t, health.Degraded)
+	assert.Zero(t, health.DockerPingFailures)
+	assert.Empty(t, health.DockerPingError)
+}
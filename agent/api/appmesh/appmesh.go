@@ -0,0 +1,154 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package appmesh
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProxyConfigurationTypeAppMesh is the only type of proxy configuration
+	// supported, where the traffic of the task is redirected to an App Mesh
+	// proxy container
+	ProxyConfigurationTypeAppMesh = "APPMESH"
+
+	ignoredUIDKey         = "IgnoredUID"
+	ignoredGIDKey         = "IgnoredGID"
+	proxyIngressPortKey   = "ProxyIngressPort"
+	proxyEgressPortKey    = "ProxyEgressPort"
+	appPortsKey           = "AppPorts"
+	egressIgnoredIPsKey   = "EgressIgnoredIPs"
+	egressIgnoredPortsKey = "EgressIgnoredPorts"
+	separator             = ","
+)
+
+// AppMesh contains the information to redirect the traffic of a task to the
+// proxy container of its proxy configuration
+type AppMesh struct {
+	// ContainerName is the name of the proxy container
+	ContainerName string
+	// IgnoredUID is the uid of the proxy, whose egress traffic isn't
+	// redirected
+	IgnoredUID string `json:",omitempty"`
+	// IgnoredGID is the gid of the proxy, whose egress traffic isn't
+	// redirected
+	IgnoredGID string `json:",omitempty"`
+	// ProxyIngressPort is the port the ingress traffic of the application
+	// ports is redirected to
+	ProxyIngressPort string `json:",omitempty"`
+	// ProxyEgressPort is the port the egress traffic is redirected to
+	ProxyEgressPort string
+	// AppPorts are the ports of the application whose ingress traffic is
+	// redirected
+	AppPorts []string `json:",omitempty"`
+	// EgressIgnoredIPs are the ips and cidr blocks whose egress traffic isn't
+	// redirected
+	EgressIgnoredIPs []string `json:",omitempty"`
+	// EgressIgnoredPorts are the ports whose egress traffic isn't redirected
+	EgressIgnoredPorts []string `json:",omitempty"`
+}
+
+// AppMeshFromACS validates the proxy configuration from the acs message and
+// creates the AppMesh object
+func AppMeshFromACS(proxyConfig *ecsacs.ProxyConfiguration) (*AppMesh, error) {
+	if proxyConfig == nil {
+		return nil, errors.New("app mesh: missing proxy configuration")
+	}
+	if configType := aws.StringValue(proxyConfig.Type); configType != ProxyConfigurationTypeAppMesh {
+		return nil, errors.Errorf("app mesh: unsupported proxy configuration type %q", configType)
+	}
+	if aws.StringValue(proxyConfig.ContainerName) == "" {
+		return nil, errors.New("app mesh: missing proxy container name")
+	}
+
+	properties := make(map[string]string)
+	for key, value := range proxyConfig.Properties {
+		properties[key] = strings.TrimSpace(aws.StringValue(value))
+	}
+	appMesh := &AppMesh{
+		ContainerName:      aws.StringValue(proxyConfig.ContainerName),
+		IgnoredUID:         properties[ignoredUIDKey],
+		IgnoredGID:         properties[ignoredGIDKey],
+		ProxyIngressPort:   properties[proxyIngressPortKey],
+		ProxyEgressPort:    properties[proxyEgressPortKey],
+		AppPorts:           splitProperty(properties[appPortsKey]),
+		EgressIgnoredIPs:   splitProperty(properties[egressIgnoredIPsKey]),
+		EgressIgnoredPorts: splitProperty(properties[egressIgnoredPortsKey]),
+	}
+	if err := appMesh.validate(); err != nil {
+		return nil, err
+	}
+	return appMesh, nil
+}
+
+// validate validates the properties of the proxy configuration, so that tasks
+// with an invalid configuration fail before their network is set up
+func (appMesh *AppMesh) validate() error {
+	// The egress traffic of the proxy itself must not be redirected, which
+	// would loop back to the proxy
+	if appMesh.IgnoredUID == "" && appMesh.IgnoredGID == "" {
+		return errors.Errorf("app mesh: one of %s or %s is required", ignoredUIDKey, ignoredGIDKey)
+	}
+	for key, id := range map[string]string{ignoredUIDKey: appMesh.IgnoredUID, ignoredGIDKey: appMesh.IgnoredGID} {
+		if id == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(id, 10, 32); err != nil {
+			return errors.Errorf("app mesh: invalid %s %q", key, id)
+		}
+	}
+	if appMesh.ProxyEgressPort == "" {
+		return errors.Errorf("app mesh: %s is required", proxyEgressPortKey)
+	}
+	if len(appMesh.AppPorts) > 0 && appMesh.ProxyIngressPort == "" {
+		return errors.Errorf("app mesh: %s is required with %s", proxyIngressPortKey, appPortsKey)
+	}
+
+	ports := append([]string{appMesh.ProxyEgressPort}, appMesh.AppPorts...)
+	ports = append(ports, appMesh.EgressIgnoredPorts...)
+	if appMesh.ProxyIngressPort != "" {
+		ports = append(ports, appMesh.ProxyIngressPort)
+	}
+	for _, port := range ports {
+		if portNumber, err := strconv.ParseUint(port, 10, 16); err != nil || portNumber == 0 {
+			return errors.Errorf("app mesh: invalid port %q", port)
+		}
+	}
+	for _, ip := range appMesh.EgressIgnoredIPs {
+		if net.ParseIP(ip) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(ip); err != nil {
+			return errors.Errorf("app mesh: invalid egress ignored ip %q", ip)
+		}
+	}
+	return nil
+}
+
+// splitProperty splits the comma separated values of a property
+func splitProperty(property string) []string {
+	var values []string
+	for _, value := range strings.Split(property, separator) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
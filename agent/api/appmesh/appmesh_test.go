@@ -0,0 +1,103 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package appmesh
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testProxyConfiguration() *ecsacs.ProxyConfiguration {
+	return &ecsacs.ProxyConfiguration{
+		Type:          aws.String(ProxyConfigurationTypeAppMesh),
+		ContainerName: aws.String("envoy"),
+		Properties: map[string]*string{
+			"IgnoredUID":         aws.String("1337"),
+			"ProxyIngressPort":   aws.String("15000"),
+			"ProxyEgressPort":    aws.String("15001"),
+			"AppPorts":           aws.String("8080, 8081"),
+			"EgressIgnoredIPs":   aws.String("10.0.0.0/16,fd00::1"),
+			"EgressIgnoredPorts": aws.String("22"),
+		},
+	}
+}
+
+func TestAppMeshFromACS(t *testing.T) {
+	appMesh, err := AppMeshFromACS(testProxyConfiguration())
+	require.NoError(t, err)
+	assert.Equal(t, &AppMesh{
+		ContainerName:      "envoy",
+		IgnoredUID:         "1337",
+		ProxyIngressPort:   "15000",
+		ProxyEgressPort:    "15001",
+		AppPorts:           []string{"8080", "8081"},
+		EgressIgnoredIPs:   []string{"10.0.0.0/16", "fd00::1"},
+		EgressIgnoredPorts: []string{"22"},
+	}, appMesh)
+}
+
+func TestAppMeshFromACSInvalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		update func(*ecsacs.ProxyConfiguration)
+	}{
+		{
+			name:   "unsupported type",
+			update: func(config *ecsacs.ProxyConfiguration) { config.Type = aws.String("ISTIO") },
+		},
+		{
+			name:   "no container name",
+			update: func(config *ecsacs.ProxyConfiguration) { config.ContainerName = nil },
+		},
+		{
+			name:   "no ignored uid or gid",
+			update: func(config *ecsacs.ProxyConfiguration) { delete(config.Properties, "IgnoredUID") },
+		},
+		{
+			name:   "invalid ignored uid",
+			update: func(config *ecsacs.ProxyConfiguration) { config.Properties["IgnoredUID"] = aws.String("envoy") },
+		},
+		{
+			name:   "no egress port",
+			update: func(config *ecsacs.ProxyConfiguration) { delete(config.Properties, "ProxyEgressPort") },
+		},
+		{
+			name:   "app ports without ingress port",
+			update: func(config *ecsacs.ProxyConfiguration) { delete(config.Properties, "ProxyIngressPort") },
+		},
+		{
+			name:   "invalid app port",
+			update: func(config *ecsacs.ProxyConfiguration) { config.Properties["AppPorts"] = aws.String("8080,70000") },
+		},
+		{
+			name:   "invalid egress ignored ip",
+			update: func(config *ecsacs.ProxyConfiguration) { config.Properties["EgressIgnoredIPs"] = aws.String("10.0.0") },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := testProxyConfiguration()
+			tc.update(config)
+			_, err := AppMeshFromACS(config)
+			assert.Error(t, err)
+		})
+	}
+}
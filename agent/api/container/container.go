@@ -57,6 +57,32 @@ const (
 
 	// SecretProviderSSM is to show secret provider being SSM
 	SecretProviderSSM = "ssm"
+
+	// DependsOnConditionStart means the dependency container must have started
+	DependsOnConditionStart = "START"
+	// DependsOnConditionComplete means the dependency container must have
+	// exited, regardless of its exit code
+	DependsOnConditionComplete = "COMPLETE"
+	// DependsOnConditionSuccess means the dependency container must have
+	// exited with an exit code of 0
+	DependsOnConditionSuccess = "SUCCESS"
+	// DependsOnConditionHealthy means the dependency container must be
+	// reported as healthy by its health check
+	DependsOnConditionHealthy = "HEALTHY"
+
+	// MountPropagationPrivate means mounts made under the mount point on the
+	// host or in the container aren't propagated to the other side
+	MountPropagationPrivate = "private"
+	// MountPropagationRSlave means mounts made under the mount point on the
+	// host are propagated to the container, but not the other way around
+	MountPropagationRSlave = "rslave"
+	// MountPropagationRShared means mounts made under the mount point are
+	// propagated both from the host to the container and back
+	MountPropagationRShared = "rshared"
+
+	// maxExitHistoryLength is the number of most recent exits recorded for a
+	// container
+	maxExitHistoryLength = 10
 )
 
 // DockerConfig represents additional metadata about a container to run. It's
@@ -81,6 +107,10 @@ type HealthStatus struct {
 	ExitCode int `json:"exitCode,omitempty"`
 	// Output is the output of health check
 	Output string `json:"output,omitempty"`
+	// FailingStreak is the number of consecutive failures of the health check
+	FailingStreak int `json:"failingStreak,omitempty"`
+	// LastCheckedAt is the timestamp when the health check last ran
+	LastCheckedAt *time.Time `json:"lastCheckedAt,omitempty"`
 }
 
 // Container is the internal representation of a container in the ECS agent
@@ -90,10 +120,14 @@ type Container struct {
 	// V3EndpointID is a container identifier used to construct v3 metadata endpoint; it's unique among
 	// all the containers managed by the agent
 	V3EndpointID string
+	// RuntimeID is the docker id of the container
+	RuntimeID string
 	// Image is the image name specified in the task definition
 	Image string
 	// ImageID is the local ID of the image used in the container
 	ImageID string
+	// ImageDigest is the digest of the image used in the container
+	ImageDigest string
 	// Command is the command to run in the container which is specified in the task definition
 	Command []string
 	// CPU is the cpu limitation of the container which is specified in the task definition
@@ -104,6 +138,9 @@ type Container struct {
 	Links []string
 	// VolumesFrom contains a list of container's volume to use, corresponding to docker option: --volumes-from
 	VolumesFrom []VolumeFrom `json:"volumesFrom"`
+	// DependsOn contains a list of containers that must reach a condition
+	// before this container can be started
+	DependsOn []DependsOn `json:"dependsOn"`
 	// MountPoints contains a list of volume mount paths
 	MountPoints []MountPoint `json:"mountPoints"`
 	// Ports contains a list of ports binding configuration
@@ -125,11 +162,65 @@ type Container struct {
 	// HealthCheckType is the mechnism to use for the container health check
 	// currently it only supports 'DOCKER'
 	HealthCheckType string `json:"healthCheckType,omitempty"`
+	// HealthCheck is the health check defined in the task definition. Docker
+	// runs it instead of the health check of the image
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
 	// Health contains the health check information of container health check
 	Health HealthStatus `json:"-"`
+	// sentHealthStatus is the last health status that was sent to ECS. It's
+	// not saved in the state file, as the health status itself isn't either
+	sentHealthStatus apicontainerstatus.ContainerHealthStatus
 	// LogsAuthStrategy specifies how the logs driver for the container will be
 	// authenticated
 	LogsAuthStrategy string
+	// StartTimeout is the number of seconds the containers depending on this
+	// container wait for it to reach the condition they depend on
+	StartTimeout uint `json:"startTimeout"`
+	// StopTimeout is the number of seconds docker waits for the container to
+	// exit before killing it when it's stopped
+	StopTimeout uint `json:"stopTimeout"`
+	// StopSignal is the signal docker sends the container to stop it, before
+	// killing it once the stop timeout elapses. Docker's default signal,
+	// SIGTERM, is used when it's not set
+	StopSignal string `json:"stopSignal,omitempty"`
+	// RestartPolicy specifies how the agent restarts the container when it
+	// exits while the task is running. Only non-essential containers are
+	// restarted
+	RestartPolicy *RestartPolicy `json:"restartPolicy,omitempty"`
+	// GPUCount is the number of GPUs reserved for the container. The task
+	// engine assigns the GPUs when creating the container
+	GPUCount int `json:"gpuCount,omitempty"`
+	// Devices are the host devices exposed to the container
+	Devices []Device `json:"devices,omitempty"`
+	// CapAdd are the linux capabilities added to the container's default
+	// capabilities
+	CapAdd []string `json:"capAdd,omitempty"`
+	// CapDrop are the linux capabilities dropped from the container's default
+	// capabilities
+	CapDrop []string `json:"capDrop,omitempty"`
+	// ReadonlyRootFilesystem mounts the container's root filesystem as read
+	// only, leaving only its volumes writable
+	ReadonlyRootFilesystem bool `json:"readonlyRootFilesystem,omitempty"`
+	// InitProcessEnabled runs an init process as pid 1 of the container, which
+	// forwards signals to the container's process and reaps zombie processes
+	InitProcessEnabled bool `json:"initProcessEnabled,omitempty"`
+	// SystemControls are the kernel parameters set in the container's namespaces,
+	// keyed by the name of the parameter, such as 'net.core.somaxconn'
+	SystemControls map[string]string `json:"systemControls,omitempty"`
+	// Ulimits are the resource limits of the container, overriding the docker
+	// daemon's default ulimits
+	Ulimits []Ulimit `json:"ulimits,omitempty"`
+	// Tmpfs are the tmpfs mounts of the container
+	Tmpfs []TmpfsMount `json:"tmpfs,omitempty"`
+	// ShmSize is the size of /dev/shm in MiB. Docker's default size is used
+	// when it's not set
+	ShmSize int64 `json:"shmSize,omitempty"`
+	// MemorySwappiness tunes the container's memory swappiness, between 0
+	// and 100. Docker's default swappiness is used when it's not set
+	MemorySwappiness *int64 `json:"memorySwappiness,omitempty"`
+	// ImagePullBehavior specifies how the agent pulls the container's image,
+	// overriding the agent's image pull behavior when set
+	ImagePullBehavior string `json:"imagePullBehavior,omitempty"`
 	// lock is used for fields that are accessed and updated concurrently
 	lock sync.RWMutex
 
@@ -195,6 +286,10 @@ type Container struct {
 	// metadata file
 	MetadataFileUpdated bool `json:"metadataFileUpdated"`
 
+	// MetadataFilePath is the path on the host of the metadata file of the
+	// container, set once the file is created
+	MetadataFilePath string `json:"metadataFilePath,omitempty"`
+
 	// KnownExitCodeUnsafe specifies the exit code for the container.
 	// It is exposed outside of the package so that it's marshalled/unmarshalled in
 	// the JSON body while saving the state.
@@ -202,6 +297,45 @@ type Container struct {
 	// and `SetKnownExitCode`.
 	KnownExitCodeUnsafe *int `json:"KnownExitCode"`
 
+	// RestartCountUnsafe is the number of times the agent restarted the
+	// container according to its restart policy.
+	// NOTE: Do not access RestartCountUnsafe directly. Instead, use
+	// `GetRestartCount` and `IncrementRestartCount`.
+	RestartCountUnsafe int `json:"RestartCount"`
+
+	// ExitHistoryUnsafe records the most recent exits of the container, oldest
+	// first, including exits after which the container was restarted.
+	// NOTE: Do not access ExitHistoryUnsafe directly. Instead, use
+	// `GetExitHistory`, `GetLatestExit` and `RecordExit`.
+	ExitHistoryUnsafe []ContainerExit `json:"ExitHistory,omitempty"`
+
+	// ImageFromCacheUnsafe is set to true when the container uses the locally
+	// cached image instead of pulling it.
+	// NOTE: Do not access ImageFromCacheUnsafe directly. Instead, use
+	// `GetImageFromCache` and `SetImageFromCache`.
+	ImageFromCacheUnsafe bool `json:"ImageFromCache"`
+
+	// PullStartedAtUnsafe and PullStoppedAtUnsafe are the timestamps when the
+	// agent started and finished pulling the image of the container. Both are
+	// set to the same time when the cached image is used.
+	// NOTE: Do not access PullStartedAtUnsafe and PullStoppedAtUnsafe directly.
+	// Instead, use `SetPullStartedAt`, `SetPullStoppedAt`, `GetPullStartedAt`,
+	// `GetPullStoppedAt` and `GetPullDuration`.
+	PullStartedAtUnsafe time.Time `json:"PullStartedAt"`
+	PullStoppedAtUnsafe time.Time `json:"PullStoppedAt"`
+
+	// GPUIDsUnsafe are the IDs of the GPUs assigned to the container.
+	// NOTE: Do not access GPUIDsUnsafe directly. Instead, use `GetGPUIDs`
+	// and `SetGPUIDs`.
+	GPUIDsUnsafe []string `json:"GPUIDs,omitempty"`
+
+	// AllocatedHostPortsUnsafe are the host ports allocated by the agent to
+	// the port mappings of the container without a host port, in the order of
+	// those mappings.
+	// NOTE: Do not access AllocatedHostPortsUnsafe directly. Instead, use
+	// `GetAllocatedHostPorts` and `SetAllocatedHostPorts`.
+	AllocatedHostPortsUnsafe []uint16 `json:"AllocatedHostPorts,omitempty"`
+
 	// KnownPortBindingsUnsafe is an array of port bindings for the container.
 	KnownPortBindingsUnsafe []PortBinding `json:"KnownPortBindings"`
 
@@ -239,6 +373,9 @@ type MountPoint struct {
 	SourceVolume  string `json:"sourceVolume"`
 	ContainerPath string `json:"containerPath"`
 	ReadOnly      bool   `json:"readOnly"`
+	// Propagation is the propagation mode of the mount, one of the
+	// MountPropagation values. Docker's default mode is used when it's not set
+	Propagation string `json:"propagation,omitempty"`
 }
 
 // VolumeFrom is a volume which references another container as its source.
@@ -247,6 +384,90 @@ type VolumeFrom struct {
 	ReadOnly        bool   `json:"readOnly"`
 }
 
+// DependsOn is a dependency on another container of the task reaching the
+// condition specified before the container can be started.
+type DependsOn struct {
+	ContainerName string `json:"containerName"`
+	Condition     string `json:"condition"`
+}
+
+// HealthCheck describes the command docker runs in the container to check its
+// health. Durations are in seconds, docker's defaults are used for the
+// parameters that aren't set
+type HealthCheck struct {
+	// Command is the command run to check the health of the container, as
+	// ["CMD", args...] or ["CMD-SHELL", command]
+	Command []string `json:"command"`
+	// Interval is the time between two runs of the command
+	Interval uint `json:"interval,omitempty"`
+	// Timeout is the time the command has to succeed before it's considered
+	// failed
+	Timeout uint `json:"timeout,omitempty"`
+	// Retries is the number of consecutive failures after which the container
+	// is unhealthy
+	Retries int `json:"retries,omitempty"`
+	// StartPeriod is the time the container has to start up, during which
+	// failures don't count towards the retries
+	StartPeriod uint `json:"startPeriod,omitempty"`
+}
+
+// RestartPolicy describes when the agent restarts a container that exited
+type RestartPolicy struct {
+	// Attempts is the maximum number of times the container is restarted
+	Attempts int `json:"attempts"`
+	// IgnoredExitCodes are the exit codes the container isn't restarted for
+	IgnoredExitCodes []int `json:"ignoredExitCodes"`
+	// RestartAttemptPeriod is the number of seconds the container has to run
+	// for before it can be restarted
+	RestartAttemptPeriod uint `json:"restartAttemptPeriod"`
+}
+
+// ContainerExit describes an exit of a container
+type ContainerExit struct {
+	// Time is the time at which the container exited
+	Time time.Time `json:"time"`
+	// ExitCode is the exit code of the container, if available
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Reason describes why the container exited, if known
+	Reason string `json:"reason,omitempty"`
+	// OutOfMemory is set when the container was killed for running out of
+	// memory
+	OutOfMemory bool `json:"outOfMemory,omitempty"`
+}
+
+// Device is a host device exposed to a container
+type Device struct {
+	// HostPath is the path of the device on the host
+	HostPath string `json:"hostPath"`
+	// ContainerPath is the path the device is exposed at in the container. The
+	// host path is used when it's not set
+	ContainerPath string `json:"containerPath,omitempty"`
+	// Permissions are the cgroup permissions of the container for the device,
+	// made of 'r' (read), 'w' (write) and 'm' (mknod). All of them are granted
+	// when it's not set
+	Permissions string `json:"permissions,omitempty"`
+}
+
+// Ulimit is a resource limit of a container
+type Ulimit struct {
+	// Name is the name of the resource, such as 'nofile' or 'nproc'
+	Name string `json:"name"`
+	// Soft is the soft limit of the resource
+	Soft int64 `json:"soft"`
+	// Hard is the hard limit of the resource
+	Hard int64 `json:"hard"`
+}
+
+// TmpfsMount is a tmpfs mount of a container
+type TmpfsMount struct {
+	// ContainerPath is the path the tmpfs is mounted at in the container
+	ContainerPath string `json:"containerPath"`
+	// Size is the size of the tmpfs in MiB
+	Size int64 `json:"size"`
+	// MountOptions are the options to mount the tmpfs with
+	MountOptions []string `json:"mountOptions,omitempty"`
+}
+
 // Secret contains all essential attributes needed for ECS secrets vending as environment variables/tmpfs files
 type Secret struct {
 	Name          string `json:"name"`
@@ -357,6 +578,179 @@ func (c *Container) GetKnownExitCode() *int {
 	return c.KnownExitCodeUnsafe
 }
 
+// GetRestartCount returns the number of times the container was restarted
+// according to its restart policy
+func (c *Container) GetRestartCount() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.RestartCountUnsafe
+}
+
+// IncrementRestartCount records a restart of the container and returns the
+// number of times it was restarted
+func (c *Container) IncrementRestartCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.RestartCountUnsafe++
+	return c.RestartCountUnsafe
+}
+
+// RecordExit appends an exit to the exit history of the container, keeping
+// only the most recent exits, and makes its exit code the known exit code of
+// the container
+func (c *Container) RecordExit(exit ContainerExit) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.ExitHistoryUnsafe = append(c.ExitHistoryUnsafe, exit)
+	if len(c.ExitHistoryUnsafe) > maxExitHistoryLength {
+		c.ExitHistoryUnsafe = c.ExitHistoryUnsafe[len(c.ExitHistoryUnsafe)-maxExitHistoryLength:]
+	}
+	c.KnownExitCodeUnsafe = exit.ExitCode
+}
+
+// GetExitHistory returns a copy of the most recent exits of the container,
+// oldest first
+func (c *Container) GetExitHistory() []ContainerExit {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if len(c.ExitHistoryUnsafe) == 0 {
+		return nil
+	}
+	history := make([]ContainerExit, len(c.ExitHistoryUnsafe))
+	copy(history, c.ExitHistoryUnsafe)
+	return history
+}
+
+// GetLatestExit returns the most recent exit of the container, and false if
+// no exit was recorded
+func (c *Container) GetLatestExit() (ContainerExit, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if len(c.ExitHistoryUnsafe) == 0 {
+		return ContainerExit{}, false
+	}
+	return c.ExitHistoryUnsafe[len(c.ExitHistoryUnsafe)-1], true
+}
+
+// SetImageFromCache sets whether the container uses the locally cached image
+// instead of pulling it
+func (c *Container) SetImageFromCache(imageFromCache bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.ImageFromCacheUnsafe = imageFromCache
+}
+
+// GetImageFromCache returns true if the container uses the locally cached
+// image instead of pulling it
+func (c *Container) GetImageFromCache() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.ImageFromCacheUnsafe
+}
+
+// SetPullStartedAt sets the timestamp when the agent started pulling the image
+// of the container
+func (c *Container) SetPullStartedAt(pullStartedAt time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.PullStartedAtUnsafe = pullStartedAt
+}
+
+// SetPullStoppedAt sets the timestamp when the agent finished pulling the
+// image of the container
+func (c *Container) SetPullStoppedAt(pullStoppedAt time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.PullStoppedAtUnsafe = pullStoppedAt
+}
+
+// GetPullStartedAt returns the timestamp when the agent started pulling the
+// image of the container
+func (c *Container) GetPullStartedAt() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.PullStartedAtUnsafe
+}
+
+// GetPullStoppedAt returns the timestamp when the agent finished pulling the
+// image of the container
+func (c *Container) GetPullStoppedAt() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.PullStoppedAtUnsafe
+}
+
+// GetPullDuration returns how long pulling the image of the container took,
+// which is zero when the cached image is used. It returns false if the pull
+// hasn't finished
+func (c *Container) GetPullDuration() (time.Duration, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.PullStartedAtUnsafe.IsZero() || c.PullStoppedAtUnsafe.IsZero() ||
+		c.PullStoppedAtUnsafe.Before(c.PullStartedAtUnsafe) {
+		return 0, false
+	}
+	return c.PullStoppedAtUnsafe.Sub(c.PullStartedAtUnsafe), true
+}
+
+// SetGPUIDs sets the IDs of the GPUs assigned to the container
+func (c *Container) SetGPUIDs(gpuIDs []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.GPUIDsUnsafe = gpuIDs
+}
+
+// GetGPUIDs returns the IDs of the GPUs assigned to the container
+func (c *Container) GetGPUIDs() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.GPUIDsUnsafe
+}
+
+// DynamicHostPortCount returns the number of port mappings of the container
+// without a host port
+func (c *Container) DynamicHostPortCount() int {
+	count := 0
+	for _, port := range c.Ports {
+		if port.HostPort == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// SetAllocatedHostPorts sets the host ports allocated to the port mappings of
+// the container without a host port
+func (c *Container) SetAllocatedHostPorts(ports []uint16) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.AllocatedHostPortsUnsafe = ports
+}
+
+// GetAllocatedHostPorts returns the host ports allocated to the port mappings
+// of the container without a host port
+func (c *Container) GetAllocatedHostPorts() []uint16 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.AllocatedHostPortsUnsafe
+}
+
 // SetRegistryAuthCredentials sets the credentials for pulling image from ECR
 func (c *Container) SetRegistryAuthCredentials(credential credentials.IAMRoleCredentials) {
 	c.lock.Lock()
@@ -465,6 +859,24 @@ func (c *Container) SetMetadataFileUpdated() {
 	c.MetadataFileUpdated = true
 }
 
+// GetMetadataFilePath returns the path on the host of the metadata file of the
+// container
+func (c *Container) GetMetadataFilePath() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.MetadataFilePath
+}
+
+// SetMetadataFilePath sets the path on the host of the metadata file of the
+// container
+func (c *Container) SetMetadataFilePath(path string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.MetadataFilePath = path
+}
+
 // IsEssential returns whether the container is an essential container or not
 func (c *Container) IsEssential() bool {
 	c.lock.RLock()
@@ -588,7 +1000,7 @@ func (c *Container) GetVolumes() []docker.Mount {
 // HealthStatusShouldBeReported returns true if the health check is defined in
 // the task definition
 func (c *Container) HealthStatusShouldBeReported() bool {
-	return c.HealthCheckType == DockerHealthCheckType
+	return c.HealthCheckType == DockerHealthCheckType || c.HealthCheck != nil
 }
 
 // SetHealthStatus sets the container health status
@@ -596,6 +1008,12 @@ func (c *Container) SetHealthStatus(health HealthStatus) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	// The results of the latest run of the health check are recorded even if
+	// the status doesn't change
+	c.Health.FailingStreak = health.FailingStreak
+	if health.LastCheckedAt != nil {
+		c.Health.LastCheckedAt = aws.Time(aws.TimeValue(health.LastCheckedAt))
+	}
 	if c.Health.Status == health.Status {
 		return
 	}
@@ -621,10 +1039,42 @@ func (c *Container) GetHealthStatus() HealthStatus {
 	if c.Health.Since != nil {
 		copyHealth.Since = aws.Time(aws.TimeValue(c.Health.Since))
 	}
+	if c.Health.LastCheckedAt != nil {
+		copyHealth.LastCheckedAt = aws.Time(aws.TimeValue(c.Health.LastCheckedAt))
+	}
 
 	return copyHealth
 }
 
+// GetSentHealthStatus returns the last health status that was sent to ECS
+func (c *Container) GetSentHealthStatus() apicontainerstatus.ContainerHealthStatus {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.sentHealthStatus
+}
+
+// SetSentHealthStatus sets the last health status that was sent to ECS
+func (c *Container) SetSentHealthStatus(healthStatus apicontainerstatus.ContainerHealthStatus) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sentHealthStatus = healthStatus
+}
+
+// HealthStatusShouldBeSent returns true if the health status of the container
+// has transitioned since it was last sent to ECS
+func (c *Container) HealthStatusShouldBeSent() bool {
+	if !c.HealthStatusShouldBeReported() {
+		return false
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.Health.Status != apicontainerstatus.ContainerHealthUnknown &&
+		c.Health.Status != c.sentHealthStatus
+}
+
 // BuildContainerDependency adds a new dependency container and satisfied status
 // to the dependent container
 func (c *Container) BuildContainerDependency(contName string,
@@ -732,6 +1182,57 @@ func (c *Container) GetV3EndpointID() string {
 	return c.V3EndpointID
 }
 
+// SetRuntimeID sets the runtime id of container
+func (c *Container) SetRuntimeID(runtimeID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.RuntimeID = runtimeID
+}
+
+// GetRuntimeID returns the runtime id of container
+func (c *Container) GetRuntimeID() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.RuntimeID
+}
+
+// SetImageDigest sets the digest of the image used in the container
+func (c *Container) SetImageDigest(imageDigest string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.ImageDigest = imageDigest
+}
+
+// GetImageDigest returns the digest of the image used in the container
+func (c *Container) GetImageDigest() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.ImageDigest
+}
+
+// GetStartTimeout returns the time the containers depending on this container
+// wait for it to reach the condition they depend on. Zero means the timeout
+// isn't set for the container
+func (c *Container) GetStartTimeout() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return time.Duration(c.StartTimeout) * time.Second
+}
+
+// GetStopTimeout returns the time docker waits for the container to exit
+// before killing it. Zero means the timeout isn't set for the container
+func (c *Container) GetStopTimeout() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return time.Duration(c.StopTimeout) * time.Second
+}
+
 // InjectV3MetadataEndpoint injects the v3 metadata endpoint as an environment variable for a container
 func (c *Container) InjectV3MetadataEndpoint() {
 	c.lock.Lock()
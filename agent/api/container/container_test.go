@@ -16,6 +16,7 @@
 package container
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"testing"
@@ -25,8 +26,10 @@ import (
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
 
 	"github.com/aws/amazon-ecs-agent/agent/utils"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type configPair struct {
@@ -204,6 +207,24 @@ func TestSetHealtStatus(t *testing.T) {
 	assert.NotEqual(t, health3.Since, health2.Since)
 }
 
+func TestSetHealthStatusRecordsLatestCheck(t *testing.T) {
+	container := Container{}
+	container.SetHealthStatus(HealthStatus{Status: apicontainerstatus.ContainerHealthy})
+	since := container.GetHealthStatus().Since
+
+	// The failures of the health check are recorded before the status changes
+	lastCheckedAt := time.Now()
+	container.SetHealthStatus(HealthStatus{
+		Status:        apicontainerstatus.ContainerHealthy,
+		FailingStreak: 2,
+		LastCheckedAt: aws.Time(lastCheckedAt),
+	})
+	health := container.GetHealthStatus()
+	assert.Equal(t, 2, health.FailingStreak)
+	assert.Equal(t, lastCheckedAt, aws.TimeValue(health.LastCheckedAt))
+	assert.Equal(t, since, health.Since, "the status didn't change")
+}
+
 func TestHealthStatusShouldBeReported(t *testing.T) {
 	container := Container{}
 	assert.False(t, container.HealthStatusShouldBeReported(), "Health status of container that does not have HealthCheckType set should not be reported")
@@ -213,6 +234,21 @@ func TestHealthStatusShouldBeReported(t *testing.T) {
 	assert.False(t, container.HealthStatusShouldBeReported(), "Health status of container that has non-docker HealthCheckType set should not be reported")
 }
 
+func TestHealthStatusShouldBeSent(t *testing.T) {
+	container := Container{}
+	container.SetHealthStatus(HealthStatus{Status: apicontainerstatus.ContainerHealthy})
+	assert.False(t, container.HealthStatusShouldBeSent(), "Health status of container that does not have HealthCheckType set should not be sent")
+
+	container.HealthCheckType = DockerHealthCheckType
+	assert.True(t, container.HealthStatusShouldBeSent(), "Health status transition should be sent")
+
+	container.SetSentHealthStatus(apicontainerstatus.ContainerHealthy)
+	assert.False(t, container.HealthStatusShouldBeSent(), "Health status that has already been sent should not be sent again")
+
+	container.SetHealthStatus(HealthStatus{Status: apicontainerstatus.ContainerUnhealthy})
+	assert.True(t, container.HealthStatusShouldBeSent(), "Health status transition should be sent")
+}
+
 func TestBuildContainerDependency(t *testing.T) {
 	container := Container{TransitionDependenciesMap: make(map[apicontainerstatus.ContainerStatus]TransitionDependencySet)}
 	depContName := "dep"
@@ -374,3 +410,95 @@ func TestMergeEnvironmentVariables(t *testing.T) {
 		})
 	}
 }
+
+// TestMemorySettingsSurviveMarshalling tests that the tmpfs mounts, shared
+// memory size and memory swappiness are saved with the container state
+func TestMemorySettingsSurviveMarshalling(t *testing.T) {
+	swappiness := int64(0)
+	container := &Container{
+		Name: "c1",
+		Tmpfs: []TmpfsMount{
+			{
+				ContainerPath: "/scratch",
+				Size:          64,
+				MountOptions:  []string{"noexec"},
+			},
+		},
+		ShmSize:          128,
+		MemorySwappiness: &swappiness,
+	}
+
+	data, err := json.Marshal(container)
+	require.NoError(t, err)
+	unmarshalled := &Container{}
+	require.NoError(t, json.Unmarshal(data, unmarshalled))
+	assert.Equal(t, container.Tmpfs, unmarshalled.Tmpfs)
+	assert.Equal(t, container.ShmSize, unmarshalled.ShmSize)
+	assert.Equal(t, container.MemorySwappiness, unmarshalled.MemorySwappiness)
+}
+
+func TestRecordExitKeepsMostRecentExits(t *testing.T) {
+	container := &Container{}
+	_, ok := container.GetLatestExit()
+	assert.False(t, ok)
+	assert.Nil(t, container.GetExitHistory())
+
+	for i := 0; i < maxExitHistoryLength+3; i++ {
+		exitCode := i
+		container.RecordExit(ContainerExit{
+			Time:     time.Unix(int64(i), 0),
+			ExitCode: &exitCode,
+		})
+	}
+
+	history := container.GetExitHistory()
+	require.Len(t, history, maxExitHistoryLength)
+	assert.Equal(t, 3, *history[0].ExitCode)
+	latest, ok := container.GetLatestExit()
+	require.True(t, ok)
+	assert.Equal(t, maxExitHistoryLength+2, *latest.ExitCode)
+	assert.Equal(t, latest.ExitCode, container.GetKnownExitCode())
+
+	// The history returned is a copy
+	history[0].Reason = "modified"
+	assert.Empty(t, container.GetExitHistory()[0].Reason)
+}
+
+func TestExitHistorySurvivesMarshalling(t *testing.T) {
+	exitCode := 137
+	container := &Container{Name: "c1"}
+	container.RecordExit(ContainerExit{
+		Time:        time.Unix(1500000000, 0).UTC(),
+		ExitCode:    &exitCode,
+		Reason:      "OOM",
+		OutOfMemory: true,
+	})
+
+	data, err := json.Marshal(container)
+	require.NoError(t, err)
+	unmarshalled := &Container{}
+	require.NoError(t, json.Unmarshal(data, unmarshalled))
+	assert.Equal(t, container.GetExitHistory(), unmarshalled.GetExitHistory())
+}
+
+func TestGetPullDuration(t *testing.T) {
+	container := &Container{Name: "c1"}
+	_, ok := container.GetPullDuration()
+	assert.False(t, ok, "Expected no pull duration before the pull started")
+
+	pullStartedAt := time.Unix(1500000000, 0)
+	container.SetPullStartedAt(pullStartedAt)
+	_, ok = container.GetPullDuration()
+	assert.False(t, ok, "Expected no pull duration before the pull stopped")
+
+	container.SetPullStoppedAt(pullStartedAt.Add(3 * time.Second))
+	duration, ok := container.GetPullDuration()
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, duration)
+
+	// Cached images are reported as pulls that took no time
+	container.SetPullStoppedAt(pullStartedAt)
+	duration, ok = container.GetPullDuration()
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), duration)
+}
@@ -23,6 +23,11 @@ const (
 	// ContainerHealthEvent represents the container health status event from docker
 	// "health_status: unhealthy" and "health_status: healthy" will have this type
 	ContainerHealthEvent
+	// EventStreamReconnectedEvent represents the docker event stream being
+	// opened again after it was closed, e.g. because the docker daemon
+	// restarted. It isn't about any container; events may have been missed
+	// while the stream was closed
+	EventStreamReconnectedEvent
 )
 
 func (eventType DockerEventType) String() string {
@@ -31,6 +36,8 @@ func (eventType DockerEventType) String() string {
 		return "ContainerStatusChangeEvent"
 	case ContainerHealthEvent:
 		return "ContainerHealthChangeEvent"
+	case EventStreamReconnectedEvent:
+		return "EventStreamReconnectedEvent"
 	default:
 		return "UNKNOWN"
 	}
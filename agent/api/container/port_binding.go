@@ -14,6 +14,7 @@
 package container
 
 import (
+	"fmt"
 	"strconv"
 
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
@@ -39,6 +40,12 @@ type PortBinding struct {
 	Protocol TransportProtocol
 }
 
+// String returns a human readable string representation of the port binding
+func (binding PortBinding) String() string {
+	return fmt.Sprintf("%s:%d->%d/%s", binding.BindIP, binding.HostPort,
+		binding.ContainerPort, binding.Protocol.String())
+}
+
 // PortBindingFromDockerPortBinding constructs a PortBinding slice from a docker
 // NetworkSettings.Ports map.
 func PortBindingFromDockerPortBinding(dockerPortBindings map[docker.Port][]docker.PortBinding) ([]PortBinding, apierrors.NamedError) {
@@ -21,6 +21,7 @@ import (
 
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
 	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestPortBindingFromDockerPortBinding(t *testing.T) {
@@ -124,3 +125,25 @@ func TestPortBindingErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestPortBindingString(t *testing.T) {
+	cases := []struct {
+		binding  PortBinding
+		expected string
+	}{
+		{
+			PortBinding{BindIP: "0.0.0.0", HostPort: 32768, ContainerPort: 53, Protocol: TransportProtocolUDP},
+			"0.0.0.0:32768->53/udp",
+		},
+		{
+			PortBinding{BindIP: "0.0.0.0", HostPort: 8080, ContainerPort: 80},
+			"0.0.0.0:8080->80/tcp",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expected, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.binding.String())
+		})
+	}
+}
@@ -82,10 +82,9 @@ func (tp *TransportProtocol) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
-// MarshalJSON overrides the logic for JSON-encoding the TransportProtocol type
-func (tp *TransportProtocol) MarshalJSON() ([]byte, error) {
-	if tp == nil {
-		return []byte("null"), nil
-	}
+// MarshalJSON overrides the logic for JSON-encoding the TransportProtocol type.
+// It uses a value receiver so that protocols held in non-addressable values,
+// such as a PortBinding marshalled by value, are not encoded as integers
+func (tp TransportProtocol) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + tp.String() + `"`), nil
 }
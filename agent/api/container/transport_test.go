@@ -139,3 +139,25 @@ func TestMarshalUnmarshalTransportProtocol(t *testing.T) {
 		t.Errorf("Expected tcp for Field1 but was %x, expected udp for Field2 but was %x", unmarshalTo.Field1, unmarshalTo.Field2)
 	}
 }
+
+func TestMarshalUnmarshalTransportProtocolByValue(t *testing.T) {
+	binding := PortBinding{
+		ContainerPort: 53,
+		HostPort:      32768,
+		Protocol:      TransportProtocolUDP,
+	}
+
+	jsonBytes, err := json.Marshal(binding)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var unmarshalTo PortBinding
+	err = json.Unmarshal(jsonBytes, &unmarshalTo)
+	if err != nil {
+		t.Error(err)
+	}
+	if unmarshalTo.Protocol != TransportProtocolUDP {
+		t.Errorf("Expected udp but was %s in %s", unmarshalTo.Protocol.String(), string(jsonBytes))
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
 	"github.com/aws/amazon-ecs-agent/agent/async"
@@ -37,7 +38,6 @@ import (
 )
 
 const (
-	ecsMaxReasonLength    = 255
 	pollEndpointCacheSize = 1
 	pollEndpointCacheTTL  = 20 * time.Minute
 	roundtripTimeout      = 5 * time.Second
@@ -346,7 +346,7 @@ func (client *APIECSClient) SubmitTaskStateChange(change api.TaskStateChange) er
 		Cluster:            aws.String(client.config.Cluster),
 		Task:               aws.String(change.TaskARN),
 		Status:             aws.String(status),
-		Reason:             aws.String(change.Reason),
+		Reason:             aws.String(api.TruncateReason(change.Reason, change.TaskARN)),
 		PullStartedAt:      change.PullStartedAt,
 		PullStoppedAt:      change.PullStoppedAt,
 		ExecutionStoppedAt: change.ExecutionStoppedAt,
@@ -373,13 +373,16 @@ func (client *APIECSClient) buildContainerStateChangePayload(change api.Containe
 		ContainerName: aws.String(change.ContainerName),
 	}
 
+	if change.RuntimeID != "" {
+		statechange.RuntimeId = aws.String(change.RuntimeID)
+	}
+
+	if change.ImageDigest != "" {
+		statechange.ImageDigest = aws.String(change.ImageDigest)
+	}
+
 	if change.Reason != "" {
-		if len(change.Reason) > ecsMaxReasonLength {
-			trimmed := change.Reason[0:ecsMaxReasonLength]
-			statechange.Reason = aws.String(trimmed)
-		} else {
-			statechange.Reason = aws.String(change.Reason)
-		}
+		statechange.Reason = aws.String(api.TruncateReason(change.Reason, change.TaskArn))
 	}
 	status := change.Status
 
@@ -395,23 +398,30 @@ func (client *APIECSClient) buildContainerStateChangePayload(change api.Containe
 		exitCode := int64(aws.IntValue(change.ExitCode))
 		statechange.ExitCode = aws.Int64(exitCode)
 	}
-	networkBindings := make([]*ecs.NetworkBinding, len(change.PortBindings))
-	for i, binding := range change.PortBindings {
-		hostPort := int64(binding.HostPort)
-		containerPort := int64(binding.ContainerPort)
-		bindIP := binding.BindIP
-		protocol := binding.Protocol.String()
+	if change.HealthStatus != apicontainerstatus.ContainerHealthUnknown {
+		statechange.HealthStatus = aws.String(change.HealthStatus.BackendStatus())
+	}
+	statechange.StartedAt = change.StartedAt
+	statechange.FinishedAt = change.FinishedAt
+	statechange.NetworkBindings = buildNetworkBindings(change.PortBindings)
 
+	return statechange
+}
+
+// buildNetworkBindings translates the port bindings of a container into the
+// network bindings reported to ECS, retaining the transport protocol of each
+// binding
+func buildNetworkBindings(portBindings []apicontainer.PortBinding) []*ecs.NetworkBinding {
+	networkBindings := make([]*ecs.NetworkBinding, len(portBindings))
+	for i, binding := range portBindings {
 		networkBindings[i] = &ecs.NetworkBinding{
-			BindIP:        aws.String(bindIP),
-			ContainerPort: aws.Int64(containerPort),
-			HostPort:      aws.Int64(hostPort),
-			Protocol:      aws.String(protocol),
+			BindIP:        aws.String(binding.BindIP),
+			ContainerPort: aws.Int64(int64(binding.ContainerPort)),
+			HostPort:      aws.Int64(int64(binding.HostPort)),
+			Protocol:      aws.String(binding.Protocol.String()),
 		}
 	}
-	statechange.NetworkBindings = networkBindings
-
-	return statechange
+	return networkBindings
 }
 
 func (client *APIECSClient) SubmitContainerStateChange(change api.ContainerStateChange) error {
@@ -420,13 +430,14 @@ func (client *APIECSClient) SubmitContainerStateChange(change api.ContainerState
 		Task:          &change.TaskArn,
 		ContainerName: &change.ContainerName,
 	}
+	if change.RuntimeID != "" {
+		req.RuntimeId = aws.String(change.RuntimeID)
+	}
+	if change.ImageDigest != "" {
+		req.ImageDigest = aws.String(change.ImageDigest)
+	}
 	if change.Reason != "" {
-		if len(change.Reason) > ecsMaxReasonLength {
-			trimmed := change.Reason[0:ecsMaxReasonLength]
-			req.Reason = &trimmed
-		} else {
-			req.Reason = &change.Reason
-		}
+		req.Reason = aws.String(api.TruncateReason(change.Reason, change.TaskArn))
 	}
 	stat := change.Status.String()
 	if stat == "DEAD" {
@@ -441,20 +452,12 @@ func (client *APIECSClient) SubmitContainerStateChange(change api.ContainerState
 		exitCode := int64(*change.ExitCode)
 		req.ExitCode = &exitCode
 	}
-	networkBindings := make([]*ecs.NetworkBinding, len(change.PortBindings))
-	for i, binding := range change.PortBindings {
-		hostPort := int64(binding.HostPort)
-		containerPort := int64(binding.ContainerPort)
-		bindIP := binding.BindIP
-		protocol := binding.Protocol.String()
-		networkBindings[i] = &ecs.NetworkBinding{
-			BindIP:        &bindIP,
-			ContainerPort: &containerPort,
-			HostPort:      &hostPort,
-			Protocol:      &protocol,
-		}
+	if change.HealthStatus != apicontainerstatus.ContainerHealthUnknown {
+		req.HealthStatus = aws.String(change.HealthStatus.BackendStatus())
 	}
-	req.NetworkBindings = networkBindings
+	req.StartedAt = change.StartedAt
+	req.FinishedAt = change.FinishedAt
+	req.NetworkBindings = buildNetworkBindings(change.PortBindings)
 
 	_, err := client.submitStateChangeClient.SubmitContainerStateChange(&req)
 	if err != nil {
@@ -464,6 +467,26 @@ func (client *APIECSClient) SubmitContainerStateChange(change api.ContainerState
 	return nil
 }
 
+func (client *APIECSClient) SubmitAttachmentStateChange(change api.AttachmentStateChange) error {
+	attachmentStatus := change.Attachment.Status.String()
+	req := ecs.SubmitAttachmentStateChangesInput{
+		Cluster: &client.config.Cluster,
+		Attachments: []*ecs.AttachmentStateChange{
+			{
+				AttachmentArn: aws.String(change.Attachment.AttachmentARN),
+				Status:        aws.String(attachmentStatus),
+			},
+		},
+	}
+
+	_, err := client.submitStateChangeClient.SubmitAttachmentStateChanges(&req)
+	if err != nil {
+		seelog.Warnf("Could not submit attachment state change [%s]: %v", change.String(), err)
+		return err
+	}
+	return nil
+}
+
 func (client *APIECSClient) DiscoverPollEndpoint(containerInstanceArn string) (string, error) {
 	resp, err := client.discoverPollEndpoint(containerInstanceArn)
 	if err != nil {
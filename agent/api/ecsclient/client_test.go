@@ -22,6 +22,7 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -118,8 +119,13 @@ func (lhs *containerSubmitInputMatcher) Matches(x interface{}) bool {
 	return (equal(lhs.Cluster, rhs.Cluster) &&
 		equal(lhs.ContainerName, rhs.ContainerName) &&
 		equal(lhs.ExitCode, rhs.ExitCode) &&
+		equal(lhs.FinishedAt, rhs.FinishedAt) &&
+		equal(lhs.HealthStatus, rhs.HealthStatus) &&
+		equal(lhs.ImageDigest, rhs.ImageDigest) &&
 		equal(lhs.NetworkBindings, rhs.NetworkBindings) &&
 		equal(lhs.Reason, rhs.Reason) &&
+		equal(lhs.RuntimeId, rhs.RuntimeId) &&
+		equal(lhs.StartedAt, rhs.StartedAt) &&
 		equal(lhs.Status, rhs.Status) &&
 		equal(lhs.Task, rhs.Task))
 }
@@ -244,12 +250,115 @@ func TestSubmitContainerStateChangeFull(t *testing.T) {
 	}
 }
 
+func TestSubmitContainerStateChangeWithRuntimeID(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+
+	mockSubmitStateClient.EXPECT().SubmitContainerStateChange(&containerSubmitInputMatcher{
+		ecs.SubmitContainerStateChangeInput{
+			Cluster:         strptr(configuredCluster),
+			Task:            strptr("arn"),
+			ContainerName:   strptr("cont"),
+			RuntimeId:       strptr("runtime-id"),
+			Status:          strptr("RUNNING"),
+			NetworkBindings: []*ecs.NetworkBinding{},
+		},
+	})
+	err := client.SubmitContainerStateChange(api.ContainerStateChange{
+		TaskArn:       "arn",
+		ContainerName: "cont",
+		RuntimeID:     "runtime-id",
+		Status:        apicontainerstatus.ContainerRunning,
+	})
+	assert.NoError(t, err)
+}
+
+func TestSubmitContainerStateChangeWithImageDigest(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+
+	mockSubmitStateClient.EXPECT().SubmitContainerStateChange(&containerSubmitInputMatcher{
+		ecs.SubmitContainerStateChangeInput{
+			Cluster:         strptr(configuredCluster),
+			Task:            strptr("arn"),
+			ContainerName:   strptr("cont"),
+			ImageDigest:     strptr("sha256:digest"),
+			Status:          strptr("RUNNING"),
+			NetworkBindings: []*ecs.NetworkBinding{},
+		},
+	})
+	err := client.SubmitContainerStateChange(api.ContainerStateChange{
+		TaskArn:       "arn",
+		ContainerName: "cont",
+		ImageDigest:   "sha256:digest",
+		Status:        apicontainerstatus.ContainerRunning,
+	})
+	assert.NoError(t, err)
+}
+
+func TestSubmitContainerStateChangeWithHealthStatus(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+
+	mockSubmitStateClient.EXPECT().SubmitContainerStateChange(&containerSubmitInputMatcher{
+		ecs.SubmitContainerStateChangeInput{
+			Cluster:         strptr(configuredCluster),
+			Task:            strptr("arn"),
+			ContainerName:   strptr("cont"),
+			Status:          strptr("RUNNING"),
+			HealthStatus:    strptr("HEALTHY"),
+			NetworkBindings: []*ecs.NetworkBinding{},
+		},
+	})
+	err := client.SubmitContainerStateChange(api.ContainerStateChange{
+		TaskArn:       "arn",
+		ContainerName: "cont",
+		Status:        apicontainerstatus.ContainerRunning,
+		HealthStatus:  apicontainerstatus.ContainerHealthy,
+	})
+	assert.NoError(t, err)
+}
+
+func TestSubmitContainerStateChangeWithTimestamps(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+	exitCode := 0
+	startedAt := time.Unix(1500000000, 0).UTC()
+	finishedAt := startedAt.Add(time.Minute)
+
+	mockSubmitStateClient.EXPECT().SubmitContainerStateChange(&containerSubmitInputMatcher{
+		ecs.SubmitContainerStateChangeInput{
+			Cluster:         strptr(configuredCluster),
+			Task:            strptr("arn"),
+			ContainerName:   strptr("cont"),
+			Status:          strptr("STOPPED"),
+			ExitCode:        int64ptr(&exitCode),
+			StartedAt:       aws.Time(startedAt),
+			FinishedAt:      aws.Time(finishedAt),
+			NetworkBindings: []*ecs.NetworkBinding{},
+		},
+	})
+	err := client.SubmitContainerStateChange(api.ContainerStateChange{
+		TaskArn:       "arn",
+		ContainerName: "cont",
+		Status:        apicontainerstatus.ContainerStopped,
+		ExitCode:      &exitCode,
+		StartedAt:     aws.Time(startedAt),
+		FinishedAt:    aws.Time(finishedAt),
+	})
+	assert.NoError(t, err)
+}
+
 func TestSubmitContainerStateChangeReason(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
 	exitCode := 20
-	reason := strings.Repeat("a", ecsMaxReasonLength)
+	reason := strings.Repeat("a", api.MaxReasonLength)
 
 	mockSubmitStateClient.EXPECT().SubmitContainerStateChange(&containerSubmitInputMatcher{
 		ecs.SubmitContainerStateChangeInput{
@@ -279,8 +388,8 @@ func TestSubmitContainerStateChangeLongReason(t *testing.T) {
 	defer mockCtrl.Finish()
 	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
 	exitCode := 20
-	trimmedReason := strings.Repeat("a", ecsMaxReasonLength)
-	reason := strings.Repeat("a", ecsMaxReasonLength+1)
+	trimmedReason := strings.Repeat("a", api.MaxReasonLength-len("…")) + "…"
+	reason := strings.Repeat("a", api.MaxReasonLength+1)
 
 	mockSubmitStateClient.EXPECT().SubmitContainerStateChange(&containerSubmitInputMatcher{
 		ecs.SubmitContainerStateChangeInput{
@@ -808,6 +917,32 @@ func TestSubmitTaskStateChangeWithAttachments(t *testing.T) {
 	assert.NoError(t, err, "Unable to submit task state change with attachments")
 }
 
+// TestSubmitAttachmentStateChange tests the SubmitAttachmentStateChange API
+// sends the attachment status through SubmitAttachmentStateChanges
+func TestSubmitAttachmentStateChange(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+	mockSubmitStateClient.EXPECT().SubmitAttachmentStateChanges(&ecs.SubmitAttachmentStateChangesInput{
+		Cluster: aws.String(configuredCluster),
+		Attachments: []*ecs.AttachmentStateChange{
+			{
+				AttachmentArn: aws.String("eni_arn"),
+				Status:        aws.String("ATTACHED"),
+			},
+		},
+	})
+
+	err := client.SubmitAttachmentStateChange(api.AttachmentStateChange{
+		Attachment: &apieni.ENIAttachment{
+			AttachmentARN: "eni_arn",
+			Status:        apieni.ENIAttached,
+		},
+	})
+	assert.NoError(t, err, "Unable to submit attachment state change")
+}
+
 func TestSubmitTaskStateChangeWithoutAttachments(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -829,6 +964,221 @@ func TestSubmitTaskStateChangeWithoutAttachments(t *testing.T) {
 	assert.NoError(t, err, "Unable to submit task state change with no attachments")
 }
 
+// TestSubmitTaskStateChangeLongReason tests that the reason of the task is
+// truncated without splitting a multibyte rune
+func TestSubmitTaskStateChangeLongReason(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+	// "é" is 2 bytes long, the cut falls in the middle of a rune
+	reason := "a" + strings.Repeat("é", api.MaxReasonLength)
+	mockSubmitStateClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(
+		func(req *ecs.SubmitTaskStateChangeInput) {
+			truncated := aws.StringValue(req.Reason)
+			assert.True(t, len(truncated) <= api.MaxReasonLength)
+			assert.True(t, utf8.ValidString(truncated))
+			assert.True(t, strings.HasSuffix(truncated, "…"))
+			assert.True(t, strings.HasPrefix(reason, strings.TrimSuffix(truncated, "…")))
+		})
+
+	err := client.SubmitTaskStateChange(api.TaskStateChange{
+		TaskARN: "task_arn",
+		Status:  apitaskstatus.TaskStopped,
+		Reason:  reason,
+	})
+	assert.NoError(t, err)
+}
+
+// TestSubmitTaskStateChangeContainerRuntimeID tests that the runtime id is
+// included in the container state changes only when it's known
+func TestSubmitTaskStateChangeContainerRuntimeID(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+	mockSubmitStateClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(
+		func(req *ecs.SubmitTaskStateChangeInput) {
+			assert.Len(t, req.Containers, 2)
+			assert.Equal(t, "runtime-id", aws.StringValue(req.Containers[0].RuntimeId))
+			assert.Nil(t, req.Containers[1].RuntimeId)
+		})
+
+	err := client.SubmitTaskStateChange(api.TaskStateChange{
+		TaskARN: "arn",
+		Status:  apitaskstatus.TaskStopped,
+		Containers: []api.ContainerStateChange{
+			{
+				TaskArn:       "arn",
+				ContainerName: "created",
+				RuntimeID:     "runtime-id",
+				Status:        apicontainerstatus.ContainerStopped,
+			},
+			{
+				TaskArn:       "arn",
+				ContainerName: "pull-failed",
+				Status:        apicontainerstatus.ContainerStopped,
+			},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+// TestSubmitTaskStateChangeContainerImageDigest tests that the image digest is
+// included in the container state changes only when it's known
+func TestSubmitTaskStateChangeContainerImageDigest(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+	mockSubmitStateClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(
+		func(req *ecs.SubmitTaskStateChangeInput) {
+			assert.Len(t, req.Containers, 2)
+			assert.Equal(t, "sha256:digest", aws.StringValue(req.Containers[0].ImageDigest))
+			assert.Nil(t, req.Containers[1].ImageDigest)
+		})
+
+	err := client.SubmitTaskStateChange(api.TaskStateChange{
+		TaskARN: "arn",
+		Status:  apitaskstatus.TaskStopped,
+		Containers: []api.ContainerStateChange{
+			{
+				TaskArn:       "arn",
+				ContainerName: "pulled",
+				ImageDigest:   "sha256:digest",
+				Status:        apicontainerstatus.ContainerStopped,
+			},
+			{
+				TaskArn:       "arn",
+				ContainerName: "pull-failed",
+				Status:        apicontainerstatus.ContainerStopped,
+			},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestSubmitTaskStateChangeContainerHealthStatus(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+	mockSubmitStateClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(
+		func(req *ecs.SubmitTaskStateChangeInput) {
+			assert.Len(t, req.Containers, 2)
+			assert.Equal(t, "UNHEALTHY", aws.StringValue(req.Containers[0].HealthStatus))
+			assert.Nil(t, req.Containers[1].HealthStatus)
+		})
+
+	err := client.SubmitTaskStateChange(api.TaskStateChange{
+		TaskARN: "arn",
+		Status:  apitaskstatus.TaskRunning,
+		Containers: []api.ContainerStateChange{
+			{
+				TaskArn:       "arn",
+				ContainerName: "health-checked",
+				Status:        apicontainerstatus.ContainerRunning,
+				HealthStatus:  apicontainerstatus.ContainerUnhealthy,
+			},
+			{
+				TaskArn:       "arn",
+				ContainerName: "not-health-checked",
+				Status:        apicontainerstatus.ContainerRunning,
+			},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestSubmitTaskStateChangeContainerTimestamps(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	startedAt := time.Unix(1500000000, 0).UTC()
+
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+	mockSubmitStateClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(
+		func(req *ecs.SubmitTaskStateChangeInput) {
+			assert.Len(t, req.Containers, 2)
+			assert.Equal(t, startedAt, aws.TimeValue(req.Containers[0].StartedAt))
+			assert.Nil(t, req.Containers[0].FinishedAt)
+			assert.Nil(t, req.Containers[1].StartedAt)
+			assert.Nil(t, req.Containers[1].FinishedAt)
+		})
+
+	err := client.SubmitTaskStateChange(api.TaskStateChange{
+		TaskARN: "arn",
+		Status:  apitaskstatus.TaskRunning,
+		Containers: []api.ContainerStateChange{
+			{
+				TaskArn:       "arn",
+				ContainerName: "started",
+				Status:        apicontainerstatus.ContainerRunning,
+				StartedAt:     aws.Time(startedAt),
+			},
+			{
+				TaskArn:       "arn",
+				ContainerName: "not-started",
+				Status:        apicontainerstatus.ContainerStopped,
+			},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+// TestSubmitTaskStateChangeContainerUDPBindings tests that the protocol of the
+// container port bindings is retained when submitting task state changes
+func TestSubmitTaskStateChangeContainerUDPBindings(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, _, mockSubmitStateClient := NewMockClient(mockCtrl, ec2.NewBlackholeEC2MetadataClient(), nil)
+	mockSubmitStateClient.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(
+		func(req *ecs.SubmitTaskStateChangeInput) {
+			assert.Len(t, req.Containers, 1)
+			assert.Equal(t, []*ecs.NetworkBinding{
+				{
+					BindIP:        strptr("0.0.0.0"),
+					ContainerPort: int64ptr(intptr(53)),
+					HostPort:      int64ptr(intptr(32768)),
+					Protocol:      strptr("udp"),
+				},
+				{
+					BindIP:        strptr("0.0.0.0"),
+					ContainerPort: int64ptr(intptr(80)),
+					HostPort:      int64ptr(intptr(32769)),
+					Protocol:      strptr("tcp"),
+				},
+			}, req.Containers[0].NetworkBindings)
+		})
+
+	err := client.SubmitTaskStateChange(api.TaskStateChange{
+		TaskARN: "arn",
+		Status:  apitaskstatus.TaskRunning,
+		Containers: []api.ContainerStateChange{
+			{
+				TaskArn:       "arn",
+				ContainerName: "dns",
+				Status:        apicontainerstatus.ContainerRunning,
+				PortBindings: []apicontainer.PortBinding{
+					{
+						BindIP:        "0.0.0.0",
+						ContainerPort: 53,
+						HostPort:      32768,
+						Protocol:      apicontainer.TransportProtocolUDP,
+					},
+					{
+						BindIP:        "0.0.0.0",
+						ContainerPort: 80,
+						HostPort:      32769,
+						Protocol:      apicontainer.TransportProtocolTCP,
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+}
+
 // TestSubmitContainerStateChangeWhileTaskInPending tests the container state change was submitted
 // when the task is still in pending state
 func TestSubmitContainerStateChangeWhileTaskInPending(t *testing.T) {
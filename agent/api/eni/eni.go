@@ -44,6 +44,9 @@ type ENI struct {
 	// SubnetGatewayIPV4Address is the address to the subnet gateway for
 	// the eni
 	SubnetGatewayIPV4Address string `json:",omitempty"`
+	// SubnetGatewayIPV6Address is the address to the ipv6 subnet gateway for
+	// the eni, set on dual-stack subnets
+	SubnetGatewayIPV6Address string `json:",omitempty"`
 }
 
 // GetIPV4Addresses returns a list of ipv4 addresses allocated to the ENI
@@ -77,6 +80,12 @@ func (eni *ENI) GetSubnetGatewayIPV4Address() string {
 	return eni.SubnetGatewayIPV4Address
 }
 
+// GetSubnetGatewayIPV6Address returns the subnet IPv6 gateway address assigned
+// to the ENI
+func (eni *ENI) GetSubnetGatewayIPV6Address() string {
+	return eni.SubnetGatewayIPV6Address
+}
+
 // String returns a human readable version of the ENI object
 func (eni *ENI) String() string {
 	var ipv4Addresses []string
@@ -87,10 +96,14 @@ func (eni *ENI) String() string {
 	for _, addr := range eni.IPV6Addresses {
 		ipv6Addresses = append(ipv6Addresses, addr.Address)
 	}
-	return fmt.Sprintf(
+	res := fmt.Sprintf(
 		"eni id:%s, mac: %s, hostname: %s, ipv4addresses: [%s], ipv6addresses: [%s], dns: [%s], dns search: [%s], gateway ipv4: [%s]",
 		eni.ID, eni.MacAddress, eni.GetHostname(), strings.Join(ipv4Addresses, ","), strings.Join(ipv6Addresses, ","),
 		strings.Join(eni.DomainNameServers, ","), strings.Join(eni.DomainNameSearchList, ","), eni.SubnetGatewayIPV4Address)
+	if eni.SubnetGatewayIPV6Address != "" {
+		res += fmt.Sprintf(", gateway ipv6: [%s]", eni.SubnetGatewayIPV6Address)
+	}
+	return res
 }
 
 // ENIIPV4Address is the ipv4 information of the eni
@@ -139,6 +152,7 @@ func ENIFromACS(acsenis []*ecsacs.ElasticNetworkInterface) (*ENI, error) {
 		MacAddress:               aws.StringValue(acsenis[0].MacAddress),
 		PrivateDNSName:           aws.StringValue(acsenis[0].PrivateDnsName),
 		SubnetGatewayIPV4Address: aws.StringValue(acsenis[0].SubnetGatewayIpv4Address),
+		SubnetGatewayIPV6Address: aws.StringValue(acsenis[0].SubnetGatewayIpv6Address),
 	}
 	for _, nameserverIP := range acsenis[0].DomainNameServers {
 		eni.DomainNameServers = append(eni.DomainNameServers, aws.StringValue(nameserverIP))
@@ -45,10 +45,11 @@ func TestENIFromACS(t *testing.T) {
 				{
 					Address: aws.String("ipv6")},
 			},
-			MacAddress:        aws.String("mac"),
-			DomainNameServers: []*string{aws.String(defaultDNS), aws.String(customDNS)},
-			DomainName:        []*string{aws.String(customSearchDomain)},
-			PrivateDnsName:    aws.String("ip.region.compute.internal"),
+			MacAddress:               aws.String("mac"),
+			DomainNameServers:        []*string{aws.String(defaultDNS), aws.String(customDNS)},
+			DomainName:               []*string{aws.String(customSearchDomain)},
+			PrivateDnsName:           aws.String("ip.region.compute.internal"),
+			SubnetGatewayIpv6Address: aws.String("2600:1f14::1"),
 		},
 	}
 
@@ -70,6 +71,32 @@ func TestENIFromACS(t *testing.T) {
 	assert.Len(t, eni.DomainNameSearchList, 1)
 	assert.Equal(t, customSearchDomain, eni.DomainNameSearchList[0])
 	assert.Equal(t, aws.StringValue(acsenis[0].PrivateDnsName), eni.PrivateDNSName)
+	assert.Equal(t, aws.StringValue(acsenis[0].SubnetGatewayIpv6Address), eni.GetSubnetGatewayIPV6Address())
+}
+
+// TestENIFromACSIPV4Only tests that enis of ipv4 only subnets carry no ipv6
+// information
+func TestENIFromACSIPV4Only(t *testing.T) {
+	acsenis := []*ecsacs.ElasticNetworkInterface{
+		{
+			AttachmentArn: aws.String("arn"),
+			Ec2Id:         aws.String("ec2id"),
+			Ipv4Addresses: []*ecsacs.IPv4AddressAssignment{
+				{
+					Primary:        aws.Bool(true),
+					PrivateAddress: aws.String("ipv4"),
+				},
+			},
+			MacAddress:               aws.String("mac"),
+			SubnetGatewayIpv4Address: aws.String("10.0.0.1/24"),
+		},
+	}
+
+	eni, err := ENIFromACS(acsenis)
+	assert.NoError(t, err)
+	assert.Empty(t, eni.GetIPV6Addresses())
+	assert.Empty(t, eni.GetSubnetGatewayIPV6Address())
+	assert.NotContains(t, eni.String(), "gateway ipv6")
 }
 
 // TestValidateENIFromACS tests the validation of enis from acs
@@ -15,6 +15,7 @@ package eni
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -33,12 +34,21 @@ type ENIAttachment struct {
 	AttachStatusSent bool `json:"attachSent"`
 	// MACAddress is the mac address of eni
 	MACAddress string `json:"macAddress"`
+	// IPV6Addresses are the ipv6 addresses of the eni, only set on dual-stack
+	// subnets
+	IPV6Addresses []string `json:"ipv6Addresses,omitempty"`
 	// Status is the status of the eni: none/attached/detached
 	Status ENIAttachmentStatus `json:"status"`
 	// ExpiresAt is the timestamp past which the ENI Attachment is considered
 	// unsuccessful. The SubmitTaskStateChange API, with the attachment information
 	// should be invoked before this timestamp.
 	ExpiresAt time.Time `json:"expiresAt"`
+	// acked is set once ECS has acknowledged the attached status, by sending
+	// down the task of the attachment
+	acked bool
+	// submissionRejected is set when ECS rejected the attached status, which
+	// is then never sent again
+	submissionRejected bool
 	// ackTimer is used to register the expirtation timeout callback for unsuccessful
 	// ENI attachments
 	ackTimer ttime.Timer
@@ -82,12 +92,48 @@ func (eni *ENIAttachment) SetSentStatus() {
 	eni.AttachStatusSent = true
 }
 
+// SetAcked marks the eni attached status as acknowledged by ECS
+func (eni *ENIAttachment) SetAcked() {
+	eni.guard.Lock()
+	defer eni.guard.Unlock()
+
+	eni.acked = true
+}
+
+// IsAcked checks if the eni attached status has been acknowledged by ECS
+func (eni *ENIAttachment) IsAcked() bool {
+	eni.guard.RLock()
+	defer eni.guard.RUnlock()
+
+	return eni.acked
+}
+
+// SetSubmissionRejected marks the eni attached status as rejected by ECS
+func (eni *ENIAttachment) SetSubmissionRejected() {
+	eni.guard.Lock()
+	defer eni.guard.Unlock()
+
+	eni.submissionRejected = true
+}
+
+// IsSubmissionRejected checks if the eni attached status has been rejected by
+// ECS, in which case it mustn't be sent again
+func (eni *ENIAttachment) IsSubmissionRejected() bool {
+	eni.guard.RLock()
+	defer eni.guard.RUnlock()
+
+	return eni.submissionRejected
+}
+
 // StopAckTimer stops the ack timer set on the ENI attachment
 func (eni *ENIAttachment) StopAckTimer() {
 	eni.guard.Lock()
 	defer eni.guard.Unlock()
 
-	eni.ackTimer.Stop()
+	// The timer isn't set for attachments loaded from the state file
+	if eni.ackTimer != nil {
+		eni.ackTimer.Stop()
+	}
 }
 
 // HasExpired returns true if the ENI attachment object has exceeded the
@@ -109,7 +155,11 @@ func (eni *ENIAttachment) String() string {
 
 // stringUnsafe returns a string representation of the ENI Attachment
 func (eni *ENIAttachment) stringUnsafe() string {
-	return fmt.Sprintf(
+	res := fmt.Sprintf(
 		"ENI Attachment: task=%s;attachment=%s;attachmentSent=%t;mac=%s;status=%s;expiresAt=%s",
 		eni.TaskARN, eni.AttachmentARN, eni.AttachStatusSent, eni.MACAddress, eni.Status.String(), eni.ExpiresAt.String())
+	if len(eni.IPV6Addresses) > 0 {
+		res += ";ipv6Addresses=" + strings.Join(eni.IPV6Addresses, ",")
+	}
+	return res
 }
@@ -50,6 +50,7 @@ func TestMarshalUnmarshal(t *testing.T) {
 	assert.Equal(t, attachment.AttachStatusSent, unmarshalledAttachment.AttachStatusSent)
 	assert.Equal(t, attachment.MACAddress, unmarshalledAttachment.MACAddress)
 	assert.Equal(t, attachment.Status, unmarshalledAttachment.Status)
+	assert.Empty(t, unmarshalledAttachment.IPV6Addresses)
 
 	expectedExpiresAtUTC, err := time.Parse(time.RFC3339, attachment.ExpiresAt.Format(time.RFC3339))
 	assert.NoError(t, err)
@@ -58,6 +59,18 @@ func TestMarshalUnmarshal(t *testing.T) {
 	assert.Equal(t, expectedExpiresAtUTC, unmarshalledExpiresAtUTC)
 }
 
+func TestStringIPV6Addresses(t *testing.T) {
+	attachment := &ENIAttachment{
+		TaskARN:       taskARN,
+		AttachmentARN: attachmentARN,
+		MACAddress:    mac,
+	}
+	assert.NotContains(t, attachment.String(), "ipv6Addresses")
+
+	attachment.IPV6Addresses = []string{"2001:db8::2"}
+	assert.Contains(t, attachment.String(), "ipv6Addresses=2001:db8::2")
+}
+
 func TestStartTimerErrorWhenExpiresAtIsInThePast(t *testing.T) {
 	expiresAt := time.Now().Unix() - 1
 	attachment := &ENIAttachment{
@@ -93,3 +106,21 @@ func TestHasExpired(t *testing.T) {
 		})
 	}
 }
+
+func TestAckedAndRejectedIndependentOfSentStatus(t *testing.T) {
+	attachment := &ENIAttachment{
+		TaskARN:       taskARN,
+		AttachmentARN: attachmentARN,
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	attachment.SetSentStatus()
+	assert.False(t, attachment.IsAcked())
+	assert.False(t, attachment.IsSubmissionRejected())
+
+	attachment.SetAcked()
+	assert.True(t, attachment.IsAcked())
+	assert.True(t, attachment.IsSent())
+
+	attachment.SetSubmissionRejected()
+	assert.True(t, attachment.IsSubmissionRejected())
+}
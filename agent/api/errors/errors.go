@@ -118,3 +118,25 @@ func (err *ResourceInitError) Error() string {
 func (err *ResourceInitError) ErrorName() string {
 	return "ResourceInitializationError"
 }
+
+// InvalidTaskError is a task error for which a container of the task is
+// configured with settings that can't be applied
+type InvalidTaskError struct {
+	taskARN string
+	origErr error
+}
+
+// NewInvalidTaskError creates an error for an invalid task configuration
+func NewInvalidTaskError(taskARN string, origErr error) *InvalidTaskError {
+	return &InvalidTaskError{taskARN, origErr}
+}
+
+// Error returns the error as a string
+func (err *InvalidTaskError) Error() string {
+	return fmt.Sprintf("invalid configuration for task %s: %v", err.taskARN, err.origErr)
+}
+
+// ErrorName is the name of the error
+func (err *InvalidTaskError) ErrorName() string {
+	return "InvalidTaskError"
+}
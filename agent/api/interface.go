@@ -34,6 +34,9 @@ type ECSClient interface {
 	// SubmitContainerStateChange sends a state change and returns an error
 	// indicating if it was submitted
 	SubmitContainerStateChange(change ContainerStateChange) error
+	// SubmitAttachmentStateChange sends an attachment state change and returns an error
+	// indicating if it was submitted
+	SubmitAttachmentStateChange(change AttachmentStateChange) error
 	// DiscoverPollEndpoint takes a ContainerInstanceARN and returns the
 	// endpoint at which this Agent should contact ACS
 	DiscoverPollEndpoint(containerInstanceArn string) (string, error)
@@ -52,8 +55,10 @@ type ECSSDK interface {
 }
 
 // ECSSubmitStateSDK is an interface with customized ecs client that
-// implements the SubmitTaskStateChange and SubmitContainerStateChange
+// implements the SubmitTaskStateChange, SubmitContainerStateChange and
+// SubmitAttachmentStateChanges
 type ECSSubmitStateSDK interface {
 	SubmitContainerStateChange(*ecs.SubmitContainerStateChangeInput) (*ecs.SubmitContainerStateChangeOutput, error)
 	SubmitTaskStateChange(*ecs.SubmitTaskStateChangeInput) (*ecs.SubmitTaskStateChangeOutput, error)
+	SubmitAttachmentStateChanges(*ecs.SubmitAttachmentStateChangesInput) (*ecs.SubmitAttachmentStateChangesOutput, error)
 }
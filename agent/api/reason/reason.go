@@ -0,0 +1,79 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package reason
+
+const (
+	// ReasonCodeNone is the zero state of a reason code; the cause of the
+	// state change isn't known
+	ReasonCodeNone ReasonCode = iota
+	// ReasonCodeOutOfMemory represents a container killed due to its memory
+	// usage
+	ReasonCodeOutOfMemory
+	// ReasonCodeCannotPullContainer represents a container whose image could
+	// not be pulled
+	ReasonCodeCannotPullContainer
+	// ReasonCodeEssentialContainerExited represents a task stopped because one
+	// of its essential containers exited
+	ReasonCodeEssentialContainerExited
+	// ReasonCodeUserInitiatedStop represents a task stopped because ACS
+	// requested it to be stopped, for example through StopTask
+	ReasonCodeUserInitiatedStop
+	// ReasonCodeEphemeralStorageExceeded represents a task stopped because its
+	// containers used more disk space than its ephemeral storage limit
+	ReasonCodeEphemeralStorageExceeded
+	// ReasonCodeTaskNotInManifest represents a task stopped because it was
+	// missing from the task manifest sent by ACS
+	ReasonCodeTaskNotInManifest
+)
+
+// ReasonCode is an enumeration of the causes of a state change, which are
+// reported alongside the free-text reason
+type ReasonCode int32
+
+var reasonCodeMap = map[string]ReasonCode{
+	"None":                     ReasonCodeNone,
+	"OutOfMemory":              ReasonCodeOutOfMemory,
+	"CannotPullContainer":      ReasonCodeCannotPullContainer,
+	"EssentialContainerExited": ReasonCodeEssentialContainerExited,
+	"UserInitiatedStop":        ReasonCodeUserInitiatedStop,
+	"EphemeralStorageExceeded": ReasonCodeEphemeralStorageExceeded,
+	"TaskNotInManifest":        ReasonCodeTaskNotInManifest,
+}
+
+// errorNameReasonCodes maps the names of the errors recorded for containers to
+// the reason codes of their state changes
+var errorNameReasonCodes = map[string]ReasonCode{
+	"OutOfMemoryError":             ReasonCodeOutOfMemory,
+	"CannotPullContainerError":     ReasonCodeCannotPullContainer,
+	"CannotPullECRContainerError":  ReasonCodeCannotPullContainer,
+	"CannotPullContainerAuthError": ReasonCodeCannotPullContainer,
+	"PullInactivityTimeoutError":   ReasonCodeCannotPullContainer,
+}
+
+// String returns a human readable string representation of this object
+func (code ReasonCode) String() string {
+	for k, v := range reasonCodeMap {
+		if v == code {
+			return k
+		}
+	}
+	return "None"
+}
+
+// FromErrorName returns the reason code for the state change of a container
+// that failed with the named error. ReasonCodeNone is returned for errors that
+// don't map to a reason code
+func FromErrorName(name string) ReasonCode {
+	return errorNameReasonCodes[name]
+}
@@ -0,0 +1,53 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package reason
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReasonCodeString(t *testing.T) {
+	assert.Equal(t, "None", ReasonCodeNone.String())
+	assert.Equal(t, "OutOfMemory", ReasonCodeOutOfMemory.String())
+	assert.Equal(t, "CannotPullContainer", ReasonCodeCannotPullContainer.String())
+	assert.Equal(t, "EssentialContainerExited", ReasonCodeEssentialContainerExited.String())
+	assert.Equal(t, "UserInitiatedStop", ReasonCodeUserInitiatedStop.String())
+	assert.Equal(t, "EphemeralStorageExceeded", ReasonCodeEphemeralStorageExceeded.String())
+	assert.Equal(t, "TaskNotInManifest", ReasonCodeTaskNotInManifest.String())
+	assert.Equal(t, "None", ReasonCode(100).String())
+}
+
+func TestFromErrorName(t *testing.T) {
+	testCases := []struct {
+		name string
+		code ReasonCode
+	}{
+		{"OutOfMemoryError", ReasonCodeOutOfMemory},
+		{"CannotPullContainerError", ReasonCodeCannotPullContainer},
+		{"CannotPullECRContainerError", ReasonCodeCannotPullContainer},
+		{"CannotPullContainerAuthError", ReasonCodeCannotPullContainer},
+		{"PullInactivityTimeoutError", ReasonCodeCannotPullContainer},
+		{"DockerTimeoutError", ReasonCodeNone},
+		{"", ReasonCodeNone},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.code, FromErrorName(tc.name))
+		})
+	}
+}
@@ -17,18 +17,29 @@ import (
 	"fmt"
 	"strconv"
 	"time"
+	"unicode/utf8"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/statechange"
+	"github.com/cihub/seelog"
 	"github.com/pkg/errors"
 
 	"github.com/aws/aws-sdk-go/aws"
 )
 
+const (
+	// MaxReasonLength is the maximum length, in bytes, of the reason that
+	// can be submitted to ECS as part of a state change
+	MaxReasonLength = 255
+	// reasonTruncationSuffix is appended to reasons that have been truncated
+	reasonTruncationSuffix = "…"
+)
+
 // ContainerStateChange represents a state change that needs to be sent to the
 // SubmitContainerStateChange API
 type ContainerStateChange struct {
@@ -36,17 +47,31 @@ type ContainerStateChange struct {
 	TaskArn string
 	// ContainerName is the name of the container
 	ContainerName string
+	// RuntimeID is the docker id of the container
+	RuntimeID string
+	// ImageDigest is the digest of the image the container was started from
+	ImageDigest string
 	// Status is the status to send
 	Status apicontainerstatus.ContainerStatus
 
 	// Reason may contain details of why the container stopped
 	Reason string
+	// ReasonCode is the cause of the state change, if known
+	ReasonCode apireason.ReasonCode
 	// ExitCode is the exit code of the container, if available
 	ExitCode *int
+	// HealthStatus is the health status of the container to send. It's only
+	// set when the health status has transitioned since it was last sent
+	HealthStatus apicontainerstatus.ContainerHealthStatus
 	// PortBindings are the details of the host ports picked for the specified
 	// container ports
 	PortBindings []apicontainer.PortBinding
 
+	// StartedAt is the timestamp when the container started
+	StartedAt *time.Time
+	// FinishedAt is the timestamp when the container exited
+	FinishedAt *time.Time
+
 	// Container is a pointer to the container involved in the state change that gives the event handler a hook into
 	// storing what status was sent.  This is used to ensure the same event is handled only once.
 	Container *apicontainer.Container
@@ -63,6 +88,11 @@ type TaskStateChange struct {
 	Status apitaskstatus.TaskStatus
 	// Reason may contain details of why the task stopped
 	Reason string
+	// ReasonCode is the cause of the state change, if known
+	ReasonCode apireason.ReasonCode
+	// SequenceNumber orders the state changes created for the task. It's zero
+	// for state changes which weren't created from the task
+	SequenceNumber uint64
 	// Containers holds the events generated by containers owned by this task
 	Containers []ContainerStateChange
 
@@ -78,6 +108,13 @@ type TaskStateChange struct {
 	Task *apitask.Task
 }
 
+// AttachmentStateChange represents a state change that needs to be sent to the
+// SubmitAttachmentStateChanges API
+type AttachmentStateChange struct {
+	// Attachment is the eni attachment object to send
+	Attachment *apieni.ENIAttachment
+}
+
 // NewTaskStateChangeEvent creates a new task state change event
 func NewTaskStateChangeEvent(task *apitask.Task, reason string) (TaskStateChange, error) {
 	var event TaskStateChange
@@ -93,12 +130,25 @@ func NewTaskStateChangeEvent(task *apitask.Task, reason string) (TaskStateChange
 			taskKnownStatus.String())
 	}
 
+	var reasonCode apireason.ReasonCode
+	if taskKnownStatus.Terminal() {
+		reasonCode = task.GetTerminalReasonCode()
+		// The reason given by ACS is preferred when ACS stopped the task,
+		// while the tasks stopped by their containers keep the reason derived
+		// from the containers
+		if stoppedReason := task.GetStoppedReason(); stoppedReason != "" &&
+			(reasonCode == apireason.ReasonCodeNone || reasonCode == apireason.ReasonCodeUserInitiatedStop) {
+			reason = stoppedReason
+		}
+	}
 	event = TaskStateChange{
-		TaskARN: task.Arn,
-		Status:  taskKnownStatus,
-		Reason:  reason,
-		Task:    task,
+		TaskARN:    task.Arn,
+		Status:     taskKnownStatus,
+		Reason:     TruncateReason(reason, task.Arn),
+		ReasonCode: reasonCode,
+		Task:       task,
 	}
+	event.SequenceNumber = task.NextStateChangeSequence()
 
 	event.SetTaskTimestamps()
 
@@ -125,31 +175,117 @@ func NewContainerStateChangeEvent(task *apitask.Task, cont *apicontainer.Contain
 			contKnownStatus.String(), cont.Name, task.Arn)
 	}
 
-	if reason == "" && cont.ApplyingError != nil {
-		reason = cont.ApplyingError.Error()
+	var reasonCode apireason.ReasonCode
+	if cont.ApplyingError != nil {
+		if reason == "" {
+			reason = cont.ApplyingError.Error()
+		}
+		reasonCode = apireason.FromErrorName(cont.ApplyingError.ErrorName())
+	}
+	// The latest recorded exit is the source of truth for the exit code, so
+	// that the event agrees with the exit history of the container
+	exitCode := cont.GetKnownExitCode()
+	if exit, ok := cont.GetLatestExit(); ok {
+		exitCode = exit.ExitCode
+	}
+	event = ContainerStateChange{
+		TaskArn:       task.Arn,
+		ContainerName: cont.Name,
+		RuntimeID:     cont.GetRuntimeID(),
+		ImageDigest:   cont.GetImageDigest(),
+		Status:        contKnownStatus.BackendStatus(cont.GetSteadyStateStatus()),
+		ExitCode:      exitCode,
+		PortBindings:  cont.GetKnownPortBindings(),
+		Reason:        TruncateReason(reason, task.Arn),
+		ReasonCode:    reasonCode,
+		Container:     cont,
+	}
+	if cont.HealthStatusShouldBeSent() {
+		event.HealthStatus = cont.GetHealthStatus().Status
+	}
+	event.SetContainerTimestamps()
+
+	return event, nil
+}
+
+// NewContainerHealthChangeEvent creates a new container state change event for
+// a transition of the container's health status while the container status
+// itself is unchanged
+func NewContainerHealthChangeEvent(task *apitask.Task, cont *apicontainer.Container) (ContainerStateChange, error) {
+	var event ContainerStateChange
+	if cont.IsInternal() {
+		return event, errors.Errorf(
+			"create container health change event api: internal container: %s",
+			cont.Name)
+	}
+	if !cont.HealthStatusShouldBeSent() {
+		return event, errors.Errorf(
+			"create container health change event api: health status [%s] already sent for container %s, task %s",
+			cont.GetHealthStatus().Status.String(), cont.Name, task.Arn)
+	}
+	contKnownStatus := cont.GetKnownStatus()
+	if contKnownStatus != cont.GetSteadyStateStatus() {
+		return event, errors.Errorf(
+			"create container health change event api: container %s, task %s is not running: %s",
+			cont.Name, task.Arn, contKnownStatus.String())
+	}
+	if cont.GetSentStatus() < contKnownStatus {
+		// The health status is sent along with the container status change
+		return event, errors.Errorf(
+			"create container health change event api: status [%s] not sent yet for container %s, task %s",
+			contKnownStatus.String(), cont.Name, task.Arn)
 	}
+
 	event = ContainerStateChange{
 		TaskArn:       task.Arn,
 		ContainerName: cont.Name,
+		RuntimeID:     cont.GetRuntimeID(),
+		ImageDigest:   cont.GetImageDigest(),
 		Status:        contKnownStatus.BackendStatus(cont.GetSteadyStateStatus()),
-		ExitCode:      cont.GetKnownExitCode(),
 		PortBindings:  cont.GetKnownPortBindings(),
-		Reason:        reason,
+		HealthStatus:  cont.GetHealthStatus().Status,
 		Container:     cont,
 	}
 
 	return event, nil
 }
 
+// TruncateReason caps the reason at the length accepted by ECS. The head of
+// the reason is kept without splitting a rune and the full reason is logged
+// so that it remains available for diagnostics
+func TruncateReason(reason string, taskARN string) string {
+	if len(reason) <= MaxReasonLength {
+		return reason
+	}
+	seelog.Infof("Truncating state change reason for task %s, full reason: %s", taskARN, reason)
+	end := MaxReasonLength - len(reasonTruncationSuffix)
+	for end > 0 && !utf8.RuneStart(reason[end]) {
+		end--
+	}
+	return reason[:end] + reasonTruncationSuffix
+}
+
 // String returns a human readable string representation of this object
 func (c *ContainerStateChange) String() string {
 	res := fmt.Sprintf("%s %s -> %s", c.TaskArn, c.ContainerName, c.Status.String())
+	if c.RuntimeID != "" {
+		res += ", RuntimeID " + c.RuntimeID
+	}
+	if c.ImageDigest != "" {
+		res += ", ImageDigest " + c.ImageDigest
+	}
 	if c.ExitCode != nil {
 		res += ", Exit " + strconv.Itoa(*c.ExitCode) + ", "
 	}
+	if c.HealthStatus != apicontainerstatus.ContainerHealthUnknown {
+		res += ", Health " + c.HealthStatus.String()
+	}
 	if c.Reason != "" {
 		res += ", Reason " + c.Reason
 	}
+	if c.ReasonCode != apireason.ReasonCodeNone {
+		res += ", ReasonCode " + c.ReasonCode.String()
+	}
 	if len(c.PortBindings) != 0 {
 		res += fmt.Sprintf(", Ports %v", c.PortBindings)
 	}
@@ -178,13 +314,33 @@ func (change *TaskStateChange) SetTaskTimestamps() {
 	}
 }
 
+// SetContainerTimestamps adds the timestamp information of container into the
+// event to be sent to ECS
+func (c *ContainerStateChange) SetContainerTimestamps() {
+	if c.Container == nil {
+		return
+	}
+
+	// Send the container timestamp if set
+	if timestamp := c.Container.GetStartedAt(); !timestamp.IsZero() {
+		c.StartedAt = aws.Time(timestamp.UTC())
+	}
+	if timestamp := c.Container.GetFinishedAt(); !timestamp.IsZero() {
+		c.FinishedAt = aws.Time(timestamp.UTC())
+	}
+}
+
 // ShouldBeReported checks if the statechange should be reported to backend
 func (change *TaskStateChange) ShouldBeReported() bool {
 	// Events that should be reported:
 	// 1. Normal task state change: RUNNING/STOPPED
 	// 2. Container state change, with task status in CREATED/RUNNING/STOPPED
-	// The task timestamp will be sent in both of the event type
-	// TODO Move the Attachment statechange check into this method
+	// 3. Attachment state change, whose ack timeout hasn't expired
+	// The task timestamp will be sent in both of the first two event types
+	if change.Attachment != nil {
+		return !change.Attachment.HasExpired()
+	}
+
 	if change.Status == apitaskstatus.TaskRunning || change.Status == apitaskstatus.TaskStopped {
 		return true
 	}
@@ -199,6 +355,12 @@ func (change *TaskStateChange) ShouldBeReported() bool {
 // String returns a human readable string representation of this object
 func (change *TaskStateChange) String() string {
 	res := fmt.Sprintf("%s -> %s", change.TaskARN, change.Status.String())
+	if change.ReasonCode != apireason.ReasonCodeNone {
+		res += ", ReasonCode " + change.ReasonCode.String()
+	}
+	if change.SequenceNumber != 0 {
+		res += ", SequenceNumber " + strconv.FormatUint(change.SequenceNumber, 10)
+	}
 	if change.Task != nil {
 		res += fmt.Sprintf(", Known Sent: %s, PullStartedAt: %s, PullStoppedAt: %s, ExecutionStoppedAt: %s",
 			change.Task.GetSentStatus().String(),
@@ -216,6 +378,21 @@ func (change *TaskStateChange) String() string {
 	return res
 }
 
+// ShouldBeReported checks if the attachment state change should be reported to
+// backend. Attachments whose ack timeout has expired are no longer tracked by
+// the backend and should not be reported
+func (change *AttachmentStateChange) ShouldBeReported() bool {
+	return change.Attachment != nil && !change.Attachment.HasExpired()
+}
+
+// String returns a human readable string representation of this object
+func (change *AttachmentStateChange) String() string {
+	if change.Attachment == nil {
+		return ""
+	}
+	return change.Attachment.String()
+}
+
 // GetEventType returns an enum identifying the event type
 func (ContainerStateChange) GetEventType() statechange.EventType {
 	return statechange.ContainerEvent
@@ -225,3 +402,8 @@ func (ContainerStateChange) GetEventType() statechange.EventType {
 func (TaskStateChange) GetEventType() statechange.EventType {
 	return statechange.TaskEvent
 }
+
+// GetEventType returns an enum identifying the event type
+func (AttachmentStateChange) GetEventType() statechange.EventType {
+	return statechange.AttachmentEvent
+}
@@ -0,0 +1,157 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
+)
+
+// containerStateChangeJSON is the json representation of ContainerStateChange.
+// The container the state change refers to is left out, as it's only used for
+// recording the sent status
+type containerStateChangeJSON struct {
+	TaskARN       string            `json:"taskArn"`
+	ContainerName string            `json:"containerName"`
+	RuntimeID     string            `json:"runtimeId,omitempty"`
+	ImageDigest   string            `json:"imageDigest,omitempty"`
+	Status        string            `json:"status"`
+	ExitCode      *int              `json:"exitCode,omitempty"`
+	HealthStatus  string            `json:"healthStatus,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+	ReasonCode    string            `json:"reasonCode,omitempty"`
+	PortBindings  []portBindingJSON `json:"portBindings,omitempty"`
+	StartedAt     *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt    *time.Time        `json:"finishedAt,omitempty"`
+}
+
+// portBindingJSON is the json representation of a container port binding
+type portBindingJSON struct {
+	ContainerPort uint16 `json:"containerPort"`
+	HostPort      uint16 `json:"hostPort"`
+	BindIP        string `json:"bindIp"`
+	Protocol      string `json:"protocol"`
+}
+
+// taskStateChangeJSON is the json representation of TaskStateChange. The task
+// the state change refers to is left out, as it's only used for recording the
+// sent status
+type taskStateChangeJSON struct {
+	TaskARN            string                     `json:"taskArn"`
+	Status             string                     `json:"status"`
+	Reason             string                     `json:"reason,omitempty"`
+	ReasonCode         string                     `json:"reasonCode,omitempty"`
+	Containers         []containerStateChangeJSON `json:"containers,omitempty"`
+	Attachment         *attachmentJSON            `json:"attachment,omitempty"`
+	PullStartedAt      *time.Time                 `json:"pullStartedAt,omitempty"`
+	PullStoppedAt      *time.Time                 `json:"pullStoppedAt,omitempty"`
+	ExecutionStoppedAt *time.Time                 `json:"executionStoppedAt,omitempty"`
+}
+
+// attachmentJSON is the json representation of the attachment of a task state
+// change
+type attachmentJSON struct {
+	AttachmentARN string `json:"attachmentArn"`
+	Status        string `json:"status"`
+}
+
+// attachmentStateChangeJSON is the json representation of
+// AttachmentStateChange
+type attachmentStateChangeJSON struct {
+	TaskARN       string `json:"taskArn"`
+	AttachmentARN string `json:"attachmentArn"`
+	Status        string `json:"status"`
+}
+
+// MarshalJSON marshals the container state change using stable field names
+func (c ContainerStateChange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newContainerStateChangeJSON(c))
+}
+
+// MarshalJSON marshals the task state change using stable field names
+func (change TaskStateChange) MarshalJSON() ([]byte, error) {
+	changeJSON := taskStateChangeJSON{
+		TaskARN:            change.TaskARN,
+		Status:             change.Status.String(),
+		Reason:             change.Reason,
+		PullStartedAt:      change.PullStartedAt,
+		PullStoppedAt:      change.PullStoppedAt,
+		ExecutionStoppedAt: change.ExecutionStoppedAt,
+	}
+	if change.ReasonCode != apireason.ReasonCodeNone {
+		changeJSON.ReasonCode = change.ReasonCode.String()
+	}
+	for _, containerChange := range change.Containers {
+		changeJSON.Containers = append(changeJSON.Containers, newContainerStateChangeJSON(containerChange))
+	}
+	if change.Attachment != nil {
+		changeJSON.Attachment = &attachmentJSON{
+			AttachmentARN: change.Attachment.AttachmentARN,
+			Status:        change.Attachment.Status.String(),
+		}
+	}
+	return json.Marshal(changeJSON)
+}
+
+// MarshalJSON marshals the attachment state change using stable field names
+func (change AttachmentStateChange) MarshalJSON() ([]byte, error) {
+	var changeJSON attachmentStateChangeJSON
+	if change.Attachment != nil {
+		changeJSON = attachmentStateChangeJSON{
+			TaskARN:       change.Attachment.TaskARN,
+			AttachmentARN: change.Attachment.AttachmentARN,
+			Status:        change.Attachment.Status.String(),
+		}
+	}
+	return json.Marshal(changeJSON)
+}
+
+func newContainerStateChangeJSON(c ContainerStateChange) containerStateChangeJSON {
+	changeJSON := containerStateChangeJSON{
+		TaskARN:       c.TaskArn,
+		ContainerName: c.ContainerName,
+		RuntimeID:     c.RuntimeID,
+		ImageDigest:   c.ImageDigest,
+		Status:        c.Status.String(),
+		ExitCode:      c.ExitCode,
+		Reason:        c.Reason,
+		PortBindings:  newPortBindingsJSON(c.PortBindings),
+		StartedAt:     c.StartedAt,
+		FinishedAt:    c.FinishedAt,
+	}
+	if c.HealthStatus != apicontainerstatus.ContainerHealthUnknown {
+		changeJSON.HealthStatus = c.HealthStatus.String()
+	}
+	if c.ReasonCode != apireason.ReasonCodeNone {
+		changeJSON.ReasonCode = c.ReasonCode.String()
+	}
+	return changeJSON
+}
+
+func newPortBindingsJSON(portBindings []apicontainer.PortBinding) []portBindingJSON {
+	var bindingsJSON []portBindingJSON
+	for _, binding := range portBindings {
+		bindingsJSON = append(bindingsJSON, portBindingJSON{
+			ContainerPort: binding.ContainerPort,
+			HostPort:      binding.HostPort,
+			BindIP:        binding.BindIP,
+			Protocol:      binding.Protocol.String(),
+		})
+	}
+	return bindingsJSON
+}
@@ -0,0 +1,172 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalTaskStateChange(t *testing.T) {
+	exitCode := 1
+	change := TaskStateChange{
+		TaskARN:    "t1",
+		Status:     apitaskstatus.TaskStopped,
+		Reason:     "Essential container in task exited",
+		ReasonCode: apireason.ReasonCodeEssentialContainerExited,
+		Task:       &apitask.Task{},
+		Containers: []ContainerStateChange{{
+			TaskArn:       "t1",
+			ContainerName: "c1",
+			Status:        apicontainerstatus.ContainerStopped,
+			ExitCode:      &exitCode,
+			ReasonCode:    apireason.ReasonCodeOutOfMemory,
+			PortBindings: []apicontainer.PortBinding{{
+				ContainerPort: 53,
+				HostPort:      8053,
+				BindIP:        "0.0.0.0",
+				Protocol:      apicontainer.TransportProtocolUDP,
+			}},
+			Container: &apicontainer.Container{},
+		}},
+	}
+
+	data, err := json.Marshal(change)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"taskArn": "t1",
+		"status": "STOPPED",
+		"reason": "Essential container in task exited",
+		"reasonCode": "EssentialContainerExited",
+		"containers": [{
+			"taskArn": "t1",
+			"containerName": "c1",
+			"status": "STOPPED",
+			"exitCode": 1,
+			"reasonCode": "OutOfMemory",
+			"portBindings": [{"containerPort": 53, "hostPort": 8053, "bindIp": "0.0.0.0", "protocol": "udp"}]
+		}]
+	}`, string(data))
+}
+
+func TestMarshalStateChangesRoundTrip(t *testing.T) {
+	exitCode := 137
+	pullStartedAt := time.Unix(1500000000, 0).UTC()
+	cases := []struct {
+		name   string
+		change TaskStateChange
+	}{
+		{
+			name: "task state change without exit code and attachment",
+			change: TaskStateChange{
+				TaskARN: "t1",
+				Status:  apitaskstatus.TaskRunning,
+				Containers: []ContainerStateChange{{
+					TaskArn:       "t1",
+					ContainerName: "c1",
+					Status:        apicontainerstatus.ContainerRunning,
+				}},
+			},
+		},
+		{
+			name: "task state change with exit code and timestamps",
+			change: TaskStateChange{
+				TaskARN:       "t1",
+				Status:        apitaskstatus.TaskStopped,
+				PullStartedAt: &pullStartedAt,
+				Containers: []ContainerStateChange{{
+					TaskArn:       "t1",
+					ContainerName: "c1",
+					Status:        apicontainerstatus.ContainerStopped,
+					ExitCode:      &exitCode,
+				}},
+			},
+		},
+		{
+			name: "attachment state change",
+			change: TaskStateChange{
+				TaskARN: "t1",
+				Attachment: &apieni.ENIAttachment{
+					AttachmentARN: "attachment",
+					Status:        apieni.ENIAttached,
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.change)
+			require.NoError(t, err)
+
+			var unmarshalled taskStateChangeJSON
+			require.NoError(t, json.Unmarshal(data, &unmarshalled))
+			assert.Equal(t, tc.change.TaskARN, unmarshalled.TaskARN)
+			assert.Equal(t, tc.change.Status.String(), unmarshalled.Status)
+			assert.Equal(t, tc.change.PullStartedAt, unmarshalled.PullStartedAt)
+			if tc.change.Attachment == nil {
+				assert.Nil(t, unmarshalled.Attachment)
+			} else if assert.NotNil(t, unmarshalled.Attachment) {
+				assert.Equal(t, tc.change.Attachment.AttachmentARN, unmarshalled.Attachment.AttachmentARN)
+				assert.Equal(t, tc.change.Attachment.Status.String(), unmarshalled.Attachment.Status)
+			}
+			require.Len(t, unmarshalled.Containers, len(tc.change.Containers))
+			for i, containerChange := range tc.change.Containers {
+				assert.Equal(t, containerChange.ContainerName, unmarshalled.Containers[i].ContainerName)
+				assert.Equal(t, containerChange.Status.String(), unmarshalled.Containers[i].Status)
+				assert.Equal(t, containerChange.ExitCode, unmarshalled.Containers[i].ExitCode)
+			}
+		})
+	}
+}
+
+func TestMarshalContainerStateChangeSkipsContainer(t *testing.T) {
+	change := ContainerStateChange{
+		TaskArn:       "t1",
+		ContainerName: "c1",
+		Status:        apicontainerstatus.ContainerRunning,
+		Container:     &apicontainer.Container{Name: "c1", Image: "image"},
+	}
+
+	data, err := json.Marshal(&change)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"taskArn": "t1", "containerName": "c1", "status": "RUNNING"}`, string(data))
+}
+
+func TestMarshalAttachmentStateChange(t *testing.T) {
+	change := AttachmentStateChange{
+		Attachment: &apieni.ENIAttachment{
+			TaskARN:       "t1",
+			AttachmentARN: "a1",
+			MACAddress:    "mac",
+			Status:        apieni.ENIAttached,
+		},
+	}
+
+	data, err := json.Marshal(&change)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"taskArn": "t1", "attachmentArn": "a1", "status": "ATTACHED"}`, string(data))
+}
@@ -17,18 +17,28 @@ package api
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShouldBeReported(t *testing.T) {
 	cases := []struct {
 		status          apitaskstatus.TaskStatus
 		containerChange []ContainerStateChange
+		attachment      *apieni.ENIAttachment
 		result          bool
 	}{
 		{ // Normal task state change to running
@@ -50,14 +60,24 @@ func TestShouldBeReported(t *testing.T) {
 			status: apitaskstatus.TaskCreated,
 			result: false,
 		},
+		{ // Attachment whose ack timeout hasn't expired
+			attachment: &apieni.ENIAttachment{ExpiresAt: time.Now().Add(time.Minute)},
+			result:     true,
+		},
+		{ // Attachment whose ack timeout has expired
+			attachment: &apieni.ENIAttachment{ExpiresAt: time.Now().Add(-time.Minute)},
+			result:     false,
+		},
 	}
 
 	for _, tc := range cases {
-		t.Run(fmt.Sprintf("task change status: %s, container change: %t", tc.status, len(tc.containerChange) > 0),
+		t.Run(fmt.Sprintf("task change status: %s, container change: %t, attachment: %t",
+			tc.status, len(tc.containerChange) > 0, tc.attachment != nil),
 			func(t *testing.T) {
 				taskChange := TaskStateChange{
 					Status:     tc.status,
 					Containers: tc.containerChange,
+					Attachment: tc.attachment,
 				}
 
 				assert.Equal(t, tc.result, taskChange.ShouldBeReported())
@@ -65,6 +85,19 @@ func TestShouldBeReported(t *testing.T) {
 	}
 }
 
+func TestAttachmentStateChangeShouldBeReported(t *testing.T) {
+	change := AttachmentStateChange{
+		Attachment: &apieni.ENIAttachment{ExpiresAt: time.Now().Add(time.Minute)},
+	}
+	assert.True(t, change.ShouldBeReported())
+	assert.Equal(t, statechange.AttachmentEvent, change.GetEventType())
+
+	change.Attachment.ExpiresAt = time.Now().Add(-time.Minute)
+	assert.False(t, change.ShouldBeReported())
+
+	assert.False(t, (&AttachmentStateChange{}).ShouldBeReported())
+}
+
 func TestSetTaskTimestamps(t *testing.T) {
 	t1 := time.Now()
 	t2 := t1.Add(time.Second)
@@ -83,3 +116,378 @@ func TestSetTaskTimestamps(t *testing.T) {
 	assert.Equal(t, t2.UTC().String(), change.PullStoppedAt.String())
 	assert.Equal(t, t3.UTC().String(), change.ExecutionStoppedAt.String())
 }
+
+func TestSetContainerTimestamps(t *testing.T) {
+	t1 := time.Now()
+	t2 := t1.Add(time.Second)
+
+	container := &apicontainer.Container{}
+	container.SetStartedAt(t1)
+	container.SetFinishedAt(t2)
+	change := &ContainerStateChange{
+		Container: container,
+	}
+
+	change.SetContainerTimestamps()
+	assert.Equal(t, t1.UTC().String(), change.StartedAt.String())
+	assert.Equal(t, t2.UTC().String(), change.FinishedAt.String())
+}
+
+func TestSetContainerTimestampsOmitsZeroTimestamps(t *testing.T) {
+	change := &ContainerStateChange{
+		Container: &apicontainer.Container{},
+	}
+
+	change.SetContainerTimestamps()
+	assert.Nil(t, change.StartedAt)
+	assert.Nil(t, change.FinishedAt)
+}
+
+func TestNewContainerStateChangeEventTimestamps(t *testing.T) {
+	startedAt := time.Now()
+	cont := &apicontainer.Container{
+		Name:              "c1",
+		KnownStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	cont.SetStartedAt(startedAt)
+	task := &apitask.Task{
+		Arn:        "t1",
+		Containers: []*apicontainer.Container{cont},
+	}
+
+	event, err := NewContainerStateChangeEvent(task, cont, "")
+	assert.NoError(t, err)
+	assert.Equal(t, startedAt.UTC().String(), event.StartedAt.String())
+	assert.Nil(t, event.FinishedAt)
+}
+
+func TestNewContainerStateChangeEventRuntimeID(t *testing.T) {
+	cases := []struct {
+		name      string
+		runtimeID string
+	}{
+		{
+			name:      "container created",
+			runtimeID: "runtime-id",
+		},
+		{
+			name:      "container never created",
+			runtimeID: "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cont := &apicontainer.Container{
+				Name:              "c1",
+				RuntimeID:         tc.runtimeID,
+				KnownStatusUnsafe: apicontainerstatus.ContainerStopped,
+			}
+			task := &apitask.Task{
+				Arn:        "t1",
+				Containers: []*apicontainer.Container{cont},
+			}
+
+			event, err := NewContainerStateChangeEvent(task, cont, "")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.runtimeID, event.RuntimeID)
+		})
+	}
+}
+
+func TestNewTaskStateChangeEventSequenceNumber(t *testing.T) {
+	task := &apitask.Task{
+		Arn:               "t1",
+		KnownStatusUnsafe: apitaskstatus.TaskRunning,
+	}
+
+	running, err := NewTaskStateChangeEvent(task, "")
+	require.NoError(t, err)
+	task.SetKnownStatus(apitaskstatus.TaskStopped)
+	stopped, err := NewTaskStateChangeEvent(task, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), running.SequenceNumber)
+	assert.Equal(t, uint64(2), stopped.SequenceNumber)
+	assert.Contains(t, stopped.String(), "SequenceNumber 2")
+}
+
+func TestNewContainerStateChangeEventImageDigest(t *testing.T) {
+	cont := &apicontainer.Container{
+		Name:              "c1",
+		ImageDigest:       "sha256:digest",
+		KnownStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	task := &apitask.Task{
+		Arn:        "t1",
+		Containers: []*apicontainer.Container{cont},
+	}
+
+	event, err := NewContainerStateChangeEvent(task, cont, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:digest", event.ImageDigest)
+	assert.Contains(t, event.String(), "ImageDigest sha256:digest")
+}
+
+func TestNewContainerStateChangeEventUsesLatestExit(t *testing.T) {
+	staleExitCode := 1
+	exitCode := 137
+	cont := &apicontainer.Container{
+		Name:                "c1",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerStopped,
+		KnownExitCodeUnsafe: &staleExitCode,
+		ExitHistoryUnsafe: []apicontainer.ContainerExit{
+			{ExitCode: &staleExitCode},
+			{ExitCode: &exitCode, OutOfMemory: true},
+		},
+	}
+	task := &apitask.Task{
+		Arn:        "t1",
+		Containers: []*apicontainer.Container{cont},
+	}
+
+	event, err := NewContainerStateChangeEvent(task, cont, "")
+	assert.NoError(t, err)
+	assert.Equal(t, &exitCode, event.ExitCode)
+}
+
+func TestTruncateReason(t *testing.T) {
+	cases := []struct {
+		name       string
+		reason     string
+		truncated  bool
+		expectHead string
+	}{
+		{
+			name:   "short reason",
+			reason: "Essential container in task exited",
+		},
+		{
+			name:   "reason at the limit",
+			reason: strings.Repeat("a", MaxReasonLength),
+		},
+		{
+			name:       "ascii reason over the limit",
+			reason:     strings.Repeat("a", MaxReasonLength+1),
+			truncated:  true,
+			expectHead: strings.Repeat("a", MaxReasonLength-len(reasonTruncationSuffix)),
+		},
+		{
+			// "é" is 2 bytes long, the cut falls in the middle of a rune
+			name:       "two byte runes over the limit",
+			reason:     "a" + strings.Repeat("é", MaxReasonLength),
+			truncated:  true,
+			expectHead: "a" + strings.Repeat("é", (MaxReasonLength-len(reasonTruncationSuffix)-1)/2),
+		},
+		{
+			// "世" is 3 bytes long, the cut falls in the middle of a rune
+			name:       "three byte runes over the limit",
+			reason:     "a" + strings.Repeat("世", MaxReasonLength),
+			truncated:  true,
+			expectHead: "a" + strings.Repeat("世", (MaxReasonLength-len(reasonTruncationSuffix)-1)/3),
+		},
+		{
+			// "😀" is 4 bytes long
+			name:       "four byte runes over the limit",
+			reason:     strings.Repeat("😀", MaxReasonLength),
+			truncated:  true,
+			expectHead: strings.Repeat("😀", (MaxReasonLength-len(reasonTruncationSuffix))/4),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			truncated := TruncateReason(tc.reason, "t1")
+			assert.True(t, utf8.ValidString(truncated))
+			assert.True(t, len(truncated) <= MaxReasonLength)
+			if !tc.truncated {
+				assert.Equal(t, tc.reason, truncated)
+				return
+			}
+			assert.Equal(t, tc.expectHead+reasonTruncationSuffix, truncated)
+		})
+	}
+}
+
+func TestNewStateChangeEventTruncatesReason(t *testing.T) {
+	reason := strings.Repeat("世", MaxReasonLength)
+	cont := &apicontainer.Container{
+		Name:              "c1",
+		KnownStatusUnsafe: apicontainerstatus.ContainerStopped,
+	}
+	task := &apitask.Task{
+		Arn:               "t1",
+		KnownStatusUnsafe: apitaskstatus.TaskStopped,
+		Containers:        []*apicontainer.Container{cont},
+	}
+
+	containerEvent, err := NewContainerStateChangeEvent(task, cont, reason)
+	assert.NoError(t, err)
+	assert.True(t, utf8.ValidString(containerEvent.Reason))
+	assert.True(t, len(containerEvent.Reason) <= MaxReasonLength)
+	assert.True(t, strings.HasSuffix(containerEvent.Reason, reasonTruncationSuffix))
+
+	taskEvent, err := NewTaskStateChangeEvent(task, reason)
+	assert.NoError(t, err)
+	assert.True(t, utf8.ValidString(taskEvent.Reason))
+	assert.True(t, len(taskEvent.Reason) <= MaxReasonLength)
+	assert.True(t, strings.HasSuffix(taskEvent.Reason, reasonTruncationSuffix))
+}
+
+func TestNewStateChangeEventReasonCode(t *testing.T) {
+	cont := &apicontainer.Container{
+		Name:              "c1",
+		KnownStatusUnsafe: apicontainerstatus.ContainerStopped,
+		ApplyingError: &apierrors.DefaultNamedError{
+			Err:  "Container killed due to memory usage",
+			Name: "OutOfMemoryError",
+		},
+	}
+	task := &apitask.Task{
+		Arn:               "t1",
+		KnownStatusUnsafe: apitaskstatus.TaskStopped,
+		Containers:        []*apicontainer.Container{cont},
+	}
+	task.SetTerminalReasonCode(apireason.ReasonCodeEssentialContainerExited)
+
+	containerEvent, err := NewContainerStateChangeEvent(task, cont, "")
+	assert.NoError(t, err)
+	assert.Equal(t, apireason.ReasonCodeOutOfMemory, containerEvent.ReasonCode)
+	assert.Contains(t, containerEvent.String(), "ReasonCode OutOfMemory")
+
+	taskEvent, err := NewTaskStateChangeEvent(task, "")
+	assert.NoError(t, err)
+	assert.Equal(t, apireason.ReasonCodeEssentialContainerExited, taskEvent.ReasonCode)
+	assert.Contains(t, taskEvent.String(), "ReasonCode EssentialContainerExited")
+}
+
+func TestNewTaskStateChangeEventReasonCodeOnlyWhenStopped(t *testing.T) {
+	task := &apitask.Task{
+		Arn:               "t1",
+		KnownStatusUnsafe: apitaskstatus.TaskRunning,
+	}
+	task.SetTerminalReasonCode(apireason.ReasonCodeUserInitiatedStop)
+
+	event, err := NewTaskStateChangeEvent(task, "")
+	assert.NoError(t, err)
+	assert.Equal(t, apireason.ReasonCodeNone, event.ReasonCode)
+	assert.NotContains(t, event.String(), "ReasonCode")
+}
+
+func TestNewTaskStateChangeEventPrefersStoppedReason(t *testing.T) {
+	task := &apitask.Task{
+		Arn:               "t1",
+		KnownStatusUnsafe: apitaskstatus.TaskStopped,
+	}
+	task.SetTerminalReasonCode(apireason.ReasonCodeUserInitiatedStop)
+	task.SetStoppedReason("Scaling activity initiated by deployment")
+
+	event, err := NewTaskStateChangeEvent(task, "Essential container in task exited")
+	assert.NoError(t, err)
+	assert.Equal(t, "Scaling activity initiated by deployment", event.Reason)
+	assert.Equal(t, apireason.ReasonCodeUserInitiatedStop, event.ReasonCode)
+}
+
+func TestNewTaskStateChangeEventKeepsContainerReason(t *testing.T) {
+	task := &apitask.Task{
+		Arn:               "t1",
+		KnownStatusUnsafe: apitaskstatus.TaskStopped,
+	}
+	task.SetTerminalReasonCode(apireason.ReasonCodeEssentialContainerExited)
+	task.SetStoppedReason("Scaling activity initiated by deployment")
+
+	event, err := NewTaskStateChangeEvent(task, "Essential container in task exited")
+	assert.NoError(t, err)
+	assert.Equal(t, "Essential container in task exited", event.Reason)
+}
+
+func TestNewContainerStateChangeEventHealthStatus(t *testing.T) {
+	cont := &apicontainer.Container{
+		Name:              "c1",
+		HealthCheckType:   apicontainer.DockerHealthCheckType,
+		KnownStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	cont.SetHealthStatus(apicontainer.HealthStatus{Status: apicontainerstatus.ContainerHealthy})
+	task := &apitask.Task{
+		Arn:        "t1",
+		Containers: []*apicontainer.Container{cont},
+	}
+
+	event, err := NewContainerStateChangeEvent(task, cont, "")
+	assert.NoError(t, err)
+	assert.Equal(t, apicontainerstatus.ContainerHealthy, event.HealthStatus)
+
+	// The health status isn't sent again once it has been sent
+	cont.SetSentHealthStatus(apicontainerstatus.ContainerHealthy)
+	event, err = NewContainerStateChangeEvent(task, cont, "")
+	assert.NoError(t, err)
+	assert.Equal(t, apicontainerstatus.ContainerHealthUnknown, event.HealthStatus)
+}
+
+func TestNewContainerHealthChangeEvent(t *testing.T) {
+	cases := []struct {
+		name         string
+		knownStatus  apicontainerstatus.ContainerStatus
+		sentStatus   apicontainerstatus.ContainerStatus
+		healthStatus apicontainerstatus.ContainerHealthStatus
+		sentHealth   apicontainerstatus.ContainerHealthStatus
+		expectError  bool
+	}{
+		{
+			name:         "health status transition of running container",
+			knownStatus:  apicontainerstatus.ContainerRunning,
+			sentStatus:   apicontainerstatus.ContainerRunning,
+			healthStatus: apicontainerstatus.ContainerUnhealthy,
+			sentHealth:   apicontainerstatus.ContainerHealthy,
+		},
+		{
+			name:         "health status already sent",
+			knownStatus:  apicontainerstatus.ContainerRunning,
+			sentStatus:   apicontainerstatus.ContainerRunning,
+			healthStatus: apicontainerstatus.ContainerHealthy,
+			sentHealth:   apicontainerstatus.ContainerHealthy,
+			expectError:  true,
+		},
+		{
+			name:         "container status not sent yet",
+			knownStatus:  apicontainerstatus.ContainerRunning,
+			sentStatus:   apicontainerstatus.ContainerStatusNone,
+			healthStatus: apicontainerstatus.ContainerHealthy,
+			expectError:  true,
+		},
+		{
+			name:         "container not running",
+			knownStatus:  apicontainerstatus.ContainerStopped,
+			sentStatus:   apicontainerstatus.ContainerStopped,
+			healthStatus: apicontainerstatus.ContainerUnhealthy,
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cont := &apicontainer.Container{
+				Name:              "c1",
+				HealthCheckType:   apicontainer.DockerHealthCheckType,
+				KnownStatusUnsafe: tc.knownStatus,
+				SentStatusUnsafe:  tc.sentStatus,
+			}
+			cont.SetHealthStatus(apicontainer.HealthStatus{Status: tc.healthStatus})
+			cont.SetSentHealthStatus(tc.sentHealth)
+			task := &apitask.Task{
+				Arn:        "t1",
+				Containers: []*apicontainer.Container{cont},
+			}
+
+			event, err := NewContainerHealthChangeEvent(task, cont)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, apicontainerstatus.ContainerRunning, event.Status)
+			assert.Equal(t, tc.healthStatus, event.HealthStatus)
+			assert.Equal(t, cont, event.Container)
+		})
+	}
+}
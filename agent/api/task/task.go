@@ -17,6 +17,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"path/filepath"
 	"reflect"
 	"strconv"
@@ -25,18 +26,22 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	apiappmesh "github.com/aws/amazon-ecs-agent/agent/api/appmesh"
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
 	dockerapi "github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
 	"github.com/aws/amazon-ecs-agent/agent/ecscni"
+	"github.com/aws/amazon-ecs-agent/agent/gpu"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/asmauth"
+	taskresourcenetwork "github.com/aws/amazon-ecs-agent/agent/taskresource/network"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/ssmsecret"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
 	resourcetype "github.com/aws/amazon-ecs-agent/agent/taskresource/types"
@@ -51,6 +56,77 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ipcNamespacedSystemControls are the kernel parameters of the ipc namespace,
+// besides the 'fs.mqueue.' ones
+var ipcNamespacedSystemControls = map[string]struct{}{
+	"kernel.msgmax":          {},
+	"kernel.msgmnb":          {},
+	"kernel.msgmni":          {},
+	"kernel.sem":             {},
+	"kernel.shmall":          {},
+	"kernel.shmmax":          {},
+	"kernel.shmmni":          {},
+	"kernel.shm_rmid_forced": {},
+}
+
+// supportedUlimits are the names of the resources docker can set ulimits for
+var supportedUlimits = map[string]struct{}{
+	"core":       {},
+	"cpu":        {},
+	"data":       {},
+	"fsize":      {},
+	"locks":      {},
+	"memlock":    {},
+	"msgqueue":   {},
+	"nice":       {},
+	"nofile":     {},
+	"nproc":      {},
+	"rss":        {},
+	"rtprio":     {},
+	"rttime":     {},
+	"sigpending": {},
+	"stack":      {},
+}
+
+// stopSignals are the names of the signals docker can send containers to stop
+// them, without their 'SIG' prefix
+var stopSignals = map[string]struct{}{
+	"ABRT":   {},
+	"ALRM":   {},
+	"BUS":    {},
+	"CHLD":   {},
+	"CONT":   {},
+	"FPE":    {},
+	"HUP":    {},
+	"ILL":    {},
+	"INT":    {},
+	"IO":     {},
+	"KILL":   {},
+	"PIPE":   {},
+	"PROF":   {},
+	"PWR":    {},
+	"QUIT":   {},
+	"SEGV":   {},
+	"STKFLT": {},
+	"STOP":   {},
+	"SYS":    {},
+	"TERM":   {},
+	"TRAP":   {},
+	"TSTP":   {},
+	"TTIN":   {},
+	"TTOU":   {},
+	"URG":    {},
+	"USR1":   {},
+	"USR2":   {},
+	"VTALRM": {},
+	"WINCH":  {},
+	"XCPU":   {},
+	"XFSZ":   {},
+}
+
+// maxSignalNumber is the highest signal number, including real-time signals
+const maxSignalNumber = 64
+
 const (
 	// NetworkPauseContainerName is the internal name for the pause container
 	NetworkPauseContainerName = "~internal~ecs~pause"
@@ -61,6 +137,10 @@ const (
 
 	emptyHostVolumeName = "~internal~ecs-emptyvolume-source"
 
+	// defaultDevicePermissions are the cgroup permissions granted for a host
+	// device when the container doesn't specify them
+	defaultDevicePermissions = "rwm"
+
 	// awsSDKCredentialsRelativeURIPathEnvironmentVariableName defines the name of the environment
 	// variable in containers' config, which will be used by the AWS SDK to fetch
 	// credentials.
@@ -85,6 +165,22 @@ const (
 	ipcModeTask     = "task"
 	ipcModeSharable = "shareable"
 	ipcModeNone     = "none"
+	networkModeHost = "host"
+	// networkModeBridge specifies the string used to define the `bridge` docker networking mode
+	networkModeBridge = "bridge"
+	// networkModeAWSVPC specifies the string used to define the `awsvpc`
+	// networking mode of the containers of tasks with an ENI
+	networkModeAWSVPC = "awsvpc"
+
+	// taskNetworkNamePrefix is the prefix of the names of the docker networks
+	// created for tasks, which end with the id of the task
+	taskNetworkNamePrefix = "ecs-task-"
+
+	// credentialsEndpointIP and instanceMetadataEndpointIP are the ips of the
+	// endpoints the egress traffic to is never redirected to the proxy of
+	// tasks with a proxy configuration
+	credentialsEndpointIP      = "169.254.170.2"
+	instanceMetadataEndpointIP = "169.254.169.254"
 )
 
 // TaskOverrides are the overrides applied to a task
@@ -153,6 +249,18 @@ type Task struct {
 	// is handled properly so that the state storage continues to work.
 	SentStatusUnsafe apitaskstatus.TaskStatus `json:"SentStatus"`
 
+	// StateChangeSequenceUnsafe is the sequence number of the latest task state
+	// change created for the task. Sequence numbers increase monotonically
+	StateChangeSequenceUnsafe uint64 `json:"StateChangeSequence,omitempty"`
+	// SubmittedStateChangeSequenceUnsafe is the sequence number of the latest
+	// task state change submitted to ECS. State changes with a lower sequence
+	// number are out of order and must not be submitted
+	SubmittedStateChangeSequenceUnsafe uint64 `json:"SubmittedStateChangeSequence,omitempty"`
+	// stopSubmissionInFlightUnsafe is set while the eventhandler is submitting
+	// the STOPPED state change of the task. The task must not be cleaned up
+	// until the submission completes
+	stopSubmissionInFlightUnsafe bool
+
 	StartSequenceNumber int64
 	StopSequenceNumber  int64
 
@@ -168,6 +276,15 @@ type Task struct {
 	// ENI is the elastic network interface specified by this task
 	ENI *apieni.ENI
 
+	// BlockInstanceMetadata overrides whether the instance metadata endpoint
+	// is blocked in the network namespace of the task, which is otherwise set
+	// by the ECS_AWSVPC_BLOCK_IMDS configuration of the instance
+	BlockInstanceMetadata *bool `json:"BlockInstanceMetadata,omitempty"`
+
+	// AppMesh is the proxy configuration of the task, whose traffic is
+	// redirected to its proxy container when it's set
+	AppMesh *apiappmesh.AppMesh `json:"AppMesh,omitempty"`
+
 	// MemoryCPULimitsEnabled to determine if task supports CPU, memory limits
 	MemoryCPULimitsEnabled bool `json:"MemoryCPULimitsEnabled,omitempty"`
 
@@ -179,6 +296,10 @@ type Task struct {
 	// stoppped.
 	terminalReason     string
 	terminalReasonOnce sync.Once
+	// TerminalReasonCodeUnsafe is the cause of the task moving to stopped.
+	// It's only set once per the task's lifecycle, by the first cause
+	// recorded. It's persisted so that the cause survives agent restarts
+	TerminalReasonCodeUnsafe apireason.ReasonCode `json:"TerminalReasonCode,omitempty"`
 
 	// PIDMode is used to determine how PID namespaces are organized between
 	// containers of the Task
@@ -188,6 +309,25 @@ type Task struct {
 	// containers of the Task
 	IPCMode string `json:"IpcMode,omitempty"`
 
+	// EphemeralStorageLimit is the limit, in MiB, on the disk space used by
+	// the writable layers of the task's containers
+	EphemeralStorageLimit int64 `json:"EphemeralStorageLimit,omitempty"`
+
+	// EgressBandwidthLimit is the limit, in Mbps, on the rate of the traffic
+	// sent through the task's ENI
+	EgressBandwidthLimit int64 `json:"EgressBandwidthLimit,omitempty"`
+
+	// CleanupWaitDurationSeconds is the number of seconds to wait after the
+	// task stopped before cleaning it up. The instance-wide task cleanup wait
+	// duration is used when it's not set
+	CleanupWaitDurationSeconds int64 `json:"CleanupWaitDurationSeconds,omitempty"`
+	// StoppedReasonUnsafe is the reason ACS gave for stopping the task, for
+	// example the reason passed to StopTask
+	StoppedReasonUnsafe string `json:"StoppedReason,omitempty"`
+	// ephemeralStorageUsageUnsafe is the disk space, in bytes, last measured
+	// as used by the writable layers of the task's containers
+	ephemeralStorageUsageUnsafe int64
+
 	// lock is for protecting all fields in the task struct
 	lock sync.RWMutex
 }
@@ -230,6 +370,9 @@ func (task *Task) PostUnmarshalTask(cfg *config.Config,
 	dockerClient dockerapi.DockerClient, ctx context.Context) error {
 	// TODO, add rudimentary plugin support and call any plugins that want to
 	// hook into this
+	if err := task.Validate(); err != nil {
+		return err
+	}
 	task.adjustForPlatform(cfg)
 	if task.MemoryCPULimitsEnabled {
 		err := task.initializeCgroupResourceSpec(cfg.CgroupPath, resourceFields)
@@ -255,6 +398,12 @@ func (task *Task) PostUnmarshalTask(cfg *config.Config,
 	if err != nil {
 		return apierrors.NewResourceInitError(task.Arn, err)
 	}
+	if cfg.TaskNetworksEnabled {
+		err = task.initializeTaskNetwork(dockerClient, ctx)
+		if err != nil {
+			return apierrors.NewResourceInitError(task.Arn, err)
+		}
+	}
 
 	task.initializeCredentialsEndpoint(credentialsManager)
 	task.initializeContainersV3MetadataEndpoint(utils.NewDynamicUUIDProvider())
@@ -265,6 +414,241 @@ func (task *Task) PostUnmarshalTask(cfg *config.Config,
 	return nil
 }
 
+// Validate validates the configuration of the task's containers. It's run
+// when a task is received, so that invalid tasks are failed before they're
+// added to the engine, and again when the task is added to the engine
+func (task *Task) Validate() error {
+	if err := task.validateContainerMemorySettings(); err != nil {
+		seelog.Errorf("Task [%s]: invalid container settings: %v", task.Arn, err)
+		return apierrors.NewInvalidTaskError(task.Arn, err)
+	}
+	if err := task.validateContainerUlimits(); err != nil {
+		seelog.Errorf("Task [%s]: invalid container ulimits: %v", task.Arn, err)
+		return apierrors.NewInvalidTaskError(task.Arn, err)
+	}
+	if err := task.validateIPCNamespaceSystemControls(); err != nil {
+		seelog.Errorf("Task [%s]: invalid container system controls: %v", task.Arn, err)
+		return apierrors.NewInvalidTaskError(task.Arn, err)
+	}
+	if err := task.validateMountPoints(); err != nil {
+		seelog.Errorf("Task [%s]: invalid container mount points: %v", task.Arn, err)
+		return apierrors.NewInvalidTaskError(task.Arn, err)
+	}
+	if err := task.validateMountPropagation(); err != nil {
+		seelog.Errorf("Task [%s]: invalid container mount points: %v", task.Arn, err)
+		return apierrors.NewInvalidTaskError(task.Arn, err)
+	}
+	if err := task.validateContainerNetworkModes(); err != nil {
+		seelog.Errorf("Task [%s]: invalid container network mode: %v", task.Arn, err)
+		return apierrors.NewInvalidTaskError(task.Arn, err)
+	}
+	if err := task.validateContainerDNSSettings(); err != nil {
+		seelog.Errorf("Task [%s]: invalid container dns settings: %v", task.Arn, err)
+		return apierrors.NewInvalidTaskError(task.Arn, err)
+	}
+	if err := task.validateContainerStopSignals(); err != nil {
+		seelog.Errorf("Task [%s]: invalid container stop signal: %v", task.Arn, err)
+		return apierrors.NewInvalidTaskError(task.Arn, err)
+	}
+	return nil
+}
+
+// validateContainerMemorySettings validates the tmpfs mounts, shared memory
+// size and memory swappiness of the task's containers. The tmpfs mounts of a
+// container can't use more memory than the container's memory limit
+func (task *Task) validateContainerMemorySettings() error {
+	for _, container := range task.Containers {
+		var tmpfsSize int64
+		for _, tmpfs := range container.Tmpfs {
+			if tmpfs.ContainerPath == "" {
+				return errors.Errorf("container %s: tmpfs mount requires a container path", container.Name)
+			}
+			if tmpfs.Size <= 0 {
+				return errors.Errorf("container %s: invalid size %d MiB for the tmpfs mount at %s",
+					container.Name, tmpfs.Size, tmpfs.ContainerPath)
+			}
+			tmpfsSize += tmpfs.Size
+		}
+		if container.Memory != 0 && tmpfsSize > int64(container.Memory) {
+			return errors.Errorf("container %s: tmpfs mounts size of %d MiB exceeds the container memory limit of %d MiB",
+				container.Name, tmpfsSize, container.Memory)
+		}
+		if container.ShmSize < 0 {
+			return errors.Errorf("container %s: invalid shared memory size %d MiB", container.Name, container.ShmSize)
+		}
+		if swappiness := container.MemorySwappiness; swappiness != nil && (*swappiness < 0 || *swappiness > 100) {
+			return errors.Errorf("container %s: invalid memory swappiness %d, expected a value between 0 and 100",
+				container.Name, *swappiness)
+		}
+	}
+	return nil
+}
+
+// validateContainerUlimits validates that the ulimits of the task's containers
+// name resources docker can limit and that their soft limits don't exceed
+// their hard limits
+func (task *Task) validateContainerUlimits() error {
+	for _, container := range task.Containers {
+		for _, ulimit := range container.Ulimits {
+			if _, ok := supportedUlimits[ulimit.Name]; !ok {
+				return errors.Errorf("container %s: unsupported ulimit %s", container.Name, ulimit.Name)
+			}
+			if ulimit.Soft > ulimit.Hard {
+				return errors.Errorf("container %s: soft limit %d of ulimit %s exceeds its hard limit %d",
+					container.Name, ulimit.Soft, ulimit.Name, ulimit.Hard)
+			}
+		}
+	}
+	return nil
+}
+
+// validateContainerStopSignals validates the stop signals of the task's
+// containers. Like docker, it accepts signal names with or without their 'SIG'
+// prefix, in any case, and signal numbers
+func (task *Task) validateContainerStopSignals() error {
+	for _, container := range task.Containers {
+		if container.StopSignal == "" || isValidStopSignal(container.StopSignal) {
+			continue
+		}
+		return errors.Errorf("container %s: unknown stop signal %s", container.Name, container.StopSignal)
+	}
+	return nil
+}
+
+// isValidStopSignal returns true if the signal is the name or the number of a
+// signal docker can send a container to stop it
+func isValidStopSignal(signal string) bool {
+	if number, err := strconv.Atoi(signal); err == nil {
+		return number > 0 && number <= maxSignalNumber
+	}
+	_, ok := stopSignals[strings.TrimPrefix(strings.ToUpper(signal), "SIG")]
+	return ok
+}
+
+// validateMountPoints validates that the mount points of the task's containers
+// name the volume they mount and the path they mount it at
+func (task *Task) validateMountPoints() error {
+	for _, container := range task.Containers {
+		for _, mountPoint := range container.MountPoints {
+			if mountPoint.SourceVolume == "" {
+				return errors.Errorf("container %s: mount point at %s requires a source volume",
+					container.Name, mountPoint.ContainerPath)
+			}
+			if mountPoint.ContainerPath == "" {
+				return errors.Errorf("container %s: mount point of volume %s requires a container path",
+					container.Name, mountPoint.SourceVolume)
+			}
+		}
+	}
+	return nil
+}
+
+// validateContainerNetworkModes validates that the network modes set in the
+// host configs of the task's containers are supported on the platform. The
+// network mode of another container can be shared as well
+func (task *Task) validateContainerNetworkModes() error {
+	for _, container := range task.Containers {
+		if container.DockerConfig.HostConfig == nil {
+			continue
+		}
+		hostConfig := &docker.HostConfig{}
+		if err := json.Unmarshal([]byte(*container.DockerConfig.HostConfig), hostConfig); err != nil {
+			return errors.Wrapf(err, "container %s: unable to decode the host config", container.Name)
+		}
+		networkMode := hostConfig.NetworkMode
+		if networkMode == "" || strings.HasPrefix(networkMode, dockerMappingContainerPrefix) {
+			continue
+		}
+		if _, ok := supportedNetworkModes[networkMode]; !ok {
+			return errors.Errorf("container %s: unknown network mode %s", container.Name, networkMode)
+		}
+	}
+	return nil
+}
+
+// validateContainerDNSSettings validates the DNS servers, search domains and
+// extra hosts of the task's containers, which would otherwise only be rejected
+// once the containers are created, or when the network namespace of awsvpc
+// tasks is set up
+func (task *Task) validateContainerDNSSettings() error {
+	for _, container := range task.Containers {
+		hostConfig, err := decodeContainerHostConfig(container)
+		if err != nil {
+			return err
+		}
+		if hostConfig == nil {
+			continue
+		}
+		for _, server := range hostConfig.DNS {
+			if net.ParseIP(server) == nil {
+				return errors.Errorf("container %s: invalid dns server %q, expected an ip address",
+					container.Name, server)
+			}
+		}
+		for _, domain := range hostConfig.DNSSearch {
+			if domain == "" || strings.ContainsAny(domain, " \t\n") {
+				return errors.Errorf("container %s: invalid dns search domain %q", container.Name, domain)
+			}
+		}
+		for _, host := range hostConfig.ExtraHosts {
+			// The hostname is separated from the ip address by the first colon,
+			// as ipv6 addresses contain colons
+			parts := strings.SplitN(host, ":", 2)
+			if len(parts) != 2 || parts[0] == "" || net.ParseIP(parts[1]) == nil {
+				return errors.Errorf("container %s: invalid extra host %q, expected hostname:ip",
+					container.Name, host)
+			}
+		}
+	}
+	return nil
+}
+
+// validateMountPropagation validates the propagation modes of the mount points
+// of the task's containers. Shared propagation is only supported for the
+// volumes of host paths, as mounts can't be propagated back to docker volumes
+func (task *Task) validateMountPropagation() error {
+	for _, container := range task.Containers {
+		for _, mountPoint := range container.MountPoints {
+			switch mountPoint.Propagation {
+			case "", apicontainer.MountPropagationPrivate, apicontainer.MountPropagationRSlave:
+			case apicontainer.MountPropagationRShared:
+				volume, ok := task.HostVolumeByName(mountPoint.SourceVolume)
+				if !ok {
+					continue
+				}
+				if _, ok := volume.(*taskresourcevolume.FSHostVolume); !ok {
+					return errors.Errorf("container %s: %s propagation of volume %s requires a host path",
+						container.Name, mountPoint.Propagation, mountPoint.SourceVolume)
+				}
+			default:
+				return errors.Errorf("container %s: unsupported propagation %s of volume %s",
+					container.Name, mountPoint.Propagation, mountPoint.SourceVolume)
+			}
+		}
+	}
+	return nil
+}
+
+// validateIPCNamespaceSystemControls validates that the task's containers don't
+// set kernel parameters of the ipc namespace when the namespace is shared with
+// the host or between the containers of the task, as the parameters would apply
+// to every process sharing the namespace
+func (task *Task) validateIPCNamespaceSystemControls() error {
+	ipcMode := task.getIPCMode()
+	if ipcMode != ipcModeHost && ipcMode != ipcModeTask {
+		return nil
+	}
+	for _, container := range task.Containers {
+		for name := range container.SystemControls {
+			if isIPCNamespacedSystemControl(name) {
+				return errors.Errorf("container %s: system control %s can't be set with the task's %s ipc mode",
+					container.Name, name, ipcMode)
+			}
+		}
+	}
+	return nil
+}
+
 func (task *Task) initializeDockerLocalVolumes(dockerClient dockerapi.DockerClient, ctx context.Context) error {
 	var requiredLocalVolumes []string
 	for _, container := range task.Containers {
@@ -347,13 +731,20 @@ func (task *Task) addTaskScopedVolumes(ctx context.Context, dockerClient dockera
 	vol *TaskVolume) error {
 
 	volumeConfig := vol.Volume.(*taskresourcevolume.DockerVolumeConfig)
+	// Task scoped volumes are labeled with the arn of the task, so that the
+	// volumes left behind by tasks that are gone can be found
+	labels := make(map[string]string)
+	for key, value := range volumeConfig.Labels {
+		labels[key] = value
+	}
+	labels[taskresource.TaskARNLabel] = task.Arn
 	volumeResource, err := taskresourcevolume.NewVolumeResource(
 		ctx,
 		vol.Name,
 		task.volumeName(vol.Name),
 		volumeConfig.Scope, volumeConfig.Autoprovision,
 		volumeConfig.Driver, volumeConfig.DriverOpts,
-		volumeConfig.Labels, dockerClient)
+		labels, dockerClient)
 	if err != nil {
 		return err
 	}
@@ -595,11 +986,101 @@ func (task *Task) BuildCNIConfig() (*ecscni.Config, error) {
 	// If there is ipv6 assigned to eni then set it
 	if len(eni.IPV6Addresses) > 0 {
 		cfg.ENIIPV6Address = eni.IPV6Addresses[0].Address
+		cfg.SubnetGatewayIPV6Address = eni.GetSubnetGatewayIPV6Address()
+	}
+
+	if appMesh := task.GetAppMesh(); appMesh != nil {
+		cfg.AppMeshCNIEnabled = true
+		cfg.IgnoredUID = appMesh.IgnoredUID
+		cfg.IgnoredGID = appMesh.IgnoredGID
+		cfg.ProxyIngressPort = appMesh.ProxyIngressPort
+		cfg.ProxyEgressPort = appMesh.ProxyEgressPort
+		cfg.AppPorts = appMesh.AppPorts
+		cfg.EgressIgnoredPorts = appMesh.EgressIgnoredPorts
+		// The credentials and metadata endpoints are reached directly rather
+		// than through the proxy
+		cfg.EgressIgnoredIPs = append(append([]string{}, appMesh.EgressIgnoredIPs...),
+			credentialsEndpointIP, instanceMetadataEndpointIP)
+	}
+	if task.EgressBandwidthLimit > 0 {
+		cfg.EgressBandwidthLimit = uint64(task.EgressBandwidthLimit)
 	}
 
 	return cfg, nil
 }
 
+// initializeTaskNetwork adds the docker network resource of the task if some of
+// its containers use the bridge network mode. Those containers are connected
+// to the network once they're created, so that they can reach each other by
+// their container names without links
+func (task *Task) initializeTaskNetwork(dockerClient dockerapi.DockerClient, ctx context.Context) error {
+	if _, ok := task.getTaskNetworkResource(); ok {
+		return nil
+	}
+	var bridgeContainers []*apicontainer.Container
+	for _, container := range task.Containers {
+		if task.usesBridgeNetworkMode(container) {
+			bridgeContainers = append(bridgeContainers, container)
+		}
+	}
+	if len(bridgeContainers) == 0 {
+		return nil
+	}
+
+	taskID, err := task.GetID()
+	if err != nil {
+		return err
+	}
+	networkResource := taskresourcenetwork.NewNetworkResource(ctx, task.Arn,
+		taskNetworkNamePrefix+strings.Replace(taskID, arnResourceDelimiter, "-", -1), dockerClient)
+	task.AddResource(resourcetype.DockerNetworkKey, networkResource)
+	for _, container := range bridgeContainers {
+		container.BuildResourceDependency(networkResource.GetName(),
+			resourcestatus.ResourceStatus(taskresourcenetwork.NetworkCreated),
+			apicontainerstatus.ContainerCreated)
+	}
+	return nil
+}
+
+// usesBridgeNetworkMode returns true if the container uses the default bridge
+// network mode of docker
+func (task *Task) usesBridgeNetworkMode(container *apicontainer.Container) bool {
+	if container.IsInternal() || task.isNetworkModeVPC() {
+		return false
+	}
+	if container.DockerConfig.HostConfig == nil {
+		return true
+	}
+	hostConfig := &docker.HostConfig{}
+	if err := json.Unmarshal([]byte(*container.DockerConfig.HostConfig), hostConfig); err != nil {
+		// The container fails to be created with an invalid host config
+		return false
+	}
+	return hostConfig.NetworkMode == "" || hostConfig.NetworkMode == networkModeBridge
+}
+
+// getTaskNetworkResource returns the docker network resource of the task
+func (task *Task) getTaskNetworkResource() (*taskresourcenetwork.NetworkResource, bool) {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	resources, ok := task.ResourcesMapUnsafe[resourcetype.DockerNetworkKey]
+	if !ok || len(resources) == 0 {
+		return nil, false
+	}
+	networkResource, ok := resources[0].(*taskresourcenetwork.NetworkResource)
+	return networkResource, ok
+}
+
+// GetTaskNetworkForContainer returns the docker network of the task the
+// container is connected to once it's created, if any
+func (task *Task) GetTaskNetworkForContainer(container *apicontainer.Container) (*taskresourcenetwork.NetworkResource, bool) {
+	if !task.usesBridgeNetworkMode(container) {
+		return nil, false
+	}
+	return task.getTaskNetworkResource()
+}
+
 // isNetworkModeVPC checks if the task is configured to use task-networking feature
 func (task *Task) isNetworkModeVPC() bool {
 	if task.GetTaskENI() == nil {
@@ -774,6 +1255,11 @@ func (task *Task) dockerConfig(container *apicontainer.Container, apiVersion doc
 	for envKey, envVal := range container.Environment {
 		dockerEnv = append(dockerEnv, envKey+"="+envVal)
 	}
+	if gpuIDs := container.GetGPUIDs(); len(gpuIDs) > 0 {
+		// The NVIDIA container runtime only exposes the GPUs listed in the
+		// environment variable to the container
+		dockerEnv = append(dockerEnv, gpu.VisibleDevicesEnvironmentVariable+"="+strings.Join(gpuIDs, ","))
+	}
 
 	// Convert MB to B
 	dockerMem := int64(container.Memory * 1024 * 1024)
@@ -792,6 +1278,7 @@ func (task *Task) dockerConfig(container *apicontainer.Container, apiVersion doc
 		Entrypoint:   entryPoint,
 		ExposedPorts: task.dockerExposedPorts(container),
 		Env:          dockerEnv,
+		StopSignal:   container.StopSignal,
 	}
 
 	err := task.SetConfigHostconfigBasedOnVersion(container, config, nil, apiVersion)
@@ -805,6 +1292,15 @@ func (task *Task) dockerConfig(container *apicontainer.Container, apiVersion doc
 			return nil, &apierrors.DockerClientConfigError{"Unable decode given docker config: " + err.Error()}
 		}
 	}
+	if container.HealthCheck != nil {
+		// The health check of the task definition overrides the ones of the
+		// image and of the docker config
+		healthcheck, err := dockerHealthcheck(container, apiVersion)
+		if err != nil {
+			return nil, &apierrors.DockerClientConfigError{err.Error()}
+		}
+		config.Healthcheck = healthcheck
+	}
 	if container.HealthCheckType == apicontainer.DockerHealthCheckType && config.Healthcheck == nil {
 		return nil, &apierrors.DockerClientConfigError{
 			"docker health check is nil while container health check type is DOCKER"}
@@ -915,12 +1411,39 @@ func (task *Task) dockerHostConfig(container *apicontainer.Container, dockerCont
 		return nil, &apierrors.HostConfigError{err.Error()}
 	}
 
+	err = verifyLinuxCapabilitiesSupported(container, apiVersion)
+	if err != nil {
+		return nil, &apierrors.HostConfigError{err.Error()}
+	}
+
+	err = verifyInitProcessSupported(container, apiVersion)
+	if err != nil {
+		return nil, &apierrors.HostConfigError{err.Error()}
+	}
+
 	// Populate hostConfig
 	hostConfig := &docker.HostConfig{
-		Links:        dockerLinkArr,
-		Binds:        binds,
-		PortBindings: dockerPortMap,
-		VolumesFrom:  volumesFrom,
+		Links:          dockerLinkArr,
+		Binds:          binds,
+		PortBindings:   dockerPortMap,
+		VolumesFrom:    volumesFrom,
+		Tmpfs:          dockerTmpfs(container),
+		CapAdd:         container.CapAdd,
+		CapDrop:        container.CapDrop,
+		Devices:        dockerDevices(container),
+		Ulimits:        dockerUlimits(container),
+		Sysctls:        container.SystemControls,
+		Init:           container.InitProcessEnabled,
+		ReadonlyRootfs: container.ReadonlyRootFilesystem,
+	}
+	if container.ShmSize > 0 {
+		// Convert MiB to B
+		hostConfig.ShmSize = container.ShmSize * 1024 * 1024
+	}
+	if container.MemorySwappiness != nil {
+		// The docker client omits a swappiness of 0, which makes docker use
+		// its default swappiness instead
+		hostConfig.MemorySwappiness = *container.MemorySwappiness
 	}
 
 	err = task.SetConfigHostconfigBasedOnVersion(container, nil, hostConfig, apiVersion)
@@ -946,15 +1469,19 @@ func (task *Task) dockerHostConfig(container *apicontainer.Container, dockerCont
 		hostConfig.NetworkMode = networkMode
 		// Override 'awsvpc' parameters if needed
 		if container.Type == apicontainer.ContainerCNIPause {
-			// apply ExtraHosts to HostConfig for pause container
-			if hosts := task.generateENIExtraHosts(); hosts != nil {
-				hostConfig.ExtraHosts = append(hostConfig.ExtraHosts, hosts...)
-			}
-
-			// Override the DNS settings for the pause container if ENI has custom
-			// DNS settings
+			// Override the DNS settings and extra hosts of the pause container,
+			// whose resolv.conf and hosts files are shared by the containers of
+			// the task
 			return task.overrideDNS(hostConfig), nil
 		}
+		if task.GetTaskENI() != nil {
+			// Docker doesn't accept the DNS settings of containers joining the
+			// network namespace of another container, they're applied to the
+			// pause container instead
+			hostConfig.DNS = nil
+			hostConfig.DNSSearch = nil
+			hostConfig.ExtraHosts = nil
+		}
 	}
 
 	ok, pidMode := task.shouldOverridePIDMode(container, dockerContainerMap)
@@ -967,9 +1494,191 @@ func (task *Task) dockerHostConfig(container *apicontainer.Container, dockerCont
 		hostConfig.IpcMode = ipcMode
 	}
 
+	err = verifySystemControls(container, hostConfig)
+	if err != nil {
+		return nil, &apierrors.HostConfigError{err.Error()}
+	}
+
 	return hostConfig, nil
 }
 
+// verifySystemControls returns an error if the container sets a kernel
+// parameter that isn't namespaced in the namespaces the container runs in.
+// Docker only sets the parameters of the ipc and network namespaces, and
+// refuses to set them when the container shares the namespace with the host
+func verifySystemControls(container *apicontainer.Container, hostConfig *docker.HostConfig) error {
+	for name := range container.SystemControls {
+		switch {
+		case isIPCNamespacedSystemControl(name):
+			if hostConfig.IpcMode == ipcModeHost {
+				return errors.Errorf("container %s: system control %s can't be set when sharing the host's ipc namespace",
+					container.Name, name)
+			}
+		case strings.HasPrefix(name, "net."):
+			if hostConfig.NetworkMode == networkModeHost {
+				return errors.Errorf("container %s: system control %s can't be set when sharing the host's network namespace",
+					container.Name, name)
+			}
+		default:
+			return errors.Errorf("container %s: system control %s isn't namespaced", container.Name, name)
+		}
+	}
+	return nil
+}
+
+// isIPCNamespacedSystemControl returns true if the kernel parameter belongs to
+// the ipc namespace
+func isIPCNamespacedSystemControl(name string) bool {
+	if strings.HasPrefix(name, "fs.mqueue.") {
+		return true
+	}
+	_, ok := ipcNamespacedSystemControls[name]
+	return ok
+}
+
+// verifyLinuxCapabilitiesSupported returns an error if the container adds or
+// drops linux capabilities and the docker API version doesn't support setting
+// them when creating the container. Docker accepts the host config, which
+// carries the capabilities, when creating a container from API version 1.15
+// and ignores it otherwise
+func verifyLinuxCapabilitiesSupported(container *apicontainer.Container, apiVersion dockerclient.DockerVersion) error {
+	if len(container.CapAdd) == 0 && len(container.CapDrop) == 0 {
+		return nil
+	}
+	dockerAPIVersion, err := docker.NewAPIVersion(string(apiVersion))
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse docker api version %s", apiVersion)
+	}
+	if dockerAPIVersion.LessThan(docker.APIVersion([]int{1, 15})) {
+		return errors.Errorf("container %s adds or drops linux capabilities, which requires docker api version 1.15 or greater, docker api version in use: %s",
+			container.Name, apiVersion)
+	}
+	return nil
+}
+
+// verifyInitProcessSupported returns an error if the container runs an init
+// process and the docker API version doesn't support it. Docker added the init
+// option in API version 1.25 and ignores it in earlier versions, which would
+// leave the container without the init process silently
+func verifyInitProcessSupported(container *apicontainer.Container, apiVersion dockerclient.DockerVersion) error {
+	if !container.InitProcessEnabled {
+		return nil
+	}
+	dockerAPIVersion, err := docker.NewAPIVersion(string(apiVersion))
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse docker api version %s", apiVersion)
+	}
+	if dockerAPIVersion.LessThan(docker.APIVersion([]int{1, 25})) {
+		return errors.Errorf("container %s runs an init process, which requires docker api version 1.25 or greater, docker api version in use: %s",
+			container.Name, apiVersion)
+	}
+	return nil
+}
+
+// dockerUlimits returns the resource limits of the container
+func dockerUlimits(container *apicontainer.Container) []docker.ULimit {
+	var ulimits []docker.ULimit
+	for _, ulimit := range container.Ulimits {
+		ulimits = append(ulimits, docker.ULimit{
+			Name: ulimit.Name,
+			Soft: ulimit.Soft,
+			Hard: ulimit.Hard,
+		})
+	}
+	return ulimits
+}
+
+// dockerDevices returns the host devices exposed to the container
+func dockerDevices(container *apicontainer.Container) []docker.Device {
+	var devices []docker.Device
+	for _, device := range container.Devices {
+		containerPath := device.ContainerPath
+		if containerPath == "" {
+			containerPath = device.HostPath
+		}
+		permissions := device.Permissions
+		if permissions == "" {
+			permissions = defaultDevicePermissions
+		}
+		devices = append(devices, docker.Device{
+			PathOnHost:        device.HostPath,
+			PathInContainer:   containerPath,
+			CgroupPermissions: permissions,
+		})
+	}
+	return append(devices, dockerGPUDevices(container)...)
+}
+
+// dockerGPUDevices returns the device files of the GPUs assigned to the
+// container and of the driver managing them
+func dockerGPUDevices(container *apicontainer.Container) []docker.Device {
+	gpuIDs := container.GetGPUIDs()
+	if len(gpuIDs) == 0 {
+		return nil
+	}
+	var paths []string
+	for _, gpuID := range gpuIDs {
+		paths = append(paths, gpu.DevicePath(gpuID))
+	}
+	var devices []docker.Device
+	for _, path := range append(paths, gpu.ControlDevicePaths()...) {
+		devices = append(devices, docker.Device{
+			PathOnHost:        path,
+			PathInContainer:   path,
+			CgroupPermissions: defaultDevicePermissions,
+		})
+	}
+	return devices
+}
+
+// dockerHealthcheck translates the health check of the container to the docker
+// health check configuration. Docker added the start period in API version
+// 1.29 and ignores it in earlier versions, which would count the failures of
+// the health check while the container starts up
+func dockerHealthcheck(container *apicontainer.Container, apiVersion dockerclient.DockerVersion) (*docker.HealthConfig, error) {
+	healthCheck := container.HealthCheck
+	if len(healthCheck.Command) == 0 {
+		return nil, errors.Errorf("health check of container %s has no command", container.Name)
+	}
+	switch healthCheck.Command[0] {
+	case "CMD", "CMD-SHELL", "NONE":
+	default:
+		return nil, errors.Errorf("health check command of container %s must start with CMD, CMD-SHELL or NONE, got %s",
+			container.Name, healthCheck.Command[0])
+	}
+	if healthCheck.StartPeriod != 0 {
+		dockerAPIVersion, err := docker.NewAPIVersion(string(apiVersion))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse docker api version %s", apiVersion)
+		}
+		if dockerAPIVersion.LessThan(docker.APIVersion([]int{1, 29})) {
+			return nil, errors.Errorf("health check of container %s has a start period, which requires docker api version 1.29 or greater, docker api version in use: %s",
+				container.Name, apiVersion)
+		}
+	}
+	return &docker.HealthConfig{
+		Test:        healthCheck.Command,
+		Interval:    time.Duration(healthCheck.Interval) * time.Second,
+		Timeout:     time.Duration(healthCheck.Timeout) * time.Second,
+		StartPeriod: time.Duration(healthCheck.StartPeriod) * time.Second,
+		Retries:     healthCheck.Retries,
+	}, nil
+}
+
+// dockerTmpfs returns the tmpfs mounts of the container, mapping the container
+// path of each mount to its mount options
+func dockerTmpfs(container *apicontainer.Container) map[string]string {
+	if len(container.Tmpfs) == 0 {
+		return nil
+	}
+	tmpfs := make(map[string]string, len(container.Tmpfs))
+	for _, mount := range container.Tmpfs {
+		options := append([]string{fmt.Sprintf("size=%dm", mount.Size)}, mount.MountOptions...)
+		tmpfs[mount.ContainerPath] = strings.Join(options, ",")
+	}
+	return tmpfs
+}
+
 // shouldOverrideNetworkMode returns true if the network mode of the container needs
 // to be overridden. It also returns the override string in this case. It returns
 // false otherwise
@@ -1014,24 +1723,98 @@ func (task *Task) shouldOverrideNetworkMode(container *apicontainer.Container, d
 	return true, dockerMappingContainerPrefix + pauseContainer.DockerID
 }
 
-// overrideDNS overrides a container's host config if the following conditions are
-// true:
-// 1. Task has an ENI associated with it
-// 2. ENI has custom DNS IPs and search list associated with it
+// overrideDNS overrides a container's host config with the DNS configuration
+// of the task if it has an ENI associated with it.
 // This should only be done for the pause container as other containers inherit
-// /etc/resolv.conf of this container (they share the network namespace)
+// /etc/resolv.conf and /etc/hosts of this container (they share the network
+// namespace)
 func (task *Task) overrideDNS(hostConfig *docker.HostConfig) *docker.HostConfig {
-	eni := task.GetTaskENI()
-	if eni == nil {
+	dnsConfig := task.GetAWSVPCDNSConfig()
+	if dnsConfig == nil {
 		return hostConfig
 	}
 
-	hostConfig.DNS = eni.DomainNameServers
-	hostConfig.DNSSearch = eni.DomainNameSearchList
+	hostConfig.DNS = dnsConfig.DNSServers
+	hostConfig.DNSSearch = dnsConfig.DNSSearchDomains
+	hostConfig.ExtraHosts = append(hostConfig.ExtraHosts, dnsConfig.ExtraHosts...)
 
 	return hostConfig
 }
 
+// AWSVPCDNSConfig is the DNS configuration of the network namespace shared by
+// the containers of a task with an ENI
+type AWSVPCDNSConfig struct {
+	// DNSServers are the DNS servers of the containers of the task, or the
+	// ones of the ENI if none of the containers has DNS servers
+	DNSServers []string
+	// DNSSearchDomains are the search domains of the containers of the task,
+	// or the ones of the ENI if none of the containers has search domains
+	DNSSearchDomains []string
+	// ExtraHosts are the "hostname:ip" entries of the hosts file, for the
+	// hostname of the ENI followed by the extra hosts of the containers
+	ExtraHosts []string
+}
+
+// GetAWSVPCDNSConfig returns the DNS configuration of the network namespace of
+// the task, which merges the DNS settings of its containers. It's nil if the
+// task has no ENI
+func (task *Task) GetAWSVPCDNSConfig() *AWSVPCDNSConfig {
+	eni := task.GetTaskENI()
+	if eni == nil {
+		return nil
+	}
+
+	dnsConfig := &AWSVPCDNSConfig{
+		ExtraHosts: task.generateENIExtraHosts(),
+	}
+	for _, container := range task.Containers {
+		hostConfig, err := decodeContainerHostConfig(container)
+		if err != nil || hostConfig == nil {
+			continue
+		}
+		dnsConfig.DNSServers = appendUnique(dnsConfig.DNSServers, hostConfig.DNS...)
+		dnsConfig.DNSSearchDomains = appendUnique(dnsConfig.DNSSearchDomains, hostConfig.DNSSearch...)
+		dnsConfig.ExtraHosts = appendUnique(dnsConfig.ExtraHosts, hostConfig.ExtraHosts...)
+	}
+	if len(dnsConfig.DNSServers) == 0 {
+		dnsConfig.DNSServers = eni.DomainNameServers
+	}
+	if len(dnsConfig.DNSSearchDomains) == 0 {
+		dnsConfig.DNSSearchDomains = eni.DomainNameSearchList
+	}
+	return dnsConfig
+}
+
+// decodeContainerHostConfig decodes the host config the container was given
+// by the backend. It's nil if the container wasn't given one
+func decodeContainerHostConfig(container *apicontainer.Container) (*docker.HostConfig, error) {
+	if container.DockerConfig.HostConfig == nil {
+		return nil, nil
+	}
+	hostConfig := &docker.HostConfig{}
+	if err := json.Unmarshal([]byte(*container.DockerConfig.HostConfig), hostConfig); err != nil {
+		return nil, errors.Wrapf(err, "container %s: unable to decode the host config", container.Name)
+	}
+	return hostConfig, nil
+}
+
+// appendUnique appends the values missing from the slice to it
+func appendUnique(slice []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range slice {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			slice = append(slice, value)
+		}
+	}
+	return slice
+}
+
 // applyENIHostname adds the hostname provided by the ENI message to the
 // container's docker config. At the time of implmentation, we are only using it
 // to configure the pause container for awsvpc tasks
@@ -1190,14 +1973,22 @@ func (task *Task) dockerLinks(container *apicontainer.Container, dockerContainer
 
 func (task *Task) dockerPortMap(container *apicontainer.Container) map[docker.Port][]docker.PortBinding {
 	dockerPortMap := make(map[docker.Port][]docker.PortBinding)
+	// The host ports allocated by the agent are used for the mappings without
+	// a host port, docker picks them otherwise
+	allocatedHostPorts := container.GetAllocatedHostPorts()
 
 	for _, portBinding := range container.Ports {
 		dockerPort := docker.Port(strconv.Itoa(int(portBinding.ContainerPort)) + "/" + portBinding.Protocol.String())
+		hostPort := portBinding.HostPort
+		if hostPort == 0 && len(allocatedHostPorts) > 0 {
+			hostPort = allocatedHostPorts[0]
+			allocatedHostPorts = allocatedHostPorts[1:]
+		}
 		currentMappings, existing := dockerPortMap[dockerPort]
 		if existing {
-			dockerPortMap[dockerPort] = append(currentMappings, docker.PortBinding{HostPort: strconv.Itoa(int(portBinding.HostPort))})
+			dockerPortMap[dockerPort] = append(currentMappings, docker.PortBinding{HostPort: strconv.Itoa(int(hostPort))})
 		} else {
-			dockerPortMap[dockerPort] = []docker.PortBinding{{HostPort: strconv.Itoa(int(portBinding.HostPort))}}
+			dockerPortMap[dockerPort] = []docker.PortBinding{{HostPort: strconv.Itoa(int(hostPort))}}
 		}
 	}
 	return dockerPortMap
@@ -1241,9 +2032,22 @@ func (task *Task) dockerHostBinds(container *apicontainer.Container) ([]string,
 				container.Name, mountPoint.SourceVolume, hv.Source(), mountPoint.ContainerPath)
 		}
 
-		bind := hv.Source() + ":" + mountPoint.ContainerPath
+		var options []string
 		if mountPoint.ReadOnly {
-			bind += ":ro"
+			options = append(options, "ro")
+		}
+		if mountPoint.Propagation != "" {
+			if mountPoint.Propagation == apicontainer.MountPropagationRShared {
+				if err := verifyMountPoint(hv.Source()); err != nil {
+					return []string{}, errors.Wrapf(err, "container %s: %s propagation of volume %s",
+						container.Name, mountPoint.Propagation, mountPoint.SourceVolume)
+				}
+			}
+			options = append(options, mountPoint.Propagation)
+		}
+		bind := hv.Source() + ":" + mountPoint.ContainerPath
+		if len(options) != 0 {
+			bind += ":" + strings.Join(options, ",")
 		}
 		binds[i] = bind
 	}
@@ -1281,6 +2085,10 @@ func (task *Task) updateTaskDesiredStatusUnsafe() {
 		if cont.Essential && (cont.KnownTerminal() || cont.DesiredTerminal()) {
 			seelog.Debugf("Updating task desired status to stopped because of container: [%s]; task: [%s]",
 				cont.Name, task.stringUnsafe())
+			if task.DesiredStatusUnsafe < apitaskstatus.TaskStopped && cont.KnownTerminal() {
+				task.setTerminalReasonCodeUnsafe(apireason.ReasonCodeEssentialContainerExited)
+				task.recordExecutionStoppedAtUnsafe(cont)
+			}
 			task.DesiredStatusUnsafe = apitaskstatus.TaskStopped
 		}
 	}
@@ -1417,6 +2225,33 @@ func (task *Task) SetSentStatus(status apitaskstatus.TaskStatus) {
 	task.SentStatusUnsafe = status
 }
 
+// StartStopSubmission records that the STOPPED state change of the task is
+// being submitted. The submission remains in flight while it's retried
+func (task *Task) StartStopSubmission() {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	task.stopSubmissionInFlightUnsafe = true
+}
+
+// CompleteStopSubmission records that the STOPPED state change of the task
+// was either sent or dropped
+func (task *Task) CompleteStopSubmission() {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	task.stopSubmissionInFlightUnsafe = false
+}
+
+// IsStopSubmissionInFlight returns true if the STOPPED state change of the
+// task is being submitted
+func (task *Task) IsStopSubmissionInFlight() bool {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	return task.stopSubmissionInFlightUnsafe
+}
+
 // SetTaskENI sets the eni information of the task
 func (task *Task) SetTaskENI(eni *apieni.ENI) {
 	task.lock.Lock()
@@ -1433,6 +2268,35 @@ func (task *Task) GetTaskENI() *apieni.ENI {
 	return task.ENI
 }
 
+// ShouldBlockInstanceMetadata returns true if the instance metadata endpoint
+// is blocked in the network namespace of the task. The task's setting takes
+// precedence over the given setting of the instance
+func (task *Task) ShouldBlockInstanceMetadata(instanceDefault bool) bool {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	if task.BlockInstanceMetadata != nil {
+		return *task.BlockInstanceMetadata
+	}
+	return instanceDefault
+}
+
+// SetAppMesh sets the proxy configuration of the task
+func (task *Task) SetAppMesh(appMesh *apiappmesh.AppMesh) {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	task.AppMesh = appMesh
+}
+
+// GetAppMesh returns the proxy configuration of the task
+func (task *Task) GetAppMesh() *apiappmesh.AppMesh {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	return task.AppMesh
+}
+
 // GetStopSequenceNumber returns the stop sequence number of a task
 func (task *Task) GetStopSequenceNumber() int64 {
 	task.lock.RLock()
@@ -1492,6 +2356,10 @@ func (task *Task) SetExecutionStoppedAt(timestamp time.Time) bool {
 	task.lock.Lock()
 	defer task.lock.Unlock()
 
+	return task.setExecutionStoppedAtUnsafe(timestamp)
+}
+
+func (task *Task) setExecutionStoppedAtUnsafe(timestamp time.Time) bool {
 	if task.ExecutionStoppedAtUnsafe.IsZero() {
 		task.ExecutionStoppedAtUnsafe = timestamp
 		return true
@@ -1557,7 +2425,10 @@ func (task *Task) GetID() (string, error) {
 }
 
 // RecordExecutionStoppedAt checks if this is an essential container stopped
-// and set the task executionStoppedAt timestamps
+// after the task was already moving to stopped and set the task
+// executionStoppedAt timestamps. When the essential container stopping is what
+// moves the task to stopped, the timestamp is recorded by UpdateDesiredStatus
+// as the desired status of the task changes
 func (task *Task) RecordExecutionStoppedAt(container *apicontainer.Container) {
 	if !container.Essential {
 		return
@@ -1565,9 +2436,24 @@ func (task *Task) RecordExecutionStoppedAt(container *apicontainer.Container) {
 	if container.GetKnownStatus() != apicontainerstatus.ContainerStopped {
 		return
 	}
-	// If the essential container is stopped, set the ExecutionStoppedAt timestamp
+
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	if task.DesiredStatusUnsafe != apitaskstatus.TaskStopped {
+		// The task desired status will be moved to stopped because of this
+		// container, which records the timestamp
+		return
+	}
+	task.recordExecutionStoppedAtUnsafe(container)
+}
+
+// recordExecutionStoppedAtUnsafe sets the executionStoppedAt timestamp of the
+// task to now, unless it was already recorded for an earlier stopped essential
+// container
+func (task *Task) recordExecutionStoppedAtUnsafe(container *apicontainer.Container) {
 	now := time.Now()
-	ok := task.SetExecutionStoppedAt(now)
+	ok := task.setExecutionStoppedAtUnsafe(now)
 	if !ok {
 		// ExecutionStoppedAt was already recorded. Nothing to left to do here
 		return
@@ -1621,6 +2507,97 @@ func (task *Task) GetTerminalReason() string {
 	return task.terminalReason
 }
 
+// SetTerminalReasonCode sets the cause of the task moving to stopped. It can
+// only be set once per the task's lifecycle; later updates are ignored
+func (task *Task) SetTerminalReasonCode(code apireason.ReasonCode) {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	task.setTerminalReasonCodeUnsafe(code)
+}
+
+func (task *Task) setTerminalReasonCodeUnsafe(code apireason.ReasonCode) {
+	if task.TerminalReasonCodeUnsafe != apireason.ReasonCodeNone {
+		return
+	}
+	seelog.Infof("Task [%s]: setting terminal reason code [%s]", task.Arn, code.String())
+	task.TerminalReasonCodeUnsafe = code
+}
+
+// GetTerminalReasonCode retrieves the cause of the task moving to stopped
+func (task *Task) GetTerminalReasonCode() apireason.ReasonCode {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	return task.TerminalReasonCodeUnsafe
+}
+
+// SetEphemeralStorageUsage records the disk space, in bytes, used by the
+// writable layers of the task's containers
+func (task *Task) SetEphemeralStorageUsage(usage int64) {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	task.ephemeralStorageUsageUnsafe = usage
+}
+
+// GetEphemeralStorageUsage returns the disk space, in bytes, last measured as
+// used by the writable layers of the task's containers
+func (task *Task) GetEphemeralStorageUsage() int64 {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	return task.ephemeralStorageUsageUnsafe
+}
+
+// SetStoppedReason records the reason ACS gave for stopping the task
+func (task *Task) SetStoppedReason(reason string) {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	task.StoppedReasonUnsafe = reason
+}
+
+// GetStoppedReason returns the reason ACS gave for stopping the task. It's
+// empty if ACS didn't stop the task or didn't give a reason
+func (task *Task) GetStoppedReason() string {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	return task.StoppedReasonUnsafe
+}
+
+// NextStateChangeSequence returns the sequence number of a new task state
+// change for the task
+func (task *Task) NextStateChangeSequence() uint64 {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	task.StateChangeSequenceUnsafe++
+	return task.StateChangeSequenceUnsafe
+}
+
+// SetSubmittedStateChangeSequence records the sequence number of a task state
+// change submitted to ECS. It's ignored if a later state change was already
+// submitted
+func (task *Task) SetSubmittedStateChangeSequence(sequence uint64) {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	if sequence > task.SubmittedStateChangeSequenceUnsafe {
+		task.SubmittedStateChangeSequenceUnsafe = sequence
+	}
+}
+
+// GetSubmittedStateChangeSequence returns the sequence number of the latest
+// task state change submitted to ECS
+func (task *Task) GetSubmittedStateChangeSequence() uint64 {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	return task.SubmittedStateChangeSequenceUnsafe
+}
+
 // PopulateASMAuthData sets docker auth credentials for a container
 func (task *Task) PopulateASMAuthData(container *apicontainer.Container) error {
 	secretID := container.RegistryAuthentication.ASMAuthData.CredentialsParameter
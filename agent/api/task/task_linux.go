@@ -16,7 +16,10 @@
 package task
 
 import (
+	"bufio"
+	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
@@ -46,9 +49,25 @@ const (
 	bytesPerMegabyte  = 1024 * 1024
 )
 
+// supportedNetworkModes are the docker network modes containers can be
+// configured with, in addition to sharing the network mode of another container
+var supportedNetworkModes = map[string]struct{}{
+	networkModeBridge: {},
+	networkModeHost:   {},
+	networkModeNone:   {},
+	networkModeAWSVPC: {},
+}
+
 // PlatformFields consists of fields specific to Linux for a task
 type PlatformFields struct{}
 
+// mountInfoPath is the file listing the mounts of the agent's mount namespace
+var mountInfoPath = "/proc/self/mountinfo"
+
+// mountInfoPathUnescaper reverts the octal escaping of the characters of mount
+// paths that would otherwise break the fields of mountinfo
+var mountInfoPathUnescaper = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
 func (task *Task) adjustForPlatform(cfg *config.Config) {
 	task.lock.Lock()
 	defer task.lock.Unlock()
@@ -221,3 +240,33 @@ func (task *Task) dockerCPUShares(containerCPU uint) int64 {
 	}
 	return int64(containerCPU)
 }
+
+// verifyMountPoint returns an error if the host path isn't a mount point, as
+// docker can only share the propagation of the mounts made under a mount point
+func verifyMountPoint(path string) error {
+	resolvedPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to resolve host path %s", path)
+	}
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to list mounts")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// The fifth field of each line is the mount point of the mount
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		if mountInfoPathUnescaper.Replace(fields[4]) == resolvedPath {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrapf(err, "unable to list mounts")
+	}
+	return errors.Errorf("host path %s isn't a mount point", path)
+}
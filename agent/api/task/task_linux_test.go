@@ -17,6 +17,10 @@ package task
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,6 +31,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/cgroup/control/mock_control"
+	taskresourcevolume "github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper/mocks"
 	"github.com/golang/mock/gomock"
 
@@ -347,6 +352,22 @@ func TestDockerHostConfigRawConfigMerging(t *testing.T) {
 	assertSetStructFieldsEqual(t, expected, *hostConfig)
 }
 
+func TestDockerHostConfigMemorySwappiness(t *testing.T) {
+	swappiness := int64(10)
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name:             "c1",
+				MemorySwappiness: &swappiness,
+			},
+		},
+	}
+
+	hostConfig, configErr := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, configErr)
+	assert.Equal(t, int64(10), hostConfig.MemorySwappiness)
+}
+
 // TestSetConfigHostconfigBasedOnAPIVersion tests the docker hostconfig was correctly
 // set based on the docker client version
 func TestSetConfigHostconfigBasedOnAPIVersion(t *testing.T) {
@@ -473,3 +494,80 @@ func TestPostUnmarshalWithCPULimitsFail(t *testing.T) {
 	assert.Equal(t, 0, len(task.GetResources()))
 	assert.Equal(t, 0, len(task.Containers[0].TransitionDependenciesMap))
 }
+
+func TestDockerHostConfigMountPropagation(t *testing.T) {
+	hostPath, err := ioutil.TempDir("", "mount-propagation")
+	require.NoError(t, err)
+	defer os.RemoveAll(hostPath)
+
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name: "c1",
+				MountPoints: []apicontainer.MountPoint{
+					{
+						SourceVolume:  "root",
+						ContainerPath: "/host",
+						ReadOnly:      true,
+						Propagation:   apicontainer.MountPropagationRShared,
+					},
+					{
+						SourceVolume:  "data",
+						ContainerPath: "/data",
+						Propagation:   apicontainer.MountPropagationRSlave,
+					},
+				},
+			},
+			{
+				Name: "c2",
+				MountPoints: []apicontainer.MountPoint{
+					{
+						SourceVolume:  "data",
+						ContainerPath: "/data",
+						Propagation:   apicontainer.MountPropagationRShared,
+					},
+				},
+			},
+		},
+		Volumes: []TaskVolume{
+			{
+				Name:   "root",
+				Type:   HostVolumeType,
+				Volume: &taskresourcevolume.FSHostVolume{FSSourcePath: "/"},
+			},
+			{
+				Name:   "data",
+				Type:   HostVolumeType,
+				Volume: &taskresourcevolume.FSHostVolume{FSSourcePath: hostPath},
+			},
+		},
+	}
+
+	config, configErr := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	require.Nil(t, configErr)
+	assert.Equal(t, []string{"/:/host:ro,rshared", hostPath + ":/data:rslave"}, config.Binds)
+
+	// Shared propagation requires the host path to be a mount point
+	_, configErr = testTask.DockerHostConfig(testTask.Containers[1], dockerMap(testTask), defaultDockerClientAPIVersion)
+	require.NotNil(t, configErr)
+	assert.Contains(t, configErr.Error(), "isn't a mount point")
+}
+
+func TestVerifyMountPointEscapedPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mount info")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	// Spaces in mount points are escaped in mountinfo
+	mountInfo := filepath.Join(dir, "mountinfo")
+	escapedDir := strings.Replace(resolvedDir, " ", `\040`, -1)
+	require.NoError(t, ioutil.WriteFile(mountInfo,
+		[]byte("36 35 98:0 / "+escapedDir+" rw,noatime master:1 - ext3 /dev/root rw\n"), 0644))
+	defer func(path string) { mountInfoPath = path }(mountInfoPath)
+	mountInfoPath = mountInfo
+
+	assert.NoError(t, verifyMountPoint(dir))
+	assert.Error(t, verifyMountPoint(mountInfo))
+}
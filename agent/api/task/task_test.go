@@ -24,9 +24,12 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	apiappmesh "github.com/aws/amazon-ecs-agent/agent/api/appmesh"
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/asm"
 	mock_factory "github.com/aws/amazon-ecs-agent/agent/asm/factory/mocks"
@@ -40,6 +43,7 @@ import (
 	mock_ssm_factory "github.com/aws/amazon-ecs-agent/agent/ssm/factory/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/asmauth"
+	taskresourcenetwork "github.com/aws/amazon-ecs-agent/agent/taskresource/network"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
 	taskresourcevolume "github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
@@ -177,25 +181,817 @@ func TestDockerHostConfigPortBinding(t *testing.T) {
 	assert.Equal(t, "20", bindings[0].HostPort, "Wrong hostport")
 }
 
+func TestDockerHostConfigAllocatedHostPorts(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name: "c1",
+				Ports: []apicontainer.PortBinding{
+					{10, 0, "", apicontainer.TransportProtocolTCP},
+					{20, 20, "", apicontainer.TransportProtocolTCP},
+					{30, 0, "", apicontainer.TransportProtocolUDP},
+				},
+				AllocatedHostPortsUnsafe: []uint16{40000, 40001},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []docker.PortBinding{{HostPort: "40000"}}, config.PortBindings["10/tcp"])
+	assert.Equal(t, []docker.PortBinding{{HostPort: "20"}}, config.PortBindings["20/tcp"])
+	assert.Equal(t, []docker.PortBinding{{HostPort: "40001"}}, config.PortBindings["30/udp"])
+}
+
 func TestDockerHostConfigVolumesFrom(t *testing.T) {
 	testTask := &Task{
 		Containers: []*apicontainer.Container{
 			{
-				Name: "c1",
+				Name: "c1",
+			},
+			{
+				Name:        "c2",
+				VolumesFrom: []apicontainer.VolumeFrom{{SourceContainer: "c1"}},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[1], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+
+	if !reflect.DeepEqual(config.VolumesFrom, []string{"dockername-c1"}) {
+		t.Error("Expected volumesFrom to be resolved, was: ", config.VolumesFrom)
+	}
+}
+
+func TestDockerHostConfigTmpfsAndShmSize(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name: "c1",
+				Tmpfs: []apicontainer.TmpfsMount{
+					{
+						ContainerPath: "/scratch",
+						Size:          64,
+						MountOptions:  []string{"rw", "noexec"},
+					},
+					{
+						ContainerPath: "/tmp",
+						Size:          16,
+					},
+				},
+				ShmSize: 256,
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"/scratch": "size=64m,rw,noexec",
+		"/tmp":     "size=16m",
+	}, config.Tmpfs)
+	assert.Equal(t, int64(256*1024*1024), config.ShmSize)
+}
+
+func TestDockerHostConfigLinuxCapabilities(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name:    "c1",
+				CapAdd:  []string{"NET_BIND_SERVICE", "NET_ADMIN"},
+				CapDrop: []string{"ALL"},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"NET_BIND_SERVICE", "NET_ADMIN"}, config.CapAdd)
+	assert.Equal(t, []string{"ALL"}, config.CapDrop)
+}
+
+func TestDockerHostConfigDevices(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name: "c1",
+				Devices: []apicontainer.Device{
+					{
+						HostPath:      "/dev/xvdf",
+						ContainerPath: "/dev/data",
+						Permissions:   "r",
+					},
+					{
+						HostPath: "/dev/fuse",
+					},
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []docker.Device{
+		{
+			PathOnHost:        "/dev/xvdf",
+			PathInContainer:   "/dev/data",
+			CgroupPermissions: "r",
+		},
+		{
+			PathOnHost:        "/dev/fuse",
+			PathInContainer:   "/dev/fuse",
+			CgroupPermissions: "rwm",
+		},
+	}, config.Devices)
+}
+
+func TestDockerHostConfigUlimits(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name: "c1",
+				Ulimits: []apicontainer.Ulimit{
+					{Name: "nofile", Soft: 1024, Hard: 4096},
+					{Name: "core", Soft: 0, Hard: 0},
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []docker.ULimit{
+		{Name: "nofile", Soft: 1024, Hard: 4096},
+		{Name: "core", Soft: 0, Hard: 0},
+	}, config.Ulimits)
+}
+
+func TestDockerHostConfigSystemControls(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name: "c1",
+				SystemControls: map[string]string{
+					"net.core.somaxconn":           "1024",
+					"net.ipv4.ip_local_port_range": "1024 65000",
+					"kernel.shmmax":                "68719476736",
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"net.core.somaxconn":           "1024",
+		"net.ipv4.ip_local_port_range": "1024 65000",
+		"kernel.shmmax":                "68719476736",
+	}, config.Sysctls)
+}
+
+func TestDockerHostConfigInvalidSystemControls(t *testing.T) {
+	testCases := []struct {
+		name           string
+		ipcMode        string
+		hostConfig     string
+		systemControls map[string]string
+	}{
+		{
+			name:           "non namespaced system control",
+			systemControls: map[string]string{"kernel.pid_max": "65536"},
+		},
+		{
+			name:           "network system control with host network mode",
+			hostConfig:     `{"NetworkMode":"host"}`,
+			systemControls: map[string]string{"net.core.somaxconn": "1024"},
+		},
+		{
+			name:           "ipc system control with host ipc mode",
+			ipcMode:        ipcModeHost,
+			systemControls: map[string]string{"fs.mqueue.msg_max": "64"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			container := &apicontainer.Container{
+				Name:           "c1",
+				SystemControls: tc.systemControls,
+			}
+			if tc.hostConfig != "" {
+				container.DockerConfig.HostConfig = &tc.hostConfig
+			}
+			testTask := &Task{
+				Arn:        "arn:aws:ecs:us-west-2:1234567890:task/test",
+				IPCMode:    tc.ipcMode,
+				Containers: []*apicontainer.Container{container},
+			}
+
+			_, err := testTask.DockerHostConfig(container, dockerMap(testTask), defaultDockerClientAPIVersion)
+			require.NotNil(t, err)
+			assert.Equal(t, "HostConfigError", err.ErrorName())
+			assert.Contains(t, err.Error(), "container c1")
+		})
+	}
+}
+
+func TestValidateContainerUlimits(t *testing.T) {
+	testCases := []struct {
+		name    string
+		ulimits []apicontainer.Ulimit
+		valid   bool
+	}{
+		{
+			name:  "no ulimits",
+			valid: true,
+		},
+		{
+			name:    "soft limit equal to the hard limit",
+			ulimits: []apicontainer.Ulimit{{Name: "nproc", Soft: 512, Hard: 512}},
+			valid:   true,
+		},
+		{
+			name:    "soft limit exceeding the hard limit",
+			ulimits: []apicontainer.Ulimit{{Name: "nofile", Soft: 4096, Hard: 1024}},
+			valid:   false,
+		},
+		{
+			name:    "unsupported ulimit",
+			ulimits: []apicontainer.Ulimit{{Name: "files", Soft: 1024, Hard: 1024}},
+			valid:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &Task{Containers: []*apicontainer.Container{{Name: "c1", Ulimits: tc.ulimits}}}
+			err := task.validateContainerUlimits()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateMountPoints(t *testing.T) {
+	testCases := []struct {
+		name       string
+		mountPoint apicontainer.MountPoint
+		valid      bool
+	}{
+		{
+			name:       "valid mount point",
+			mountPoint: apicontainer.MountPoint{SourceVolume: "volume", ContainerPath: "/data"},
+			valid:      true,
+		},
+		{
+			name:       "no container path",
+			mountPoint: apicontainer.MountPoint{SourceVolume: "volume"},
+			valid:      false,
+		},
+		{
+			name:       "no source volume",
+			mountPoint: apicontainer.MountPoint{ContainerPath: "/data"},
+			valid:      false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &Task{Containers: []*apicontainer.Container{{
+				Name:        "c1",
+				MountPoints: []apicontainer.MountPoint{tc.mountPoint},
+			}}}
+			err := task.validateMountPoints()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateContainerNetworkModes(t *testing.T) {
+	testCases := []struct {
+		name       string
+		hostConfig *string
+		valid      bool
+	}{
+		{
+			name:  "no host config",
+			valid: true,
+		},
+		{
+			name:       "no network mode",
+			hostConfig: aws.String(`{}`),
+			valid:      true,
+		},
+		{
+			name:       "supported network mode",
+			hostConfig: aws.String(`{"NetworkMode":"none"}`),
+			valid:      true,
+		},
+		{
+			name:       "network mode of another container",
+			hostConfig: aws.String(`{"NetworkMode":"container:c2"}`),
+			valid:      true,
+		},
+		{
+			name:       "unknown network mode",
+			hostConfig: aws.String(`{"NetworkMode":"unknown"}`),
+			valid:      false,
+		},
+		{
+			name:       "invalid host config",
+			hostConfig: aws.String(`{`),
+			valid:      false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &Task{Containers: []*apicontainer.Container{{
+				Name:         "c1",
+				DockerConfig: apicontainer.DockerConfig{HostConfig: tc.hostConfig},
+			}}}
+			err := task.validateContainerNetworkModes()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateContainerDNSSettings(t *testing.T) {
+	testCases := []struct {
+		name       string
+		hostConfig *string
+		valid      bool
+	}{
+		{
+			name:  "no host config",
+			valid: true,
+		},
+		{
+			name:       "valid settings",
+			hostConfig: aws.String(`{"Dns":["10.0.0.2","fd00::2"],"DnsSearch":["example.com"],"ExtraHosts":["db:10.0.0.10","db6:fd00::10"]}`),
+			valid:      true,
+		},
+		{
+			name:       "invalid dns server",
+			hostConfig: aws.String(`{"Dns":["dns.example.com"]}`),
+			valid:      false,
+		},
+		{
+			name:       "empty search domain",
+			hostConfig: aws.String(`{"DnsSearch":[""]}`),
+			valid:      false,
+		},
+		{
+			name:       "search domain with whitespace",
+			hostConfig: aws.String(`{"DnsSearch":["example .com"]}`),
+			valid:      false,
+		},
+		{
+			name:       "extra host without ip",
+			hostConfig: aws.String(`{"ExtraHosts":["db"]}`),
+			valid:      false,
+		},
+		{
+			name:       "extra host with invalid ip",
+			hostConfig: aws.String(`{"ExtraHosts":["db:10.0.0.300"]}`),
+			valid:      false,
+		},
+		{
+			name:       "extra host without hostname",
+			hostConfig: aws.String(`{"ExtraHosts":[":10.0.0.10"]}`),
+			valid:      false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &Task{Containers: []*apicontainer.Container{{
+				Name:         "c1",
+				DockerConfig: apicontainer.DockerConfig{HostConfig: tc.hostConfig},
+			}}}
+			err := task.validateContainerDNSSettings()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestGetAWSVPCDNSConfig(t *testing.T) {
+	task := &Task{
+		ENI: &apieni.ENI{
+			IPV4Addresses:        []*apieni.ENIIPV4Address{{Primary: true, Address: "10.0.1.1"}},
+			PrivateDNSName:       "eni.ip.region.compute.internal",
+			DomainNameServers:    []string{"169.254.169.253"},
+			DomainNameSearchList: []string{"us-west-2.compute.internal"},
+		},
+		Containers: []*apicontainer.Container{
+			{
+				Name:         "c1",
+				DockerConfig: apicontainer.DockerConfig{HostConfig: aws.String(`{"Dns":["10.0.0.2"],"ExtraHosts":["db:10.0.0.10"]}`)},
+			},
+			{
+				Name:         "c2",
+				DockerConfig: apicontainer.DockerConfig{HostConfig: aws.String(`{"Dns":["10.0.0.2","10.0.0.3"],"ExtraHosts":["cache:10.0.0.11"]}`)},
+			},
+		},
+	}
+
+	dnsConfig := task.GetAWSVPCDNSConfig()
+	require.NotNil(t, dnsConfig)
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, dnsConfig.DNSServers)
+	// The search domains of the ENI are used as the containers have none
+	assert.Equal(t, []string{"us-west-2.compute.internal"}, dnsConfig.DNSSearchDomains)
+	assert.Equal(t, []string{"eni.ip.region.compute.internal:10.0.1.1", "db:10.0.0.10", "cache:10.0.0.11"},
+		dnsConfig.ExtraHosts)
+}
+
+func TestGetAWSVPCDNSConfigNoENI(t *testing.T) {
+	task := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name:         "c1",
+				DockerConfig: apicontainer.DockerConfig{HostConfig: aws.String(`{"Dns":["10.0.0.2"]}`)},
+			},
+		},
+	}
+
+	assert.Nil(t, task.GetAWSVPCDNSConfig())
+}
+
+func TestPostUnmarshalTaskWithInvalidUlimit(t *testing.T) {
+	task := &Task{
+		Arn: "arn:aws:ecs:us-west-2:1234567890:task/test",
+		Containers: []*apicontainer.Container{
+			{Name: "c1"},
+			{
+				Name:    "c2",
+				Ulimits: []apicontainer.Ulimit{{Name: "nofile", Soft: 4096, Hard: 1024}},
+			},
+		},
+	}
+	cfg := config.Config{}
+	err := task.PostUnmarshalTask(&cfg, nil, nil, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, "InvalidTaskError", err.(apierrors.NamedError).ErrorName())
+	assert.Contains(t, err.Error(), "container c2")
+}
+
+func TestDockerConfigAndHostConfigGPUs(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name:     "c1",
+				GPUCount: 2,
+			},
+		},
+	}
+	testTask.Containers[0].SetGPUIDs([]string{"0", "3"})
+
+	config, configErr := testTask.DockerConfig(testTask.Containers[0], defaultDockerClientAPIVersion)
+	require.Nil(t, configErr)
+	assert.Contains(t, config.Env, "NVIDIA_VISIBLE_DEVICES=0,3")
+
+	hostConfig, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	require.Nil(t, err)
+	var devicePaths []string
+	for _, device := range hostConfig.Devices {
+		assert.Equal(t, device.PathOnHost, device.PathInContainer)
+		devicePaths = append(devicePaths, device.PathOnHost)
+	}
+	assert.Equal(t, []string{"/dev/nvidia0", "/dev/nvidia3", "/dev/nvidiactl", "/dev/nvidia-uvm"}, devicePaths)
+}
+
+func TestDockerHostConfigLinuxCapabilitiesUnsupportedAPIVersion(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name:    "c1",
+				CapDrop: []string{"ALL"},
+			},
+			{
+				Name: "c2",
+			},
+		},
+	}
+
+	_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), dockerclient.DockerVersion("1.14"))
+	assert.NotNil(t, err)
+	assert.Equal(t, "HostConfigError", err.ErrorName())
+
+	_, err = testTask.DockerHostConfig(testTask.Containers[1], dockerMap(testTask), dockerclient.DockerVersion("1.14"))
+	assert.Nil(t, err)
+}
+
+func TestDockerHostConfigInitProcess(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name:               "c1",
+				InitProcessEnabled: true,
+			},
+			{
+				Name: "c2",
+			},
+		},
+	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), dockerclient.Version_1_25)
+	assert.Nil(t, err)
+	assert.True(t, config.Init)
+
+	config, err = testTask.DockerHostConfig(testTask.Containers[1], dockerMap(testTask), dockerclient.Version_1_25)
+	assert.Nil(t, err)
+	assert.False(t, config.Init)
+}
+
+func TestDockerHostConfigInitProcessUnsupportedAPIVersion(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name:               "c1",
+				InitProcessEnabled: true,
+			},
+		},
+	}
+
+	_, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), dockerclient.Version_1_24)
+	require.NotNil(t, err)
+	assert.Equal(t, "HostConfigError", err.ErrorName())
+	assert.Contains(t, err.Error(), "requires docker api version 1.25")
+}
+
+func TestValidateContainerMemorySettings(t *testing.T) {
+	testCases := []struct {
+		name      string
+		container *apicontainer.Container
+		valid     bool
+	}{
+		{
+			name:      "no settings",
+			container: &apicontainer.Container{Name: "c1", Memory: 128},
+			valid:     true,
+		},
+		{
+			name: "tmpfs within the memory limit",
+			container: &apicontainer.Container{
+				Name:   "c1",
+				Memory: 128,
+				Tmpfs: []apicontainer.TmpfsMount{
+					{ContainerPath: "/a", Size: 64},
+					{ContainerPath: "/b", Size: 64},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "tmpfs without a memory limit",
+			container: &apicontainer.Container{
+				Name:  "c1",
+				Tmpfs: []apicontainer.TmpfsMount{{ContainerPath: "/a", Size: 1024}},
+			},
+			valid: true,
+		},
+		{
+			name: "tmpfs exceeding the memory limit",
+			container: &apicontainer.Container{
+				Name:   "c1",
+				Memory: 128,
+				Tmpfs: []apicontainer.TmpfsMount{
+					{ContainerPath: "/a", Size: 64},
+					{ContainerPath: "/b", Size: 65},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "tmpfs without a size",
+			container: &apicontainer.Container{
+				Name:  "c1",
+				Tmpfs: []apicontainer.TmpfsMount{{ContainerPath: "/a"}},
+			},
+			valid: false,
+		},
+		{
+			name: "tmpfs without a container path",
+			container: &apicontainer.Container{
+				Name:  "c1",
+				Tmpfs: []apicontainer.TmpfsMount{{Size: 64}},
+			},
+			valid: false,
+		},
+		{
+			name:      "negative shared memory size",
+			container: &apicontainer.Container{Name: "c1", ShmSize: -1},
+			valid:     false,
+		},
+		{
+			name:      "memory swappiness out of range",
+			container: &apicontainer.Container{Name: "c1", MemorySwappiness: aws.Int64(101)},
+			valid:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &Task{Containers: []*apicontainer.Container{tc.container}}
+			err := task.validateContainerMemorySettings()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPostUnmarshalTaskWithInvalidTmpfsSize(t *testing.T) {
+	task := &Task{
+		Arn: "arn:aws:ecs:us-west-2:1234567890:task/test",
+		Containers: []*apicontainer.Container{
+			{
+				Name:   "c1",
+				Memory: 64,
+				Tmpfs:  []apicontainer.TmpfsMount{{ContainerPath: "/scratch", Size: 128}},
 			},
+		},
+	}
+	cfg := config.Config{}
+	err := task.PostUnmarshalTask(&cfg, nil, nil, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, "InvalidTaskError", err.(apierrors.NamedError).ErrorName())
+}
+
+func TestPostUnmarshalTaskWithSharedIPCNamespaceSystemControls(t *testing.T) {
+	testCases := []struct {
+		ipcMode string
+		valid   bool
+	}{
+		{"", true},
+		{ipcModeNone, true},
+		{ipcModeHost, false},
+		{ipcModeTask, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("ipc mode %q", tc.ipcMode), func(t *testing.T) {
+			task := &Task{
+				Arn:     "arn:aws:ecs:us-west-2:1234567890:task/test",
+				IPCMode: tc.ipcMode,
+				Containers: []*apicontainer.Container{
+					{
+						Name:                      "c1",
+						SystemControls:            map[string]string{"kernel.shmmax": "68719476736"},
+						TransitionDependenciesMap: make(map[apicontainerstatus.ContainerStatus]apicontainer.TransitionDependencySet),
+					},
+				},
+			}
+			cfg := config.Config{}
+			err := task.PostUnmarshalTask(&cfg, nil, nil, nil, nil)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, "InvalidTaskError", err.(apierrors.NamedError).ErrorName())
+			}
+		})
+	}
+}
+
+func TestPostUnmarshalTaskMountPropagation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		volume      taskresourcevolume.Volume
+		propagation string
+		valid       bool
+	}{
+		{"private host path", &taskresourcevolume.FSHostVolume{FSSourcePath: "/data"}, apicontainer.MountPropagationPrivate, true},
+		{"rslave host path", &taskresourcevolume.FSHostVolume{FSSourcePath: "/data"}, apicontainer.MountPropagationRSlave, true},
+		{"rshared host path", &taskresourcevolume.FSHostVolume{FSSourcePath: "/data"}, apicontainer.MountPropagationRShared, true},
+		{"rshared docker volume", &taskresourcevolume.LocalDockerVolume{}, apicontainer.MountPropagationRShared, false},
+		{"unsupported propagation", &taskresourcevolume.FSHostVolume{FSSourcePath: "/data"}, "shared", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &Task{
+				Arn: "arn:aws:ecs:us-west-2:1234567890:task/test",
+				Containers: []*apicontainer.Container{
+					{
+						Name: "c1",
+						MountPoints: []apicontainer.MountPoint{
+							{
+								SourceVolume:  "data",
+								ContainerPath: "/data",
+								Propagation:   tc.propagation,
+							},
+						},
+						TransitionDependenciesMap: make(map[apicontainerstatus.ContainerStatus]apicontainer.TransitionDependencySet),
+					},
+				},
+				Volumes: []TaskVolume{
+					{
+						Name:   "data",
+						Type:   HostVolumeType,
+						Volume: tc.volume,
+					},
+				},
+			}
+			cfg := config.Config{}
+			err := task.PostUnmarshalTask(&cfg, nil, nil, nil, nil)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, "InvalidTaskError", err.(apierrors.NamedError).ErrorName())
+			}
+		})
+	}
+}
+
+func TestPostUnmarshalTaskStopSignal(t *testing.T) {
+	testCases := []struct {
+		signal string
+		valid  bool
+	}{
+		{"", true},
+		{"SIGQUIT", true},
+		{"quit", true},
+		{"sigusr1", true},
+		{"9", true},
+		{"0", false},
+		{"65", false},
+		{"SIGFOO", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("stop signal %q", tc.signal), func(t *testing.T) {
+			task := &Task{
+				Arn: "arn:aws:ecs:us-west-2:1234567890:task/test",
+				Containers: []*apicontainer.Container{
+					{
+						Name:                      "c1",
+						StopSignal:                tc.signal,
+						TransitionDependenciesMap: make(map[apicontainerstatus.ContainerStatus]apicontainer.TransitionDependencySet),
+					},
+				},
+			}
+			cfg := config.Config{}
+			err := task.PostUnmarshalTask(&cfg, nil, nil, nil, nil)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, "InvalidTaskError", err.(apierrors.NamedError).ErrorName())
+				assert.Contains(t, err.Error(), "unknown stop signal")
+			}
+		})
+	}
+}
+
+func TestDockerConfigStopSignal(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
 			{
-				Name:        "c2",
-				VolumesFrom: []apicontainer.VolumeFrom{{SourceContainer: "c1"}},
+				Name:       "c1",
+				StopSignal: "SIGQUIT",
 			},
 		},
 	}
 
-	config, err := testTask.DockerHostConfig(testTask.Containers[1], dockerMap(testTask), defaultDockerClientAPIVersion)
-	assert.Nil(t, err)
+	config, err := testTask.DockerConfig(testTask.Containers[0], defaultDockerClientAPIVersion)
+	require.Nil(t, err)
+	assert.Equal(t, "SIGQUIT", config.StopSignal)
+}
 
-	if !reflect.DeepEqual(config.VolumesFrom, []string{"dockername-c1"}) {
-		t.Error("Expected volumesFrom to be resolved, was: ", config.VolumesFrom)
+func TestDockerHostConfigReadonlyRootFilesystem(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name:                   "c1",
+				ReadonlyRootFilesystem: true,
+			},
+		},
 	}
+
+	config, err := testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+	assert.True(t, config.ReadonlyRootfs)
 }
 
 func TestDockerHostConfigRawConfig(t *testing.T) {
@@ -246,6 +1042,88 @@ func TestDockerHostConfigRawConfig(t *testing.T) {
 	assertSetStructFieldsEqual(t, expectedOutput, *config)
 }
 
+func TestBuildCNIConfigIPV6(t *testing.T) {
+	testTask := &Task{
+		ENI: &apieni.ENI{
+			ID:         "eniID",
+			MacAddress: "mac",
+			IPV4Addresses: []*apieni.ENIIPV4Address{
+				{Primary: true, Address: "10.0.0.2"},
+			},
+			SubnetGatewayIPV4Address: "10.0.0.1/24",
+		},
+	}
+
+	cfg, err := testTask.BuildCNIConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", cfg.ENIIPV4Address)
+	assert.Empty(t, cfg.ENIIPV6Address)
+	assert.Empty(t, cfg.SubnetGatewayIPV6Address)
+
+	testTask.ENI.IPV6Addresses = []*apieni.ENIIPV6Address{{Address: "2001:db8::2"}}
+	testTask.ENI.SubnetGatewayIPV6Address = "2001:db8::1"
+	cfg, err = testTask.BuildCNIConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::2", cfg.ENIIPV6Address)
+	assert.Equal(t, "2001:db8::1", cfg.SubnetGatewayIPV6Address)
+}
+
+func TestBuildCNIConfigEgressBandwidthLimit(t *testing.T) {
+	testTask := &Task{
+		ENI: &apieni.ENI{
+			ID:         "eniID",
+			MacAddress: "mac",
+			IPV4Addresses: []*apieni.ENIIPV4Address{
+				{Primary: true, Address: "10.0.0.2"},
+			},
+		},
+	}
+
+	cfg, err := testTask.BuildCNIConfig()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.EgressBandwidthLimit)
+
+	testTask.EgressBandwidthLimit = 100
+	cfg, err = testTask.BuildCNIConfig()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), cfg.EgressBandwidthLimit)
+}
+
+func TestBuildCNIConfigAppMesh(t *testing.T) {
+	testTask := &Task{
+		ENI: &apieni.ENI{
+			ID:         "eniID",
+			MacAddress: "mac",
+			IPV4Addresses: []*apieni.ENIIPV4Address{
+				{Primary: true, Address: "10.0.0.2"},
+			},
+		},
+	}
+
+	cfg, err := testTask.BuildCNIConfig()
+	require.NoError(t, err)
+	assert.False(t, cfg.AppMeshCNIEnabled)
+
+	testTask.SetAppMesh(&apiappmesh.AppMesh{
+		ContainerName:    "envoy",
+		IgnoredUID:       "1337",
+		ProxyIngressPort: "15000",
+		ProxyEgressPort:  "15001",
+		AppPorts:         []string{"8080"},
+		EgressIgnoredIPs: []string{"10.0.0.0/16"},
+	})
+	cfg, err = testTask.BuildCNIConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.AppMeshCNIEnabled)
+	assert.Equal(t, "1337", cfg.IgnoredUID)
+	assert.Equal(t, "15000", cfg.ProxyIngressPort)
+	assert.Equal(t, "15001", cfg.ProxyEgressPort)
+	assert.Equal(t, []string{"8080"}, cfg.AppPorts)
+	assert.Equal(t, []string{"10.0.0.0/16", credentialsEndpointIP, instanceMetadataEndpointIP}, cfg.EgressIgnoredIPs)
+	// The ips of the proxy configuration aren't modified
+	assert.Equal(t, []string{"10.0.0.0/16"}, testTask.GetAppMesh().EgressIgnoredIPs)
+}
+
 func TestDockerHostConfigPauseContainer(t *testing.T) {
 	testTask := &Task{
 		ENI: &apieni.ENI{
@@ -309,6 +1187,42 @@ func TestDockerHostConfigPauseContainer(t *testing.T) {
 
 }
 
+func TestDockerHostConfigPauseContainerTaskDNS(t *testing.T) {
+	testTask := &Task{
+		ENI: &apieni.ENI{
+			ID:                "eniID",
+			DomainNameServers: []string{"169.254.169.253"},
+		},
+		Containers: []*apicontainer.Container{
+			{
+				Name: "c1",
+				DockerConfig: apicontainer.DockerConfig{
+					HostConfig: aws.String(`{"Dns":["10.0.0.2"],"DnsSearch":["example.com"],"ExtraHosts":["db:10.0.0.10"]}`),
+				},
+			},
+			{
+				Name: NetworkPauseContainerName,
+				Type: apicontainer.ContainerCNIPause,
+			},
+		},
+	}
+
+	// The DNS settings of the task's containers are applied to the pause
+	// container
+	config, err := testTask.DockerHostConfig(testTask.Containers[1], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.2"}, config.DNS)
+	assert.Equal(t, []string{"example.com"}, config.DNSSearch)
+	assert.Equal(t, []string{"db:10.0.0.10"}, config.ExtraHosts)
+
+	// And removed from the containers joining its network namespace
+	config, err = testTask.DockerHostConfig(testTask.Containers[0], dockerMap(testTask), defaultDockerClientAPIVersion)
+	assert.Nil(t, err)
+	assert.Empty(t, config.DNS)
+	assert.Empty(t, config.DNSSearch)
+	assert.Empty(t, config.ExtraHosts)
+}
+
 func TestBadDockerHostConfigRawConfig(t *testing.T) {
 	for _, badHostConfig := range []string{"malformed", `{"Privileged": "wrongType"}`} {
 		testTask := Task{
@@ -575,6 +1489,79 @@ func TestPostUnmarshalTaskWithDockerVolumes(t *testing.T) {
 	assert.Equal(t, DockerVolumeType, taskVol.Type)
 }
 
+func TestPostUnmarshalTaskWithTaskNetwork(t *testing.T) {
+	testCases := []struct {
+		name                string
+		taskNetworksEnabled bool
+		networkModes        []string
+		expectedNetwork     bool
+	}{
+		{
+			name:                "task networks disabled",
+			taskNetworksEnabled: false,
+			networkModes:        []string{""},
+			expectedNetwork:     false,
+		},
+		{
+			name:                "bridge containers",
+			taskNetworksEnabled: true,
+			networkModes:        []string{"", "bridge", "host"},
+			expectedNetwork:     true,
+		},
+		{
+			name:                "no bridge container",
+			taskNetworksEnabled: true,
+			networkModes:        []string{"host", "none"},
+			expectedNetwork:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &Task{
+				Arn:                "arn:aws:ecs:us-west-2:123456789012:task/cluster/task-id",
+				ResourcesMapUnsafe: make(map[string][]taskresource.TaskResource),
+			}
+			for i, networkMode := range tc.networkModes {
+				container := &apicontainer.Container{
+					Name:                      fmt.Sprintf("c%d", i),
+					TransitionDependenciesMap: make(map[apicontainerstatus.ContainerStatus]apicontainer.TransitionDependencySet),
+				}
+				if networkMode != "" {
+					hostConfig := fmt.Sprintf(`{"NetworkMode":"%s"}`, networkMode)
+					container.DockerConfig.HostConfig = &hostConfig
+				}
+				task.Containers = append(task.Containers, container)
+			}
+
+			cfg := &config.Config{TaskNetworksEnabled: tc.taskNetworksEnabled}
+			require.NoError(t, task.PostUnmarshalTask(cfg, nil, nil, nil, nil))
+
+			networkResource, ok := task.getTaskNetworkResource()
+			require.Equal(t, tc.expectedNetwork, ok)
+			if !tc.expectedNetwork {
+				return
+			}
+			assert.Equal(t, "ecs-task-cluster-task-id", networkResource.GetNetworkName())
+			for i, networkMode := range tc.networkModes {
+				container := task.Containers[i]
+				_, connected := task.GetTaskNetworkForContainer(container)
+				bridge := networkMode == "" || networkMode == "bridge"
+				assert.Equal(t, bridge, connected)
+				resourceDependencies := container.TransitionDependenciesMap[apicontainerstatus.ContainerCreated].ResourceDependencies
+				if bridge {
+					assert.Equal(t, []apicontainer.ResourceDependency{{
+						Name:           networkResource.GetName(),
+						RequiredStatus: resourcestatus.ResourceStatus(taskresourcenetwork.NetworkCreated),
+					}}, resourceDependencies)
+				} else {
+					assert.Empty(t, resourceDependencies)
+				}
+			}
+		})
+	}
+}
+
 func TestInitializeContainersV3MetadataEndpoint(t *testing.T) {
 	task := Task{
 		Containers: []*apicontainer.Container{
@@ -922,6 +1909,56 @@ func TestTaskFromACS(t *testing.T) {
 						SourceContainer: strptr("volumeLink"),
 					},
 				},
+				DependsOn: []*ecsacs.ContainerDependency{
+					{
+						ContainerName: strptr("initContainer"),
+						Condition:     strptr("SUCCESS"),
+					},
+				},
+				StartTimeout:      intptr(60),
+				StopTimeout:       intptr(120),
+				ImagePullBehavior: strptr("prefer-cached"),
+				ShmSize:           intptr(128),
+				MemorySwappiness:  intptr(10),
+				CapAdd:            []*string{strptr("NET_BIND_SERVICE")},
+				CapDrop:           []*string{strptr("ALL")},
+				Devices: []*ecsacs.Device{
+					{
+						HostPath:      strptr("/dev/fuse"),
+						ContainerPath: strptr("/dev/fuse"),
+						Permissions:   strptr("rw"),
+					},
+				},
+				SystemControls: map[string]*string{
+					"net.core.somaxconn": strptr("1024"),
+				},
+				GpuCount: intptr(1),
+				Ulimits: []*ecsacs.Ulimit{
+					{
+						Name: strptr("nofile"),
+						Soft: intptr(1024),
+						Hard: intptr(4096),
+					},
+				},
+				Tmpfs: []*ecsacs.Tmpfs{
+					{
+						ContainerPath: strptr("/scratch"),
+						Size:          intptr(64),
+						MountOptions:  []*string{strptr("noexec")},
+					},
+				},
+				RestartPolicy: &ecsacs.ContainerRestartPolicy{
+					Attempts:             intptr(3),
+					IgnoredExitCodes:     []*int64{intptr(0)},
+					RestartAttemptPeriod: intptr(60),
+				},
+				HealthCheck: &ecsacs.HealthCheck{
+					Command:     []*string{strptr("CMD"), strptr("true")},
+					Interval:    intptr(10),
+					Timeout:     intptr(5),
+					Retries:     intptr(3),
+					StartPeriod: intptr(30),
+				},
 				DockerConfig: &ecsacs.DockerConfig{
 					Config:     strptr("config json"),
 					HostConfig: strptr("hostconfig json"),
@@ -946,8 +1983,11 @@ func TestTaskFromACS(t *testing.T) {
 			SecretAccessKey: strptr("OhhSecret"),
 			SessionToken:    strptr("sessionToken"),
 		},
-		Cpu:    floatptr(2.0),
-		Memory: intptr(512),
+		Cpu:                        floatptr(2.0),
+		Memory:                     intptr(512),
+		EphemeralStorageLimit:      intptr(1024),
+		CleanupWaitDurationSeconds: intptr(600),
+		StoppedReason:              strptr("Task stopped by user"),
 	}
 	expectedTask := &Task{
 		Arn:                 "myArn",
@@ -988,6 +2028,56 @@ func TestTaskFromACS(t *testing.T) {
 						SourceContainer: "volumeLink",
 					},
 				},
+				DependsOn: []apicontainer.DependsOn{
+					{
+						ContainerName: "initContainer",
+						Condition:     apicontainer.DependsOnConditionSuccess,
+					},
+				},
+				StartTimeout:      60,
+				StopTimeout:       120,
+				ImagePullBehavior: "prefer-cached",
+				ShmSize:           128,
+				MemorySwappiness:  aws.Int64(10),
+				CapAdd:            []string{"NET_BIND_SERVICE"},
+				CapDrop:           []string{"ALL"},
+				Devices: []apicontainer.Device{
+					{
+						HostPath:      "/dev/fuse",
+						ContainerPath: "/dev/fuse",
+						Permissions:   "rw",
+					},
+				},
+				SystemControls: map[string]string{
+					"net.core.somaxconn": "1024",
+				},
+				GPUCount: 1,
+				Ulimits: []apicontainer.Ulimit{
+					{
+						Name: "nofile",
+						Soft: 1024,
+						Hard: 4096,
+					},
+				},
+				Tmpfs: []apicontainer.TmpfsMount{
+					{
+						ContainerPath: "/scratch",
+						Size:          64,
+						MountOptions:  []string{"noexec"},
+					},
+				},
+				RestartPolicy: &apicontainer.RestartPolicy{
+					Attempts:             3,
+					IgnoredExitCodes:     []int{0},
+					RestartAttemptPeriod: 60,
+				},
+				HealthCheck: &apicontainer.HealthCheck{
+					Command:     []string{"CMD", "true"},
+					Interval:    10,
+					Timeout:     5,
+					Retries:     3,
+					StartPeriod: 30,
+				},
 				DockerConfig: apicontainer.DockerConfig{
 					Config:     strptr("config json"),
 					HostConfig: strptr("hostconfig json"),
@@ -1005,10 +2095,13 @@ func TestTaskFromACS(t *testing.T) {
 				},
 			},
 		},
-		StartSequenceNumber: 42,
-		CPU:                 2.0,
-		Memory:              512,
-		ResourcesMapUnsafe:  make(map[string][]taskresource.TaskResource),
+		StartSequenceNumber:        42,
+		CPU:                        2.0,
+		Memory:                     512,
+		EphemeralStorageLimit:      1024,
+		CleanupWaitDurationSeconds: 600,
+		StoppedReasonUnsafe:        "Task stopped by user",
+		ResourcesMapUnsafe:         make(map[string][]taskresource.TaskResource),
 	}
 
 	seqNum := int64(42)
@@ -1601,36 +2694,121 @@ func TestContainerHealthConfig(t *testing.T) {
 	assert.Equal(t, config.Healthcheck.StartPeriod, 1*time.Minute)
 }
 
+// TestContainerTaskDefinitionHealthConfig tests that the health check of the
+// task definition overrides the one of the docker config
+func TestContainerTaskDefinitionHealthConfig(t *testing.T) {
+	testTask := &Task{
+		Containers: []*apicontainer.Container{
+			{
+				Name: "c1",
+				HealthCheck: &apicontainer.HealthCheck{
+					Command:     []string{"CMD-SHELL", "curl -f http://localhost/"},
+					Interval:    10,
+					Timeout:     2,
+					Retries:     4,
+					StartPeriod: 30,
+				},
+				DockerConfig: apicontainer.DockerConfig{
+					Config: aws.String(`{"HealthCheck":{"Test":["command"],"Retries":5}}`),
+				},
+			},
+		},
+	}
+
+	config, err := testTask.DockerConfig(testTask.Containers[0], dockerclient.Version_1_29)
+	assert.Nil(t, err)
+	require.NotNil(t, config.Healthcheck, "health config was not set in docker config")
+	assert.Equal(t, &docker.HealthConfig{
+		Test:        []string{"CMD-SHELL", "curl -f http://localhost/"},
+		Interval:    10 * time.Second,
+		Timeout:     2 * time.Second,
+		StartPeriod: 30 * time.Second,
+		Retries:     4,
+	}, config.Healthcheck)
+	assert.True(t, testTask.Containers[0].HealthStatusShouldBeReported())
+}
+
+func TestContainerTaskDefinitionHealthConfigInvalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		healthCheck *apicontainer.HealthCheck
+		apiVersion  dockerclient.DockerVersion
+		errorText   string
+	}{
+		{
+			name:        "no command",
+			healthCheck: &apicontainer.HealthCheck{},
+			apiVersion:  dockerclient.Version_1_29,
+			errorText:   "has no command",
+		},
+		{
+			name:        "command without test type",
+			healthCheck: &apicontainer.HealthCheck{Command: []string{"curl", "http://localhost/"}},
+			apiVersion:  dockerclient.Version_1_29,
+			errorText:   "must start with CMD, CMD-SHELL or NONE",
+		},
+		{
+			name:        "start period unsupported",
+			healthCheck: &apicontainer.HealthCheck{Command: []string{"CMD", "true"}, StartPeriod: 30},
+			apiVersion:  dockerclient.Version_1_28,
+			errorText:   "requires docker api version 1.29",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testTask := &Task{
+				Containers: []*apicontainer.Container{{Name: "c1", HealthCheck: tc.healthCheck}},
+			}
+			_, err := testTask.DockerConfig(testTask.Containers[0], tc.apiVersion)
+			require.NotNil(t, err)
+			assert.Contains(t, err.Error(), tc.errorText)
+		})
+	}
+}
+
 func TestRecordExecutionStoppedAt(t *testing.T) {
 	testCases := []struct {
 		essential             bool
 		status                apicontainerstatus.ContainerStatus
+		taskDesiredStatus     apitaskstatus.TaskStatus
 		executionStoppedAtSet bool
 		msg                   string
 	}{
 		{
 			essential:             true,
 			status:                apicontainerstatus.ContainerStopped,
+			taskDesiredStatus:     apitaskstatus.TaskStopped,
 			executionStoppedAtSet: true,
 			msg:                   "essential container stopped should have executionStoppedAt set",
 		},
 		{
 			essential:             false,
 			status:                apicontainerstatus.ContainerStopped,
+			taskDesiredStatus:     apitaskstatus.TaskStopped,
 			executionStoppedAtSet: false,
 			msg:                   "non essential container stopped should not cause executionStoppedAt set",
 		},
 		{
 			essential:             true,
 			status:                apicontainerstatus.ContainerRunning,
+			taskDesiredStatus:     apitaskstatus.TaskStopped,
 			executionStoppedAtSet: false,
 			msg:                   "essential non-stop status change should not cause executionStoppedAt set",
 		},
+		{
+			essential:             true,
+			status:                apicontainerstatus.ContainerStopped,
+			taskDesiredStatus:     apitaskstatus.TaskRunning,
+			executionStoppedAtSet: false,
+			msg:                   "essential container stopped before the task desired status changes should leave executionStoppedAt to the desired status update",
+		},
 	}
 
 	for _, tc := range testCases {
-		t.Run(fmt.Sprintf("Container status: %s, essential: %v, executionStoppedAt should be set: %v", tc.status, tc.essential, tc.executionStoppedAtSet), func(t *testing.T) {
-			task := &Task{}
+		t.Run(fmt.Sprintf("Container status: %s, essential: %v, task desired status: %s, executionStoppedAt should be set: %v",
+			tc.status, tc.essential, tc.taskDesiredStatus, tc.executionStoppedAtSet), func(t *testing.T) {
+			task := &Task{DesiredStatusUnsafe: tc.taskDesiredStatus}
 			task.RecordExecutionStoppedAt(&apicontainer.Container{
 				Essential:         tc.essential,
 				KnownStatusUnsafe: tc.status,
@@ -1640,6 +2818,74 @@ func TestRecordExecutionStoppedAt(t *testing.T) {
 	}
 }
 
+func TestExecutionStoppedAtMultipleEssentialContainers(t *testing.T) {
+	for _, firstStopped := range []int{0, 1} {
+		t.Run(fmt.Sprintf("essential container %d stops first", firstStopped), func(t *testing.T) {
+			task := &Task{
+				Arn:                 "arn:aws:ecs:us-west-2:123456789012:task/task-id",
+				DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+				Containers: []*apicontainer.Container{
+					{
+						Name:                "c0",
+						Essential:           true,
+						KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+						DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+					},
+					{
+						Name:                "c1",
+						Essential:           true,
+						KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+						DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+					},
+				},
+			}
+			first := task.Containers[firstStopped]
+			second := task.Containers[1-firstStopped]
+
+			first.SetKnownStatus(apicontainerstatus.ContainerStopped)
+			task.RecordExecutionStoppedAt(first)
+			assert.True(t, task.GetExecutionStoppedAt().IsZero(),
+				"executionStoppedAt should not be set before the task desired status changes")
+
+			task.UpdateDesiredStatus()
+			assert.Equal(t, apitaskstatus.TaskStopped, task.GetDesiredStatus())
+			executionStoppedAt := task.GetExecutionStoppedAt()
+			assert.False(t, executionStoppedAt.IsZero(),
+				"executionStoppedAt should be set when the task desired status moves to stopped")
+
+			second.SetKnownStatus(apicontainerstatus.ContainerStopped)
+			task.RecordExecutionStoppedAt(second)
+			task.UpdateDesiredStatus()
+			assert.Equal(t, executionStoppedAt, task.GetExecutionStoppedAt(),
+				"executionStoppedAt should not change when the second essential container stops")
+		})
+	}
+}
+
+func TestExecutionStoppedAtTaskAlreadyStopping(t *testing.T) {
+	task := &Task{
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		Containers: []*apicontainer.Container{
+			{
+				Name:                "c0",
+				Essential:           true,
+				KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+				DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+			},
+		},
+	}
+
+	task.SetDesiredStatus(apitaskstatus.TaskStopped)
+	task.UpdateDesiredStatus()
+	assert.True(t, task.GetExecutionStoppedAt().IsZero(),
+		"executionStoppedAt should not be set while the essential container is running")
+
+	task.Containers[0].SetKnownStatus(apicontainerstatus.ContainerStopped)
+	task.RecordExecutionStoppedAt(task.Containers[0])
+	assert.False(t, task.GetExecutionStoppedAt().IsZero(),
+		"executionStoppedAt should be set when the essential container of a stopping task stops")
+}
+
 func TestMarshalUnmarshalTaskASMResource(t *testing.T) {
 
 	expectedCredentialsParameter := "secret-id"
@@ -1729,6 +2975,47 @@ func TestSetTerminalReason(t *testing.T) {
 	assert.Equal(t, expectedTerminalReason, task.GetTerminalReason())
 }
 
+func TestSetTerminalReasonCode(t *testing.T) {
+	task := &Task{}
+	assert.Equal(t, apireason.ReasonCodeNone, task.GetTerminalReasonCode())
+
+	task.SetTerminalReasonCode(apireason.ReasonCodeUserInitiatedStop)
+	assert.Equal(t, apireason.ReasonCodeUserInitiatedStop, task.GetTerminalReasonCode())
+
+	// The first reason code recorded is not overridden
+	task.SetTerminalReasonCode(apireason.ReasonCodeEssentialContainerExited)
+	assert.Equal(t, apireason.ReasonCodeUserInitiatedStop, task.GetTerminalReasonCode())
+}
+
+func TestUpdateDesiredStatusSetsEssentialContainerExitedReasonCode(t *testing.T) {
+	task := &Task{
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		Containers: []*apicontainer.Container{{
+			Name:              "essential",
+			Essential:         true,
+			KnownStatusUnsafe: apicontainerstatus.ContainerStopped,
+		}},
+	}
+	task.UpdateDesiredStatus()
+	assert.Equal(t, apitaskstatus.TaskStopped, task.GetDesiredStatus())
+	assert.Equal(t, apireason.ReasonCodeEssentialContainerExited, task.GetTerminalReasonCode())
+}
+
+func TestUpdateDesiredStatusKeepsStopRequestedReasonCode(t *testing.T) {
+	task := &Task{
+		DesiredStatusUnsafe: apitaskstatus.TaskStopped,
+		Containers: []*apicontainer.Container{{
+			Name:                "essential",
+			Essential:           true,
+			KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+			DesiredStatusUnsafe: apicontainerstatus.ContainerStopped,
+		}},
+	}
+	task.SetTerminalReasonCode(apireason.ReasonCodeUserInitiatedStop)
+	task.UpdateDesiredStatus()
+	assert.Equal(t, apireason.ReasonCodeUserInitiatedStop, task.GetTerminalReasonCode())
+}
+
 func TestPopulateASMAuthData(t *testing.T) {
 	expectedUsername := "username"
 	expectedPassword := "password"
@@ -2120,4 +3407,4 @@ func TestRequiresSSMSecretNoSecret(t *testing.T) {
 	}
 
 	assert.Equal(t, false, task.requiresSSMSecret())
-}
\ No newline at end of file
+}
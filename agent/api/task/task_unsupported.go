@@ -22,6 +22,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	"github.com/cihub/seelog"
 	docker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -39,6 +40,15 @@ const (
 	bytesPerMegabyte  = 1024 * 1024
 )
 
+// supportedNetworkModes are the docker network modes containers can be
+// configured with, in addition to sharing the network mode of another container
+var supportedNetworkModes = map[string]struct{}{
+	networkModeBridge: {},
+	networkModeHost:   {},
+	networkModeNone:   {},
+	networkModeAWSVPC: {},
+}
+
 // PlatformFields consists of fields specific to Linux for a task
 type PlatformFields struct{}
 
@@ -68,3 +78,7 @@ func (task *Task) dockerCPUShares(containerCPU uint) int64 {
 	}
 	return int64(containerCPU)
 }
+
+func verifyMountPoint(path string) error {
+	return errors.New("mount propagation isn't supported on this platform")
+}
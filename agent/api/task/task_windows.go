@@ -37,6 +37,17 @@ const (
 	minimumCPUPercent = 1
 )
 
+// supportedNetworkModes are the docker network modes containers can be
+// configured with, in addition to sharing the network mode of another container
+var supportedNetworkModes = map[string]struct{}{
+	"default":         {},
+	"nat":             {},
+	"transparent":     {},
+	"l2bridge":        {},
+	networkModeAWSVPC: {},
+	networkModeNone:   {},
+}
+
 // PlatformFields consists of fields specific to Windows for a task
 type PlatformFields struct {
 	// CpuUnbounded determines whether a mix of unbounded and bounded CPU tasks
@@ -136,3 +147,9 @@ func (task *Task) dockerCPUShares(containerCPU uint) int64 {
 func (task *Task) initializeCgroupResourceSpec(cgroupPath string, resourceFields *taskresource.ResourceFields) error {
 	return errors.New("unsupported platform")
 }
+
+// verifyMountPoint returns an error, as docker doesn't support the propagation
+// of mounts on Windows
+func verifyMountPoint(path string) error {
+	return errors.New("mount propagation isn't supported on windows")
+}
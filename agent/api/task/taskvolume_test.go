@@ -421,6 +421,7 @@ func TestInitializeSharedAutoprovisionVolumeTimeout(t *testing.T) {
 func TestInitializeTaskVolume(t *testing.T) {
 	sharedVolumeMatchFullConfig := true
 	testTask := &Task{
+		Arn:                "taskArn",
 		ResourcesMapUnsafe: make(map[string][]taskresource.TaskResource),
 		Containers: []*apicontainer.Container{
 			{
@@ -438,7 +439,8 @@ func TestInitializeTaskVolume(t *testing.T) {
 				Name: "task-volume-test",
 				Type: "docker",
 				Volume: &taskresourcevolume.DockerVolumeConfig{
-					Scope: "task",
+					Scope:  "task",
+					Labels: map[string]string{"label": "value"},
 				},
 			},
 		},
@@ -448,4 +450,7 @@ func TestInitializeTaskVolume(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, testTask.ResourcesMapUnsafe, 1, "expect the resource map has an empty volume resource")
 	assert.Len(t, testTask.Containers[0].TransitionDependenciesMap, 1, "expect a volume resource as the container dependency")
+	volumeResource := testTask.ResourcesMapUnsafe["dockerVolume"][0].(*taskresourcevolume.VolumeResource)
+	assert.Equal(t, map[string]string{"label": "value", taskresource.TaskARNLabel: "taskArn"},
+		volumeResource.VolumeConfig.Labels, "expect the task scoped volume to be labeled with the task arn")
 }
@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	acshandler "github.com/aws/amazon-ecs-agent/agent/acs/handler"
+	"github.com/aws/amazon-ecs-agent/agent/agenthealth"
 	"github.com/aws/amazon-ecs-agent/agent/api"
 	"github.com/aws/amazon-ecs-agent/agent/api/ecsclient"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
@@ -38,7 +39,9 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/eni/pause"
 	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/gpu"
 	"github.com/aws/amazon-ecs-agent/agent/handlers"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
@@ -231,15 +234,25 @@ func (agent *ecsAgent) doStart(containerChangeEventStream *eventstream.EventStre
 		}
 	}
 
+	// Discover the GPUs the task engine assigns to containers
+	if agent.cfg.GPUSupportEnabled {
+		if err := agent.initializeGPUs(); err != nil {
+			seelog.Criticalf("Unable to discover the GPUs of the instance: %v", err)
+			return exitcodes.ExitTerminal
+		}
+	}
+
 	// Create the task engine
+	pendingStateChanges := eventhandler.NewPendingStateChanges()
+	acsSequenceNumbers := acshandler.NewSequenceNumbers()
 	taskEngine, currentEC2InstanceID, err := agent.newTaskEngine(containerChangeEventStream,
-		credentialsManager, state, imageManager)
+		credentialsManager, state, imageManager, pendingStateChanges, acsSequenceNumbers)
 	if err != nil {
 		return exitcodes.ExitTerminal
 	}
 
 	// Initialize the state manager
-	stateManager, err := agent.newStateManager(taskEngine,
+	stateManager, err := agent.newStateManager(taskEngine, pendingStateChanges, acsSequenceNumbers,
 		&agent.cfg.Cluster, &agent.containerInstanceARN, &currentEC2InstanceID)
 	if err != nil {
 		seelog.Criticalf("Error creating state manager: %v", err)
@@ -250,18 +263,25 @@ func (agent *ecsAgent) doStart(containerChangeEventStream *eventstream.EventStre
 	// Check if Task ENI is enabled
 	if agent.cfg.TaskENIEnabled {
 		err, terminal := agent.initializeTaskENIDependencies(state, taskEngine)
-		switch err {
-		case nil:
+		switch {
+		case err == nil:
 			// No error, we can proceed with the rest of initialization
 			// Set vpc and subnet id attributes
 			vpcSubnetAttributes = agent.constructVPCSubnetAttributes()
-		case instanceNotLaunchedInVPCError:
+		case err == instanceNotLaunchedInVPCError:
 			// We have ascertained that the EC2 Instance is not running in a VPC
 			// No need to stop the ECS Agent in this case; all we need to do is
 			// to not update the config to disable the TaskENIEnabled flag and
 			// move on
 			seelog.Warnf("Unable to detect VPC ID for the Instance, disabling Task ENI capability: %v", err)
 			agent.cfg.TaskENIEnabled = false
+		case pause.IsNoSuchFileError(err):
+			// The pause container image is neither present locally nor
+			// bundled with the Agent. Tasks with the awsvpc network mode
+			// can't be started without it, so the Task ENI capability
+			// isn't registered
+			seelog.Warnf("Unable to load the pause container image, disabling Task ENI capability: %v", err)
+			agent.cfg.TaskENIEnabled = false
 		default:
 			// Encountered an error initializing dependencies for dealing with
 			// ENIs for Tasks. Exit with the appropriate error code
@@ -295,21 +315,45 @@ func (agent *ecsAgent) doStart(containerChangeEventStream *eventstream.EventStre
 	deregisterInstanceEventStream := eventstream.NewEventStream(
 		deregisterContainerInstanceEventStreamName, agent.ctx)
 	deregisterInstanceEventStream.StartListening()
-	taskHandler := eventhandler.NewTaskHandler(agent.ctx, stateManager, state, client)
+	taskHandler := eventhandler.NewTaskHandler(agent.ctx, stateManager, state, client, pendingStateChanges)
+	// Queue the state changes which hadn't been submitted before the agent
+	// restarted ahead of the ones emitted by the task engine
+	taskHandler.RestorePendingStateChanges()
+	acsConnectionStatus := acshandler.NewConnectionStatus()
+	acsInstanceStatus := acshandler.NewInstanceStatus()
 	agent.startAsyncRoutines(containerChangeEventStream, credentialsManager, imageManager,
-		taskEngine, stateManager, deregisterInstanceEventStream, client, taskHandler, state)
+		taskEngine, stateManager, deregisterInstanceEventStream, client, taskHandler, state, acsConnectionStatus,
+		acsInstanceStatus)
 
 	// Start the acs session, which should block doStart
 	return agent.startACSSession(credentialsManager, taskEngine, stateManager,
-		deregisterInstanceEventStream, client, state, taskHandler)
+		deregisterInstanceEventStream, client, state, taskHandler, acsConnectionStatus, acsSequenceNumbers,
+		acsInstanceStatus)
+}
+
+// initializeGPUs discovers the GPUs of the instance and records their IDs in
+// the config, from which the task engine and the registration attributes read
+// them
+func (agent *ecsAgent) initializeGPUs() error {
+	gpuIDs, err := gpu.DiscoverGPUIDs()
+	if err != nil {
+		return err
+	}
+	seelog.Infof("Discovered %d GPUs: %v", len(gpuIDs), gpuIDs)
+	agent.cfg.GPUIDs = gpuIDs
+	return nil
 }
 
 // newTaskEngine creates a new docker task engine object. It tries to load the
-// local state if needed, else initializes a new one
+// local state if needed, else initializes a new one. The state changes which
+// haven't been submitted yet are loaded into pendingStateChanges, and the
+// sequence numbers of the ACS payloads into acsSequenceNumbers
 func (agent *ecsAgent) newTaskEngine(containerChangeEventStream *eventstream.EventStream,
 	credentialsManager credentials.Manager,
 	state dockerstate.TaskEngineState,
-	imageManager engine.ImageManager) (engine.TaskEngine, string, error) {
+	imageManager engine.ImageManager,
+	pendingStateChanges *eventhandler.PendingStateChanges,
+	acsSequenceNumbers *acshandler.SequenceNumbers) (engine.TaskEngine, string, error) {
 
 	containerChangeEventStream.StartListening()
 
@@ -328,8 +372,8 @@ func (agent *ecsAgent) newTaskEngine(containerChangeEventStream *eventstream.Eve
 
 	// previousStateManager is used to verify that our current runtime configuration is
 	// compatible with our past configuration as reflected by our state-file
-	previousStateManager, err := agent.newStateManager(previousTaskEngine, &previousCluster,
-		&previousContainerInstanceArn, &previousEC2InstanceID)
+	previousStateManager, err := agent.newStateManager(previousTaskEngine, pendingStateChanges, acsSequenceNumbers,
+		&previousCluster, &previousContainerInstanceArn, &previousEC2InstanceID)
 	if err != nil {
 		seelog.Criticalf("Error creating state manager: %v", err)
 		return nil, "", err
@@ -411,6 +455,8 @@ func (agent *ecsAgent) getEC2InstanceID() string {
 // will be backfilled when state manager's Load() method is invoked
 func (agent *ecsAgent) newStateManager(
 	taskEngine engine.TaskEngine,
+	pendingStateChanges *eventhandler.PendingStateChanges,
+	acsSequenceNumbers *acshandler.SequenceNumbers,
 	cluster *string,
 	containerInstanceArn *string,
 	savedInstanceID *string) (statemanager.StateManager, error) {
@@ -421,6 +467,8 @@ func (agent *ecsAgent) newStateManager(
 
 	return agent.stateManagerFactory.NewStateManager(agent.cfg,
 		statemanager.AddSaveable("TaskEngine", taskEngine),
+		statemanager.AddSaveable("PendingStateChanges", pendingStateChanges),
+		statemanager.AddSaveable("ACSSequenceNumbers", acsSequenceNumbers),
 		// This is for making testing easier as we can mock this
 		agent.saveableOptionFactory.AddSaveable("ContainerInstanceArn",
 			containerInstanceArn),
@@ -536,7 +584,9 @@ func (agent *ecsAgent) startAsyncRoutines(
 	deregisterInstanceEventStream *eventstream.EventStream,
 	client api.ECSClient,
 	taskHandler *eventhandler.TaskHandler,
-	state dockerstate.TaskEngineState) {
+	state dockerstate.TaskEngineState,
+	acsConnectionStatus *acshandler.ConnectionStatus,
+	acsInstanceStatus *acshandler.InstanceStatus) {
 
 	// Start of the periodic image cleanup process
 	if !agent.cfg.ImageCleanupDisabled {
@@ -545,16 +595,31 @@ func (agent *ecsAgent) startAsyncRoutines(
 
 	go agent.terminationHandler(stateManager, taskEngine)
 
-	// Agent introspection api
-	go handlers.ServeIntrospectionHTTPEndpoint(&agent.containerInstanceARN, taskEngine, agent.cfg)
+	// Start monitoring the health of the agent and its connection to docker
+	agentHealth := agenthealth.NewMonitor(agent.dockerClient)
+	go agentHealth.Start(agent.ctx)
 
 	statsEngine := stats.NewDockerStatsEngine(agent.cfg, agent.dockerClient, containerChangeEventStream)
+	statsEngine.SetDockerHealth(agentHealth)
+
+	// Broadcast the state change events to the introspection api, and to the
+	// socket if one is configured
+	broadcaster, err := eventhandler.NewEventBroadcaster(agent.ctx, agent.cfg.StateChangeEventsSocketPath)
+	if err != nil {
+		seelog.Errorf("Unable to broadcast state change events on the socket: %v", err)
+		broadcaster, _ = eventhandler.NewEventBroadcaster(agent.ctx, "")
+	}
+
+	// Agent introspection api
+	go handlers.ServeIntrospectionHTTPEndpoint(&agent.containerInstanceARN, taskEngine, taskHandler, agentHealth,
+		credentialsManager, acsConnectionStatus, acsInstanceStatus, broadcaster,
+		[]metrics.Source{metrics.AgentMetrics, taskHandler, statsEngine}, agent.cfg)
 
 	// Start serving the endpoint to fetch IAM Role credentials and other task metadata
 	go handlers.ServeTaskHTTPEndpoint(credentialsManager, state, agent.containerInstanceARN, agent.cfg, statsEngine)
 
 	// Start sending events to the backend
-	go eventhandler.HandleEngineEvents(taskEngine, client, taskHandler)
+	go eventhandler.HandleEngineEvents(taskEngine, client, taskHandler, broadcaster)
 
 	telemetrySessionParams := tcshandler.TelemetrySessionParams{
 		Ctx:                           agent.ctx,
@@ -580,7 +645,10 @@ func (agent *ecsAgent) startACSSession(
 	deregisterInstanceEventStream *eventstream.EventStream,
 	client api.ECSClient,
 	state dockerstate.TaskEngineState,
-	taskHandler *eventhandler.TaskHandler) int {
+	taskHandler *eventhandler.TaskHandler,
+	acsConnectionStatus *acshandler.ConnectionStatus,
+	acsSequenceNumbers *acshandler.SequenceNumbers,
+	acsInstanceStatus *acshandler.InstanceStatus) int {
 
 	acsSession := acshandler.NewSession(
 		agent.ctx,
@@ -594,6 +662,9 @@ func (agent *ecsAgent) startACSSession(
 		taskEngine,
 		credentialsManager,
 		taskHandler,
+		acsConnectionStatus,
+		acsSequenceNumbers,
+		acsInstanceStatus,
 	)
 	seelog.Info("Beginning Polling for updates")
 	err := acsSession.Start()
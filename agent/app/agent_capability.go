@@ -14,6 +14,9 @@
 package app
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/ecs_client/model/ecs"
@@ -32,13 +35,21 @@ const (
 	capabilityTaskIAMRoleNetHost                = "task-iam-role-network-host"
 	taskENIAttributeSuffix                      = "task-eni"
 	taskENIBlockInstanceMetadataAttributeSuffix = "task-eni-block-instance-metadata"
+	taskENIEgressBandwidthLimitAttributeSuffix  = "task-eni-egress-bandwidth-limit"
 	cniPluginVersionSuffix                      = "cni-plugin-version"
+	appMeshAttributeSuffix                      = "aws-appmesh"
 	capabilityTaskCPUMemLimit                   = "task-cpu-mem-limit"
 	capabilityDockerPluginInfix                 = "docker-plugin."
 	attributeSeparator                          = "."
 	capabilityPrivateRegistryAuthASM            = "private-registry-authentication.secretsmanager"
 	capabilitySecretEnvSSM                      = "secrets.ssm.environment-variables"
 	capabiltyPIDAndIPCNamespaceSharing          = "pid-ipc-namespace-sharing"
+	capabilityLinuxCapabilities                 = "linux-capabilities"
+	capabilityContainerDevices                  = "container-devices"
+	capabilityInitProcess                       = "init-process"
+	gpuCountAttributeSuffix                     = "gpu-count"
+	gpuIDsAttributeSuffix                       = "gpu-ids"
+	dockerAPIVersionAttributeSuffix             = "docker-api-version"
 )
 
 // capabilities returns the supported capabilities of this agent / docker-client pair.
@@ -63,11 +74,20 @@ const (
 //    ecs.capability.docker-volume-driver.${driverName}
 //    ecs.capability.task-eni
 //    ecs.capability.task-eni-block-instance-metadata
+//    ecs.capability.task-eni-egress-bandwidth-limit
+//    ecs.capability.aws-appmesh
 //    ecs.capability.execution-role-ecr-pull
 //    ecs.capability.execution-role-awslogs
 //    ecs.capability.container-health-check
+//    ecs.capability.init-process
 //    ecs.capability.private-registry-authentication.secretsmanager
 //    ecs.capability.secrets.ssm.environment-variables
+//    ecs.capability.pid-ipc-namespace-sharing
+//    ecs.capability.linux-capabilities
+//    ecs.capability.container-devices
+//    ecs.capability.gpu-count
+//    ecs.capability.gpu-ids
+//    ecs.capability.docker-api-version
 
 func (agent *ecsAgent) capabilities() ([]*ecs.Attribute, error) {
 	var capabilities []*ecs.Attribute
@@ -122,9 +142,37 @@ func (agent *ecsAgent) capabilities() ([]*ecs.Attribute, error) {
 	// with host EC2 instance and among containers within the task
 	capabilities = appendNameOnlyAttribute(capabilities, attributePrefix+capabiltyPIDAndIPCNamespaceSharing)
 
+	// ecs agent supports adding and dropping the linux capabilities of containers
+	capabilities = appendNameOnlyAttribute(capabilities, attributePrefix+capabilityLinuxCapabilities)
+
+	// ecs agent supports exposing host devices to containers
+	capabilities = appendNameOnlyAttribute(capabilities, attributePrefix+capabilityContainerDevices)
+
+	capabilities = agent.appendGPUAttributes(capabilities)
+
+	capabilities = appendDockerAPIVersionAttribute(capabilities, supportedVersions)
+
 	return capabilities, nil
 }
 
+// appendGPUAttributes appends the number and the IDs of the GPUs the task
+// engine assigns to containers. The IDs are separated by spaces, as attribute
+// values can't contain commas
+func (agent *ecsAgent) appendGPUAttributes(capabilities []*ecs.Attribute) []*ecs.Attribute {
+	if !agent.cfg.GPUSupportEnabled || len(agent.cfg.GPUIDs) == 0 {
+		return capabilities
+	}
+	return append(capabilities,
+		&ecs.Attribute{
+			Name:  aws.String(attributePrefix + gpuCountAttributeSuffix),
+			Value: aws.String(strconv.Itoa(len(agent.cfg.GPUIDs))),
+		},
+		&ecs.Attribute{
+			Name:  aws.String(attributePrefix + gpuIDsAttributeSuffix),
+			Value: aws.String(strings.Join(agent.cfg.GPUIDs, " ")),
+		})
+}
+
 func (agent *ecsAgent) appendDockerDependentCapabilities(capabilities []*ecs.Attribute,
 	supportedVersions map[dockerclient.DockerVersion]bool) []*ecs.Attribute {
 	if _, ok := supportedVersions[dockerclient.Version_1_19]; ok {
@@ -136,9 +184,43 @@ func (agent *ecsAgent) appendDockerDependentCapabilities(capabilities []*ecs.Att
 		// Docker health check was added in API 1.24
 		capabilities = appendNameOnlyAttribute(capabilities, attributePrefix+"container-health-check")
 	}
+
+	if _, ok := supportedVersions[dockerclient.Version_1_25]; ok {
+		// Docker init process was added in API 1.25
+		capabilities = appendNameOnlyAttribute(capabilities, attributePrefix+capabilityInitProcess)
+	}
 	return capabilities
 }
 
+// appendDockerAPIVersionAttribute appends the attribute with the Docker API
+// version negotiated with the Docker daemon, which is the highest of the
+// supported versions, so that tasks can be placed on instances depending on it
+func appendDockerAPIVersionAttribute(capabilities []*ecs.Attribute,
+	supportedVersions map[dockerclient.DockerVersion]bool) []*ecs.Attribute {
+	var negotiatedVersion dockerclient.DockerVersion
+	for version := range supportedVersions {
+		if negotiatedVersion == "" {
+			negotiatedVersion = version
+			continue
+		}
+		higher, err := dockerclient.DockerAPIVersion(version).Matches(">" + string(negotiatedVersion))
+		if err != nil {
+			seelog.Warnf("Unable to compare Docker API versions %s and %s: %v", version, negotiatedVersion, err)
+			continue
+		}
+		if higher {
+			negotiatedVersion = version
+		}
+	}
+	if negotiatedVersion == "" {
+		return capabilities
+	}
+	return append(capabilities, &ecs.Attribute{
+		Name:  aws.String(attributePrefix + dockerAPIVersionAttributeSuffix),
+		Value: aws.String(string(negotiatedVersion)),
+	})
+}
+
 func (agent *ecsAgent) appendLoggingDriverCapabilities(capabilities []*ecs.Attribute) []*ecs.Attribute {
 	knownVersions := make(map[dockerclient.DockerVersion]struct{})
 	// Determine known API versions. Known versions are used exclusively for logging-driver enablement, since none of
@@ -208,6 +290,17 @@ func (agent *ecsAgent) appendTaskENICapabilities(capabilities []*ecs.Attribute)
 			return capabilities
 		}
 		capabilities = append(capabilities, taskENIVersionAttribute)
+		// The egress traffic of tasks is shaped by the agent, limits are
+		// ignored on kernels without the support of the queueing discipline
+		capabilities = appendNameOnlyAttribute(capabilities, attributePrefix+taskENIEgressBandwidthLimitAttributeSuffix)
+		// The proxy configuration of tasks is only supported when the app
+		// mesh plugin is installed along with the other plugins
+		if _, err := agent.cniClient.Version(ecscni.ECSAppMeshPluginName); err == nil {
+			capabilities = appendNameOnlyAttribute(capabilities, attributePrefix+appMeshAttributeSuffix)
+		} else {
+			seelog.Infof("App Mesh plugin '%s' unavailable, proxy configurations are not supported: %v",
+				ecscni.ECSAppMeshPluginName, err)
+		}
 		// We only care about AWSVPCBlockInstanceMetdata if Task ENI is enabled
 		if agent.cfg.AWSVPCBlockInstanceMetdata {
 			// If the Block Instance Metadata flag is set for AWS VPC networking mode, register a capability
@@ -74,6 +74,7 @@ func TestCapabilities(t *testing.T) {
 			dockerclient.Version_1_19,
 		}),
 		cniClient.EXPECT().Version(ecscni.ECSENIPluginName).Return("v1", nil),
+		cniClient.EXPECT().Version(ecscni.ECSAppMeshPluginName).Return("v1", nil),
 		mockMobyPlugins.EXPECT().Scan().AnyTimes().Return([]string{}, nil),
 		client.EXPECT().ListPluginsWithFilters(gomock.Any(), gomock.Any(), gomock.Any(),
 			gomock.Any()).AnyTimes().Return([]string{}, nil),
@@ -102,6 +103,12 @@ func TestCapabilities(t *testing.T) {
 				Name:  aws.String(attributePrefix + cniPluginVersionSuffix),
 				Value: aws.String("v1"),
 			},
+			{
+				Name: aws.String(attributePrefix + taskENIEgressBandwidthLimitAttributeSuffix),
+			},
+			{
+				Name: aws.String(attributePrefix + appMeshAttributeSuffix),
+			},
 			{
 				Name: aws.String(attributePrefix + taskENIBlockInstanceMetadataAttributeSuffix),
 			},
@@ -114,6 +121,19 @@ func TestCapabilities(t *testing.T) {
 			{
 				Name: aws.String(attributePrefix + capabilitySecretEnvSSM),
 			},
+			{
+				Name: aws.String(attributePrefix + capabiltyPIDAndIPCNamespaceSharing),
+			},
+			{
+				Name: aws.String(attributePrefix + capabilityLinuxCapabilities),
+			},
+			{
+				Name: aws.String(attributePrefix + capabilityContainerDevices),
+			},
+			{
+				Name:  aws.String(attributePrefix + dockerAPIVersionAttributeSuffix),
+				Value: aws.String(string(dockerclient.Version_1_18)),
+			},
 		}...)
 
 	ctx, cancel := context.WithCancel(context.TODO())
@@ -130,6 +150,7 @@ func TestCapabilities(t *testing.T) {
 	capabilities, err := agent.capabilities()
 	assert.NoError(t, err)
 
+	require.Len(t, capabilities, len(expectedCapabilities))
 	for i, expected := range expectedCapabilities {
 		assert.Equal(t, aws.StringValue(expected.Name), aws.StringValue(capabilities[i].Name))
 		assert.Equal(t, aws.StringValue(expected.Value), aws.StringValue(capabilities[i].Value))
@@ -590,6 +611,94 @@ func TestCapabilitiesContainerHealth(t *testing.T) {
 	assert.True(t, ok, "Could not find container health check capability when expected; got capabilities %v", capabilities)
 }
 
+func TestCapabilitiesInitProcess(t *testing.T) {
+	testCases := []struct {
+		version   dockerclient.DockerVersion
+		supported bool
+	}{
+		{
+			version:   dockerclient.Version_1_24,
+			supported: false,
+		},
+		{
+			version:   dockerclient.Version_1_25,
+			supported: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.version), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			client := mock_dockerapi.NewMockDockerClient(ctrl)
+			mockMobyPlugins := mock_mobypkgwrapper.NewMockPlugins(ctrl)
+
+			client.EXPECT().SupportedVersions().Return([]dockerclient.DockerVersion{tc.version})
+			client.EXPECT().KnownVersions().Return(nil)
+			mockMobyPlugins.EXPECT().Scan().AnyTimes().Return([]string{}, nil)
+			client.EXPECT().ListPluginsWithFilters(gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any()).AnyTimes().Return([]string{}, nil)
+
+			ctx, cancel := context.WithCancel(context.TODO())
+			// Cancel the context to cancel async routines
+			defer cancel()
+			agent := &ecsAgent{
+				ctx:          ctx,
+				cfg:          &config.Config{},
+				dockerClient: client,
+				mobyPlugins:  mockMobyPlugins,
+			}
+
+			capabilities, err := agent.capabilities()
+			require.NoError(t, err)
+
+			capMap := make(map[string]bool)
+			for _, capability := range capabilities {
+				capMap[aws.StringValue(capability.Name)] = true
+			}
+			assert.Equal(t, tc.supported, capMap["ecs.capability.init-process"])
+		})
+	}
+}
+
+func TestCapabilitiesGPU(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	mockMobyPlugins := mock_mobypkgwrapper.NewMockPlugins(ctrl)
+
+	client.EXPECT().SupportedVersions().Return(nil)
+	client.EXPECT().KnownVersions().Return(nil)
+	mockMobyPlugins.EXPECT().Scan().AnyTimes().Return([]string{}, nil)
+	client.EXPECT().ListPluginsWithFilters(gomock.Any(), gomock.Any(), gomock.Any(),
+		gomock.Any()).AnyTimes().Return([]string{}, nil)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	// Cancel the context to cancel async routines
+	defer cancel()
+	agent := &ecsAgent{
+		ctx: ctx,
+		cfg: &config.Config{
+			GPUSupportEnabled: true,
+			GPUIDs:            []string{"0", "1"},
+		},
+		dockerClient: client,
+		mobyPlugins:  mockMobyPlugins,
+	}
+
+	capabilities, err := agent.capabilities()
+	require.NoError(t, err)
+
+	attributes := make(map[string]string)
+	for _, capability := range capabilities {
+		attributes[aws.StringValue(capability.Name)] = aws.StringValue(capability.Value)
+	}
+	assert.Equal(t, "2", attributes["ecs.capability.gpu-count"])
+	assert.Equal(t, "0 1", attributes["ecs.capability.gpu-ids"])
+}
+
 func TestCapabilitesListPluginsErrorCase(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
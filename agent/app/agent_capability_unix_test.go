@@ -16,6 +16,7 @@
 package app
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/aws/amazon-ecs-agent/agent/ecs_client/model/ecs"
@@ -70,6 +71,7 @@ func TestVolumeDriverCapabilitiesUnix(t *testing.T) {
 			dockerclient.Version_1_19,
 		}),
 		cniClient.EXPECT().Version(ecscni.ECSENIPluginName).Return("v1", nil),
+		cniClient.EXPECT().Version(ecscni.ECSAppMeshPluginName).Return("", errors.New("not found")),
 		mockMobyPlugins.EXPECT().Scan().Return([]string{"fancyvolumedriver"}, nil),
 		client.EXPECT().ListPluginsWithFilters(gomock.Any(), gomock.Any(), gomock.Any(),
 			gomock.Any()).Return(
@@ -99,6 +101,9 @@ func TestVolumeDriverCapabilitiesUnix(t *testing.T) {
 				Name:  aws.String(attributePrefix + cniPluginVersionSuffix),
 				Value: aws.String("v1"),
 			},
+			{
+				Name: aws.String(attributePrefix + taskENIEgressBandwidthLimitAttributeSuffix),
+			},
 			{
 				Name: aws.String(attributePrefix + taskENIBlockInstanceMetadataAttributeSuffix),
 			},
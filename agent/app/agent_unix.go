@@ -16,6 +16,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -93,10 +94,11 @@ func (agent *ecsAgent) initializeTaskENIDependencies(state dockerstate.TaskEngin
 		return err, false
 	}
 
-	if err := agent.startUdevWatcher(state, taskEngine.StateChangeEvents()); err != nil {
-		// If udev watcher was not initialized in this run because of the udev socket
-		// file not being available etc, the Agent might be able to retry and succeed
-		// on the next run. Hence, returning a false here for terminal bool
+	if err := agent.startENIWatcher(state, taskEngine.StateChangeEvents()); err != nil {
+		// If the eni watcher was not initialized in this run because of the
+		// network interfaces not being listable etc, the Agent might be able
+		// to retry and succeed on the next run. Hence, returning a false here
+		// for terminal bool
 		return err, false
 	}
 
@@ -162,16 +164,12 @@ func (agent *ecsAgent) verifyCNIPluginsCapabilities() error {
 	return nil
 }
 
-// startUdevWatcher starts the udev monitor and the watcher for receiving
-// notifications from the monitor
-func (agent *ecsAgent) startUdevWatcher(state dockerstate.TaskEngineState, stateChangeEvents chan<- statechange.Event) error {
+// startENIWatcher starts the watcher of the ENIs attached to the instance. The
+// udev monitor is used to be notified of the ENIs if it's available, and the
+// link updates of netlink otherwise
+func (agent *ecsAgent) startENIWatcher(state dockerstate.TaskEngineState, stateChangeEvents chan<- statechange.Event) error {
 	seelog.Debug("Setting up ENI Watcher")
-	udevMonitor, err := udevwrapper.New()
-	if err != nil {
-		return errors.Wrapf(err, "unable to create udev monitor")
-	}
-	// Create Watcher
-	eniWatcher := watcher.New(agent.ctx, agent.mac, udevMonitor, state, stateChangeEvents)
+	eniWatcher := newENIWatcher(agent.ctx, agent.mac, state, stateChangeEvents)
 	if err := eniWatcher.Init(); err != nil {
 		return errors.Wrapf(err, "unable to initialize eni watcher")
 	}
@@ -179,6 +177,18 @@ func (agent *ecsAgent) startUdevWatcher(state dockerstate.TaskEngineState, state
 	return nil
 }
 
+// newENIWatcher creates the watcher of the ENIs attached to the instance. It's
+// replaced in the tests so that they don't subscribe to the events of the host
+var newENIWatcher = func(ctx context.Context, primaryMAC string,
+	state dockerstate.TaskEngineState, stateChangeEvents chan<- statechange.Event) watcher.ENIWatcher {
+	udevMonitor, err := udevwrapper.New()
+	if err != nil {
+		seelog.Warnf("Unable to create udev monitor, watching ENIs with netlink instead: %v", err)
+		return watcher.NewNetlinkWatcher(ctx, primaryMAC, state, stateChangeEvents)
+	}
+	return watcher.New(ctx, primaryMAC, udevMonitor, state, stateChangeEvents)
+}
+
 func contains(capabilities []string, capability string) bool {
 	for _, cap := range capabilities {
 		if cap == capability {
@@ -34,12 +34,15 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/ecscni"
 	"github.com/aws/amazon-ecs-agent/agent/ecscni/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/engine/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/eni/pause"
 	"github.com/aws/amazon-ecs-agent/agent/eni/pause/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/eni/watcher"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/cgroup/control/mock_control"
@@ -56,6 +59,24 @@ const (
 	subnetID = "subnet-1234"
 )
 
+// noopENIWatcher is an ENI watcher that doesn't watch the ENIs of the host
+type noopENIWatcher struct{}
+
+func (noopENIWatcher) Init() error { return nil }
+func (noopENIWatcher) Start()      {}
+func (noopENIWatcher) Stop()       {}
+
+// useNoopENIWatcher replaces the ENI watcher created by the agent until the
+// returned function is invoked
+func useNoopENIWatcher() func() {
+	original := newENIWatcher
+	newENIWatcher = func(ctx context.Context, primaryMAC string,
+		state dockerstate.TaskEngineState, stateChangeEvents chan<- statechange.Event) watcher.ENIWatcher {
+		return noopENIWatcher{}
+	}
+	return func() { newENIWatcher = original }
+}
+
 func TestDoStartHappyPath(t *testing.T) {
 	ctrl, credentialsManager, state, imageManager, client,
 		dockerClient, _, _ := setup(t)
@@ -70,6 +91,7 @@ func TestDoStartHappyPath(t *testing.T) {
 	// These calls are expected to happen, but cannot be ordered as they are
 	// invoked via go routines, which will lead to occasional test failues
 	dockerClient.EXPECT().Version(gomock.Any(), gomock.Any()).AnyTimes()
+	dockerClient.EXPECT().Ping(gomock.Any(), gomock.Any()).AnyTimes()
 	dockerClient.EXPECT().SupportedVersions().Return(apiVersions)
 	imageManager.EXPECT().StartImageCleanupProcess(gomock.Any()).MaxTimes(1)
 	mockCredentialsProvider.EXPECT().IsExpired().Return(false).AnyTimes()
@@ -127,6 +149,7 @@ func TestDoStartTaskENIHappyPath(t *testing.T) {
 	ctrl, credentialsManager, state, imageManager, client,
 		dockerClient, _, _ := setup(t)
 	defer ctrl.Finish()
+	defer useNoopENIWatcher()()
 
 	cniCapabilities := []string{ecscni.CapabilityAWSVPCNetworkingMode}
 	containerChangeEvents := make(chan dockerapi.DockerContainerChangeEvent)
@@ -145,6 +168,7 @@ func TestDoStartTaskENIHappyPath(t *testing.T) {
 	// invoked via go routines, which will lead to occasional test failues
 	mockCredentialsProvider.EXPECT().IsExpired().Return(false).AnyTimes()
 	dockerClient.EXPECT().Version(gomock.Any(), gomock.Any()).AnyTimes()
+	dockerClient.EXPECT().Ping(gomock.Any(), gomock.Any()).AnyTimes()
 	dockerClient.EXPECT().SupportedVersions().Return(apiVersions)
 	dockerClient.EXPECT().ListContainers(gomock.Any(), gomock.Any(), gomock.Any()).Return(
 		dockerapi.ListContainersResponse{}).AnyTimes()
@@ -175,6 +199,7 @@ func TestDoStartTaskENIHappyPath(t *testing.T) {
 		dockerClient.EXPECT().SupportedVersions().Return(nil),
 		dockerClient.EXPECT().KnownVersions().Return(nil),
 		cniClient.EXPECT().Version(ecscni.ECSENIPluginName).Return("v1", nil),
+		cniClient.EXPECT().Version(ecscni.ECSAppMeshPluginName).Return("", errors.New("not found")),
 		mockMobyPlugins.EXPECT().Scan().Return([]string{}, nil),
 		dockerClient.EXPECT().ListPluginsWithFilters(gomock.Any(), gomock.Any(), gomock.Any(),
 			gomock.Any()).Return([]string{}, nil),
@@ -228,6 +253,99 @@ func TestDoStartTaskENIHappyPath(t *testing.T) {
 	discoverEndpointsInvoked.Wait()
 }
 
+// TestDoStartTaskENIPauseImageNotFound tests that the Task ENI capability isn't
+// registered when the pause container image is neither present locally nor
+// bundled with the Agent
+func TestDoStartTaskENIPauseImageNotFound(t *testing.T) {
+	ctrl, credentialsManager, state, imageManager, client,
+		dockerClient, _, _ := setup(t)
+	defer ctrl.Finish()
+	defer useNoopENIWatcher()()
+
+	cniCapabilities := []string{ecscni.CapabilityAWSVPCNetworkingMode}
+	containerChangeEvents := make(chan dockerapi.DockerContainerChangeEvent)
+
+	cniClient := mock_ecscni.NewMockCNIClient(ctrl)
+	mockCredentialsProvider := app_mocks.NewMockProvider(ctrl)
+	mockPauseLoader := mock_pause.NewMockLoader(ctrl)
+	mockOS := mock_oswrapper.NewMockOS(ctrl)
+	mockMetadata := mock_ec2.NewMockEC2MetadataClient(ctrl)
+	mockMobyPlugins := mock_mobypkgwrapper.NewMockPlugins(ctrl)
+
+	var discoverEndpointsInvoked sync.WaitGroup
+	discoverEndpointsInvoked.Add(2)
+
+	mockCredentialsProvider.EXPECT().IsExpired().Return(false).AnyTimes()
+	dockerClient.EXPECT().Version(gomock.Any(), gomock.Any()).AnyTimes()
+	dockerClient.EXPECT().Ping(gomock.Any(), gomock.Any()).AnyTimes()
+	dockerClient.EXPECT().SupportedVersions().Return(apiVersions)
+	dockerClient.EXPECT().ListContainers(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		dockerapi.ListContainersResponse{}).AnyTimes()
+	imageManager.EXPECT().StartImageCleanupProcess(gomock.Any()).MaxTimes(1)
+	client.EXPECT().DiscoverPollEndpoint(gomock.Any()).Do(func(x interface{}) {
+		discoverEndpointsInvoked.Done()
+	}).Return("poll-endpoint", nil)
+	client.EXPECT().DiscoverPollEndpoint(gomock.Any()).Return("acs-endpoint", nil).AnyTimes()
+	client.EXPECT().DiscoverTelemetryEndpoint(gomock.Any()).Do(func(x interface{}) {
+		discoverEndpointsInvoked.Done()
+	}).Return("telemetry-endpoint", nil)
+	client.EXPECT().DiscoverTelemetryEndpoint(gomock.Any()).Return(
+		"tele-endpoint", nil).AnyTimes()
+
+	gomock.InOrder(
+		mockOS.EXPECT().Getpid().Return(10),
+		mockMetadata.EXPECT().PrimaryENIMAC().Return(mac, nil),
+		mockMetadata.EXPECT().VPCID(mac).Return(vpcID, nil),
+		mockMetadata.EXPECT().SubnetID(mac).Return(subnetID, nil),
+		cniClient.EXPECT().Capabilities(ecscni.ECSENIPluginName).Return(cniCapabilities, nil),
+		cniClient.EXPECT().Capabilities(ecscni.ECSBridgePluginName).Return(cniCapabilities, nil),
+		cniClient.EXPECT().Capabilities(ecscni.ECSIPAMPluginName).Return(cniCapabilities, nil),
+		mockPauseLoader.EXPECT().LoadImage(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+			nil, pause.NewNoSuchFileError(errors.New("not found"))),
+		mockCredentialsProvider.EXPECT().Retrieve().Return(credentials.Value{}, nil),
+		dockerClient.EXPECT().SupportedVersions().Return(nil),
+		dockerClient.EXPECT().KnownVersions().Return(nil),
+		mockMobyPlugins.EXPECT().Scan().Return([]string{}, nil),
+		dockerClient.EXPECT().ListPluginsWithFilters(gomock.Any(), gomock.Any(), gomock.Any(),
+			gomock.Any()).Return([]string{}, nil),
+		client.EXPECT().RegisterContainerInstance(gomock.Any(), gomock.Any(), gomock.Any()).Do(
+			func(x interface{}, attributes []*ecs.Attribute, y interface{}) {
+				for _, attribute := range attributes {
+					assert.NotEqual(t, attributePrefix+taskENIAttributeSuffix, aws.StringValue(attribute.Name))
+					assert.NotEqual(t, vpcIDAttributeName, aws.StringValue(attribute.Name))
+				}
+			}).Return("arn", nil),
+		imageManager.EXPECT().SetSaver(gomock.Any()),
+		dockerClient.EXPECT().ContainerEvents(gomock.Any()).Return(containerChangeEvents, nil),
+		state.EXPECT().AllImageStates().Return(nil),
+		state.EXPECT().AllTasks().Return(nil),
+	)
+
+	cfg := getTestConfig()
+	cfg.TaskENIEnabled = true
+	ctx, cancel := context.WithCancel(context.TODO())
+	// Cancel the context to cancel async routines
+	defer cancel()
+	agent := &ecsAgent{
+		ctx:                ctx,
+		cfg:                &cfg,
+		credentialProvider: credentials.NewCredentials(mockCredentialsProvider),
+		dockerClient:       dockerClient,
+		pauseLoader:        mockPauseLoader,
+		cniClient:          cniClient,
+		os:                 mockOS,
+		ec2MetadataClient:  mockMetadata,
+		terminationHandler: func(saver statemanager.Saver, taskEngine engine.TaskEngine) {},
+		mobyPlugins:        mockMobyPlugins,
+	}
+
+	go agent.doStart(eventstream.NewEventStream("events", ctx),
+		credentialsManager, state, imageManager, client)
+
+	discoverEndpointsInvoked.Wait()
+	assert.False(t, cfg.TaskENIEnabled)
+}
+
 func TestSetVPCSubnetHappyPath(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -479,6 +597,7 @@ func TestDoStartCgroupInitHappyPath(t *testing.T) {
 	containerChangeEvents := make(chan dockerapi.DockerContainerChangeEvent)
 
 	dockerClient.EXPECT().Version(gomock.Any(), gomock.Any()).AnyTimes()
+	dockerClient.EXPECT().Ping(gomock.Any(), gomock.Any()).AnyTimes()
 	dockerClient.EXPECT().SupportedVersions().Return(apiVersions)
 	imageManager.EXPECT().StartImageCleanupProcess(gomock.Any()).MaxTimes(1)
 	mockCredentialsProvider.EXPECT().IsExpired().Return(false).AnyTimes()
@@ -507,9 +626,10 @@ func TestDoStartCgroupInitHappyPath(t *testing.T) {
 		}).Return("telemetry-endpoint", nil),
 		client.EXPECT().DiscoverTelemetryEndpoint(gomock.Any()).Return(
 			"tele-endpoint", nil).AnyTimes(),
-		dockerClient.EXPECT().ListContainers(gomock.Any(), gomock.Any(), gomock.Any()).Return(
-			dockerapi.ListContainersResponse{}).AnyTimes(),
 	)
+	dockerClient.EXPECT().ListContainers(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		dockerapi.ListContainersResponse{}).AnyTimes()
+	dockerClient.EXPECT().Ping(gomock.Any(), gomock.Any()).AnyTimes()
 
 	cfg := config.DefaultConfig()
 	ctx, cancel := context.WithCancel(context.TODO())
@@ -548,6 +668,7 @@ func TestDoStartCgroupInitErrorPath(t *testing.T) {
 	discoverEndpointsInvoked.Add(2)
 
 	dockerClient.EXPECT().Version(gomock.Any(), gomock.Any()).AnyTimes()
+	dockerClient.EXPECT().Ping(gomock.Any(), gomock.Any()).AnyTimes()
 	dockerClient.EXPECT().SupportedVersions().Return(apiVersions)
 	imageManager.EXPECT().StartImageCleanupProcess(gomock.Any()).MaxTimes(1)
 	mockCredentialsProvider.EXPECT().IsExpired().Return(false).AnyTimes()
@@ -43,7 +43,14 @@ func GetDockerAuthFromASM(secretID string, client secretsmanageriface.SecretsMan
 			"asm fetching secret from the service for %s", secretID)
 	}
 
-	return extractASMValue(out)
+	dac, err := extractASMValue(out)
+	if err != nil {
+		// The secret value is never part of the error, as it ends up in the
+		// stop reason of the task
+		return docker.AuthConfiguration{}, errors.Wrapf(err,
+			"asm invalid registry credentials in secret %s", secretID)
+	}
+	return dac, nil
 }
 
 func extractASMValue(out *secretsmanager.GetSecretValueOutput) (docker.AuthConfiguration, error) {
@@ -103,6 +103,7 @@ func TestASMGetAuthConfig(t *testing.T) {
 
 			if c.ShouldError {
 				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "secret-value-id")
 			} else {
 				assert.NoError(t, err)
 			}
@@ -27,6 +27,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/ec2"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
+	"github.com/aws/amazon-ecs-agent/agent/utils/cipher"
 	"github.com/cihub/seelog"
 )
 
@@ -57,6 +58,18 @@ const (
 	// clean up task's containers.
 	DefaultTaskCleanupWaitDuration = 3 * time.Hour
 
+	// defaultOrphanCleanupWaitDuration specifies the default value for the duration to wait before
+	// cleaning up the containers of tasks missing from the agent's state.
+	defaultOrphanCleanupWaitDuration = 10 * time.Minute
+
+	// defaultDockerResourcesCleanupInterval specifies the default value for the time between the
+	// sweeps of the docker networks and volumes of tasks missing from the agent's state.
+	defaultDockerResourcesCleanupInterval = 1 * time.Hour
+
+	// defaultTaskCredentialsRefreshWindow specifies the default value for how long before
+	// their expiration the refresh of task IAM role credentials is requested.
+	defaultTaskCredentialsRefreshWindow = 10 * time.Minute
+
 	// defaultDockerStopTimeout specifies the value for container stop timeout duration
 	defaultDockerStopTimeout = 30 * time.Second
 
@@ -68,6 +81,12 @@ const (
 	// image cleanup.
 	DefaultNumImagesToDeletePerCycle = 5
 
+	// DefaultImagePullConcurrency specifies the default number of images pulled at the same time.
+	DefaultImagePullConcurrency = 3
+
+	// DefaultImagePullAttempts specifies the default number of attempts made to pull an image.
+	DefaultImagePullAttempts = 10
+
 	// DefaultImageDeletionAge specifies the default value for minimum amount of elapsed time after an image
 	// has been pulled before it can be deleted.
 	DefaultImageDeletionAge = 1 * time.Hour
@@ -87,10 +106,29 @@ const (
 	// image cleanup.
 	minimumImageCleanupInterval = 10 * time.Minute
 
+	// minimumDockerResourcesCleanupInterval specifies the minimum time between the sweeps of
+	// the docker networks and volumes of tasks missing from the agent's state.
+	minimumDockerResourcesCleanupInterval = 10 * time.Minute
+
+	// minimumTaskCredentialsRefreshWindow specifies the minimum value for how long before
+	// their expiration the refresh of task IAM role credentials is requested.
+	minimumTaskCredentialsRefreshWindow = 1 * time.Minute
+
+	// minimumImagePullConcurrency specifies the minimum number of images pulled at the same time.
+	minimumImagePullConcurrency = 1
+
+	// minimumImagePullAttempts specifies the minimum number of attempts made to pull an image.
+	minimumImagePullAttempts = 1
+
 	// minimumNumImagesToDeletePerCycle specifies the minimum number of images that to be deleted when
 	// performing image cleanup.
 	minimumNumImagesToDeletePerCycle = 1
 
+	// defaultImageCleanupDiskUsageThresholdGap specifies how far below the
+	// high threshold the disk usage low threshold of image cleanup is when it
+	// isn't configured
+	defaultImageCleanupDiskUsageThresholdGap = 10
+
 	// defaultCNIPluginsPath is the default path where cni binaries are located
 	defaultCNIPluginsPath = "/amazon-ecs-cni-plugins"
 
@@ -107,6 +145,28 @@ const (
 
 	// DefaultTaskMetadataBurstRate is set to handle 60 burst requests at once
 	DefaultTaskMetadataBurstRate = 60
+
+	// DefaultTaskCredentialsSteadyStateRate is the steady state throttle of
+	// the credentials endpoint, which is higher than the one of the other task
+	// metadata paths, so that containers polling their metadata can't starve
+	// the credentials of the task
+	DefaultTaskCredentialsSteadyStateRate = 100
+
+	// DefaultTaskCredentialsBurstRate is set to handle 150 burst requests for
+	// credentials at once
+	DefaultTaskCredentialsBurstRate = 150
+
+	// DefaultIntrospectionSteadyStateRate is the steady state throttle of the
+	// introspection endpoint
+	DefaultIntrospectionSteadyStateRate = 100
+
+	// DefaultIntrospectionBurstRate is set to handle 150 burst requests to
+	// the introspection endpoint at once
+	DefaultIntrospectionBurstRate = 150
+
+	// DefaultHTTPMaxConnections is the default number of concurrent
+	// connections to each of the task metadata and introspection endpoints
+	DefaultHTTPMaxConnections = 1024
 )
 
 const (
@@ -146,6 +206,11 @@ var (
 	// DefaultPauseContainerTag is the tag for the pause container image. The linker's load
 	// flags are used to populate this value from the Makefile
 	DefaultPauseContainerTag = ""
+
+	// DefaultPauseContainerImageID is the id of the pause container image bundled with
+	// the agent, which the loaded image is verified against. The linker's load flags are
+	// used to populate this value from the Makefile
+	DefaultPauseContainerImageID = ""
 )
 
 // Merge merges two config files, preferring the ones on the left. Any nil or
@@ -261,6 +326,17 @@ func (cfg *Config) validateAndOverrideBounds() error {
 	if cfg.ContainerStartTimeout < minimumContainerStartTimeout {
 		return fmt.Errorf("config: invalid value for docker container start timeout: %v", cfg.ContainerStartTimeout.String())
 	}
+	if cfg.WebsocketTLSMinVersion != "" {
+		if _, err := cipher.ParseTLSVersion(cfg.WebsocketTLSMinVersion); err != nil {
+			return fmt.Errorf("config: invalid value for websocket TLS minimum version: %v", err)
+		}
+	}
+	if cfg.WebsocketCABundlePath != "" {
+		if _, err := cipher.LoadCertPool(cfg.WebsocketCABundlePath); err != nil {
+			return fmt.Errorf("config: invalid websocket CA bundle: %v", err)
+		}
+	}
+
 	var badDrivers []string
 	for _, driver := range cfg.AvailableLoggingDrivers {
 		_, ok := dockerclient.LoggingDriverMinimumVersion[driver]
@@ -289,17 +365,77 @@ func (cfg *Config) validateAndOverrideBounds() error {
 		cfg.ImageCleanupInterval = DefaultImageCleanupTimeInterval
 	}
 
+	if cfg.DockerResourcesCleanupInterval < minimumDockerResourcesCleanupInterval {
+		seelog.Warnf("Invalid value for docker resources cleanup interval, will be overridden with the default value: %s. Parsed value: %v, minimum value: %v.", defaultDockerResourcesCleanupInterval.String(), cfg.DockerResourcesCleanupInterval, minimumDockerResourcesCleanupInterval)
+		cfg.DockerResourcesCleanupInterval = defaultDockerResourcesCleanupInterval
+	}
+
+	if cfg.TaskCredentialsRefreshWindow < minimumTaskCredentialsRefreshWindow {
+		seelog.Warnf("Invalid value for task credentials refresh window, will be overridden with the default value: %s. Parsed value: %v, minimum value: %v.", defaultTaskCredentialsRefreshWindow.String(), cfg.TaskCredentialsRefreshWindow, minimumTaskCredentialsRefreshWindow)
+		cfg.TaskCredentialsRefreshWindow = defaultTaskCredentialsRefreshWindow
+	}
+
 	if cfg.NumImagesToDeletePerCycle < minimumNumImagesToDeletePerCycle {
 		seelog.Warnf("Invalid value for number of images to delete for image cleanup, will be overridden with the default value: %d. Parsed value: %d, minimum value: %d.", DefaultImageDeletionAge, cfg.NumImagesToDeletePerCycle, minimumNumImagesToDeletePerCycle)
 		cfg.NumImagesToDeletePerCycle = DefaultNumImagesToDeletePerCycle
 	}
 
+	if cfg.ImageCleanupDiskUsageHighThreshold < 0 || cfg.ImageCleanupDiskUsageHighThreshold > 100 {
+		seelog.Warnf("Invalid value for disk usage threshold of image cleanup, image cleanup by disk usage will be disabled. Parsed value: %d, expected a percentage between 1 and 100.", cfg.ImageCleanupDiskUsageHighThreshold)
+		cfg.ImageCleanupDiskUsageHighThreshold = 0
+	}
+	if cfg.ImageCleanupDiskUsageHighThreshold > 0 &&
+		(cfg.ImageCleanupDiskUsageLowThreshold < 1 || cfg.ImageCleanupDiskUsageLowThreshold >= cfg.ImageCleanupDiskUsageHighThreshold) {
+		lowThreshold := cfg.ImageCleanupDiskUsageHighThreshold - defaultImageCleanupDiskUsageThresholdGap
+		if lowThreshold < 0 {
+			lowThreshold = 0
+		}
+		if cfg.ImageCleanupDiskUsageLowThreshold != 0 {
+			seelog.Warnf("Invalid value for disk usage low threshold of image cleanup, will be overridden with %d. Parsed value: %d, expected a percentage below the high threshold %d.", lowThreshold, cfg.ImageCleanupDiskUsageLowThreshold, cfg.ImageCleanupDiskUsageHighThreshold)
+		}
+		cfg.ImageCleanupDiskUsageLowThreshold = lowThreshold
+	}
+
+	if cfg.ImagePullConcurrency < minimumImagePullConcurrency {
+		seelog.Warnf("Invalid value for number of images pulled at the same time, will be overridden with the default value: %d. Parsed value: %d, minimum value: %d.", DefaultImagePullConcurrency, cfg.ImagePullConcurrency, minimumImagePullConcurrency)
+		cfg.ImagePullConcurrency = DefaultImagePullConcurrency
+	}
+
+	if cfg.ImagePullAttempts < minimumImagePullAttempts {
+		seelog.Warnf("Invalid value for number of image pull attempts, will be overridden with the default value: %d. Parsed value: %d, minimum value: %d.", DefaultImagePullAttempts, cfg.ImagePullAttempts, minimumImagePullAttempts)
+		cfg.ImagePullAttempts = DefaultImagePullAttempts
+	}
+
 	if cfg.TaskMetadataSteadyStateRate <= 0 || cfg.TaskMetadataBurstRate <= 0 {
 		seelog.Warnf("Invalid values for rate limits, will be overridden with default values: %d,%d.", DefaultTaskMetadataSteadyStateRate, DefaultTaskMetadataBurstRate)
 		cfg.TaskMetadataSteadyStateRate = DefaultTaskMetadataSteadyStateRate
 		cfg.TaskMetadataBurstRate = DefaultTaskMetadataBurstRate
 	}
 
+	if cfg.TaskCredentialsSteadyStateRate <= 0 || cfg.TaskCredentialsBurstRate <= 0 {
+		seelog.Warnf("Invalid values for credentials rate limits, will be overridden with default values: %d,%d.", DefaultTaskCredentialsSteadyStateRate, DefaultTaskCredentialsBurstRate)
+		cfg.TaskCredentialsSteadyStateRate = DefaultTaskCredentialsSteadyStateRate
+		cfg.TaskCredentialsBurstRate = DefaultTaskCredentialsBurstRate
+	}
+
+	if cfg.IntrospectionSteadyStateRate <= 0 || cfg.IntrospectionBurstRate <= 0 {
+		seelog.Warnf("Invalid values for introspection rate limits, will be overridden with default values: %d,%d.", DefaultIntrospectionSteadyStateRate, DefaultIntrospectionBurstRate)
+		cfg.IntrospectionSteadyStateRate = DefaultIntrospectionSteadyStateRate
+		cfg.IntrospectionBurstRate = DefaultIntrospectionBurstRate
+	}
+
+	if cfg.HTTPMaxConnections <= 0 {
+		seelog.Warnf("Invalid value for the maximum number of http connections, will be overridden with the default value: %d. Parsed value: %d.", DefaultHTTPMaxConnections, cfg.HTTPMaxConnections)
+		cfg.HTTPMaxConnections = DefaultHTTPMaxConnections
+	}
+
+	if cfg.DynamicHostPortRangeStart > cfg.DynamicHostPortRangeEnd ||
+		(cfg.DynamicHostPortRangeStart == 0) != (cfg.DynamicHostPortRangeEnd == 0) {
+		seelog.Warnf("Invalid dynamic host port range, host ports will be picked by docker. Parsed range: %d-%d.", cfg.DynamicHostPortRangeStart, cfg.DynamicHostPortRangeEnd)
+		cfg.DynamicHostPortRangeStart = 0
+		cfg.DynamicHostPortRangeEnd = 0
+	}
+
 	cfg.platformOverrides()
 
 	return nil
@@ -428,6 +564,10 @@ func environmentConfig() (Config, error) {
 	dataDir := os.Getenv("ECS_DATADIR")
 
 	steadyStateRate, burstRate := parseTaskMetadataThrottles()
+	credentialsSteadyStateRate, credentialsBurstRate := parseTaskCredentialsThrottles()
+	introspectionSteadyStateRate, introspectionBurstRate := parseIntrospectionThrottles()
+
+	dynamicHostPortRangeStart, dynamicHostPortRangeEnd := parseDynamicHostPortRange()
 
 	var errs []error
 	instanceAttributes, errs := parseInstanceAttributes(errs)
@@ -451,6 +591,7 @@ func environmentConfig() (Config, error) {
 		Checkpoint:                         parseCheckpoint(dataDir),
 		EngineAuthType:                     os.Getenv("ECS_ENGINE_AUTH_TYPE"),
 		EngineAuthData:                     NewSensitiveRawMessage([]byte(os.Getenv("ECS_ENGINE_AUTH_DATA"))),
+		EngineAuthConfigFile:               os.Getenv("ECS_ENGINE_AUTH_CONFIG_FILE"),
 		UpdatesEnabled:                     utils.ParseBool(os.Getenv("ECS_UPDATES_ENABLED"), false),
 		UpdateDownloadDir:                  os.Getenv("ECS_UPDATE_DOWNLOAD_DIR"),
 		DisableMetrics:                     utils.ParseBool(os.Getenv("ECS_DISABLE_METRICS"), false),
@@ -460,6 +601,9 @@ func environmentConfig() (Config, error) {
 		SELinuxCapable:                     utils.ParseBool(os.Getenv("ECS_SELINUX_CAPABLE"), false),
 		AppArmorCapable:                    utils.ParseBool(os.Getenv("ECS_APPARMOR_CAPABLE"), false),
 		TaskCleanupWaitDuration:            parseEnvVariableDuration("ECS_ENGINE_TASK_CLEANUP_WAIT_DURATION"),
+		OrphanCleanupWaitDuration:          parseEnvVariableDuration("ECS_ORPHANED_CONTAINER_CLEANUP_WAIT_DURATION"),
+		DockerResourcesCleanupInterval:     parseEnvVariableDuration("ECS_DOCKER_RESOURCES_CLEANUP_INTERVAL"),
+		DockerResourcesCleanupDryRun:       utils.ParseBool(os.Getenv("ECS_DOCKER_RESOURCES_CLEANUP_DRY_RUN"), false),
 		TaskENIEnabled:                     utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_ENI"), false),
 		TaskIAMRoleEnabled:                 utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_IAM_ROLE"), false),
 		TaskCPUMemLimit:                    parseTaskCPUMemLimitEnabled(),
@@ -469,11 +613,17 @@ func environmentConfig() (Config, error) {
 		CredentialsAuditLogFile:            os.Getenv("ECS_AUDIT_LOGFILE"),
 		CredentialsAuditLogDisabled:        utils.ParseBool(os.Getenv("ECS_AUDIT_LOGFILE_DISABLED"), false),
 		TaskIAMRoleEnabledForNetworkHost:   utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_IAM_ROLE_NETWORK_HOST"), false),
+		TaskCredentialsRefreshWindow:       parseEnvVariableDuration("ECS_TASK_CREDENTIALS_REFRESH_WINDOW"),
 		ImageCleanupDisabled:               utils.ParseBool(os.Getenv("ECS_DISABLE_IMAGE_CLEANUP"), false),
 		MinimumImageDeletionAge:            parseEnvVariableDuration("ECS_IMAGE_MINIMUM_CLEANUP_AGE"),
 		ImageCleanupInterval:               parseEnvVariableDuration("ECS_IMAGE_CLEANUP_INTERVAL"),
 		NumImagesToDeletePerCycle:          parseNumImagesToDeletePerCycle(),
+		ImageCleanupDiskUsageHighThreshold: parseEnvVariableInt("ECS_IMAGE_CLEANUP_DISK_USAGE_HIGH_THRESHOLD"),
+		ImageCleanupDiskUsageLowThreshold:  parseEnvVariableInt("ECS_IMAGE_CLEANUP_DISK_USAGE_LOW_THRESHOLD"),
+		ImageCleanupExclusionList:          parseImageCleanupExclusionList(),
 		ImagePullBehavior:                  parseImagePullBehavior(),
+		ImagePullConcurrency:               parseImagePullConcurrency(),
+		ImagePullAttempts:                  parseImagePullAttempts(),
 		InstanceAttributes:                 instanceAttributes,
 		CNIPluginsPath:                     os.Getenv("ECS_CNI_PLUGINS_PATH"),
 		AWSVPCBlockInstanceMetdata:         utils.ParseBool(os.Getenv("ECS_AWSVPC_BLOCK_IMDS"), false),
@@ -484,9 +634,24 @@ func environmentConfig() (Config, error) {
 		CgroupPath:                         os.Getenv("ECS_CGROUP_PATH"),
 		TaskMetadataSteadyStateRate:        steadyStateRate,
 		TaskMetadataBurstRate:              burstRate,
+		TaskCredentialsSteadyStateRate:     credentialsSteadyStateRate,
+		TaskCredentialsBurstRate:           credentialsBurstRate,
+		IntrospectionSteadyStateRate:       introspectionSteadyStateRate,
+		IntrospectionBurstRate:             introspectionBurstRate,
+		HTTPMaxConnections:                 parseEnvVariableInt("ECS_HTTP_MAX_CONNECTIONS"),
 		SharedVolumeMatchFullConfig:        utils.ParseBool(os.Getenv("ECS_SHARED_VOLUME_MATCH_FULL_CONFIG"), false),
 		ContainerInstanceTags:              containerInstanceTags,
 		ContainerInstancePropagateTagsFrom: parseContainerInstancePropagateTagsFrom(),
+		StateChangeEventsSocketPath:        os.Getenv("ECS_STATE_CHANGE_EVENTS_SOCKET_PATH"),
+		GPUSupportEnabled:                  utils.ParseBool(os.Getenv("ECS_ENABLE_GPU_SUPPORT"), false),
+		TaskNetworksEnabled:                utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_NETWORKS"), false),
+		DynamicHostPortRangeStart:          dynamicHostPortRangeStart,
+		DynamicHostPortRangeEnd:            dynamicHostPortRangeEnd,
+		IntrospectionExecToken:             NewSensitiveRawMessage([]byte(os.Getenv("ECS_INTROSPECTION_EXEC_TOKEN"))),
+		PrometheusMetricsEnabled:           utils.ParseBool(os.Getenv("ECS_ENABLE_PROMETHEUS_METRICS"), false),
+		WebsocketProxy:                     os.Getenv("ECS_WEBSOCKET_PROXY"),
+		WebsocketTLSMinVersion:             os.Getenv("ECS_WEBSOCKET_TLS_MIN_VERSION"),
+		WebsocketCABundlePath:              os.Getenv("ECS_WEBSOCKET_CA_BUNDLE"),
 	}, err
 }
 
@@ -18,6 +18,7 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"os"
 	"testing"
 	"time"
@@ -29,6 +30,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMerge(t *testing.T) {
@@ -99,18 +101,39 @@ func TestEnvironmentConfig(t *testing.T) {
 	defer setTestEnv("ECS_APPARMOR_CAPABLE", "true")()
 	defer setTestEnv("ECS_DISABLE_PRIVILEGED", "true")()
 	defer setTestEnv("ECS_ENGINE_TASK_CLEANUP_WAIT_DURATION", "90s")()
+	defer setTestEnv("ECS_ORPHANED_CONTAINER_CLEANUP_WAIT_DURATION", "30m")()
+	defer setTestEnv("ECS_DOCKER_RESOURCES_CLEANUP_INTERVAL", "2h")()
+	defer setTestEnv("ECS_DOCKER_RESOURCES_CLEANUP_DRY_RUN", "true")()
+	defer setTestEnv("ECS_TASK_CREDENTIALS_REFRESH_WINDOW", "15m")()
 	defer setTestEnv("ECS_ENABLE_TASK_IAM_ROLE", "true")()
 	defer setTestEnv("ECS_ENABLE_TASK_IAM_ROLE_NETWORK_HOST", "true")()
 	defer setTestEnv("ECS_DISABLE_IMAGE_CLEANUP", "true")()
 	defer setTestEnv("ECS_IMAGE_CLEANUP_INTERVAL", "2h")()
 	defer setTestEnv("ECS_IMAGE_MINIMUM_CLEANUP_AGE", "30m")()
 	defer setTestEnv("ECS_NUM_IMAGES_DELETE_PER_CYCLE", "2")()
+	defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_USAGE_HIGH_THRESHOLD", "85")()
+	defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_USAGE_LOW_THRESHOLD", "70")()
+	defer setTestEnv("ECS_IMAGE_CLEANUP_EXCLUDE", `["amazonlinux:2", " internal/base-* ", "invalid["]`)()
+	defer setTestEnv("ECS_IMAGE_PULL_CONCURRENCY", "5")()
+	defer setTestEnv("ECS_IMAGE_PULL_ATTEMPTS", "4")()
 	defer setTestEnv("ECS_IMAGE_PULL_BEHAVIOR", "always")()
 	defer setTestEnv("ECS_INSTANCE_ATTRIBUTES", "{\"my_attribute\": \"testing\"}")()
 	defer setTestEnv("ECS_CONTAINER_INSTANCE_TAGS", `{"my_tag": "testing"}`)()
 	defer setTestEnv("ECS_ENABLE_TASK_ENI", "true")()
 	defer setTestEnv("ECS_TASK_METADATA_RPS_LIMIT", "1000,1100")()
+	defer setTestEnv("ECS_TASK_CREDENTIALS_RPS_LIMIT", "2000,2200")()
+	defer setTestEnv("ECS_INTROSPECTION_RPS_LIMIT", "50,75")()
+	defer setTestEnv("ECS_HTTP_MAX_CONNECTIONS", "256")()
 	defer setTestEnv("ECS_SHARED_VOLUME_MATCH_FULL_CONFIG", "true")()
+	defer setTestEnv("ECS_STATE_CHANGE_EVENTS_SOCKET_PATH", "/var/run/ecs/events.sock")()
+	defer setTestEnv("ECS_ENABLE_GPU_SUPPORT", "true")()
+	defer setTestEnv("ECS_ENABLE_TASK_NETWORKS", "true")()
+	defer setTestEnv("ECS_INTROSPECTION_EXEC_TOKEN", "secret")()
+	defer setTestEnv("ECS_ENABLE_PROMETHEUS_METRICS", "true")()
+	defer setTestEnv("ECS_WEBSOCKET_PROXY", "http://proxy:3128")()
+	defer setTestEnv("ECS_WEBSOCKET_TLS_MIN_VERSION", "1.2")()
+	defer setTestEnv("ECS_WEBSOCKET_CA_BUNDLE", "/etc/ecs/ca.pem")()
+	defer setTestEnv("ECS_DYNAMIC_HOST_PORT_RANGE", "40000-40999")()
 	additionalLocalRoutesJSON := `["1.2.3.4/22","5.6.7.8/32"]`
 	setTestEnv("ECS_AWSVPC_ADDITIONAL_LOCAL_ROUTES", additionalLocalRoutesJSON)
 	setTestEnv("ECS_ENABLE_CONTAINER_METADATA", "true")
@@ -139,10 +162,19 @@ func TestEnvironmentConfig(t *testing.T) {
 	assert.Equal(t, (30 * time.Minute), conf.MinimumImageDeletionAge)
 	assert.Equal(t, (2 * time.Hour), conf.ImageCleanupInterval)
 	assert.Equal(t, 2, conf.NumImagesToDeletePerCycle)
+	assert.Equal(t, 85, conf.ImageCleanupDiskUsageHighThreshold)
+	assert.Equal(t, 70, conf.ImageCleanupDiskUsageLowThreshold)
+	assert.Equal(t, []string{"amazonlinux:2", "internal/base-*"}, conf.ImageCleanupExclusionList)
+	assert.Equal(t, 5, conf.ImagePullConcurrency)
+	assert.Equal(t, 4, conf.ImagePullAttempts)
 	assert.Equal(t, ImagePullAlwaysBehavior, conf.ImagePullBehavior)
 	assert.Equal(t, "testing", conf.InstanceAttributes["my_attribute"])
 	assert.Equal(t, "testing", conf.ContainerInstanceTags["my_tag"])
 	assert.Equal(t, (90 * time.Second), conf.TaskCleanupWaitDuration)
+	assert.Equal(t, (30 * time.Minute), conf.OrphanCleanupWaitDuration)
+	assert.Equal(t, (2 * time.Hour), conf.DockerResourcesCleanupInterval)
+	assert.True(t, conf.DockerResourcesCleanupDryRun, "Wrong value for DockerResourcesCleanupDryRun")
+	assert.Equal(t, 15*time.Minute, conf.TaskCredentialsRefreshWindow)
 	serializedAdditionalLocalRoutesJSON, err := json.Marshal(conf.AWSVPCAdditionalLocalRoutes)
 	assert.NoError(t, err, "should marshal additional local routes")
 	assert.Equal(t, additionalLocalRoutesJSON, string(serializedAdditionalLocalRoutesJSON))
@@ -150,18 +182,37 @@ func TestEnvironmentConfig(t *testing.T) {
 	assert.True(t, conf.ContainerMetadataEnabled, "Wrong value for ContainerMetadataEnabled")
 	assert.Equal(t, 1000, conf.TaskMetadataSteadyStateRate)
 	assert.Equal(t, 1100, conf.TaskMetadataBurstRate)
+	assert.Equal(t, 2000, conf.TaskCredentialsSteadyStateRate)
+	assert.Equal(t, 2200, conf.TaskCredentialsBurstRate)
+	assert.Equal(t, 50, conf.IntrospectionSteadyStateRate)
+	assert.Equal(t, 75, conf.IntrospectionBurstRate)
+	assert.Equal(t, 256, conf.HTTPMaxConnections)
 	assert.True(t, conf.SharedVolumeMatchFullConfig, "Wrong value for SharedVolumeMatchFullConfig")
+	assert.Equal(t, "/var/run/ecs/events.sock", conf.StateChangeEventsSocketPath)
+	assert.True(t, conf.GPUSupportEnabled, "Wrong value for GPUSupportEnabled")
+	assert.True(t, conf.TaskNetworksEnabled, "Wrong value for TaskNetworksEnabled")
+	if assert.NotNil(t, conf.IntrospectionExecToken) {
+		assert.Equal(t, "secret", string(conf.IntrospectionExecToken.Contents()))
+	}
+	assert.Equal(t, uint16(40000), conf.DynamicHostPortRangeStart)
+	assert.Equal(t, uint16(40999), conf.DynamicHostPortRangeEnd)
+	assert.True(t, conf.PrometheusMetricsEnabled, "Wrong value for PrometheusMetricsEnabled")
+	assert.Equal(t, "http://proxy:3128", conf.WebsocketProxy)
+	assert.Equal(t, "1.2", conf.WebsocketTLSMinVersion)
+	assert.Equal(t, "/etc/ecs/ca.pem", conf.WebsocketCABundlePath)
 }
 
 func TestTrimWhitespaceWhenCreating(t *testing.T) {
 	defer setTestRegion()()
 	defer setTestEnv("ECS_CLUSTER", "default \r")()
 	defer setTestEnv("ECS_ENGINE_AUTH_TYPE", "dockercfg\r")()
+	defer setTestEnv("ECS_ENGINE_AUTH_CONFIG_FILE", "/etc/ecs/docker/config.json \n")()
 
 	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
 	assert.NoError(t, err)
 	assert.Equal(t, cfg.Cluster, "default", "Wrong cluster")
 	assert.Equal(t, cfg.EngineAuthType, "dockercfg", "Wrong auth type")
+	assert.Equal(t, "/etc/ecs/docker/config.json", cfg.EngineAuthConfigFile, "Wrong auth config file")
 }
 
 func TestTrimWhitespace(t *testing.T) {
@@ -212,6 +263,29 @@ func TestInvalidLoggingDriver(t *testing.T) {
 	assert.Error(t, conf.validateAndOverrideBounds(), "Should be error with invalid-logging-driver")
 }
 
+func TestInvalidWebsocketTLSMinVersion(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_WEBSOCKET_TLS_MIN_VERSION", "1.9")()
+	_, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.Error(t, err, "Should be error with an unsupported TLS version")
+}
+
+func TestInvalidWebsocketCABundle(t *testing.T) {
+	emptyBundle, err := ioutil.TempFile("", "ca-bundle")
+	require.NoError(t, err)
+	defer os.Remove(emptyBundle.Name())
+	emptyBundle.Close()
+
+	for _, path := range []string{"/does/not/exist.pem", emptyBundle.Name()} {
+		t.Run(path, func(t *testing.T) {
+			defer setTestRegion()()
+			defer setTestEnv("ECS_WEBSOCKET_CA_BUNDLE", path)()
+			_, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+			assert.Error(t, err, "Should be error with a CA bundle that can't be loaded")
+		})
+	}
+}
+
 func TestDefaultCheckpointWithoutECSDataDir(t *testing.T) {
 	conf, err := environmentConfig()
 	assert.NoError(t, err)
@@ -288,6 +362,19 @@ func TestNegativeValueDockerInactivityTimeout(t *testing.T) {
 	assert.Equal(t, conf.ImagePullInactivityTimeout, minimumImagePullInactivityTimeout, "Wrong value for ImagePullInactivityTimeout")
 }
 
+func TestInvalidDynamicHostPortRange(t *testing.T) {
+	for _, portRange := range []string{"40999-40000", "0-40000", "40000", "a-b"} {
+		t.Run(portRange, func(t *testing.T) {
+			defer setTestRegion()()
+			defer setTestEnv("ECS_DYNAMIC_HOST_PORT_RANGE", portRange)()
+			conf, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+			assert.NoError(t, err)
+			assert.Zero(t, conf.DynamicHostPortRangeStart, "Wrong value for DynamicHostPortRangeStart")
+			assert.Zero(t, conf.DynamicHostPortRangeEnd, "Wrong value for DynamicHostPortRangeEnd")
+		})
+	}
+}
+
 // Zero is also how the config api handles 'bad' values... so we get a 'default' and not a minimum
 func TestZeroValueContainerStartTimeout(t *testing.T) {
 	defer setTestRegion()()
@@ -427,6 +514,24 @@ func TestImageCleanupMinimumInterval(t *testing.T) {
 	assert.Equal(t, cfg.ImageCleanupInterval, DefaultImageCleanupTimeInterval, "Wrong value for ImageCleanupInterval")
 }
 
+func TestDockerResourcesCleanupMinimumInterval(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_DOCKER_RESOURCES_CLEANUP_INTERVAL", "1m")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, defaultDockerResourcesCleanupInterval, cfg.DockerResourcesCleanupInterval,
+		"Wrong value for DockerResourcesCleanupInterval")
+}
+
+func TestTaskCredentialsRefreshMinimumWindow(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_TASK_CREDENTIALS_REFRESH_WINDOW", "30s")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, defaultTaskCredentialsRefreshWindow, cfg.TaskCredentialsRefreshWindow,
+		"Wrong value for TaskCredentialsRefreshWindow")
+}
+
 func TestImageCleanupMinimumNumImagesToDeletePerCycle(t *testing.T) {
 	defer setTestRegion()()
 	defer setTestEnv("ECS_NUM_IMAGES_DELETE_PER_CYCLE", "-1")()
@@ -435,6 +540,52 @@ func TestImageCleanupMinimumNumImagesToDeletePerCycle(t *testing.T) {
 	assert.Equal(t, cfg.NumImagesToDeletePerCycle, DefaultNumImagesToDeletePerCycle, "Wrong value for NumImagesToDeletePerCycle")
 }
 
+func TestImageCleanupDiskUsageThresholdDisabledByDefault(t *testing.T) {
+	defer setTestRegion()()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.ImageCleanupDiskUsageHighThreshold)
+	assert.Zero(t, cfg.ImageCleanupDiskUsageLowThreshold)
+}
+
+func TestImageCleanupDiskUsageHighThresholdInvalid(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_USAGE_HIGH_THRESHOLD", "120")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.ImageCleanupDiskUsageHighThreshold, "Invalid high threshold should disable image cleanup by disk usage")
+}
+
+func TestImageCleanupDiskUsageLowThresholdDefault(t *testing.T) {
+	for _, lowThreshold := range []string{"", "90", "-5"} {
+		t.Run(lowThreshold, func(t *testing.T) {
+			defer setTestRegion()()
+			defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_USAGE_HIGH_THRESHOLD", "80")()
+			defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_USAGE_LOW_THRESHOLD", lowThreshold)()
+			cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+			assert.NoError(t, err)
+			assert.Equal(t, 80, cfg.ImageCleanupDiskUsageHighThreshold)
+			assert.Equal(t, 70, cfg.ImageCleanupDiskUsageLowThreshold)
+		})
+	}
+}
+
+func TestMinimumImagePullConcurrency(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_IMAGE_PULL_CONCURRENCY", "0")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultImagePullConcurrency, cfg.ImagePullConcurrency, "Wrong value for ImagePullConcurrency")
+}
+
+func TestMinimumImagePullAttempts(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_IMAGE_PULL_ATTEMPTS", "0")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultImagePullAttempts, cfg.ImagePullAttempts, "Wrong value for ImagePullAttempts")
+}
+
 func TestInvalidImagePullBehavior(t *testing.T) {
 	defer setTestRegion()()
 	defer setTestEnv("ECS_IMAGE_PULL_BEHAVIOR", "invalid")()
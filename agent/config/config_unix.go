@@ -52,6 +52,9 @@ func DefaultConfig() Config {
 		ReservedMemory:                     0,
 		AvailableLoggingDrivers:            []dockerclient.LoggingDriver{dockerclient.JSONFileDriver, dockerclient.NoneDriver},
 		TaskCleanupWaitDuration:            DefaultTaskCleanupWaitDuration,
+		OrphanCleanupWaitDuration:          defaultOrphanCleanupWaitDuration,
+		DockerResourcesCleanupInterval:     defaultDockerResourcesCleanupInterval,
+		TaskCredentialsRefreshWindow:       defaultTaskCredentialsRefreshWindow,
 		DockerStopTimeout:                  defaultDockerStopTimeout,
 		ContainerStartTimeout:              defaultContainerStartTimeout,
 		CredentialsAuditLogFile:            defaultCredentialsAuditLogFile,
@@ -61,16 +64,25 @@ func DefaultConfig() Config {
 		ImageCleanupInterval:               DefaultImageCleanupTimeInterval,
 		ImagePullInactivityTimeout:         defaultImagePullInactivityTimeout,
 		NumImagesToDeletePerCycle:          DefaultNumImagesToDeletePerCycle,
+		ImagePullConcurrency:               DefaultImagePullConcurrency,
+		ImagePullAttempts:                  DefaultImagePullAttempts,
 		CNIPluginsPath:                     defaultCNIPluginsPath,
 		PauseContainerTarballPath:          pauseContainerTarballPath,
 		PauseContainerImageName:            DefaultPauseContainerImageName,
 		PauseContainerTag:                  DefaultPauseContainerTag,
 		AWSVPCBlockInstanceMetdata:         false,
 		ContainerMetadataEnabled:           false,
+		GPUSupportEnabled:                  false,
+		TaskNetworksEnabled:                false,
 		TaskCPUMemLimit:                    DefaultEnabled,
 		CgroupPath:                         defaultCgroupPath,
 		TaskMetadataSteadyStateRate:        DefaultTaskMetadataSteadyStateRate,
 		TaskMetadataBurstRate:              DefaultTaskMetadataBurstRate,
+		TaskCredentialsSteadyStateRate:     DefaultTaskCredentialsSteadyStateRate,
+		TaskCredentialsBurstRate:           DefaultTaskCredentialsBurstRate,
+		IntrospectionSteadyStateRate:       DefaultIntrospectionSteadyStateRate,
+		IntrospectionBurstRate:             DefaultIntrospectionBurstRate,
+		HTTPMaxConnections:                 DefaultHTTPMaxConnections,
 		SharedVolumeMatchFullConfig:        false, // only requiring shared volumes to match on name, which is default docker behavior
 		ContainerInstancePropagateTagsFrom: ContainerInstancePropagateTagsFromNoneType,
 	}
@@ -79,25 +79,35 @@ func DefaultConfig() Config {
 		DataDir:          dataDir,
 		// DataDirOnHost is identical to DataDir for Windows because we do not
 		// run as a container
-		DataDirOnHost:               dataDir,
-		ReservedMemory:              0,
-		AvailableLoggingDrivers:     []dockerclient.LoggingDriver{dockerclient.JSONFileDriver, dockerclient.NoneDriver, dockerclient.AWSLogsDriver},
-		TaskCleanupWaitDuration:     DefaultTaskCleanupWaitDuration,
-		DockerStopTimeout:           defaultDockerStopTimeout,
-		ContainerStartTimeout:       defaultContainerStartTimeout,
-		ImagePullInactivityTimeout:  defaultImagePullInactivityTimeout,
-		CredentialsAuditLogFile:     filepath.Join(ecsRoot, defaultCredentialsAuditLogFile),
-		CredentialsAuditLogDisabled: false,
-		ImageCleanupDisabled:        false,
-		MinimumImageDeletionAge:     DefaultImageDeletionAge,
-		ImageCleanupInterval:        DefaultImageCleanupTimeInterval,
-		NumImagesToDeletePerCycle:   DefaultNumImagesToDeletePerCycle,
-		ContainerMetadataEnabled:    false,
-		TaskCPUMemLimit:             ExplicitlyDisabled,
-		PlatformVariables:           platformVariables,
-		TaskMetadataSteadyStateRate: DefaultTaskMetadataSteadyStateRate,
-		TaskMetadataBurstRate:       DefaultTaskMetadataBurstRate,
-		SharedVolumeMatchFullConfig: false, //only requiring shared volumes to match on name, which is default docker behavior
+		DataDirOnHost:                  dataDir,
+		ReservedMemory:                 0,
+		AvailableLoggingDrivers:        []dockerclient.LoggingDriver{dockerclient.JSONFileDriver, dockerclient.NoneDriver, dockerclient.AWSLogsDriver},
+		TaskCleanupWaitDuration:        DefaultTaskCleanupWaitDuration,
+		OrphanCleanupWaitDuration:      defaultOrphanCleanupWaitDuration,
+		DockerResourcesCleanupInterval: defaultDockerResourcesCleanupInterval,
+		TaskCredentialsRefreshWindow:   defaultTaskCredentialsRefreshWindow,
+		DockerStopTimeout:              defaultDockerStopTimeout,
+		ContainerStartTimeout:          defaultContainerStartTimeout,
+		ImagePullInactivityTimeout:     defaultImagePullInactivityTimeout,
+		CredentialsAuditLogFile:        filepath.Join(ecsRoot, defaultCredentialsAuditLogFile),
+		CredentialsAuditLogDisabled:    false,
+		ImageCleanupDisabled:           false,
+		MinimumImageDeletionAge:        DefaultImageDeletionAge,
+		ImageCleanupInterval:           DefaultImageCleanupTimeInterval,
+		NumImagesToDeletePerCycle:      DefaultNumImagesToDeletePerCycle,
+		ImagePullConcurrency:           DefaultImagePullConcurrency,
+		ImagePullAttempts:              DefaultImagePullAttempts,
+		ContainerMetadataEnabled:       false,
+		TaskCPUMemLimit:                ExplicitlyDisabled,
+		PlatformVariables:              platformVariables,
+		TaskMetadataSteadyStateRate:    DefaultTaskMetadataSteadyStateRate,
+		TaskMetadataBurstRate:          DefaultTaskMetadataBurstRate,
+		TaskCredentialsSteadyStateRate: DefaultTaskCredentialsSteadyStateRate,
+		TaskCredentialsBurstRate:       DefaultTaskCredentialsBurstRate,
+		IntrospectionSteadyStateRate:   DefaultIntrospectionSteadyStateRate,
+		IntrospectionBurstRate:         DefaultIntrospectionBurstRate,
+		HTTPMaxConnections:             DefaultHTTPMaxConnections,
+		SharedVolumeMatchFullConfig:    false, //only requiring shared volumes to match on name, which is default docker behavior
 	}
 }
 
@@ -114,6 +124,12 @@ func (cfg *Config) platformOverrides() {
 	// ensure TaskResourceLimit is disabled
 	cfg.TaskCPUMemLimit = ExplicitlyDisabled
 
+	// ensure GPU support is disabled
+	cfg.GPUSupportEnabled = false
+
+	// ensure task networks are disabled
+	cfg.TaskNetworksEnabled = false
+
 	cpuUnbounded := utils.ParseBool(os.Getenv("ECS_ENABLE_CPU_UNBOUNDED_WINDOWS_WORKAROUND"), false)
 	platformVariables := PlatformVariables{
 		CPUUnbounded: cpuUnbounded,
@@ -58,6 +58,16 @@ func TestConfigDefault(t *testing.T) {
 		"Default TaskMetadataSteadyStateRate is set incorrectly")
 	assert.Equal(t, DefaultTaskMetadataBurstRate, cfg.TaskMetadataBurstRate,
 		"Default TaskMetadataBurstRate is set incorrectly")
+	assert.Equal(t, DefaultTaskCredentialsSteadyStateRate, cfg.TaskCredentialsSteadyStateRate,
+		"Default TaskCredentialsSteadyStateRate is set incorrectly")
+	assert.Equal(t, DefaultTaskCredentialsBurstRate, cfg.TaskCredentialsBurstRate,
+		"Default TaskCredentialsBurstRate is set incorrectly")
+	assert.Equal(t, DefaultIntrospectionSteadyStateRate, cfg.IntrospectionSteadyStateRate,
+		"Default IntrospectionSteadyStateRate is set incorrectly")
+	assert.Equal(t, DefaultIntrospectionBurstRate, cfg.IntrospectionBurstRate,
+		"Default IntrospectionBurstRate is set incorrectly")
+	assert.Equal(t, DefaultHTTPMaxConnections, cfg.HTTPMaxConnections,
+		"Default HTTPMaxConnections is set incorrectly")
 	assert.False(t, cfg.SharedVolumeMatchFullConfig, "Default SharedVolumeMatchFullConfig set incorrectly")
 }
 
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -58,6 +59,26 @@ func parseReservedPorts(env string) []uint16 {
 	return reservedPorts
 }
 
+func parseDynamicHostPortRange() (uint16, uint16) {
+	// Format: start-end, e.g. 49153-65535
+	portRangeEnv := os.Getenv("ECS_DYNAMIC_HOST_PORT_RANGE")
+	if portRangeEnv == "" {
+		return 0, 0
+	}
+	bounds := strings.Split(portRangeEnv, "-")
+	if len(bounds) != 2 {
+		seelog.Warnf("Invalid format for \"ECS_DYNAMIC_HOST_PORT_RANGE\" environment variable; expected a range like 49153-65535. Parsed value: %s", portRangeEnv)
+		return 0, 0
+	}
+	start, startErr := strconv.ParseUint(strings.TrimSpace(bounds[0]), 10, 16)
+	end, endErr := strconv.ParseUint(strings.TrimSpace(bounds[1]), 10, 16)
+	if startErr != nil || endErr != nil {
+		seelog.Warnf("Invalid format for \"ECS_DYNAMIC_HOST_PORT_RANGE\" environment variable; expected a range like 49153-65535. Parsed value: %s", portRangeEnv)
+		return 0, 0
+	}
+	return uint16(start), uint16(end)
+}
+
 func parseDockerStopTimeout() time.Duration {
 	var dockerStopTimeout time.Duration
 	parsedStopTimeout := parseEnvVariableDuration("ECS_CONTAINER_STOP_TIMEOUT")
@@ -130,19 +151,73 @@ func parseNumImagesToDeletePerCycle() int {
 	return numImagesToDeletePerCycle
 }
 
+func parseImageCleanupExclusionList() []string {
+	exclusionListEnv := os.Getenv("ECS_IMAGE_CLEANUP_EXCLUDE")
+	var exclusionList []string
+	err := json.NewDecoder(strings.NewReader(exclusionListEnv)).Decode(&exclusionList)
+	if err != io.EOF && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_IMAGE_CLEANUP_EXCLUDE\" environment variable; expected a JSON array like [\"amazonlinux:2\",\"internal/base-*\"]. err %v", err)
+		return nil
+	}
+	var patterns []string
+	for _, pattern := range exclusionList {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		// Match only fails for malformed patterns
+		if _, err := path.Match(pattern, ""); err != nil {
+			seelog.Warnf("Invalid image cleanup exclusion pattern %q will be ignored: %v", pattern, err)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func parseImagePullConcurrency() int {
+	imagePullConcurrencyEnvVal := os.Getenv("ECS_IMAGE_PULL_CONCURRENCY")
+	imagePullConcurrency, err := strconv.Atoi(imagePullConcurrencyEnvVal)
+	if imagePullConcurrencyEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_IMAGE_PULL_CONCURRENCY\", expected an integer. err %v", err)
+	}
+
+	return imagePullConcurrency
+}
+
+func parseImagePullAttempts() int {
+	imagePullAttemptsEnvVal := os.Getenv("ECS_IMAGE_PULL_ATTEMPTS")
+	imagePullAttempts, err := strconv.Atoi(imagePullAttemptsEnvVal)
+	if imagePullAttemptsEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_IMAGE_PULL_ATTEMPTS\", expected an integer. err %v", err)
+	}
+
+	return imagePullAttempts
+}
+
 func parseImagePullBehavior() ImagePullBehaviorType {
-	ImagePullBehaviorString := os.Getenv("ECS_IMAGE_PULL_BEHAVIOR")
-	switch ImagePullBehaviorString {
+	// Use the default image pull behavior when ECS_IMAGE_PULL_BEHAVIOR is
+	// "default" or not valid
+	imagePullBehavior, _ := ParseImagePullBehavior(os.Getenv("ECS_IMAGE_PULL_BEHAVIOR"))
+	return imagePullBehavior
+}
+
+// ParseImagePullBehavior returns the image pull behavior named by the string,
+// which is one of "default", "always", "once" and "prefer-cached". It returns
+// false along with the default image pull behavior if the string doesn't name
+// an image pull behavior
+func ParseImagePullBehavior(imagePullBehavior string) (ImagePullBehaviorType, bool) {
+	switch imagePullBehavior {
+	case "default":
+		return ImagePullDefaultBehavior, true
 	case "always":
-		return ImagePullAlwaysBehavior
+		return ImagePullAlwaysBehavior, true
 	case "once":
-		return ImagePullOnceBehavior
+		return ImagePullOnceBehavior, true
 	case "prefer-cached":
-		return ImagePullPreferCachedBehavior
+		return ImagePullPreferCachedBehavior, true
 	default:
-		// Use the default image pull behavior when ECS_IMAGE_PULL_BEHAVIOR is
-		// "default" or not valid
-		return ImagePullDefaultBehavior
+		return ImagePullDefaultBehavior, false
 	}
 }
 
@@ -195,25 +270,39 @@ func parseTaskCPUMemLimitEnabled() Conditional {
 }
 
 func parseTaskMetadataThrottles() (int, int) {
+	return parseThrottles("ECS_TASK_METADATA_RPS_LIMIT")
+}
+
+func parseTaskCredentialsThrottles() (int, int) {
+	return parseThrottles("ECS_TASK_CREDENTIALS_RPS_LIMIT")
+}
+
+func parseIntrospectionThrottles() (int, int) {
+	return parseThrottles("ECS_INTROSPECTION_RPS_LIMIT")
+}
+
+// parseThrottles parses the steady state and burst rates of the environment
+// variable, in the "rateLimit,burst" format
+func parseThrottles(envVar string) (int, int) {
 	var steadyStateRate, burstRate int
-	rpsLimitEnvVal := os.Getenv("ECS_TASK_METADATA_RPS_LIMIT")
+	rpsLimitEnvVal := os.Getenv(envVar)
 	if rpsLimitEnvVal == "" {
-		seelog.Debug("Environment variable empty: ECS_TASK_METADATA_RPS_LIMIT")
+		seelog.Debugf("Environment variable empty: %s", envVar)
 		return 0, 0
 	}
 	rpsLimitSplits := strings.Split(rpsLimitEnvVal, ",")
 	if len(rpsLimitSplits) != 2 {
-		seelog.Warn(`Invalid format for "ECS_TASK_METADATA_RPS_LIMIT", expected: "rateLimit,burst"`)
+		seelog.Warnf(`Invalid format for "%s", expected: "rateLimit,burst"`, envVar)
 		return 0, 0
 	}
 	steadyStateRate, err := strconv.Atoi(strings.TrimSpace(rpsLimitSplits[0]))
 	if err != nil {
-		seelog.Warnf(`Invalid format for "ECS_TASK_METADATA_RPS_LIMIT", expected integer for steady state rate: %v`, err)
+		seelog.Warnf(`Invalid format for "%s", expected integer for steady state rate: %v`, envVar, err)
 		return 0, 0
 	}
 	burstRate, err = strconv.Atoi(strings.TrimSpace(rpsLimitSplits[1]))
 	if err != nil {
-		seelog.Warnf(`Invalid format for "ECS_TASK_METADATA_RPS_LIMIT", expected integer for burst rate: %v`, err)
+		seelog.Warnf(`Invalid format for "%s", expected integer for burst rate: %v`, envVar, err)
 		return 0, 0
 	}
 	return steadyStateRate, burstRate
@@ -266,6 +355,19 @@ func parseEnvVariableUint16(envVar string) uint16 {
 	return var16
 }
 
+func parseEnvVariableInt(envVar string) int {
+	envVal := os.Getenv(envVar)
+	var value int
+	if envVal != "" {
+		var err error
+		value, err = strconv.Atoi(envVal)
+		if err != nil {
+			seelog.Warnf("Invalid format for \""+envVar+"\" environment variable; expected integer. err %v", err)
+		}
+	}
+	return value
+}
+
 func parseEnvVariableDuration(envVar string) time.Duration {
 	var duration time.Duration
 	envVal := os.Getenv(envVar)
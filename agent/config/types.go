@@ -76,6 +76,10 @@ type Config struct {
 	// EngineAuthData contains authentication data. Please see the documentation
 	// for EngineAuthType for more information.
 	EngineAuthData *SensitiveRawMessage
+	// EngineAuthConfigFile is the path of a docker config file with the auth
+	// data of registries. It takes precedence over EngineAuthData, and is
+	// reloaded when it changes
+	EngineAuthConfigFile string `trim:"true"`
 
 	// UpdatesEnabled specifies whether updates should be applied to this agent.
 	// Default true
@@ -123,6 +127,21 @@ type Config struct {
 	// until cleanup of task resources is started.
 	TaskCleanupWaitDuration time.Duration
 
+	// OrphanCleanupWaitDuration specifies the time to wait after the engine is
+	// initialized until the containers of tasks missing from the agent's state
+	// are stopped and removed. The containers aren't removed when it's zero.
+	OrphanCleanupWaitDuration time.Duration
+
+	// DockerResourcesCleanupInterval specifies the time between the sweeps
+	// removing the docker networks and volumes created for tasks that are
+	// missing from the agent's state
+	DockerResourcesCleanupInterval time.Duration
+
+	// DockerResourcesCleanupDryRun specifies if the sweeps of the docker
+	// networks and volumes of missing tasks only log the resources they would
+	// remove
+	DockerResourcesCleanupDryRun bool
+
 	// TaskIAMRoleEnabled specifies if the Agent is capable of launching
 	// tasks with IAM Roles.
 	TaskIAMRoleEnabled bool
@@ -140,6 +159,11 @@ type Config struct {
 	// tasks with IAM Roles when networkMode is set to 'host'
 	TaskIAMRoleEnabledForNetworkHost bool
 
+	// TaskCredentialsRefreshWindow specifies how long before their expiration
+	// the agent asks ECS to refresh the IAM role credentials of tasks that
+	// haven't been refreshed yet
+	TaskCredentialsRefreshWindow time.Duration
+
 	// TaskENIEnabled specifies if the Agent is capable of launching task within
 	// defined EC2 networks
 	TaskENIEnabled bool
@@ -160,10 +184,35 @@ type Config struct {
 	// when Agent performs cleanup
 	NumImagesToDeletePerCycle int
 
+	// ImageCleanupDiskUsageHighThreshold specifies the percentage of the file
+	// system of the docker root above which image cleanup also removes the
+	// least recently used unused images until the usage drops below
+	// ImageCleanupDiskUsageLowThreshold. It's disabled when 0
+	ImageCleanupDiskUsageHighThreshold int
+
+	// ImageCleanupDiskUsageLowThreshold specifies the percentage of the file
+	// system of the docker root that image cleanup triggered by the disk usage
+	// brings the usage under
+	ImageCleanupDiskUsageLowThreshold int
+
+	// ImageCleanupExclusionList specifies the patterns of the names of the
+	// images that image cleanup never removes. A pattern is an image name, a
+	// repository, which matches all its tags, a prefix ending with a slash, or
+	// a glob like "internal/base-*"
+	ImageCleanupExclusionList []string
+
 	// ImagePullBehavior specifies the agent's behavior for pulling image and loading
 	// local Docker image cache
 	ImagePullBehavior ImagePullBehaviorType
 
+	// ImagePullConcurrency specifies the maximum number of images the agent
+	// pulls at the same time
+	ImagePullConcurrency int
+
+	// ImagePullAttempts specifies the maximum number of attempts the agent
+	// makes to pull an image before failing the container
+	ImagePullAttempts int
+
 	// InstanceAttributes contains key/value pairs representing
 	// attributes to be associated with this instance within the
 	// ECS service and used to influence behavior such as launch
@@ -226,6 +275,27 @@ type Config struct {
 	// TaskMetadataBurstRate specifies the burst rate throttle for the task metadata endpoint
 	TaskMetadataBurstRate int
 
+	// TaskCredentialsSteadyStateRate specifies the steady state throttle for
+	// the credentials paths of the task metadata endpoint
+	TaskCredentialsSteadyStateRate int
+
+	// TaskCredentialsBurstRate specifies the burst rate throttle for the
+	// credentials paths of the task metadata endpoint
+	TaskCredentialsBurstRate int
+
+	// IntrospectionSteadyStateRate specifies the steady state throttle for the
+	// introspection endpoint
+	IntrospectionSteadyStateRate int
+
+	// IntrospectionBurstRate specifies the burst rate throttle for the
+	// introspection endpoint
+	IntrospectionBurstRate int
+
+	// HTTPMaxConnections is the maximum number of concurrent connections to
+	// each of the task metadata and introspection endpoints, past which
+	// requests are throttled
+	HTTPMaxConnections int
+
 	// SharedVolumeMatchFullConfig is config option used to short-circuit volume validation against a
 	// provisioned volume, if false (default). If true, we perform deep comparison including driver options
 	// and labels. For comparing shared volume across 2 instances, this should be set to false as docker's
@@ -243,6 +313,57 @@ type Config struct {
 	// When set to "none" (or any other string), no API call will be made.
 	ContainerInstancePropagateTagsFrom ContainerInstancePropagateTagsFromType
 
+	// StateChangeEventsSocketPath is the path of the unix domain socket on
+	// which the task and container state change events are broadcast to local
+	// subscribers. Events aren't broadcast if it's empty
+	StateChangeEventsSocketPath string
+
+	// GPUSupportEnabled specifies if the agent should discover the NVIDIA GPUs
+	// of the instance and assign them to the containers reserving GPUs
+	GPUSupportEnabled bool
+
+	// GPUIDs are the IDs of the GPUs discovered when the agent starts with GPU
+	// support enabled. They aren't read from the environment
+	GPUIDs []string
+
+	// TaskNetworksEnabled specifies if the agent should create a docker bridge
+	// network for each task with the bridge network mode, on which the
+	// containers of the task reach each other by their container names
+	TaskNetworksEnabled bool
+
+	// DynamicHostPortRangeStart and DynamicHostPortRangeEnd bound the range of
+	// host ports the agent allocates to the port mappings of containers
+	// without a host port. Docker picks the host ports from the ephemeral
+	// range of the kernel if they aren't set
+	DynamicHostPortRangeStart uint16
+	DynamicHostPortRangeEnd   uint16
+
+	// IntrospectionExecToken is the bearer token authenticating the requests
+	// to run commands in containers through the introspection server. The
+	// exec endpoint is disabled if it's not set
+	IntrospectionExecToken *SensitiveRawMessage
+
+	// PrometheusMetricsEnabled enables serving the metrics of the agent in
+	// the Prometheus text format on the /metrics path of the introspection
+	// server
+	PrometheusMetricsEnabled bool
+
+	// WebsocketProxy is the url of the HTTP proxy the websocket connections
+	// to ACS and TCS are tunneled through. When it's not set, the proxy is
+	// read from the HTTPS_PROXY environment variable. Hosts matching NO_PROXY
+	// are connected to directly either way
+	WebsocketProxy string
+
+	// WebsocketTLSMinVersion is the minimum TLS version of the websocket
+	// connections to ACS and TCS, like "1.2". The default of the TLS library
+	// applies when it's not set
+	WebsocketTLSMinVersion string
+
+	// WebsocketCABundlePath is the path of a PEM bundle of CA certificates
+	// that are trusted, in addition to the system root CAs, by the websocket
+	// connections to ACS and TCS
+	WebsocketCABundlePath string
+
 	// ContainerInstanceTags contains key/value pairs representing
 	// tags extracted from config file and will be associated with this instance
 	// through RegisterContainerInstance call. Tags with the same keys from DescribeTags
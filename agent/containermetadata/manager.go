@@ -18,13 +18,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper"
 	"github.com/aws/amazon-ecs-agent/agent/utils/oswrapper"
 
+	"github.com/cihub/seelog"
 	docker "github.com/fsouza/go-dockerclient"
 )
 
@@ -35,6 +41,7 @@ const (
 	inspectContainerTimeout     = 30 * time.Second
 	metadataFile                = "ecs-container-metadata.json"
 	metadataPerm                = 0644
+	awsvpcNetworkMode           = "awsvpc"
 )
 
 // Manager is an interface that allows us to abstract away the metadata
@@ -42,7 +49,7 @@ const (
 type Manager interface {
 	SetContainerInstanceARN(string)
 	Create(*docker.Config, *docker.HostConfig, *apitask.Task, string) error
-	Update(context.Context, string, *apitask.Task, string) error
+	Update(context.Context, string, *apitask.Task, *apicontainer.Container) error
 	Clean(string) error
 }
 
@@ -65,17 +72,23 @@ type metadataManager struct {
 	osWrap oswrapper.OS
 	// ioutilWrap is a wrapper for 'ioutil' package operations
 	ioutilWrap ioutilwrapper.IOUtil
+	// writtenStatuses maps the paths of the metadata files to the status of
+	// the container last written to them. Updates are made in the background,
+	// so that a late update doesn't overwrite the file with an older status
+	writtenStatuses map[string]apicontainerstatus.ContainerStatus
+	lock            sync.Mutex
 }
 
 // NewManager creates a metadataManager for a given DockerTaskEngine settings.
 func NewManager(client DockerMetadataClient, cfg *config.Config) Manager {
 	return &metadataManager{
-		client:        client,
-		cluster:       cfg.Cluster,
-		dataDir:       cfg.DataDir,
-		dataDirOnHost: cfg.DataDirOnHost,
-		osWrap:        oswrapper.NewOS(),
-		ioutilWrap:    ioutilwrapper.NewIOUtil(),
+		client:          client,
+		cluster:         cfg.Cluster,
+		dataDir:         cfg.DataDir,
+		dataDirOnHost:   cfg.DataDirOnHost,
+		osWrap:          oswrapper.NewOS(),
+		ioutilWrap:      ioutilwrapper.NewIOUtil(),
+		writtenStatuses: make(map[string]apicontainerstatus.ContainerStatus),
 	}
 }
 
@@ -107,6 +120,9 @@ func (manager *metadataManager) Create(config *docker.Config, hostConfig *docker
 	if err != nil {
 		return err
 	}
+	if container, ok := task.ContainerByName(containerName); ok {
+		container.SetMetadataFilePath(hostMetadataFilePath(manager.dataDirOnHost, metadataDirectoryPath))
+	}
 
 	// Add the directory of this container's metadata to the container's mount binds
 	// Then add the destination directory as an environment variable in the container $METADATA
@@ -116,22 +132,39 @@ func (manager *metadataManager) Create(config *docker.Config, hostConfig *docker
 	return nil
 }
 
-// Update updates the metadata file after container starts and dynamic metadata is available
-func (manager *metadataManager) Update(ctx context.Context, dockerID string, task *apitask.Task, containerName string) error {
-	// Get docker container information through api call
-	dockerContainer, err := manager.client.InspectContainer(ctx, dockerID, inspectContainerTimeout)
-	if err != nil {
-		return err
+// Update rewrites the metadata file with the state of the container known to
+// the agent, on every transition of the container. Containers that are
+// created are inspected for their dynamic metadata, such as their port bindings
+// and network settings, unless the docker id is empty
+func (manager *metadataManager) Update(ctx context.Context, dockerID string, task *apitask.Task, container *apicontainer.Container) error {
+	// Record the status before inspecting the container, which may take long
+	// enough for the container to transition again
+	containerMD := parseContainerMetadata(container, container.GetKnownStatus())
+
+	var dockerContainer *docker.Container
+	if dockerID != "" {
+		var err error
+		// Get docker container information through api call
+		dockerContainer, err = manager.client.InspectContainer(ctx, dockerID, inspectContainerTimeout)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Ensure we do not update a container that is invalid or is not running
-	if dockerContainer == nil || !dockerContainer.State.Running {
-		return fmt.Errorf("container metadata update for container %s in task %s: container not running or invalid", containerName, task.Arn)
+	// The metadata directory is created here too, as the file is updated once
+	// the image is pulled, before the container is created
+	metadataDirectoryPath, err := getMetadataFilePath(task.Arn, container.Name, manager.dataDir)
+	if err != nil {
+		return fmt.Errorf("container metadata update for task %s container %s: %v", task.Arn, container.Name, err)
+	}
+	err = manager.osWrap.MkdirAll(metadataDirectoryPath, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("creating metadata directory for task %s: %v", task.Arn, err)
 	}
 
 	// Acquire the metadata then write it in JSON format to the file
-	metadata := manager.parseMetadata(dockerContainer, task, containerName)
-	return manager.marshalAndWrite(metadata, task.Arn, containerName)
+	metadata := manager.parseMetadata(dockerContainer, task, container, containerMD)
+	return manager.marshalAndWrite(metadata, task.Arn, container.Name)
 }
 
 // Clean removes the metadata files of all containers associated with a task
@@ -140,6 +173,14 @@ func (manager *metadataManager) Clean(taskARN string) error {
 	if err != nil {
 		return fmt.Errorf("clean task metadata: unable to get metadata directory for task %s: %v", taskARN, err)
 	}
+
+	manager.lock.Lock()
+	for path := range manager.writtenStatuses {
+		if strings.HasPrefix(path, metadataPath+string(filepath.Separator)) {
+			delete(manager.writtenStatuses, path)
+		}
+	}
+	manager.lock.Unlock()
 	return manager.osWrap.RemoveAll(metadataPath)
 }
 
@@ -149,6 +190,28 @@ func (manager *metadataManager) marshalAndWrite(metadata Metadata, taskARN strin
 		return fmt.Errorf("create metadata for container %s in task %s: failed to marshal metadata: %v", containerName, taskARN, err)
 	}
 
+	metadataDirectoryPath, err := getMetadataFilePath(taskARN, containerName, manager.dataDir)
+	if err != nil {
+		return fmt.Errorf("write to metadata file for task %s container %s: %v", taskARN, containerName, err)
+	}
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	status := metadata.containerMetadata.status
+	if writtenStatus, ok := manager.writtenStatuses[metadataDirectoryPath]; ok && status < writtenStatus {
+		seelog.Debugf("Skipping the update of the metadata file of container %s in task %s to status %s: already written for status %s",
+			containerName, taskARN, status.String(), writtenStatus.String())
+		return nil
+	}
+
 	// Write the metadata to file
-	return writeToMetadataFile(manager.osWrap, manager.ioutilWrap, data, taskARN, containerName, manager.dataDir)
+	err = writeToMetadataFile(manager.osWrap, manager.ioutilWrap, data, taskARN, containerName, manager.dataDir)
+	if err != nil {
+		return err
+	}
+	if manager.writtenStatuses == nil {
+		manager.writtenStatuses = make(map[string]apicontainerstatus.ContainerStatus)
+	}
+	manager.writtenStatuses[metadataDirectoryPath] = status
+	return nil
 }
@@ -17,9 +17,12 @@ package containermetadata
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
 
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	"github.com/aws/amazon-ecs-agent/agent/containermetadata/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper/mocks"
@@ -113,36 +116,206 @@ func TestUpdateInspectFail(t *testing.T) {
 	mockClient.EXPECT().InspectContainer(gomock.Any(), mockDockerID, inspectContainerTimeout).Return(nil, errors.New("Inspect fail"))
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
-	err := newManager.Update(ctx, mockDockerID, mockTask, mockContainerName)
+	err := newManager.Update(ctx, mockDockerID, mockTask, &apicontainer.Container{Name: mockContainerName})
 
 	assert.Error(t, err, "Expected inspect error to result in update fail")
 }
 
-// TestUpdateNotRunningFail checks case where container is not running
-func TestUpdateNotRunningFail(t *testing.T) {
-	mockClient, _, _, _, done := managerSetup(t)
+// TestCreate is the happypath case for metadata create
+func TestCreate(t *testing.T) {
+	_, mockIOUtil, mockOS, mockFile, done := managerSetup(t)
+	defer done()
+
+	mockTaskARN := validTaskARN
+	mockContainer := &apicontainer.Container{Name: containerName}
+	mockTask := &apitask.Task{Arn: mockTaskARN, Containers: []*apicontainer.Container{mockContainer}}
+	mockContainerName := containerName
+	mockConfig := &docker.Config{Env: make([]string, 0)}
+	mockHostConfig := &docker.HostConfig{Binds: make([]string, 0)}
+
+	gomock.InOrder(
+		mockOS.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).Return(nil),
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil),
+		mockFile.EXPECT().Write(gomock.Any()).Return(0, nil),
+		mockFile.EXPECT().Chmod(gomock.Any()).Return(nil),
+		mockFile.EXPECT().Sync().Return(nil),
+		mockFile.EXPECT().Close().Return(nil),
+		mockFile.EXPECT().Name().Return(""),
+		mockOS.EXPECT().Rename(gomock.Any(), gomock.Any()).Return(nil),
+	)
+
+	newManager := &metadataManager{
+		osWrap:     mockOS,
+		ioutilWrap: mockIOUtil,
+	}
+	err := newManager.Create(mockConfig, mockHostConfig, mockTask, mockContainerName)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(mockConfig.Env), "Unexpected number of environment variables in config")
+	assert.Equal(t, 1, len(mockHostConfig.Binds), "Unexpected number of binds in host config")
+	assert.Contains(t, mockContainer.GetMetadataFilePath(), metadataFile)
+}
+
+// TestUpdate is happypath case for metadata update
+func TestUpdate(t *testing.T) {
+	mockClient, mockIOUtil, mockOS, mockFile, done := managerSetup(t)
 	defer done()
 
 	mockDockerID := dockerID
 	mockTaskARN := validTaskARN
 	mockTask := &apitask.Task{Arn: mockTaskARN}
-	mockContainerName := containerName
+	mockContainer := &apicontainer.Container{Name: containerName}
+	mockContainer.SetKnownStatus(apicontainerstatus.ContainerRunning)
 	mockState := docker.State{
-		Running: false,
+		Running: true,
 	}
-	mockContainer := &docker.Container{
-		State: mockState,
+
+	mockConfig := &docker.Config{Image: "image"}
+
+	mockNetworks := make(map[string]docker.ContainerNetwork)
+	mockNetworkSettings := &docker.NetworkSettings{Networks: mockNetworks}
+
+	mockDockerContainer := &docker.Container{
+		State:           mockState,
+		Config:          mockConfig,
+		NetworkSettings: mockNetworkSettings,
 	}
 
 	newManager := &metadataManager{
-		client: mockClient,
+		client:     mockClient,
+		osWrap:     mockOS,
+		ioutilWrap: mockIOUtil,
 	}
 
-	mockClient.EXPECT().InspectContainer(gomock.Any(), mockDockerID, inspectContainerTimeout).Return(mockContainer, nil)
+	gomock.InOrder(
+		mockClient.EXPECT().InspectContainer(gomock.Any(), mockDockerID, inspectContainerTimeout).Return(mockDockerContainer, nil),
+		mockOS.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).Return(nil),
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil),
+		mockFile.EXPECT().Write(gomock.Any()).Return(0, nil),
+		mockFile.EXPECT().Chmod(gomock.Any()).Return(nil),
+		mockFile.EXPECT().Sync().Return(nil),
+		mockFile.EXPECT().Close().Return(nil),
+		mockFile.EXPECT().Name().Return(""),
+		mockOS.EXPECT().Rename(gomock.Any(), gomock.Any()).Return(nil),
+	)
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
-	err := newManager.Update(ctx, mockDockerID, mockTask, mockContainerName)
-	assert.Error(t, err)
+	err := newManager.Update(ctx, mockDockerID, mockTask, mockContainer)
+
+	assert.NoError(t, err)
+}
+
+// TestUpdateStoppedContainer checks that the metadata file of a stopped
+// container is updated with its exit code
+func TestUpdateStoppedContainer(t *testing.T) {
+	mockClient, mockIOUtil, mockOS, mockFile, done := managerSetup(t)
+	defer done()
+
+	mockDockerID := dockerID
+	mockTask := &apitask.Task{Arn: validTaskARN}
+	mockContainer := &apicontainer.Container{Name: containerName}
+	mockContainer.SetKnownStatus(apicontainerstatus.ContainerStopped)
+	exitCode := 137
+	mockContainer.SetKnownExitCode(&exitCode)
+	mockDockerContainer := &docker.Container{
+		State:           docker.State{Running: false},
+		NetworkSettings: &docker.NetworkSettings{},
+	}
+
+	newManager := &metadataManager{
+		client:     mockClient,
+		osWrap:     mockOS,
+		ioutilWrap: mockIOUtil,
+	}
+
+	var written metadataSerializer
+	gomock.InOrder(
+		mockClient.EXPECT().InspectContainer(gomock.Any(), mockDockerID, inspectContainerTimeout).Return(mockDockerContainer, nil),
+		mockOS.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).Return(nil),
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil),
+		mockFile.EXPECT().Write(gomock.Any()).Do(func(data []byte) {
+			assert.NoError(t, json.Unmarshal(data, &written))
+		}).Return(0, nil),
+		mockFile.EXPECT().Chmod(gomock.Any()).Return(nil),
+		mockFile.EXPECT().Sync().Return(nil),
+		mockFile.EXPECT().Close().Return(nil),
+		mockFile.EXPECT().Name().Return(""),
+		mockOS.EXPECT().Rename(gomock.Any(), gomock.Any()).Return(nil),
+	)
+	err := newManager.Update(context.TODO(), mockDockerID, mockTask, mockContainer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "STOPPED", written.ContainerStatus)
+	assert.Equal(t, &exitCode, written.ExitCode)
+	assert.Equal(t, MetadataReady, written.MetadataFileStatus)
+}
+
+// TestUpdateWithoutDockerID checks that containers that aren't created yet
+// aren't inspected
+func TestUpdateWithoutDockerID(t *testing.T) {
+	_, mockIOUtil, mockOS, mockFile, done := managerSetup(t)
+	defer done()
+
+	mockTask := &apitask.Task{Arn: validTaskARN}
+	mockContainer := &apicontainer.Container{Name: containerName}
+	mockContainer.SetKnownStatus(apicontainerstatus.ContainerPulled)
+
+	newManager := &metadataManager{
+		osWrap:     mockOS,
+		ioutilWrap: mockIOUtil,
+	}
+
+	gomock.InOrder(
+		mockOS.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).Return(nil),
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil),
+		mockFile.EXPECT().Write(gomock.Any()).Return(0, nil),
+		mockFile.EXPECT().Chmod(gomock.Any()).Return(nil),
+		mockFile.EXPECT().Sync().Return(nil),
+		mockFile.EXPECT().Close().Return(nil),
+		mockFile.EXPECT().Name().Return(""),
+		mockOS.EXPECT().Rename(gomock.Any(), gomock.Any()).Return(nil),
+	)
+	err := newManager.Update(context.TODO(), "", mockTask, mockContainer)
+	assert.NoError(t, err)
+}
+
+// TestUpdateOlderStatusSkipped checks that a late update doesn't overwrite the
+// metadata file written for a newer status of the container, until the task is
+// cleaned up
+func TestUpdateOlderStatusSkipped(t *testing.T) {
+	_, mockIOUtil, mockOS, mockFile, done := managerSetup(t)
+	defer done()
+
+	mockTask := &apitask.Task{Arn: validTaskARN}
+	createdContainer := &apicontainer.Container{Name: containerName}
+	createdContainer.SetKnownStatus(apicontainerstatus.ContainerCreated)
+	pulledContainer := &apicontainer.Container{Name: containerName}
+	pulledContainer.SetKnownStatus(apicontainerstatus.ContainerPulled)
+
+	newManager := &metadataManager{
+		osWrap:     mockOS,
+		ioutilWrap: mockIOUtil,
+	}
+
+	expectWrite := func() {
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil)
+		mockFile.EXPECT().Write(gomock.Any()).Return(0, nil)
+		mockFile.EXPECT().Chmod(gomock.Any()).Return(nil)
+		mockFile.EXPECT().Sync().Return(nil)
+		mockFile.EXPECT().Close().Return(nil)
+		mockFile.EXPECT().Name().Return("")
+		mockOS.EXPECT().Rename(gomock.Any(), gomock.Any()).Return(nil)
+	}
+	mockOS.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	expectWrite()
+	assert.NoError(t, newManager.Update(context.TODO(), "", mockTask, createdContainer))
+	// The older status isn't written
+	assert.NoError(t, newManager.Update(context.TODO(), "", mockTask, pulledContainer))
+
+	mockOS.EXPECT().RemoveAll(gomock.Any()).Return(nil)
+	assert.NoError(t, newManager.Clean(validTaskARN))
+	expectWrite()
+	assert.NoError(t, newManager.Update(context.TODO(), "", mockTask, pulledContainer))
 }
 
 // TestMalformedFilepath checks case where ARN is invalid
@@ -17,6 +17,7 @@ import (
 	"fmt"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 
 	"github.com/cihub/seelog"
@@ -29,7 +30,7 @@ import (
 // Since we accept incomplete metadata fields, we should not return
 // errors here and handle them at this or the above stage.
 func (manager *metadataManager) parseMetadataAtContainerCreate(task *apitask.Task, containerName string) Metadata {
-	return Metadata{
+	metadata := Metadata{
 		cluster: manager.cluster,
 		taskMetadata: TaskMetadata{
 			containerName:          containerName,
@@ -40,28 +41,73 @@ func (manager *metadataManager) parseMetadataAtContainerCreate(task *apitask.Tas
 		containerInstanceARN: manager.containerInstanceARN,
 		metadataStatus:       MetadataInitial,
 	}
+	if container, ok := task.ContainerByName(containerName); ok {
+		metadata.containerMetadata = parseContainerMetadata(container, container.GetKnownStatus())
+	}
+	return metadata
 }
 
-// parseMetadata gathers metadata from a docker container, and task
-// configuration and data then packages it for JSON Marshaling
+// parseMetadata gathers metadata from a docker container, the state of the
+// container known to the agent, and task configuration and data then packages
+// it for JSON Marshaling. The docker container is nil for containers that
+// aren't created yet
 // Since we accept incomplete metadata fields, we should not return
 // errors here and handle them at this or the above stage.
-func (manager *metadataManager) parseMetadata(dockerContainer *docker.Container, task *apitask.Task, containerName string) Metadata {
-	dockerMD := parseDockerContainerMetadata(task.Arn, containerName, dockerContainer)
+func (manager *metadataManager) parseMetadata(dockerContainer *docker.Container, task *apitask.Task,
+	container *apicontainer.Container, containerMD ContainerMetadata) Metadata {
+	var dockerMD DockerContainerMetadata
+	if dockerContainer != nil {
+		dockerMD = parseDockerContainerMetadata(task.Arn, container.Name, dockerContainer)
+	}
+	// Docker doesn't report the port bindings of stopped containers
+	if len(dockerMD.ports) == 0 {
+		dockerMD.ports = container.GetKnownPortBindings()
+	}
+	// The network settings of the containers of awsvpc tasks are the ones of
+	// the ENI of the task, which docker doesn't know about
+	if eni := task.GetTaskENI(); eni != nil {
+		dockerMD.networkInfo = NetworkMetadata{
+			networks: []Network{{
+				NetworkMode:   awsvpcNetworkMode,
+				IPv4Addresses: eni.GetIPV4Addresses(),
+				IPv6Addresses: eni.GetIPV6Addresses(),
+			}},
+		}
+	}
+	metadataStatus := MetadataInitial
+	if containerMD.status >= apicontainerstatus.ContainerRunning {
+		metadataStatus = MetadataReady
+	}
 	return Metadata{
 		cluster: manager.cluster,
 		taskMetadata: TaskMetadata{
-			containerName:          containerName,
+			containerName:          container.Name,
 			taskARN:                task.Arn,
 			taskDefinitionFamily:   task.Family,
 			taskDefinitionRevision: task.Version,
 		},
 		dockerContainerMetadata: dockerMD,
+		containerMetadata:       containerMD,
 		containerInstanceARN:    manager.containerInstanceARN,
-		metadataStatus:          MetadataReady,
+		metadataStatus:          metadataStatus,
 	}
 }
 
+// parseContainerMetadata packages the state of the container known to the
+// agent at the given status for JSON Marshaling
+func parseContainerMetadata(container *apicontainer.Container, status apicontainerstatus.ContainerStatus) ContainerMetadata {
+	containerMD := ContainerMetadata{
+		status: status,
+	}
+	if container.HealthStatusShouldBeReported() {
+		containerMD.healthStatus = container.GetHealthStatus().Status.String()
+	}
+	if status.Terminal() {
+		containerMD.exitCode = container.GetKnownExitCode()
+	}
+	return containerMD
+}
+
 // parseDockerContainerMetadata parses the metadata in a docker container
 // and packages this data for JSON marshaling
 // Since we accept incomplete metadata fields, we should not return
@@ -134,11 +180,17 @@ func parseNetworkMetadata(settings *docker.NetworkSettings, hostConfig *docker.H
 			networkMode := modeFromSettings
 			ipv4Addresses := []string{containerNetwork.IPAddress}
 			network := Network{NetworkMode: networkMode, IPv4Addresses: ipv4Addresses}
+			if containerNetwork.GlobalIPv6Address != "" {
+				network.IPv6Addresses = []string{containerNetwork.GlobalIPv6Address}
+			}
 			networkList = append(networkList, network)
 		}
 	} else {
 		ipv4Addresses := []string{ipv4AddressFromSettings}
 		network := Network{NetworkMode: networkModeFromHostConfig, IPv4Addresses: ipv4Addresses}
+		if settings.GlobalIPv6Address != "" {
+			network.IPv6Addresses = []string{settings.GlobalIPv6Address}
+		}
 		networkList = append(networkList, network)
 	}
 
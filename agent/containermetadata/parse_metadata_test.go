@@ -16,8 +16,12 @@
 package containermetadata
 
 import (
+	"encoding/json"
 	"testing"
 
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 
 	docker "github.com/fsouza/go-dockerclient"
@@ -28,6 +32,8 @@ const (
 	cluster = "us-west2"
 )
 
+var runningContainerMetadata = ContainerMetadata{status: apicontainerstatus.ContainerRunning}
+
 // TestParseContainerCreate checks case when parsing is done at metadata creation
 func TestParseContainerCreate(t *testing.T) {
 	mockTaskARN := validTaskARN
@@ -55,6 +61,19 @@ func TestParseContainerCreate(t *testing.T) {
 	assert.Equal(t, string(metadata.metadataStatus), expectedStatus, "Expected status "+expectedStatus)
 }
 
+// TestParseContainerCreateContainerStatus checks that the status of the
+// container is parsed at metadata creation
+func TestParseContainerCreateContainerStatus(t *testing.T) {
+	container := &apicontainer.Container{Name: containerName}
+	container.SetKnownStatus(apicontainerstatus.ContainerPulled)
+	mockTask := &apitask.Task{Arn: validTaskARN, Containers: []*apicontainer.Container{container}}
+
+	newManager := &metadataManager{}
+	metadata := newManager.parseMetadataAtContainerCreate(mockTask, containerName)
+	assert.Equal(t, apicontainerstatus.ContainerPulled, metadata.containerMetadata.status)
+	assert.Equal(t, MetadataInitial, metadata.metadataStatus)
+}
+
 func TestParseHasNoContainer(t *testing.T) {
 	mockTaskARN := validTaskARN
 	mockTask := &apitask.Task{Arn: mockTaskARN}
@@ -69,7 +88,7 @@ func TestParseHasNoContainer(t *testing.T) {
 		containerInstanceARN: mockContainerInstanceARN,
 	}
 
-	metadata := newManager.parseMetadata(nil, mockTask, mockContainerName)
+	metadata := newManager.parseMetadata(nil, mockTask, &apicontainer.Container{Name: mockContainerName}, runningContainerMetadata)
 	assert.Equal(t, metadata.cluster, mockCluster, "Expected cluster "+mockCluster)
 	assert.Equal(t, metadata.taskMetadata.containerName, mockContainerName, "Expected container name "+mockContainerName)
 	assert.Equal(t, metadata.taskMetadata.taskARN, mockTaskARN, "Expected task ARN "+mockTaskARN)
@@ -102,7 +121,7 @@ func TestParseHasConfig(t *testing.T) {
 		containerInstanceARN: mockContainerInstanceARN,
 	}
 
-	metadata := newManager.parseMetadata(mockContainer, mockTask, mockContainerName)
+	metadata := newManager.parseMetadata(mockContainer, mockTask, &apicontainer.Container{Name: mockContainerName}, runningContainerMetadata)
 
 	assert.Equal(t, metadata.cluster, mockCluster, "Expected cluster "+mockCluster)
 	assert.Equal(t, metadata.taskMetadata.containerName, mockContainerName, "Expected container name "+mockContainerName)
@@ -138,7 +157,7 @@ func TestParseHasNetworkSettingsPortBindings(t *testing.T) {
 		containerInstanceARN: mockContainerInstanceARN,
 	}
 
-	metadata := newManager.parseMetadata(mockContainer, mockTask, mockContainerName)
+	metadata := newManager.parseMetadata(mockContainer, mockTask, &apicontainer.Container{Name: mockContainerName}, runningContainerMetadata)
 	assert.Equal(t, metadata.cluster, mockCluster, "Expected cluster "+mockCluster)
 	assert.Equal(t, metadata.taskMetadata.containerName, mockContainerName, "Expected container name "+mockContainerName)
 	assert.Equal(t, metadata.taskMetadata.taskARN, mockTaskARN, "Expected task ARN "+mockTaskARN)
@@ -170,7 +189,7 @@ func TestParseHasNetworkSettingsNetworksEmpty(t *testing.T) {
 		containerInstanceARN: mockContainerInstanceARN,
 	}
 
-	metadata := newManager.parseMetadata(mockContainer, mockTask, mockContainerName)
+	metadata := newManager.parseMetadata(mockContainer, mockTask, &apicontainer.Container{Name: mockContainerName}, runningContainerMetadata)
 	assert.Equal(t, metadata.cluster, mockCluster, "Expected cluster "+mockCluster)
 	assert.Equal(t, metadata.taskMetadata.containerName, mockContainerName, "Expected container name "+mockContainerName)
 	assert.Equal(t, metadata.taskMetadata.taskARN, mockTaskARN, "Expected task ARN "+mockTaskARN)
@@ -200,7 +219,7 @@ func TestParseHasNetworkSettingsNetworksNonEmpty(t *testing.T) {
 		containerInstanceARN: mockContainerInstanceARN,
 	}
 
-	metadata := newManager.parseMetadata(mockContainer, mockTask, mockContainerName)
+	metadata := newManager.parseMetadata(mockContainer, mockTask, &apicontainer.Container{Name: mockContainerName}, runningContainerMetadata)
 	assert.Equal(t, metadata.cluster, mockCluster, "Expected cluster "+mockCluster)
 	assert.Equal(t, metadata.taskMetadata.containerName, mockContainerName, "Expected container name "+mockContainerName)
 	assert.Equal(t, metadata.taskMetadata.taskARN, mockTaskARN, "Expected task ARN "+mockTaskARN)
@@ -209,6 +228,30 @@ func TestParseHasNetworkSettingsNetworksNonEmpty(t *testing.T) {
 	assert.Equal(t, len(metadata.dockerContainerMetadata.networkInfo.networks), 2, "Expected two networks")
 }
 
+func TestParseHasNetworkSettingsIPv6Addresses(t *testing.T) {
+	mockTask := &apitask.Task{Arn: validTaskARN}
+	mockHostConfig := &docker.HostConfig{NetworkMode: "bridge"}
+	mockNetworks := map[string]docker.ContainerNetwork{
+		"bridge": {IPAddress: "172.17.0.2", GlobalIPv6Address: "2001:db8::2"},
+	}
+	mockNetworkSettings := &docker.NetworkSettings{Networks: mockNetworks}
+	mockContainer := &docker.Container{HostConfig: mockHostConfig, NetworkSettings: mockNetworkSettings}
+
+	newManager := &metadataManager{}
+	metadata := newManager.parseMetadata(mockContainer, mockTask, &apicontainer.Container{Name: containerName}, runningContainerMetadata)
+	networks := metadata.dockerContainerMetadata.networkInfo.networks
+	assert.Len(t, networks, 1)
+	assert.Equal(t, []string{"172.17.0.2"}, networks[0].IPv4Addresses)
+	assert.Equal(t, []string{"2001:db8::2"}, networks[0].IPv6Addresses)
+
+	// Networks without ipv6 addresses are described as before
+	mockNetworks["bridge"] = docker.ContainerNetwork{IPAddress: "172.17.0.2"}
+	metadata = newManager.parseMetadata(mockContainer, mockTask, &apicontainer.Container{Name: containerName}, runningContainerMetadata)
+	networks = metadata.dockerContainerMetadata.networkInfo.networks
+	assert.Len(t, networks, 1)
+	assert.Nil(t, networks[0].IPv6Addresses)
+}
+
 func TestParseTaskDefinitionSettings(t *testing.T) {
 	mockTaskARN := validTaskARN
 	mockTask := &apitask.Task{Arn: mockTaskARN}
@@ -228,7 +271,7 @@ func TestParseTaskDefinitionSettings(t *testing.T) {
 		containerInstanceARN: mockContainerInstanceARN,
 	}
 
-	metadata := newManager.parseMetadata(mockContainer, mockTask, mockContainerName)
+	metadata := newManager.parseMetadata(mockContainer, mockTask, &apicontainer.Container{Name: mockContainerName}, runningContainerMetadata)
 	assert.Equal(t, metadata.cluster, mockCluster, "Expected cluster "+mockCluster)
 	assert.Equal(t, metadata.taskMetadata.containerName, mockContainerName, "Expected container name "+mockContainerName)
 	assert.Equal(t, metadata.taskMetadata.taskARN, mockTaskARN, "Expected task ARN "+mockTaskARN)
@@ -241,7 +284,101 @@ func TestParseTaskDefinitionSettings(t *testing.T) {
 	mockTaskDefinitionFamily := taskDefinitionFamily
 	mockTaskDefinitionRevision := taskDefinitionRevision
 	mockTask = &apitask.Task{Arn: mockTaskARN, Family: mockTaskDefinitionFamily, Version: mockTaskDefinitionRevision}
-	metadata = newManager.parseMetadata(nil, mockTask, mockContainerName)
+	metadata = newManager.parseMetadata(nil, mockTask, &apicontainer.Container{Name: mockContainerName}, runningContainerMetadata)
 	assert.Equal(t, metadata.taskMetadata.taskDefinitionFamily, mockTaskDefinitionFamily, "Expected task definition family "+mockTaskDefinitionFamily)
 	assert.Equal(t, metadata.taskMetadata.taskDefinitionRevision, mockTaskDefinitionRevision, "Expected task definition revision "+mockTaskDefinitionRevision)
 }
+
+// TestParseContainerStates checks the metadata parsed as the container
+// transitions
+func TestParseContainerStates(t *testing.T) {
+	mockTask := &apitask.Task{Arn: validTaskARN}
+	container := &apicontainer.Container{
+		Name:                    containerName,
+		HealthCheckType:         apicontainer.DockerHealthCheckType,
+		KnownPortBindingsUnsafe: []apicontainer.PortBinding{{ContainerPort: 80, HostPort: 8080, BindIP: "0.0.0.0"}},
+	}
+	container.SetHealthStatus(apicontainer.HealthStatus{Status: apicontainerstatus.ContainerHealthy})
+	exitCode := 1
+	container.SetKnownExitCode(&exitCode)
+
+	newManager := &metadataManager{}
+	for _, tc := range []struct {
+		status         apicontainerstatus.ContainerStatus
+		metadataStatus MetadataStatus
+		exitCode       *int
+	}{
+		{apicontainerstatus.ContainerCreated, MetadataInitial, nil},
+		{apicontainerstatus.ContainerRunning, MetadataReady, nil},
+		{apicontainerstatus.ContainerStopped, MetadataReady, &exitCode},
+	} {
+		t.Run(tc.status.String(), func(t *testing.T) {
+			metadata := newManager.parseMetadata(nil, mockTask, container, parseContainerMetadata(container, tc.status))
+			assert.Equal(t, tc.metadataStatus, metadata.metadataStatus)
+			assert.Equal(t, tc.status, metadata.containerMetadata.status)
+			assert.Equal(t, "HEALTHY", metadata.containerMetadata.healthStatus)
+			assert.Equal(t, tc.exitCode, metadata.containerMetadata.exitCode)
+			// The port bindings known to the agent are used without the ones
+			// reported by docker
+			assert.Equal(t, container.GetKnownPortBindings(), metadata.dockerContainerMetadata.ports)
+		})
+	}
+}
+
+// TestParseNoHealthCheck checks that the health status isn't parsed for
+// containers without a health check
+func TestParseNoHealthCheck(t *testing.T) {
+	container := &apicontainer.Container{Name: containerName}
+	containerMD := parseContainerMetadata(container, apicontainerstatus.ContainerRunning)
+	assert.Empty(t, containerMD.healthStatus)
+}
+
+// TestParseAWSVPCNetworks checks that the networks of the containers of awsvpc
+// tasks are the ones of the ENI of the task
+func TestParseAWSVPCNetworks(t *testing.T) {
+	mockTask := &apitask.Task{Arn: validTaskARN}
+	mockTask.SetTaskENI(&apieni.ENI{
+		IPV4Addresses: []*apieni.ENIIPV4Address{{Primary: true, Address: "10.0.0.2"}},
+		IPV6Addresses: []*apieni.ENIIPV6Address{{Address: "2001:db8::2"}},
+	})
+	mockHostConfig := &docker.HostConfig{NetworkMode: "container:pause"}
+	mockNetworkSettings := &docker.NetworkSettings{}
+	mockContainer := &docker.Container{HostConfig: mockHostConfig, NetworkSettings: mockNetworkSettings}
+
+	newManager := &metadataManager{}
+	metadata := newManager.parseMetadata(mockContainer, mockTask, &apicontainer.Container{Name: containerName}, runningContainerMetadata)
+	assert.Equal(t, []Network{{
+		NetworkMode:   "awsvpc",
+		IPv4Addresses: []string{"10.0.0.2"},
+		IPv6Addresses: []string{"2001:db8::2"},
+	}}, metadata.dockerContainerMetadata.networkInfo.networks)
+}
+
+// TestMarshalContainerState checks the keys of the state of the container in
+// the metadata file
+func TestMarshalContainerState(t *testing.T) {
+	exitCode := 0
+	metadata := Metadata{
+		containerMetadata: ContainerMetadata{
+			status:       apicontainerstatus.ContainerStopped,
+			healthStatus: "UNHEALTHY",
+			exitCode:     &exitCode,
+		},
+		metadataStatus: MetadataReady,
+	}
+	data, err := json.Marshal(metadata)
+	assert.NoError(t, err)
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, "STOPPED", fields["ContainerStatus"])
+	assert.Equal(t, "UNHEALTHY", fields["HealthStatus"])
+	assert.Equal(t, float64(0), fields["ExitCode"])
+
+	// Containers that aren't known to the agent have no state
+	data, err = json.Marshal(Metadata{})
+	assert.NoError(t, err)
+	fields = nil
+	assert.NoError(t, json.Unmarshal(data, &fields))
+	assert.NotContains(t, fields, "ContainerStatus")
+	assert.NotContains(t, fields, "ExitCode")
+}
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 
 	docker "github.com/fsouza/go-dockerclient"
 )
@@ -111,6 +112,16 @@ type DockerContainerMetadata struct {
 	networkInfo         NetworkMetadata
 }
 
+// ContainerMetadata keeps track of the state of the container known to the
+// agent, which changes on every transition of the container. The health
+// status is only set for containers with a health check, and the exit code
+// once the container stopped
+type ContainerMetadata struct {
+	status       apicontainerstatus.ContainerStatus
+	healthStatus string
+	exitCode     *int
+}
+
 // TaskMetadata keeps track of all metadata associated with a task
 // provided by AWS, does not depend on the creation of the container
 type TaskMetadata struct {
@@ -128,6 +139,7 @@ type Metadata struct {
 	cluster                 string
 	taskMetadata            TaskMetadata
 	dockerContainerMetadata DockerContainerMetadata
+	containerMetadata       ContainerMetadata
 	containerInstanceARN    string
 	metadataStatus          MetadataStatus
 }
@@ -147,10 +159,17 @@ type metadataSerializer struct {
 	ImageName              string                     `json:"ImageName,omitempty"`
 	Ports                  []apicontainer.PortBinding `json:"PortMappings,omitempty"`
 	Networks               []Network                  `json:"Networks,omitempty"`
+	ContainerStatus        string                     `json:"ContainerStatus,omitempty"`
+	HealthStatus           string                     `json:"HealthStatus,omitempty"`
+	ExitCode               *int                       `json:"ExitCode,omitempty"`
 	MetadataFileStatus     MetadataStatus             `json:"MetadataFileStatus,omitempty"`
 }
 
 func (m Metadata) MarshalJSON() ([]byte, error) {
+	var containerStatus string
+	if m.containerMetadata.status != apicontainerstatus.ContainerStatusNone {
+		containerStatus = m.containerMetadata.status.String()
+	}
 	return json.Marshal(
 		metadataSerializer{
 			Cluster:                m.cluster,
@@ -165,6 +184,9 @@ func (m Metadata) MarshalJSON() ([]byte, error) {
 			ImageName:              m.dockerContainerMetadata.imageName,
 			Ports:                  m.dockerContainerMetadata.ports,
 			Networks:               m.dockerContainerMetadata.networkInfo.networks,
+			ContainerStatus:        containerStatus,
+			HealthStatus:           m.containerMetadata.healthStatus,
+			ExitCode:               m.containerMetadata.exitCode,
 			MetadataFileStatus:     m.metadataStatus,
 		})
 }
@@ -0,0 +1,72 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package containermetadata
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper"
+	"github.com/aws/amazon-ecs-agent/agent/utils/oswrapper"
+)
+
+const (
+	tempFile = "temp_metadata_file"
+)
+
+// writeToMetadataFile writes the metadata to a temporary file in the metadata
+// directory, which is then renamed to the metadata file, so that readers never
+// see a partially written file as it's rewritten on every transition of the
+// container. The temporary file is closed before it's renamed, as open files
+// can't be renamed on Windows, and removed if the metadata file can't be
+// written
+func writeToMetadataFile(osWrap oswrapper.OS, ioutilWrap ioutilwrapper.IOUtil, data []byte, taskARN string, containerName string, dataDir string) error {
+	metadataFileDir, err := getMetadataFilePath(taskARN, containerName, dataDir)
+	// Boundary case if file path is bad (Such as if task arn is incorrectly formatted)
+	if err != nil {
+		return fmt.Errorf("write to metadata file for task %s container %s: %v", taskARN, containerName, err)
+	}
+	metadataFileName := filepath.Join(metadataFileDir, metadataFile)
+
+	temp, err := ioutilWrap.TempFile(metadataFileDir, tempFile)
+	if err != nil {
+		return err
+	}
+	if err = writeTempMetadataFile(temp, data); err != nil {
+		temp.Close()
+		osWrap.Remove(temp.Name())
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		osWrap.Remove(temp.Name())
+		return err
+	}
+	if err = osWrap.Rename(temp.Name(), metadataFileName); err != nil {
+		osWrap.Remove(temp.Name())
+		return err
+	}
+	return nil
+}
+
+// writeTempMetadataFile writes the metadata to the temporary file and flushes
+// it to disk
+func writeTempMetadataFile(temp oswrapper.File, data []byte) error {
+	if _, err := temp.Write(data); err != nil {
+		return err
+	}
+	if err := temp.Chmod(metadataPerm); err != nil {
+		return err
+	}
+	return temp.Sync()
+}
@@ -16,6 +16,7 @@
 package containermetadata
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -48,3 +49,132 @@ func TestWriteInvalidARN(t *testing.T) {
 	err := writeToMetadataFile(nil, nil, mockData, mockTaskARN, mockContainerName, mockDataDir)
 	assert.Equal(t, expectErrorMessage, err.Error())
 }
+
+// TestWriteTempFileFail checks case where temp file cannot be made
+func TestWriteTempFileFail(t *testing.T) {
+	mockIOUtil, _, _, done := writeSetup(t)
+	defer done()
+
+	mockData := []byte("")
+	mockTaskARN := validTaskARN
+	mockContainerName := containerName
+	mockDataDir := dataDir
+
+	gomock.InOrder(
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(nil, errors.New("temp file fail")),
+	)
+
+	err := writeToMetadataFile(nil, mockIOUtil, mockData, mockTaskARN, mockContainerName, mockDataDir)
+	expectErrorMessage := "temp file fail"
+
+	assert.Error(t, err)
+	assert.Equal(t, expectErrorMessage, err.Error())
+}
+
+// TestWriteFileWriteFail checks case where write to file fails
+func TestWriteFileWriteFail(t *testing.T) {
+	mockIOUtil, mockOS, mockFile, done := writeSetup(t)
+	defer done()
+
+	mockData := []byte("")
+	mockTaskARN := validTaskARN
+	mockContainerName := containerName
+	mockDataDir := dataDir
+
+	gomock.InOrder(
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil),
+		mockFile.EXPECT().Write(mockData).Return(0, errors.New("write fail")),
+		mockFile.EXPECT().Close(),
+		mockFile.EXPECT().Name().Return(tempFile),
+		mockOS.EXPECT().Remove(tempFile),
+	)
+
+	err := writeToMetadataFile(mockOS, mockIOUtil, mockData, mockTaskARN, mockContainerName, mockDataDir)
+	expectErrorMessage := "write fail"
+
+	assert.Error(t, err)
+	assert.Equal(t, expectErrorMessage, err.Error())
+}
+
+// TestWriteChmodFail checks case where chmod fails
+func TestWriteChmodFail(t *testing.T) {
+	mockIOUtil, mockOS, mockFile, done := writeSetup(t)
+	defer done()
+
+	mockData := []byte("")
+	mockTaskARN := validTaskARN
+	mockContainerName := containerName
+	mockDataDir := dataDir
+
+	gomock.InOrder(
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil),
+		mockFile.EXPECT().Write(mockData).Return(0, nil),
+		mockFile.EXPECT().Chmod(gomock.Any()).Return(errors.New("chmod fail")),
+		mockFile.EXPECT().Close(),
+		mockFile.EXPECT().Name().Return(tempFile),
+		mockOS.EXPECT().Remove(tempFile),
+	)
+
+	err := writeToMetadataFile(mockOS, mockIOUtil, mockData, mockTaskARN, mockContainerName, mockDataDir)
+	expectErrorMessage := "chmod fail"
+
+	assert.Error(t, err)
+	assert.Equal(t, expectErrorMessage, err.Error())
+}
+
+// TestWriteSyncFail checks case where flushing the file to disk fails
+func TestWriteSyncFail(t *testing.T) {
+	mockIOUtil, mockOS, mockFile, done := writeSetup(t)
+	defer done()
+
+	mockData := []byte("")
+	mockTaskARN := validTaskARN
+	mockContainerName := containerName
+	mockDataDir := dataDir
+
+	gomock.InOrder(
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil),
+		mockFile.EXPECT().Write(mockData).Return(0, nil),
+		mockFile.EXPECT().Chmod(gomock.Any()).Return(nil),
+		mockFile.EXPECT().Sync().Return(errors.New("sync fail")),
+		mockFile.EXPECT().Close(),
+		mockFile.EXPECT().Name().Return(tempFile),
+		mockOS.EXPECT().Remove(tempFile),
+	)
+
+	err := writeToMetadataFile(mockOS, mockIOUtil, mockData, mockTaskARN, mockContainerName, mockDataDir)
+	expectErrorMessage := "sync fail"
+
+	assert.Error(t, err)
+	assert.Equal(t, expectErrorMessage, err.Error())
+}
+
+// TestWriteRenameFail checks case where the temp file is closed before it's
+// renamed, and removed when the rename fails
+func TestWriteRenameFail(t *testing.T) {
+	mockIOUtil, mockOS, mockFile, done := writeSetup(t)
+	defer done()
+
+	mockData := []byte("")
+	mockTaskARN := validTaskARN
+	mockContainerName := containerName
+	mockDataDir := dataDir
+
+	gomock.InOrder(
+		mockIOUtil.EXPECT().TempFile(gomock.Any(), gomock.Any()).Return(mockFile, nil),
+		mockFile.EXPECT().Write(mockData).Return(0, nil),
+		mockFile.EXPECT().Chmod(gomock.Any()).Return(nil),
+		mockFile.EXPECT().Sync().Return(nil),
+		mockFile.EXPECT().Close().Return(nil),
+		mockFile.EXPECT().Name().Return(tempFile),
+		mockOS.EXPECT().Rename(tempFile, gomock.Any()).Return(errors.New("rename fail")),
+		mockFile.EXPECT().Name().Return(tempFile),
+		mockOS.EXPECT().Remove(tempFile),
+	)
+
+	err := writeToMetadataFile(mockOS, mockIOUtil, mockData, mockTaskARN, mockContainerName, mockDataDir)
+	expectErrorMessage := "rename fail"
+
+	assert.Error(t, err)
+	assert.Equal(t, expectErrorMessage, err.Error())
+}
@@ -19,15 +19,11 @@ import (
 	"fmt"
 	"path/filepath"
 
-	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper"
-	"github.com/aws/amazon-ecs-agent/agent/utils/oswrapper"
-
 	"github.com/pborman/uuid"
 )
 
 const (
 	mountPoint = "/opt/ecs/metadata"
-	tempFile   = "temp_metadata_file"
 )
 
 // createBindsEnv will do the appropriate formatting to add a new mount in a container's HostConfig
@@ -42,28 +38,8 @@ func createBindsEnv(binds []string, env []string, dataDirOnHost string, metadata
 	return binds, env
 }
 
-// writeToMetadata puts the metadata into JSON format and writes into
-// the metadata file
-func writeToMetadataFile(osWrap oswrapper.OS, ioutilWrap ioutilwrapper.IOUtil, data []byte, taskARN string, containerName string, dataDir string) error {
-	metadataFileDir, err := getMetadataFilePath(taskARN, containerName, dataDir)
-	// Boundary case if file path is bad (Such as if task arn is incorrectly formatted)
-	if err != nil {
-		return fmt.Errorf("write to metadata file for task %s container %s: %v", taskARN, containerName, err)
-	}
-	metadataFileName := filepath.Join(metadataFileDir, metadataFile)
-
-	temp, err := ioutilWrap.TempFile(metadataFileDir, tempFile)
-	if err != nil {
-		return err
-	}
-	defer temp.Close()
-	_, err = temp.Write(data)
-	if err != nil {
-		return err
-	}
-	err = temp.Chmod(metadataPerm)
-	if err != nil {
-		return err
-	}
-	return osWrap.Rename(temp.Name(), metadataFileName)
+// hostMetadataFilePath returns the path on the host of the metadata file in the
+// metadata directory
+func hostMetadataFilePath(dataDirOnHost string, metadataDirectoryPath string) string {
+	return filepath.Join(dataDirOnHost, metadataDirectoryPath, metadataFile)
 }
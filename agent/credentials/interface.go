@@ -20,4 +20,5 @@ type Manager interface {
 	SetTaskCredentials(TaskIAMRoleCredentials) error
 	GetTaskCredentials(string) (TaskIAMRoleCredentials, bool)
 	RemoveCredentials(string)
+	GetCredentialsStatus() []CredentialsStatus
 }
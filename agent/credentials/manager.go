@@ -15,7 +15,9 @@ package credentials
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/aws-sdk-go/aws"
@@ -69,6 +71,30 @@ type TaskIAMRoleCredentials struct {
 	ARN                string
 	IAMRoleCredentials IAMRoleCredentials
 	lock               sync.RWMutex
+	// refreshedAt is when the credentials were last set in the credentials
+	// manager
+	refreshedAt time.Time
+}
+
+// CredentialsStatus describes when a set of task IAM role credentials was last
+// refreshed and when it expires
+type CredentialsStatus struct {
+	CredentialsID string
+	TaskARN       string
+	RoleType      string
+	RefreshedAt   time.Time
+	// ExpiresAt is the zero time if the expiration sent by ACS couldn't be
+	// parsed
+	ExpiresAt time.Time
+}
+
+// ExpiresWithin returns true if the credentials expire within the window
+// following now. Credentials with an unknown expiration never do
+func (status CredentialsStatus) ExpiresWithin(now time.Time, window time.Duration) bool {
+	if status.ExpiresAt.IsZero() {
+		return false
+	}
+	return status.ExpiresAt.Before(now.Add(window))
 }
 
 // GetIAMRoleCredentials returns the IAM role credentials in the task IAM role struct
@@ -134,6 +160,7 @@ func (manager *credentialsManager) SetTaskCredentials(taskCredentials TaskIAMRol
 	manager.idToTaskCredentials[credentials.CredentialsID] = TaskIAMRoleCredentials{
 		ARN:                taskCredentials.ARN,
 		IAMRoleCredentials: taskCredentials.GetIAMRoleCredentials(),
+		refreshedAt:        time.Now(),
 	}
 
 	return nil
@@ -155,6 +182,35 @@ func (manager *credentialsManager) GetTaskCredentials(id string) (TaskIAMRoleCre
 	}, ok
 }
 
+// GetCredentialsStatus returns when each set of credentials was last refreshed
+// and when it expires, ordered by expiration
+func (manager *credentialsManager) GetCredentialsStatus() []CredentialsStatus {
+	manager.taskCredentialsLock.RLock()
+	defer manager.taskCredentialsLock.RUnlock()
+
+	statuses := make([]CredentialsStatus, 0, len(manager.idToTaskCredentials))
+	for id := range manager.idToTaskCredentials {
+		// The fields are read in place rather than from a copy of the entry,
+		// which holds a lock. The entries are only modified under the lock of
+		// the manager
+		credentials := manager.idToTaskCredentials[id].IAMRoleCredentials
+		status := CredentialsStatus{
+			CredentialsID: id,
+			TaskARN:       manager.idToTaskCredentials[id].ARN,
+			RoleType:      credentials.RoleType,
+			RefreshedAt:   manager.idToTaskCredentials[id].refreshedAt,
+		}
+		if expiresAt, err := time.Parse(time.RFC3339, credentials.Expiration); err == nil {
+			status.ExpiresAt = expiresAt
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ExpiresAt.Before(statuses[j].ExpiresAt)
+	})
+	return statuses
+}
+
 // RemoveCredentials removes credentials from the credentials manager
 func (manager *credentialsManager) RemoveCredentials(id string) {
 	manager.taskCredentialsLock.Lock()
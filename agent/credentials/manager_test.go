@@ -18,6 +18,7 @@ package credentials
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/aws-sdk-go/aws"
@@ -171,3 +172,39 @@ func TestRemoveExistingCredentials(t *testing.T) {
 		t.Error("Expected GetTaskCredentials to return false for removed credentials")
 	}
 }
+
+// TestGetCredentialsStatus tests that the status of the credentials records
+// when they were set and when they expire, ordered by expiration
+func TestGetCredentialsStatus(t *testing.T) {
+	manager := NewManager()
+	before := time.Now()
+	for _, credentials := range []IAMRoleCredentials{
+		{CredentialsID: "later", Expiration: "2018-10-01T12:30:00Z", RoleType: ApplicationRoleType},
+		{CredentialsID: "sooner", Expiration: "2018-10-01T12:00:00Z", RoleType: ExecutionRoleType},
+		{CredentialsID: "unknown", Expiration: "soon", RoleType: ApplicationRoleType},
+	} {
+		err := manager.SetTaskCredentials(TaskIAMRoleCredentials{ARN: "t1", IAMRoleCredentials: credentials})
+		assert.NoError(t, err)
+	}
+
+	statuses := manager.GetCredentialsStatus()
+	assert.Len(t, statuses, 3)
+	assert.Equal(t, "unknown", statuses[0].CredentialsID)
+	assert.True(t, statuses[0].ExpiresAt.IsZero())
+	assert.Equal(t, "sooner", statuses[1].CredentialsID)
+	assert.Equal(t, ExecutionRoleType, statuses[1].RoleType)
+	assert.Equal(t, time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC), statuses[1].ExpiresAt.UTC())
+	assert.Equal(t, "later", statuses[2].CredentialsID)
+	for _, status := range statuses {
+		assert.Equal(t, "t1", status.TaskARN)
+		assert.False(t, status.RefreshedAt.Before(before))
+	}
+}
+
+func TestCredentialsStatusExpiresWithin(t *testing.T) {
+	now := time.Now()
+	status := CredentialsStatus{ExpiresAt: now.Add(5 * time.Minute)}
+	assert.True(t, status.ExpiresWithin(now, 10*time.Minute))
+	assert.False(t, status.ExpiresWithin(now, time.Minute))
+	assert.False(t, CredentialsStatus{}.ExpiresWithin(now, 10*time.Minute))
+}
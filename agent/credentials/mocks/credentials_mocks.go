@@ -47,6 +47,18 @@ func (m *MockManager) EXPECT() *MockManagerMockRecorder {
 	return m.recorder
 }
 
+// GetCredentialsStatus mocks base method
+func (m *MockManager) GetCredentialsStatus() []credentials.CredentialsStatus {
+	ret := m.ctrl.Call(m, "GetCredentialsStatus")
+	ret0, _ := ret[0].([]credentials.CredentialsStatus)
+	return ret0
+}
+
+// GetCredentialsStatus indicates an expected call of GetCredentialsStatus
+func (mr *MockManagerMockRecorder) GetCredentialsStatus() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCredentialsStatus", reflect.TypeOf((*MockManager)(nil).GetCredentialsStatus))
+}
+
 // GetTaskCredentials mocks base method
 func (m *MockManager) GetTaskCredentials(arg0 string) (credentials.TaskIAMRoleCredentials, bool) {
 	ret := m.ctrl.Call(m, "GetTaskCredentials", arg0)
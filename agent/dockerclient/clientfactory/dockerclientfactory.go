@@ -19,7 +19,6 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockeriface"
 	log "github.com/cihub/seelog"
-	docker "github.com/fsouza/go-dockerclient"
 	"github.com/pkg/errors"
 )
 
@@ -36,7 +35,7 @@ const (
 // recommended client version as well as a set of alternative supported
 // docker clients.
 type Factory interface {
-	// GetDefaultClient returns a versioned client for the default version
+	// GetDefaultClient returns a versioned client for the negotiated version
 	GetDefaultClient() (dockeriface.Client, error)
 
 	// GetClient returns a client with the specified version or an error
@@ -61,29 +60,65 @@ type Factory interface {
 
 	// FindClientAPIVersion returns the client api version
 	FindClientAPIVersion(dockeriface.Client) dockerclient.DockerVersion
+
+	// NegotiatedAPIVersion returns the Docker API version used by the default
+	// client. It's the highest agent-supported version that's supported by the
+	// Docker daemon, as negotiated when the Factory was created.
+	NegotiatedAPIVersion() dockerclient.DockerVersion
 }
 
 type factory struct {
 	endpoint string
 	clients  map[dockerclient.DockerVersion]dockeriface.Client
+	// daemonAPIVersion is the maximum API version reported by the Docker
+	// daemon. It's empty if the daemon didn't report it
+	daemonAPIVersion string
+	// negotiatedVersion is the version of the default client
+	negotiatedVersion dockerclient.DockerVersion
 }
 
 // newVersionedClient is a variable such that the implementation can be
 // swapped out for unit tests
 var newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
-	return docker.NewVersionedClient(endpoint, version)
+	return newEventsClient(endpoint, version)
 }
 
 // NewFactory initializes a client factory using a specified endpoint.
+// The Docker API version of the default client is negotiated with the Docker
+// daemon at creation time.
 func NewFactory(ctx context.Context, endpoint string) Factory {
-	return &factory{
-		endpoint: endpoint,
-		clients:  findDockerVersions(ctx, endpoint),
+	clients, daemonAPIVersion := findDockerVersions(ctx, endpoint)
+	f := &factory{
+		endpoint:         endpoint,
+		clients:          clients,
+		daemonAPIVersion: daemonAPIVersion,
 	}
+	f.negotiatedVersion = f.negotiateAPIVersion()
+	return f
 }
 
 func (f *factory) GetDefaultClient() (dockeriface.Client, error) {
-	return f.GetClient(getDefaultVersion())
+	return f.GetClient(f.negotiatedVersion)
+}
+
+func (f *factory) NegotiatedAPIVersion() dockerclient.DockerVersion {
+	return f.negotiatedVersion
+}
+
+// negotiateAPIVersion returns the highest agent-supported version for which a
+// client could be created, falling back to the default version if there's none
+func (f *factory) negotiateAPIVersion() dockerclient.DockerVersion {
+	agentVersions := getAgentVersions()
+	for i := len(agentVersions) - 1; i >= 0; i-- {
+		if _, err := f.GetClient(agentVersions[i]); err == nil {
+			log.Infof("Negotiated Docker API version %s, Docker daemon API version: %s",
+				agentVersions[i], f.daemonAPIVersion)
+			return agentVersions[i]
+		}
+	}
+	log.Warnf("Unable to negotiate the Docker API version, Docker daemon API version: %s. Falling back to version %s",
+		f.daemonAPIVersion, getDefaultVersion())
+	return getDefaultVersion()
 }
 
 func (f *factory) FindSupportedAPIVersions() []dockerclient.DockerVersion {
@@ -119,7 +154,7 @@ func (f *factory) FindClientAPIVersion(client dockeriface.Client) dockerclient.D
 		}
 	}
 
-	return getDefaultVersion()
+	return f.negotiatedVersion
 }
 
 // getClient returns a client specified by the docker version. Its wrapped
@@ -134,8 +169,9 @@ func (f *factory) getClient(version dockerclient.DockerVersion) (dockeriface.Cli
 }
 
 // findDockerVersions loops over all known API versions and finds which ones
-// are supported by the docker daemon on the host
-func findDockerVersions(ctx context.Context, endpoint string) map[dockerclient.DockerVersion]dockeriface.Client {
+// are supported by the docker daemon on the host. It also returns the maximum
+// API version reported by the docker daemon, if any
+func findDockerVersions(ctx context.Context, endpoint string) (map[dockerclient.DockerVersion]dockeriface.Client, string) {
 	// if the client version returns a MinAPIVersion and APIVersion, then use it to return
 	// all the Docker clients between MinAPIVersion and APIVersion, else try pinging
 	// the clients in getKnownAPIVersions
@@ -168,7 +204,7 @@ func findDockerVersions(ctx context.Context, endpoint string) map[dockerclient.D
 		}
 		clients[version] = dockerClient
 	}
-	return clients
+	return clients, apiVersion
 }
 
 func getDockerClientForVersion(
@@ -17,6 +17,7 @@ package clientfactory
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
@@ -33,10 +34,11 @@ func TestGetDefaultClientSuccess(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	agentVersions := getAgentVersions()
 	expectedClient := mock_dockeriface.NewMockClient(ctrl)
 	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
 		mockClient := mock_dockeriface.NewMockClient(ctrl)
-		if version == string(getDefaultVersion()) {
+		if version == string(agentVersions[len(agentVersions)-1]) {
 			mockClient = expectedClient
 		}
 		mockClient.EXPECT().VersionWithContext(gomock.Any()).Return(&docker.Env{}, nil).AnyTimes()
@@ -53,6 +55,51 @@ func TestGetDefaultClientSuccess(t *testing.T) {
 	assert.Equal(t, expectedClient, actualClient)
 }
 
+func TestNegotiateAPIVersionWithDaemonAPIVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClients := make(map[string]*mock_dockeriface.MockClient)
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		mockClient := mock_dockeriface.NewMockClient(ctrl)
+		mockClient.EXPECT().VersionWithContext(gomock.Any()).Return(
+			&docker.Env{"MinAPIVersion=1.12", "ApiVersion=1.24"}, nil).AnyTimes()
+		mockClient.EXPECT().Ping().AnyTimes()
+		mockClients[version] = mockClient
+		return mockClient, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	factory := NewFactory(ctx, expectedEndpoint)
+	assert.Equal(t, dockerclient.Version_1_24, factory.NegotiatedAPIVersion())
+
+	client, err := factory.GetDefaultClient()
+	assert.NoError(t, err)
+	assert.Equal(t, mockClients[string(dockerclient.Version_1_24)], client)
+	assert.Equal(t, dockerclient.Version_1_24, factory.FindClientAPIVersion(client))
+}
+
+func TestNegotiateAPIVersionFallsBackToDefaultVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newVersionedClient = func(endpoint, version string) (dockeriface.Client, error) {
+		mockClient := mock_dockeriface.NewMockClient(ctrl)
+		mockClient.EXPECT().VersionWithContext(gomock.Any()).Return(nil, errors.New("error")).AnyTimes()
+		mockClient.EXPECT().Ping().Return(errors.New("error")).AnyTimes()
+		return mockClient, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	factory := NewFactory(ctx, expectedEndpoint)
+	assert.Equal(t, getDefaultVersion(), factory.NegotiatedAPIVersion())
+
+	_, err := factory.GetDefaultClient()
+	assert.Error(t, err)
+}
+
 func TestFindSupportedAPIVersions(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -0,0 +1,141 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clientfactory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockeriface"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+)
+
+const (
+	// nativeHost is the host of the urls of requests to the docker daemon
+	// over a unix socket or a named pipe, which isn't used to connect
+	nativeHost = "unix.sock"
+	// maxEventsErrorSize bounds how much of the body of a failed events request
+	// is read into the error
+	maxEventsErrorSize = 4 * 1024
+	// containerTypeEvent is the type of the events of containers, the only
+	// events that are listed
+	containerTypeEvent = "container"
+)
+
+// eventsClient adds listing the past events of the docker daemon to the
+// go-dockerclient client, which only streams the events following the
+// addition of a listener
+type eventsClient struct {
+	*docker.Client
+	version string
+}
+
+func newEventsClient(endpoint, version string) (dockeriface.Client, error) {
+	client, err := docker.NewVersionedClient(endpoint, version)
+	if err != nil {
+		return nil, err
+	}
+	return &eventsClient{Client: client, version: version}, nil
+}
+
+// ListEvents returns the container events the docker daemon emitted between
+// since and until, oldest first
+func (c *eventsClient) ListEvents(ctx context.Context, since time.Time, until time.Time) ([]*docker.APIEvents, error) {
+	eventsURL, err := c.eventsURL(since, until)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, eventsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "docker events: unable to list the events")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxEventsErrorSize))
+		return nil, errors.Errorf("docker events: unable to list the events, status %d: %s",
+			resp.StatusCode, string(body))
+	}
+
+	var events []*docker.APIEvents
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		event := &docker.APIEvents{}
+		if err := decoder.Decode(event); err != nil {
+			if err == io.EOF {
+				return events, nil
+			}
+			return nil, errors.Wrap(err, "docker events: unable to decode the events")
+		}
+		// Newer versions of the docker API only describe events with the
+		// fields introduced in version 1.22, while the agent relies on the
+		// fields of older versions like the event stream of go-dockerclient
+		if event.Status == "" {
+			event.Status = event.Action
+		}
+		if event.ID == "" {
+			event.ID = event.Actor.ID
+		}
+		if event.Type == "" {
+			event.Type = containerTypeEvent
+		}
+		events = append(events, event)
+	}
+}
+
+func (c *eventsClient) eventsURL(since time.Time, until time.Time) (string, error) {
+	endpoint, err := url.Parse(c.Endpoint())
+	if err != nil {
+		return "", errors.Wrapf(err, "docker events: unable to parse the docker endpoint %s", c.Endpoint())
+	}
+	eventsURL := url.URL{
+		Scheme: "http",
+		Host:   endpoint.Host,
+		Path:   "/v" + c.version + "/events",
+	}
+	switch endpoint.Scheme {
+	case "unix", "npipe":
+		eventsURL.Host = nativeHost
+	default:
+		if c.TLSConfig != nil {
+			eventsURL.Scheme = "https"
+		}
+	}
+	filters, err := json.Marshal(map[string][]string{"type": {containerTypeEvent}})
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("since", eventsTimestamp(since))
+	query.Set("until", eventsTimestamp(until))
+	query.Set("filters", string(filters))
+	eventsURL.RawQuery = query.Encode()
+	return eventsURL.String(), nil
+}
+
+// eventsTimestamp formats the time as the unix timestamp with nanoseconds that
+// the docker events API expects
+func eventsTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
@@ -0,0 +1,69 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clientfactory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListEvents(t *testing.T) {
+	since := time.Unix(1500000000, 5)
+	until := time.Unix(1500000060, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1.25/events", r.URL.Path)
+		assert.Equal(t, "1500000000.000000005", r.URL.Query().Get("since"))
+		assert.Equal(t, "1500000060.000000000", r.URL.Query().Get("until"))
+		assert.Equal(t, `{"type":["container"]}`, r.URL.Query().Get("filters"))
+		fmt.Fprintln(w, `{"status":"start","id":"cid1","Type":"container","Action":"start","time":1500000001,"timeNano":1500000001000000000}`)
+		fmt.Fprintln(w, `{"Type":"container","Action":"die","Actor":{"ID":"cid2"},"time":1500000002,"timeNano":1500000002000000000}`)
+	}))
+	defer server.Close()
+
+	client, err := newEventsClient("tcp://"+server.Listener.Addr().String(), "1.25")
+	require.NoError(t, err)
+	events, err := client.ListEvents(context.TODO(), since, until)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "cid1", events[0].ID)
+	assert.Equal(t, "start", events[0].Status)
+	assert.Equal(t, int64(1500000001000000000), events[0].TimeNano)
+	// Events only described with the fields of newer API versions are
+	// translated to the fields the agent relies on
+	assert.Equal(t, "cid2", events[1].ID)
+	assert.Equal(t, "die", events[1].Status)
+	assert.Equal(t, "container", events[1].Type)
+}
+
+func TestListEventsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad filters", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client, err := newEventsClient("tcp://"+server.Listener.Addr().String(), "1.25")
+	require.NoError(t, err)
+	_, err = client.ListEvents(context.TODO(), time.Unix(1500000000, 0), time.Unix(1500000060, 0))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad filters")
+}
@@ -109,3 +109,15 @@ func (m *MockFactory) GetDefaultClient() (dockeriface.Client, error) {
 func (mr *MockFactoryMockRecorder) GetDefaultClient() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDefaultClient", reflect.TypeOf((*MockFactory)(nil).GetDefaultClient))
 }
+
+// NegotiatedAPIVersion mocks base method
+func (m *MockFactory) NegotiatedAPIVersion() dockerclient.DockerVersion {
+	ret := m.ctrl.Call(m, "NegotiatedAPIVersion")
+	ret0, _ := ret[0].(dockerclient.DockerVersion)
+	return ret0
+}
+
+// NegotiatedAPIVersion indicates an expected call of NegotiatedAPIVersion
+func (mr *MockFactoryMockRecorder) NegotiatedAPIVersion() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NegotiatedAPIVersion", reflect.TypeOf((*MockFactory)(nil).NegotiatedAPIVersion))
+}
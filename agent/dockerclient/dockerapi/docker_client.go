@@ -19,6 +19,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -33,6 +35,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerauth"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockeriface"
 	"github.com/aws/amazon-ecs-agent/agent/ecr"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 
@@ -46,6 +49,15 @@ const (
 	imageNameFormat = "%s:%s"
 	// the buffer size will ensure agent doesn't miss any event from docker
 	dockerEventBufferSize = 100
+	// Parameters for backing off while the listener of the docker event
+	// stream is added back after the stream was closed
+	eventListenerBackoffMin      = time.Second
+	eventListenerBackoffMax      = 30 * time.Second
+	eventListenerBackoffJitter   = 0.2
+	eventListenerBackoffMultiple = 1.5
+	// listEventsTimeout bounds listing the docker events that were missed
+	// while the docker event stream was closed
+	listEventsTimeout = 30 * time.Second
 	// healthCheckStarting is the initial status returned from docker container health check
 	healthCheckStarting = "starting"
 	// healthCheckHealthy is the healthy status returned from docker container health check
@@ -54,6 +66,9 @@ const (
 	healthCheckUnhealthy = "unhealthy"
 	// maxHealthCheckOutputLength is the maximum length of healthcheck command output that agent will save
 	maxHealthCheckOutputLength = 1024
+	// bridgeNetworkDriver is the name of the docker network driver of the
+	// networks created for tasks
+	bridgeNetworkDriver = "bridge"
 	// VolumeDriverType is one of the plugin capabilities see https://docs.docker.com/engine/reference/commandline/plugin_ls/#filtering
 	VolumeDriverType = "volumedriver"
 )
@@ -61,7 +76,10 @@ const (
 // Timelimits for docker operations enforced above docker
 // TODO: Make these limits configurable.
 const (
-	pullImageTimeout = 2 * time.Hour
+	// pullImageTimeout bounds pulling an image, including retries. Pulls that
+	// hang are detected by the pull inactivity timeout instead, this only
+	// guards against pulls that keep making progress forever
+	pullImageTimeout = 12 * time.Hour
 	// CreateContainerTimeout is the timeout for the CreateContainer API.
 	CreateContainerTimeout = 4 * time.Minute
 	// StopContainerTimeout is the timeout for the StopContainer API.
@@ -80,6 +98,21 @@ const (
 	InspectVolumeTimeout = 5 * time.Minute
 	// RemoveVolumeTimeout is the timout for RemoveVolume API.
 	RemoveVolumeTimeout = 5 * time.Minute
+	// CreateNetworkTimeout is the timeout for the CreateNetwork API.
+	CreateNetworkTimeout = 1 * time.Minute
+	// InspectNetworkTimeout is the timeout for the InspectNetwork API.
+	InspectNetworkTimeout = 30 * time.Second
+	// ConnectNetworkTimeout is the timeout for the ConnectNetwork and
+	// DisconnectNetwork APIs.
+	ConnectNetworkTimeout = 1 * time.Minute
+	// RemoveNetworkTimeout is the timeout for the RemoveNetwork API.
+	RemoveNetworkTimeout = 1 * time.Minute
+	// ListNetworksTimeout is the timeout for the ListNetworks API.
+	ListNetworksTimeout = 1 * time.Minute
+	// ListVolumesTimeout is the timeout for the ListVolumes API.
+	ListVolumesTimeout = 1 * time.Minute
+	// ListImagesTimeout is the timeout for the ListImages API.
+	ListImagesTimeout = 1 * time.Minute
 	// Parameters for caching the docker auth for ECR
 	tokenCacheSize = 100
 	// tokenCacheTTL is the default ttl of the docker auth for ECR
@@ -90,16 +123,15 @@ const (
 	// around a docker bug which sometimes results in pulls not progressing.
 	dockerPullBeginTimeout = 5 * time.Minute
 
-	// pullStatusSuppressDelay controls the time where pull status progress bar
-	// output will be suppressed in debug mode
-	pullStatusSuppressDelay = 2 * time.Second
+	// pullProgressLogInterval controls how often the progress of the layers
+	// of the image being pulled is logged in debug mode
+	pullProgressLogInterval = 10 * time.Second
 
 	// StatsInactivityTimeout controls the amount of time we hold open a
 	// connection to the Docker daemon waiting for stats data
 	StatsInactivityTimeout = 5 * time.Second
 
 	// retry settings for pulling images
-	maximumPullRetries        = 10
 	minimumPullRetryDelay     = 250 * time.Millisecond
 	maximumPullRetryDelay     = 1 * time.Second
 	pullRetryDelayMultiplier  = 1.5
@@ -125,6 +157,11 @@ type DockerClient interface {
 	// PullImage pulls an image. authData should contain authentication data provided by the ECS backend.
 	PullImage(image string, authData *apicontainer.RegistryAuthenticationData) DockerContainerMetadata
 
+	// AuthConfigFileLoadedAt returns when the credentials of registries were last loaded from the docker config
+	// file configured with ECS_ENGINE_AUTH_CONFIG_FILE. It's the zero time if none is configured or it was never
+	// loaded
+	AuthConfigFileLoadedAt() time.Time
+
 	// CreateContainer creates a container with the provided docker.Config, docker.HostConfig, and name. A timeout value
 	// and a context should be provided for the request.
 	CreateContainer(context.Context, *docker.Config, *docker.HostConfig, string, time.Duration) DockerContainerMetadata
@@ -133,10 +170,15 @@ type DockerClient interface {
 	// provided for the request.
 	StartContainer(context.Context, string, time.Duration) DockerContainerMetadata
 
-	// StopContainer stops the container identified by the name provided. A timeout value and a context should be provided
-	// for the request.
+	// StopContainer stops the container identified by the name provided. The time docker waits for the container to
+	// exit before killing it and a context should be provided for the request. The request times out
+	// StopContainerTimeout after the container should have been killed.
 	StopContainer(context.Context, string, time.Duration) DockerContainerMetadata
 
+	// WaitContainer blocks until the container identified by the docker id provided exits and returns its exit
+	// code. The wait isn't bounded by a timeout, the context provided cancels it.
+	WaitContainer(context.Context, string) DockerContainerMetadata
+
 	// DescribeContainer returns status information about the specified container. A context should be provided
 	// for the request
 	DescribeContainer(context.Context, string) (apicontainerstatus.ContainerStatus, DockerContainerMetadata)
@@ -153,6 +195,15 @@ type DockerClient interface {
 	// should be provided for the request.
 	ListContainers(context.Context, bool, time.Duration) ListContainersResponse
 
+	// ContainerSizes returns the size in bytes of the writable layer of every container known to the Docker
+	// daemon, keyed by docker id. A timeout value and a context should be provided for the request.
+	ContainerSizes(context.Context, time.Duration) (map[string]int64, error)
+
+	// ExecCommand runs a command in the container identified by the docker id provided, streaming the input
+	// stream to it and its output to the stdout and stderr writers, and returns the exit code of the command.
+	// The input stream may be nil. A timeout value and a context should be provided for the request.
+	ExecCommand(context.Context, string, []string, io.Reader, io.Writer, io.Writer, time.Duration) (int, error)
+
 	// CreateVolume creates a docker volume. A timeout value should be provided for the request
 	CreateVolume(context.Context, string, string, map[string]string, map[string]string, time.Duration) VolumeResponse
 
@@ -162,6 +213,33 @@ type DockerClient interface {
 	// RemoveVolume removes a volume by its name. A timeout value should be provided for the request
 	RemoveVolume(context.Context, string, time.Duration) error
 
+	// CreateNetwork creates a bridge network with the name and labels provided. A timeout value should be
+	// provided for the request
+	CreateNetwork(context.Context, string, map[string]string, time.Duration) NetworkResponse
+
+	// InspectNetwork returns a network, including the containers connected to it, by its id. A timeout value
+	// should be provided for the request
+	InspectNetwork(context.Context, string, time.Duration) NetworkResponse
+
+	// ConnectNetwork connects the container identified by the docker id provided to the network, where it's
+	// reachable by the aliases provided. A timeout value should be provided for the request
+	ConnectNetwork(context.Context, string, string, []string, time.Duration) error
+
+	// DisconnectNetwork forcibly disconnects the container identified by the docker id provided from the
+	// network. A timeout value should be provided for the request
+	DisconnectNetwork(context.Context, string, string, time.Duration) error
+
+	// RemoveNetwork removes a network by its id. A timeout value should be provided for the request
+	RemoveNetwork(context.Context, string, time.Duration) error
+
+	// ListNetworksWithLabel returns the networks carrying the label provided. A timeout value should be
+	// provided for the request
+	ListNetworksWithLabel(context.Context, string, time.Duration) ([]docker.Network, error)
+
+	// ListVolumesWithLabel returns the volumes carrying the label provided. A timeout value should be
+	// provided for the request
+	ListVolumesWithLabel(context.Context, string, time.Duration) ([]docker.Volume, error)
+
 	// ListPluginsWithFilters returns the set of docker plugins installed on the host, filtered by options provided.
 	// A timeout value should be provided for the request.
 	ListPluginsWithFilters(context.Context, bool, []string, time.Duration) ([]string, error)
@@ -174,15 +252,28 @@ type DockerClient interface {
 	// be canceled.
 	Stats(string, context.Context) (<-chan *docker.Stats, error)
 
+	// Ping checks that the Docker daemon is reachable. A timeout value should be provided for the request.
+	Ping(context.Context, time.Duration) error
+
 	// Version returns the version of the Docker daemon.
 	Version(context.Context, time.Duration) (string, error)
 
+	// StorageDriver returns the name of the storage driver used by the Docker daemon.
+	StorageDriver(context.Context, time.Duration) (string, error)
+
+	// RootDir returns the root directory of the Docker daemon, where it stores images and containers.
+	RootDir(context.Context, time.Duration) (string, error)
+
 	// APIVersion returns the api version of the client
 	APIVersion() (dockerclient.DockerVersion, error)
 
 	// InspectImage returns information about the specified image.
 	InspectImage(string) (*docker.Image, error)
 
+	// ListImages returns the images known to the Docker daemon, excluding intermediate images. A timeout value
+	// and a context should be provided for the request.
+	ListImages(context.Context, time.Duration) ([]docker.APIImages, error)
+
 	// RemoveImage removes the metadata associated with an image and may remove the underlying layer data. A timeout
 	// value and a context should be provided for the request.
 	RemoveImage(context.Context, string, time.Duration) error
@@ -206,18 +297,21 @@ type DockerClient interface {
 //    appropriately there.
 // Implements DockerClient
 type dockerGoClient struct {
-	clientFactory    clientfactory.Factory
-	version          dockerclient.DockerVersion
-	ecrClientFactory ecr.ECRFactory
-	auth             dockerauth.DockerAuthProvider
-	ecrTokenCache    async.Cache
-	config           *config.Config
+	clientFactory clientfactory.Factory
+	version       dockerclient.DockerVersion
+	auth          dockerauth.DockerAuthProvider
+	// ecrAuth is shared by the pulls of all tasks, so that ECR auth tokens
+	// are cached and requested once per registry
+	ecrAuth dockerauth.DockerAuthProvider
+	config  *config.Config
 
 	_time     ttime.Time
 	_timeOnce sync.Once
 
-	daemonVersionUnsafe string
-	lock                sync.Mutex
+	daemonVersionUnsafe       string
+	daemonStorageDriverUnsafe string
+	daemonRootDirUnsafe       string
+	lock                      sync.Mutex
 }
 
 func (dg *dockerGoClient) WithVersion(version dockerclient.DockerVersion) DockerClient {
@@ -225,6 +319,7 @@ func (dg *dockerGoClient) WithVersion(version dockerclient.DockerVersion) Docker
 		clientFactory: dg.clientFactory,
 		version:       version,
 		auth:          dg.auth,
+		ecrAuth:       dg.ecrAuth,
 		config:        dg.config,
 	}
 }
@@ -249,16 +344,22 @@ func NewDockerGoClient(clientFactory clientfactory.Factory, cfg *config.Config)
 		return nil, err
 	}
 
-	var dockerAuthData json.RawMessage
-	if cfg.EngineAuthData != nil {
-		dockerAuthData = cfg.EngineAuthData.Contents()
+	var auth dockerauth.DockerAuthProvider
+	if cfg.EngineAuthConfigFile != "" {
+		auth = dockerauth.NewDockerConfigAuthProvider(cfg.EngineAuthConfigFile)
+	} else {
+		var dockerAuthData json.RawMessage
+		if cfg.EngineAuthData != nil {
+			dockerAuthData = cfg.EngineAuthData.Contents()
+		}
+		auth = dockerauth.NewDockerAuthProvider(cfg.EngineAuthType, dockerAuthData)
 	}
 	return &dockerGoClient{
-		clientFactory:    clientFactory,
-		auth:             dockerauth.NewDockerAuthProvider(cfg.EngineAuthType, dockerAuthData),
-		ecrClientFactory: ecr.NewECRFactory(cfg.AcceptInsecureCert),
-		ecrTokenCache:    async.NewLRUCache(tokenCacheSize, tokenCacheTTL),
-		config:           cfg,
+		clientFactory: clientFactory,
+		auth:          auth,
+		ecrAuth: dockerauth.NewECRAuthProvider(ecr.NewECRFactory(cfg.AcceptInsecureCert),
+			async.NewLRUCache(tokenCacheSize, tokenCacheTTL)),
+		config: cfg,
 	}, nil
 }
 
@@ -279,6 +380,7 @@ func (dg *dockerGoClient) time() ttime.Time {
 }
 
 func (dg *dockerGoClient) PullImage(image string, authData *apicontainer.RegistryAuthenticationData) DockerContainerMetadata {
+	defer metrics.RecordDockerCall("PullImage", time.Now())
 	// TODO Switch to just using context.WithDeadline and get rid of this funky code
 	timeout := dg.time().After(pullImageTimeout)
 	ctx, cancel := context.WithCancel(context.TODO())
@@ -288,15 +390,24 @@ func (dg *dockerGoClient) PullImage(image string, authData *apicontainer.Registr
 	go func() {
 		imagePullBackoff := utils.NewSimpleBackoff(minimumPullRetryDelay,
 			maximumPullRetryDelay, pullRetryJitterMultiplier, pullRetryDelayMultiplier)
-		err := utils.RetryNWithBackoffCtx(ctx, imagePullBackoff, maximumPullRetries,
+		attempts := dg.imagePullAttempts()
+		attempt := 0
+		pullStart := time.Now()
+		err := utils.RetryNWithBackoffCtx(ctx, imagePullBackoff, attempts,
 			func() error {
+				attempt++
+				attemptStart := time.Now()
 				err := dg.pullImage(image, authData)
 				if err != nil {
-					seelog.Warnf("DockerGoClient: failed to pull image %s: %s", image, err.Error())
+					seelog.Warnf("DockerGoClient: attempt %d/%d to pull image %s failed after %s (%s since the first attempt): %s",
+						attempt, attempts, image, time.Since(attemptStart).String(), time.Since(pullStart).String(), err.Error())
+				} else if attempt > 1 {
+					seelog.Infof("DockerGoClient: attempt %d/%d to pull image %s succeeded after %s since the first attempt",
+						attempt, attempts, image, time.Since(pullStart).String())
 				}
 				return err
 			})
-		response <- DockerContainerMetadata{Error: wrapPullErrorAsNamedError(err)}
+		response <- DockerContainerMetadata{Error: wrapFinalPullError(image, err)}
 	}()
 	select {
 	case resp := <-response:
@@ -307,12 +418,32 @@ func (dg *dockerGoClient) PullImage(image string, authData *apicontainer.Registr
 	}
 }
 
+// imagePullAttempts returns the maximum number of attempts made to pull an image
+func (dg *dockerGoClient) imagePullAttempts() int {
+	if dg.config.ImagePullAttempts < 1 {
+		return config.DefaultImagePullAttempts
+	}
+	return dg.config.ImagePullAttempts
+}
+
+// wrapFinalPullError wraps the error of the last image pull attempt into a
+// CannotPullContainerError that names the image, unless the error already
+// describes a more specific pull failure
+func wrapFinalPullError(image string, err error) apierrors.NamedError {
+	namedErr := wrapPullErrorAsNamedError(err)
+	if pullErr, ok := namedErr.(CannotPullContainerError); ok {
+		pullErr.Image = image
+		return pullErr
+	}
+	return namedErr
+}
+
 func wrapPullErrorAsNamedError(err error) apierrors.NamedError {
 	var retErr apierrors.NamedError
 	if err != nil {
 		engErr, ok := err.(apierrors.NamedError)
 		if !ok {
-			engErr = CannotPullContainerError{err}
+			engErr = CannotPullContainerError{FromError: err}
 		}
 		retErr = engErr
 	}
@@ -336,17 +467,20 @@ func (dg *dockerGoClient) pullImage(image string, authData *apicontainer.Registr
 
 	repository := getRepository(image)
 
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
 	opts := docker.PullImageOptions{
-		Repository:        repository,
-		OutputStream:      pullWriter,
-		InactivityTimeout: dg.config.ImagePullInactivityTimeout,
+		Repository:   repository,
+		OutputStream: pullWriter,
+		Context:      ctx,
 	}
 	timeout := dg.time().After(dockerPullBeginTimeout)
-	// pullBegan is a channel indicating that we have seen at least one line of data on the 'OutputStream' above.
-	// It is here to guard against a bug wherein Docker never writes anything to that channel and hangs in pulling forever.
-	pullBegan := make(chan bool, 1)
+	// pullProgress is a channel indicating that we have seen a line of data on the 'OutputStream' above.
+	// The first line guards against a bug wherein Docker never writes anything to that channel and hangs
+	// in pulling forever. The following lines reset the pull inactivity timeout.
+	pullProgress := make(chan struct{}, 1)
 
-	go dg.filterPullDebugOutput(pullDebugOut, pullBegan, image)
+	go dg.filterPullDebugOutput(pullDebugOut, pullProgress, image)
 
 	pullFinished := make(chan error, 1)
 	go func() {
@@ -354,11 +488,11 @@ func (dg *dockerGoClient) pullImage(image string, authData *apicontainer.Registr
 	}()
 
 	select {
-	case <-pullBegan:
+	case <-pullProgress:
 		break
 	case pullErr := <-pullFinished:
 		if pullErr != nil {
-			return CannotPullContainerError{pullErr}
+			return CannotPullContainerError{FromError: pullErr}
 		}
 		seelog.Debugf("DockerGoClient: pulling image complete: %s", image)
 		return nil
@@ -367,37 +501,82 @@ func (dg *dockerGoClient) pullImage(image string, authData *apicontainer.Registr
 	}
 	seelog.Debugf("DockerGoClient: pull began for image: %s", image)
 
-	err = <-pullFinished
+	err = dg.waitForPull(image, pullProgress, pullFinished, cancel)
 	if err != nil {
-		return CannotPullContainerError{err}
+		return wrapPullErrorAsNamedError(err)
 	}
 
 	seelog.Debugf("DockerGoClient: pulling image complete: %s", image)
 	return nil
 }
 
-func (dg *dockerGoClient) filterPullDebugOutput(pullDebugOut *io.PipeReader, pullBegan chan<- bool, image string) {
-	// pullBeganOnce ensures we only indicate it began once (since our channel will only be read 0 or 1 times)
-	pullBeganOnce := sync.Once{}
+// waitForPull waits for the pull to finish. The pull is canceled when no
+// progress is reported for the pull inactivity timeout
+func (dg *dockerGoClient) waitForPull(image string, pullProgress <-chan struct{}, pullFinished <-chan error,
+	cancel context.CancelFunc) error {
+	inactivityTimeout := dg.config.ImagePullInactivityTimeout
+	if inactivityTimeout <= 0 {
+		if err := <-pullFinished; err != nil {
+			return CannotPullContainerError{FromError: err}
+		}
+		return nil
+	}
+
+	inactive := make(chan struct{}, 1)
+	inactivityTimer := dg.time().AfterFunc(inactivityTimeout, func() {
+		select {
+		case inactive <- struct{}{}:
+		default:
+		}
+	})
+	defer inactivityTimer.Stop()
+	for {
+		select {
+		case <-pullProgress:
+			inactivityTimer.Reset(inactivityTimeout)
+		case err := <-pullFinished:
+			if err != nil {
+				return CannotPullContainerError{FromError: err}
+			}
+			return nil
+		case <-inactive:
+			seelog.Warnf("DockerGoClient: no progress pulling image %s for %s, canceling the pull",
+				image, inactivityTimeout.String())
+			cancel()
+			return &PullInactivityTimeoutError{Duration: inactivityTimeout, Image: image}
+		}
+	}
+}
 
+func (dg *dockerGoClient) filterPullDebugOutput(pullDebugOut *io.PipeReader, pullProgress chan<- struct{}, image string) {
 	reader := bufio.NewReader(pullDebugOut)
 	var line string
 	var pullErr error
-	var statusDisplayed time.Time
+	// layerProgress is the latest status of each layer of the image
+	layerProgress := make(map[string]string)
+	var progressLogged time.Time
 	for {
 		line, pullErr = reader.ReadString('\n')
 		if pullErr != nil {
 			break
 		}
-		pullBeganOnce.Do(func() {
-			pullBegan <- true
-		})
+		select {
+		case pullProgress <- struct{}{}:
+		default:
+			// A progress notification is already pending
+		}
 
-		now := time.Now()
-		if !strings.Contains(line, "[=") || now.After(statusDisplayed.Add(pullStatusSuppressDelay)) {
-			// skip most of the progress bar lines, but retain enough for debugging
+		if layerID, status, ok := parseLayerProgress(line); ok {
+			layerProgress[layerID] = status
+		}
+		if !strings.Contains(line, "[=") {
+			// Progress bars are only logged as part of the layer progress below
 			seelog.Debugf("DockerGoClient: pulling image %s, status %s", image, line)
-			statusDisplayed = now
+		}
+		now := time.Now()
+		if len(layerProgress) > 0 && now.After(progressLogged.Add(pullProgressLogInterval)) {
+			logLayerProgress(image, layerProgress)
+			progressLogged = now
 		}
 
 		if strings.Contains(line, "already being pulled by another client. Waiting.") {
@@ -411,6 +590,31 @@ func (dg *dockerGoClient) filterPullDebugOutput(pullDebugOut *io.PipeReader, pul
 	}
 }
 
+// layerProgressLine matches the lines of the pull progress stream reporting
+// the status of a layer, e.g. "d1bd2f0ce2b4: Downloading [==>   ] 1.2MB/20MB"
+var layerProgressLine = regexp.MustCompile(`^([0-9a-f]{12}): (.*)$`)
+
+// parseLayerProgress returns the layer and the status reported by a line of the
+// pull progress stream, if it reports the status of a layer
+func parseLayerProgress(line string) (string, string, bool) {
+	match := layerProgressLine.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+func logLayerProgress(image string, layerProgress map[string]string) {
+	layers := make([]string, 0, len(layerProgress))
+	for layerID := range layerProgress {
+		layers = append(layers, layerID)
+	}
+	sort.Strings(layers)
+	for _, layerID := range layers {
+		seelog.Debugf("DockerGoClient: pulling image %s, layer %s: %s", image, layerID, layerProgress[layerID])
+	}
+}
+
 func getRepository(image string) string {
 	repository, tag := parseRepositoryTag(image)
 	if tag == "" {
@@ -421,7 +625,16 @@ func getRepository(image string) string {
 	return repository
 }
 
+func (dg *dockerGoClient) AuthConfigFileLoadedAt() time.Time {
+	authProvider, ok := dg.auth.(dockerauth.DockerConfigAuthProvider)
+	if !ok {
+		return time.Time{}
+	}
+	return authProvider.LoadedAt()
+}
+
 func (dg *dockerGoClient) InspectImage(image string) (*docker.Image, error) {
+	defer metrics.RecordDockerCall("InspectImage", time.Now())
 	client, err := dg.dockerClient()
 	if err != nil {
 		return nil, err
@@ -437,8 +650,7 @@ func (dg *dockerGoClient) getAuthdata(image string, authData *apicontainer.Regis
 
 	switch authData.Type {
 	case apicontainer.AuthTypeECR:
-		provider := dockerauth.NewECRAuthProvider(dg.ecrClientFactory, dg.ecrTokenCache)
-		authConfig, err := provider.GetAuthconfig(image, authData)
+		authConfig, err := dg.ecrAuth.GetAuthconfig(image, authData)
 		if err != nil {
 			return authConfig, CannotPullECRContainerError{err}
 		}
@@ -457,6 +669,7 @@ func (dg *dockerGoClient) CreateContainer(ctx context.Context,
 	hostConfig *docker.HostConfig,
 	name string,
 	timeout time.Duration) DockerContainerMetadata {
+	defer metrics.RecordDockerCall("CreateContainer", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -506,6 +719,7 @@ func (dg *dockerGoClient) createContainer(ctx context.Context,
 }
 
 func (dg *dockerGoClient) StartContainer(ctx context.Context, id string, timeout time.Duration) DockerContainerMetadata {
+	defer metrics.RecordDockerCall("StartContainer", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -569,6 +783,7 @@ func (dg *dockerGoClient) DescribeContainer(ctx context.Context, dockerID string
 }
 
 func (dg *dockerGoClient) InspectContainer(ctx context.Context, dockerID string, timeout time.Duration) (*docker.Container, error) {
+	defer metrics.RecordDockerCall("InspectContainer", time.Now())
 	type inspectResponse struct {
 		container *docker.Container
 		err       error
@@ -606,14 +821,16 @@ func (dg *dockerGoClient) inspectContainer(ctx context.Context, dockerID string)
 	return client.InspectContainerWithContext(dockerID, ctx)
 }
 
-func (dg *dockerGoClient) StopContainer(ctx context.Context, dockerID string, timeout time.Duration) DockerContainerMetadata {
+func (dg *dockerGoClient) StopContainer(ctx context.Context, dockerID string, stopTimeout time.Duration) DockerContainerMetadata {
+	defer metrics.RecordDockerCall("StopContainer", time.Now())
+	timeout := stopTimeout + StopContainerTimeout
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// Buffered channel so in the case of timeout it takes one write, never gets
 	// read, and can still be GC'd
 	response := make(chan DockerContainerMetadata, 1)
-	go func() { response <- dg.stopContainer(ctx, dockerID) }()
+	go func() { response <- dg.stopContainer(ctx, dockerID, stopTimeout) }()
 	select {
 	case resp := <-response:
 		return resp
@@ -628,13 +845,13 @@ func (dg *dockerGoClient) StopContainer(ctx context.Context, dockerID string, ti
 	}
 }
 
-func (dg *dockerGoClient) stopContainer(ctx context.Context, dockerID string) DockerContainerMetadata {
+func (dg *dockerGoClient) stopContainer(ctx context.Context, dockerID string, stopTimeout time.Duration) DockerContainerMetadata {
 	client, err := dg.dockerClient()
 	if err != nil {
 		return DockerContainerMetadata{Error: CannotGetDockerClientError{version: dg.version, err: err}}
 	}
 
-	err = client.StopContainerWithContext(dockerID, uint(dg.config.DockerStopTimeout/time.Second), ctx)
+	err = client.StopContainerWithContext(dockerID, uint(stopTimeout/time.Second), ctx)
 	metadata := dg.containerMetadata(ctx, dockerID)
 	if err != nil {
 		seelog.Infof("DockerGoClient: error stopping container %s: %v", dockerID, err)
@@ -645,7 +862,21 @@ func (dg *dockerGoClient) stopContainer(ctx context.Context, dockerID string) Do
 	return metadata
 }
 
+func (dg *dockerGoClient) WaitContainer(ctx context.Context, dockerID string) DockerContainerMetadata {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return DockerContainerMetadata{Error: CannotGetDockerClientError{version: dg.version, err: err}}
+	}
+
+	exitCode, err := client.WaitContainerWithContext(dockerID, ctx)
+	if err != nil {
+		return DockerContainerMetadata{DockerID: dockerID, Error: CannotWaitContainerError{err}}
+	}
+	return DockerContainerMetadata{DockerID: dockerID, ExitCode: &exitCode}
+}
+
 func (dg *dockerGoClient) RemoveContainer(ctx context.Context, dockerID string, timeout time.Duration) error {
+	defer metrics.RecordDockerCall("RemoveContainer", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -745,7 +976,11 @@ func getMetadataHealthCheck(dockerContainer *docker.Container) apicontainer.Heal
 			size = maxHealthCheckOutputLength
 		}
 		health.Output = output[:size]
+		if end := dockerContainer.State.Health.Log[logLength-1].End; !end.IsZero() {
+			health.LastCheckedAt = &end
+		}
 	}
+	health.FailingStreak = dockerContainer.State.Health.FailingStreak
 
 	switch dockerContainer.State.Health.Status {
 	case healthCheckHealthy:
@@ -769,36 +1004,119 @@ func (dg *dockerGoClient) ContainerEvents(ctx context.Context) (<-chan DockerCon
 	if err != nil {
 		return nil, err
 	}
-	dockerEvents := make(chan *docker.APIEvents, dockerEventBufferSize)
+	dockerEvents, err := addEventListener(ctx, client)
+	if err != nil {
+		seelog.Errorf("DockerGoClient: unable to add a docker event listener: %v", err)
+		return nil, err
+	}
+
 	events := make(chan *docker.APIEvents)
 	buffer := NewInfiniteBuffer()
+	changedContainers := make(chan DockerContainerChangeEvent)
+	cursor := newEventCursor()
+
+	// Cache the event from go docker client
+	go dg.listenForEvents(ctx, client, dockerEvents, buffer, cursor, changedContainers)
+	// Read the buffered events and send to task engine
+	go buffer.Consume(events)
+
+	go dg.handleContainerEvents(ctx, events, cursor, changedContainers)
+	return changedContainers, nil
+}
 
-	err = client.AddEventListener(dockerEvents)
+// addEventListener adds a listener of the docker event stream, which is removed
+// once the context is done
+func addEventListener(ctx context.Context, client dockeriface.Client) (chan *docker.APIEvents, error) {
+	dockerEvents := make(chan *docker.APIEvents, dockerEventBufferSize)
+	err := client.AddEventListener(dockerEvents)
 	if err != nil {
-		seelog.Errorf("DockerGoClient: unable to add a docker event listener: %v", err)
 		return nil, err
 	}
 	go func() {
 		<-ctx.Done()
 		client.RemoveEventListener(dockerEvents)
 	}()
+	return dockerEvents, nil
+}
 
-	// Cache the event from go docker client
-	go buffer.StartListening(dockerEvents)
-	// Read the buffered events and send to task engine
-	go buffer.Consume(events)
+// listenForEvents copies the events of the docker event stream into the
+// buffer. The docker client closes the listeners when the stream ends, which
+// happens when the docker daemon restarts. The listener is then added back, the
+// events since the last processed event are replayed and an
+// EventStreamReconnectedEvent is sent, in case events were missed anyway
+func (dg *dockerGoClient) listenForEvents(ctx context.Context,
+	client dockeriface.Client,
+	dockerEvents chan *docker.APIEvents,
+	buffer *InfiniteBuffer,
+	cursor *eventCursor,
+	changedContainers chan<- DockerContainerChangeEvent) {
+	for {
+		buffer.StartListening(dockerEvents)
+		if ctx.Err() != nil {
+			return
+		}
 
-	changedContainers := make(chan DockerContainerChangeEvent)
-	go dg.handleContainerEvents(ctx, events, changedContainers)
-	return changedContainers, nil
+		seelog.Warn("DockerGoClient: docker event stream closed, adding the docker event listener back")
+		backoff := utils.NewSimpleBackoff(eventListenerBackoffMin, eventListenerBackoffMax,
+			eventListenerBackoffJitter, eventListenerBackoffMultiple)
+		utils.RetryWithBackoffCtx(ctx, backoff, func() error {
+			var err error
+			dockerEvents, err = addEventListener(ctx, client)
+			if err != nil {
+				seelog.Warnf("DockerGoClient: unable to add a docker event listener: %v", err)
+			}
+			return err
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		dg.replayEvents(ctx, client, buffer, cursor)
+
+		select {
+		case changedContainers <- DockerContainerChangeEvent{Type: apicontainer.EventStreamReconnectedEvent}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// replayEvents copies the events the docker daemon emitted since the last
+// processed event into the buffer. They're listed after the listener is added
+// back, so that no event is missed between the two. Events that are both
+// replayed and received by the listener are only processed once
+func (dg *dockerGoClient) replayEvents(ctx context.Context,
+	client dockeriface.Client,
+	buffer *InfiniteBuffer,
+	cursor *eventCursor) {
+	since := cursor.since()
+	if since.IsZero() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, listEventsTimeout)
+	defer cancel()
+
+	events, err := client.ListEvents(ctx, since, dg.time().Now())
+	if err != nil {
+		seelog.Warnf("DockerGoClient: unable to replay the docker events since %s: %v", since.String(), err)
+		return
+	}
+	seelog.Infof("DockerGoClient: replaying %d docker events since %s", len(events), since.String())
+	for _, event := range events {
+		buffer.CopyEvents(event)
+	}
 }
 
 func (dg *dockerGoClient) handleContainerEvents(ctx context.Context,
 	events <-chan *docker.APIEvents,
+	cursor *eventCursor,
 	changedContainers chan<- DockerContainerChangeEvent) {
 	for event := range events {
 		containerID := event.ID
 		seelog.Debugf("DockerGoClient: got event from docker daemon: %v", event)
+		if !cursor.record(event) {
+			seelog.Debugf("DockerGoClient: ignoring docker event that was already processed: %v", event)
+			continue
+		}
 
 		var status apicontainerstatus.ContainerStatus
 		eventType := apicontainer.ContainerStatusEvent
@@ -854,6 +1172,7 @@ func (dg *dockerGoClient) handleContainerEvents(ctx context.Context,
 
 // ListContainers returns a slice of container IDs.
 func (dg *dockerGoClient) ListContainers(ctx context.Context, all bool, timeout time.Duration) ListContainersResponse {
+	defer metrics.RecordDockerCall("ListContainers", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -899,6 +1218,124 @@ func (dg *dockerGoClient) listContainers(ctx context.Context, all bool) ListCont
 	return ListContainersResponse{DockerIDs: containerIDs, Error: nil}
 }
 
+// ContainerSizes returns the size of the writable layer of every container,
+// keyed by docker id
+func (dg *dockerGoClient) ContainerSizes(ctx context.Context, timeout time.Duration) (map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type sizesResponse struct {
+		sizes map[string]int64
+		err   error
+	}
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan sizesResponse, 1)
+	go func() {
+		sizes, err := dg.containerSizes(ctx)
+		response <- sizesResponse{sizes, err}
+	}()
+	select {
+	case resp := <-response:
+		return resp.sizes, resp.err
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return nil, &DockerTimeoutError{timeout, "listing container sizes"}
+		}
+		return nil, &CannotListContainersError{err}
+	}
+}
+
+func (dg *dockerGoClient) containerSizes(ctx context.Context) (map[string]int64, error) {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Size:    true,
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, &CannotListContainersError{err}
+	}
+
+	sizes := make(map[string]int64, len(containers))
+	for _, container := range containers {
+		sizes[container.ID] = container.SizeRw
+	}
+	return sizes, nil
+}
+
+// ExecCommand creates an exec instance running the command in the container,
+// attaches to it until the command exits and returns the exit code of the
+// command
+func (dg *dockerGoClient) ExecCommand(ctx context.Context,
+	dockerID string,
+	cmd []string,
+	stdin io.Reader,
+	stdout io.Writer,
+	stderr io.Writer,
+	timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := dg.dockerClient()
+	if err != nil {
+		return 0, err
+	}
+
+	exec, err := client.CreateExec(docker.CreateExecOptions{
+		Container:    dockerID,
+		Cmd:          cmd,
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Context:      ctx,
+	})
+	if err != nil {
+		return 0, &CannotExecCommandError{err}
+	}
+
+	waiter, err := client.StartExecNonBlocking(exec.ID, docker.StartExecOptions{
+		InputStream:  stdin,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+	})
+	if err != nil {
+		return 0, &CannotExecCommandError{err}
+	}
+	// The attached streams aren't bound to the context, the connection to
+	// docker is closed instead if the context is done first
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	attached := make(chan error, 1)
+	go func() {
+		attached <- waiter.Wait()
+	}()
+	select {
+	case err := <-attached:
+		if err != nil {
+			return 0, &CannotExecCommandError{err}
+		}
+	case <-ctx.Done():
+		waiter.Close()
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return 0, &DockerTimeoutError{timeout, "exec command"}
+		}
+		return 0, &CannotExecCommandError{err}
+	}
+
+	inspect, err := client.InspectExec(exec.ID)
+	if err != nil {
+		return 0, &CannotExecCommandError{err}
+	}
+	return inspect.ExitCode, nil
+}
+
 func (dg *dockerGoClient) SupportedVersions() []dockerclient.DockerVersion {
 	return dg.clientFactory.FindSupportedAPIVersions()
 }
@@ -907,6 +1344,19 @@ func (dg *dockerGoClient) KnownVersions() []dockerclient.DockerVersion {
 	return dg.clientFactory.FindKnownAPIVersions()
 }
 
+// Ping checks that the docker daemon responds to requests
+func (dg *dockerGoClient) Ping(ctx context.Context, timeout time.Duration) error {
+	defer metrics.RecordDockerCall("Ping", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := dg.dockerClient()
+	if err != nil {
+		return err
+	}
+	return client.PingWithContext(ctx)
+}
+
 func (dg *dockerGoClient) Version(ctx context.Context, timeout time.Duration) (string, error) {
 	version := dg.getDaemonVersion()
 	if version != "" {
@@ -944,6 +1394,98 @@ func (dg *dockerGoClient) setDaemonVersion(version string) {
 	dg.daemonVersionUnsafe = version
 }
 
+// StorageDriver returns the name of the storage driver of the docker daemon.
+// The result is cached as the driver can't change without restarting docker
+func (dg *dockerGoClient) StorageDriver(ctx context.Context, timeout time.Duration) (string, error) {
+	driver := dg.getDaemonStorageDriver()
+	if driver != "" {
+		return driver, nil
+	}
+
+	info, err := dg.info(ctx, timeout)
+	if err != nil {
+		return "", err
+	}
+	dg.setDaemonStorageDriver(info.Driver)
+	return info.Driver, nil
+}
+
+// RootDir returns the root directory of the docker daemon. The result is cached
+// as the directory can't change without restarting docker
+func (dg *dockerGoClient) RootDir(ctx context.Context, timeout time.Duration) (string, error) {
+	rootDir := dg.getDaemonRootDir()
+	if rootDir != "" {
+		return rootDir, nil
+	}
+
+	info, err := dg.info(ctx, timeout)
+	if err != nil {
+		return "", err
+	}
+	dg.setDaemonRootDir(info.DockerRootDir)
+	return info.DockerRootDir, nil
+}
+
+func (dg *dockerGoClient) info(ctx context.Context, timeout time.Duration) (*docker.DockerInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := dg.dockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	type infoResponse struct {
+		info *docker.DockerInfo
+		err  error
+	}
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan infoResponse, 1)
+	go func() {
+		info, err := client.Info()
+		response <- infoResponse{info, err}
+	}()
+	select {
+	case resp := <-response:
+		return resp.info, resp.err
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return nil, &DockerTimeoutError{timeout, "info"}
+		}
+		return nil, err
+	}
+}
+
+func (dg *dockerGoClient) getDaemonStorageDriver() string {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	return dg.daemonStorageDriverUnsafe
+}
+
+func (dg *dockerGoClient) setDaemonStorageDriver(driver string) {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	dg.daemonStorageDriverUnsafe = driver
+}
+
+func (dg *dockerGoClient) getDaemonRootDir() string {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	return dg.daemonRootDirUnsafe
+}
+
+func (dg *dockerGoClient) setDaemonRootDir(rootDir string) {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	dg.daemonRootDirUnsafe = rootDir
+}
+
 func (dg *dockerGoClient) CreateVolume(ctx context.Context, name string,
 	driver string,
 	driverOptions map[string]string,
@@ -1081,6 +1623,331 @@ func (dg *dockerGoClient) removeVolume(ctx context.Context, name string) error {
 	return nil
 }
 
+func (dg *dockerGoClient) CreateNetwork(ctx context.Context, name string, labels map[string]string,
+	timeout time.Duration) NetworkResponse {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan NetworkResponse, 1)
+	go func() { response <- dg.createNetwork(ctx, name, labels) }()
+
+	// Wait until we get a response or for the 'done' context channel
+	select {
+	case resp := <-response:
+		return resp
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return NetworkResponse{Error: &DockerTimeoutError{timeout, "creating network"}}
+		}
+		return NetworkResponse{Error: &CannotCreateNetworkError{err}}
+	}
+}
+
+func (dg *dockerGoClient) createNetwork(ctx context.Context, name string, labels map[string]string) NetworkResponse {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return NetworkResponse{Error: &CannotGetDockerClientError{version: dg.version, err: err}}
+	}
+
+	dockerNetwork, err := client.CreateNetwork(docker.CreateNetworkOptions{
+		Name:           name,
+		Driver:         bridgeNetworkDriver,
+		Labels:         labels,
+		CheckDuplicate: true,
+		Context:        ctx,
+	})
+	if err != nil {
+		return NetworkResponse{Error: &CannotCreateNetworkError{err}}
+	}
+	return NetworkResponse{DockerNetwork: dockerNetwork}
+}
+
+func (dg *dockerGoClient) InspectNetwork(ctx context.Context, networkID string, timeout time.Duration) NetworkResponse {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan NetworkResponse, 1)
+	go func() { response <- dg.inspectNetwork(networkID) }()
+
+	// Wait until we get a response or for the 'done' context channel
+	select {
+	case resp := <-response:
+		return resp
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return NetworkResponse{Error: &DockerTimeoutError{timeout, "inspecting network"}}
+		}
+		return NetworkResponse{Error: &CannotInspectNetworkError{err}}
+	}
+}
+
+func (dg *dockerGoClient) inspectNetwork(networkID string) NetworkResponse {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return NetworkResponse{Error: &CannotGetDockerClientError{version: dg.version, err: err}}
+	}
+
+	dockerNetwork, err := client.NetworkInfo(networkID)
+	if err != nil {
+		return NetworkResponse{Error: &CannotInspectNetworkError{err}}
+	}
+	return NetworkResponse{DockerNetwork: dockerNetwork}
+}
+
+func (dg *dockerGoClient) ConnectNetwork(ctx context.Context, networkID string, dockerID string, aliases []string,
+	timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan error, 1)
+	go func() {
+		response <- dg.connectNetwork(networkID, docker.NetworkConnectionOptions{
+			Container:      dockerID,
+			EndpointConfig: &docker.EndpointConfig{Aliases: aliases},
+			Context:        ctx,
+		})
+	}()
+
+	// Wait until we get a response or for the 'done' context channel
+	select {
+	case resp := <-response:
+		return resp
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return &DockerTimeoutError{timeout, "connecting container to network"}
+		}
+		return &CannotConnectNetworkError{err}
+	}
+}
+
+func (dg *dockerGoClient) connectNetwork(networkID string, opts docker.NetworkConnectionOptions) error {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return &CannotGetDockerClientError{version: dg.version, err: err}
+	}
+
+	if err := client.ConnectNetwork(networkID, opts); err != nil {
+		return &CannotConnectNetworkError{err}
+	}
+	return nil
+}
+
+func (dg *dockerGoClient) DisconnectNetwork(ctx context.Context, networkID string, dockerID string,
+	timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan error, 1)
+	go func() { response <- dg.disconnectNetwork(networkID, dockerID) }()
+
+	// Wait until we get a response or for the 'done' context channel
+	select {
+	case resp := <-response:
+		return resp
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return &DockerTimeoutError{timeout, "disconnecting container from network"}
+		}
+		return &CannotDisconnectNetworkError{err}
+	}
+}
+
+func (dg *dockerGoClient) disconnectNetwork(networkID string, dockerID string) error {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return &CannotGetDockerClientError{version: dg.version, err: err}
+	}
+
+	err = client.DisconnectNetwork(networkID, docker.NetworkConnectionOptions{
+		Container: dockerID,
+		Force:     true,
+	})
+	if err != nil {
+		return &CannotDisconnectNetworkError{err}
+	}
+	return nil
+}
+
+func (dg *dockerGoClient) RemoveNetwork(ctx context.Context, networkID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan error, 1)
+	go func() { response <- dg.removeNetwork(networkID) }()
+
+	// Wait until we get a response or for the 'done' context channel
+	select {
+	case resp := <-response:
+		return resp
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return &DockerTimeoutError{timeout, "removing network"}
+		}
+		return &CannotRemoveNetworkError{err}
+	}
+}
+
+func (dg *dockerGoClient) removeNetwork(networkID string) error {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return &CannotGetDockerClientError{version: dg.version, err: err}
+	}
+
+	if err := client.RemoveNetwork(networkID); err != nil {
+		return &CannotRemoveNetworkError{err}
+	}
+	return nil
+}
+
+// ListNetworksWithLabel returns the networks carrying the label, whatever its value
+func (dg *dockerGoClient) ListNetworksWithLabel(ctx context.Context, label string, timeout time.Duration) ([]docker.Network, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type networksResponse struct {
+		networks []docker.Network
+		err      error
+	}
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan networksResponse, 1)
+	go func() {
+		networks, err := dg.listNetworksWithLabel(label)
+		response <- networksResponse{networks, err}
+	}()
+
+	// Wait until we get a response or for the 'done' context channel
+	select {
+	case resp := <-response:
+		return resp.networks, resp.err
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return nil, &DockerTimeoutError{timeout, "listing networks"}
+		}
+		return nil, &CannotListNetworksError{err}
+	}
+}
+
+func (dg *dockerGoClient) listNetworksWithLabel(label string) ([]docker.Network, error) {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return nil, &CannotGetDockerClientError{version: dg.version, err: err}
+	}
+
+	networks, err := client.FilteredListNetworks(docker.NetworkFilterOpts{
+		"label": {label: true},
+	})
+	if err != nil {
+		return nil, &CannotListNetworksError{err}
+	}
+	return networks, nil
+}
+
+// ListVolumesWithLabel returns the volumes carrying the label, whatever its value
+func (dg *dockerGoClient) ListVolumesWithLabel(ctx context.Context, label string, timeout time.Duration) ([]docker.Volume, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type volumesResponse struct {
+		volumes []docker.Volume
+		err     error
+	}
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan volumesResponse, 1)
+	go func() {
+		volumes, err := dg.listVolumesWithLabel(ctx, label)
+		response <- volumesResponse{volumes, err}
+	}()
+
+	// Wait until we get a response or for the 'done' context channel
+	select {
+	case resp := <-response:
+		return resp.volumes, resp.err
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return nil, &DockerTimeoutError{timeout, "listing volumes"}
+		}
+		return nil, &CannotListVolumesError{err}
+	}
+}
+
+func (dg *dockerGoClient) listVolumesWithLabel(ctx context.Context, label string) ([]docker.Volume, error) {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return nil, &CannotGetDockerClientError{version: dg.version, err: err}
+	}
+
+	volumes, err := client.ListVolumes(docker.ListVolumesOptions{
+		Filters: map[string][]string{"label": {label}},
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, &CannotListVolumesError{err}
+	}
+	return volumes, nil
+}
+
+func (dg *dockerGoClient) ListImages(ctx context.Context, timeout time.Duration) ([]docker.APIImages, error) {
+	defer metrics.RecordDockerCall("ListImages", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type imagesResponse struct {
+		images []docker.APIImages
+		err    error
+	}
+	// Buffered channel so in the case of timeout it takes one write, never gets
+	// read, and can still be GC'd
+	response := make(chan imagesResponse, 1)
+	go func() {
+		images, err := dg.listImages(ctx)
+		response <- imagesResponse{images, err}
+	}()
+
+	// Wait until we get a response or for the 'done' context channel
+	select {
+	case resp := <-response:
+		return resp.images, resp.err
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			return nil, &DockerTimeoutError{timeout, "listing images"}
+		}
+		return nil, &CannotListImagesError{err}
+	}
+}
+
+func (dg *dockerGoClient) listImages(ctx context.Context) ([]docker.APIImages, error) {
+	client, err := dg.dockerClient()
+	if err != nil {
+		return nil, &CannotGetDockerClientError{version: dg.version, err: err}
+	}
+
+	images, err := client.ListImages(docker.ListImagesOptions{Context: ctx})
+	if err != nil {
+		return nil, &CannotListImagesError{err}
+	}
+	return images, nil
+}
+
 // ListPluginsWithFilters currently is a convenience method as go-dockerclient doesn't implement fitered list. When we or someone else submits
 // PR for the fix we will refactor this to pass in the fiters. See https://docs.docker.com/engine/reference/commandline/plugin_ls/#filtering.
 func (dg *dockerGoClient) ListPluginsWithFilters(ctx context.Context, enabled bool, capabilities []string, timeout time.Duration) ([]string, error) {
@@ -1190,6 +2057,7 @@ func (dg *dockerGoClient) Stats(id string, ctx context.Context) (<-chan *docker.
 // RemoveImage invokes github.com/fsouza/go-dockerclient.Client's
 // RemoveImage API with a timeout
 func (dg *dockerGoClient) RemoveImage(ctx context.Context, imageName string, timeout time.Duration) error {
+	defer metrics.RecordDockerCall("RemoveImage", time.Now())
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -16,10 +16,12 @@
 package dockerapi
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"strconv"
 	"sync"
@@ -29,10 +31,12 @@ import (
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	"github.com/aws/amazon-ecs-agent/agent/async"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/clientfactory/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerauth"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockeriface/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/ec2"
 	"github.com/aws/amazon-ecs-agent/agent/ecr/mocks"
@@ -84,7 +88,7 @@ func dockerClientSetupWithConfig(t *testing.T, conf config.Config) (
 	client, _ := NewDockerGoClient(factory, &conf)
 	goClient, _ := client.(*dockerGoClient)
 	ecrClientFactory := mock_ecr.NewMockECRFactory(ctrl)
-	goClient.ecrClientFactory = ecrClientFactory
+	goClient.ecrAuth = dockerauth.NewECRAuthProvider(ecrClientFactory, async.NewLRUCache(tokenCacheSize, tokenCacheTTL))
 	goClient._time = mockTime
 	return mockDocker, goClient, mockTime, ctrl, ecrClientFactory, ctrl.Finish
 }
@@ -116,7 +120,7 @@ func TestPullImageOutputTimeout(t *testing.T) {
 			pullBeginTimeout <- time.Now()
 			wait.Wait()
 			// Don't return, verify timeout happens
-		}).Times(maximumPullRetries) // expected number of retries
+		}).Times(config.DefaultImagePullAttempts) // expected number of retries
 
 	metadata := client.PullImage("image", nil)
 	if metadata.Error == nil {
@@ -131,13 +135,18 @@ func TestPullImageOutputTimeout(t *testing.T) {
 }
 
 func TestPullImageGlobalTimeout(t *testing.T) {
-	mockDocker, client, testTime, _, _, done := dockerClientSetup(t)
+	mockDocker, client, testTime, ctrl, _, done := dockerClientSetup(t)
 	defer done()
 
 	pullBeginTimeout := make(chan time.Time, 1)
 	testTime.EXPECT().After(dockerPullBeginTimeout).Return(pullBeginTimeout)
 	pullTimeout := make(chan time.Time, 1)
 	testTime.EXPECT().After(pullImageTimeout).Return(pullTimeout)
+	// The pull may begin before the global timeout is noticed
+	inactivityTimer := mock_ttime.NewMockTimer(ctrl)
+	inactivityTimer.EXPECT().Reset(gomock.Any()).AnyTimes()
+	inactivityTimer.EXPECT().Stop().AnyTimes()
+	testTime.EXPECT().AfterFunc(gomock.Any(), gomock.Any()).Return(inactivityTimer).AnyTimes()
 	wait := sync.WaitGroup{}
 	wait.Add(1)
 	mockDocker.EXPECT().PullImage(&pullImageOptsMatcher{"image:latest"}, gomock.Any()).Do(func(x, y interface{}) {
@@ -175,13 +184,156 @@ func TestPullImageInactivityTimeout(t *testing.T) {
 
 	testTime.EXPECT().After(gomock.Any()).AnyTimes()
 	mockDocker.EXPECT().PullImage(&pullImageOptsMatcher{"image:latest"}, gomock.Any()).Return(
-		docker.ErrInactivityTimeout).Times(maximumPullRetries) // expected number of retries
+		docker.ErrInactivityTimeout).Times(config.DefaultImagePullAttempts) // expected number of retries
 
 	metadata := client.PullImage("image", nil)
 	assert.Error(t, metadata.Error, "Expected error for pull inactivity timeout")
 	assert.Equal(t, "CannotPullContainerError", metadata.Error.(apierrors.NamedError).ErrorName(), "Wrong error type")
 }
 
+func TestPullImageNoProgressTimeout(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.ImagePullAttempts = 1
+	mockDocker, client, testTime, ctrl, _, done := dockerClientSetupWithConfig(t, conf)
+	defer done()
+
+	testTime.EXPECT().After(gomock.Any()).AnyTimes()
+	inactivityTimer := mock_ttime.NewMockTimer(ctrl)
+	inactivityTimer.EXPECT().Reset(gomock.Any()).AnyTimes()
+	inactivityTimer.EXPECT().Stop().AnyTimes()
+	// No progress is reported once the pull began, the inactivity timeout expires right away
+	testTime.EXPECT().AfterFunc(client.config.ImagePullInactivityTimeout, gomock.Any()).Do(
+		func(d time.Duration, f func()) {
+			go f()
+		}).Return(inactivityTimer)
+	mockDocker.EXPECT().PullImage(&pullImageOptsMatcher{"image:latest"}, gomock.Any()).Do(
+		func(x, y interface{}) {
+			opts := x.(docker.PullImageOptions)
+			io.WriteString(opts.OutputStream, "d1bd2f0ce2b4: Pulling fs layer\n")
+			// The pull is canceled
+			<-opts.Context.Done()
+		}).Return(context.Canceled)
+
+	metadata := client.PullImage("image", nil)
+	require.Error(t, metadata.Error, "Expected error for pull inactivity timeout")
+	assert.Equal(t, PullInactivityTimeoutErrorName, metadata.Error.(apierrors.NamedError).ErrorName(), "Wrong error type")
+	assert.Contains(t, metadata.Error.Error(), "pull inactivity timeout")
+}
+
+func TestPullImageProgressResetsInactivityTimeout(t *testing.T) {
+	mockDocker, client, testTime, ctrl, _, done := dockerClientSetup(t)
+	defer done()
+
+	testTime.EXPECT().After(gomock.Any()).AnyTimes()
+	resetCalled := make(chan struct{})
+	var resetOnce sync.Once
+	inactivityTimer := mock_ttime.NewMockTimer(ctrl)
+	inactivityTimer.EXPECT().Reset(client.config.ImagePullInactivityTimeout).Do(func(d time.Duration) {
+		resetOnce.Do(func() { close(resetCalled) })
+	}).MinTimes(1)
+	inactivityTimer.EXPECT().Stop()
+	testTime.EXPECT().AfterFunc(client.config.ImagePullInactivityTimeout, gomock.Any()).Return(inactivityTimer)
+	mockDocker.EXPECT().PullImage(&pullImageOptsMatcher{"image:latest"}, gomock.Any()).Do(
+		func(x, y interface{}) {
+			opts := x.(docker.PullImageOptions)
+			io.WriteString(opts.OutputStream, "latest: Pulling from library/image\n")
+			// The pull finishes once the progress reset the inactivity timeout
+			for {
+				select {
+				case <-resetCalled:
+					return
+				case <-time.After(5 * time.Millisecond):
+					io.WriteString(opts.OutputStream, "d1bd2f0ce2b4: Downloading [==>   ] 1.2MB/20MB\n")
+				}
+			}
+		}).Return(nil)
+
+	metadata := client.PullImage("image", nil)
+	assert.NoError(t, metadata.Error, "Expected pull to succeed")
+}
+
+func TestParseLayerProgress(t *testing.T) {
+	testCases := []struct {
+		line    string
+		layerID string
+		status  string
+		ok      bool
+	}{
+		{
+			line:    "d1bd2f0ce2b4: Downloading [==>   ] 1.2MB/20MB\n",
+			layerID: "d1bd2f0ce2b4",
+			status:  "Downloading [==>   ] 1.2MB/20MB",
+			ok:      true,
+		},
+		{
+			line:    "d1bd2f0ce2b4: Pull complete\n",
+			layerID: "d1bd2f0ce2b4",
+			status:  "Pull complete",
+			ok:      true,
+		},
+		{
+			line: "latest: Pulling from library/image\n",
+		},
+		{
+			line: "Digest: sha256:bc8813ea7b3603864987522f02a76101c17ad122e1c46d790efc0fca78ca7bfb\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			layerID, status, ok := parseLayerProgress(tc.line)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.layerID, layerID)
+			assert.Equal(t, tc.status, status)
+		})
+	}
+}
+
+func TestPullImageAttempts(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.ImagePullAttempts = 3
+	mockDocker, client, testTime, _, _, done := dockerClientSetupWithConfig(t, conf)
+	defer done()
+
+	testTime.EXPECT().After(gomock.Any()).AnyTimes()
+	mockDocker.EXPECT().PullImage(&pullImageOptsMatcher{"image:latest"}, gomock.Any()).Return(
+		errors.New("connection reset by peer")).Times(3)
+
+	metadata := client.PullImage("image", nil)
+	require.Error(t, metadata.Error, "Expected error for pull failure")
+	assert.Equal(t, "CannotPullContainerError", metadata.Error.(apierrors.NamedError).ErrorName(), "Wrong error type")
+	assert.Equal(t, "failed to pull image image: connection reset by peer", metadata.Error.Error())
+}
+
+func TestPullImageSucceedsOnRetry(t *testing.T) {
+	mockDocker, client, testTime, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	testTime.EXPECT().After(gomock.Any()).AnyTimes()
+	gomock.InOrder(
+		mockDocker.EXPECT().PullImage(&pullImageOptsMatcher{"image:latest"}, gomock.Any()).Return(
+			errors.New("received unexpected HTTP status: 500 Internal Server Error")),
+		mockDocker.EXPECT().PullImage(&pullImageOptsMatcher{"image:latest"}, gomock.Any()).Return(nil),
+	)
+
+	metadata := client.PullImage("image", nil)
+	assert.NoError(t, metadata.Error, "Expected pull to succeed on retry")
+}
+
+func TestPullImageNotRetriedForPermanentError(t *testing.T) {
+	mockDocker, client, testTime, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	testTime.EXPECT().After(gomock.Any()).AnyTimes()
+	mockDocker.EXPECT().PullImage(&pullImageOptsMatcher{"image:latest"}, gomock.Any()).Return(
+		errors.New("manifest for image:latest not found")).Times(1)
+
+	metadata := client.PullImage("image", nil)
+	require.Error(t, metadata.Error, "Expected error for pull failure")
+	assert.Equal(t, "CannotPullContainerError", metadata.Error.(apierrors.NamedError).ErrorName(), "Wrong error type")
+	assert.Equal(t, "failed to pull image image: manifest for image:latest not found", metadata.Error.Error())
+}
+
 func TestPullImage(t *testing.T) {
 	mockDocker, client, testTime, _, _, done := dockerClientSetup(t)
 	defer done()
@@ -230,7 +382,7 @@ func TestPullImageECRSuccess(t *testing.T) {
 	ecrClientFactory := mock_ecr.NewMockECRFactory(ctrl)
 	ecrClient := mock_ecr.NewMockECRClient(ctrl)
 	mockTime := mock_ttime.NewMockTime(ctrl)
-	goClient.ecrClientFactory = ecrClientFactory
+	goClient.ecrAuth = dockerauth.NewECRAuthProvider(ecrClientFactory, async.NewLRUCache(tokenCacheSize, tokenCacheTTL))
 	goClient._time = mockTime
 
 	mockTime.EXPECT().After(gomock.Any()).AnyTimes()
@@ -284,7 +436,7 @@ func TestPullImageECRAuthFail(t *testing.T) {
 	ecrClientFactory := mock_ecr.NewMockECRFactory(ctrl)
 	ecrClient := mock_ecr.NewMockECRClient(ctrl)
 	mockTime := mock_ttime.NewMockTime(ctrl)
-	goClient.ecrClientFactory = ecrClientFactory
+	goClient.ecrAuth = dockerauth.NewECRAuthProvider(ecrClientFactory, async.NewLRUCache(tokenCacheSize, tokenCacheTTL))
 	goClient._time = mockTime
 
 	mockTime.EXPECT().After(gomock.Any()).AnyTimes()
@@ -422,7 +574,9 @@ func TestStopContainerTimeout(t *testing.T) {
 		// Don't return, verify timeout happens
 	}).MaxTimes(1).Return(errors.New("test error"))
 	mockDocker.EXPECT().InspectContainerWithContext(gomock.Any(), gomock.Any()).AnyTimes()
-	ctx, cancel := context.WithCancel(context.TODO())
+	// The request only times out StopContainerTimeout after the stop timeout
+	// elapses, so the context is used to time it out sooner
+	ctx, cancel := context.WithTimeout(context.TODO(), xContainerShortTimeout)
 	defer cancel()
 	metadata := client.StopContainer(ctx, "id", xContainerShortTimeout)
 	if metadata.Error == nil {
@@ -434,6 +588,20 @@ func TestStopContainerTimeout(t *testing.T) {
 	wait.Done()
 }
 
+func TestStopContainerWithStopTimeout(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	gomock.InOrder(
+		mockDocker.EXPECT().StopContainerWithContext("id", uint(120), gomock.Any()).Return(nil),
+		mockDocker.EXPECT().InspectContainerWithContext("id", gomock.Any()).Return(&docker.Container{ID: "id"}, nil),
+	)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	metadata := client.StopContainer(ctx, "id", 2*time.Minute)
+	assert.NoError(t, metadata.Error)
+}
+
 func TestStopContainer(t *testing.T) {
 	mockDocker, client, _, _, _, done := dockerClientSetup(t)
 	defer done()
@@ -444,7 +612,7 @@ func TestStopContainer(t *testing.T) {
 	)
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
-	metadata := client.StopContainer(ctx, "id", dockerclient.StopContainerTimeout)
+	metadata := client.StopContainer(ctx, "id", client.config.DockerStopTimeout)
 	if metadata.Error != nil {
 		t.Error("Did not expect error")
 	}
@@ -453,6 +621,29 @@ func TestStopContainer(t *testing.T) {
 	}
 }
 
+func TestWaitContainer(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().WaitContainerWithContext("id", gomock.Any()).Return(137, nil)
+	metadata := client.WaitContainer(context.TODO(), "id")
+	require.NoError(t, metadata.Error)
+	assert.Equal(t, "id", metadata.DockerID)
+	require.NotNil(t, metadata.ExitCode)
+	assert.Equal(t, 137, aws.IntValue(metadata.ExitCode))
+}
+
+func TestWaitContainerError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().WaitContainerWithContext("id", gomock.Any()).Return(0, errors.New("connection reset"))
+	metadata := client.WaitContainer(context.TODO(), "id")
+	require.Error(t, metadata.Error)
+	assert.Equal(t, "CannotWaitContainerError", metadata.Error.(apierrors.NamedError).ErrorName())
+	assert.Nil(t, metadata.ExitCode)
+}
+
 func TestInspectContainerTimeout(t *testing.T) {
 	mockDocker, client, _, _, _, done := dockerClientSetup(t)
 	defer done()
@@ -652,6 +843,86 @@ func TestContainerEvents(t *testing.T) {
 	}
 }
 
+func TestContainerEventsReconnect(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	listeners := make(chan chan<- *docker.APIEvents, 2)
+	mockDocker.EXPECT().AddEventListener(gomock.Any()).Do(func(x interface{}) {
+		listeners <- x.(chan<- *docker.APIEvents)
+	}).Times(2)
+
+	dockerEvents, err := client.ContainerEvents(context.TODO())
+	require.NoError(t, err, "Could not get container events")
+
+	// The docker client closes the listeners when the event stream ends
+	close(<-listeners)
+	event := <-dockerEvents
+	assert.Equal(t, apicontainer.EventStreamReconnectedEvent, event.Type)
+
+	events := <-listeners
+	mockDocker.EXPECT().InspectContainerWithContext("cid", gomock.Any()).Return(&docker.Container{ID: "cid"}, nil)
+	go func() {
+		events <- &docker.APIEvents{Type: "container", ID: "cid", Status: "start"}
+	}()
+	event = <-dockerEvents
+	assert.Equal(t, "cid", event.DockerID)
+	assert.Equal(t, apicontainerstatus.ContainerRunning, event.Status)
+}
+
+func TestContainerEventsReplayAfterReconnect(t *testing.T) {
+	mockDocker, client, mockTime, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	listeners := make(chan chan<- *docker.APIEvents, 2)
+	mockDocker.EXPECT().AddEventListener(gomock.Any()).Do(func(x interface{}) {
+		listeners <- x.(chan<- *docker.APIEvents)
+	}).Times(2)
+	mockDocker.EXPECT().InspectContainerWithContext("cid", gomock.Any()).Return(&docker.Container{ID: "cid"}, nil).AnyTimes()
+
+	dockerEvents, err := client.ContainerEvents(context.TODO())
+	require.NoError(t, err, "Could not get container events")
+
+	createEvent := &docker.APIEvents{Type: "container", ID: "cid", Status: "create", TimeNano: 1500000001000000000}
+	startEvent := &docker.APIEvents{Type: "container", ID: "cid", Status: "start", TimeNano: 1500000002000000000}
+	dieEvent := &docker.APIEvents{Type: "container", ID: "cid", Status: "die", TimeNano: 1500000003000000000}
+	restartEvent := &docker.APIEvents{Type: "container", ID: "cid", Status: "start", TimeNano: 1500000004000000000}
+
+	events := <-listeners
+	events <- createEvent
+	assert.Equal(t, apicontainerstatus.ContainerCreated, (<-dockerEvents).Status)
+	events <- startEvent
+	assert.Equal(t, apicontainerstatus.ContainerRunning, (<-dockerEvents).Status)
+
+	// The stream drops while the container stops, the events since the last
+	// processed event are replayed once the listener is added back
+	now := time.Unix(1500000010, 0)
+	mockTime.EXPECT().Now().Return(now)
+	mockDocker.EXPECT().ListEvents(gomock.Any(), time.Unix(0, startEvent.TimeNano), now).Return(
+		[]*docker.APIEvents{startEvent, dieEvent}, nil)
+	close(events)
+
+	// The reconnection is signaled separately from the replayed events
+	var replayedEvents []DockerContainerChangeEvent
+	for i := 0; i < 2; i++ {
+		event := <-dockerEvents
+		if event.Type != apicontainer.EventStreamReconnectedEvent {
+			replayedEvents = append(replayedEvents, event)
+		}
+	}
+	require.Len(t, replayedEvents, 1, "the replayed start event should be ignored")
+	assert.Equal(t, "cid", replayedEvents[0].DockerID)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, replayedEvents[0].Status)
+
+	// Events both replayed and received by the new listener are only
+	// processed once
+	events = <-listeners
+	events <- dieEvent
+	events <- restartEvent
+	event := <-dockerEvents
+	assert.Equal(t, apicontainerstatus.ContainerRunning, event.Status)
+}
+
 func TestDockerVersion(t *testing.T) {
 	mockDocker, client, _, _, _, done := dockerClientSetup(t)
 	defer done()
@@ -710,6 +981,181 @@ func TestListContainers(t *testing.T) {
 	}
 }
 
+func TestContainerSizes(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	containers := []docker.APIContainers{{ID: "id1", SizeRw: 1024}, {ID: "id2"}}
+	mockDocker.EXPECT().ListContainers(gomock.Any()).Do(func(opts docker.ListContainersOptions) {
+		assert.True(t, opts.All)
+		assert.True(t, opts.Size)
+	}).Return(containers, nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	sizes, err := client.ContainerSizes(ctx, dockerclient.ContainerSizesTimeout)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"id1": 1024, "id2": 0}, sizes)
+}
+
+func TestContainerSizesError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().ListContainers(gomock.Any()).Return(nil, errors.New("test error"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err := client.ContainerSizes(ctx, dockerclient.ContainerSizesTimeout)
+	require.Error(t, err)
+	assert.Equal(t, "CannotListContainersError", err.(apierrors.NamedError).ErrorName())
+}
+
+// execCloseWaiter is a docker.CloseWaiter for an exec instance whose
+// attached streams stay open until the exec is done or the waiter is closed
+type execCloseWaiter struct {
+	done   chan struct{}
+	closed chan struct{}
+}
+
+func newExecCloseWaiter() *execCloseWaiter {
+	return &execCloseWaiter{
+		done:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+func (w *execCloseWaiter) Wait() error {
+	select {
+	case <-w.done:
+		return nil
+	case <-w.closed:
+		return errors.New("closed")
+	}
+}
+
+func (w *execCloseWaiter) Close() error {
+	close(w.closed)
+	return nil
+}
+
+func TestExecCommand(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	waiter := newExecCloseWaiter()
+	stdin := bytes.NewBufferString("input")
+	var stdout, stderr bytes.Buffer
+	gomock.InOrder(
+		mockDocker.EXPECT().CreateExec(gomock.Any()).Do(func(opts docker.CreateExecOptions) {
+			assert.Equal(t, "id", opts.Container)
+			assert.Equal(t, []string{"cat"}, opts.Cmd)
+			assert.True(t, opts.AttachStdin)
+			assert.True(t, opts.AttachStdout)
+			assert.True(t, opts.AttachStderr)
+		}).Return(&docker.Exec{ID: "execID"}, nil),
+		mockDocker.EXPECT().StartExecNonBlocking("execID", gomock.Any()).Do(func(id string, opts docker.StartExecOptions) {
+			assert.Equal(t, stdin, opts.InputStream)
+			opts.OutputStream.Write([]byte("output"))
+			close(waiter.done)
+		}).Return(waiter, nil),
+		mockDocker.EXPECT().InspectExec("execID").Return(&docker.ExecInspect{ExitCode: 3}, nil),
+	)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	exitCode, err := client.ExecCommand(ctx, "id", []string{"cat"}, stdin, &stdout, &stderr, dockerclient.ExecCommandTimeout)
+	require.NoError(t, err)
+	assert.Equal(t, 3, exitCode)
+	assert.Equal(t, "output", stdout.String())
+}
+
+func TestExecCommandCreateError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().CreateExec(gomock.Any()).Return(nil, errors.New("test error"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err := client.ExecCommand(ctx, "id", []string{"ls"}, nil, ioutil.Discard, ioutil.Discard, dockerclient.ExecCommandTimeout)
+	require.Error(t, err)
+	assert.Equal(t, "CannotExecCommandError", err.(apierrors.NamedError).ErrorName())
+}
+
+func TestExecCommandTimeout(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	waiter := newExecCloseWaiter()
+	mockDocker.EXPECT().CreateExec(gomock.Any()).Do(func(opts docker.CreateExecOptions) {
+		assert.False(t, opts.AttachStdin)
+	}).Return(&docker.Exec{ID: "execID"}, nil)
+	mockDocker.EXPECT().StartExecNonBlocking("execID", gomock.Any()).Return(waiter, nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err := client.ExecCommand(ctx, "id", []string{"sleep", "60"}, nil, ioutil.Discard, ioutil.Discard, xContainerShortTimeout)
+	require.Error(t, err)
+	assert.Equal(t, "DockerTimeoutError", err.(apierrors.NamedError).ErrorName())
+	select {
+	case <-waiter.closed:
+	default:
+		t.Error("expected the attached streams to be closed")
+	}
+}
+
+func TestPing(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	gomock.InOrder(
+		mockDocker.EXPECT().PingWithContext(gomock.Any()).Return(nil),
+		mockDocker.EXPECT().PingWithContext(gomock.Any()).Return(errors.New("test error")),
+	)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	assert.NoError(t, client.Ping(ctx, time.Second))
+	assert.Error(t, client.Ping(ctx, time.Second))
+}
+
+func TestStorageDriver(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	// The driver is only queried once and cached for subsequent calls
+	mockDocker.EXPECT().Info().Return(&docker.DockerInfo{Driver: "devicemapper"}, nil).Times(1)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		driver, err := client.StorageDriver(ctx, dockerclient.InfoTimeout)
+		require.NoError(t, err)
+		assert.Equal(t, "devicemapper", driver)
+	}
+}
+
+func TestStorageDriverError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().Info().Return(nil, errors.New("test error"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err := client.StorageDriver(ctx, dockerclient.InfoTimeout)
+	assert.Error(t, err)
+	assert.Empty(t, client.getDaemonStorageDriver())
+}
+
+func TestRootDir(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	// The root directory is only queried once and cached for subsequent calls
+	mockDocker.EXPECT().Info().Return(&docker.DockerInfo{DockerRootDir: "/var/lib/docker"}, nil).Times(1)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		rootDir, err := client.RootDir(ctx, dockerclient.InfoTimeout)
+		require.NoError(t, err)
+		assert.Equal(t, "/var/lib/docker", rootDir)
+	}
+}
+
 func TestListContainersTimeout(t *testing.T) {
 	mockDocker, client, _, _, _, done := dockerClientSetup(t)
 	defer done()
@@ -1255,6 +1701,28 @@ func TestMetadataFromContainerHealthCheckWithNoLogs(t *testing.T) {
 	assert.Equal(t, apicontainerstatus.ContainerUnhealthy, metadata.Health.Status)
 }
 
+func TestMetadataFromContainerHealthCheck(t *testing.T) {
+	checkEnd := time.Now()
+	dockerContainer := &docker.Container{
+		State: docker.State{
+			Health: docker.Health{
+				Status:        "unhealthy",
+				FailingStreak: 3,
+				Log: []docker.HealthCheck{
+					{End: checkEnd.Add(-time.Minute), ExitCode: 2, Output: "earlier"},
+					{End: checkEnd, ExitCode: 1, Output: "failed"},
+				},
+			},
+		}}
+
+	metadata := MetadataFromContainer(dockerContainer)
+	assert.Equal(t, apicontainerstatus.ContainerUnhealthy, metadata.Health.Status)
+	assert.Equal(t, 1, metadata.Health.ExitCode)
+	assert.Equal(t, "failed", metadata.Health.Output)
+	assert.Equal(t, 3, metadata.Health.FailingStreak)
+	assert.Equal(t, checkEnd, *metadata.Health.LastCheckedAt)
+}
+
 func TestCreateVolumeTimeout(t *testing.T) {
 	mockDocker, client, _, _, _, done := dockerClientSetup(t)
 	defer done()
@@ -1407,6 +1875,240 @@ func TestRemoveVolume(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestCreateNetworkTimeout(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	wait := &sync.WaitGroup{}
+	wait.Add(1)
+	mockDocker.EXPECT().CreateNetwork(gomock.Any()).Do(func(x interface{}) {
+		wait.Wait()
+	}).MaxTimes(1)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	networkResponse := client.CreateNetwork(ctx, "name", nil, xContainerShortTimeout)
+	assert.Error(t, networkResponse.Error, "expected error for timeout")
+	assert.Equal(t, "DockerTimeoutError", networkResponse.Error.(apierrors.NamedError).ErrorName())
+	wait.Done()
+}
+
+func TestCreateNetworkError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().CreateNetwork(gomock.Any()).Return(nil, errors.New("some docker error"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	networkResponse := client.CreateNetwork(ctx, "name", nil, CreateNetworkTimeout)
+	assert.Equal(t, "CannotCreateNetworkError", networkResponse.Error.(apierrors.NamedError).ErrorName())
+}
+
+func TestCreateNetwork(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	networkName := "networkName"
+	labels := map[string]string{"label": "value"}
+	mockDocker.EXPECT().CreateNetwork(gomock.Any()).Do(func(opts docker.CreateNetworkOptions) {
+		assert.Equal(t, networkName, opts.Name)
+		assert.Equal(t, "bridge", opts.Driver)
+		assert.Equal(t, labels, opts.Labels)
+		assert.True(t, opts.CheckDuplicate)
+	}).Return(&docker.Network{ID: "networkID", Name: networkName}, nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	networkResponse := client.CreateNetwork(ctx, networkName, labels, CreateNetworkTimeout)
+	assert.NoError(t, networkResponse.Error)
+	assert.Equal(t, "networkID", networkResponse.DockerNetwork.ID)
+}
+
+func TestInspectNetworkError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().NetworkInfo("networkID").Return(nil, errors.New("some docker error"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	networkResponse := client.InspectNetwork(ctx, "networkID", InspectNetworkTimeout)
+	assert.Equal(t, "CannotInspectNetworkError", networkResponse.Error.(apierrors.NamedError).ErrorName())
+}
+
+func TestInspectNetwork(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().NetworkInfo("networkID").Return(&docker.Network{ID: "networkID"}, nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	networkResponse := client.InspectNetwork(ctx, "networkID", InspectNetworkTimeout)
+	assert.NoError(t, networkResponse.Error)
+	assert.Equal(t, "networkID", networkResponse.DockerNetwork.ID)
+}
+
+func TestConnectNetwork(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().ConnectNetwork("networkID", gomock.Any()).Do(func(id string, opts docker.NetworkConnectionOptions) {
+		assert.Equal(t, "dockerID", opts.Container)
+		require.NotNil(t, opts.EndpointConfig)
+		assert.Equal(t, []string{"alias"}, opts.EndpointConfig.Aliases)
+	}).Return(nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	err := client.ConnectNetwork(ctx, "networkID", "dockerID", []string{"alias"}, ConnectNetworkTimeout)
+	assert.NoError(t, err)
+}
+
+func TestConnectNetworkError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().ConnectNetwork("networkID", gomock.Any()).Return(errors.New("some docker error"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	err := client.ConnectNetwork(ctx, "networkID", "dockerID", nil, ConnectNetworkTimeout)
+	assert.Equal(t, "CannotConnectNetworkError", err.(apierrors.NamedError).ErrorName())
+}
+
+func TestDisconnectNetwork(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().DisconnectNetwork("networkID", gomock.Any()).Do(func(id string, opts docker.NetworkConnectionOptions) {
+		assert.Equal(t, "dockerID", opts.Container)
+		assert.True(t, opts.Force)
+	}).Return(nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	err := client.DisconnectNetwork(ctx, "networkID", "dockerID", ConnectNetworkTimeout)
+	assert.NoError(t, err)
+}
+
+func TestRemoveNetworkTimeout(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	wait := &sync.WaitGroup{}
+	wait.Add(1)
+	mockDocker.EXPECT().RemoveNetwork(gomock.Any()).Do(func(x interface{}) {
+		wait.Wait()
+	}).MaxTimes(1)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	err := client.RemoveNetwork(ctx, "networkID", xContainerShortTimeout)
+	assert.Error(t, err, "expected error for timeout")
+	assert.Equal(t, "DockerTimeoutError", err.(apierrors.NamedError).ErrorName())
+	wait.Done()
+}
+
+func TestRemoveNetworkError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().RemoveNetwork("networkID").Return(errors.New("some docker error"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	err := client.RemoveNetwork(ctx, "networkID", RemoveNetworkTimeout)
+	assert.Equal(t, "CannotRemoveNetworkError", err.(apierrors.NamedError).ErrorName())
+}
+
+func TestRemoveNetwork(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().RemoveNetwork("networkID").Return(nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	err := client.RemoveNetwork(ctx, "networkID", RemoveNetworkTimeout)
+	assert.NoError(t, err)
+}
+
+func TestListNetworksWithLabel(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().FilteredListNetworks(docker.NetworkFilterOpts{
+		"label": {"label": true},
+	}).Return([]docker.Network{{ID: "networkID"}}, nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	networks, err := client.ListNetworksWithLabel(ctx, "label", ListNetworksTimeout)
+	assert.NoError(t, err)
+	assert.Equal(t, []docker.Network{{ID: "networkID"}}, networks)
+}
+
+func TestListNetworksWithLabelError(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().FilteredListNetworks(gomock.Any()).Return(nil, errors.New("some docker error"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err := client.ListNetworksWithLabel(ctx, "label", ListNetworksTimeout)
+	assert.Equal(t, "CannotListNetworksError", err.(apierrors.NamedError).ErrorName())
+}
+
+func TestListVolumesWithLabel(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().ListVolumes(gomock.Any()).Do(func(opts docker.ListVolumesOptions) {
+		assert.Equal(t, map[string][]string{"label": {"label"}}, opts.Filters)
+	}).Return([]docker.Volume{{Name: "volumeName"}}, nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	volumes, err := client.ListVolumesWithLabel(ctx, "label", ListVolumesTimeout)
+	assert.NoError(t, err)
+	assert.Equal(t, []docker.Volume{{Name: "volumeName"}}, volumes)
+}
+
+func TestListVolumesWithLabelTimeout(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	wait := &sync.WaitGroup{}
+	wait.Add(1)
+	mockDocker.EXPECT().ListVolumes(gomock.Any()).Do(func(x interface{}) {
+		wait.Wait()
+	}).MaxTimes(1)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err := client.ListVolumesWithLabel(ctx, "label", xContainerShortTimeout)
+	assert.Error(t, err, "expected error for timeout")
+	assert.Equal(t, "DockerTimeoutError", err.(apierrors.NamedError).ErrorName())
+	wait.Done()
+}
+
+func TestListImages(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDocker.EXPECT().ListImages(gomock.Any()).Return([]docker.APIImages{{ID: "sha256:qwerty"}}, nil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	images, err := client.ListImages(ctx, ListImagesTimeout)
+	assert.NoError(t, err)
+	assert.Equal(t, []docker.APIImages{{ID: "sha256:qwerty"}}, images)
+}
+
+func TestListImagesTimeout(t *testing.T) {
+	mockDocker, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	wait := &sync.WaitGroup{}
+	wait.Add(1)
+	mockDocker.EXPECT().ListImages(gomock.Any()).Do(func(x interface{}) {
+		wait.Wait()
+	}).MaxTimes(1)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err := client.ListImages(ctx, xContainerShortTimeout)
+	assert.Error(t, err, "expected error for timeout")
+	assert.Equal(t, "DockerTimeoutError", err.(apierrors.NamedError).ErrorName())
+	wait.Done()
+}
+
 func TestListPluginsTimeout(t *testing.T) {
 	mockDocker, client, _, _, _, done := dockerClientSetup(t)
 	defer done()
@@ -0,0 +1,102 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerapi
+
+import (
+	"sync"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// eventDedupeWindow is how long processed events are remembered before
+	// the last processed event. Events replayed after the event stream
+	// reconnects are never older than the last processed event, so this only
+	// needs to cover events sharing its timestamp and events that were
+	// processed out of order
+	eventDedupeWindow = time.Minute
+)
+
+// eventKey identifies a docker event, in order to find events that are
+// received again when the event stream is replayed
+type eventKey struct {
+	dockerID string
+	status   string
+	timeNano int64
+}
+
+// eventCursor tracks the time of the last processed docker event, which is
+// where the event stream is replayed from after it's reconnected, and the
+// events processed since shortly before then, so that replayed events aren't
+// processed again
+type eventCursor struct {
+	lastTimeNano int64
+	processed    map[eventKey]struct{}
+	lock         sync.Mutex
+}
+
+func newEventCursor() *eventCursor {
+	return &eventCursor{
+		processed: make(map[eventKey]struct{}),
+	}
+}
+
+// record records the event as processed. It returns false if the event was
+// already processed. Events without a timestamp are always processed
+func (cursor *eventCursor) record(event *docker.APIEvents) bool {
+	timeNano := eventTimeNano(event)
+	if timeNano == 0 {
+		return true
+	}
+
+	cursor.lock.Lock()
+	defer cursor.lock.Unlock()
+
+	key := eventKey{dockerID: event.ID, status: event.Status, timeNano: timeNano}
+	if _, ok := cursor.processed[key]; ok {
+		return false
+	}
+	cursor.processed[key] = struct{}{}
+	if timeNano > cursor.lastTimeNano {
+		cursor.lastTimeNano = timeNano
+		for processedKey := range cursor.processed {
+			if processedKey.timeNano < cursor.lastTimeNano-eventDedupeWindow.Nanoseconds() {
+				delete(cursor.processed, processedKey)
+			}
+		}
+	}
+	return true
+}
+
+// since returns the time of the last processed event. It's the zero time if
+// no event was processed
+func (cursor *eventCursor) since() time.Time {
+	cursor.lock.Lock()
+	defer cursor.lock.Unlock()
+
+	if cursor.lastTimeNano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, cursor.lastTimeNano)
+}
+
+// eventTimeNano returns the time of the event in nanoseconds. Versions of the
+// docker API older than 1.22 only report the time in seconds
+func eventTimeNano(event *docker.APIEvents) int64 {
+	if event.TimeNano != 0 {
+		return event.TimeNano
+	}
+	return event.Time * time.Second.Nanoseconds()
+}
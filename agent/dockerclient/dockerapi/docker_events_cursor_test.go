@@ -0,0 +1,60 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerapi
+
+import (
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventCursorRecord(t *testing.T) {
+	cursor := newEventCursor()
+	assert.True(t, cursor.since().IsZero())
+
+	start := &docker.APIEvents{ID: "cid", Status: "start", TimeNano: 1500000001000000000}
+	die := &docker.APIEvents{ID: "cid", Status: "die", TimeNano: 1500000001000000000}
+	assert.True(t, cursor.record(start))
+	assert.True(t, cursor.record(die), "events of another status at the same time should be processed")
+	assert.False(t, cursor.record(start), "replayed events should be ignored")
+	assert.Equal(t, time.Unix(0, 1500000001000000000), cursor.since())
+
+	// Events without a timestamp can't be told apart
+	noTimestamp := &docker.APIEvents{ID: "cid", Status: "start"}
+	assert.True(t, cursor.record(noTimestamp))
+	assert.True(t, cursor.record(noTimestamp))
+}
+
+func TestEventCursorRecordSeconds(t *testing.T) {
+	cursor := newEventCursor()
+
+	start := &docker.APIEvents{ID: "cid", Status: "start", Time: 1500000001}
+	assert.True(t, cursor.record(start))
+	assert.False(t, cursor.record(start))
+	assert.Equal(t, time.Unix(1500000001, 0), cursor.since())
+}
+
+func TestEventCursorForgetsOldEvents(t *testing.T) {
+	cursor := newEventCursor()
+
+	old := &docker.APIEvents{ID: "cid", Status: "start", Time: 1500000000}
+	assert.True(t, cursor.record(old))
+	assert.True(t, cursor.record(&docker.APIEvents{ID: "cid", Status: "die", Time: 1500000000 + int64(2*eventDedupeWindow/time.Second)}))
+	assert.NotContains(t, cursor.processed, eventKey{dockerID: "cid", status: "start", timeNano: eventTimeNano(old)})
+	assert.Len(t, cursor.processed, 1)
+}
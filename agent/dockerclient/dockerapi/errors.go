@@ -14,6 +14,8 @@
 package dockerapi
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
@@ -23,10 +25,16 @@ import (
 const (
 	// DockerTimeoutErrorName is the name of docker timeout error.
 	DockerTimeoutErrorName = "DockerTimeoutError"
+	// PullInactivityTimeoutErrorName is the name of the error of an image pull
+	// that stopped making progress.
+	PullInactivityTimeoutErrorName = "PullInactivityTimeoutError"
 	// CannotInspectContainerErrorName is the name of container inspect error.
 	CannotInspectContainerErrorName = "CannotInspectContainerError"
 	// CannotDescribeContainerErrorName is the name of describe container error.
 	CannotDescribeContainerErrorName = "CannotDescribeContainerError"
+	// OutOfMemoryErrorName is the name of the error of a container killed
+	// due to its memory usage.
+	OutOfMemoryErrorName = "OutOfMemoryError"
 )
 
 // DockerTimeoutError is an error type for describing timeouts
@@ -44,13 +52,29 @@ func (err *DockerTimeoutError) Error() string {
 // ErrorName returns the name of the error
 func (err *DockerTimeoutError) ErrorName() string { return DockerTimeoutErrorName }
 
+// PullInactivityTimeoutError is returned when no progress is reported while
+// pulling an image for the pull inactivity timeout
+type PullInactivityTimeoutError struct {
+	// Duration is the inactivity timeout
+	Duration time.Duration
+	// Image is the image being pulled
+	Image string
+}
+
+func (err *PullInactivityTimeoutError) Error() string {
+	return fmt.Sprintf("pull inactivity timeout: no progress pulling image %s for %s", err.Image, err.Duration.String())
+}
+
+// ErrorName returns the name of the error
+func (err *PullInactivityTimeoutError) ErrorName() string { return PullInactivityTimeoutErrorName }
+
 // OutOfMemoryError is a type for errors caused by running out of memory
 type OutOfMemoryError struct{}
 
 func (err OutOfMemoryError) Error() string { return "Container killed due to memory usage" }
 
 // ErrorName returns the name of the error
-func (err OutOfMemoryError) ErrorName() string { return "OutOfMemoryError" }
+func (err OutOfMemoryError) ErrorName() string { return OutOfMemoryErrorName }
 
 // DockerStateError is a wrapper around the error docker puts in the '.State.Error' field of its inspect output.
 type DockerStateError struct {
@@ -125,14 +149,46 @@ func (err CannotStopContainerError) IsRetriableError() bool {
 	return true
 }
 
+// CannotWaitContainerError indicates any error when waiting for a container
+// to exit
+type CannotWaitContainerError struct {
+	FromError error
+}
+
+func (err CannotWaitContainerError) Error() string {
+	return err.FromError.Error()
+}
+
+// ErrorName returns name of the CannotWaitContainerError.
+func (err CannotWaitContainerError) ErrorName() string {
+	return "CannotWaitContainerError"
+}
+
+// permanentPullErrors are the docker pull error messages for pull failures
+// that are not resolved by pulling the image again
+var permanentPullErrors = []string{
+	"manifest unknown",
+	"not found",
+	"pull access denied",
+	"unauthorized",
+	"no basic auth credentials",
+	"denied:",
+}
+
 // CannotPullContainerError indicates any error when trying to pull
 // a container image
 type CannotPullContainerError struct {
 	FromError error
+	// Image is the image that failed to be pulled. It's set on the error
+	// returned once all the pull attempts have failed
+	Image string
 }
 
 func (err CannotPullContainerError) Error() string {
-	return err.FromError.Error()
+	if err.Image == "" {
+		return err.FromError.Error()
+	}
+	return fmt.Sprintf("failed to pull image %s: %s", err.Image, err.FromError.Error())
 }
 
 // ErrorName returns name of the CannotPullContainerError.
@@ -140,6 +196,19 @@ func (err CannotPullContainerError) ErrorName() string {
 	return "CannotPullContainerError"
 }
 
+// Retry fulfills the utils.Retrier interface and allows retries to be skipped
+// by utils.Retry* functions when pulling the image again can't succeed, such
+// as when the image doesn't exist or access to it is denied
+func (err CannotPullContainerError) Retry() bool {
+	message := strings.ToLower(err.FromError.Error())
+	for _, permanentErr := range permanentPullErrors {
+		if strings.Contains(message, permanentErr) {
+			return false
+		}
+	}
+	return true
+}
+
 // CannotPullECRContainerError indicates any error when trying to pull
 // a container image from ECR
 type CannotPullECRContainerError struct {
@@ -264,6 +333,21 @@ func (err CannotListContainersError) ErrorName() string {
 	return "CannotListContainersError"
 }
 
+// CannotExecCommandError indicates any error when trying to run a command in a
+// container
+type CannotExecCommandError struct {
+	FromError error
+}
+
+func (err CannotExecCommandError) Error() string {
+	return err.FromError.Error()
+}
+
+// ErrorName returns name of the CannotExecCommandError
+func (err CannotExecCommandError) ErrorName() string {
+	return "CannotExecCommandError"
+}
+
 // CannotCreateVolumeError indicates any error when trying to create a volume
 type CannotCreateVolumeError struct {
 	fromError error
@@ -303,6 +387,110 @@ func (err CannotRemoveVolumeError) ErrorName() string {
 	return "CannotRemoveVolumeError"
 }
 
+// CannotCreateNetworkError indicates any error when trying to create a network
+type CannotCreateNetworkError struct {
+	fromError error
+}
+
+func (err CannotCreateNetworkError) Error() string {
+	return err.fromError.Error()
+}
+
+func (err CannotCreateNetworkError) ErrorName() string {
+	return "CannotCreateNetworkError"
+}
+
+// CannotInspectNetworkError indicates any error when trying to inspect a network
+type CannotInspectNetworkError struct {
+	fromError error
+}
+
+func (err CannotInspectNetworkError) Error() string {
+	return err.fromError.Error()
+}
+
+func (err CannotInspectNetworkError) ErrorName() string {
+	return "CannotInspectNetworkError"
+}
+
+// CannotConnectNetworkError indicates any error when trying to connect a container to a network
+type CannotConnectNetworkError struct {
+	fromError error
+}
+
+func (err CannotConnectNetworkError) Error() string {
+	return err.fromError.Error()
+}
+
+func (err CannotConnectNetworkError) ErrorName() string {
+	return "CannotConnectNetworkError"
+}
+
+// CannotDisconnectNetworkError indicates any error when trying to disconnect a container from a network
+type CannotDisconnectNetworkError struct {
+	fromError error
+}
+
+func (err CannotDisconnectNetworkError) Error() string {
+	return err.fromError.Error()
+}
+
+func (err CannotDisconnectNetworkError) ErrorName() string {
+	return "CannotDisconnectNetworkError"
+}
+
+// CannotRemoveNetworkError indicates any error when trying to remove a network
+type CannotRemoveNetworkError struct {
+	fromError error
+}
+
+func (err CannotRemoveNetworkError) Error() string {
+	return err.fromError.Error()
+}
+
+func (err CannotRemoveNetworkError) ErrorName() string {
+	return "CannotRemoveNetworkError"
+}
+
+// CannotListNetworksError indicates any error when trying to list networks
+type CannotListNetworksError struct {
+	fromError error
+}
+
+func (err CannotListNetworksError) Error() string {
+	return err.fromError.Error()
+}
+
+func (err CannotListNetworksError) ErrorName() string {
+	return "CannotListNetworksError"
+}
+
+// CannotListVolumesError indicates any error when trying to list volumes
+type CannotListVolumesError struct {
+	fromError error
+}
+
+func (err CannotListVolumesError) Error() string {
+	return err.fromError.Error()
+}
+
+func (err CannotListVolumesError) ErrorName() string {
+	return "CannotListVolumesError"
+}
+
+// CannotListImagesError indicates any error when trying to list images
+type CannotListImagesError struct {
+	fromError error
+}
+
+func (err CannotListImagesError) Error() string {
+	return err.fromError.Error()
+}
+
+func (err CannotListImagesError) ErrorName() string {
+	return "CannotListImagesError"
+}
+
 // CannotListPluginsError indicates any error when trying to list docker plugins
 type CannotListPluginsError struct {
 	fromError error
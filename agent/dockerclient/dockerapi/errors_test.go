@@ -37,3 +37,32 @@ func TestRetriableErrorReturnsTrue(t *testing.T) {
 	err := CannotStopContainerError{errors.New("error")}
 	assert.True(t, err.IsRetriableError(), "Non unretriable error treated as unretriable docker error")
 }
+
+func TestCannotPullContainerErrorRetry(t *testing.T) {
+	testCases := []struct {
+		pullErr   string
+		retriable bool
+	}{
+		{"manifest for image:latest not found", false},
+		{"pull access denied for image, repository does not exist or may require 'docker login'", false},
+		{"Get https://registry/v2/: unauthorized: authentication required", false},
+		{"no basic auth credentials", false},
+		{"net/http: TLS handshake timeout", true},
+		{"received unexpected HTTP status: 500 Internal Server Error", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pullErr, func(t *testing.T) {
+			err := CannotPullContainerError{FromError: errors.New(tc.pullErr)}
+			assert.Equal(t, tc.retriable, err.Retry())
+		})
+	}
+}
+
+func TestCannotPullContainerErrorNamesImage(t *testing.T) {
+	err := CannotPullContainerError{FromError: errors.New("connection reset by peer")}
+	assert.Equal(t, "connection reset by peer", err.Error())
+
+	err.Image = "image:latest"
+	assert.Equal(t, "failed to pull image image:latest: connection reset by peer", err.Error())
+}
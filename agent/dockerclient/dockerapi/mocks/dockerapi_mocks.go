@@ -67,6 +67,30 @@ func (mr *MockDockerClientMockRecorder) APIVersion() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "APIVersion", reflect.TypeOf((*MockDockerClient)(nil).APIVersion))
 }
 
+// AuthConfigFileLoadedAt mocks base method
+func (m *MockDockerClient) AuthConfigFileLoadedAt() time.Time {
+	ret := m.ctrl.Call(m, "AuthConfigFileLoadedAt")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// AuthConfigFileLoadedAt indicates an expected call of AuthConfigFileLoadedAt
+func (mr *MockDockerClientMockRecorder) AuthConfigFileLoadedAt() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthConfigFileLoadedAt", reflect.TypeOf((*MockDockerClient)(nil).AuthConfigFileLoadedAt))
+}
+
+// ConnectNetwork mocks base method
+func (m *MockDockerClient) ConnectNetwork(arg0 context.Context, arg1, arg2 string, arg3 []string, arg4 time.Duration) error {
+	ret := m.ctrl.Call(m, "ConnectNetwork", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConnectNetwork indicates an expected call of ConnectNetwork
+func (mr *MockDockerClientMockRecorder) ConnectNetwork(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConnectNetwork", reflect.TypeOf((*MockDockerClient)(nil).ConnectNetwork), arg0, arg1, arg2, arg3, arg4)
+}
+
 // ContainerEvents mocks base method
 func (m *MockDockerClient) ContainerEvents(arg0 context.Context) (<-chan dockerapi.DockerContainerChangeEvent, error) {
 	ret := m.ctrl.Call(m, "ContainerEvents", arg0)
@@ -80,6 +104,19 @@ func (mr *MockDockerClientMockRecorder) ContainerEvents(arg0 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerEvents", reflect.TypeOf((*MockDockerClient)(nil).ContainerEvents), arg0)
 }
 
+// ContainerSizes mocks base method
+func (m *MockDockerClient) ContainerSizes(arg0 context.Context, arg1 time.Duration) (map[string]int64, error) {
+	ret := m.ctrl.Call(m, "ContainerSizes", arg0, arg1)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerSizes indicates an expected call of ContainerSizes
+func (mr *MockDockerClientMockRecorder) ContainerSizes(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerSizes", reflect.TypeOf((*MockDockerClient)(nil).ContainerSizes), arg0, arg1)
+}
+
 // CreateContainer mocks base method
 func (m *MockDockerClient) CreateContainer(arg0 context.Context, arg1 *go_dockerclient.Config, arg2 *go_dockerclient.HostConfig, arg3 string, arg4 time.Duration) dockerapi.DockerContainerMetadata {
 	ret := m.ctrl.Call(m, "CreateContainer", arg0, arg1, arg2, arg3, arg4)
@@ -92,6 +129,18 @@ func (mr *MockDockerClientMockRecorder) CreateContainer(arg0, arg1, arg2, arg3,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateContainer", reflect.TypeOf((*MockDockerClient)(nil).CreateContainer), arg0, arg1, arg2, arg3, arg4)
 }
 
+// CreateNetwork mocks base method
+func (m *MockDockerClient) CreateNetwork(arg0 context.Context, arg1 string, arg2 map[string]string, arg3 time.Duration) dockerapi.NetworkResponse {
+	ret := m.ctrl.Call(m, "CreateNetwork", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(dockerapi.NetworkResponse)
+	return ret0
+}
+
+// CreateNetwork indicates an expected call of CreateNetwork
+func (mr *MockDockerClientMockRecorder) CreateNetwork(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNetwork", reflect.TypeOf((*MockDockerClient)(nil).CreateNetwork), arg0, arg1, arg2, arg3)
+}
+
 // CreateVolume mocks base method
 func (m *MockDockerClient) CreateVolume(arg0 context.Context, arg1, arg2 string, arg3, arg4 map[string]string, arg5 time.Duration) dockerapi.VolumeResponse {
 	ret := m.ctrl.Call(m, "CreateVolume", arg0, arg1, arg2, arg3, arg4, arg5)
@@ -117,6 +166,31 @@ func (mr *MockDockerClientMockRecorder) DescribeContainer(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeContainer", reflect.TypeOf((*MockDockerClient)(nil).DescribeContainer), arg0, arg1)
 }
 
+// DisconnectNetwork mocks base method
+func (m *MockDockerClient) DisconnectNetwork(arg0 context.Context, arg1, arg2 string, arg3 time.Duration) error {
+	ret := m.ctrl.Call(m, "DisconnectNetwork", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisconnectNetwork indicates an expected call of DisconnectNetwork
+func (mr *MockDockerClientMockRecorder) DisconnectNetwork(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisconnectNetwork", reflect.TypeOf((*MockDockerClient)(nil).DisconnectNetwork), arg0, arg1, arg2, arg3)
+}
+
+// ExecCommand mocks base method
+func (m *MockDockerClient) ExecCommand(arg0 context.Context, arg1 string, arg2 []string, arg3 io.Reader, arg4, arg5 io.Writer, arg6 time.Duration) (int, error) {
+	ret := m.ctrl.Call(m, "ExecCommand", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecCommand indicates an expected call of ExecCommand
+func (mr *MockDockerClientMockRecorder) ExecCommand(arg0, arg1, arg2, arg3, arg4, arg5, arg6 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecCommand", reflect.TypeOf((*MockDockerClient)(nil).ExecCommand), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+}
+
 // InspectContainer mocks base method
 func (m *MockDockerClient) InspectContainer(arg0 context.Context, arg1 string, arg2 time.Duration) (*go_dockerclient.Container, error) {
 	ret := m.ctrl.Call(m, "InspectContainer", arg0, arg1, arg2)
@@ -143,6 +217,18 @@ func (mr *MockDockerClientMockRecorder) InspectImage(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectImage", reflect.TypeOf((*MockDockerClient)(nil).InspectImage), arg0)
 }
 
+// InspectNetwork mocks base method
+func (m *MockDockerClient) InspectNetwork(arg0 context.Context, arg1 string, arg2 time.Duration) dockerapi.NetworkResponse {
+	ret := m.ctrl.Call(m, "InspectNetwork", arg0, arg1, arg2)
+	ret0, _ := ret[0].(dockerapi.NetworkResponse)
+	return ret0
+}
+
+// InspectNetwork indicates an expected call of InspectNetwork
+func (mr *MockDockerClientMockRecorder) InspectNetwork(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectNetwork", reflect.TypeOf((*MockDockerClient)(nil).InspectNetwork), arg0, arg1, arg2)
+}
+
 // InspectVolume mocks base method
 func (m *MockDockerClient) InspectVolume(arg0 context.Context, arg1 string, arg2 time.Duration) dockerapi.VolumeResponse {
 	ret := m.ctrl.Call(m, "InspectVolume", arg0, arg1, arg2)
@@ -179,6 +265,32 @@ func (mr *MockDockerClientMockRecorder) ListContainers(arg0, arg1, arg2 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListContainers", reflect.TypeOf((*MockDockerClient)(nil).ListContainers), arg0, arg1, arg2)
 }
 
+// ListImages mocks base method
+func (m *MockDockerClient) ListImages(arg0 context.Context, arg1 time.Duration) ([]go_dockerclient.APIImages, error) {
+	ret := m.ctrl.Call(m, "ListImages", arg0, arg1)
+	ret0, _ := ret[0].([]go_dockerclient.APIImages)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImages indicates an expected call of ListImages
+func (mr *MockDockerClientMockRecorder) ListImages(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImages", reflect.TypeOf((*MockDockerClient)(nil).ListImages), arg0, arg1)
+}
+
+// ListNetworksWithLabel mocks base method
+func (m *MockDockerClient) ListNetworksWithLabel(arg0 context.Context, arg1 string, arg2 time.Duration) ([]go_dockerclient.Network, error) {
+	ret := m.ctrl.Call(m, "ListNetworksWithLabel", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]go_dockerclient.Network)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListNetworksWithLabel indicates an expected call of ListNetworksWithLabel
+func (mr *MockDockerClientMockRecorder) ListNetworksWithLabel(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNetworksWithLabel", reflect.TypeOf((*MockDockerClient)(nil).ListNetworksWithLabel), arg0, arg1, arg2)
+}
+
 // ListPlugins mocks base method
 func (m *MockDockerClient) ListPlugins(arg0 context.Context, arg1 time.Duration) dockerapi.ListPluginsResponse {
 	ret := m.ctrl.Call(m, "ListPlugins", arg0, arg1)
@@ -204,6 +316,19 @@ func (mr *MockDockerClientMockRecorder) ListPluginsWithFilters(arg0, arg1, arg2,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPluginsWithFilters", reflect.TypeOf((*MockDockerClient)(nil).ListPluginsWithFilters), arg0, arg1, arg2, arg3)
 }
 
+// ListVolumesWithLabel mocks base method
+func (m *MockDockerClient) ListVolumesWithLabel(arg0 context.Context, arg1 string, arg2 time.Duration) ([]go_dockerclient.Volume, error) {
+	ret := m.ctrl.Call(m, "ListVolumesWithLabel", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]go_dockerclient.Volume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVolumesWithLabel indicates an expected call of ListVolumesWithLabel
+func (mr *MockDockerClientMockRecorder) ListVolumesWithLabel(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVolumesWithLabel", reflect.TypeOf((*MockDockerClient)(nil).ListVolumesWithLabel), arg0, arg1, arg2)
+}
+
 // LoadImage mocks base method
 func (m *MockDockerClient) LoadImage(arg0 context.Context, arg1 io.Reader, arg2 time.Duration) error {
 	ret := m.ctrl.Call(m, "LoadImage", arg0, arg1, arg2)
@@ -216,6 +341,18 @@ func (mr *MockDockerClientMockRecorder) LoadImage(arg0, arg1, arg2 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadImage", reflect.TypeOf((*MockDockerClient)(nil).LoadImage), arg0, arg1, arg2)
 }
 
+// Ping mocks base method
+func (m *MockDockerClient) Ping(arg0 context.Context, arg1 time.Duration) error {
+	ret := m.ctrl.Call(m, "Ping", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockDockerClientMockRecorder) Ping(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockDockerClient)(nil).Ping), arg0, arg1)
+}
+
 // PullImage mocks base method
 func (m *MockDockerClient) PullImage(arg0 string, arg1 *container.RegistryAuthenticationData) dockerapi.DockerContainerMetadata {
 	ret := m.ctrl.Call(m, "PullImage", arg0, arg1)
@@ -252,6 +389,18 @@ func (mr *MockDockerClientMockRecorder) RemoveImage(arg0, arg1, arg2 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveImage", reflect.TypeOf((*MockDockerClient)(nil).RemoveImage), arg0, arg1, arg2)
 }
 
+// RemoveNetwork mocks base method
+func (m *MockDockerClient) RemoveNetwork(arg0 context.Context, arg1 string, arg2 time.Duration) error {
+	ret := m.ctrl.Call(m, "RemoveNetwork", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveNetwork indicates an expected call of RemoveNetwork
+func (mr *MockDockerClientMockRecorder) RemoveNetwork(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveNetwork", reflect.TypeOf((*MockDockerClient)(nil).RemoveNetwork), arg0, arg1, arg2)
+}
+
 // RemoveVolume mocks base method
 func (m *MockDockerClient) RemoveVolume(arg0 context.Context, arg1 string, arg2 time.Duration) error {
 	ret := m.ctrl.Call(m, "RemoveVolume", arg0, arg1, arg2)
@@ -264,6 +413,19 @@ func (mr *MockDockerClientMockRecorder) RemoveVolume(arg0, arg1, arg2 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveVolume", reflect.TypeOf((*MockDockerClient)(nil).RemoveVolume), arg0, arg1, arg2)
 }
 
+// RootDir mocks base method
+func (m *MockDockerClient) RootDir(arg0 context.Context, arg1 time.Duration) (string, error) {
+	ret := m.ctrl.Call(m, "RootDir", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RootDir indicates an expected call of RootDir
+func (mr *MockDockerClientMockRecorder) RootDir(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RootDir", reflect.TypeOf((*MockDockerClient)(nil).RootDir), arg0, arg1)
+}
+
 // StartContainer mocks base method
 func (m *MockDockerClient) StartContainer(arg0 context.Context, arg1 string, arg2 time.Duration) dockerapi.DockerContainerMetadata {
 	ret := m.ctrl.Call(m, "StartContainer", arg0, arg1, arg2)
@@ -301,6 +463,19 @@ func (mr *MockDockerClientMockRecorder) StopContainer(arg0, arg1, arg2 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopContainer", reflect.TypeOf((*MockDockerClient)(nil).StopContainer), arg0, arg1, arg2)
 }
 
+// StorageDriver mocks base method
+func (m *MockDockerClient) StorageDriver(arg0 context.Context, arg1 time.Duration) (string, error) {
+	ret := m.ctrl.Call(m, "StorageDriver", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StorageDriver indicates an expected call of StorageDriver
+func (mr *MockDockerClientMockRecorder) StorageDriver(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StorageDriver", reflect.TypeOf((*MockDockerClient)(nil).StorageDriver), arg0, arg1)
+}
+
 // SupportedVersions mocks base method
 func (m *MockDockerClient) SupportedVersions() []dockerclient.DockerVersion {
 	ret := m.ctrl.Call(m, "SupportedVersions")
@@ -326,6 +501,18 @@ func (mr *MockDockerClientMockRecorder) Version(arg0, arg1 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Version", reflect.TypeOf((*MockDockerClient)(nil).Version), arg0, arg1)
 }
 
+// WaitContainer mocks base method
+func (m *MockDockerClient) WaitContainer(arg0 context.Context, arg1 string) dockerapi.DockerContainerMetadata {
+	ret := m.ctrl.Call(m, "WaitContainer", arg0, arg1)
+	ret0, _ := ret[0].(dockerapi.DockerContainerMetadata)
+	return ret0
+}
+
+// WaitContainer indicates an expected call of WaitContainer
+func (mr *MockDockerClientMockRecorder) WaitContainer(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitContainer", reflect.TypeOf((*MockDockerClient)(nil).WaitContainer), arg0, arg1)
+}
+
 // WithVersion mocks base method
 func (m *MockDockerClient) WithVersion(arg0 dockerclient.DockerVersion) dockerapi.DockerClient {
 	ret := m.ctrl.Call(m, "WithVersion", arg0)
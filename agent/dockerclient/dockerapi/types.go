@@ -88,6 +88,12 @@ type VolumeResponse struct {
 	Error        error
 }
 
+// NetworkResponse wrapper for CreateNetwork and InspectNetwork
+type NetworkResponse struct {
+	DockerNetwork *docker.Network
+	Error         error
+}
+
 // ListPluginsResponse is a wrapper for ListPlugins api
 type ListPluginsResponse struct {
 	Plugins []docker.PluginDetail
@@ -54,5 +54,15 @@ The auth type "dockercfg" is intended to allow easy use of an existing
 the "AuthData" to be a string containing the contents of that file. The contents
 of your ".dockercfg" will generally be a string of the following form:
 	'{"http://myregistry.com/v1/":{"auth":"dXNlcjpzd29yZGZpc2g=","email":"email"}}'
+
+Docker config file
+
+Instead of the above, the "EngineAuthConfigFile" configuration key, or the
+"ECS_ENGINE_AUTH_CONFIG_FILE" environment variable, may be set to the path of
+a "config.json" file generated by running "docker login". The credentials in its
+"auths" object are used, and the file is read again when it changes, so that
+rotated credentials apply to the following pulls. A malformed file is ignored
+and the credentials last loaded from it are kept.
+	{"auths":{"myregistry.com":{"auth":"dXNlcjpzd29yZGZpc2g="}}}
 */
 package dockerauth
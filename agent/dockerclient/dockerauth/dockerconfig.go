@@ -0,0 +1,159 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+)
+
+// dockerConfigFile is the part of the docker config file holding the auth
+// information of registries
+type dockerConfigFile struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+type dockerConfigAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// dockerConfigAuthProvider reads the auth information of registries from a
+// docker config file. The file is checked for changes on every pull, and read
+// again if it was modified
+type dockerConfigAuthProvider struct {
+	path string
+	// authProvider serves the auth information last loaded from the file
+	authProvider *dockerAuthProvider
+	loadedAt     time.Time
+	// modTime and size identify the version of the file that was last read,
+	// whether or not it could be loaded
+	modTime time.Time
+	size    int64
+	lock    sync.Mutex
+}
+
+// NewDockerConfigAuthProvider returns a DockerConfigAuthProvider reading the
+// docker config file at the given path
+func NewDockerConfigAuthProvider(path string) DockerConfigAuthProvider {
+	authProvider := &dockerConfigAuthProvider{
+		path:         path,
+		authProvider: &dockerAuthProvider{authMap: dockerAuths{}},
+	}
+	authProvider.reloadIfModified()
+	return authProvider
+}
+
+// GetAuthconfig retrieves the auth configuration of the registry of the image
+// from the docker config file
+func (authProvider *dockerConfigAuthProvider) GetAuthconfig(image string,
+	registryAuthData *apicontainer.RegistryAuthenticationData) (docker.AuthConfiguration, error) {
+	return authProvider.reloadIfModified().GetAuthconfig(image, registryAuthData)
+}
+
+// LoadedAt returns when the auth information was last loaded from the file
+func (authProvider *dockerConfigAuthProvider) LoadedAt() time.Time {
+	authProvider.reloadIfModified()
+
+	authProvider.lock.Lock()
+	defer authProvider.lock.Unlock()
+
+	return authProvider.loadedAt
+}
+
+// reloadIfModified reads the docker config file again if it was modified since
+// it was last read, and returns the provider of the auth information last
+// loaded from it. The previously loaded auth information is kept if the file
+// can't be read or is malformed
+func (authProvider *dockerConfigAuthProvider) reloadIfModified() *dockerAuthProvider {
+	authProvider.lock.Lock()
+	defer authProvider.lock.Unlock()
+
+	info, err := os.Stat(authProvider.path)
+	if err != nil {
+		seelog.Warnf("Unable to read the docker config file %s, using the credentials last loaded from it: %v",
+			authProvider.path, err)
+		return authProvider.authProvider
+	}
+	if info.ModTime().Equal(authProvider.modTime) && info.Size() == authProvider.size {
+		return authProvider.authProvider
+	}
+	authProvider.modTime = info.ModTime()
+	authProvider.size = info.Size()
+
+	authMap, err := readDockerConfigFile(authProvider.path)
+	if err != nil {
+		seelog.Errorf("Unable to load the docker config file %s, using the credentials last loaded from it: %v",
+			authProvider.path, err)
+		return authProvider.authProvider
+	}
+	seelog.Infof("Loaded the credentials of %d registries from the docker config file %s",
+		len(authMap), authProvider.path)
+	authProvider.authProvider = &dockerAuthProvider{authMap: authMap}
+	authProvider.loadedAt = time.Now()
+	return authProvider.authProvider
+}
+
+// readDockerConfigFile parses the auth information of the docker config file.
+// Unlike the "dockercfg" auth type, the whole file is rejected if the auth
+// information of any registry is malformed, so that the credentials of a file
+// that's being rewritten aren't partially loaded
+func readDockerConfigFile(path string) (dockerAuths, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configFile dockerConfigFile
+	if err := json.Unmarshal(data, &configFile); err != nil {
+		return nil, errors.Wrap(err, "docker config file: unable to parse the file")
+	}
+
+	authMap := make(dockerAuths)
+	for registry, auth := range configFile.Auths {
+		authConfig := docker.AuthConfiguration{
+			Username: auth.Username,
+			Password: auth.Password,
+		}
+		if auth.Auth != "" {
+			data, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, errors.Errorf("docker config file: malformed auth data for registry %s", registry)
+			}
+			usernamePass := strings.SplitN(string(data), ":", 2)
+			if len(usernamePass) != 2 {
+				return nil, errors.Errorf("docker config file: malformed auth data for registry %s; must contain ':'", registry)
+			}
+			authConfig.Username = usernamePass[0]
+			authConfig.Password = usernamePass[1]
+		}
+		if authConfig == (docker.AuthConfiguration{}) {
+			// The credentials of the registry are kept in a credential
+			// store, which isn't supported
+			continue
+		}
+		authMap[stripRegistrySchema(registry)] = authConfig
+	}
+	return authMap, nil
+}
@@ -0,0 +1,124 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerauth
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDockerConfigFile(t *testing.T, path string, contents string, modTime time.Time) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	// Set the modification time explicitly, as rewrites within the resolution
+	// of the file system's timestamps aren't detected otherwise
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func encodeDockerConfigAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+func TestDockerConfigAuthProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	writeDockerConfigFile(t, path, `{"auths": {
+		"https://my.registry.tld": {"auth": "`+encodeDockerConfigAuth("user", "swordfish")+`"},
+		"https://index.docker.io/v1/": {"username": "hubuser", "password": "hubpass"},
+		"credstore.registry.tld": {}
+	}}`, time.Now().Add(-time.Hour))
+
+	provider := NewDockerConfigAuthProvider(path)
+	assert.False(t, provider.LoadedAt().IsZero())
+
+	authConfig, err := provider.GetAuthconfig("my.registry.tld/image:latest", nil)
+	require.NoError(t, err)
+	assert.Equal(t, docker.AuthConfiguration{Username: "user", Password: "swordfish"}, authConfig)
+	authConfig, err = provider.GetAuthconfig("library/ubuntu", nil)
+	require.NoError(t, err)
+	assert.Equal(t, docker.AuthConfiguration{Username: "hubuser", Password: "hubpass"}, authConfig)
+	authConfig, err = provider.GetAuthconfig("credstore.registry.tld/image", nil)
+	require.NoError(t, err)
+	assert.Equal(t, docker.AuthConfiguration{}, authConfig)
+}
+
+func TestDockerConfigAuthProviderReloadsModifiedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	writeDockerConfigFile(t, path, `{"auths": {"my.registry.tld": {"auth": "`+
+		encodeDockerConfigAuth("user", "swordfish")+`"}}}`, time.Now().Add(-time.Hour))
+
+	provider := NewDockerConfigAuthProvider(path)
+	firstLoadedAt := provider.LoadedAt()
+
+	// Rotate the password
+	writeDockerConfigFile(t, path, `{"auths": {"my.registry.tld": {"auth": "`+
+		encodeDockerConfigAuth("user", "rotated")+`"}}}`, time.Now())
+	authConfig, err := provider.GetAuthconfig("my.registry.tld/image", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", authConfig.Password)
+	assert.False(t, provider.LoadedAt().Before(firstLoadedAt))
+}
+
+func TestDockerConfigAuthProviderKeepsCredentialsOfMalformedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	writeDockerConfigFile(t, path, `{"auths": {"my.registry.tld": {"auth": "`+
+		encodeDockerConfigAuth("user", "swordfish")+`"}}}`, time.Now().Add(-2*time.Hour))
+
+	provider := NewDockerConfigAuthProvider(path)
+	loadedAt := provider.LoadedAt()
+
+	for _, contents := range []string{
+		`{"auths": {"my.registry.tld": {"auth": "`,
+		`{"auths": {"my.registry.tld": {"auth": "not base64"}}}`,
+		`{"auths": {"my.registry.tld": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("nocolon")) + `"}}}`,
+	} {
+		writeDockerConfigFile(t, path, contents, time.Now().Add(-time.Hour))
+		authConfig, err := provider.GetAuthconfig("my.registry.tld/image", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "swordfish", authConfig.Password, "Expected the previous credentials to be kept for %s", contents)
+		assert.Equal(t, loadedAt, provider.LoadedAt())
+	}
+
+	// The credentials are kept if the file is removed
+	require.NoError(t, os.Remove(path))
+	authConfig, err := provider.GetAuthconfig("my.registry.tld/image", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "swordfish", authConfig.Password)
+}
+
+func TestDockerConfigAuthProviderMissingFile(t *testing.T) {
+	provider := NewDockerConfigAuthProvider(filepath.Join(os.TempDir(), "missing", "config.json"))
+
+	assert.True(t, provider.LoadedAt().IsZero())
+	authConfig, err := provider.GetAuthconfig("my.registry.tld/image", nil)
+	require.NoError(t, err)
+	assert.Equal(t, docker.AuthConfiguration{}, authConfig)
+}
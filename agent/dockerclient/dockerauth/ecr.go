@@ -17,6 +17,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
@@ -40,6 +41,19 @@ type cacheKey struct {
 type ecrAuthProvider struct {
 	tokenCache async.Cache
 	factory    ecr.ECRFactory
+	// tokenRequests are the in-flight token requests by cache key, which
+	// concurrent pulls from the same registry wait for instead of requesting
+	// a token of their own
+	tokenRequests map[string]*tokenRequest
+	lock          sync.Mutex
+}
+
+// tokenRequest is a call to ECR.GetAuthorizationToken. done is closed once
+// the call returns, after which authData and err are set
+type tokenRequest struct {
+	done     chan struct{}
+	authData *ecrapi.AuthorizationData
+	err      error
 }
 
 const (
@@ -48,6 +62,10 @@ const (
 	MinimumJitterDuration = 30 * time.Minute
 	roundtripTimeout      = 5 * time.Second
 	proxyEndpointScheme   = "https://"
+	// proactiveRefreshWindow is how long before cached tokens may be marked
+	// as expired that they're refreshed in the background, so that pulls
+	// don't wait for a new token
+	proactiveRefreshWindow = 5 * time.Minute
 )
 
 // String formats the cachKey as a string
@@ -94,7 +112,7 @@ func (authProvider *ecrAuthProvider) GetAuthconfig(image string,
 	}
 
 	// Try to get the auth config from cache
-	auth := authProvider.getAuthConfigFromCache(key)
+	auth := authProvider.getAuthConfigFromCache(image, key, authData)
 	if auth != nil {
 		return *auth, nil
 	}
@@ -103,8 +121,9 @@ func (authProvider *ecrAuthProvider) GetAuthconfig(image string,
 	return authProvider.getAuthConfigFromECR(image, key, authData)
 }
 
-// getAuthconfigFromCache retrieves the token from cache
-func (authProvider *ecrAuthProvider) getAuthConfigFromCache(key cacheKey) *docker.AuthConfiguration {
+// getAuthconfigFromCache retrieves the token from cache. Tokens close to being
+// marked as expired are refreshed in the background
+func (authProvider *ecrAuthProvider) getAuthConfigFromCache(image string, key cacheKey, authData *apicontainer.ECRAuthData) *docker.AuthConfiguration {
 	token, ok := authProvider.tokenCache.Get(key.String())
 	if !ok {
 		return nil
@@ -124,6 +143,9 @@ func (authProvider *ecrAuthProvider) getAuthConfigFromCache(key cacheKey) *docke
 			authProvider.tokenCache.Delete(key.String())
 			return nil
 		}
+		if shouldRefreshToken(cachedToken) {
+			authProvider.refreshToken(image, key, authData)
+		}
 		return &auth
 	} else {
 		// Remove invalid token from cache
@@ -132,21 +154,76 @@ func (authProvider *ecrAuthProvider) getAuthConfigFromCache(key cacheKey) *docke
 	return nil
 }
 
-// getAuthConfigFromECR calls the ECR API to get docker auth config
+// getAuthConfigFromECR calls the ECR API to get docker auth config. Concurrent
+// calls for the same cache key share a single call to the ECR API
 func (authProvider *ecrAuthProvider) getAuthConfigFromECR(image string, key cacheKey, authData *apicontainer.ECRAuthData) (docker.AuthConfiguration, error) {
+	request, started := authProvider.startTokenRequest(key)
+	if started {
+		authProvider.requestToken(request, image, key, authData)
+	}
+	<-request.done
+
+	if request.err != nil {
+		return docker.AuthConfiguration{}, request.err
+	}
+	return extractToken(request.authData)
+}
+
+// refreshToken gets a new token from the ECR API in the background, unless a
+// token request for the cache key is already in-flight
+func (authProvider *ecrAuthProvider) refreshToken(image string, key cacheKey, authData *apicontainer.ECRAuthData) {
+	request, started := authProvider.startTokenRequest(key)
+	if !started {
+		return
+	}
+	log.Debugf("Refreshing the ECR auth token for %s before it expires", image)
+	go authProvider.requestToken(request, image, key, authData)
+}
+
+// startTokenRequest returns the in-flight token request for the cache key. If
+// there is none, a new request is tracked and started is true, in which case
+// the caller must make the request with requestToken
+func (authProvider *ecrAuthProvider) startTokenRequest(key cacheKey) (request *tokenRequest, started bool) {
+	authProvider.lock.Lock()
+	defer authProvider.lock.Unlock()
+
+	if request, ok := authProvider.tokenRequests[key.String()]; ok {
+		return request, false
+	}
+	if authProvider.tokenRequests == nil {
+		authProvider.tokenRequests = make(map[string]*tokenRequest)
+	}
+	request = &tokenRequest{done: make(chan struct{})}
+	authProvider.tokenRequests[key.String()] = request
+	return request, true
+}
+
+// requestToken calls the ECR API for the token request, caches the new token
+// and stops tracking the request
+func (authProvider *ecrAuthProvider) requestToken(request *tokenRequest, image string, key cacheKey, authData *apicontainer.ECRAuthData) {
+	request.authData, request.err = authProvider.getTokenFromECR(image, key, authData)
+
+	authProvider.lock.Lock()
+	delete(authProvider.tokenRequests, key.String())
+	authProvider.lock.Unlock()
+	close(request.done)
+}
+
+// getTokenFromECR calls the ECR API to get a token and caches it
+func (authProvider *ecrAuthProvider) getTokenFromECR(image string, key cacheKey, authData *apicontainer.ECRAuthData) (*ecrapi.AuthorizationData, error) {
 	// Create ECR client to get the token
 	client, err := authProvider.factory.GetClient(authData)
 	if err != nil {
-		return docker.AuthConfiguration{}, err
+		return nil, err
 	}
 
 	log.Debugf("Calling ECR.GetAuthorizationToken for %s", image)
 	ecrAuthData, err := client.GetAuthorizationToken(authData.RegistryID)
 	if err != nil {
-		return docker.AuthConfiguration{}, err
+		return nil, err
 	}
 	if ecrAuthData == nil {
-		return docker.AuthConfiguration{}, fmt.Errorf("ecr auth: missing AuthorizationData in ECR response for %s", image)
+		return nil, fmt.Errorf("ecr auth: missing AuthorizationData in ECR response for %s", image)
 	}
 
 	// Verify the auth data has the correct format for ECR
@@ -156,9 +233,9 @@ func (authProvider *ecrAuthProvider) getAuthConfigFromECR(image string, key cach
 
 		// Cache the new token
 		authProvider.tokenCache.Set(key.String(), ecrAuthData)
-		return extractToken(ecrAuthData)
+		return ecrAuthData, nil
 	}
-	return docker.AuthConfiguration{}, fmt.Errorf("ecr auth: AuthorizationData is malformed for %s", image)
+	return nil, fmt.Errorf("ecr auth: AuthorizationData is malformed for %s", image)
 }
 
 func extractToken(authData *ecrapi.AuthorizationData) (docker.AuthConfiguration, error) {
@@ -186,3 +263,12 @@ func (authProvider *ecrAuthProvider) IsTokenValid(authData *ecrapi.Authorization
 
 	return time.Now().Before(refreshTime)
 }
+
+// shouldRefreshToken checks whether the token is within the proactive refresh
+// window of the earliest time IsTokenValid may mark it as expired
+func shouldRefreshToken(authData *ecrapi.AuthorizationData) bool {
+	refreshTime := aws.TimeValue(authData.ExpiresAt).
+		Add(-2*MinimumJitterDuration - proactiveRefreshWindow)
+
+	return !time.Now().Before(refreshTime)
+}
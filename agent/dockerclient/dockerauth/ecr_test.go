@@ -19,6 +19,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -460,6 +461,128 @@ func TestAuthorizationTokenCacheHitExpired(t *testing.T) {
 	assert.Equal(t, password, authconfig.Password)
 }
 
+func TestAuthorizationTokenConcurrentCacheMiss(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	factory := mock_ecr.NewMockECRFactory(ctrl)
+	ecrClient := mock_ecr.NewMockECRClient(ctrl)
+
+	provider := ecrAuthProvider{
+		factory:    factory,
+		tokenCache: async.NewLRUCache(tokenCacheSize, tokenCacheTTL),
+	}
+	username := "test_user"
+	password := "test_passwd"
+	proxyEndpoint := "proxy"
+	authData := &apicontainer.ECRAuthData{
+		Region:     "us-west-2",
+		RegistryID: "0123456789012",
+	}
+	registryAuthData := &apicontainer.RegistryAuthenticationData{
+		ECRAuthData: authData,
+	}
+
+	release := make(chan struct{})
+	factory.EXPECT().GetClient(authData).Return(ecrClient, nil).Times(1)
+	ecrClient.EXPECT().GetAuthorizationToken(authData.RegistryID).Do(func(registryID string) {
+		<-release
+	}).Return(&ecrapi.AuthorizationData{
+		ProxyEndpoint:      aws.String(proxyEndpointScheme + proxyEndpoint),
+		AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte(username + ":" + password))),
+		ExpiresAt:          aws.Time(time.Now().Add(12 * time.Hour)),
+	}, nil).Times(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			authconfig, err := provider.GetAuthconfig(proxyEndpoint+"/myimage", registryAuthData)
+			assert.NoError(t, err)
+			assert.Equal(t, username, authconfig.Username)
+			assert.Equal(t, password, authconfig.Password)
+		}()
+	}
+	// Give the pulls time to wait for the in-flight request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestAuthorizationTokenCacheHitRefreshesTokenCloseToExpiry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	factory := mock_ecr.NewMockECRFactory(ctrl)
+	ecrClient := mock_ecr.NewMockECRClient(ctrl)
+
+	tokenCache := async.NewLRUCache(tokenCacheSize, tokenCacheTTL)
+	provider := ecrAuthProvider{
+		factory:    factory,
+		tokenCache: tokenCache,
+	}
+	username := "test_user"
+	password := "test_passwd"
+	proxyEndpoint := "proxy"
+	authData := &apicontainer.ECRAuthData{
+		Region:     "us-west-2",
+		RegistryID: "0123456789012",
+	}
+	registryAuthData := &apicontainer.RegistryAuthenticationData{
+		ECRAuthData: authData,
+	}
+	key := cacheKey{
+		region:     authData.Region,
+		registryID: authData.RegistryID,
+	}
+
+	// The cached token is valid, but within the refresh window
+	cachedAuthData := &ecrapi.AuthorizationData{
+		ProxyEndpoint:      aws.String(proxyEndpointScheme + proxyEndpoint),
+		AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte(username + ":" + password))),
+		ExpiresAt:          aws.Time(time.Now().Add(2*MinimumJitterDuration + time.Minute)),
+	}
+	tokenCache.Set(key.String(), cachedAuthData)
+	refreshedAuthData := &ecrapi.AuthorizationData{
+		ProxyEndpoint:      aws.String(proxyEndpointScheme + proxyEndpoint),
+		AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte(username + ":" + password))),
+		ExpiresAt:          aws.Time(time.Now().Add(12 * time.Hour)),
+	}
+	factory.EXPECT().GetClient(authData).Return(ecrClient, nil)
+	ecrClient.EXPECT().GetAuthorizationToken(authData.RegistryID).Return(refreshedAuthData, nil)
+
+	authconfig, err := provider.GetAuthconfig(proxyEndpoint+"/myimage", registryAuthData)
+	require.NoError(t, err)
+	assert.Equal(t, username, authconfig.Username)
+	assert.Equal(t, password, authconfig.Password)
+
+	for i := 0; i < 100; i++ {
+		token, _ := tokenCache.Get(key.String())
+		if token == refreshedAuthData {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected the cached token to be refreshed in the background")
+}
+
+func TestShouldRefreshToken(t *testing.T) {
+	testCases := []struct {
+		expiresAt     time.Time
+		shouldRefresh bool
+	}{
+		{time.Now().Add(12 * time.Hour), false},
+		{time.Now().Add(2*MinimumJitterDuration + proactiveRefreshWindow + time.Minute), false},
+		{time.Now().Add(2*MinimumJitterDuration + proactiveRefreshWindow - time.Minute), true},
+		{time.Now(), true},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("expires at %s", tc.expiresAt), func(t *testing.T) {
+			authData := &ecrapi.AuthorizationData{ExpiresAt: aws.Time(tc.expiresAt)}
+			assert.Equal(t, tc.shouldRefresh, shouldRefreshToken(authData))
+		})
+	}
+}
+
 func TestExtractECRTokenError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -16,6 +16,8 @@
 package dockerauth
 
 import (
+	"time"
+
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	docker "github.com/fsouza/go-dockerclient"
 )
@@ -24,3 +26,12 @@ import (
 type DockerAuthProvider interface {
 	GetAuthconfig(image string, registryAuthData *apicontainer.RegistryAuthenticationData) (docker.AuthConfiguration, error)
 }
+
+// DockerConfigAuthProvider is a DockerAuthProvider reading the auth information
+// from a docker config file
+type DockerConfigAuthProvider interface {
+	DockerAuthProvider
+	// LoadedAt returns when the auth information was last loaded from the
+	// file, which is the zero time if it never was
+	LoadedAt() time.Time
+}
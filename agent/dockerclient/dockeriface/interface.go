@@ -17,6 +17,7 @@ package dockeriface
 
 import (
 	"context"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
 )
@@ -26,22 +27,39 @@ import (
 type Client interface {
 	AddEventListener(listener chan<- *docker.APIEvents) error
 	CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error)
+	CreateExec(opts docker.CreateExecOptions) (*docker.Exec, error)
 	ImportImage(opts docker.ImportImageOptions) error
+	Info() (*docker.DockerInfo, error)
 	InspectContainer(id string) (*docker.Container, error)
 	InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error)
+	InspectExec(id string) (*docker.ExecInspect, error)
 	InspectImage(name string) (*docker.Image, error)
 	ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error)
+	ListImages(opts docker.ListImagesOptions) ([]docker.APIImages, error)
+	// ListEvents isn't part of go-dockerclient, it's added by the clients of
+	// the client factory
+	ListEvents(ctx context.Context, since time.Time, until time.Time) ([]*docker.APIEvents, error)
 	Ping() error
+	PingWithContext(ctx context.Context) error
 	PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
 	RemoveContainer(opts docker.RemoveContainerOptions) error
 	RemoveEventListener(listener chan *docker.APIEvents) error
 	StartContainer(id string, hostConfig *docker.HostConfig) error
 	StartContainerWithContext(id string, hostConfig *docker.HostConfig, ctx context.Context) error
+	StartExecNonBlocking(id string, opts docker.StartExecOptions) (docker.CloseWaiter, error)
 	StopContainer(id string, timeout uint) error
 	StopContainerWithContext(id string, timeout uint, ctx context.Context) error
+	WaitContainerWithContext(id string, ctx context.Context) (int, error)
 	CreateVolume(opts docker.CreateVolumeOptions) (*docker.Volume, error)
 	InspectVolume(name string) (*docker.Volume, error)
 	RemoveVolume(name string) error
+	CreateNetwork(opts docker.CreateNetworkOptions) (*docker.Network, error)
+	NetworkInfo(id string) (*docker.Network, error)
+	ConnectNetwork(id string, opts docker.NetworkConnectionOptions) error
+	DisconnectNetwork(id string, opts docker.NetworkConnectionOptions) error
+	RemoveNetwork(id string) error
+	FilteredListNetworks(opts docker.NetworkFilterOpts) ([]docker.Network, error)
+	ListVolumes(opts docker.ListVolumesOptions) ([]docker.Volume, error)
 	ListPlugins(ctx context.Context) ([]docker.PluginDetail, error)
 	Stats(opts docker.StatsOptions) error
 	VersionWithContext(context.Context) (*docker.Env, error)
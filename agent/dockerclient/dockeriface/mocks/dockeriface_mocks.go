@@ -20,6 +20,7 @@ package mock_dockeriface
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	go_dockerclient "github.com/fsouza/go-dockerclient"
 	gomock "github.com/golang/mock/gomock"
@@ -60,6 +61,18 @@ func (mr *MockClientMockRecorder) AddEventListener(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEventListener", reflect.TypeOf((*MockClient)(nil).AddEventListener), arg0)
 }
 
+// ConnectNetwork mocks base method
+func (m *MockClient) ConnectNetwork(arg0 string, arg1 go_dockerclient.NetworkConnectionOptions) error {
+	ret := m.ctrl.Call(m, "ConnectNetwork", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConnectNetwork indicates an expected call of ConnectNetwork
+func (mr *MockClientMockRecorder) ConnectNetwork(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConnectNetwork", reflect.TypeOf((*MockClient)(nil).ConnectNetwork), arg0, arg1)
+}
+
 // CreateContainer mocks base method
 func (m *MockClient) CreateContainer(arg0 go_dockerclient.CreateContainerOptions) (*go_dockerclient.Container, error) {
 	ret := m.ctrl.Call(m, "CreateContainer", arg0)
@@ -73,6 +86,32 @@ func (mr *MockClientMockRecorder) CreateContainer(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateContainer", reflect.TypeOf((*MockClient)(nil).CreateContainer), arg0)
 }
 
+// CreateExec mocks base method
+func (m *MockClient) CreateExec(arg0 go_dockerclient.CreateExecOptions) (*go_dockerclient.Exec, error) {
+	ret := m.ctrl.Call(m, "CreateExec", arg0)
+	ret0, _ := ret[0].(*go_dockerclient.Exec)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExec indicates an expected call of CreateExec
+func (mr *MockClientMockRecorder) CreateExec(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExec", reflect.TypeOf((*MockClient)(nil).CreateExec), arg0)
+}
+
+// CreateNetwork mocks base method
+func (m *MockClient) CreateNetwork(arg0 go_dockerclient.CreateNetworkOptions) (*go_dockerclient.Network, error) {
+	ret := m.ctrl.Call(m, "CreateNetwork", arg0)
+	ret0, _ := ret[0].(*go_dockerclient.Network)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateNetwork indicates an expected call of CreateNetwork
+func (mr *MockClientMockRecorder) CreateNetwork(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNetwork", reflect.TypeOf((*MockClient)(nil).CreateNetwork), arg0)
+}
+
 // CreateVolume mocks base method
 func (m *MockClient) CreateVolume(arg0 go_dockerclient.CreateVolumeOptions) (*go_dockerclient.Volume, error) {
 	ret := m.ctrl.Call(m, "CreateVolume", arg0)
@@ -86,6 +125,31 @@ func (mr *MockClientMockRecorder) CreateVolume(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVolume", reflect.TypeOf((*MockClient)(nil).CreateVolume), arg0)
 }
 
+// DisconnectNetwork mocks base method
+func (m *MockClient) DisconnectNetwork(arg0 string, arg1 go_dockerclient.NetworkConnectionOptions) error {
+	ret := m.ctrl.Call(m, "DisconnectNetwork", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisconnectNetwork indicates an expected call of DisconnectNetwork
+func (mr *MockClientMockRecorder) DisconnectNetwork(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisconnectNetwork", reflect.TypeOf((*MockClient)(nil).DisconnectNetwork), arg0, arg1)
+}
+
+// FilteredListNetworks mocks base method
+func (m *MockClient) FilteredListNetworks(arg0 go_dockerclient.NetworkFilterOpts) ([]go_dockerclient.Network, error) {
+	ret := m.ctrl.Call(m, "FilteredListNetworks", arg0)
+	ret0, _ := ret[0].([]go_dockerclient.Network)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FilteredListNetworks indicates an expected call of FilteredListNetworks
+func (mr *MockClientMockRecorder) FilteredListNetworks(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilteredListNetworks", reflect.TypeOf((*MockClient)(nil).FilteredListNetworks), arg0)
+}
+
 // ImportImage mocks base method
 func (m *MockClient) ImportImage(arg0 go_dockerclient.ImportImageOptions) error {
 	ret := m.ctrl.Call(m, "ImportImage", arg0)
@@ -98,6 +162,19 @@ func (mr *MockClientMockRecorder) ImportImage(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportImage", reflect.TypeOf((*MockClient)(nil).ImportImage), arg0)
 }
 
+// Info mocks base method
+func (m *MockClient) Info() (*go_dockerclient.DockerInfo, error) {
+	ret := m.ctrl.Call(m, "Info")
+	ret0, _ := ret[0].(*go_dockerclient.DockerInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Info indicates an expected call of Info
+func (mr *MockClientMockRecorder) Info() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Info", reflect.TypeOf((*MockClient)(nil).Info))
+}
+
 // InspectContainer mocks base method
 func (m *MockClient) InspectContainer(arg0 string) (*go_dockerclient.Container, error) {
 	ret := m.ctrl.Call(m, "InspectContainer", arg0)
@@ -124,6 +201,19 @@ func (mr *MockClientMockRecorder) InspectContainerWithContext(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectContainerWithContext", reflect.TypeOf((*MockClient)(nil).InspectContainerWithContext), arg0, arg1)
 }
 
+// InspectExec mocks base method
+func (m *MockClient) InspectExec(arg0 string) (*go_dockerclient.ExecInspect, error) {
+	ret := m.ctrl.Call(m, "InspectExec", arg0)
+	ret0, _ := ret[0].(*go_dockerclient.ExecInspect)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectExec indicates an expected call of InspectExec
+func (mr *MockClientMockRecorder) InspectExec(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectExec", reflect.TypeOf((*MockClient)(nil).InspectExec), arg0)
+}
+
 // InspectImage mocks base method
 func (m *MockClient) InspectImage(arg0 string) (*go_dockerclient.Image, error) {
 	ret := m.ctrl.Call(m, "InspectImage", arg0)
@@ -163,6 +253,32 @@ func (mr *MockClientMockRecorder) ListContainers(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListContainers", reflect.TypeOf((*MockClient)(nil).ListContainers), arg0)
 }
 
+// ListEvents mocks base method
+func (m *MockClient) ListEvents(arg0 context.Context, arg1, arg2 time.Time) ([]*go_dockerclient.APIEvents, error) {
+	ret := m.ctrl.Call(m, "ListEvents", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*go_dockerclient.APIEvents)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEvents indicates an expected call of ListEvents
+func (mr *MockClientMockRecorder) ListEvents(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEvents", reflect.TypeOf((*MockClient)(nil).ListEvents), arg0, arg1, arg2)
+}
+
+// ListImages mocks base method
+func (m *MockClient) ListImages(arg0 go_dockerclient.ListImagesOptions) ([]go_dockerclient.APIImages, error) {
+	ret := m.ctrl.Call(m, "ListImages", arg0)
+	ret0, _ := ret[0].([]go_dockerclient.APIImages)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImages indicates an expected call of ListImages
+func (mr *MockClientMockRecorder) ListImages(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImages", reflect.TypeOf((*MockClient)(nil).ListImages), arg0)
+}
+
 // ListPlugins mocks base method
 func (m *MockClient) ListPlugins(arg0 context.Context) ([]go_dockerclient.PluginDetail, error) {
 	ret := m.ctrl.Call(m, "ListPlugins", arg0)
@@ -176,6 +292,19 @@ func (mr *MockClientMockRecorder) ListPlugins(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPlugins", reflect.TypeOf((*MockClient)(nil).ListPlugins), arg0)
 }
 
+// ListVolumes mocks base method
+func (m *MockClient) ListVolumes(arg0 go_dockerclient.ListVolumesOptions) ([]go_dockerclient.Volume, error) {
+	ret := m.ctrl.Call(m, "ListVolumes", arg0)
+	ret0, _ := ret[0].([]go_dockerclient.Volume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVolumes indicates an expected call of ListVolumes
+func (mr *MockClientMockRecorder) ListVolumes(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVolumes", reflect.TypeOf((*MockClient)(nil).ListVolumes), arg0)
+}
+
 // LoadImage mocks base method
 func (m *MockClient) LoadImage(arg0 go_dockerclient.LoadImageOptions) error {
 	ret := m.ctrl.Call(m, "LoadImage", arg0)
@@ -188,6 +317,19 @@ func (mr *MockClientMockRecorder) LoadImage(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadImage", reflect.TypeOf((*MockClient)(nil).LoadImage), arg0)
 }
 
+// NetworkInfo mocks base method
+func (m *MockClient) NetworkInfo(arg0 string) (*go_dockerclient.Network, error) {
+	ret := m.ctrl.Call(m, "NetworkInfo", arg0)
+	ret0, _ := ret[0].(*go_dockerclient.Network)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NetworkInfo indicates an expected call of NetworkInfo
+func (mr *MockClientMockRecorder) NetworkInfo(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NetworkInfo", reflect.TypeOf((*MockClient)(nil).NetworkInfo), arg0)
+}
+
 // Ping mocks base method
 func (m *MockClient) Ping() error {
 	ret := m.ctrl.Call(m, "Ping")
@@ -200,6 +342,18 @@ func (mr *MockClientMockRecorder) Ping() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockClient)(nil).Ping))
 }
 
+// PingWithContext mocks base method
+func (m *MockClient) PingWithContext(arg0 context.Context) error {
+	ret := m.ctrl.Call(m, "PingWithContext", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PingWithContext indicates an expected call of PingWithContext
+func (mr *MockClientMockRecorder) PingWithContext(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingWithContext", reflect.TypeOf((*MockClient)(nil).PingWithContext), arg0)
+}
+
 // PullImage mocks base method
 func (m *MockClient) PullImage(arg0 go_dockerclient.PullImageOptions, arg1 go_dockerclient.AuthConfiguration) error {
 	ret := m.ctrl.Call(m, "PullImage", arg0, arg1)
@@ -248,6 +402,18 @@ func (mr *MockClientMockRecorder) RemoveImage(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveImage", reflect.TypeOf((*MockClient)(nil).RemoveImage), arg0)
 }
 
+// RemoveNetwork mocks base method
+func (m *MockClient) RemoveNetwork(arg0 string) error {
+	ret := m.ctrl.Call(m, "RemoveNetwork", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveNetwork indicates an expected call of RemoveNetwork
+func (mr *MockClientMockRecorder) RemoveNetwork(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveNetwork", reflect.TypeOf((*MockClient)(nil).RemoveNetwork), arg0)
+}
+
 // RemoveVolume mocks base method
 func (m *MockClient) RemoveVolume(arg0 string) error {
 	ret := m.ctrl.Call(m, "RemoveVolume", arg0)
@@ -284,6 +450,19 @@ func (mr *MockClientMockRecorder) StartContainerWithContext(arg0, arg1, arg2 int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartContainerWithContext", reflect.TypeOf((*MockClient)(nil).StartContainerWithContext), arg0, arg1, arg2)
 }
 
+// StartExecNonBlocking mocks base method
+func (m *MockClient) StartExecNonBlocking(arg0 string, arg1 go_dockerclient.StartExecOptions) (go_dockerclient.CloseWaiter, error) {
+	ret := m.ctrl.Call(m, "StartExecNonBlocking", arg0, arg1)
+	ret0, _ := ret[0].(go_dockerclient.CloseWaiter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartExecNonBlocking indicates an expected call of StartExecNonBlocking
+func (mr *MockClientMockRecorder) StartExecNonBlocking(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartExecNonBlocking", reflect.TypeOf((*MockClient)(nil).StartExecNonBlocking), arg0, arg1)
+}
+
 // Stats mocks base method
 func (m *MockClient) Stats(arg0 go_dockerclient.StatsOptions) error {
 	ret := m.ctrl.Call(m, "Stats", arg0)
@@ -332,3 +511,16 @@ func (m *MockClient) VersionWithContext(arg0 context.Context) (*go_dockerclient.
 func (mr *MockClientMockRecorder) VersionWithContext(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VersionWithContext", reflect.TypeOf((*MockClient)(nil).VersionWithContext), arg0)
 }
+
+// WaitContainerWithContext mocks base method
+func (m *MockClient) WaitContainerWithContext(arg0 string, arg1 context.Context) (int, error) {
+	ret := m.ctrl.Call(m, "WaitContainerWithContext", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WaitContainerWithContext indicates an expected call of WaitContainerWithContext
+func (mr *MockClientMockRecorder) WaitContainerWithContext(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitContainerWithContext", reflect.TypeOf((*MockClient)(nil).WaitContainerWithContext), arg0, arg1)
+}
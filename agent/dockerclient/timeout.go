@@ -37,4 +37,12 @@ const (
 	RemoveImageTimeout = 3 * time.Minute
 	// VersionTimeout is the timeout for the Version API
 	VersionTimeout = 10 * time.Second
+	// InfoTimeout is the timeout for the Info API
+	InfoTimeout = 10 * time.Second
+	// ContainerSizesTimeout is the timeout for listing containers along with
+	// the size of their writable layers, which docker computes on each request
+	ContainerSizesTimeout = 2 * time.Minute
+	// ExecCommandTimeout is the timeout for running a command in a container,
+	// from creating the exec instance to inspecting its exit code
+	ExecCommandTimeout = 15 * time.Minute
 )
@@ -0,0 +1,38 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+// bandwidthLimiter limits the rate of the traffic sent through an interface of
+// a network namespace
+type bandwidthLimiter interface {
+	// Limit limits the rate, in Mbps, of the traffic sent through the
+	// interface of the network namespace at the path. Limiting the interface
+	// again replaces its limit
+	Limit(netnsPath string, ifName string, rateMbps uint64) error
+	// Unlimit removes the limit of the interface of the network namespace at
+	// the path. It's a no-op if the interface isn't limited
+	Unlimit(netnsPath string, ifName string) error
+}
+
+// unsupportedQdiscError is returned by the bandwidth limiter when the kernel
+// lacks the support of the queueing discipline the traffic is shaped with
+type unsupportedQdiscError struct {
+	error
+}
+
+// isUnsupportedQdiscError returns true if the error is an unsupportedQdiscError
+func isUnsupportedQdiscError(err error) bool {
+	_, ok := err.(unsupportedQdiscError)
+	return ok
+}
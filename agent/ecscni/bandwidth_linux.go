@@ -0,0 +1,127 @@
+// +build linux
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// bitsPerMegabit is the number of bits in a megabit, the unit of the
+	// bandwidth limits of tasks
+	bitsPerMegabit = 1000 * 1000
+	// tbfLatencyDivisor sets the latency of the token bucket filter to a
+	// 20th of a second, the time packets wait for tokens before being dropped
+	tbfLatencyDivisor = 20
+	// tbfBurstDivisor sets the burst of the token bucket filter to the bytes
+	// sent during a 100th of a second at the limited rate
+	tbfBurstDivisor = 100
+)
+
+// tbfLimiter limits the rate of the traffic sent through an interface with a
+// token bucket filter, the root queueing discipline of the interface
+type tbfLimiter struct{}
+
+func newBandwidthLimiter() bandwidthLimiter {
+	return tbfLimiter{}
+}
+
+// Limit replaces the root queueing discipline of the interface with a token
+// bucket filter of the rate
+func (tbfLimiter) Limit(netnsPath string, ifName string, rateMbps uint64) error {
+	handle, link, err := linkAt(netnsPath, ifName)
+	if err != nil {
+		return errors.Wrap(err, "limit bandwidth")
+	}
+	defer handle.Delete()
+
+	rate := rateMbps * bitsPerMegabit / 8
+	burst := rate / tbfBurstDivisor
+	// The bucket holds at least two packets, as packets larger than the
+	// bucket are never sent
+	if minBurst := uint64(2 * link.Attrs().MTU); burst < minBurst {
+		burst = minBurst
+	}
+	qdisc := &netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(1, 0),
+			Parent:    netlink.HANDLE_ROOT,
+		},
+		Rate:   rate,
+		Limit:  uint32(rate/tbfLatencyDivisor + burst),
+		Buffer: uint32(netlink.Xmittime(rate, uint32(burst))),
+	}
+	if err := handle.QdiscReplace(qdisc); err != nil {
+		if err == unix.ENOENT || err == unix.EOPNOTSUPP {
+			// The kernel returns ENOENT when the tbf module can't be found
+			return unsupportedQdiscError{errors.Wrapf(err,
+				"limit bandwidth: token bucket filter unsupported by the kernel")}
+		}
+		return errors.Wrapf(err, "limit bandwidth: unable to add the token bucket filter of interface %s", ifName)
+	}
+	return nil
+}
+
+// Unlimit deletes the token bucket filter of the interface, which reverts to
+// the default queueing discipline
+func (tbfLimiter) Unlimit(netnsPath string, ifName string) error {
+	handle, link, err := linkAt(netnsPath, ifName)
+	if err != nil {
+		return errors.Wrap(err, "unlimit bandwidth")
+	}
+	defer handle.Delete()
+
+	qdiscs, err := handle.QdiscList(link)
+	if err != nil {
+		return errors.Wrapf(err, "unlimit bandwidth: unable to list the queueing disciplines of interface %s", ifName)
+	}
+	for _, qdisc := range qdiscs {
+		if _, ok := qdisc.(*netlink.Tbf); !ok || qdisc.Attrs().Parent != netlink.HANDLE_ROOT {
+			continue
+		}
+		if err := handle.QdiscDel(qdisc); err != nil {
+			return errors.Wrapf(err, "unlimit bandwidth: unable to delete the token bucket filter of interface %s",
+				ifName)
+		}
+	}
+	return nil
+}
+
+// linkAt returns the interface of the network namespace at the path, along
+// with the netlink handle of the namespace, which callers delete
+func linkAt(netnsPath string, ifName string) (*netlink.Handle, netlink.Link, error) {
+	ns, err := netns.GetFromPath(netnsPath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to open the network namespace %s", netnsPath)
+	}
+	defer ns.Close()
+
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to enter the network namespace %s", netnsPath)
+	}
+	link, err := handle.LinkByName(ifName)
+	if err != nil {
+		handle.Delete()
+		return nil, nil, errors.Wrapf(err, "unable to find interface %s in the network namespace %s",
+			ifName, netnsPath)
+	}
+	return handle, link, nil
+}
@@ -0,0 +1,68 @@
+// +build linux,sudo
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+)
+
+func rootTbf(t *testing.T, handle *netlink.Handle) *netlink.Tbf {
+	link, err := handle.LinkByName(defaultVethName)
+	require.NoError(t, err)
+	qdiscs, err := handle.QdiscList(link)
+	require.NoError(t, err)
+	for _, qdisc := range qdiscs {
+		if tbf, ok := qdisc.(*netlink.Tbf); ok && tbf.Parent == netlink.HANDLE_ROOT {
+			return tbf
+		}
+	}
+	return nil
+}
+
+func TestLimitBandwidthInNetns(t *testing.T) {
+	withTestNetns(t, func(netnsPath string, handle *netlink.Handle) {
+		limiter := newBandwidthLimiter()
+		require.NoError(t, limiter.Limit(netnsPath, defaultVethName, 100))
+
+		tbf := rootTbf(t, handle)
+		require.NotNil(t, tbf)
+		// 100 Mbps in bytes per second
+		assert.Equal(t, uint64(12500000), tbf.Rate)
+
+		// The limit is replaced when the namespace is set up again
+		require.NoError(t, limiter.Limit(netnsPath, defaultVethName, 10))
+		tbf = rootTbf(t, handle)
+		require.NotNil(t, tbf)
+		assert.Equal(t, uint64(1250000), tbf.Rate)
+
+		require.NoError(t, limiter.Unlimit(netnsPath, defaultVethName))
+		assert.Nil(t, rootTbf(t, handle))
+		// Removing a missing limit is a no-op
+		assert.NoError(t, limiter.Unlimit(netnsPath, defaultVethName))
+	})
+}
+
+func TestLimitBandwidthUnknownInterface(t *testing.T) {
+	withTestNetns(t, func(netnsPath string, handle *netlink.Handle) {
+		err := newBandwidthLimiter().Limit(netnsPath, "unknown", 100)
+		assert.Error(t, err)
+		assert.False(t, isUnsupportedQdiscError(err))
+	})
+}
@@ -0,0 +1,29 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+const (
+	// instanceMetadataEndpoint is the destination of the traffic blocked in
+	// the network namespace of tasks that can't access the instance metadata.
+	// The credentials endpoint at 169.254.170.2 remains reachable
+	instanceMetadataEndpoint = "169.254.169.254/32"
+)
+
+// instanceMetadataBlocker blocks the traffic of a network namespace to the
+// instance metadata endpoint
+type instanceMetadataBlocker interface {
+	// Block blocks the instance metadata endpoint in the network namespace at
+	// the path. Blocking the endpoint again is a no-op
+	Block(netnsPath string) error
+}
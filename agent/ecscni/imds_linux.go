@@ -0,0 +1,66 @@
+// +build linux
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// routeBlocker blocks the instance metadata endpoint with a blackhole route,
+// which drops the traffic to it before it reaches any interface
+type routeBlocker struct{}
+
+func newInstanceMetadataBlocker() instanceMetadataBlocker {
+	return routeBlocker{}
+}
+
+// Block adds the blackhole route of the instance metadata endpoint to the
+// main routing table of the network namespace
+func (routeBlocker) Block(netnsPath string) error {
+	ns, err := netns.GetFromPath(netnsPath)
+	if err != nil {
+		return errors.Wrapf(err, "block instance metadata: unable to open the network namespace %s", netnsPath)
+	}
+	defer ns.Close()
+
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return errors.Wrapf(err, "block instance metadata: unable to enter the network namespace %s", netnsPath)
+	}
+	defer handle.Delete()
+
+	_, dst, err := net.ParseCIDR(instanceMetadataEndpoint)
+	if err != nil {
+		return err
+	}
+	// The route is replaced rather than added, as it's already there when the
+	// eni plugin blocked the endpoint too, or when the namespace is set up
+	// again after the agent restarted
+	err = handle.RouteReplace(&netlink.Route{
+		Dst:  dst,
+		Type: unix.RTN_BLACKHOLE,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "block instance metadata: unable to add the blackhole route in the network namespace %s",
+			netnsPath)
+	}
+	return nil
+}
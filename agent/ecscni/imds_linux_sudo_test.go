@@ -0,0 +1,113 @@
+// +build linux,sudo
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// withTestNetns runs the test with the path of a new network namespace, which
+// only has a loopback interface and a route to the credentials endpoint, like
+// the namespaces of tasks set up by the bridge plugin
+func withTestNetns(t *testing.T, test func(netnsPath string, handle *netlink.Handle)) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origin, err := netns.Get()
+	require.NoError(t, err)
+	defer origin.Close()
+
+	ns, err := netns.New()
+	require.NoError(t, err)
+	defer ns.Close()
+	// The namespace is only kept open by its handle, so it's reached through
+	// the thread that created it
+	netnsPath := fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid())
+	defer netns.Set(origin)
+
+	handle, err := netlink.NewHandleAt(ns)
+	require.NoError(t, err)
+	defer handle.Delete()
+
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "ecs-eth0"}, PeerName: "ecs-peer"}
+	require.NoError(t, handle.LinkAdd(veth))
+	link, err := handle.LinkByName("ecs-eth0")
+	require.NoError(t, err)
+	require.NoError(t, handle.LinkSetUp(link))
+	address, err := netlink.ParseAddr("169.254.172.2/22")
+	require.NoError(t, err)
+	require.NoError(t, handle.AddrAdd(link, address))
+	_, credentialsEndpoint, _ := net.ParseCIDR(TaskIAMRoleEndpoint)
+	require.NoError(t, handle.RouteAdd(&netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       credentialsEndpoint,
+		Gw:        net.ParseIP("169.254.172.1"),
+	}))
+
+	test(netnsPath, handle)
+}
+
+func blackholeRoutes(t *testing.T, handle *netlink.Handle) []netlink.Route {
+	routes, err := handle.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Type: unix.RTN_BLACKHOLE},
+		netlink.RT_FILTER_TYPE)
+	require.NoError(t, err)
+	return routes
+}
+
+func TestBlockInstanceMetadataInNetns(t *testing.T) {
+	withTestNetns(t, func(netnsPath string, handle *netlink.Handle) {
+		require.NoError(t, newInstanceMetadataBlocker().Block(netnsPath))
+
+		routes := blackholeRoutes(t, handle)
+		require.Len(t, routes, 1)
+		assert.Equal(t, instanceMetadataEndpoint, routes[0].Dst.String())
+
+		// The instance metadata endpoint is unreachable
+		_, err := handle.RouteGet(net.ParseIP("169.254.169.254"))
+		assert.Error(t, err)
+		// The credentials endpoint is still routed through the bridge
+		routes, err = handle.RouteGet(net.ParseIP("169.254.170.2"))
+		require.NoError(t, err)
+		require.Len(t, routes, 1)
+		assert.Equal(t, "169.254.172.1", routes[0].Gw.String())
+	})
+}
+
+func TestBlockInstanceMetadataInNetnsIdempotent(t *testing.T) {
+	withTestNetns(t, func(netnsPath string, handle *netlink.Handle) {
+		blocker := newInstanceMetadataBlocker()
+		// The namespace is blocked again when it's set up again after the
+		// agent restarted
+		require.NoError(t, blocker.Block(netnsPath))
+		require.NoError(t, blocker.Block(netnsPath))
+
+		assert.Len(t, blackholeRoutes(t, handle), 1)
+	})
+}
+
+func TestBlockInstanceMetadataUnknownNetns(t *testing.T) {
+	assert.Error(t, newInstanceMetadataBlocker().Block("/proc/0/ns/net"))
+}
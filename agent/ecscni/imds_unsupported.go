@@ -0,0 +1,33 @@
+// +build !linux
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+type unsupportedBlocker struct{}
+
+func newInstanceMetadataBlocker() instanceMetadataBlocker {
+	return unsupportedBlocker{}
+}
+
+// Block returns an error on the unsupported platform
+func (unsupportedBlocker) Block(netnsPath string) error {
+	return errors.Errorf("block instance metadata: unsupported platform: %s/%s", runtime.GOOS, runtime.GOARCH)
+}
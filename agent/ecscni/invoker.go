@@ -0,0 +1,184 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/invoke"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+	"github.com/pkg/errors"
+)
+
+const (
+	// pluginExecTimeout bounds each invocation of a plugin, after which the
+	// plugin is killed. The plugins of a namespace setup are invoked one
+	// after another within cniSetupTimeout of the engine
+	pluginExecTimeout = 30 * time.Second
+	// maxPluginStderrSize bounds how much of the end of the stderr of a failed
+	// plugin is kept in its error
+	maxPluginStderrSize = 1024
+	// cniErrTryAgainLater is the well-known error code of the cni spec for
+	// transient failures, which the plugin expects to succeed when retried
+	cniErrTryAgainLater uint = 11
+)
+
+// pluginExecutor runs a cni plugin with the network configuration as its stdin
+// and returns its stdout
+type pluginExecutor interface {
+	ExecPlugin(ctx context.Context, pluginPath string, stdinData []byte, environ []string) ([]byte, error)
+}
+
+// processExecutor runs the plugins as child processes, which are killed once
+// the context is done
+type processExecutor struct{}
+
+// ExecPlugin runs the plugin and returns a pluginError carrying its stderr if
+// it fails
+func (processExecutor) ExecPlugin(ctx context.Context, pluginPath string, stdinData []byte, environ []string) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, pluginPath)
+	cmd.Env = environ
+	cmd.Stdin = bytes.NewBuffer(stdinData)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = errors.Wrap(ctx.Err(), "plugin killed")
+		}
+		return nil, newPluginError(filepath.Base(pluginPath), err, stdout.Bytes(), stderr.Bytes())
+	}
+	return stdout.Bytes(), nil
+}
+
+// pluginError is the error of a plugin invocation that failed
+type pluginError struct {
+	plugin string
+	// err is the *cnitypes.Error the plugin reported on stdout, or the error
+	// running it if it didn't report one
+	err    error
+	stderr string
+}
+
+func newPluginError(plugin string, err error, stdout []byte, stderr []byte) *pluginError {
+	if _, ok := err.(*exec.ExitError); ok {
+		cniErr := &cnitypes.Error{}
+		if json.Unmarshal(stdout, cniErr) == nil && cniErr.Msg != "" {
+			err = cniErr
+		}
+	}
+	if len(stderr) > maxPluginStderrSize {
+		stderr = stderr[len(stderr)-maxPluginStderrSize:]
+	}
+	return &pluginError{
+		plugin: plugin,
+		err:    err,
+		stderr: strings.TrimSpace(string(stderr)),
+	}
+}
+
+func (err *pluginError) Error() string {
+	msg := fmt.Sprintf("%s: %s", err.plugin, err.err.Error())
+	if cniErr, ok := err.err.(*cnitypes.Error); ok && cniErr.Details != "" {
+		msg += "; " + cniErr.Details
+	}
+	if err.stderr != "" {
+		msg += "; stderr: " + err.stderr
+	}
+	return msg
+}
+
+// Transient returns true if the plugin reported a transient failure, in which
+// case it can be invoked again
+func (err *pluginError) Transient() bool {
+	cniErr, ok := err.err.(*cnitypes.Error)
+	return ok && cniErr.Code == cniErrTryAgainLater
+}
+
+// IsTransientError returns true if the error of a namespace setup was caused
+// by a plugin reporting a transient failure, in which case the setup can be
+// retried
+func IsTransientError(err error) bool {
+	transientErr, ok := errors.Cause(err).(interface {
+		Transient() bool
+	})
+	return ok && transientErr.Transient()
+}
+
+// pluginInvoker invokes the ADD and DEL commands of the plugins like
+// libcni.CNIConfig, but kills hanging plugins and keeps their stderr in their
+// errors
+type pluginInvoker struct {
+	*libcni.CNIConfig
+	executor pluginExecutor
+	timeout  time.Duration
+}
+
+func newPluginInvoker(pluginsPath string) *pluginInvoker {
+	return &pluginInvoker{
+		CNIConfig: &libcni.CNIConfig{Path: []string{pluginsPath}},
+		executor:  processExecutor{},
+		timeout:   pluginExecTimeout,
+	}
+}
+
+// AddNetwork executes the plugin with the ADD command
+func (invoker *pluginInvoker) AddNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) (cnitypes.Result, error) {
+	stdout, err := invoker.exec("ADD", net, rt)
+	if err != nil {
+		return nil, err
+	}
+	// The plugin returns its result in the version of the network configuration
+	confVersion, err := (&version.ConfigDecoder{}).Decode(net.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return version.NewResult(confVersion, stdout)
+}
+
+// DelNetwork executes the plugin with the DEL command
+func (invoker *pluginInvoker) DelNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) error {
+	_, err := invoker.exec("DEL", net, rt)
+	return err
+}
+
+func (invoker *pluginInvoker) exec(command string, net *libcni.NetworkConfig, rt *libcni.RuntimeConf) ([]byte, error) {
+	pluginPath, err := invoke.FindInPath(net.Network.Type, invoker.Path)
+	if err != nil {
+		return nil, err
+	}
+	args := &invoke.Args{
+		Command:     command,
+		ContainerID: rt.ContainerID,
+		NetNS:       rt.NetNS,
+		PluginArgs:  rt.Args,
+		IfName:      rt.IfName,
+		Path:        strings.Join(invoker.Path, string(os.PathListSeparator)),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), invoker.timeout)
+	defer cancel()
+	return invoker.executor.ExecPlugin(ctx, pluginPath, net.Bytes, args.AsEnv())
+}
@@ -0,0 +1,204 @@
+// +build linux,unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecscni
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testENIResult = `{"cniVersion":"0.3.0","ips":[{"version":"4","address":"169.254.172.2/22"}]}`
+
+// fakeExecutor records the plugin commands it's asked to run and runs them
+// with its exec function
+type fakeExecutor struct {
+	lock     sync.Mutex
+	commands []string
+	exec     func(ctx context.Context, plugin string) ([]byte, error)
+}
+
+func (executor *fakeExecutor) ExecPlugin(ctx context.Context, pluginPath string, stdinData []byte, environ []string) ([]byte, error) {
+	plugin := filepath.Base(pluginPath)
+	command := ""
+	for _, env := range environ {
+		if strings.HasPrefix(env, "CNI_COMMAND=") {
+			command = strings.TrimPrefix(env, "CNI_COMMAND=")
+		}
+	}
+	executor.lock.Lock()
+	executor.commands = append(executor.commands, command+" "+plugin)
+	executor.lock.Unlock()
+	return executor.exec(ctx, plugin)
+}
+
+func (executor *fakeExecutor) invokedCommands() []string {
+	executor.lock.Lock()
+	defer executor.lock.Unlock()
+	return append([]string{}, executor.commands...)
+}
+
+// newTestClient returns a client invoking the plugins with the executor, from
+// a directory holding the plugin files
+func newTestClient(t *testing.T, executor pluginExecutor, timeout time.Duration) (CNIClient, func()) {
+	pluginsPath, err := ioutil.TempDir("", "ecscni")
+	require.NoError(t, err)
+	for _, plugin := range []string{ECSENIPluginName, ECSBridgePluginName, ECSIPAMPluginName} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pluginsPath, plugin), nil, 0755))
+	}
+	client := NewClient(&Config{PluginsPath: pluginsPath, MinSupportedCNIVersion: "0.3.0"})
+	invoker := newPluginInvoker(pluginsPath)
+	invoker.executor = executor
+	invoker.timeout = timeout
+	client.(*cniClient).libcni = invoker
+	return client, func() { os.RemoveAll(pluginsPath) }
+}
+
+func TestSetupNSHangingPluginKilled(t *testing.T) {
+	executor := &fakeExecutor{
+		exec: func(ctx context.Context, plugin string) ([]byte, error) {
+			<-ctx.Done()
+			return nil, newPluginError(plugin, errors.Wrap(ctx.Err(), "plugin killed"), nil,
+				[]byte("waiting for the ipam database\n"))
+		},
+	}
+	client, cleanup := newTestClient(t, executor, 10*time.Millisecond)
+	defer cleanup()
+
+	_, err := client.SetupNS(context.TODO(), &Config{}, time.Minute)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ecs-eni: plugin killed")
+	assert.Contains(t, err.Error(), "stderr: waiting for the ipam database")
+	assert.False(t, IsTransientError(err))
+	// Nothing was set up, so there's nothing to clean up
+	assert.Equal(t, []string{"ADD ecs-eni"}, executor.invokedCommands())
+}
+
+func TestSetupNSTimeoutCleansUpLateSetup(t *testing.T) {
+	release := make(chan struct{})
+	executor := &fakeExecutor{
+		exec: func(ctx context.Context, plugin string) ([]byte, error) {
+			if plugin == ECSENIPluginName {
+				<-release
+				return []byte(testENIResult), nil
+			}
+			return nil, nil
+		},
+	}
+	client, cleanup := newTestClient(t, executor, time.Minute)
+	defer cleanup()
+
+	_, err := client.SetupNS(context.TODO(), &Config{}, 10*time.Millisecond)
+	require.Error(t, err)
+	close(release)
+
+	// The eni set up after the timeout is cleaned up, and the bridge is
+	// never set up
+	for i := 0; i < 100 && len(executor.invokedCommands()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, []string{"ADD ecs-eni", "DEL ecs-eni"}, executor.invokedCommands())
+}
+
+func TestSetupNSSecondPluginFails(t *testing.T) {
+	testCases := []struct {
+		name      string
+		output    string
+		transient bool
+	}{
+		{
+			name:   "terminal failure",
+			output: `{"code":7,"msg":"invalid ipam configuration"}`,
+		},
+		{
+			name:      "transient failure",
+			output:    `{"code":11,"msg":"ipam database locked"}`,
+			transient: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			executor := &fakeExecutor{
+				exec: func(ctx context.Context, plugin string) ([]byte, error) {
+					switch plugin {
+					case ECSENIPluginName:
+						return []byte(testENIResult), nil
+					case ECSBridgePluginName:
+						return nil, newPluginError(plugin, exitError(t), []byte(tc.output),
+							[]byte("bridge plugin stderr"))
+					}
+					return nil, nil
+				},
+			}
+			client, cleanup := newTestClient(t, executor, time.Minute)
+			defer cleanup()
+
+			_, err := client.SetupNS(context.TODO(), &Config{}, time.Minute)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "cni setup: invoke bridge plugin failed: ecs-bridge")
+			assert.Contains(t, err.Error(), "stderr: bridge plugin stderr")
+			assert.Equal(t, tc.transient, IsTransientError(err))
+			// The ip the bridge may have allocated is released, and the eni
+			// set up before the bridge failed is cleaned up
+			assert.Equal(t, []string{"ADD ecs-eni", "ADD ecs-bridge", "DEL ecs-ipam", "DEL ecs-eni"},
+				executor.invokedCommands())
+		})
+	}
+}
+
+func TestProcessExecutor(t *testing.T) {
+	pluginsPath, err := ioutil.TempDir("", "ecscni")
+	require.NoError(t, err)
+	defer os.RemoveAll(pluginsPath)
+
+	failingPlugin := filepath.Join(pluginsPath, "failing")
+	require.NoError(t, ioutil.WriteFile(failingPlugin, []byte("#!/bin/sh\n"+
+		`echo '{"code":11,"msg":"try again later","details":"ipam busy"}'`+"\n"+
+		"echo 'plugin log line' >&2\n"+
+		"exit 1\n"), 0755))
+	_, err = processExecutor{}.ExecPlugin(context.TODO(), failingPlugin, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, "failing: try again later; ipam busy; stderr: plugin log line", err.Error())
+	assert.True(t, IsTransientError(errors.Wrap(err, "cni setup")))
+
+	hangingPlugin := filepath.Join(pluginsPath, "hanging")
+	require.NoError(t, ioutil.WriteFile(hangingPlugin, []byte("#!/bin/sh\nexec sleep 10\n"), 0755))
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = processExecutor{}.ExecPlugin(ctx, hangingPlugin, nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hanging: plugin killed")
+	assert.True(t, time.Since(start) < 5*time.Second, "hanging plugin should be killed")
+}
+
+// exitError returns the error of a process that exited with a non-zero status
+func exitError(t *testing.T) error {
+	err := exec.Command("false").Run()
+	require.Error(t, err)
+	return err
+}
@@ -58,24 +58,26 @@ type cniClient struct {
 	cniVersion  string
 	subnet      string
 	libcni      libcni.CNI
+	imdsBlocker instanceMetadataBlocker
+	limiter     bandwidthLimiter
 }
 
 // NewClient creates a client of ecscni which is used to invoke the plugin
 func NewClient(cfg *Config) CNIClient {
-	libcniConfig := &libcni.CNIConfig{
-		Path: []string{cfg.PluginsPath},
-	}
-
 	return &cniClient{
 		pluginsPath: cfg.PluginsPath,
 		cniVersion:  cfg.MinSupportedCNIVersion,
 		subnet:      ecsSubnet,
-		libcni:      libcniConfig,
+		libcni:      newPluginInvoker(cfg.PluginsPath),
+		imdsBlocker: newInstanceMetadataBlocker(),
+		limiter:     newBandwidthLimiter(),
 	}
 }
 
 // SetupNS will set up the namespace of container, including create the bridge
-// and the veth pair, move the eni to container namespace, setup the routes
+// and the veth pair, move the eni to container namespace, setup the routes.
+// If the setup fails or outlasts the timeout, the plugins that were set up are
+// cleaned up, so that the namespace is left as it was
 func (client *cniClient) SetupNS(ctx context.Context,
 	cfg *Config,
 	timeout time.Duration) (*current.Result, error) {
@@ -86,9 +88,11 @@ func (client *cniClient) SetupNS(ctx context.Context,
 		result *current.Result
 		err    error
 	}
-	response := make(chan output)
+	// The setup carries on after the timeout until the plugin being invoked
+	// returns, the response is buffered so that it doesn't block then
+	response := make(chan output, 1)
 	go func(response chan output) {
-		result, err := client.setupNS(cfg)
+		result, err := client.setupNS(derivedCtx, cfg)
 		response <- output{
 			result: result,
 			err:    err,
@@ -103,7 +107,7 @@ func (client *cniClient) SetupNS(ctx context.Context,
 	}
 }
 
-func (client *cniClient) setupNS(cfg *Config) (*current.Result, error) {
+func (client *cniClient) setupNS(ctx context.Context, cfg *Config) (*current.Result, error) {
 	runtimeConfig := libcni.RuntimeConf{
 		ContainerID: cfg.ContainerID,
 		NetNS:       fmt.Sprintf(netnsFormat, cfg.ContainerPID),
@@ -119,13 +123,59 @@ func (client *cniClient) setupNS(cfg *Config) (*current.Result, error) {
 		return nil, errors.Wrap(err, "cni setup: invoke eni plugin failed")
 	}
 	seelog.Debugf("[ECSCNI] ENI setup done: %s", result.String())
+	if ctx.Err() != nil {
+		client.undoSetupNS(runtimeConfig, cfg, false, false, false)
+		return nil, errors.Wrap(ctx.Err(), "cni setup: container namespace setup failed")
+	}
 
 	// Invoke bridge plugin ADD command
 	result, err = client.add(runtimeConfig, cfg, client.createBridgeNetworkConfigWithIPAM)
 	if err != nil {
+		// The ip may have been allocated before the bridge plugin failed
+		client.undoSetupNS(runtimeConfig, cfg, false, true, false)
 		return nil, errors.Wrap(err, "cni setup: invoke bridge plugin failed")
 	}
 	seelog.Debugf("[ECSCNI] Set up container namespace done: %s", result.String())
+
+	if cfg.AppMeshCNIEnabled {
+		// Invoke app mesh plugin ADD command, once the interfaces the traffic
+		// is redirected on are set up
+		if _, err = client.add(runtimeConfig, cfg, client.createAppMeshConfig); err != nil {
+			client.undoSetupNS(runtimeConfig, cfg, true, true, false)
+			return nil, errors.Wrap(err, "cni setup: invoke app mesh plugin failed")
+		}
+		seelog.Debugf("[ECSCNI] App Mesh setup done: %s", cfg.ContainerID)
+	}
+
+	if cfg.BlockInstanceMetdata {
+		// The eni plugin is asked to block the instance metadata endpoint as
+		// well, the agent makes sure it's blocked before the containers of the
+		// task start whatever the version of the plugin
+		if err = client.imdsBlocker.Block(runtimeConfig.NetNS); err != nil {
+			client.undoSetupNS(runtimeConfig, cfg, true, true, cfg.AppMeshCNIEnabled)
+			return nil, errors.Wrap(err, "cni setup: unable to block the instance metadata endpoint")
+		}
+		seelog.Debugf("[ECSCNI] Instance metadata endpoint blocked: %s", cfg.ContainerID)
+	}
+	if cfg.EgressBandwidthLimit > 0 {
+		err = client.limiter.Limit(runtimeConfig.NetNS, defaultENIName, cfg.EgressBandwidthLimit)
+		if isUnsupportedQdiscError(err) {
+			// Tasks still run on kernels that can't shape their traffic,
+			// without their limit
+			seelog.Warnf("[ECSCNI] Unable to limit the egress bandwidth of container namespace %s, ignoring the limit: %v",
+				cfg.ContainerID, err)
+		} else if err != nil {
+			client.undoSetupNS(runtimeConfig, cfg, true, true, cfg.AppMeshCNIEnabled)
+			return nil, errors.Wrap(err, "cni setup: unable to limit the egress bandwidth")
+		} else {
+			seelog.Debugf("[ECSCNI] Egress bandwidth limited to %d Mbps: %s", cfg.EgressBandwidthLimit, cfg.ContainerID)
+		}
+	}
+	if ctx.Err() != nil {
+		// The engine gave up on the setup, which completed too late
+		client.undoSetupNS(runtimeConfig, cfg, true, true, cfg.AppMeshCNIEnabled)
+		return nil, errors.Wrap(ctx.Err(), "cni setup: container namespace setup failed")
+	}
 	if _, err = result.GetAsVersion(currentCNISpec); err != nil {
 		seelog.Warnf("[ECSCNI] Unable to convert result to spec version %s; error: %v; result is of version: %s",
 			currentCNISpec, err, result.Version())
@@ -142,6 +192,47 @@ func (client *cniClient) setupNS(cfg *Config) (*current.Result, error) {
 	return curResult, nil
 }
 
+// undoSetupNS invokes the DEL command of the plugins whose ADD command succeeded
+// during a failed setup, in the reverse order, so that no veth pair or ip
+// allocation is left behind. The eni plugin is always set up first. The ip is
+// released whenever the bridge plugin was invoked, as it may have allocated
+// the ip before failing
+func (client *cniClient) undoSetupNS(runtimeConfig libcni.RuntimeConf, cfg *Config,
+	bridgeAdded bool, ipamInvoked bool, appMeshAdded bool) {
+	seelog.Infof("[ECSCNI] Cleaning up the partially set up container namespace: %s", cfg.ContainerID)
+	if appMeshAdded {
+		if err := client.del(runtimeConfig, cfg, client.createAppMeshConfig); err != nil {
+			seelog.Warnf("[ECSCNI] Unable to clean up the app mesh rules of container namespace %s: %v", cfg.ContainerID, err)
+		}
+	}
+	if bridgeAdded {
+		if err := client.del(runtimeConfig, cfg, client.createBridgeNetworkConfigWithoutIPAM); err != nil {
+			seelog.Warnf("[ECSCNI] Unable to clean up the bridge of container namespace %s: %v", cfg.ContainerID, err)
+		}
+	}
+	if ipamInvoked {
+		if err := client.del(runtimeConfig, cfg, client.createIPAMNetworkConfig); err != nil {
+			seelog.Warnf("[ECSCNI] Unable to release the ip of container namespace %s: %v", cfg.ContainerID, err)
+		}
+	}
+	client.unlimitBandwidth(runtimeConfig, cfg)
+	if err := client.del(runtimeConfig, cfg, client.createENINetworkConfig); err != nil {
+		seelog.Warnf("[ECSCNI] Unable to clean up the eni of container namespace %s: %v", cfg.ContainerID, err)
+	}
+}
+
+// unlimitBandwidth removes the egress bandwidth limit of the eni before it's
+// given back to the instance. The namespace is cleaned up whether it succeeds
+func (client *cniClient) unlimitBandwidth(runtimeConfig libcni.RuntimeConf, cfg *Config) {
+	if cfg.EgressBandwidthLimit == 0 {
+		return
+	}
+	if err := client.limiter.Unlimit(runtimeConfig.NetNS, defaultENIName); err != nil {
+		seelog.Warnf("[ECSCNI] Unable to remove the egress bandwidth limit of container namespace %s: %v",
+			cfg.ContainerID, err)
+	}
+}
+
 // CleanupNS will clean up the container namespace, including remove the veth
 // pair and stop the dhclient
 func (client *cniClient) CleanupNS(
@@ -174,6 +265,12 @@ func (client *cniClient) cleanupNS(cfg *Config) error {
 	os.Setenv("ECS_CNI_LOGLEVEL", logger.GetLevel())
 	defer os.Unsetenv("ECS_CNI_LOGLEVEL")
 	seelog.Debugf("[ECSCNI] Starting clean up the container namespace: %s", cfg.ContainerID)
+	if cfg.AppMeshCNIEnabled {
+		if err := client.del(runtimeConfig, cfg, client.createAppMeshConfig); err != nil {
+			return errors.Wrap(err, "cni cleanup: invoke app mesh plugin failed")
+		}
+		seelog.Debugf("[ECSCNI] App Mesh cleanup done: %s", cfg.ContainerID)
+	}
 	// clean up the network namespace is separate from releasing the IP from IPAM
 	err := client.del(runtimeConfig, cfg, client.createBridgeNetworkConfigWithoutIPAM)
 	if err != nil {
@@ -181,6 +278,7 @@ func (client *cniClient) cleanupNS(cfg *Config) error {
 	}
 	seelog.Debugf("[ECSCNI] bridge cleanup done: %s", cfg.ContainerID)
 
+	client.unlimitBandwidth(runtimeConfig, cfg)
 	err = client.del(runtimeConfig, cfg, client.createENINetworkConfig)
 	if err != nil {
 		return errors.Wrap(err, "cni cleanup: invoke eni plugin failed")
@@ -303,6 +401,7 @@ func (client *cniClient) createENINetworkConfig(cfg *Config) (string, *libcni.Ne
 		MACAddress:               cfg.ENIMACAddress,
 		BlockInstanceMetdata:     cfg.BlockInstanceMetdata,
 		SubnetGatewayIPV4Address: cfg.SubnetGatewayIPV4Address,
+		SubnetGatewayIPV6Address: cfg.SubnetGatewayIPV6Address,
 	}
 	networkConfig, err := client.constructNetworkConfig(eniConf, ECSENIPluginName)
 	if err != nil {
@@ -311,6 +410,27 @@ func (client *cniClient) createENINetworkConfig(cfg *Config) (string, *libcni.Ne
 	return defaultENIName, networkConfig, nil
 }
 
+// createAppMeshConfig creates the config of the app mesh plugin, which
+// programs the iptables rules redirecting the traffic of the task to its proxy
+func (client *cniClient) createAppMeshConfig(cfg *Config) (string, *libcni.NetworkConfig, error) {
+	appMeshConfig := AppMeshConfig{
+		Type:               ECSAppMeshPluginName,
+		CNIVersion:         client.cniVersion,
+		IgnoredUID:         cfg.IgnoredUID,
+		IgnoredGID:         cfg.IgnoredGID,
+		ProxyIngressPort:   cfg.ProxyIngressPort,
+		ProxyEgressPort:    cfg.ProxyEgressPort,
+		AppPorts:           cfg.AppPorts,
+		EgressIgnoredPorts: cfg.EgressIgnoredPorts,
+		EgressIgnoredIPs:   cfg.EgressIgnoredIPs,
+	}
+	networkConfig, err := client.constructNetworkConfig(appMeshConfig, ECSAppMeshPluginName)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "createAppMeshConfig: construct the app mesh network configuration failed")
+	}
+	return defaultAppMeshIfName, networkConfig, nil
+}
+
 // createIPAMNetworkConfig constructs the ipam configuration accepted by libcni
 func (client *cniClient) createIPAMNetworkConfig(cfg *Config) (string, *libcni.NetworkConfig, error) {
 	ipamConfig, err := client.createIPAMConfig(cfg)
@@ -32,7 +32,9 @@ import (
 	cnitypes "github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/types/current"
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetupNS(t *testing.T) {
@@ -69,6 +71,281 @@ func TestSetupNS(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestSetupNSBridgeFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	ecscniClient.(*cniClient).libcni = libcniClient
+
+	gomock.InOrder(
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil),
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(nil, errors.New("bridge failed")),
+		// The ip the bridge plugin may have allocated is released, and the
+		// eni plugin is cleaned up
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSIPAMPluginName, net.Network.Type)
+			}),
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSENIPluginName, net.Network.Type)
+			}),
+	)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{}, time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bridge")
+}
+
+func TestSetupNSAppMesh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	ecscniClient.(*cniClient).libcni = libcniClient
+
+	gomock.InOrder(
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSENIPluginName, net.Network.Type, "first plugin should be eni")
+			}),
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSBridgePluginName, net.Network.Type, "second plugin should be bridge")
+			}),
+		// App mesh plugin is called once the interfaces are set up
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSAppMeshPluginName, net.Network.Type, "last plugin should be app mesh")
+				var appMeshConfig AppMeshConfig
+				err := json.Unmarshal(net.Bytes, &appMeshConfig)
+				assert.NoError(t, err, "unmarshal AppMeshConfig")
+				assert.Equal(t, "1337", appMeshConfig.IgnoredUID)
+				assert.Equal(t, "15001", appMeshConfig.ProxyEgressPort)
+				assert.Equal(t, []string{"169.254.170.2"}, appMeshConfig.EgressIgnoredIPs)
+			}),
+	)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{
+		AppMeshCNIEnabled: true,
+		IgnoredUID:        "1337",
+		ProxyEgressPort:   "15001",
+		EgressIgnoredIPs:  []string{"169.254.170.2"},
+	}, time.Second)
+	assert.NoError(t, err)
+}
+
+func TestSetupNSAppMeshFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	ecscniClient.(*cniClient).libcni = libcniClient
+
+	gomock.InOrder(
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Times(2),
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(nil, errors.New("iptables failed")),
+		// The bridge, ipam and eni plugins are cleaned up
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSBridgePluginName, net.Network.Type)
+			}),
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSIPAMPluginName, net.Network.Type)
+			}),
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSENIPluginName, net.Network.Type)
+			}),
+	)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{AppMeshCNIEnabled: true}, time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app mesh")
+}
+
+// fakeBlocker records the network namespaces whose instance metadata endpoint
+// is blocked
+type fakeBlocker struct {
+	netnsPaths []string
+	err        error
+}
+
+func (blocker *fakeBlocker) Block(netnsPath string) error {
+	blocker.netnsPaths = append(blocker.netnsPaths, netnsPath)
+	return blocker.err
+}
+
+func TestSetupNSBlockInstanceMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	blocker := &fakeBlocker{}
+	ecscniClient.(*cniClient).libcni = libcniClient
+	ecscniClient.(*cniClient).imdsBlocker = blocker
+
+	gomock.InOrder(
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				var eniConfig ENIConfig
+				err := json.Unmarshal(net.Bytes, &eniConfig)
+				assert.NoError(t, err, "unmarshal ENIConfig")
+				assert.True(t, eniConfig.BlockInstanceMetdata)
+			}),
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil),
+	)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{
+		ContainerPID:         "123",
+		BlockInstanceMetdata: true,
+	}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/host/proc/123/ns/net"}, blocker.netnsPaths)
+}
+
+func TestSetupNSInstanceMetadataNotBlocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	blocker := &fakeBlocker{}
+	ecscniClient.(*cniClient).libcni = libcniClient
+	ecscniClient.(*cniClient).imdsBlocker = blocker
+
+	libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Times(2)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{ContainerPID: "123"}, time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, blocker.netnsPaths)
+}
+
+func TestSetupNSBlockInstanceMetadataFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	ecscniClient.(*cniClient).libcni = libcniClient
+	ecscniClient.(*cniClient).imdsBlocker = &fakeBlocker{err: errors.New("netlink failed")}
+
+	gomock.InOrder(
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Times(2),
+		// The bridge, ipam and eni plugins are cleaned up
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Times(3),
+	)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{BlockInstanceMetdata: true}, time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "instance metadata")
+}
+
+// fakeLimiter records the interfaces whose egress bandwidth is limited and
+// unlimited
+type fakeLimiter struct {
+	limited   []string
+	unlimited []string
+	rate      uint64
+	err       error
+}
+
+func (limiter *fakeLimiter) Limit(netnsPath string, ifName string, rateMbps uint64) error {
+	limiter.limited = append(limiter.limited, netnsPath+":"+ifName)
+	limiter.rate = rateMbps
+	return limiter.err
+}
+
+func (limiter *fakeLimiter) Unlimit(netnsPath string, ifName string) error {
+	limiter.unlimited = append(limiter.unlimited, netnsPath+":"+ifName)
+	return nil
+}
+
+func TestSetupNSLimitEgressBandwidth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	limiter := &fakeLimiter{}
+	ecscniClient.(*cniClient).libcni = libcniClient
+	ecscniClient.(*cniClient).limiter = limiter
+
+	libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Times(2)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{
+		ContainerPID:         "123",
+		EgressBandwidthLimit: 100,
+	}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/host/proc/123/ns/net:eth0"}, limiter.limited)
+	assert.Equal(t, uint64(100), limiter.rate)
+}
+
+func TestSetupNSEgressBandwidthNotLimited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	limiter := &fakeLimiter{}
+	ecscniClient.(*cniClient).libcni = libcniClient
+	ecscniClient.(*cniClient).limiter = limiter
+
+	libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Times(2)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{ContainerPID: "123"}, time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, limiter.limited)
+}
+
+func TestSetupNSEgressBandwidthQdiscUnsupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	ecscniClient.(*cniClient).libcni = libcniClient
+	ecscniClient.(*cniClient).limiter = &fakeLimiter{err: unsupportedQdiscError{errors.New("no tbf")}}
+
+	// The namespace is set up without the limit
+	libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Times(2)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{EgressBandwidthLimit: 100}, time.Second)
+	assert.NoError(t, err)
+}
+
+func TestSetupNSLimitEgressBandwidthFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	limiter := &fakeLimiter{err: errors.New("netlink failed")}
+	ecscniClient.(*cniClient).libcni = libcniClient
+	ecscniClient.(*cniClient).limiter = limiter
+
+	gomock.InOrder(
+		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Times(2),
+		// The bridge, ipam and eni plugins are cleaned up
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Times(3),
+	)
+
+	_, err := ecscniClient.SetupNS(context.TODO(), &Config{
+		ContainerPID:         "123",
+		EgressBandwidthLimit: 100,
+	}, time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "egress bandwidth")
+	assert.Equal(t, []string{"/host/proc/123/ns/net:eth0"}, limiter.unlimited)
+}
+
 func TestSetupNSTimeout(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -80,18 +357,26 @@ func TestSetupNSTimeout(t *testing.T) {
 	libcniClient := mock_libcni.NewMockCNI(ctrl)
 	ecscniClient.(*cniClient).libcni = libcniClient
 
+	cleanedUp := make(chan struct{})
 	gomock.InOrder(
 		// ENI plugin was called first
 		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).Do(
 			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
 				wg.Wait()
 			}).MaxTimes(1),
-		libcniClient.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(&current.Result{}, nil).MaxTimes(1),
+		// The eni set up after the timeout is cleaned up, without setting up
+		// the bridge
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSENIPluginName, net.Network.Type)
+				close(cleanedUp)
+			}).Return(nil),
 	)
 
 	_, err := ecscniClient.SetupNS(context.TODO(), &Config{}, time.Millisecond)
 	assert.Error(t, err)
 	wg.Done()
+	<-cleanedUp
 }
 
 func TestCleanupNS(t *testing.T) {
@@ -113,6 +398,56 @@ func TestCleanupNS(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestCleanupNSAppMesh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	ecscniClient.(*cniClient).libcni = libcniClient
+
+	gomock.InOrder(
+		// The iptables rules are removed first
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, ECSAppMeshPluginName, net.Network.Type)
+			}),
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Times(2),
+	)
+
+	err := ecscniClient.CleanupNS(context.TODO(), &Config{AppMeshCNIEnabled: true}, time.Second)
+	assert.NoError(t, err)
+}
+
+func TestCleanupNSUnlimitEgressBandwidth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ecscniClient := NewClient(&Config{})
+	libcniClient := mock_libcni.NewMockCNI(ctrl)
+	limiter := &fakeLimiter{}
+	ecscniClient.(*cniClient).libcni = libcniClient
+	ecscniClient.(*cniClient).limiter = limiter
+
+	gomock.InOrder(
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Empty(t, limiter.unlimited, "limit removed before the bridge cleanup")
+			}),
+		// The limit is removed before the eni is given back to the instance
+		libcniClient.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil).Do(
+			func(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) {
+				assert.Equal(t, []string{"/host/proc/123/ns/net:eth0"}, limiter.unlimited)
+			}),
+	)
+
+	err := ecscniClient.CleanupNS(context.TODO(), &Config{
+		ContainerPID:         "123",
+		EgressBandwidthLimit: 100,
+	}, time.Second)
+	assert.NoError(t, err)
+}
+
 func TestCleanupNSTimeout(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -169,6 +504,7 @@ func TestConstructENINetworkConfig(t *testing.T) {
 		ENIMACAddress:            "02:7b:64:49:b1:40",
 		BlockInstanceMetdata:     true,
 		SubnetGatewayIPV4Address: "172.31.1.1/20",
+		SubnetGatewayIPV6Address: "2001:0db8:85a3::1",
 	}
 
 	_, eniNetworkConfig, err := ecscniClient.(*cniClient).createENINetworkConfig(config)
@@ -183,6 +519,24 @@ func TestConstructENINetworkConfig(t *testing.T) {
 	assert.Equal(t, config.ENIMACAddress, eniConfig.MACAddress)
 	assert.True(t, eniConfig.BlockInstanceMetdata)
 	assert.Equal(t, config.SubnetGatewayIPV4Address, eniConfig.SubnetGatewayIPV4Address)
+	assert.Equal(t, config.SubnetGatewayIPV6Address, eniConfig.SubnetGatewayIPV6Address)
+}
+
+// TestConstructENINetworkConfigIPV4Only tests that the configuration of enis
+// without ipv6 addresses carries no ipv6 fields for the eni plugin
+func TestConstructENINetworkConfigIPV4Only(t *testing.T) {
+	ecscniClient := NewClient(&Config{})
+
+	config := &Config{
+		ENIID:                    "eni-12345678",
+		ENIIPV4Address:           "172.31.21.40",
+		ENIMACAddress:            "02:7b:64:49:b1:40",
+		SubnetGatewayIPV4Address: "172.31.1.1/20",
+	}
+
+	_, eniNetworkConfig, err := ecscniClient.(*cniClient).createENINetworkConfig(config)
+	assert.NoError(t, err, "construct eni network config failed")
+	assert.NotContains(t, string(eniNetworkConfig.Bytes), "ipv6")
 }
 
 // TestConstructBridgeNetworkConfigWithoutIPAM tests createBridgeNetworkConfigWithoutIPAM creates the right configuration for bridge plugin
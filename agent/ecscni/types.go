@@ -41,6 +41,12 @@ const (
 	ECSBridgePluginName = "ecs-bridge"
 	// ECSENIPluginName is the binary of the eni plugin
 	ECSENIPluginName = "ecs-eni"
+	// ECSAppMeshPluginName is the binary of the plugin programming the
+	// iptables rules redirecting the traffic of tasks to their App Mesh proxy
+	ECSAppMeshPluginName = "aws-appmesh"
+	// defaultAppMeshIfName is the interface name passed to the app mesh
+	// plugin, which doesn't create any interface
+	defaultAppMeshIfName = "aws-appmesh"
 	// TaskIAMRoleEndpoint is the endpoint of ecs-agent exposes credentials for
 	// task IAM role
 	TaskIAMRoleEndpoint = "169.254.170.2/32"
@@ -113,7 +119,7 @@ type ENIConfig struct {
 	// IPV4Address is the ipv4 of eni
 	IPV4Address string `json:"ipv4-address"`
 	// IPV6Address is the ipv6 of eni
-	IPV6Address string `json:"ipv6-address, omitempty"`
+	IPV6Address string `json:"ipv6-address,omitempty"`
 	// MacAddress is the mac address of eni
 	MACAddress string `json:"mac"`
 	// BlockInstanceMetdata specifies if InstanceMetadata endpoint should be
@@ -122,6 +128,33 @@ type ENIConfig struct {
 	// SubnetGatewayIPV4Address specifies the ipv4 address of the subnet gateway
 	// for the ENI
 	SubnetGatewayIPV4Address string `json:"subnetgateway-ipv4-address"`
+	// SubnetGatewayIPV6Address specifies the ipv6 address of the subnet gateway
+	// for the ENI, which the default ipv6 route of the namespace goes through
+	SubnetGatewayIPV6Address string `json:"subnetgateway-ipv6-address,omitempty"`
+}
+
+// AppMeshConfig contains all the information needed to invoke the app mesh
+// plugin
+type AppMeshConfig struct {
+	// Type is the cni plugin name
+	Type string `json:"type,omitempty"`
+	// CNIVersion is the cni spec version to use
+	CNIVersion string `json:"cniVersion,omitempty"`
+	// IgnoredUID is the uid of the proxy, whose egress traffic isn't redirected
+	IgnoredUID string `json:"ignoredUID,omitempty"`
+	// IgnoredGID is the gid of the proxy, whose egress traffic isn't redirected
+	IgnoredGID string `json:"ignoredGID,omitempty"`
+	// ProxyIngressPort is the port the ingress traffic is redirected to
+	ProxyIngressPort string `json:"proxyIngressPort,omitempty"`
+	// ProxyEgressPort is the port the egress traffic is redirected to
+	ProxyEgressPort string `json:"proxyEgressPort"`
+	// AppPorts are the ports whose ingress traffic is redirected
+	AppPorts []string `json:"appPorts,omitempty"`
+	// EgressIgnoredPorts are the ports whose egress traffic isn't redirected
+	EgressIgnoredPorts []string `json:"egressIgnoredPorts,omitempty"`
+	// EgressIgnoredIPs are the ips and cidr blocks whose egress traffic isn't
+	// redirected
+	EgressIgnoredIPs []string `json:"egressIgnoredIPs,omitempty"`
 }
 
 // Config contains all the information to set up the container namespace using
@@ -156,4 +189,28 @@ type Config struct {
 	AdditionalLocalRoutes []cnitypes.IPNet
 	// SubnetGatewayIPV4Address is the address to the subnet gate for the eni
 	SubnetGatewayIPV4Address string
+	// SubnetGatewayIPV6Address is the address to the ipv6 subnet gateway for
+	// the eni
+	SubnetGatewayIPV6Address string
+	// AppMeshCNIEnabled specifies if the traffic of the task is redirected to
+	// its App Mesh proxy by the app mesh plugin
+	AppMeshCNIEnabled bool
+	// IgnoredUID is the uid of the proxy, whose egress traffic isn't redirected
+	IgnoredUID string
+	// IgnoredGID is the gid of the proxy, whose egress traffic isn't redirected
+	IgnoredGID string
+	// ProxyIngressPort is the port the ingress traffic is redirected to
+	ProxyIngressPort string
+	// ProxyEgressPort is the port the egress traffic is redirected to
+	ProxyEgressPort string
+	// AppPorts are the ports whose ingress traffic is redirected
+	AppPorts []string
+	// EgressIgnoredPorts are the ports whose egress traffic isn't redirected
+	EgressIgnoredPorts []string
+	// EgressIgnoredIPs are the ips and cidr blocks whose egress traffic isn't
+	// redirected
+	EgressIgnoredIPs []string
+	// EgressBandwidthLimit is the rate, in Mbps, the traffic sent through the
+	// eni is limited to. The traffic isn't limited when it's 0
+	EgressBandwidthLimit uint64
 }
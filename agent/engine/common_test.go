@@ -190,9 +190,22 @@ func validateContainerRunWorkflow(t *testing.T,
 				containerEventsWG.Done()
 			}()
 		}).Return(dockerapi.DockerContainerMetadata{DockerID: containerID})
+	expectContainerWaitUntilTaskEnds(client)
 	assertions()
 }
 
+// expectContainerWaitUntilTaskEnds sets up waiting for the exit of containers to
+// block until the task ends, for tests that report container exits through
+// docker events
+func expectContainerWaitUntilTaskEnds(client *mock_dockerapi.MockDockerClient) {
+	client.EXPECT().WaitContainer(gomock.Any(), gomock.Any()).Do(
+		func(ctx context.Context, dockerID string) {
+			<-ctx.Done()
+		}).Return(dockerapi.DockerContainerMetadata{
+		Error: dockerapi.CannotWaitContainerError{FromError: context.Canceled},
+	}).AnyTimes()
+}
+
 // checkDockerConfigsExceptEnv checks whether the contents in the docker config are expected
 // except for the Env field. Checking for Env field is seperated because when agent converts
 // its container config to docker config, it iterates over the container's env map and
@@ -14,11 +14,14 @@
 package dependencygraph
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	log "github.com/cihub/seelog"
@@ -41,8 +44,34 @@ var (
 	// ErrResourceDependencyNotResolved is when the container's dependencies
 	// on task resources are not resolved
 	ErrResourceDependencyNotResolved = errors.New("dependency graph: dependency on resources not resolved")
+	// ErrContainerOrderingNotResolved is when the containers a container
+	// depends on haven't reached the conditions specified for them yet
+	ErrContainerOrderingNotResolved = errors.New("dependency graph: container ordering dependency not resolved")
+	// ErrContainerOrderingUnresolvable is when a container depends on a
+	// container that stopped without reaching the condition specified for it
+	ErrContainerOrderingUnresolvable = errors.New("dependency graph: container ordering dependency cannot be resolved")
+	// ErrContainerShutdownOrderNotResolved is when a container can't be
+	// stopped yet because the containers depending on it are still stopping
+	ErrContainerShutdownOrderNotResolved = errors.New("dependency graph: containers depending on container not stopped")
 )
 
+// ContainerOrderingTimeoutError is the error where a container gave up waiting
+// on a container it depends on to reach the condition specified for it
+type ContainerOrderingTimeoutError struct {
+	Dependency apicontainer.DependsOn
+	Timeout    time.Duration
+}
+
+func (err *ContainerOrderingTimeoutError) Error() string {
+	return fmt.Sprintf("dependency %s did not become %s within %ds",
+		err.Dependency.ContainerName, err.Dependency.Condition, int64(err.Timeout/time.Second))
+}
+
+// ErrorName is the name of the error
+func (err *ContainerOrderingTimeoutError) ErrorName() string {
+	return "ContainerOrderingTimeoutError"
+}
+
 // Because a container may depend on another container being created
 // (volumes-from) or running (links) it makes sense to abstract it out
 // to each container having dependencies on another container being in any
@@ -77,6 +106,84 @@ OuterLoop:
 	return true
 }
 
+// ValidateContainerOrdering verifies that the containers each container of the
+// task depends on exist, that the conditions specified for them can be met and
+// that the dependencies don't form a cycle. It is called during
+// DockerTaskEngine.AddTask, so that a task that can never start is rejected
+// rather than left waiting forever.
+func ValidateContainerOrdering(task *apitask.Task) error {
+	containers := make(map[string]*apicontainer.Container)
+	for _, container := range task.Containers {
+		containers[container.Name] = container
+	}
+
+	for _, container := range task.Containers {
+		for _, dependency := range container.DependsOn {
+			dependencyContainer, ok := containers[dependency.ContainerName]
+			if !ok {
+				return errors.Errorf("container %s depends on unknown container %s",
+					container.Name, dependency.ContainerName)
+			}
+			switch dependency.Condition {
+			case apicontainer.DependsOnConditionStart,
+				apicontainer.DependsOnConditionComplete,
+				apicontainer.DependsOnConditionSuccess:
+			case apicontainer.DependsOnConditionHealthy:
+				if !dependencyContainer.HealthStatusShouldBeReported() {
+					return errors.Errorf("container %s depends on container %s being healthy, but it has no health check",
+						container.Name, dependency.ContainerName)
+				}
+			default:
+				return errors.Errorf("container %s depends on container %s with unknown condition %q",
+					container.Name, dependency.ContainerName, dependency.Condition)
+			}
+		}
+	}
+
+	// Links are part of the start ordering as well, as a container isn't
+	// created until the containers it links to are running
+	visited := make(map[string]bool)
+	for _, container := range task.Containers {
+		if cycle := findStartOrderingCycle(container, containers, visited, nil); cycle != nil {
+			return errors.Errorf("circular container dependency: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	return nil
+}
+
+// findStartOrderingCycle walks the containers that have to be started before
+// `container` depth first and returns the names of the containers forming a
+// cycle, if any. `path` holds the containers being walked; the containers in
+// `visited` are known not to be part of a cycle.
+func findStartOrderingCycle(container *apicontainer.Container, containers map[string]*apicontainer.Container,
+	visited map[string]bool, path []string) []string {
+	if visited[container.Name] {
+		return nil
+	}
+	for i, name := range path {
+		if name == container.Name {
+			return append(path[i:], container.Name)
+		}
+	}
+
+	path = append(path, container.Name)
+	dependencies := linksToContainerNames(container.Links)
+	for _, dependency := range container.DependsOn {
+		dependencies = append(dependencies, dependency.ContainerName)
+	}
+	for _, dependency := range dependencies {
+		dependencyContainer, ok := containers[dependency]
+		if !ok {
+			continue
+		}
+		if cycle := findStartOrderingCycle(dependencyContainer, containers, visited, path); cycle != nil {
+			return cycle
+		}
+	}
+	visited[container.Name] = true
+	return nil
+}
+
 // DependenciesCanBeResolved verifies that it's possible to transition a `target`
 // given a group of already handled containers, `by`. Essentially, it asks "is
 // `target` resolved by `by`". It assumes that everything in `by` has reached
@@ -111,7 +218,8 @@ func DependenciesAreResolved(target *apicontainer.Container,
 	by []*apicontainer.Container,
 	id string,
 	manager credentials.Manager,
-	resources []taskresource.TaskResource) error {
+	resources []taskresource.TaskResource,
+	cfg *config.Config) error {
 	if !executionCredentialsResolved(target, id, manager) {
 		return CredentialsNotResolvedErr
 	}
@@ -141,6 +249,15 @@ func DependenciesAreResolved(target *apicontainer.Container,
 	if !verifyStatusResolvable(target, nameMap, target.SteadyStateDependencies, onSteadyStateIsResolved) {
 		return DependentContainerNotResolvedErr
 	}
+
+	if err := verifyContainerOrderingResolved(target, nameMap, cfg); err != nil {
+		return err
+	}
+
+	if !verifyShutdownOrderResolved(target, by) {
+		return ErrContainerShutdownOrderNotResolved
+	}
+
 	if err := verifyTransitionDependenciesResolved(target, nameMap, resourcesMap); err != nil {
 		return err
 	}
@@ -193,6 +310,121 @@ func verifyStatusResolvable(target *apicontainer.Container, existingContainers m
 	return true
 }
 
+// verifyContainerOrderingResolved validates that the containers `target`
+// depends on have reached the conditions specified for them. Only the start of
+// `target` is held back; it can be pulled and created while it waits. A
+// dependency that doesn't reach its condition within its start timeout, or the
+// container start timeout of the config if it has none, fails `target`.
+func verifyContainerOrderingResolved(target *apicontainer.Container,
+	existingContainers map[string]*apicontainer.Container, cfg *config.Config) error {
+	if target.GetKnownStatus() < apicontainerstatus.ContainerCreated || target.DesiredTerminal() {
+		return nil
+	}
+
+	for _, dependency := range target.DependsOn {
+		dependencyContainer, ok := existingContainers[dependency.ContainerName]
+		if !ok {
+			return ErrContainerOrderingUnresolvable
+		}
+		resolved, resolvable := containerOrderingIsResolved(dependencyContainer, dependency.Condition)
+		if !resolvable {
+			return ErrContainerOrderingUnresolvable
+		}
+		if !resolved {
+			startTimeout := dependencyContainer.GetStartTimeout()
+			if startTimeout <= 0 {
+				startTimeout = cfg.ContainerStartTimeout
+			}
+			createdAt := target.GetCreatedAt()
+			if !createdAt.IsZero() && time.Since(createdAt) > startTimeout {
+				return &ContainerOrderingTimeoutError{
+					Dependency: dependency,
+					Timeout:    startTimeout,
+				}
+			}
+			return ErrContainerOrderingNotResolved
+		}
+	}
+	return nil
+}
+
+// verifyShutdownOrderResolved validates that the running `target` container
+// can be stopped, which is once the containers depending on it that are being
+// stopped as well have stopped. Containers are stopped in the reverse of the
+// order they're started in, so that a container doesn't lose the containers it
+// depends on while it's stopping.
+func verifyShutdownOrderResolved(target *apicontainer.Container, by []*apicontainer.Container) bool {
+	if !target.DesiredTerminal() || target.GetKnownStatus() != apicontainerstatus.ContainerRunning {
+		return true
+	}
+
+	for _, dependent := range by {
+		if dependent == target || !dependent.DesiredTerminal() || dependent.KnownTerminal() {
+			continue
+		}
+		if containerDependsOn(dependent, target.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// containerDependsOn returns true if `container` has to be started after the
+// container named `name`, because it depends on or links to it
+func containerDependsOn(container *apicontainer.Container, name string) bool {
+	for _, dependency := range container.DependsOn {
+		if dependency.ContainerName == name {
+			return true
+		}
+	}
+	for _, link := range linksToContainerNames(container.Links) {
+		if link == name {
+			return true
+		}
+	}
+	return false
+}
+
+// containerOrderingIsResolved returns whether `dependency` has reached
+// `condition`, and whether it still can if it hasn't
+func containerOrderingIsResolved(dependency *apicontainer.Container, condition string) (bool, bool) {
+	knownStatus := dependency.GetKnownStatus()
+	stopped := knownStatus == apicontainerstatus.ContainerStopped
+	exitCode := dependency.GetKnownExitCode()
+
+	switch condition {
+	case apicontainer.DependsOnConditionStart:
+		if stopped {
+			// A container that exited before the dependent container was
+			// started counts as started, as long as it did start
+			started := !dependency.GetStartedAt().IsZero()
+			return started, started
+		}
+		return knownStatus >= apicontainerstatus.ContainerRunning, true
+	case apicontainer.DependsOnConditionComplete:
+		if stopped {
+			return exitCode != nil, exitCode != nil
+		}
+		return false, true
+	case apicontainer.DependsOnConditionSuccess:
+		if stopped {
+			succeeded := exitCode != nil && *exitCode == 0
+			return succeeded, succeeded
+		}
+		return false, true
+	case apicontainer.DependsOnConditionHealthy:
+		if !dependency.HealthStatusShouldBeReported() {
+			return false, false
+		}
+		if dependency.GetHealthStatus().Status == apicontainerstatus.ContainerHealthy {
+			return true, true
+		}
+		return false, !stopped
+	default:
+		return false, false
+	}
+}
+
 func verifyTransitionDependenciesResolved(target *apicontainer.Container,
 	existingContainers map[string]*apicontainer.Container,
 	existingResources map[string]taskresource.TaskResource) error {
@@ -18,13 +18,16 @@ package dependencygraph
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/mocks"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+	"github.com/aws/aws-sdk-go/aws"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -114,6 +117,261 @@ func TestValidDependenciesWithUnresolvedReference(t *testing.T) {
 	assert.False(t, resolveable, "Nonexistent reference shouldn't resolve")
 }
 
+func TestValidateContainerOrdering(t *testing.T) {
+	dependsOn := func(name, condition string) []apicontainer.DependsOn {
+		return []apicontainer.DependsOn{{ContainerName: name, Condition: condition}}
+	}
+	testCases := []struct {
+		name          string
+		containers    []*apicontainer.Container
+		expectedError string
+	}{
+		{
+			name: "no dependencies",
+			containers: []*apicontainer.Container{
+				{Name: "a"},
+				{Name: "b"},
+			},
+		},
+		{
+			name: "valid dependencies",
+			containers: []*apicontainer.Container{
+				{Name: "init"},
+				{Name: "sidecar", HealthCheckType: apicontainer.DockerHealthCheckType},
+				{Name: "app", DependsOn: []apicontainer.DependsOn{
+					{ContainerName: "init", Condition: apicontainer.DependsOnConditionSuccess},
+					{ContainerName: "sidecar", Condition: apicontainer.DependsOnConditionHealthy},
+				}},
+			},
+		},
+		{
+			name: "unknown container",
+			containers: []*apicontainer.Container{
+				{Name: "a", DependsOn: dependsOn("b", apicontainer.DependsOnConditionStart)},
+			},
+			expectedError: "container a depends on unknown container b",
+		},
+		{
+			name: "unknown condition",
+			containers: []*apicontainer.Container{
+				{Name: "a", DependsOn: dependsOn("b", "STOP")},
+				{Name: "b"},
+			},
+			expectedError: `container a depends on container b with unknown condition "STOP"`,
+		},
+		{
+			name: "healthy without health check",
+			containers: []*apicontainer.Container{
+				{Name: "a", DependsOn: dependsOn("b", apicontainer.DependsOnConditionHealthy)},
+				{Name: "b"},
+			},
+			expectedError: "container a depends on container b being healthy, but it has no health check",
+		},
+		{
+			name: "circular dependency",
+			containers: []*apicontainer.Container{
+				{Name: "a", DependsOn: dependsOn("b", apicontainer.DependsOnConditionStart)},
+				{Name: "b", DependsOn: dependsOn("c", apicontainer.DependsOnConditionComplete)},
+				{Name: "c", DependsOn: dependsOn("a", apicontainer.DependsOnConditionSuccess)},
+			},
+			expectedError: "circular container dependency: a -> b -> c -> a",
+		},
+		{
+			name: "circular dependency through links",
+			containers: []*apicontainer.Container{
+				{Name: "a", Links: []string{"b:alias"}},
+				{Name: "b", DependsOn: dependsOn("a", apicontainer.DependsOnConditionStart)},
+			},
+			expectedError: "circular container dependency: a -> b -> a",
+		},
+		{
+			name: "depends on itself",
+			containers: []*apicontainer.Container{
+				{Name: "a", DependsOn: dependsOn("a", apicontainer.DependsOnConditionStart)},
+			},
+			expectedError: "circular container dependency: a -> a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateContainerOrdering(&apitask.Task{Containers: tc.containers})
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestContainerOrderingIsResolved(t *testing.T) {
+	exitCode := func(code int) *int { return &code }
+	testCases := []struct {
+		name               string
+		condition          string
+		knownStatus        apicontainerstatus.ContainerStatus
+		exitCode           *int
+		started            bool
+		healthStatus       apicontainerstatus.ContainerHealthStatus
+		expectedResolved   bool
+		expectedResolvable bool
+	}{
+		{"start, created", apicontainer.DependsOnConditionStart, apicontainerstatus.ContainerCreated, nil, false, apicontainerstatus.ContainerHealthUnknown, false, true},
+		{"start, running", apicontainer.DependsOnConditionStart, apicontainerstatus.ContainerRunning, nil, true, apicontainerstatus.ContainerHealthUnknown, true, true},
+		{"start, stopped after starting", apicontainer.DependsOnConditionStart, apicontainerstatus.ContainerStopped, exitCode(1), true, apicontainerstatus.ContainerHealthUnknown, true, true},
+		{"start, stopped without starting", apicontainer.DependsOnConditionStart, apicontainerstatus.ContainerStopped, nil, false, apicontainerstatus.ContainerHealthUnknown, false, false},
+		{"complete, running", apicontainer.DependsOnConditionComplete, apicontainerstatus.ContainerRunning, nil, true, apicontainerstatus.ContainerHealthUnknown, false, true},
+		{"complete, exited with error", apicontainer.DependsOnConditionComplete, apicontainerstatus.ContainerStopped, exitCode(1), true, apicontainerstatus.ContainerHealthUnknown, true, true},
+		{"complete, stopped without exit code", apicontainer.DependsOnConditionComplete, apicontainerstatus.ContainerStopped, nil, false, apicontainerstatus.ContainerHealthUnknown, false, false},
+		{"success, running", apicontainer.DependsOnConditionSuccess, apicontainerstatus.ContainerRunning, nil, true, apicontainerstatus.ContainerHealthUnknown, false, true},
+		{"success, exited successfully", apicontainer.DependsOnConditionSuccess, apicontainerstatus.ContainerStopped, exitCode(0), true, apicontainerstatus.ContainerHealthUnknown, true, true},
+		{"success, exited with error", apicontainer.DependsOnConditionSuccess, apicontainerstatus.ContainerStopped, exitCode(1), true, apicontainerstatus.ContainerHealthUnknown, false, false},
+		{"healthy, unknown", apicontainer.DependsOnConditionHealthy, apicontainerstatus.ContainerRunning, nil, true, apicontainerstatus.ContainerHealthUnknown, false, true},
+		{"healthy, unhealthy", apicontainer.DependsOnConditionHealthy, apicontainerstatus.ContainerRunning, nil, true, apicontainerstatus.ContainerUnhealthy, false, true},
+		{"healthy, healthy", apicontainer.DependsOnConditionHealthy, apicontainerstatus.ContainerRunning, nil, true, apicontainerstatus.ContainerHealthy, true, true},
+		{"healthy, stopped", apicontainer.DependsOnConditionHealthy, apicontainerstatus.ContainerStopped, exitCode(1), true, apicontainerstatus.ContainerUnhealthy, false, false},
+		{"unknown condition", "STOP", apicontainerstatus.ContainerStopped, exitCode(0), true, apicontainerstatus.ContainerHealthUnknown, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dependency := &apicontainer.Container{
+				Name:              "dependency",
+				KnownStatusUnsafe: tc.knownStatus,
+				HealthCheckType:   apicontainer.DockerHealthCheckType,
+			}
+			if tc.exitCode != nil {
+				dependency.SetKnownExitCode(tc.exitCode)
+			}
+			if tc.started {
+				dependency.SetStartedAt(time.Now())
+			}
+			dependency.SetHealthStatus(apicontainer.HealthStatus{Status: tc.healthStatus})
+
+			resolved, resolvable := containerOrderingIsResolved(dependency, tc.condition)
+			assert.Equal(t, tc.expectedResolved, resolved)
+			assert.Equal(t, tc.expectedResolvable, resolvable)
+		})
+	}
+}
+
+func TestDependenciesAreResolvedWithContainerOrdering(t *testing.T) {
+	initContainer := &apicontainer.Container{
+		Name:                "init",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+	}
+	app := &apicontainer.Container{
+		Name:                "app",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		DependsOn: []apicontainer.DependsOn{
+			{ContainerName: "init", Condition: apicontainer.DependsOnConditionSuccess},
+		},
+	}
+	containers := []*apicontainer.Container{initContainer, app}
+
+	assert.NoError(t, DependenciesAreResolved(app, containers, "", nil, nil, &config.Config{}),
+		"app should be pulled without waiting for init")
+	app.SetKnownStatus(apicontainerstatus.ContainerPulled)
+	assert.NoError(t, DependenciesAreResolved(app, containers, "", nil, nil, &config.Config{}),
+		"app should be created without waiting for init")
+
+	app.SetKnownStatus(apicontainerstatus.ContainerCreated)
+	assert.Equal(t, ErrContainerOrderingNotResolved, DependenciesAreResolved(app, containers, "", nil, nil, &config.Config{}),
+		"app shouldn't start while init is running")
+
+	initContainer.SetKnownStatus(apicontainerstatus.ContainerStopped)
+	initContainer.SetKnownExitCode(aws.Int(1))
+	assert.Equal(t, ErrContainerOrderingUnresolvable, DependenciesAreResolved(app, containers, "", nil, nil, &config.Config{}),
+		"app can never start once init failed")
+
+	initContainer.SetKnownExitCode(aws.Int(0))
+	assert.NoError(t, DependenciesAreResolved(app, containers, "", nil, nil, &config.Config{}),
+		"app should start once init succeeded")
+
+	app.SetDesiredStatus(apicontainerstatus.ContainerStopped)
+	initContainer.SetKnownExitCode(aws.Int(1))
+	assert.NoError(t, DependenciesAreResolved(app, containers, "", nil, nil, &config.Config{}),
+		"app should always be able to stop")
+}
+
+func TestDependenciesAreResolvedWithContainerOrderingTimeout(t *testing.T) {
+	initContainer := &apicontainer.Container{
+		Name:                "init",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+	}
+	app := &apicontainer.Container{
+		Name:                "app",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerCreated,
+		DependsOn: []apicontainer.DependsOn{
+			{ContainerName: "init", Condition: apicontainer.DependsOnConditionComplete},
+		},
+	}
+	app.SetCreatedAt(time.Now().Add(-2 * time.Minute))
+	containers := []*apicontainer.Container{initContainer, app}
+	cfg := &config.Config{ContainerStartTimeout: time.Minute}
+
+	err := DependenciesAreResolved(app, containers, "", nil, nil, cfg)
+	assert.EqualError(t, err, "dependency init did not become COMPLETE within 60s",
+		"the start timeout of the config should be used when the dependency has none")
+
+	initContainer.StartTimeout = 300
+	err = DependenciesAreResolved(app, containers, "", nil, nil, cfg)
+	assert.Equal(t, ErrContainerOrderingNotResolved, err,
+		"the start timeout of the dependency should override the config")
+
+	initContainer.StartTimeout = 90
+	err = DependenciesAreResolved(app, containers, "", nil, nil, cfg)
+	timeoutErr, ok := err.(*ContainerOrderingTimeoutError)
+	if assert.True(t, ok, "expected the wait for init to time out") {
+		assert.Equal(t, 90*time.Second, timeoutErr.Timeout)
+		assert.Equal(t, "init", timeoutErr.Dependency.ContainerName)
+	}
+}
+
+func TestDependenciesAreResolvedWithShutdownOrder(t *testing.T) {
+	db := &apicontainer.Container{
+		Name:                "db",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerStopped,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+	}
+	proxy := &apicontainer.Container{
+		Name:                "proxy",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerStopped,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+	}
+	app := &apicontainer.Container{
+		Name:                "app",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerStopped,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		Links:               []string{"db:database"},
+		DependsOn: []apicontainer.DependsOn{
+			{ContainerName: "proxy", Condition: apicontainer.DependsOnConditionStart},
+		},
+	}
+	containers := []*apicontainer.Container{db, proxy, app}
+	cfg := &config.Config{}
+
+	assert.NoError(t, DependenciesAreResolved(app, containers, "", nil, nil, cfg),
+		"nothing depends on app, so it's stopped first")
+	assert.Equal(t, ErrContainerShutdownOrderNotResolved, DependenciesAreResolved(db, containers, "", nil, nil, cfg),
+		"db can't be stopped before app, which links to it")
+	assert.Equal(t, ErrContainerShutdownOrderNotResolved, DependenciesAreResolved(proxy, containers, "", nil, nil, cfg),
+		"proxy can't be stopped before app, which depends on it")
+
+	app.SetDesiredStatus(apicontainerstatus.ContainerRunning)
+	assert.NoError(t, DependenciesAreResolved(proxy, containers, "", nil, nil, cfg),
+		"proxy doesn't wait for app when app isn't being stopped")
+
+	app.SetDesiredStatus(apicontainerstatus.ContainerStopped)
+	app.SetKnownStatus(apicontainerstatus.ContainerStopped)
+	assert.NoError(t, DependenciesAreResolved(db, containers, "", nil, nil, cfg))
+	assert.NoError(t, DependenciesAreResolved(proxy, containers, "", nil, nil, cfg))
+}
+
 func TestDependenciesAreResolvedWhenSteadyStateIsRunning(t *testing.T) {
 	task := &apitask.Task{
 		Containers: []*apicontainer.Container{
@@ -123,7 +381,7 @@ func TestDependenciesAreResolvedWhenSteadyStateIsRunning(t *testing.T) {
 			},
 		},
 	}
-	err := DependenciesAreResolved(task.Containers[0], task.Containers, "", nil, nil)
+	err := DependenciesAreResolved(task.Containers[0], task.Containers, "", nil, nil, &config.Config{})
 	assert.NoError(t, err, "One container should resolve trivially")
 
 	// Webserver stack
@@ -140,28 +398,28 @@ func TestDependenciesAreResolvedWhenSteadyStateIsRunning(t *testing.T) {
 		},
 	}
 
-	err = DependenciesAreResolved(php, task.Containers, "", nil, nil)
+	err = DependenciesAreResolved(php, task.Containers, "", nil, nil, &config.Config{})
 	assert.Error(t, err, "Shouldn't be resolved; db isn't running")
 
-	err = DependenciesAreResolved(db, task.Containers, "", nil, nil)
+	err = DependenciesAreResolved(db, task.Containers, "", nil, nil, &config.Config{})
 	assert.Error(t, err, "Shouldn't be resolved; dbdatavolume isn't created")
 
-	err = DependenciesAreResolved(dbdata, task.Containers, "", nil, nil)
+	err = DependenciesAreResolved(dbdata, task.Containers, "", nil, nil, &config.Config{})
 	assert.NoError(t, err, "data volume with no deps should resolve")
 
 	dbdata.KnownStatusUnsafe = apicontainerstatus.ContainerCreated
-	err = DependenciesAreResolved(php, task.Containers, "", nil, nil)
+	err = DependenciesAreResolved(php, task.Containers, "", nil, nil, &config.Config{})
 	assert.Error(t, err, "Php shouldn't run, db is not created")
 
 	db.KnownStatusUnsafe = apicontainerstatus.ContainerCreated
-	err = DependenciesAreResolved(php, task.Containers, "", nil, nil)
+	err = DependenciesAreResolved(php, task.Containers, "", nil, nil, &config.Config{})
 	assert.Error(t, err, "Php shouldn't run, db is not running")
 
-	err = DependenciesAreResolved(db, task.Containers, "", nil, nil)
+	err = DependenciesAreResolved(db, task.Containers, "", nil, nil, &config.Config{})
 	assert.NoError(t, err, "db should be resolved, dbdata volume is Created")
 	db.KnownStatusUnsafe = apicontainerstatus.ContainerRunning
 
-	err = DependenciesAreResolved(php, task.Containers, "", nil, nil)
+	err = DependenciesAreResolved(php, task.Containers, "", nil, nil, &config.Config{})
 	assert.NoError(t, err, "Php should resolve")
 }
 
@@ -178,15 +436,15 @@ func TestRunDependencies(t *testing.T) {
 	}
 	task := &apitask.Task{Containers: []*apicontainer.Container{c1, c2}}
 
-	assert.Error(t, DependenciesAreResolved(c2, task.Containers, "", nil, nil), "Dependencies should not be resolved")
+	assert.Error(t, DependenciesAreResolved(c2, task.Containers, "", nil, nil, &config.Config{}), "Dependencies should not be resolved")
 	task.Containers[1].SetDesiredStatus(apicontainerstatus.ContainerRunning)
-	assert.Error(t, DependenciesAreResolved(c2, task.Containers, "", nil, nil), "Dependencies should not be resolved")
+	assert.Error(t, DependenciesAreResolved(c2, task.Containers, "", nil, nil, &config.Config{}), "Dependencies should not be resolved")
 
 	task.Containers[0].KnownStatusUnsafe = apicontainerstatus.ContainerRunning
-	assert.NoError(t, DependenciesAreResolved(c2, task.Containers, "", nil, nil), "Dependencies should be resolved")
+	assert.NoError(t, DependenciesAreResolved(c2, task.Containers, "", nil, nil, &config.Config{}), "Dependencies should be resolved")
 
 	task.Containers[1].SetDesiredStatus(apicontainerstatus.ContainerCreated)
-	assert.NoError(t, DependenciesAreResolved(c1, task.Containers, "", nil, nil), "Dependencies should be resolved")
+	assert.NoError(t, DependenciesAreResolved(c1, task.Containers, "", nil, nil, &config.Config{}), "Dependencies should be resolved")
 }
 
 func TestRunDependenciesWhenSteadyStateIsResourcesProvisionedForOneContainer(t *testing.T) {
@@ -212,11 +470,11 @@ func TestRunDependenciesWhenSteadyStateIsResourcesProvisionedForOneContainer(t *
 			continue
 		}
 		container.SteadyStateDependencies = []string{"pause"}
-		err := DependenciesAreResolved(container, task.Containers, "", nil, nil)
+		err := DependenciesAreResolved(container, task.Containers, "", nil, nil, &config.Config{})
 		assert.Error(t, err, "Shouldn't be resolved; pause isn't running")
 	}
 
-	err := DependenciesAreResolved(pause, task.Containers, "", nil, nil)
+	err := DependenciesAreResolved(pause, task.Containers, "", nil, nil, &config.Config{})
 	assert.NoError(t, err, "Pause container's dependencies should be resolved")
 
 	// Transition pause container to RUNNING
@@ -230,13 +488,13 @@ func TestRunDependenciesWhenSteadyStateIsResourcesProvisionedForOneContainer(t *
 		}
 		// Assert that dependencies remain unresolved until the pause container reaches
 		// RESOURCES_PROVISIONED
-		err = DependenciesAreResolved(container, task.Containers, "", nil, nil)
+		err = DependenciesAreResolved(container, task.Containers, "", nil, nil, &config.Config{})
 		assert.Error(t, err, "Shouldn't be resolved; pause isn't running")
 	}
 	pause.KnownStatusUnsafe = apicontainerstatus.ContainerResourcesProvisioned
 	// Dependecies should be resolved now that the 'pause' container has
 	// transitioned into RESOURCES_PROVISIONED
-	err = DependenciesAreResolved(php, task.Containers, "", nil, nil)
+	err = DependenciesAreResolved(php, task.Containers, "", nil, nil, &config.Config{})
 	assert.NoError(t, err, "Php should resolve")
 }
 
@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,10 +29,15 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine/image"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
 )
 
 const (
 	imageNotFoundForDeletionError = "no such image"
+	// legacyEmptyVolumeImageName is the repository of the image of the empty
+	// volume containers of older versions of the agent, which may still be
+	// present on instances whose agent was upgraded
+	legacyEmptyVolumeImageName = "amazon/amazon-ecs-emptyvolume-base"
 )
 
 // ImageManager is responsible for saving the Image states,
@@ -39,6 +45,7 @@ const (
 type ImageManager interface {
 	RecordContainerReference(container *apicontainer.Container) error
 	RemoveContainerReferenceFromImageState(container *apicontainer.Container) error
+	RecordContainerStopped(container *apicontainer.Container)
 	AddAllImageStates(imageStates []*image.ImageState)
 	GetImageStateFromImageName(containerImageName string) (*image.ImageState, bool)
 	StartImageCleanupProcess(ctx context.Context)
@@ -59,6 +66,16 @@ type dockerImageManager struct {
 	numImagesToDelete                int
 	imageCleanupTimeInterval         time.Duration
 	imagePullBehavior                config.ImagePullBehaviorType
+	// diskUsageHighThreshold is the disk usage percentage of the docker root
+	// directory above which unused images are removed until the usage drops
+	// below diskUsageLowThreshold. Cleanup by disk usage is disabled when 0
+	diskUsageHighThreshold int
+	diskUsageLowThreshold  int
+	// fileSystemUsage returns the disk usage percentage of the path
+	fileSystemUsage func(path string) (float64, error)
+	// exclusionList are the patterns of the names of the images that are
+	// never removed
+	exclusionList []string
 }
 
 // ImageStatesForDeletion is used for implementing the sort interface
@@ -73,9 +90,26 @@ func NewImageManager(cfg *config.Config, client dockerapi.DockerClient, state do
 		numImagesToDelete:        cfg.NumImagesToDeletePerCycle,
 		imageCleanupTimeInterval: cfg.ImageCleanupInterval,
 		imagePullBehavior:        cfg.ImagePullBehavior,
+		diskUsageHighThreshold:   cfg.ImageCleanupDiskUsageHighThreshold,
+		diskUsageLowThreshold:    cfg.ImageCleanupDiskUsageLowThreshold,
+		fileSystemUsage:          fileSystemUsage,
+		exclusionList:            imageCleanupExclusionList(cfg),
 	}
 }
 
+// imageCleanupExclusionList returns the configured exclusion patterns of image
+// cleanup along with the images of the agent's own containers
+func imageCleanupExclusionList(cfg *config.Config) []string {
+	exclusionList := append([]string{legacyEmptyVolumeImageName}, cfg.ImageCleanupExclusionList...)
+	if cfg.PauseContainerImageName != "" {
+		exclusionList = append(exclusionList, cfg.PauseContainerImageName+":"+cfg.PauseContainerTag)
+	}
+	if config.DefaultPauseContainerImageName != "" {
+		exclusionList = append(exclusionList, config.DefaultPauseContainerImageName+":"+config.DefaultPauseContainerTag)
+	}
+	return exclusionList
+}
+
 func (imageManager *dockerImageManager) SetSaver(stateManager statemanager.Saver) {
 	imageManager.saver = stateManager
 }
@@ -119,6 +153,7 @@ func (imageManager *dockerImageManager) RecordContainerReference(container *apic
 	}
 
 	container.ImageID = imageInspected.ID
+	container.SetImageDigest(resolveImageDigest(container.Image, imageInspected.RepoDigests))
 	added := imageManager.addContainerReferenceToExistingImageState(container)
 	if !added {
 		imageManager.addContainerReferenceToNewImageState(container, imageInspected.Size)
@@ -126,6 +161,28 @@ func (imageManager *dockerImageManager) RecordContainerReference(container *apic
 	return nil
 }
 
+// resolveImageDigest returns the digest of the image referenced by the container.
+// Images pulled by digest carry it in their name; otherwise it's the repo digest
+// of the inspected image for the image's repository, if any
+func resolveImageDigest(imageName string, repoDigests []string) string {
+	if i := strings.LastIndex(imageName, "@"); i != -1 {
+		return imageName[i+1:]
+	}
+	repository := imageName
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	for _, repoDigest := range repoDigests {
+		i := strings.LastIndex(repoDigest, "@")
+		if i != -1 && repoDigest[:i] == repository {
+			return repoDigest[i+1:]
+		}
+	}
+	// The digests of the other repositories the image is tagged into aren't
+	// the digest of the image's repository
+	return ""
+}
+
 func (imageManager *dockerImageManager) addContainerReferenceToExistingImageState(container *apicontainer.Container) bool {
 	// this lock is used for reading the image states in the image manager
 	imageManager.updateLock.RLock()
@@ -182,6 +239,24 @@ func (imageManager *dockerImageManager) RemoveContainerReferenceFromImageState(c
 	return imageState.RemoveContainerReference(container)
 }
 
+// RecordContainerStopped updates the last used time of the image state of the
+// container, which stopped, so that images of recently stopped containers are
+// removed after the ones that weren't used for longer
+func (imageManager *dockerImageManager) RecordContainerStopped(container *apicontainer.Container) {
+	imageManager.updateLock.RLock()
+	defer imageManager.updateLock.RUnlock()
+	if container.ImageID == "" {
+		return
+	}
+	imageState, ok := imageManager.getImageState(container.ImageID)
+	if !ok {
+		seelog.Debugf("No image state found for the stopped container %s", container.Name)
+		return
+	}
+	imageState.SetLastUsedAt(time.Now())
+	imageManager.saver.Save()
+}
+
 func (imageManager *dockerImageManager) addImageState(imageState *image.ImageState) {
 	imageManager.imageStates = append(imageManager.imageStates, imageState)
 }
@@ -221,6 +296,11 @@ func (imageManager *dockerImageManager) getCandidateImagesForDeletion() []*image
 	}
 	var imagesForDeletion []*image.ImageState
 	for _, imageState := range imageManager.imageStatesConsideredForDeletion {
+		if pattern, ok := image.MatchExclusionPattern(imageManager.exclusionList, imageState.Image.Names); ok {
+			seelog.Infof("Image [%s] skipped for deletion, excluded from image cleanup by pattern %s",
+				imageState.String(), pattern)
+			continue
+		}
 		if imageManager.isImageOldEnough(imageState) && imageState.HasNoAssociatedContainers() {
 			seelog.Infof("Candidate image for deletion: [%s]", imageState.String())
 			imagesForDeletion = append(imagesForDeletion, imageState)
@@ -240,7 +320,7 @@ func (imageStates ImageStatesForDeletion) Len() int {
 }
 
 func (imageStates ImageStatesForDeletion) Less(i, j int) bool {
-	return imageStates[i].LastUsedAt.Before(imageStates[j].LastUsedAt)
+	return imageStates[i].GetLastUsedAt().Before(imageStates[j].GetLastUsedAt())
 }
 
 func (imageStates ImageStatesForDeletion) Swap(i, j int) {
@@ -313,6 +393,109 @@ func (imageManager *dockerImageManager) removeUnusedImages(ctx context.Context)
 			break
 		}
 	}
+	imageManager.removeDanglingImages(ctx)
+	if imageManager.diskUsageHighThreshold > 0 {
+		imageManager.removeUnusedImagesForDiskUsage(ctx)
+	}
+}
+
+// removeDanglingImages removes the images without tags that are old enough and
+// neither tracked, used by a tracked container nor the parent of another image.
+// Such images are left behind when a tag is pulled again after it was moved to
+// another image
+func (imageManager *dockerImageManager) removeDanglingImages(ctx context.Context) {
+	images, err := imageManager.client.ListImages(ctx, dockerapi.ListImagesTimeout)
+	if err != nil {
+		seelog.Warnf("Unable to list the images to remove dangling images: %v", err)
+		return
+	}
+	inUse := make(map[string]struct{})
+	for _, imageState := range imageManager.getAllImageStates() {
+		inUse[imageState.Image.ImageID] = struct{}{}
+	}
+	for _, task := range imageManager.state.AllTasks() {
+		for _, container := range task.Containers {
+			if container.ImageID != "" {
+				inUse[container.ImageID] = struct{}{}
+			}
+		}
+	}
+	for _, dockerImage := range images {
+		if dockerImage.ParentID != "" {
+			inUse[dockerImage.ParentID] = struct{}{}
+		}
+	}
+
+	removed := 0
+	var reclaimed int64
+	for _, dockerImage := range images {
+		if !isDanglingImage(dockerImage) {
+			continue
+		}
+		if _, ok := inUse[dockerImage.ID]; ok {
+			continue
+		}
+		if time.Since(time.Unix(dockerImage.Created, 0)) < imageManager.minimumAgeBeforeDeletion {
+			continue
+		}
+		seelog.Infof("Removing dangling image: %s", dockerImage.ID)
+		if err := imageManager.client.RemoveImage(ctx, dockerImage.ID, dockerclient.RemoveImageTimeout); err != nil {
+			seelog.Errorf("Error removing dangling image %s - %v", dockerImage.ID, err)
+			continue
+		}
+		seelog.Infof("Dangling image %s removed from the instance, freed %d bytes", dockerImage.ID, dockerImage.Size)
+		removed++
+		reclaimed += dockerImage.Size
+	}
+	if removed > 0 {
+		seelog.Infof("Removed %d dangling images, reclaimed %d bytes", removed, reclaimed)
+	}
+}
+
+// isDanglingImage returns true if the image doesn't have any tag
+func isDanglingImage(dockerImage docker.APIImages) bool {
+	for _, tag := range dockerImage.RepoTags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+// removeUnusedImagesForDiskUsage removes the least recently used images that
+// are eligible for deletion, as long as the disk usage of the docker root
+// directory isn't below the low threshold, once it's above the high threshold
+func (imageManager *dockerImageManager) removeUnusedImagesForDiskUsage(ctx context.Context) {
+	usage, err := imageManager.dockerRootDirUsage(ctx)
+	if err != nil {
+		seelog.Warnf("Unable to get the disk usage of the docker root directory for image cleanup: %v", err)
+		return
+	}
+	if usage < float64(imageManager.diskUsageHighThreshold) {
+		return
+	}
+	seelog.Infof("Disk usage of the docker root directory is %.1f%%, above the image cleanup threshold of %d%%; removing unused images until it's below %d%%",
+		usage, imageManager.diskUsageHighThreshold, imageManager.diskUsageLowThreshold)
+	for usage >= float64(imageManager.diskUsageLowThreshold) {
+		if err := imageManager.removeLeastRecentlyUsedImage(ctx); err != nil {
+			seelog.Infof("End of eligible images for deletion: %v; Disk usage of the docker root directory is still %.1f%%", err, usage)
+			return
+		}
+		usage, err = imageManager.dockerRootDirUsage(ctx)
+		if err != nil {
+			seelog.Warnf("Unable to get the disk usage of the docker root directory for image cleanup: %v", err)
+			return
+		}
+	}
+	seelog.Infof("Disk usage of the docker root directory is down to %.1f%%", usage)
+}
+
+func (imageManager *dockerImageManager) dockerRootDirUsage(ctx context.Context) (float64, error) {
+	rootDir, err := imageManager.client.RootDir(ctx, dockerclient.InfoTimeout)
+	if err != nil {
+		return 0, err
+	}
+	return imageManager.fileSystemUsage(rootDir)
 }
 
 func (imageManager *dockerImageManager) removeLeastRecentlyUsedImage(ctx context.Context) error {
@@ -368,6 +551,7 @@ func (imageManager *dockerImageManager) deleteImage(ctx context.Context, imageID
 	seelog.Infof("Image removed: %v", imageID)
 	imageState.RemoveImageName(imageID)
 	if len(imageState.Image.Names) == 0 {
+		seelog.Infof("Image %s removed from the instance, freed %d bytes", imageState.Image.ImageID, imageState.Image.Size)
 		seelog.Infof("Cleaning up all tracking information for image %s as it has zero references", imageID)
 		delete(imageManager.imageStatesConsideredForDeletion, imageState.Image.ImageID)
 		imageManager.removeImageState(imageState)
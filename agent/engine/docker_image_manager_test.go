@@ -24,8 +24,10 @@ import (
 	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/ec2"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
@@ -49,6 +51,8 @@ func TestImagePullRemoveDeadlock(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	// No dangling images
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).AnyTimes()
 
 	cfg := defaultTestConfig()
 	imageManager := NewImageManager(cfg, client, dockerstate.NewTaskEngineState())
@@ -134,6 +138,100 @@ func TestAddAndRemoveContainerToImageStateReferenceHappyPath(t *testing.T) {
 	}
 }
 
+func TestRecordContainerReferenceSetsImageDigest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	imageManager := NewImageManager(defaultTestConfig(), client, dockerstate.NewTaskEngineState())
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	container := &apicontainer.Container{
+		Name:  "testContainer",
+		Image: "registry:5000/testContainerImage:latest",
+	}
+	imageInspected := &docker.Image{
+		ID: "sha256:qwerty",
+		RepoDigests: []string{
+			"mirror/testContainerImage@sha256:mirror",
+			"registry:5000/testContainerImage@sha256:digest",
+		},
+	}
+	client.EXPECT().InspectImage(container.Image).Return(imageInspected, nil)
+	require.NoError(t, imageManager.RecordContainerReference(container))
+	assert.Equal(t, "sha256:digest", container.GetImageDigest())
+}
+
+func TestRecordContainerReferenceImageDigestOfOtherRepositories(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	imageManager := NewImageManager(defaultTestConfig(), client, dockerstate.NewTaskEngineState())
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	container := &apicontainer.Container{
+		Name:  "testContainer",
+		Image: "registry:5000/testContainerImage:latest",
+	}
+	// The image is tagged into several repositories, none of which is the
+	// container's
+	imageInspected := &docker.Image{
+		ID: "sha256:qwerty",
+		RepoDigests: []string{
+			"mirror/testContainerImage@sha256:mirror",
+			"other:5000/testContainerImage@sha256:other",
+		},
+	}
+	client.EXPECT().InspectImage(container.Image).Return(imageInspected, nil)
+	require.NoError(t, imageManager.RecordContainerReference(container))
+	assert.Empty(t, container.GetImageDigest())
+}
+
+func TestResolveImageDigest(t *testing.T) {
+	testCases := []struct {
+		name        string
+		image       string
+		repoDigests []string
+		digest      string
+	}{
+		{
+			name:   "image pulled by digest",
+			image:  "testContainerImage@sha256:digest",
+			digest: "sha256:digest",
+		},
+		{
+			name:        "repo digest of the image's repository",
+			image:       "registry:5000/testContainerImage:tag",
+			repoDigests: []string{"other@sha256:other", "registry:5000/testContainerImage@sha256:digest"},
+			digest:      "sha256:digest",
+		},
+		{
+			name:        "image without tag",
+			image:       "registry:5000/testContainerImage",
+			repoDigests: []string{"registry:5000/testContainerImage@sha256:digest"},
+			digest:      "sha256:digest",
+		},
+		{
+			name:        "no repo digest of the image's repository",
+			image:       "testContainerImage:tag",
+			repoDigests: []string{"other@sha256:other", "another@sha256:another"},
+			digest:      "",
+		},
+		{
+			name:   "no repo digests",
+			image:  "testContainerImage:tag",
+			digest: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.digest, resolveImageDigest(tc.image, tc.repoDigests))
+		})
+	}
+}
+
 func TestRecordContainerReferenceInspectError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -572,6 +670,36 @@ func TestGetCandidateImagesForDeletionImageHasMoreContainerReferences(t *testing
 	}
 }
 
+func TestGetCandidateImagesForDeletionExcludedImages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	cfg := defaultTestConfig()
+	cfg.ImageCleanupExclusionList = []string{"internal/base-*"}
+	cfg.PauseContainerImageName = "pause"
+	cfg.PauseContainerTag = "1.0"
+	imageManager := NewImageManager(cfg, client, dockerstate.NewTaskEngineState()).(*dockerImageManager)
+	imageManager.minimumAgeBeforeDeletion = time.Millisecond
+
+	imageManager.imageStatesConsideredForDeletion = make(map[string]*image.ImageState)
+	for id, names := range map[string][]string{
+		"sha256:golden":      {"myimage:latest", "internal/base-java:8"},
+		"sha256:pause":       {"pause:1.0"},
+		"sha256:emptyvolume": {"amazon/amazon-ecs-emptyvolume-base:autogenerated"},
+		"sha256:unused":      {"myotherimage:latest"},
+	} {
+		imageManager.imageStatesConsideredForDeletion[id] = &image.ImageState{
+			Image:    &image.Image{ImageID: id, Names: names},
+			PulledAt: time.Now().AddDate(0, -2, 0),
+		}
+	}
+
+	candidates := imageManager.getCandidateImagesForDeletion()
+	require.Len(t, candidates, 1)
+	assert.Equal(t, "sha256:unused", candidates[0].Image.ImageID)
+}
+
 func TestGetLeastRecentlyUsedImages(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -699,6 +827,8 @@ func TestImageCleanupHappyPath(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	// No dangling images
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).AnyTimes()
 
 	imageManager := &dockerImageManager{
 		client: client,
@@ -751,6 +881,8 @@ func TestImageCleanupCannotRemoveImage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	// No dangling images
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).AnyTimes()
 
 	imageManager := &dockerImageManager{
 		client: client,
@@ -804,6 +936,8 @@ func TestImageCleanupRemoveImageById(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	// No dangling images
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).AnyTimes()
 
 	imageManager := &dockerImageManager{
 		client: client,
@@ -850,6 +984,226 @@ func TestImageCleanupRemoveImageById(t *testing.T) {
 	}
 }
 
+// diskUsageImageManager returns an image manager with image cleanup by disk
+// usage between 80% and 70%, managing the images provided, which were last used
+// in order
+func diskUsageImageManager(client *mock_dockerapi.MockDockerClient, imageIDs ...string) *dockerImageManager {
+	imageManager := &dockerImageManager{
+		client:                   client,
+		state:                    dockerstate.NewTaskEngineState(),
+		minimumAgeBeforeDeletion: time.Millisecond,
+		diskUsageHighThreshold:   80,
+		diskUsageLowThreshold:    70,
+	}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).AnyTimes()
+	for i, imageID := range imageIDs {
+		imageManager.addImageState(&image.ImageState{
+			Image:      &image.Image{ImageID: imageID, Names: []string{imageID + "-name"}, Size: 1024},
+			PulledAt:   time.Now().AddDate(0, -2, 0),
+			LastUsedAt: time.Now().AddDate(0, -2, 0).Add(time.Duration(i) * time.Minute),
+		})
+	}
+	return imageManager
+}
+
+// fileSystemUsages returns a fileSystemUsage func returning the usages provided,
+// one per call
+func fileSystemUsages(t *testing.T, usages ...float64) func(string) (float64, error) {
+	return func(path string) (float64, error) {
+		assert.Equal(t, "/var/lib/docker", path)
+		require.NotEmpty(t, usages, "unexpected disk usage request")
+		usage := usages[0]
+		usages = usages[1:]
+		return usage, nil
+	}
+}
+
+func TestImageCleanupByDiskUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	imageManager := diskUsageImageManager(client, "sha256:oldest", "sha256:used", "sha256:older", "sha256:recent")
+	imageManager.fileSystemUsage = fileSystemUsages(t, 90, 75, 65)
+	usedImageState, _ := imageManager.getImageState("sha256:used")
+	usedImageState.UpdateContainerReference(&apicontainer.Container{Name: "stopped"})
+
+	client.EXPECT().RootDir(gomock.Any(), dockerclient.InfoTimeout).Return("/var/lib/docker", nil).AnyTimes()
+	gomock.InOrder(
+		client.EXPECT().RemoveImage(gomock.Any(), "sha256:oldest-name", dockerclient.RemoveImageTimeout).Return(nil),
+		client.EXPECT().RemoveImage(gomock.Any(), "sha256:older-name", dockerclient.RemoveImageTimeout).Return(nil),
+	)
+	imageManager.removeUnusedImages(context.TODO())
+
+	assert.Equal(t, 2, imageManager.GetImageStatesCount())
+	_, ok := imageManager.getImageState("sha256:used")
+	assert.True(t, ok, "image referenced by a container should not be removed")
+	_, ok = imageManager.getImageState("sha256:recent")
+	assert.True(t, ok, "image should not be removed once the disk usage is below the low threshold")
+}
+
+func TestImageCleanupByDiskUsageBelowThreshold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	imageManager := diskUsageImageManager(client, "sha256:oldest")
+	imageManager.fileSystemUsage = fileSystemUsages(t, 79)
+
+	client.EXPECT().RootDir(gomock.Any(), dockerclient.InfoTimeout).Return("/var/lib/docker", nil)
+	client.EXPECT().RemoveImage(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	imageManager.removeUnusedImages(context.TODO())
+
+	assert.Equal(t, 1, imageManager.GetImageStatesCount())
+}
+
+func TestImageCleanupByDiskUsageNoMoreEligibleImages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	imageManager := diskUsageImageManager(client, "sha256:oldest", "sha256:used")
+	imageManager.fileSystemUsage = fileSystemUsages(t, 95, 90)
+	usedImageState, _ := imageManager.getImageState("sha256:used")
+	usedImageState.UpdateContainerReference(&apicontainer.Container{Name: "running"})
+
+	client.EXPECT().RootDir(gomock.Any(), dockerclient.InfoTimeout).Return("/var/lib/docker", nil).AnyTimes()
+	client.EXPECT().RemoveImage(gomock.Any(), "sha256:oldest-name", dockerclient.RemoveImageTimeout).Return(nil)
+	imageManager.removeUnusedImages(context.TODO())
+
+	assert.Equal(t, 1, imageManager.GetImageStatesCount())
+}
+
+func TestImageCleanupByDiskUsageRootDirError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	imageManager := diskUsageImageManager(client, "sha256:oldest")
+	imageManager.fileSystemUsage = fileSystemUsages(t)
+
+	client.EXPECT().RootDir(gomock.Any(), dockerclient.InfoTimeout).Return("", errors.New("info error"))
+	imageManager.removeUnusedImages(context.TODO())
+
+	assert.Equal(t, 1, imageManager.GetImageStatesCount())
+}
+
+func TestRemoveDanglingImages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	state := dockerstate.NewTaskEngineState()
+	state.AddTask(&apitask.Task{
+		Arn:        "task",
+		Containers: []*apicontainer.Container{{Name: "stopped", ImageID: "sha256:usedbycontainer"}},
+	})
+	imageManager := &dockerImageManager{
+		client:                   client,
+		state:                    state,
+		minimumAgeBeforeDeletion: time.Hour,
+	}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+	imageManager.addImageState(&image.ImageState{Image: &image.Image{ImageID: "sha256:tracked"}})
+
+	old := time.Now().AddDate(0, -2, 0).Unix()
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).Return([]docker.APIImages{
+		{ID: "sha256:dangling", RepoTags: []string{"<none>:<none>"}, Created: old, Size: 1024},
+		{ID: "sha256:danglingdigest", RepoDigests: []string{"myimage@sha256:digest"}, Created: old, Size: 2048},
+		{ID: "sha256:tagged", RepoTags: []string{"myimage:latest"}, ParentID: "sha256:parent", Created: old},
+		{ID: "sha256:parent", RepoTags: []string{"<none>:<none>"}, Created: old},
+		{ID: "sha256:tracked", Created: old},
+		{ID: "sha256:usedbycontainer", Created: old},
+		{ID: "sha256:recent", Created: time.Now().Unix()},
+		{ID: "sha256:cannotremove", Created: old},
+	}, nil)
+	client.EXPECT().RemoveImage(gomock.Any(), "sha256:dangling", dockerclient.RemoveImageTimeout).Return(nil)
+	client.EXPECT().RemoveImage(gomock.Any(), "sha256:danglingdigest", dockerclient.RemoveImageTimeout).Return(nil)
+	client.EXPECT().RemoveImage(gomock.Any(), "sha256:cannotremove", dockerclient.RemoveImageTimeout).Return(
+		errors.New("conflict: unable to delete, image is being used by a stopped container"))
+
+	imageManager.removeUnusedImages(context.TODO())
+	assert.Equal(t, 1, imageManager.GetImageStatesCount(), "tracked image should not be removed")
+}
+
+func TestRemoveDanglingImagesListError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	imageManager := &dockerImageManager{client: client, state: dockerstate.NewTaskEngineState()}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).Return(nil, errors.New("list error"))
+	imageManager.removeUnusedImages(context.TODO())
+}
+
+func TestRecordContainerStopped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	imageManager := &dockerImageManager{client: client, state: dockerstate.NewTaskEngineState()}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	lastUsedAt := time.Now().AddDate(0, -1, 0)
+	imageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:qwerty"},
+		LastUsedAt: lastUsedAt,
+	}
+	imageManager.addImageState(imageState)
+
+	imageManager.RecordContainerStopped(&apicontainer.Container{Name: "untracked", ImageID: "sha256:untracked"})
+	assert.Equal(t, lastUsedAt, imageState.GetLastUsedAt())
+
+	imageManager.RecordContainerStopped(&apicontainer.Container{Name: "stopped", ImageID: "sha256:qwerty"})
+	assert.True(t, imageState.GetLastUsedAt().After(lastUsedAt), "last used time should be updated by the stop")
+}
+
+// TestImageCleanupRecentlyStoppedImageOutlivesIdleImage tests that the image of
+// a service whose containers stopped recently is removed after an image that
+// was pulled later but hasn't been used since
+func TestImageCleanupRecentlyStoppedImageOutlivesIdleImage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).AnyTimes()
+
+	imageManager := &dockerImageManager{
+		client:                   client,
+		state:                    dockerstate.NewTaskEngineState(),
+		minimumAgeBeforeDeletion: time.Millisecond,
+		numImagesToDelete:        1,
+	}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	serviceContainer := &apicontainer.Container{Name: "service", Image: "service:latest", ImageID: "sha256:service"}
+	serviceImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:service", Names: []string{"service:latest"}},
+		PulledAt:   time.Now().AddDate(0, -2, 0),
+		LastUsedAt: time.Now().AddDate(0, -2, 0),
+	}
+	serviceImageState.UpdateContainerReference(serviceContainer)
+	idleImageState := &image.ImageState{
+		Image:      &image.Image{ImageID: "sha256:idle", Names: []string{"idle:latest"}},
+		PulledAt:   time.Now().AddDate(0, -1, 0),
+		LastUsedAt: time.Now().AddDate(0, -1, 0),
+	}
+	imageManager.AddAllImageStates([]*image.ImageState{serviceImageState, idleImageState})
+
+	// The service ran until now; its container stops and is cleaned up
+	imageManager.RecordContainerStopped(serviceContainer)
+	require.NoError(t, imageManager.RemoveContainerReferenceFromImageState(serviceContainer))
+
+	client.EXPECT().RemoveImage(gomock.Any(), "idle:latest", dockerclient.RemoveImageTimeout).Return(nil)
+	imageManager.removeUnusedImages(context.TODO())
+
+	_, ok := imageManager.getImageState("sha256:service")
+	assert.True(t, ok, "image of the recently stopped service should not be removed")
+	_, ok = imageManager.getImageState("sha256:idle")
+	assert.False(t, ok, "idle image should be removed")
+}
+
 func TestDeleteImage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -974,6 +1328,8 @@ func TestRemoveUnusedImagesNoImages(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	// No dangling images
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).AnyTimes()
 	imageManager := &dockerImageManager{client: client, state: dockerstate.NewTaskEngineState()}
 	imageManager.SetSaver(statemanager.NewNoopStateManager())
 	ctx, cancel := context.WithCancel(context.TODO())
@@ -1035,6 +1391,8 @@ func TestConcurrentRemoveUnusedImages(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	// No dangling images
+	client.EXPECT().ListImages(gomock.Any(), dockerapi.ListImagesTimeout).AnyTimes()
 
 	imageManager := &dockerImageManager{
 		client: client,
@@ -0,0 +1,32 @@
+// +build !windows
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import "golang.org/x/sys/unix"
+
+// fileSystemUsage returns the percentage of the space of the file system of
+// the path that's in use, as reported by df
+func fileSystemUsage(path string) (float64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	used := stat.Blocks - stat.Bfree
+	total := used + stat.Bavail
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(used) * 100 / float64(total), nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/cihub/seelog"
+)
+
+// dockerResourcesCleanupStats counts the docker resources removed by the
+// sweeps of the resources of tasks missing from the state
+type dockerResourcesCleanupStats struct {
+	removedNetworks uint64
+	removedVolumes  uint64
+}
+
+// cleanupDockerResources periodically removes the docker networks and volumes
+// labeled with the arn of a task missing from the state. These are left behind
+// when the agent is stopped before cleaning up the task
+func (engine *DockerTaskEngine) cleanupDockerResources(ctx context.Context) {
+	if engine.cfg.DockerResourcesCleanupInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(engine.cfg.DockerResourcesCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			engine.sweepDockerResources(ctx)
+		}
+	}
+}
+
+// sweepDockerResources removes the docker networks and volumes of the tasks
+// missing from the state. Resources without the task arn label weren't created
+// by the agent and are never removed. They're only logged in dry run mode
+func (engine *DockerTaskEngine) sweepDockerResources(ctx context.Context) {
+	networks, err := engine.client.ListNetworksWithLabel(ctx, labelTaskARN, dockerapi.ListNetworksTimeout)
+	if err != nil {
+		seelog.Warnf("Task engine: unable to list networks to remove the networks of missing tasks: %v", err)
+	}
+	for _, network := range networks {
+		taskARN := network.Labels[labelTaskARN]
+		if !engine.isMissingTask(taskARN) {
+			continue
+		}
+		if engine.cfg.DockerResourcesCleanupDryRun {
+			seelog.Infof("Task engine: dry run, not removing network [%s] of task [%s] missing from the state",
+				network.Name, taskARN)
+			continue
+		}
+		if err := engine.client.RemoveNetwork(ctx, network.ID, dockerapi.RemoveNetworkTimeout); err != nil {
+			seelog.Warnf("Task engine: unable to remove network [%s] of task [%s] missing from the state: %v",
+				network.Name, taskARN, err)
+			continue
+		}
+		atomic.AddUint64(&engine.dockerResourcesCleanupStats.removedNetworks, 1)
+		seelog.Infof("Task engine: removed network [%s] of task [%s] missing from the state", network.Name, taskARN)
+	}
+
+	volumes, err := engine.client.ListVolumesWithLabel(ctx, labelTaskARN, dockerapi.ListVolumesTimeout)
+	if err != nil {
+		seelog.Warnf("Task engine: unable to list volumes to remove the volumes of missing tasks: %v", err)
+	}
+	for _, volume := range volumes {
+		taskARN := volume.Labels[labelTaskARN]
+		if !engine.isMissingTask(taskARN) {
+			continue
+		}
+		if engine.cfg.DockerResourcesCleanupDryRun {
+			seelog.Infof("Task engine: dry run, not removing volume [%s] of task [%s] missing from the state",
+				volume.Name, taskARN)
+			continue
+		}
+		if err := engine.client.RemoveVolume(ctx, volume.Name, dockerapi.RemoveVolumeTimeout); err != nil {
+			seelog.Warnf("Task engine: unable to remove volume [%s] of task [%s] missing from the state: %v",
+				volume.Name, taskARN, err)
+			continue
+		}
+		atomic.AddUint64(&engine.dockerResourcesCleanupStats.removedVolumes, 1)
+		seelog.Infof("Task engine: removed volume [%s] of task [%s] missing from the state", volume.Name, taskARN)
+	}
+
+	seelog.Infof("Task engine: removed %d networks and %d volumes of missing tasks since the agent started",
+		atomic.LoadUint64(&engine.dockerResourcesCleanupStats.removedNetworks),
+		atomic.LoadUint64(&engine.dockerResourcesCleanupStats.removedVolumes))
+}
+
+// isMissingTask returns true if the docker resource labeled with the task arn
+// belongs to a task missing from the state
+func (engine *DockerTaskEngine) isMissingTask(taskARN string) bool {
+	if taskARN == "" {
+		return false
+	}
+	_, ok := engine.state.TaskByArn(taskARN)
+	return !ok
+}
@@ -0,0 +1,99 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSweepDockerResources tests that only the labeled networks and volumes of
+// tasks missing from the state are removed
+func TestSweepDockerResources(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	dockerTaskEngine := taskEngine.(*DockerTaskEngine)
+	testTask := testdata.LoadTask("sleep5")
+	dockerTaskEngine.State().AddTask(testTask)
+
+	client.EXPECT().ListNetworksWithLabel(gomock.Any(), labelTaskARN, dockerapi.ListNetworksTimeout).Return(
+		[]docker.Network{
+			{ID: "unlabeled", Name: "unlabeled"},
+			{ID: "live", Name: "live", Labels: map[string]string{labelTaskARN: testTask.Arn}},
+			{ID: "missing", Name: "missing", Labels: map[string]string{labelTaskARN: "missing-task"}},
+			{ID: "busy", Name: "busy", Labels: map[string]string{labelTaskARN: "missing-task"}},
+		}, nil)
+	client.EXPECT().RemoveNetwork(gomock.Any(), "missing", dockerapi.RemoveNetworkTimeout).Return(nil)
+	client.EXPECT().RemoveNetwork(gomock.Any(), "busy", dockerapi.RemoveNetworkTimeout).Return(
+		errors.New("network has active endpoints"))
+	client.EXPECT().ListVolumesWithLabel(gomock.Any(), labelTaskARN, dockerapi.ListVolumesTimeout).Return(
+		[]docker.Volume{
+			{Name: "live", Labels: map[string]string{labelTaskARN: testTask.Arn}},
+			{Name: "missing", Labels: map[string]string{labelTaskARN: "missing-task"}},
+		}, nil)
+	client.EXPECT().RemoveVolume(gomock.Any(), "missing", dockerapi.RemoveVolumeTimeout).Return(nil)
+
+	dockerTaskEngine.sweepDockerResources(ctx)
+	assert.Equal(t, uint64(1), dockerTaskEngine.dockerResourcesCleanupStats.removedNetworks)
+	assert.Equal(t, uint64(1), dockerTaskEngine.dockerResourcesCleanupStats.removedVolumes)
+}
+
+// TestSweepDockerResourcesDryRun tests that the resources of missing tasks
+// aren't removed in dry run mode
+func TestSweepDockerResourcesDryRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cfg := defaultConfig
+	cfg.DockerResourcesCleanupDryRun = true
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &cfg)
+	defer ctrl.Finish()
+
+	client.EXPECT().ListNetworksWithLabel(gomock.Any(), labelTaskARN, gomock.Any()).Return(
+		[]docker.Network{{ID: "missing", Labels: map[string]string{labelTaskARN: "missing-task"}}}, nil)
+	client.EXPECT().ListVolumesWithLabel(gomock.Any(), labelTaskARN, gomock.Any()).Return(
+		[]docker.Volume{{Name: "missing", Labels: map[string]string{labelTaskARN: "missing-task"}}}, nil)
+
+	dockerTaskEngine := taskEngine.(*DockerTaskEngine)
+	dockerTaskEngine.sweepDockerResources(ctx)
+	assert.Zero(t, dockerTaskEngine.dockerResourcesCleanupStats.removedNetworks)
+	assert.Zero(t, dockerTaskEngine.dockerResourcesCleanupStats.removedVolumes)
+}
+
+// TestSweepDockerResourcesListError tests that the volumes are swept when the
+// networks can't be listed
+func TestSweepDockerResourcesListError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	client.EXPECT().ListNetworksWithLabel(gomock.Any(), labelTaskARN, gomock.Any()).Return(nil, errors.New("error"))
+	client.EXPECT().ListVolumesWithLabel(gomock.Any(), labelTaskARN, gomock.Any()).Return(
+		[]docker.Volume{{Name: "missing", Labels: map[string]string{labelTaskARN: "missing-task"}}}, nil)
+	client.EXPECT().RemoveVolume(gomock.Any(), "missing", gomock.Any()).Return(nil)
+
+	taskEngine.(*DockerTaskEngine).sweepDockerResources(ctx)
+}
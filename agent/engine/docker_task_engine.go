@@ -15,8 +15,12 @@
 package engine
 
 import (
+	"encoding/json"
+	"io"
+	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +28,7 @@ import (
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/config"
@@ -34,7 +39,10 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/ecscni"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dependencygraph"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/engine/hostport"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/gpu"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/statechange"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
@@ -46,6 +54,7 @@ import (
 	"context"
 
 	"github.com/cihub/seelog"
+	"github.com/containernetworking/cni/pkg/types/current"
 	"github.com/pkg/errors"
 )
 
@@ -67,8 +76,48 @@ const (
 	labelCluster                 = labelPrefix + "cluster"
 	cniSetupTimeout              = 1 * time.Minute
 	cniCleanupTimeout            = 30 * time.Second
+	// cniSetupMaxAttempts bounds how many times the setup of the network
+	// namespace of a task is attempted when the plugins fail transiently
+	cniSetupMaxAttempts     = 3
+	cniSetupRetryBackoffMin = 500 * time.Millisecond
+	cniSetupRetryBackoffMax = 5 * time.Second
+	// orphanedContainerMinimumAge is how old a container of a task missing
+	// from the state must be before it's removed, so that the containers of a
+	// task being added aren't removed
+	orphanedContainerMinimumAge = 5 * time.Minute
+	// ephemeralStorageCheckInterval is how often the disk space used by the
+	// containers of the tasks with an ephemeral storage limit is measured
+	ephemeralStorageCheckInterval = 1 * time.Minute
+	// ephemeralStorageExceededReason is the reason recorded for the tasks
+	// stopped for exceeding their ephemeral storage limit
+	ephemeralStorageExceededReason = "ephemeral storage exceeded"
+	// bytesPerMiB is the number of bytes in a mebibyte
+	bytesPerMiB = 1024 * 1024
+	// maxHostPortAllocationAttempts is how many times a container is started
+	// with newly allocated host ports when docker reports that they're in use
+	maxHostPortAllocationAttempts = 3
+	// hostNetworkMode is the network mode of containers sharing the network
+	// namespace of the host, whose container ports are host ports
+	hostNetworkMode = "host"
+	// Parameters for backing off while waiting for the exit of a container
+	// again after the wait failed
+	containerWaitBackoffMin      = time.Second
+	containerWaitBackoffMax      = 10 * time.Second
+	containerWaitBackoffJitter   = 0.2
+	containerWaitBackoffMultiple = 1.5
 )
 
+// quotaStorageDrivers are the storage drivers that support limiting the size
+// of the writable layer of a container with the 'size' storage option. The
+// overlay2 driver is left out as it only supports it on xfs with project
+// quotas enabled, which can't be discovered through the docker api
+var quotaStorageDrivers = map[string]struct{}{
+	"devicemapper":  {},
+	"btrfs":         {},
+	"zfs":           {},
+	"windowsfilter": {},
+}
+
 // DockerTaskEngine is a state machine for managing a task and its containers
 // in ECS.
 //
@@ -126,6 +175,44 @@ type DockerTaskEngine struct {
 	taskSteadyStatePollInterval time.Duration
 
 	resourceFields *taskresource.ResourceFields
+
+	// imagePullSemaphore bounds the number of images being pulled at the same
+	// time to the configured image pull concurrency
+	imagePullSemaphore chan struct{}
+	// imagePulls tracks the in-flight pulls by image reference so that
+	// containers referencing the same image share a single pull
+	imagePulls     map[string]*imagePull
+	imagePullsLock sync.Mutex
+
+	// metadataFileUpdates tracks the containers whose metadata file is being
+	// rewritten, so that the rewrites of a container's file don't overlap. The
+	// value is set when the file has to be rewritten again once the rewrite in
+	// progress completes
+	metadataFileUpdates     map[*apicontainer.Container]bool
+	metadataFileUpdatesLock sync.Mutex
+
+	// gpuManager tracks the GPUs assigned to the containers of the tasks, so
+	// that concurrently starting tasks aren't assigned the same GPU
+	gpuManager *gpu.Manager
+
+	// hostPortAllocator tracks the host ports allocated to the port mappings
+	// without a host port from the dynamic host port range
+	hostPortAllocator *hostport.Allocator
+	// hostPortProber checks whether the static host ports of containers are
+	// bound by processes of the host before the containers are created
+	hostPortProber hostport.Prober
+
+	// dockerResourcesCleanupStats counts the docker resources of tasks missing
+	// from the state removed since the engine started
+	dockerResourcesCleanupStats dockerResourcesCleanupStats
+}
+
+// imagePull is an in-flight image pull that other pulls of the same image
+// wait on
+type imagePull struct {
+	done     chan struct{}
+	waiters  int
+	metadata dockerapi.DockerContainerMetadata
 }
 
 // NewDockerTaskEngine returns a created, but uninitialized, DockerTaskEngine.
@@ -163,6 +250,13 @@ func NewDockerTaskEngine(cfg *config.Config,
 		metadataManager:             metadataManager,
 		taskSteadyStatePollInterval: defaultTaskSteadyStatePollInterval,
 		resourceFields:              resourceFields,
+		imagePullSemaphore:          make(chan struct{}, imagePullConcurrency(cfg)),
+		imagePulls:                  make(map[string]*imagePull),
+		metadataFileUpdates:         make(map[*apicontainer.Container]bool),
+		gpuManager:                  gpu.NewManager(cfg.GPUIDs),
+		hostPortAllocator: hostport.NewAllocator(cfg.DynamicHostPortRangeStart, cfg.DynamicHostPortRangeEnd,
+			cfg.ReservedPorts, cfg.ReservedPortsUDP),
+		hostPortProber: hostport.NewProber(),
 	}
 
 	dockerTaskEngine.initializeContainerStatusToTransitionFunction()
@@ -170,6 +264,15 @@ func NewDockerTaskEngine(cfg *config.Config,
 	return dockerTaskEngine
 }
 
+// imagePullConcurrency returns the number of images that can be pulled at the
+// same time, which is never less than one
+func imagePullConcurrency(cfg *config.Config) int {
+	if cfg.ImagePullConcurrency < 1 {
+		return 1
+	}
+	return cfg.ImagePullConcurrency
+}
+
 func (engine *DockerTaskEngine) initializeContainerStatusToTransitionFunction() {
 	containerStatusToTransitionFunction := map[apicontainerstatus.ContainerStatus]transitionApplyFunc{
 		apicontainerstatus.ContainerPulled:               engine.pullContainer,
@@ -182,7 +285,7 @@ func (engine *DockerTaskEngine) initializeContainerStatusToTransitionFunction()
 }
 
 // ImagePullDeleteLock ensures that pulls and deletes do not run at the same time and pulls can be run at the same time for docker >= 1.11.1
-// Pulls are serialized as a temporary workaround for a devicemapper issue. (see https://github.com/docker/docker/issues/9718)
+// The number of concurrent pulls is bounded by the engine's image pull semaphore.
 // Deletes must not run at the same time as pulls to prevent deletion of images that are being used to launch new tasks.
 var ImagePullDeleteLock sync.RWMutex
 
@@ -216,6 +319,9 @@ func (engine *DockerTaskEngine) Init(ctx context.Context) error {
 	engine.synchronizeState()
 	// Now catch up and start processing new events per normal
 	go engine.handleDockerEvents(derivedCtx)
+	go engine.cleanupOrphanedContainers(derivedCtx)
+	go engine.cleanupDockerResources(derivedCtx)
+	go engine.monitorEphemeralStorage(derivedCtx)
 	engine.initialized = true
 	return nil
 }
@@ -284,6 +390,8 @@ func (engine *DockerTaskEngine) synchronizeState() {
 	tasksToStart := engine.filterTasksToStartUnsafe(tasks)
 	for _, task := range tasks {
 		task.InitializeResources(engine.resourceFields)
+		engine.reserveTaskGPUs(task)
+		engine.reserveTaskHostPorts(task)
 	}
 
 	for _, task := range tasksToStart {
@@ -321,6 +429,80 @@ func (engine *DockerTaskEngine) filterTasksToStartUnsafe(tasks []*apitask.Task)
 	return tasksToStart
 }
 
+// cleanupOrphanedContainers waits for the orphan cleanup wait duration and then
+// removes the containers left behind by the tasks missing from the state
+func (engine *DockerTaskEngine) cleanupOrphanedContainers(ctx context.Context) {
+	if engine.cfg.OrphanCleanupWaitDuration <= 0 {
+		return
+	}
+	timer := time.NewTimer(engine.cfg.OrphanCleanupWaitDuration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+	engine.removeOrphanedContainers(ctx)
+}
+
+// removeOrphanedContainers stops and removes the containers labeled with the
+// arn of a task that isn't in the state. These are left behind when the state
+// of a previous agent was lost. Containers created recently are skipped, as
+// their task may be being added
+func (engine *DockerTaskEngine) removeOrphanedContainers(ctx context.Context) {
+	listResponse := engine.client.ListContainers(ctx, true, dockerclient.ListContainersTimeout)
+	if listResponse.Error != nil {
+		seelog.Warnf("Task engine: unable to list containers to remove orphaned containers: %v", listResponse.Error)
+		return
+	}
+
+	for _, dockerID := range listResponse.DockerIDs {
+		if _, ok := engine.state.ContainerByID(dockerID); ok {
+			continue
+		}
+		container, err := engine.client.InspectContainer(ctx, dockerID, dockerclient.InspectContainerTimeout)
+		if err != nil {
+			seelog.Warnf("Task engine: unable to inspect container [%s] to check if it's orphaned: %v", dockerID, err)
+			continue
+		}
+		if container.Config == nil {
+			continue
+		}
+		taskARN, ok := container.Config.Labels[labelTaskARN]
+		if !ok {
+			continue
+		}
+		if _, ok := engine.state.TaskByArn(taskARN); ok {
+			continue
+		}
+		if engine.time().Now().Sub(container.Created) < orphanedContainerMinimumAge {
+			continue
+		}
+		engine.removeOrphanedContainer(ctx, container, taskARN)
+	}
+}
+
+// removeOrphanedContainer stops and removes a container of a task missing from
+// the state
+func (engine *DockerTaskEngine) removeOrphanedContainer(ctx context.Context, container *docker.Container, taskARN string) {
+	if container.State.Running {
+		metadata := engine.client.StopContainer(ctx, container.ID, engine.cfg.DockerStopTimeout)
+		if metadata.Error != nil {
+			seelog.Warnf("Task engine: unable to stop orphaned container [%s] of task [%s]: %v",
+				container.ID, taskARN, metadata.Error)
+			return
+		}
+	}
+	err := engine.client.RemoveContainer(ctx, container.ID, dockerclient.RemoveContainerTimeout)
+	if err != nil {
+		seelog.Warnf("Task engine: unable to remove orphaned container [%s] of task [%s]: %v",
+			container.ID, taskARN, err)
+		return
+	}
+	seelog.Infof("Task engine: removed orphaned container [%s] of task [%s] missing from the state",
+		container.ID, taskARN)
+}
+
 // updateContainerMetadata sets the container metadata from the docker inspect
 func updateContainerMetadata(metadata *dockerapi.DockerContainerMetadata, container *apicontainer.Container, task *apitask.Task) {
 	container.SetCreatedAt(metadata.CreatedAt)
@@ -372,6 +554,7 @@ func (engine *DockerTaskEngine) synchronizeContainerStatus(container *apicontain
 			metadata := dockerapi.MetadataFromContainer(describedContainer)
 			updateContainerMetadata(&metadata, container.Container, task)
 			container.DockerID = describedContainer.ID
+			container.Container.SetRuntimeID(container.DockerID)
 
 			container.Container.SetKnownStatus(dockerapi.DockerStateToState(describedContainer.State))
 			// update mappings that need dockerid
@@ -381,6 +564,12 @@ func (engine *DockerTaskEngine) synchronizeContainerStatus(container *apicontain
 		return
 	}
 
+	// Containers restored from state files written before the runtime id was
+	// tracked only have the docker id recorded in the engine state
+	if container.Container.GetRuntimeID() == "" {
+		container.Container.SetRuntimeID(container.DockerID)
+	}
+
 	currentState, metadata := engine.client.DescribeContainer(engine.ctx, container.DockerID)
 	if metadata.Error != nil {
 		currentState = apicontainerstatus.ContainerStopped
@@ -401,13 +590,17 @@ func (engine *DockerTaskEngine) synchronizeContainerStatus(container *apicontain
 		// update the container metadata in case the container status/metadata changed during agent restart
 		updateContainerMetadata(&metadata, container.Container, task)
 		engine.imageManager.RecordContainerReference(container.Container)
-		if engine.cfg.ContainerMetadataEnabled && !container.Container.IsMetadataFileUpdated() {
-			go engine.updateMetadataFile(task, container)
-		}
 	}
+	// The metadata file is rewritten if the container transitioned while the
+	// agent was down
+	metadataFileOutdated := !container.Container.IsMetadataFileUpdated()
 	if currentState > container.Container.GetKnownStatus() {
 		// update the container known status
 		container.Container.SetKnownStatus(currentState)
+		metadataFileOutdated = true
+	}
+	if metadata.Error == nil && engine.cfg.ContainerMetadataEnabled && !container.Container.IsInternal() && metadataFileOutdated {
+		engine.queueMetadataFileUpdate(task, container.Container)
 	}
 	// Update task ExecutionStoppedAt timestamp
 	task.RecordExecutionStoppedAt(container.Container)
@@ -443,6 +636,90 @@ func (engine *DockerTaskEngine) checkTaskState(task *apitask.Task) {
 	}
 }
 
+// reconcileStateWithDocker compares the known statuses of the containers of all
+// the tasks with docker once the docker event stream reconnects. The docker
+// daemon may have restarted, stopping or restarting the containers, so the
+// transitions missed while the stream was closed are sent to the tasks
+func (engine *DockerTaskEngine) reconcileStateWithDocker() {
+	seelog.Infof("Task engine: docker event stream reconnected, reconciling the state of containers with docker")
+	listResponse := engine.client.ListContainers(engine.ctx, true, dockerclient.ListContainersTimeout)
+	if listResponse.Error != nil {
+		seelog.Warnf("Task engine: unable to list containers to reconcile the state of containers: %v",
+			listResponse.Error)
+		return
+	}
+	dockerIDs := make(map[string]struct{})
+	for _, dockerID := range listResponse.DockerIDs {
+		dockerIDs[dockerID] = struct{}{}
+	}
+
+	for _, task := range engine.state.AllTasks() {
+		engine.reconcileTaskStateWithDocker(task, dockerIDs)
+	}
+}
+
+// reconcileTaskStateWithDocker inspects the containers of the task that aren't
+// known to be stopped and writes their state to the managed task. Containers
+// that are missing from `dockerIDs` no longer exist and are written as stopped
+func (engine *DockerTaskEngine) reconcileTaskStateWithDocker(task *apitask.Task, dockerIDs map[string]struct{}) {
+	taskContainers, ok := engine.state.ContainerMapByArn(task.Arn)
+	if !ok {
+		return
+	}
+
+	var changes []dockerContainerChange
+	for _, container := range task.Containers {
+		dockerContainer, ok := taskContainers[container.Name]
+		if !ok || dockerContainer.DockerID == "" || container.KnownTerminal() {
+			continue
+		}
+
+		var event dockerapi.DockerContainerChangeEvent
+		if _, ok := dockerIDs[dockerContainer.DockerID]; !ok {
+			seelog.Warnf("Task engine [%s]: container [%s] not found after docker daemon restart",
+				task.Arn, container.Name)
+			event = dockerapi.DockerContainerChangeEvent{
+				Status: apicontainerstatus.ContainerStopped,
+				DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+					DockerID: dockerContainer.DockerID,
+					Error:    &ContainerMissingAfterDaemonRestartError{},
+				},
+			}
+		} else {
+			status, metadata := engine.client.DescribeContainer(engine.ctx, dockerContainer.DockerID)
+			if metadata.Error != nil && metadata.Error.ErrorName() == dockerapi.CannotDescribeContainerErrorName {
+				seelog.Warnf("Task engine [%s]: unable to describe container [%s] to reconcile its state: %v",
+					task.Arn, container.Name, metadata.Error)
+				continue
+			}
+			if status == container.GetKnownStatus() {
+				continue
+			}
+			event = dockerapi.DockerContainerChangeEvent{
+				Status:                  status,
+				DockerContainerMetadata: metadata,
+			}
+		}
+		changes = append(changes, dockerContainerChange{container: container, event: event})
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	engine.tasksLock.RLock()
+	// hold the lock until the changes are sent so we don't send on a closed channel
+	defer engine.tasksLock.RUnlock()
+	managedTask, ok := engine.managedTasks[task.Arn]
+	if !ok {
+		return
+	}
+	for _, change := range changes {
+		seelog.Infof("Task engine [%s]: reconciled state of container [%s] with docker: %s",
+			task.Arn, change.container.Name, change.event.String())
+		managedTask.emitDockerContainerChange(change)
+	}
+}
+
 // sweepTask deletes all the containers associated with a task
 func (engine *DockerTaskEngine) sweepTask(task *apitask.Task) {
 	for _, cont := range task.Containers {
@@ -461,6 +738,10 @@ func (engine *DockerTaskEngine) sweepTask(task *apitask.Task) {
 				task.Arn, cont.Name, err)
 		}
 	}
+	// The task's GPUs and host ports can be assigned to other tasks once its
+	// containers are removed
+	engine.gpuManager.Release(task.Arn)
+	engine.hostPortAllocator.Release(task.Arn)
 
 	// Clean metadata directory for task
 	if engine.cfg.ContainerMetadataEnabled {
@@ -511,6 +792,20 @@ func (engine *DockerTaskEngine) emitTaskEvent(task *apitask.Task, reason string)
 	engine.stateChangeEvents <- event
 }
 
+// emitContainerHealthEvent emits a container state change event for a
+// transition of the container's health status
+func (engine *DockerTaskEngine) emitContainerHealthEvent(task *apitask.Task, cont *apicontainer.Container) {
+	event, err := api.NewContainerHealthChangeEvent(task, cont)
+	if err != nil {
+		seelog.Debugf("Task engine [%s]: unable to create health change event for container [%s]: %v",
+			task.Arn, cont.Name, err)
+		return
+	}
+
+	seelog.Infof("Task engine [%s]: sending container health change event [%s]", task.Arn, event.String())
+	engine.stateChangeEvents <- event
+}
+
 // startTask creates a managedTask construct to track the task and then begins
 // pushing it towards its desired state when allowed startTask is protected by
 // the tasksLock lock of 'AddTask'. It should not be called from anywhere
@@ -566,6 +861,11 @@ func (engine *DockerTaskEngine) handleDockerEvents(ctx context.Context) {
 func (engine *DockerTaskEngine) handleDockerEvent(event dockerapi.DockerContainerChangeEvent) {
 	seelog.Debugf("Task engine: handling a docker event: %s", event.String())
 
+	if event.Type == apicontainer.EventStreamReconnectedEvent {
+		go engine.reconcileStateWithDocker()
+		return
+	}
+
 	task, ok := engine.state.TaskByID(event.DockerID)
 	if !ok {
 		seelog.Debugf("Task engine: event for container [%s] not managed, unable to map container id to task",
@@ -585,7 +885,13 @@ func (engine *DockerTaskEngine) handleDockerEvent(event dockerapi.DockerContaine
 		if cont.Container.HealthStatusShouldBeReported() {
 			seelog.Debugf("Task engine: updating container [%s(%s)] health status: %v",
 				cont.Container.Name, cont.DockerID, event.DockerContainerMetadata.Health)
+			previousHealthStatus := cont.Container.GetHealthStatus().Status
 			cont.Container.SetHealthStatus(event.DockerContainerMetadata.Health)
+			// Only report transitions of the health status, rather than
+			// the result of every health check run
+			if cont.Container.GetHealthStatus().Status != previousHealthStatus {
+				engine.emitContainerHealthEvent(task, cont.Container)
+			}
 		}
 		return
 	}
@@ -634,14 +940,15 @@ func (engine *DockerTaskEngine) AddTask(task *apitask.Task) {
 		task.UpdateDesiredStatus()
 
 		engine.state.AddTask(task)
-		if dependencygraph.ValidDependencies(task) {
+		if err := dependencygraph.ValidateContainerOrdering(task); err != nil {
+			seelog.Errorf("Task engine [%s]: unable to progress task with invalid container ordering: %v",
+				task.Arn, err)
+			engine.stopTaskWithDependencyError(task, TaskDependencyError{taskArn: task.Arn, reason: err.Error()})
+		} else if dependencygraph.ValidDependencies(task) {
 			engine.startTask(task)
 		} else {
 			seelog.Errorf("Task engine [%s]: unable to progress task with circular dependencies", task.Arn)
-			task.SetKnownStatus(apitaskstatus.TaskStopped)
-			task.SetDesiredStatus(apitaskstatus.TaskStopped)
-			err := TaskDependencyError{task.Arn}
-			engine.emitTaskEvent(task, err.Error())
+			engine.stopTaskWithDependencyError(task, TaskDependencyError{taskArn: task.Arn})
 		}
 		return
 	}
@@ -650,6 +957,14 @@ func (engine *DockerTaskEngine) AddTask(task *apitask.Task) {
 	engine.updateTaskUnsafe(existingTask, task)
 }
 
+// stopTaskWithDependencyError moves a task whose dependencies can't be
+// resolved to stopped without starting it
+func (engine *DockerTaskEngine) stopTaskWithDependencyError(task *apitask.Task, err TaskDependencyError) {
+	task.SetKnownStatus(apitaskstatus.TaskStopped)
+	task.SetDesiredStatus(apitaskstatus.TaskStopped)
+	engine.emitTaskEvent(task, err.Error())
+}
+
 // ListTasks returns the tasks currently managed by the DockerTaskEngine
 func (engine *DockerTaskEngine) ListTasks() ([]*apitask.Task, error) {
 	return engine.state.AllTasks(), nil
@@ -667,11 +982,16 @@ func (engine *DockerTaskEngine) pullContainer(task *apitask.Task, container *api
 		return dockerapi.DockerContainerMetadata{}
 	}
 
-	if engine.imagePullRequired(engine.cfg.ImagePullBehavior, container, task.Arn) {
+	if engine.imagePullRequired(imagePullBehavior(engine.cfg, container), container, task.Arn) {
+		container.SetImageFromCache(false)
 		// Record the pullStoppedAt timestamp
 		defer func() {
 			timestamp := engine.time().Now()
 			task.SetPullStoppedAt(timestamp)
+			container.SetPullStoppedAt(timestamp)
+			if duration, ok := container.GetPullDuration(); ok {
+				metrics.RecordImagePull(duration)
+			}
 		}()
 
 		seelog.Infof("Task engine [%s]: pulling container %s concurrently", task.Arn, container.Name)
@@ -680,13 +1000,34 @@ func (engine *DockerTaskEngine) pullContainer(task *apitask.Task, container *api
 	}
 
 	// No pull image is required, just update container reference and use cached image.
+	container.SetImageFromCache(true)
+	// The cached image is reported as a pull that took no time
+	timestamp := engine.time().Now()
+	container.SetPullStartedAt(timestamp)
+	container.SetPullStoppedAt(timestamp)
 	engine.updateContainerReference(false, container, task.Arn)
 	// Return the metadata without any error
 	return dockerapi.DockerContainerMetadata{Error: nil}
 }
 
+// imagePullBehavior returns the behavior for pulling the container's image. The
+// image pull behavior of the container overrides the agent's image pull
+// behavior when it's valid
+func imagePullBehavior(cfg *config.Config, container *apicontainer.Container) config.ImagePullBehaviorType {
+	if container.ImagePullBehavior == "" {
+		return cfg.ImagePullBehavior
+	}
+	behavior, ok := config.ParseImagePullBehavior(container.ImagePullBehavior)
+	if !ok {
+		seelog.Warnf("Invalid image pull behavior %s for container %s, using the agent's image pull behavior",
+			container.ImagePullBehavior, container.Name)
+		return cfg.ImagePullBehavior
+	}
+	return behavior
+}
+
 // imagePullRequired returns true if pulling image is required, or return false if local image cache
-// should be used, by inspecting the agent pull behavior variable defined in config. The caller has
+// should be used, by inspecting the image pull behavior of the container. The caller has
 // to make sure the container passed in is not an internal container.
 func (engine *DockerTaskEngine) imagePullRequired(imagePullBehavior config.ImagePullBehaviorType,
 	container *apicontainer.Container,
@@ -731,6 +1072,7 @@ func (engine *DockerTaskEngine) concurrentPull(task *apitask.Task, container *ap
 
 	// Record the task pull_started_at timestamp
 	pullStart := engine.time().Now()
+	container.SetPullStartedAt(pullStart)
 	ok := task.SetPullStartedAt(pullStart)
 	if ok {
 		seelog.Infof("Task engine [%s]: Recording timestamp for starting image pulltime: %s",
@@ -790,7 +1132,7 @@ func (engine *DockerTaskEngine) pullAndUpdateContainerReference(task *apitask.Ta
 		defer container.SetASMDockerAuthConfig(docker.AuthConfiguration{})
 	}
 
-	metadata := engine.client.PullImage(container.Image, container.RegistryAuthentication)
+	metadata := engine.pullImage(task.Arn, container)
 
 	// Don't add internal images(created by ecs-agent) into imagemanger state
 	if container.IsInternal() {
@@ -801,6 +1143,34 @@ func (engine *DockerTaskEngine) pullAndUpdateContainerReference(task *apitask.Ta
 	return metadata
 }
 
+// pullImage pulls the container's image once the image pull semaphore is
+// acquired. If the same image is already being pulled, it waits for that pull
+// to finish and returns its result instead of pulling the image again
+func (engine *DockerTaskEngine) pullImage(taskArn string, container *apicontainer.Container) dockerapi.DockerContainerMetadata {
+	engine.imagePullsLock.Lock()
+	if pull, ok := engine.imagePulls[container.Image]; ok {
+		pull.waiters++
+		engine.imagePullsLock.Unlock()
+		seelog.Infof("Task engine [%s]: image %s is already being pulled, waiting for it for container %s",
+			taskArn, container.Image, container.Name)
+		<-pull.done
+		return pull.metadata
+	}
+	pull := &imagePull{done: make(chan struct{})}
+	engine.imagePulls[container.Image] = pull
+	engine.imagePullsLock.Unlock()
+
+	engine.imagePullSemaphore <- struct{}{}
+	pull.metadata = engine.client.PullImage(container.Image, container.RegistryAuthentication)
+	<-engine.imagePullSemaphore
+
+	engine.imagePullsLock.Lock()
+	delete(engine.imagePulls, container.Image)
+	engine.imagePullsLock.Unlock()
+	close(pull.done)
+	return pull.metadata
+}
+
 func (engine *DockerTaskEngine) updateContainerReference(pullSucceeded bool, container *apicontainer.Container, taskArn string) {
 	err := engine.imageManager.RecordContainerReference(container)
 	if err != nil {
@@ -815,8 +1185,281 @@ func (engine *DockerTaskEngine) updateContainerReference(pullSucceeded bool, con
 	engine.saver.Save()
 }
 
+// verifyContainerDevices verifies that the host devices exposed to the container
+// exist on the instance, so that the container fails with a clear reason
+// instead of docker's error when one of them is missing
+func verifyContainerDevices(container *apicontainer.Container) apierrors.NamedError {
+	for _, device := range container.Devices {
+		if _, err := os.Stat(device.HostPath); err != nil {
+			return ContainerDeviceError{
+				containerName: container.Name,
+				hostPath:      device.HostPath,
+				err:           err,
+			}
+		}
+	}
+	return nil
+}
+
+// assignContainerGPUs assigns the GPUs reserved for the container, unless they
+// were assigned by an earlier attempt to create the container
+func (engine *DockerTaskEngine) assignContainerGPUs(task *apitask.Task, container *apicontainer.Container) apierrors.NamedError {
+	if container.GPUCount == 0 || len(container.GetGPUIDs()) != 0 {
+		return nil
+	}
+	gpuIDs, err := engine.gpuManager.Assign(task.Arn, container.GPUCount)
+	if err != nil {
+		return ContainerGPUAssignmentError{
+			containerName: container.Name,
+			err:           err,
+		}
+	}
+	seelog.Infof("Task engine [%s]: assigned gpus %v to container %s", task.Arn, gpuIDs, container.Name)
+	container.SetGPUIDs(gpuIDs)
+	return nil
+}
+
+// applyEphemeralStorageLimit limits the size of the writable layer of the
+// container to the ephemeral storage limit of its task, when the storage
+// driver supports it. The limit is otherwise only enforced by stopping the
+// task when its containers use more than it, see checkEphemeralStorage
+func (engine *DockerTaskEngine) applyEphemeralStorageLimit(task *apitask.Task, hostConfig *docker.HostConfig) {
+	if task.EphemeralStorageLimit <= 0 {
+		return
+	}
+	driver, err := engine.client.StorageDriver(engine.ctx, dockerclient.InfoTimeout)
+	if err != nil {
+		seelog.Warnf("Task engine [%s]: unable to determine the docker storage driver to limit ephemeral storage: %v",
+			task.Arn, err)
+		return
+	}
+	if _, ok := quotaStorageDrivers[driver]; !ok {
+		seelog.Debugf("Task engine [%s]: storage driver %s doesn't support limiting container size", task.Arn, driver)
+		return
+	}
+	if hostConfig.StorageOpt == nil {
+		hostConfig.StorageOpt = make(map[string]string)
+	}
+	hostConfig.StorageOpt["size"] = strconv.FormatInt(task.EphemeralStorageLimit, 10) + "M"
+}
+
+// monitorEphemeralStorage periodically measures the disk space used by the
+// containers of the tasks with an ephemeral storage limit
+func (engine *DockerTaskEngine) monitorEphemeralStorage(ctx context.Context) {
+	ticker := time.NewTicker(ephemeralStorageCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			engine.checkEphemeralStorage(ctx)
+		}
+	}
+}
+
+// checkEphemeralStorage records the disk space used by the writable layers of
+// the containers of each task with an ephemeral storage limit, and stops the
+// tasks whose containers use more than their limit
+func (engine *DockerTaskEngine) checkEphemeralStorage(ctx context.Context) {
+	var tasks []*apitask.Task
+	for _, task := range engine.state.AllTasks() {
+		if task.EphemeralStorageLimit > 0 && task.GetDesiredStatus() != apitaskstatus.TaskStopped {
+			tasks = append(tasks, task)
+		}
+	}
+	if len(tasks) == 0 {
+		return
+	}
+
+	sizes, err := engine.client.ContainerSizes(ctx, dockerclient.ContainerSizesTimeout)
+	if err != nil {
+		seelog.Warnf("Task engine: unable to measure the ephemeral storage used by tasks: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		containerMap, ok := engine.state.ContainerMapByArn(task.Arn)
+		if !ok {
+			continue
+		}
+		var usage int64
+		for _, dockerContainer := range containerMap {
+			usage += sizes[dockerContainer.DockerID]
+		}
+		task.SetEphemeralStorageUsage(usage)
+		if usage > task.EphemeralStorageLimit*bytesPerMiB {
+			engine.stopTaskExceedingEphemeralStorage(task, usage)
+		}
+	}
+}
+
+// allocateContainerHostPorts allocates host ports from the dynamic host port
+// range to the port mappings of the container without a host port, unless
+// they were allocated by an earlier attempt to create the container. Docker
+// picks the host ports if no range is configured. The containers of tasks
+// using the awsvpc network mode share the network namespace of the pause
+// container and don't publish ports
+func (engine *DockerTaskEngine) allocateContainerHostPorts(task *apitask.Task, container *apicontainer.Container) apierrors.NamedError {
+	count := container.DynamicHostPortCount()
+	if count == 0 || len(container.GetAllocatedHostPorts()) != 0 ||
+		!engine.hostPortAllocator.Enabled() || task.GetTaskENI() != nil {
+		return nil
+	}
+	ports, err := engine.hostPortAllocator.Allocate(task.Arn, count)
+	if err != nil {
+		return ContainerHostPortAllocationError{
+			containerName: container.Name,
+			err:           err,
+		}
+	}
+	seelog.Infof("Task engine [%s]: allocated host ports %v to container %s", task.Arn, ports, container.Name)
+	container.SetAllocatedHostPorts(ports)
+	return nil
+}
+
+// claimContainerHostPorts claims the static host ports of the container, and
+// the container ports of containers using the host network mode, which fails
+// the container right away if they're used by another task or bound by a
+// process of the host, rather than once docker fails to start it
+func (engine *DockerTaskEngine) claimContainerHostPorts(task *apitask.Task, container *apicontainer.Container,
+	networkMode string) apierrors.NamedError {
+	bindings := staticHostPortBindings(container, networkMode)
+	if len(bindings) == 0 {
+		return nil
+	}
+	ports := make([]uint16, 0, len(bindings))
+	for _, binding := range bindings {
+		ports = append(ports, binding.HostPort)
+	}
+	if err := engine.hostPortAllocator.Claim(task.Arn, ports); err != nil {
+		claimedErr, ok := err.(hostport.ClaimedError)
+		if !ok {
+			return ContainerHostPortAllocationError{containerName: container.Name, err: err}
+		}
+		return HostPortConflictError{port: claimedErr.Port, owner: "task " + claimedErr.TaskArn}
+	}
+	for _, binding := range bindings {
+		if engine.hostPortProber.InUse(binding.BindIP, binding.HostPort, binding.Protocol.String()) {
+			return HostPortConflictError{port: binding.HostPort, owner: "host process"}
+		}
+	}
+	return nil
+}
+
+// staticHostPortBindings returns the port bindings of the container with a
+// host port set in the task definition. The host ports of containers using
+// the host network mode are their container ports
+func staticHostPortBindings(container *apicontainer.Container, networkMode string) []apicontainer.PortBinding {
+	var bindings []apicontainer.PortBinding
+	for _, binding := range container.Ports {
+		if networkMode == hostNetworkMode {
+			binding.HostPort = binding.ContainerPort
+			binding.BindIP = ""
+		}
+		if binding.HostPort != 0 {
+			bindings = append(bindings, binding)
+		}
+	}
+	return bindings
+}
+
+// stopTaskExceedingEphemeralStorage stops a task whose containers use more disk
+// space than its ephemeral storage limit
+func (engine *DockerTaskEngine) stopTaskExceedingEphemeralStorage(task *apitask.Task, usage int64) {
+	engine.tasksLock.RLock()
+	managedTask, ok := engine.managedTasks[task.Arn]
+	engine.tasksLock.RUnlock()
+	if !ok {
+		return
+	}
+	seelog.Warnf("Task engine [%s]: stopping task using %d bytes of ephemeral storage, over its limit of %d MiB",
+		task.Arn, usage, task.EphemeralStorageLimit)
+	task.SetTerminalReasonCode(apireason.ReasonCodeEphemeralStorageExceeded)
+	task.SetTerminalReason(ephemeralStorageExceededReason)
+	go managedTask.emitACSTransition(acsTransition{desiredStatus: apitaskstatus.TaskStopped})
+}
+
+// StopTasks stops the managed tasks identified by the arns, which aren't
+// already stopping, recording the cause and reason of their stop
+func (engine *DockerTaskEngine) StopTasks(taskARNs []string, reasonCode apireason.ReasonCode, reason string) {
+	engine.tasksLock.RLock()
+	defer engine.tasksLock.RUnlock()
+
+	for _, taskARN := range taskARNs {
+		managedTask, ok := engine.managedTasks[taskARN]
+		if !ok {
+			seelog.Warnf("Task engine [%s]: unable to stop unmanaged task", taskARN)
+			continue
+		}
+		if managedTask.GetDesiredStatus().Terminal() {
+			continue
+		}
+		seelog.Infof("Task engine [%s]: stopping task: %s", taskARN, reason)
+		managedTask.SetTerminalReasonCode(reasonCode)
+		managedTask.SetTerminalReason(reason)
+		go managedTask.emitACSTransition(acsTransition{desiredStatus: apitaskstatus.TaskStopped})
+	}
+}
+
+// reserveTaskHostPorts restores the allocation of the host ports allocated to
+// the task's containers before the agent restarted, and the claims of the
+// static host ports of the task unless it stopped
+func (engine *DockerTaskEngine) reserveTaskHostPorts(task *apitask.Task) {
+	for _, container := range task.Containers {
+		if ports := container.GetAllocatedHostPorts(); len(ports) != 0 {
+			engine.hostPortAllocator.Reserve(task.Arn, ports)
+		}
+		if task.GetKnownStatus().Terminal() || container.GetKnownStatus() < apicontainerstatus.ContainerCreated {
+			continue
+		}
+		var ports []uint16
+		for _, binding := range staticHostPortBindings(container, containerNetworkMode(container)) {
+			ports = append(ports, binding.HostPort)
+		}
+		if err := engine.hostPortAllocator.Claim(task.Arn, ports); err != nil {
+			seelog.Warnf("Task engine [%s]: unable to restore the host ports of container %s: %v",
+				task.Arn, container.Name, err)
+		}
+	}
+}
+
+// containerNetworkMode returns the network mode of the host config of the
+// container in its task definition
+func containerNetworkMode(container *apicontainer.Container) string {
+	if container.DockerConfig.HostConfig == nil {
+		return ""
+	}
+	hostConfig := &docker.HostConfig{}
+	if err := json.Unmarshal([]byte(*container.DockerConfig.HostConfig), hostConfig); err != nil {
+		return ""
+	}
+	return hostConfig.NetworkMode
+}
+
+// reserveTaskGPUs restores the assignment of the GPUs assigned to the task's
+// containers before the agent restarted
+func (engine *DockerTaskEngine) reserveTaskGPUs(task *apitask.Task) {
+	for _, container := range task.Containers {
+		if gpuIDs := container.GetGPUIDs(); len(gpuIDs) != 0 {
+			engine.gpuManager.Reserve(task.Arn, gpuIDs)
+		}
+	}
+}
+
 func (engine *DockerTaskEngine) createContainer(task *apitask.Task, container *apicontainer.Container) dockerapi.DockerContainerMetadata {
 	seelog.Infof("Task engine [%s]: creating container: %s", task.Arn, container.Name)
+	if err := verifyContainerDevices(container); err != nil {
+		seelog.Errorf("Task engine [%s]: unable to create container %s: %v", task.Arn, container.Name, err)
+		return dockerapi.DockerContainerMetadata{Error: err}
+	}
+	if err := engine.assignContainerGPUs(task, container); err != nil {
+		seelog.Errorf("Task engine [%s]: unable to create container %s: %v", task.Arn, container.Name, err)
+		return dockerapi.DockerContainerMetadata{Error: err}
+	}
+	if err := engine.allocateContainerHostPorts(task, container); err != nil {
+		seelog.Errorf("Task engine [%s]: unable to create container %s: %v", task.Arn, container.Name, err)
+		return dockerapi.DockerContainerMetadata{Error: err}
+	}
 	client := engine.client
 	if container.DockerConfig.Version != nil {
 		client = client.WithVersion(dockerclient.DockerVersion(*container.DockerConfig.Version))
@@ -850,6 +1493,13 @@ func (engine *DockerTaskEngine) createContainer(task *apitask.Task, container *a
 		return dockerapi.DockerContainerMetadata{Error: apierrors.NamedError(hcerr)}
 	}
 
+	if err := engine.claimContainerHostPorts(task, container, hostConfig.NetworkMode); err != nil {
+		seelog.Errorf("Task engine [%s]: unable to create container %s: %v", task.Arn, container.Name, err)
+		return dockerapi.DockerContainerMetadata{Error: err}
+	}
+
+	engine.applyEphemeralStorageLimit(task, hostConfig)
+
 	if container.AWSLogAuthExecutionRole() {
 		err := task.ApplyExecutionRoleLogsAuth(hostConfig, engine.credentialsManager)
 		if err != nil {
@@ -915,16 +1565,40 @@ func (engine *DockerTaskEngine) createContainer(task *apitask.Task, container *a
 	if metadata.DockerID != "" {
 		seelog.Infof("Task engine [%s]: created docker container for task: %s -> %s",
 			task.Arn, container.Name, metadata.DockerID)
+		container.SetRuntimeID(metadata.DockerID)
 		engine.state.AddContainer(&apicontainer.DockerContainer{DockerID: metadata.DockerID,
 			DockerName: dockerContainerName,
 			Container:  container}, task)
 	}
+	if metadata.Error == nil {
+		metadata.Error = engine.connectTaskNetwork(task, container, client, metadata.DockerID)
+	}
 	container.SetLabels(config.Labels)
 	seelog.Infof("Task engine [%s]: created docker container for task: %s -> %s, took %s",
 		task.Arn, container.Name, metadata.DockerID, time.Since(createContainerBegin))
 	return metadata
 }
 
+// connectTaskNetwork connects the container to the docker network of its task,
+// if the task has one, with the name of the container as its alias
+func (engine *DockerTaskEngine) connectTaskNetwork(task *apitask.Task,
+	container *apicontainer.Container,
+	client dockerapi.DockerClient,
+	dockerID string) apierrors.NamedError {
+	taskNetwork, ok := task.GetTaskNetworkForContainer(container)
+	if !ok {
+		return nil
+	}
+	err := client.ConnectNetwork(engine.ctx, taskNetwork.GetNetworkID(), dockerID,
+		[]string{container.Name}, dockerapi.ConnectNetworkTimeout)
+	if err != nil {
+		seelog.Errorf("Task engine [%s]: unable to connect container %s to network %s: %v",
+			task.Arn, container.Name, taskNetwork.GetNetworkName(), err)
+		return dockerapi.CannotCreateContainerError{FromError: err}
+	}
+	return nil
+}
+
 func (engine *DockerTaskEngine) startContainer(task *apitask.Task, container *apicontainer.Container) dockerapi.DockerContainerMetadata {
 	seelog.Infof("Task engine [%s]: starting container: %s", task.Arn, container.Name)
 	client := engine.client
@@ -950,32 +1624,107 @@ func (engine *DockerTaskEngine) startContainer(task *apitask.Task, container *ap
 		}
 	}
 	startContainerBegin := time.Now()
-	dockerContainerMD := client.StartContainer(engine.ctx, dockerContainer.DockerID, engine.cfg.ContainerStartTimeout)
-
-	// Get metadata through container inspection and available task information then write this to the metadata file
-	// Performs this in the background to avoid delaying container start
-	// TODO: Add a state to the apicontainer.Container for the status of the metadata file (Whether it needs update) and
-	// add logic to engine state restoration to do a metadata update for containers that are running after the agent was restarted
-	if dockerContainerMD.Error == nil &&
-		engine.cfg.ContainerMetadataEnabled &&
-		!container.IsInternal() {
-		go func() {
-			err := engine.metadataManager.Update(engine.ctx, dockerContainer.DockerID, task, container.Name)
-			if err != nil {
-				seelog.Warnf("Task engine [%s]: failed to update metadata file for container %s: %v",
-					task.Arn, container.Name, err)
-				return
-			}
-			container.SetMetadataFileUpdated()
-			seelog.Debugf("Task engine [%s]: updated metadata file for container %s",
-				task.Arn, container.Name)
-		}()
+	dockerID := dockerContainer.DockerID
+	dockerContainerMD := client.StartContainer(engine.ctx, dockerID, engine.cfg.ContainerStartTimeout)
+	for attempt := 1; attempt < maxHostPortAllocationAttempts && len(container.GetAllocatedHostPorts()) != 0 &&
+		isHostPortInUseError(dockerContainerMD.Error); attempt++ {
+		dockerID, dockerContainerMD = engine.startContainerWithNewHostPorts(task, container, client, dockerID)
 	}
+
 	seelog.Infof("Task engine [%s]: started docker container for task: %s -> %s, took %s",
 		task.Arn, container.Name, dockerContainerMD.DockerID, time.Since(startContainerBegin))
 	return dockerContainerMD
 }
 
+// waitForContainerExit waits for the container to exit through docker's wait
+// API and handles its exit like the docker event of the exit, so that fast
+// exits aren't missed or reported late. When the wait fails, e.g. as the docker
+// daemon restarted, the state of the container is inspected instead, and the
+// wait is resumed if the container is still running
+func (engine *DockerTaskEngine) waitForContainerExit(ctx context.Context, task *apitask.Task,
+	container *apicontainer.Container, dockerID string) {
+	backoff := utils.NewSimpleBackoff(containerWaitBackoffMin, containerWaitBackoffMax,
+		containerWaitBackoffJitter, containerWaitBackoffMultiple)
+	utils.RetryWithBackoffCtx(ctx, backoff, func() error {
+		waitMetadata := engine.client.WaitContainer(ctx, dockerID)
+		if ctx.Err() != nil {
+			return nil
+		}
+		status, metadata := engine.client.DescribeContainer(ctx, dockerID)
+		if waitMetadata.Error != nil {
+			if metadata.Error != nil || status != apicontainerstatus.ContainerStopped {
+				seelog.Warnf("Task engine [%s]: unable to wait for the exit of container [%s]: %v",
+					task.Arn, container.Name, waitMetadata.Error)
+				return waitMetadata.Error
+			}
+		} else {
+			if metadata.Error != nil {
+				// The exit code is enough to record the exit of the container
+				metadata = dockerapi.DockerContainerMetadata{}
+			}
+			metadata.ExitCode = waitMetadata.ExitCode
+		}
+		metadata.DockerID = dockerID
+		seelog.Infof("Task engine [%s]: container [%s] exited with exit code %s",
+			task.Arn, container.Name, exitCodeString(metadata.ExitCode))
+		engine.handleDockerEvent(dockerapi.DockerContainerChangeEvent{
+			Status:                  apicontainerstatus.ContainerStopped,
+			Type:                    apicontainer.ContainerStatusEvent,
+			DockerContainerMetadata: metadata,
+		})
+		return nil
+	})
+}
+
+func exitCodeString(exitCode *int) string {
+	if exitCode == nil {
+		return "unknown"
+	}
+	return strconv.Itoa(*exitCode)
+}
+
+// startContainerWithNewHostPorts replaces the container, which docker didn't
+// start as a host port allocated to it is already in use, with a container
+// using newly allocated host ports and starts it. It returns the docker id of
+// the new container
+func (engine *DockerTaskEngine) startContainerWithNewHostPorts(task *apitask.Task, container *apicontainer.Container,
+	client dockerapi.DockerClient, dockerID string) (string, dockerapi.DockerContainerMetadata) {
+	ports, err := engine.hostPortAllocator.Reallocate(task.Arn, container.GetAllocatedHostPorts())
+	if err != nil {
+		return "", dockerapi.DockerContainerMetadata{
+			Error: ContainerHostPortAllocationError{
+				containerName: container.Name,
+				err:           err,
+			},
+		}
+	}
+	seelog.Warnf("Task engine [%s]: host ports %v of container %s are in use, recreating it with host ports %v",
+		task.Arn, container.GetAllocatedHostPorts(), container.Name, ports)
+	container.SetAllocatedHostPorts(ports)
+	if err := client.RemoveContainer(engine.ctx, dockerID, dockerclient.RemoveContainerTimeout); err != nil {
+		return "", dockerapi.DockerContainerMetadata{
+			Error: dockerapi.CannotStartContainerError{
+				FromError: errors.Wrap(err, "unable to remove container to replace its host ports"),
+			},
+		}
+	}
+	metadata := engine.createContainer(task, container)
+	if metadata.Error != nil {
+		return "", metadata
+	}
+	return metadata.DockerID, client.StartContainer(engine.ctx, metadata.DockerID, engine.cfg.ContainerStartTimeout)
+}
+
+// isHostPortInUseError returns true if docker didn't start a container as one
+// of its host ports is already in use
+func isHostPortInUseError(err apierrors.NamedError) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(err.Error(), "port is already allocated") ||
+		strings.Contains(err.Error(), "address already in use")
+}
+
 func (engine *DockerTaskEngine) provisionContainerResources(task *apitask.Task, container *apicontainer.Container) dockerapi.DockerContainerMetadata {
 	seelog.Infof("Task engine [%s]: setting up container resources for container [%s]",
 		task.Arn, container.Name)
@@ -988,11 +1737,26 @@ func (engine *DockerTaskEngine) provisionContainerResources(task *apitask.Task,
 			},
 		}
 	}
-	// Invoke the libcni to config the network namespace for the container
-	result, err := engine.cniClient.SetupNS(engine.ctx, cniConfig, cniSetupTimeout)
+	// Invoke the libcni to config the network namespace for the container.
+	// Failed setups are cleaned up by the cni client, so transient failures
+	// of the plugins can be retried
+	var result *current.Result
+	backoff := utils.NewSimpleBackoff(cniSetupRetryBackoffMin, cniSetupRetryBackoffMax, 0.2, 2)
+	for attempt := 1; ; attempt++ {
+		result, err = engine.cniClient.SetupNS(engine.ctx, cniConfig, cniSetupTimeout)
+		if err == nil || attempt == cniSetupMaxAttempts || !ecscni.IsTransientError(err) {
+			break
+		}
+		seelog.Warnf("Task engine [%s]: transient failure configuring pause container namespace, retrying: %v",
+			task.Arn, err)
+		engine._time.Sleep(backoff.Duration())
+	}
 	if err != nil {
 		seelog.Errorf("Task engine [%s]: unable to configure pause container namespace: %v",
 			task.Arn, err)
+		// The error carries the stderr of the plugin that failed, which is
+		// why the task stops
+		task.SetTerminalReason("failed to setup network namespace: " + err.Error())
 		return dockerapi.DockerContainerMetadata{
 			DockerID: cniConfig.ContainerID,
 			Error: ContainerNetworkingError{errors.Wrap(err,
@@ -1058,7 +1822,7 @@ func (engine *DockerTaskEngine) buildCNIConfigFromTaskContainer(task *apitask.Ta
 
 	cfg.ContainerPID = strconv.Itoa(containerInspectOutput.State.Pid)
 	cfg.ContainerID = containerInspectOutput.ID
-	cfg.BlockInstanceMetdata = engine.cfg.AWSVPCBlockInstanceMetdata
+	cfg.BlockInstanceMetdata = task.ShouldBlockInstanceMetadata(engine.cfg.AWSVPCBlockInstanceMetdata)
 
 	return cfg, nil
 }
@@ -1090,9 +1854,14 @@ func (engine *DockerTaskEngine) stopContainer(task *apitask.Task, container *api
 		}
 		seelog.Infof("Task engine [%s]: cleaned pause container network namespace", task.Arn)
 	}
-	// timeout is defined by the const 'stopContainerTimeout' and the 'DockerStopTimeout' in the config
-	timeout := engine.cfg.DockerStopTimeout + dockerclient.StopContainerTimeout
-	return engine.client.StopContainer(engine.ctx, dockerContainer.DockerID, timeout)
+	// The stop timeout of the container overrides the 'DockerStopTimeout' in the config
+	stopTimeout := container.GetStopTimeout()
+	if stopTimeout <= 0 {
+		stopTimeout = engine.cfg.DockerStopTimeout
+	}
+	// Docker sends the container the stop signal recorded when creating it,
+	// and only kills the container if it hasn't exited once the timeout elapses
+	return engine.client.StopContainer(engine.ctx, dockerContainer.DockerID, stopTimeout)
 }
 
 func (engine *DockerTaskEngine) removeContainer(task *apitask.Task, container *apicontainer.Container) error {
@@ -1131,6 +1900,7 @@ func (engine *DockerTaskEngine) updateTaskUnsafe(task *apitask.Task, update *api
 	managedTask.emitACSTransition(acsTransition{
 		desiredStatus: updateDesiredStatus,
 		seqnum:        update.StopSequenceNumber,
+		stoppedReason: update.GetStoppedReason(),
 	})
 	seelog.Debugf("Task engine [%s]: update taken off the acs channel: [%s] with seqnum [%d]",
 		task.Arn, updateDesiredStatus.String(), update.StopSequenceNumber)
@@ -1195,19 +1965,121 @@ func (engine *DockerTaskEngine) State() dockerstate.TaskEngineState {
 	return engine.state
 }
 
+// AuthConfigFileLoadedAt returns when the credentials of registries were last
+// loaded from the configured docker config file
+func (engine *DockerTaskEngine) AuthConfigFileLoadedAt() time.Time {
+	return engine.client.AuthConfigFileLoadedAt()
+}
+
 // Version returns the underlying docker version.
 func (engine *DockerTaskEngine) Version() (string, error) {
 	return engine.client.Version(engine.ctx, dockerclient.VersionTimeout)
 }
 
-func (engine *DockerTaskEngine) updateMetadataFile(task *apitask.Task, cont *apicontainer.DockerContainer) {
-	err := engine.metadataManager.Update(engine.ctx, cont.DockerID, task, cont.Container.Name)
+// ExecRequest describes a command to run in a container of a task
+type ExecRequest struct {
+	TaskARN       string
+	ContainerName string
+	Command       []string
+	// Requester identifies who asked for the command to be run, for the audit
+	// record of the invocation
+	Requester string
+}
+
+// ExecCommand runs the command of the request in the running container it
+// names, streaming the input of the command from the reader provided and its
+// output to the writers provided, and returns the exit code of the command.
+// Every invocation is audited in the agent log, whether or not the command
+// could be run
+func (engine *DockerTaskEngine) ExecCommand(ctx context.Context, request ExecRequest,
+	stdin io.Reader, stdout io.Writer, stderr io.Writer) (int, error) {
+	seelog.Infof("Task engine [%s]: exec audit: %s requested running %q in container [%s]",
+		request.TaskARN, request.Requester, request.Command, request.ContainerName)
+
+	dockerID, err := engine.execContainerDockerID(request)
+	if err != nil {
+		seelog.Warnf("Task engine [%s]: exec audit: refused running %q in container [%s] for %s: %v",
+			request.TaskARN, request.Command, request.ContainerName, request.Requester, err)
+		return 0, err
+	}
+	exitCode, err := engine.client.ExecCommand(ctx, dockerID, request.Command, stdin, stdout, stderr,
+		dockerclient.ExecCommandTimeout)
+	if err != nil {
+		seelog.Warnf("Task engine [%s]: exec audit: failed running %q in container [%s] (%s) for %s: %v",
+			request.TaskARN, request.Command, request.ContainerName, dockerID, request.Requester, err)
+		return 0, err
+	}
+	seelog.Infof("Task engine [%s]: exec audit: ran %q in container [%s] (%s) for %s, exit code: %d",
+		request.TaskARN, request.Command, request.ContainerName, dockerID, request.Requester, exitCode)
+	return exitCode, nil
+}
+
+// execContainerDockerID resolves the container named by the exec request to
+// its docker id. Commands can only be run in running containers
+func (engine *DockerTaskEngine) execContainerDockerID(request ExecRequest) (string, error) {
+	containerMap, ok := engine.state.ContainerMapByArn(request.TaskARN)
+	if !ok {
+		return "", &ExecContainerNotFoundError{request.TaskARN, request.ContainerName}
+	}
+	dockerContainer, ok := containerMap[request.ContainerName]
+	if !ok || dockerContainer.Container.IsInternal() {
+		return "", &ExecContainerNotFoundError{request.TaskARN, request.ContainerName}
+	}
+	knownStatus := dockerContainer.Container.GetKnownStatus()
+	if knownStatus != apicontainerstatus.ContainerRunning || dockerContainer.DockerID == "" {
+		return "", &ExecContainerNotRunningError{request.ContainerName, knownStatus}
+	}
+	return dockerContainer.DockerID, nil
+}
+
+// queueMetadataFileUpdate rewrites the metadata file of the container in the
+// background. The rewrites of the file of a container are done one at a time,
+// and the ones requested while a rewrite is in progress are coalesced into a
+// single rewrite with the latest state of the container
+func (engine *DockerTaskEngine) queueMetadataFileUpdate(task *apitask.Task, container *apicontainer.Container) {
+	engine.metadataFileUpdatesLock.Lock()
+	defer engine.metadataFileUpdatesLock.Unlock()
+	if _, ok := engine.metadataFileUpdates[container]; ok {
+		engine.metadataFileUpdates[container] = true
+		return
+	}
+	engine.metadataFileUpdates[container] = false
+
+	go func() {
+		for {
+			engine.updateMetadataFile(task, container)
+
+			engine.metadataFileUpdatesLock.Lock()
+			if !engine.metadataFileUpdates[container] {
+				delete(engine.metadataFileUpdates, container)
+				engine.metadataFileUpdatesLock.Unlock()
+				return
+			}
+			engine.metadataFileUpdates[container] = false
+			engine.metadataFileUpdatesLock.Unlock()
+		}
+	}()
+}
+
+// updateMetadataFile rewrites the metadata file of the container with its
+// current state. The container is inspected once it's created, and the file is
+// known to be updated once the container is running
+func (engine *DockerTaskEngine) updateMetadataFile(task *apitask.Task, container *apicontainer.Container) {
+	var dockerID string
+	if containerMap, ok := engine.state.ContainerMapByArn(task.Arn); ok {
+		if dockerContainer, ok := containerMap[container.Name]; ok {
+			dockerID = dockerContainer.DockerID
+		}
+	}
+	err := engine.metadataManager.Update(engine.ctx, dockerID, task, container)
 	if err != nil {
 		seelog.Errorf("Task engine [%s]: failed to update metadata file for container %s: %v",
-			task.Arn, cont.Container.Name, err)
-	} else {
-		cont.Container.SetMetadataFileUpdated()
-		seelog.Debugf("Task engine [%s]: updated metadata file for container %s",
-			task.Arn, cont.Container.Name)
+			task.Arn, container.Name, err)
+		return
 	}
+	if container.GetKnownStatus() >= apicontainerstatus.ContainerRunning {
+		container.SetMetadataFileUpdated()
+	}
+	seelog.Debugf("Task engine [%s]: updated metadata file for container %s",
+		task.Arn, container.Name)
 }
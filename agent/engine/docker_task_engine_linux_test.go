@@ -40,6 +40,7 @@ import (
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper/mocks"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/containerd/cgroups"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -63,6 +64,7 @@ func TestResourceContainerProgression(t *testing.T) {
 	defer cancel()
 	ctrl, client, mockTime, taskEngine, _, imageManager, _ := mocks(t, ctx, &defaultConfig)
 	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(client)
 
 	sleepTask := testdata.LoadTask("sleep5")
 	sleepContainer := sleepTask.Containers[0]
@@ -88,6 +90,7 @@ func TestResourceContainerProgression(t *testing.T) {
 	gomock.InOrder(
 		// Ensure that the resource is created first
 		mockControl.EXPECT().Exists(gomock.Any()).Return(false),
+		mockControl.EXPECT().SubsystemMounted(cgroups.Memory).Return(true),
 		mockControl.EXPECT().Create(gomock.Any()).Return(nil, nil),
 		mockIO.EXPECT().WriteFile(cgroupMemoryPath, gomock.Any(), gomock.Any()).Return(nil),
 		imageManager.EXPECT().AddAllImageStates(gomock.Any()).AnyTimes(),
@@ -191,6 +194,7 @@ func TestResourceContainerProgressionFailure(t *testing.T) {
 	gomock.InOrder(
 		// resource creation failure
 		mockControl.EXPECT().Exists(gomock.Any()).Return(false),
+		mockControl.EXPECT().SubsystemMounted(cgroups.Memory).Return(true),
 		mockControl.EXPECT().Create(gomock.Any()).Return(nil, errors.New("cgroup create error")),
 	)
 	mockTime.EXPECT().Now().Return(time.Now()).AnyTimes()
@@ -231,6 +235,7 @@ func TestTaskCPULimitHappyPath(t *testing.T) {
 			ctrl, client, mockTime, taskEngine, credentialsManager, imageManager, metadataManager := mocks(
 				t, ctx, &metadataConfig)
 			defer ctrl.Finish()
+			expectContainerWaitUntilTaskEnds(client)
 
 			roleCredentials := credentials.TaskIAMRoleCredentials{
 				IAMRoleCredentials: credentials.IAMRoleCredentials{CredentialsID: "credsid"},
@@ -274,6 +279,7 @@ func TestTaskCPULimitHappyPath(t *testing.T) {
 					},
 				}
 				mockControl.EXPECT().Exists(gomock.Any()).Return(false)
+				mockControl.EXPECT().SubsystemMounted(cgroups.Memory).Return(true)
 				mockControl.EXPECT().Create(gomock.Any()).Return(nil, nil)
 				mockIO.EXPECT().WriteFile(cgroupMemoryPath, gomock.Any(), gomock.Any()).Return(nil)
 			}
@@ -285,7 +291,7 @@ func TestTaskCPULimitHappyPath(t *testing.T) {
 						metadataManager.EXPECT().Create(gomock.Any(), gomock.Any(),
 							gomock.Any(), gomock.Any()).Return(tc.metadataCreateError)
 						metadataManager.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any(),
-							gomock.Any()).Return(tc.metadataUpdateError)
+							gomock.Any()).Return(tc.metadataUpdateError).AnyTimes()
 					})
 			}
 
@@ -16,10 +16,14 @@
 package engine
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,6 +34,7 @@ import (
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/asm"
@@ -55,7 +60,9 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/asmauth"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/mocks"
+	taskresourcenetwork "github.com/aws/amazon-ecs-agent/agent/taskresource/network"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/ssmsecret"
+	taskresourcetypes "github.com/aws/amazon-ecs-agent/agent/taskresource/types"
 	taskresourcevolume "github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
 	"github.com/aws/aws-sdk-go/aws"
@@ -133,6 +140,8 @@ func mocks(t *testing.T, ctx context.Context, cfg *config.Config) (*gomock.Contr
 	containerChangeEventStream := eventstream.NewEventStream("TESTTASKENGINE", ctx)
 	containerChangeEventStream.StartListening()
 	imageManager := mock_engine.NewMockImageManager(ctrl)
+	// Stopped containers update the last used time of their images
+	imageManager.EXPECT().RecordContainerStopped(gomock.Any()).AnyTimes()
 	metadataManager := mock_containermetadata.NewMockManager(ctrl)
 
 	taskEngine := NewTaskEngine(cfg, client, credentialsManager, containerChangeEventStream,
@@ -216,7 +225,7 @@ func TestBatchContainerHappyPath(t *testing.T) {
 						metadataManager.EXPECT().Create(gomock.Any(), gomock.Any(),
 							gomock.Any(), gomock.Any()).Return(tc.metadataCreateError)
 						metadataManager.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any(),
-							gomock.Any()).Return(tc.metadataUpdateError)
+							gomock.Any()).Return(tc.metadataUpdateError).AnyTimes()
 					})
 			}
 
@@ -285,6 +294,7 @@ func TestTaskWithSteadyStateResourcesProvisioned(t *testing.T) {
 	defer cancel()
 	ctrl, client, mockTime, taskEngine, _, imageManager, _ := mocks(t, ctx, &defaultConfig)
 	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(client)
 
 	mockCNIClient := mock_ecscni.NewMockCNIClient(ctrl)
 	taskEngine.(*DockerTaskEngine).cniClient = mockCNIClient
@@ -774,6 +784,93 @@ func TestCreateContainerAddV3EndpointIDToState(t *testing.T) {
 	assert.Equal(t, "dockerID", addedDockerID)
 }
 
+// TestCreateContainerSetsRuntimeID tests that the docker id of the created
+// container is recorded as the container's runtime id
+func TestCreateContainerSetsRuntimeID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	testContainer := &apicontainer.Container{
+		Name: "c1",
+	}
+	testTask := &apitask.Task{
+		Arn:     "myTaskArn",
+		Family:  "myFamily",
+		Version: "1",
+		Containers: []*apicontainer.Container{
+			testContainer,
+		},
+	}
+
+	client.EXPECT().APIVersion().Return(defaultDockerClientAPIVersion, nil).AnyTimes()
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(dockerapi.DockerContainerMetadata{
+		DockerID: "dockerID",
+	})
+	taskEngine.createContainer(testTask, testContainer)
+
+	assert.Equal(t, "dockerID", testContainer.GetRuntimeID())
+}
+
+func TestCreateContainerConnectsTaskNetwork(t *testing.T) {
+	testCases := []struct {
+		name          string
+		connectError  error
+		expectedError bool
+	}{
+		{
+			name:          "connected",
+			connectError:  nil,
+			expectedError: false,
+		},
+		{
+			name:          "connect error",
+			connectError:  errors.New("error"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+			defer ctrl.Finish()
+
+			taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+			testContainer := &apicontainer.Container{
+				Name: "c1",
+			}
+			testTask := &apitask.Task{
+				Arn:                "myTaskArn",
+				Family:             "myFamily",
+				Version:            "1",
+				Containers:         []*apicontainer.Container{testContainer},
+				ResourcesMapUnsafe: make(map[string][]taskresource.TaskResource),
+			}
+			client.EXPECT().CreateNetwork(gomock.Any(), "ecs-task-id", gomock.Any(), gomock.Any()).Return(
+				dockerapi.NetworkResponse{DockerNetwork: &docker.Network{ID: "networkID"}})
+			networkResource := taskresourcenetwork.NewNetworkResource(ctx, testTask.Arn, "ecs-task-id", client)
+			require.NoError(t, networkResource.Create())
+			testTask.AddResource(taskresourcetypes.DockerNetworkKey, networkResource)
+
+			client.EXPECT().APIVersion().Return(defaultDockerClientAPIVersion, nil).AnyTimes()
+			client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+				dockerapi.DockerContainerMetadata{DockerID: "dockerID"})
+			client.EXPECT().ConnectNetwork(gomock.Any(), "networkID", "dockerID", []string{"c1"},
+				dockerapi.ConnectNetworkTimeout).Return(tc.connectError)
+			metadata := taskEngine.createContainer(testTask, testContainer)
+
+			assert.Equal(t, "dockerID", metadata.DockerID)
+			assert.Equal(t, tc.expectedError, metadata.Error != nil)
+		})
+	}
+}
+
 // TestTaskTransitionWhenStopContainerTimesout tests that task transitions to stopped
 // only when terminal events are received from docker event stream when
 // StopContainer times out
@@ -782,6 +879,7 @@ func TestTaskTransitionWhenStopContainerTimesout(t *testing.T) {
 	defer cancel()
 	ctrl, client, mockTime, taskEngine, _, imageManager, _ := mocks(t, ctx, &defaultConfig)
 	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(client)
 
 	sleepTask := testdata.LoadTask("sleep5")
 	eventStream := make(chan dockerapi.DockerContainerChangeEvent)
@@ -877,6 +975,7 @@ func TestTaskTransitionWhenStopContainerReturnsUnretriableError(t *testing.T) {
 	defer cancel()
 	ctrl, client, mockTime, taskEngine, _, imageManager, _ := mocks(t, ctx, &defaultConfig)
 	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(client)
 
 	sleepTask := testdata.LoadTask("sleep5")
 	eventStream := make(chan dockerapi.DockerContainerChangeEvent)
@@ -947,6 +1046,7 @@ func TestTaskTransitionWhenStopContainerReturnsTransientErrorBeforeSucceeding(t
 	defer cancel()
 	ctrl, client, mockTime, taskEngine, _, imageManager, _ := mocks(t, ctx, &defaultConfig)
 	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(client)
 
 	sleepTask := testdata.LoadTask("sleep5")
 	eventStream := make(chan dockerapi.DockerContainerChangeEvent)
@@ -1029,6 +1129,7 @@ func TestPauseContainerHappyPath(t *testing.T) {
 	defer cancel()
 	ctrl, dockerClient, mockTime, taskEngine, _, imageManager, _ := mocks(t, ctx, &defaultConfig)
 	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(dockerClient)
 
 	cniClient := mock_ecscni.NewMockCNIClient(ctrl)
 	taskEngine.(*DockerTaskEngine).cniClient = cniClient
@@ -1138,16 +1239,29 @@ func TestPauseContainerHappyPath(t *testing.T) {
 }
 
 func TestBuildCNIConfigFromTaskContainer(t *testing.T) {
-	for _, blockIMDS := range []bool{true, false} {
-		t.Run(fmt.Sprintf("When BlockInstanceMetadata is %t", blockIMDS), func(t *testing.T) {
+	testCases := []struct {
+		name              string
+		instanceBlockIMDS bool
+		taskBlockIMDS     *bool
+		blockIMDS         bool
+	}{
+		{"When BlockInstanceMetadata is true", true, nil, true},
+		{"When BlockInstanceMetadata is false", false, nil, false},
+		{"When the task blocks the instance metadata", false, aws.Bool(true), true},
+		{"When the task doesn't block the instance metadata", true, aws.Bool(false), false},
+	}
+	for _, tc := range testCases {
+		blockIMDS := tc.blockIMDS
+		t.Run(tc.name, func(t *testing.T) {
 			config := defaultConfig
-			config.AWSVPCBlockInstanceMetdata = blockIMDS
+			config.AWSVPCBlockInstanceMetdata = tc.instanceBlockIMDS
 			ctx, cancel := context.WithCancel(context.TODO())
 			defer cancel()
 			ctrl, dockerClient, _, taskEngine, _, _, _ := mocks(t, ctx, &config)
 			defer ctrl.Finish()
 
 			testTask := testdata.LoadTask("sleep5")
+			testTask.BlockInstanceMetadata = tc.taskBlockIMDS
 			testTask.SetTaskENI(&apieni.ENI{
 				ID: "TestBuildCNIConfigFromTaskContainer",
 				IPV4Addresses: []*apieni.ENIIPV4Address{
@@ -1211,6 +1325,100 @@ func TestBuildCNIConfigFromTaskContainerInspectError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+// transientCNIError is the error of a cni plugin that failed transiently
+type transientCNIError struct{}
+
+func (transientCNIError) Error() string   { return "ecs-bridge: ipam database locked" }
+func (transientCNIError) Transient() bool { return true }
+
+// newProvisioningTask returns a task whose pause container is known to the
+// engine, with the docker client expecting it to be inspected
+func newProvisioningTask(taskEngine TaskEngine, dockerClient *mock_dockerapi.MockDockerClient) (*apitask.Task, *apicontainer.Container) {
+	testTask := testdata.LoadTask("sleep5")
+	testTask.SetTaskENI(&apieni.ENI{
+		ID: "TestProvisionContainerResources",
+		IPV4Addresses: []*apieni.ENIIPV4Address{
+			{
+				Primary: true,
+				Address: ipv4,
+			},
+		},
+		MacAddress: mac,
+	})
+	pauseContainer := &apicontainer.Container{
+		Name: apitask.NetworkPauseContainerName,
+		Type: apicontainer.ContainerCNIPause,
+	}
+	taskEngine.(*DockerTaskEngine).state.AddContainer(&apicontainer.DockerContainer{
+		Container:  pauseContainer,
+		DockerName: dockerContainerName,
+	}, testTask)
+	dockerClient.EXPECT().InspectContainer(gomock.Any(), dockerContainerName, gomock.Any()).Return(&docker.Container{
+		ID:    containerID,
+		State: docker.State{Pid: containerPid},
+	}, nil)
+	return testTask, pauseContainer
+}
+
+func TestProvisionContainerResourcesRetriesTransientFailures(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, dockerClient, mockTime, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+	mockCNIClient := mock_ecscni.NewMockCNIClient(ctrl)
+	taskEngine.(*DockerTaskEngine).cniClient = mockCNIClient
+	testTask, pauseContainer := newProvisioningTask(taskEngine, dockerClient)
+
+	gomock.InOrder(
+		mockCNIClient.EXPECT().SetupNS(gomock.Any(), gomock.Any(), cniSetupTimeout).Return(nil, transientCNIError{}),
+		mockTime.EXPECT().Sleep(gomock.Any()),
+		mockCNIClient.EXPECT().SetupNS(gomock.Any(), gomock.Any(), cniSetupTimeout).Return(nsResult, nil),
+	)
+
+	metadata := taskEngine.(*DockerTaskEngine).provisionContainerResources(testTask, pauseContainer)
+	assert.NoError(t, metadata.Error)
+	assert.Equal(t, containerID, metadata.DockerID)
+	assert.Empty(t, testTask.GetTerminalReason())
+}
+
+func TestProvisionContainerResourcesGivesUpOnTransientFailures(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, dockerClient, mockTime, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+	mockCNIClient := mock_ecscni.NewMockCNIClient(ctrl)
+	taskEngine.(*DockerTaskEngine).cniClient = mockCNIClient
+	testTask, pauseContainer := newProvisioningTask(taskEngine, dockerClient)
+
+	mockCNIClient.EXPECT().SetupNS(gomock.Any(), gomock.Any(), cniSetupTimeout).Return(
+		nil, transientCNIError{}).Times(cniSetupMaxAttempts)
+	mockTime.EXPECT().Sleep(gomock.Any()).Times(cniSetupMaxAttempts - 1)
+
+	metadata := taskEngine.(*DockerTaskEngine).provisionContainerResources(testTask, pauseContainer)
+	require.Error(t, metadata.Error)
+	assert.Equal(t, "ContainerNetworkingError", metadata.Error.ErrorName())
+}
+
+func TestProvisionContainerResourcesTerminalFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, dockerClient, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+	mockCNIClient := mock_ecscni.NewMockCNIClient(ctrl)
+	taskEngine.(*DockerTaskEngine).cniClient = mockCNIClient
+	testTask, pauseContainer := newProvisioningTask(taskEngine, dockerClient)
+
+	// Terminal failures aren't retried
+	mockCNIClient.EXPECT().SetupNS(gomock.Any(), gomock.Any(), cniSetupTimeout).Return(
+		nil, errors.New("cni setup: invoke bridge plugin failed: ecs-bridge: invalid config; stderr: no ipam db"))
+
+	metadata := taskEngine.(*DockerTaskEngine).provisionContainerResources(testTask, pauseContainer)
+	require.Error(t, metadata.Error)
+	assert.Equal(t, containerID, metadata.DockerID)
+	// The stderr of the plugin makes it into the reason the task stopped
+	assert.Contains(t, testTask.GetTerminalReason(), "stderr: no ipam db")
+}
+
 // TestStopPauseContainerCleanupCalled tests when stopping the pause container
 // its network namespace should be cleaned up first
 func TestStopPauseContainerCleanupCalled(t *testing.T) {
@@ -1257,37 +1465,591 @@ func TestStopPauseContainerCleanupCalled(t *testing.T) {
 		mockCNIClient.EXPECT().CleanupNS(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil),
 		dockerClient.EXPECT().StopContainer(gomock.Any(),
 			containerID,
-			defaultConfig.DockerStopTimeout+dockerclient.StopContainerTimeout,
+			defaultConfig.DockerStopTimeout,
 		).Return(dockerapi.DockerContainerMetadata{}),
 	)
 
-	taskEngine.(*DockerTaskEngine).stopContainer(testTask, pauseContainer)
+	taskEngine.(*DockerTaskEngine).stopContainer(testTask, pauseContainer)
+}
+
+// TestStopContainerWithStopTimeout tests that the stop timeout of the container
+// is used as the docker stop grace period instead of the one in the config
+func TestStopContainerWithStopTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	testTask := testdata.LoadTask("sleep5")
+	container := testTask.Containers[0]
+	container.StopTimeout = 120
+	taskEngine.(*DockerTaskEngine).State().AddTask(testTask)
+	taskEngine.(*DockerTaskEngine).State().AddContainer(&apicontainer.DockerContainer{
+		DockerID:   containerID,
+		DockerName: "docker_container_name",
+		Container:  container,
+	}, testTask)
+
+	client.EXPECT().StopContainer(gomock.Any(), containerID, 2*time.Minute).Return(dockerapi.DockerContainerMetadata{})
+	taskEngine.(*DockerTaskEngine).stopContainer(testTask, container)
+}
+
+// TestTaskWithCircularDependency tests the task with containers of which the
+// dependencies can't be resolved
+func TestTaskWithCircularDependency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	client.EXPECT().ContainerEvents(gomock.Any())
+
+	task := testdata.LoadTask("circular_dependency")
+
+	err := taskEngine.Init(ctx)
+	assert.NoError(t, err)
+
+	events := taskEngine.StateChangeEvents()
+	go taskEngine.AddTask(task)
+	event := <-events
+	assert.Equal(t, event.(api.TaskStateChange).Status, apitaskstatus.TaskStopped, "Expected task to move to stopped directly")
+	_, ok := taskEngine.(*DockerTaskEngine).state.TaskByArn(task.Arn)
+	assert.True(t, ok, "Task state should be added to the agent state")
+
+	_, ok = taskEngine.(*DockerTaskEngine).managedTasks[task.Arn]
+	assert.False(t, ok, "Task should not be added to task manager for processing")
+}
+
+// TestTaskWithCircularContainerOrdering tests that a task with containers
+// depending on each other to start is stopped with the cycle as its reason
+func TestTaskWithCircularContainerOrdering(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	client.EXPECT().ContainerEvents(gomock.Any())
+
+	task := testdata.LoadTask("circular_container_ordering")
+
+	err := taskEngine.Init(ctx)
+	assert.NoError(t, err)
+
+	events := taskEngine.StateChangeEvents()
+	go taskEngine.AddTask(task)
+	event := <-events
+	taskEvent := event.(api.TaskStateChange)
+	assert.Equal(t, apitaskstatus.TaskStopped, taskEvent.Status, "Expected task to move to stopped directly")
+	assert.Contains(t, taskEvent.Reason, "circular container dependency: app -> init -> app")
+
+	_, ok := taskEngine.(*DockerTaskEngine).managedTasks[task.Arn]
+	assert.False(t, ok, "Task should not be added to task manager for processing")
+}
+
+// TestCreateContainerWithMissingDevice tests that a container exposing a host
+// device that doesn't exist fails without calling docker
+func TestCreateContainerWithMissingDevice(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	dir, err := ioutil.TempDir("", "devices")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	devicePath := filepath.Join(dir, "missing")
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.Devices = []apicontainer.Device{{HostPath: devicePath}}
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	require.Error(t, metadata.Error)
+	assert.Equal(t, "ContainerDeviceError", metadata.Error.ErrorName())
+	assert.Equal(t, "host device "+devicePath+" required by container sleep5 does not exist on the instance",
+		metadata.Error.Error())
+}
+
+func TestVerifyContainerDevices(t *testing.T) {
+	device, err := ioutil.TempFile("", "device")
+	require.NoError(t, err)
+	device.Close()
+	defer os.Remove(device.Name())
+
+	container := &apicontainer.Container{
+		Name:    "c1",
+		Devices: []apicontainer.Device{{HostPath: device.Name()}},
+	}
+	assert.NoError(t, verifyContainerDevices(container))
+}
+
+func TestCreateContainerWithUnavailableGPUs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{GPUIDs: []string{"0"}})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	sleepContainer, _ := sleepTask.ContainerByName("sleep5")
+	sleepContainer.GPUCount = 2
+
+	metadata := taskEngine.createContainer(sleepTask, sleepContainer)
+	require.Error(t, metadata.Error)
+	assert.Equal(t, "ContainerGPUAssignmentError", metadata.Error.ErrorName())
+	assert.Empty(t, sleepContainer.GetGPUIDs())
+}
+
+func TestAssignContainerGPUs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{GPUIDs: []string{"0", "1"}})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	task1 := &apitask.Task{Arn: "t1", Containers: []*apicontainer.Container{{Name: "c1", GPUCount: 1}}}
+	task2 := &apitask.Task{Arn: "t2", Containers: []*apicontainer.Container{{Name: "c1", GPUCount: 1}}}
+	task3 := &apitask.Task{Arn: "t3", Containers: []*apicontainer.Container{{Name: "c1", GPUCount: 1}}}
+
+	require.Nil(t, taskEngine.assignContainerGPUs(task1, task1.Containers[0]))
+	assert.Equal(t, []string{"0"}, task1.Containers[0].GetGPUIDs())
+	require.Nil(t, taskEngine.assignContainerGPUs(task2, task2.Containers[0]))
+	assert.Equal(t, []string{"1"}, task2.Containers[0].GetGPUIDs())
+
+	// Creating the container again keeps the GPUs assigned to it
+	require.Nil(t, taskEngine.assignContainerGPUs(task1, task1.Containers[0]))
+	assert.Equal(t, []string{"0"}, task1.Containers[0].GetGPUIDs())
+
+	assert.NotNil(t, taskEngine.assignContainerGPUs(task3, task3.Containers[0]))
+
+	// The GPUs of a cleaned up task can be assigned to other tasks
+	taskEngine.gpuManager.Release(task1.Arn)
+	require.Nil(t, taskEngine.assignContainerGPUs(task3, task3.Containers[0]))
+	assert.Equal(t, []string{"0"}, task3.Containers[0].GetGPUIDs())
+}
+
+func TestReserveTaskGPUs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{GPUIDs: []string{"0", "1"}})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	// The task was assigned a GPU before the agent restarted
+	restoredContainer := &apicontainer.Container{Name: "c1", GPUCount: 1}
+	restoredContainer.SetGPUIDs([]string{"0"})
+	taskEngine.reserveTaskGPUs(&apitask.Task{Arn: "t1", Containers: []*apicontainer.Container{restoredContainer}})
+
+	task := &apitask.Task{Arn: "t2", Containers: []*apicontainer.Container{{Name: "c1", GPUCount: 1}}}
+	require.Nil(t, taskEngine.assignContainerGPUs(task, task.Containers[0]))
+	assert.Equal(t, []string{"1"}, task.Containers[0].GetGPUIDs())
+}
+
+func TestAllocateContainerHostPorts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{
+		DynamicHostPortRangeStart: 40000,
+		DynamicHostPortRangeEnd:   40002,
+	})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	ports := []apicontainer.PortBinding{{ContainerPort: 80}, {ContainerPort: 443, HostPort: 443}, {ContainerPort: 53}}
+	task1 := &apitask.Task{Arn: "t1", Containers: []*apicontainer.Container{{Name: "c1", Ports: ports}}}
+	task2 := &apitask.Task{Arn: "t2", Containers: []*apicontainer.Container{{Name: "c1", Ports: ports}}}
+	awsvpcTask := &apitask.Task{Arn: "t3", Containers: []*apicontainer.Container{{Name: "c1", Ports: ports}}}
+	awsvpcTask.SetTaskENI(&apieni.ENI{})
+
+	require.Nil(t, taskEngine.allocateContainerHostPorts(task1, task1.Containers[0]))
+	assert.Equal(t, []uint16{40000, 40001}, task1.Containers[0].GetAllocatedHostPorts())
+
+	// Creating the container again keeps the host ports allocated to it
+	require.Nil(t, taskEngine.allocateContainerHostPorts(task1, task1.Containers[0]))
+	assert.Equal(t, []uint16{40000, 40001}, task1.Containers[0].GetAllocatedHostPorts())
+
+	// The containers of awsvpc tasks don't publish ports
+	require.Nil(t, taskEngine.allocateContainerHostPorts(awsvpcTask, awsvpcTask.Containers[0]))
+	assert.Empty(t, awsvpcTask.Containers[0].GetAllocatedHostPorts())
+
+	err := taskEngine.allocateContainerHostPorts(task2, task2.Containers[0])
+	require.NotNil(t, err)
+	assert.Equal(t, "ContainerHostPortAllocationError", err.ErrorName())
+
+	// The host ports of a cleaned up task can be allocated to other tasks
+	taskEngine.hostPortAllocator.Release(task1.Arn)
+	require.Nil(t, taskEngine.allocateContainerHostPorts(task2, task2.Containers[0]))
+	assert.Equal(t, []uint16{40002, 40000}, task2.Containers[0].GetAllocatedHostPorts())
+}
+
+func TestAllocateContainerHostPortsWithoutRange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	task := &apitask.Task{Arn: "t1", Containers: []*apicontainer.Container{{
+		Name:  "c1",
+		Ports: []apicontainer.PortBinding{{ContainerPort: 80}},
+	}}}
+	require.Nil(t, taskEngine.allocateContainerHostPorts(task, task.Containers[0]))
+	assert.Empty(t, task.Containers[0].GetAllocatedHostPorts())
+}
+
+func TestReserveTaskHostPorts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{
+		DynamicHostPortRangeStart: 40000,
+		DynamicHostPortRangeEnd:   40001,
+	})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	// The task was allocated a host port before the agent restarted
+	ports := []apicontainer.PortBinding{{ContainerPort: 80}}
+	restoredContainer := &apicontainer.Container{Name: "c1", Ports: ports}
+	restoredContainer.SetAllocatedHostPorts([]uint16{40000})
+	taskEngine.reserveTaskHostPorts(&apitask.Task{Arn: "t1", Containers: []*apicontainer.Container{restoredContainer}})
+
+	task := &apitask.Task{Arn: "t2", Containers: []*apicontainer.Container{{Name: "c1", Ports: ports}}}
+	require.Nil(t, taskEngine.allocateContainerHostPorts(task, task.Containers[0]))
+	assert.Equal(t, []uint16{40001}, task.Containers[0].GetAllocatedHostPorts())
+}
+
+// fakeProber reports the ports bound by processes of the host
+type fakeProber struct {
+	inUse map[uint16]bool
+}
+
+func (prober *fakeProber) InUse(bindIP string, port uint16, protocol string) bool {
+	return prober.inUse[port]
+}
+
+func TestClaimContainerHostPorts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.hostPortProber = &fakeProber{inUse: map[uint16]bool{7070: true}}
+
+	staticPorts := []apicontainer.PortBinding{{ContainerPort: 80, HostPort: 8080}, {ContainerPort: 53}}
+	task1 := &apitask.Task{Arn: "t1", Containers: []*apicontainer.Container{{Name: "c1", Ports: staticPorts}}}
+	task2 := &apitask.Task{Arn: "t2", Containers: []*apicontainer.Container{{Name: "c1", Ports: staticPorts}}}
+
+	require.Nil(t, taskEngine.claimContainerHostPorts(task1, task1.Containers[0], "bridge"))
+	// Creating the container again keeps its claims
+	require.Nil(t, taskEngine.claimContainerHostPorts(task1, task1.Containers[0], "bridge"))
+
+	err := taskEngine.claimContainerHostPorts(task2, task2.Containers[0], "bridge")
+	require.NotNil(t, err)
+	assert.Equal(t, "HostPortConflictError", err.ErrorName())
+	assert.Equal(t, "host port 8080 already in use by task t1", err.Error())
+
+	// The container ports of the host network are host ports
+	hostTask := &apitask.Task{Arn: "t3", Containers: []*apicontainer.Container{{
+		Name:  "c1",
+		Ports: []apicontainer.PortBinding{{ContainerPort: 9090}},
+	}}}
+	require.Nil(t, taskEngine.claimContainerHostPorts(hostTask, hostTask.Containers[0], hostNetworkMode))
+	conflictingTask := &apitask.Task{Arn: "t4", Containers: []*apicontainer.Container{{
+		Name:  "c1",
+		Ports: []apicontainer.PortBinding{{ContainerPort: 80, HostPort: 9090}},
+	}}}
+	err = taskEngine.claimContainerHostPorts(conflictingTask, conflictingTask.Containers[0], "bridge")
+	require.NotNil(t, err)
+	assert.Equal(t, "host port 9090 already in use by task t3", err.Error())
+
+	boundTask := &apitask.Task{Arn: "t5", Containers: []*apicontainer.Container{{
+		Name:  "c1",
+		Ports: []apicontainer.PortBinding{{ContainerPort: 80, HostPort: 7070}},
+	}}}
+	err = taskEngine.claimContainerHostPorts(boundTask, boundTask.Containers[0], "bridge")
+	require.NotNil(t, err)
+	assert.Equal(t, "host port 7070 already in use by host process", err.Error())
+
+	// The static host ports of a stopped task can be used by other tasks
+	taskEngine.hostPortAllocator.ReleaseClaims(task1.Arn)
+	require.Nil(t, taskEngine.claimContainerHostPorts(task2, task2.Containers[0], "bridge"))
+}
+
+func TestReserveTaskHostPortsRestoresClaims(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.hostPortProber = &fakeProber{}
+
+	hostConfig := `{"NetworkMode":"host"}`
+	runningContainer := &apicontainer.Container{
+		Name:              "c1",
+		Ports:             []apicontainer.PortBinding{{ContainerPort: 8080}},
+		DockerConfig:      apicontainer.DockerConfig{HostConfig: &hostConfig},
+		KnownStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	stoppedContainer := &apicontainer.Container{
+		Name:              "c1",
+		Ports:             []apicontainer.PortBinding{{ContainerPort: 80, HostPort: 9090}},
+		KnownStatusUnsafe: apicontainerstatus.ContainerStopped,
+	}
+	taskEngine.reserveTaskHostPorts(&apitask.Task{
+		Arn:               "t1",
+		Containers:        []*apicontainer.Container{runningContainer},
+		KnownStatusUnsafe: apitaskstatus.TaskRunning,
+	})
+	taskEngine.reserveTaskHostPorts(&apitask.Task{
+		Arn:               "t2",
+		Containers:        []*apicontainer.Container{stoppedContainer},
+		KnownStatusUnsafe: apitaskstatus.TaskStopped,
+	})
+
+	task := &apitask.Task{Arn: "t3", Containers: []*apicontainer.Container{{
+		Name:  "c1",
+		Ports: []apicontainer.PortBinding{{ContainerPort: 80, HostPort: 8080}},
+	}}}
+	err := taskEngine.claimContainerHostPorts(task, task.Containers[0], "bridge")
+	require.NotNil(t, err)
+	assert.Equal(t, "host port 8080 already in use by task t1", err.Error())
+
+	task.Containers[0].Ports[0].HostPort = 9090
+	assert.Nil(t, taskEngine.claimContainerHostPorts(task, task.Containers[0], "bridge"))
+}
+
+func TestCreateContainerHostPortConflict(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	taskEngine.hostPortProber = &fakeProber{inUse: map[uint16]bool{8080: true}}
+
+	container := &apicontainer.Container{
+		Name:  "c1",
+		Ports: []apicontainer.PortBinding{{ContainerPort: 80, HostPort: 8080}},
+	}
+	task := &apitask.Task{Arn: "t1", Family: "family", Version: "1", Containers: []*apicontainer.Container{container}}
+	client.EXPECT().APIVersion().Return(defaultDockerClientAPIVersion, nil).AnyTimes()
+	// The container isn't created
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	metadata := taskEngine.createContainer(task, container)
+	require.Error(t, metadata.Error)
+	assert.Equal(t, "host port 8080 already in use by host process", metadata.Error.Error())
+}
+
+func TestStartContainerWithHostPortInUse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{
+		DynamicHostPortRangeStart: 40000,
+		DynamicHostPortRangeEnd:   40009,
+	})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	container := &apicontainer.Container{Name: "c1", Ports: []apicontainer.PortBinding{{ContainerPort: 80}}}
+	task := &apitask.Task{Arn: "t1", Family: "family", Version: "1", Containers: []*apicontainer.Container{container}}
+	require.Nil(t, taskEngine.allocateContainerHostPorts(task, container))
+	taskEngine.state.AddTask(task)
+	taskEngine.state.AddContainer(&apicontainer.DockerContainer{
+		DockerID:   "id1",
+		DockerName: "name",
+		Container:  container,
+	}, task)
+
+	portInUse := dockerapi.DockerContainerMetadata{
+		Error: dockerapi.CannotStartContainerError{
+			FromError: errors.New("Bind for 0.0.0.0:40000 failed: port is already allocated"),
+		},
+	}
+	client.EXPECT().APIVersion().Return(defaultDockerClientAPIVersion, nil).AnyTimes()
+	gomock.InOrder(
+		client.EXPECT().StartContainer(gomock.Any(), "id1", gomock.Any()).Return(portInUse),
+		client.EXPECT().RemoveContainer(gomock.Any(), "id1", dockerclient.RemoveContainerTimeout).Return(nil),
+		client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), "name", gomock.Any()).Do(
+			func(ctx context.Context, config *docker.Config, hostConfig *docker.HostConfig, name string, timeout time.Duration) {
+				assert.Equal(t, []docker.PortBinding{{HostPort: "40001"}}, hostConfig.PortBindings["80/tcp"])
+			}).Return(dockerapi.DockerContainerMetadata{DockerID: "id2"}),
+		client.EXPECT().StartContainer(gomock.Any(), "id2", gomock.Any()).Return(dockerapi.DockerContainerMetadata{DockerID: "id2"}),
+	)
+
+	metadata := taskEngine.startContainer(task, container)
+	assert.NoError(t, metadata.Error)
+	assert.Equal(t, "id2", metadata.DockerID)
+	assert.Equal(t, []uint16{40001}, container.GetAllocatedHostPorts())
+	dockerContainer, ok := taskEngine.state.ContainerByID("id2")
+	require.True(t, ok)
+	assert.Equal(t, container, dockerContainer.Container)
+}
+
+func TestStartContainerWithHostPortInUseStopsRetrying(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{
+		DynamicHostPortRangeStart: 40000,
+		DynamicHostPortRangeEnd:   40009,
+	})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	container := &apicontainer.Container{Name: "c1", Ports: []apicontainer.PortBinding{{ContainerPort: 80}}}
+	task := &apitask.Task{Arn: "t1", Family: "family", Version: "1", Containers: []*apicontainer.Container{container}}
+	require.Nil(t, taskEngine.allocateContainerHostPorts(task, container))
+	taskEngine.state.AddTask(task)
+	taskEngine.state.AddContainer(&apicontainer.DockerContainer{
+		DockerID:   "id",
+		DockerName: "name",
+		Container:  container,
+	}, task)
+
+	portInUse := dockerapi.DockerContainerMetadata{
+		Error: dockerapi.CannotStartContainerError{
+			FromError: errors.New("listen tcp 0.0.0.0:40000: bind: address already in use"),
+		},
+	}
+	client.EXPECT().APIVersion().Return(defaultDockerClientAPIVersion, nil).AnyTimes()
+	client.EXPECT().StartContainer(gomock.Any(), "id", gomock.Any()).Return(portInUse).Times(maxHostPortAllocationAttempts)
+	client.EXPECT().RemoveContainer(gomock.Any(), "id", gomock.Any()).Return(nil).Times(maxHostPortAllocationAttempts - 1)
+	client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), "name", gomock.Any()).Return(
+		dockerapi.DockerContainerMetadata{DockerID: "id"}).Times(maxHostPortAllocationAttempts - 1)
+
+	metadata := taskEngine.startContainer(task, container)
+	assert.Error(t, metadata.Error)
+}
+
+func TestCreateContainerWithEphemeralStorageLimit(t *testing.T) {
+	testCases := []struct {
+		driver     string
+		storageOpt map[string]string
+	}{
+		{
+			driver:     "devicemapper",
+			storageOpt: map[string]string{"size": "1024M"},
+		},
+		{
+			driver:     "overlay2",
+			storageOpt: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.driver, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+			defer ctrl.Finish()
+			taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+			testTask := &apitask.Task{
+				Arn:                   "myTaskArn",
+				Family:                "myFamily",
+				Version:               "1",
+				EphemeralStorageLimit: 1024,
+				Containers:            []*apicontainer.Container{{Name: "c1"}},
+			}
+			client.EXPECT().APIVersion().Return(defaultDockerClientAPIVersion, nil).AnyTimes()
+			client.EXPECT().StorageDriver(gomock.Any(), gomock.Any()).Return(tc.driver, nil)
+			client.EXPECT().CreateContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+				func(ctx interface{}, config *docker.Config, hostConfig *docker.HostConfig, name string, timeout time.Duration) {
+					assert.Equal(t, tc.storageOpt, hostConfig.StorageOpt)
+				})
+			taskEngine.createContainer(testTask, testTask.Containers[0])
+		})
+	}
+}
+
+func TestCheckEphemeralStorage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	withinLimit := &apitask.Task{
+		Arn:                   "t1",
+		EphemeralStorageLimit: 10,
+		DesiredStatusUnsafe:   apitaskstatus.TaskRunning,
+		Containers:            []*apicontainer.Container{{Name: "c1"}, {Name: "c2"}},
+	}
+	overLimit := &apitask.Task{
+		Arn:                   "t2",
+		EphemeralStorageLimit: 10,
+		DesiredStatusUnsafe:   apitaskstatus.TaskRunning,
+		Containers:            []*apicontainer.Container{{Name: "c1"}},
+	}
+	for i, task := range []*apitask.Task{withinLimit, overLimit} {
+		taskEngine.state.AddTask(task)
+		for j, container := range task.Containers {
+			taskEngine.state.AddContainer(&apicontainer.DockerContainer{
+				DockerID:   fmt.Sprintf("id%d%d", i, j),
+				DockerName: fmt.Sprintf("name%d%d", i, j),
+				Container:  container,
+			}, task)
+		}
+	}
+	acsMessages := make(chan acsTransition)
+	taskEngine.managedTasks[overLimit.Arn] = &managedTask{
+		Task:        overLimit,
+		ctx:         ctx,
+		acsMessages: acsMessages,
+	}
+
+	client.EXPECT().ContainerSizes(gomock.Any(), gomock.Any()).Return(map[string]int64{
+		"id00": 4 * bytesPerMiB,
+		"id01": 4 * bytesPerMiB,
+		"id10": 11 * bytesPerMiB,
+	}, nil)
+	taskEngine.checkEphemeralStorage(ctx)
+
+	assert.Equal(t, int64(8*bytesPerMiB), withinLimit.GetEphemeralStorageUsage())
+	assert.Equal(t, apireason.ReasonCodeNone, withinLimit.GetTerminalReasonCode())
+	assert.Equal(t, int64(11*bytesPerMiB), overLimit.GetEphemeralStorageUsage())
+	assert.Equal(t, apireason.ReasonCodeEphemeralStorageExceeded, overLimit.GetTerminalReasonCode())
+	assert.Equal(t, "Ephemeral storage exceeded", overLimit.GetTerminalReason())
+	transition := <-acsMessages
+	assert.Equal(t, apitaskstatus.TaskStopped, transition.desiredStatus)
 }
 
-// TestTaskWithCircularDependency tests the task with containers of which the
-// dependencies can't be resolved
-func TestTaskWithCircularDependency(t *testing.T) {
+func TestStopTasks(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
-	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	ctrl, _, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
 	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
 
-	client.EXPECT().ContainerEvents(gomock.Any())
-
-	task := testdata.LoadTask("circular_dependency")
-
-	err := taskEngine.Init(ctx)
-	assert.NoError(t, err)
+	running := &apitask.Task{
+		Arn:                 "t1",
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+	}
+	stopping := &apitask.Task{
+		Arn:                 "t2",
+		DesiredStatusUnsafe: apitaskstatus.TaskStopped,
+	}
+	acsMessages := make(chan acsTransition, 2)
+	for _, task := range []*apitask.Task{running, stopping} {
+		taskEngine.managedTasks[task.Arn] = &managedTask{
+			Task:        task,
+			ctx:         ctx,
+			acsMessages: acsMessages,
+		}
+	}
 
-	events := taskEngine.StateChangeEvents()
-	go taskEngine.AddTask(task)
-	event := <-events
-	assert.Equal(t, event.(api.TaskStateChange).Status, apitaskstatus.TaskStopped, "Expected task to move to stopped directly")
-	_, ok := taskEngine.(*DockerTaskEngine).state.TaskByArn(task.Arn)
-	assert.True(t, ok, "Task state should be added to the agent state")
+	taskEngine.StopTasks([]string{"t1", "t2", "unknown"}, apireason.ReasonCodeTaskNotInManifest, "not in manifest")
 
-	_, ok = taskEngine.(*DockerTaskEngine).managedTasks[task.Arn]
-	assert.False(t, ok, "Task should not be added to task manager for processing")
+	assert.Equal(t, apireason.ReasonCodeTaskNotInManifest, running.GetTerminalReasonCode())
+	assert.Equal(t, "Not in manifest", running.GetTerminalReason())
+	assert.Equal(t, apireason.ReasonCodeNone, stopping.GetTerminalReasonCode())
+	transition := <-acsMessages
+	assert.Equal(t, apitaskstatus.TaskStopped, transition.desiredStatus)
+	select {
+	case <-acsMessages:
+		t.Error("Expected a single task to be stopped")
+	case <-time.After(10 * time.Millisecond):
+	}
 }
 
 // TestCreateContainerOnAgentRestart tests when agent restarts it should use the
@@ -1364,6 +2126,87 @@ func TestPullNormalImage(t *testing.T) {
 	assert.Equal(t, dockerapi.DockerContainerMetadata{}, metadata, "expected empty metadata")
 }
 
+// TestPullImageConcurrencyLimit tests that no more images than the configured
+// image pull concurrency are pulled at the same time
+func TestPullImageConcurrencyLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{ImagePullConcurrency: 2})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	pullStarted := make(chan struct{}, 3)
+	releasePull := make(chan struct{})
+	client.EXPECT().PullImage(gomock.Any(), gomock.Any()).Do(func(image string, auth *apicontainer.RegistryAuthenticationData) {
+		pullStarted <- struct{}{}
+		<-releasePull
+	}).Return(dockerapi.DockerContainerMetadata{}).Times(3)
+
+	var wg sync.WaitGroup
+	for _, imageName := range []string{"image1", "image2", "image3"} {
+		wg.Add(1)
+		go func(imageName string) {
+			defer wg.Done()
+			taskEngine.pullImage("task", &apicontainer.Container{Name: imageName, Image: imageName})
+		}(imageName)
+	}
+
+	<-pullStarted
+	<-pullStarted
+	select {
+	case <-pullStarted:
+		t.Fatal("expected at most 2 images to be pulled at the same time")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(releasePull)
+	<-pullStarted
+	wg.Wait()
+}
+
+// TestPullImageDedupesInFlightPulls tests that containers referencing the
+// same image share a single pull of the image
+func TestPullImageDedupesInFlightPulls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, privateTaskEngine, _, _, _ := mocks(t, ctx, &config.Config{ImagePullConcurrency: 3})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	pullErr := dockerapi.CannotPullContainerError{FromError: errors.New("error")}
+	pullStarted := make(chan struct{})
+	releasePull := make(chan struct{})
+	client.EXPECT().PullImage("image", gomock.Any()).Do(func(image string, auth *apicontainer.RegistryAuthenticationData) {
+		close(pullStarted)
+		<-releasePull
+	}).Return(dockerapi.DockerContainerMetadata{Error: pullErr})
+
+	firstResult := make(chan dockerapi.DockerContainerMetadata)
+	go func() {
+		firstResult <- taskEngine.pullImage("task", &apicontainer.Container{Name: "c1", Image: "image"})
+	}()
+	<-pullStarted
+
+	secondResult := make(chan dockerapi.DockerContainerMetadata)
+	go func() {
+		secondResult <- taskEngine.pullImage("task", &apicontainer.Container{Name: "c2", Image: "image"})
+	}()
+	// Wait for the second pull to start waiting on the in-flight pull so
+	// that it doesn't pull the image again once the first pull is done
+	for {
+		taskEngine.imagePullsLock.Lock()
+		waiters := taskEngine.imagePulls["image"].waiters
+		taskEngine.imagePullsLock.Unlock()
+		if waiters == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(releasePull)
+
+	assert.Equal(t, pullErr, (<-firstResult).Error)
+	assert.Equal(t, pullErr, (<-secondResult).Error)
+}
+
 func TestPullImageWithImagePullOnceBehavior(t *testing.T) {
 	testcases := []struct {
 		name          string
@@ -1439,6 +2282,63 @@ func TestPullImageWithImagePullPreferCachedBehaviorWithCachedImage(t *testing.T)
 	saver.EXPECT().Save()
 	metadata := taskEngine.pullContainer(task, container)
 	assert.Equal(t, dockerapi.DockerContainerMetadata{}, metadata, "expected empty metadata")
+	assert.True(t, container.GetImageFromCache(), "expected the cached image to be used")
+	pullDuration, ok := container.GetPullDuration()
+	assert.True(t, ok, "expected the use of the cached image to be recorded as a pull")
+	assert.Equal(t, time.Duration(0), pullDuration)
+}
+
+// TestPullImageWithContainerImagePullBehavior tests that the image pull
+// behavior of the container overrides the agent's image pull behavior
+func TestPullImageWithContainerImagePullBehavior(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, privateTaskEngine, _, imageManager, _ := mocks(t, ctx, &config.Config{ImagePullBehavior: config.ImagePullAlwaysBehavior})
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+	saver := mock_statemanager.NewMockStateManager(ctrl)
+	taskEngine.SetSaver(saver)
+	taskEngine._time = nil
+	imageName := "image"
+	container := &apicontainer.Container{
+		Type:              apicontainer.ContainerNormal,
+		Image:             imageName,
+		ImagePullBehavior: "prefer-cached",
+	}
+	task := &apitask.Task{
+		Containers: []*apicontainer.Container{container},
+	}
+	imageState := &image.ImageState{
+		Image: &image.Image{ImageID: "id"},
+	}
+	client.EXPECT().InspectImage(imageName).Return(nil, nil)
+	imageManager.EXPECT().RecordContainerReference(container)
+	imageManager.EXPECT().GetImageStateFromImageName(imageName).Return(imageState, true)
+	saver.EXPECT().Save()
+	metadata := taskEngine.pullContainer(task, container)
+	assert.Equal(t, dockerapi.DockerContainerMetadata{}, metadata, "expected empty metadata")
+	assert.True(t, container.GetImageFromCache(), "expected the cached image to be used")
+}
+
+func TestImagePullBehavior(t *testing.T) {
+	cfg := &config.Config{ImagePullBehavior: config.ImagePullOnceBehavior}
+	testCases := []struct {
+		containerBehavior string
+		expectedBehavior  config.ImagePullBehaviorType
+	}{
+		{"", config.ImagePullOnceBehavior},
+		{"invalid", config.ImagePullOnceBehavior},
+		{"default", config.ImagePullDefaultBehavior},
+		{"always", config.ImagePullAlwaysBehavior},
+		{"prefer-cached", config.ImagePullPreferCachedBehavior},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.containerBehavior, func(t *testing.T) {
+			container := &apicontainer.Container{ImagePullBehavior: tc.containerBehavior}
+			assert.Equal(t, tc.expectedBehavior, imagePullBehavior(cfg, container))
+		})
+	}
 }
 
 func TestPullImageWithImagePullPreferCachedBehaviorWithoutCachedImage(t *testing.T) {
@@ -1468,6 +2368,10 @@ func TestPullImageWithImagePullPreferCachedBehaviorWithoutCachedImage(t *testing
 	saver.EXPECT().Save()
 	metadata := taskEngine.pullContainer(task, container)
 	assert.Equal(t, dockerapi.DockerContainerMetadata{}, metadata, "expected empty metadata")
+	assert.False(t, container.GetImageFromCache(), "expected the image to be pulled")
+	_, ok := container.GetPullDuration()
+	assert.True(t, ok, "expected the pull to be recorded")
+	assert.False(t, container.GetPullStartedAt().IsZero())
 }
 
 func TestUpdateContainerReference(t *testing.T) {
@@ -1504,11 +2408,11 @@ func TestUpdateContainerReference(t *testing.T) {
 // agent starts, container created, metadata file created, agent restarted, container recovered
 // during task engine init, metadata file updated
 func TestMetadataFileUpdatedAgentRestart(t *testing.T) {
-	conf := &defaultConfig
+	conf := defaultConfig
 	conf.ContainerMetadataEnabled = true
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
-	ctrl, client, _, privateTaskEngine, _, imageManager, metadataManager := mocks(t, ctx, conf)
+	ctrl, client, _, privateTaskEngine, _, imageManager, metadataManager := mocks(t, ctx, &conf)
 	saver := mock_statemanager.NewMockStateManager(ctrl)
 	defer ctrl.Finish()
 
@@ -1539,9 +2443,9 @@ func TestMetadataFileUpdatedAgentRestart(t *testing.T) {
 	saver.EXPECT().ForceSave().AnyTimes()
 
 	metadataManager.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
-		func(ctx interface{}, dockerID string, task *apitask.Task, containerName string) {
+		func(ctx interface{}, dockerID string, task *apitask.Task, container *apicontainer.Container) {
 			assert.Equal(t, expectedTaskARN, task.Arn)
-			assert.Equal(t, expectedContainerName, containerName)
+			assert.Equal(t, expectedContainerName, container.Name)
 			assert.Equal(t, expectedDockerID, dockerID)
 			metadataUpdateWG.Done()
 		})
@@ -1552,6 +2456,62 @@ func TestMetadataFileUpdatedAgentRestart(t *testing.T) {
 	metadataUpdateWG.Wait()
 }
 
+// TestMetadataFileUpdatesCoalesced tests that the rewrites of the metadata file
+// of a container requested while a rewrite is in progress are done once it
+// completes, in a single rewrite with the latest state of the container
+func TestMetadataFileUpdatesCoalesced(t *testing.T) {
+	conf := defaultConfig
+	conf.ContainerMetadataEnabled = true
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, privateTaskEngine, _, _, metadataManager := mocks(t, ctx, &conf)
+	defer ctrl.Finish()
+	taskEngine, _ := privateTaskEngine.(*DockerTaskEngine)
+
+	task := testdata.LoadTask("sleep5")
+	container, _ := task.ContainerByName("sleep5")
+	container.SetKnownStatus(apicontainerstatus.ContainerCreated)
+	taskEngine.State().AddTask(task)
+	taskEngine.State().AddContainer(&apicontainer.DockerContainer{DockerID: containerID, Container: container}, task)
+
+	firstUpdateStarted := make(chan struct{})
+	firstUpdateDone := make(chan struct{})
+	lastUpdateDone := make(chan struct{})
+	gomock.InOrder(
+		metadataManager.EXPECT().Update(gomock.Any(), containerID, task, container).Do(
+			func(ctx interface{}, dockerID string, task *apitask.Task, container *apicontainer.Container) {
+				close(firstUpdateStarted)
+				<-firstUpdateDone
+			}),
+		metadataManager.EXPECT().Update(gomock.Any(), containerID, task, container).Do(
+			func(ctx interface{}, dockerID string, task *apitask.Task, container *apicontainer.Container) {
+				assert.Equal(t, apicontainerstatus.ContainerStopped, container.GetKnownStatus())
+				close(lastUpdateDone)
+			}),
+	)
+
+	taskEngine.queueMetadataFileUpdate(task, container)
+	<-firstUpdateStarted
+	container.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	taskEngine.queueMetadataFileUpdate(task, container)
+	container.SetKnownStatus(apicontainerstatus.ContainerStopped)
+	taskEngine.queueMetadataFileUpdate(task, container)
+	close(firstUpdateDone)
+	<-lastUpdateDone
+
+	// Wait for the container to be no longer tracked, so that no unexpected
+	// rewrite is done after the test
+	for {
+		taskEngine.metadataFileUpdatesLock.Lock()
+		_, ok := taskEngine.metadataFileUpdates[container]
+		taskEngine.metadataFileUpdatesLock.Unlock()
+		if !ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 // TestTaskUseExecutionRolePullECRImage tests the agent will use the execution role
 // credentials to pull from an ECR repository
 func TestTaskUseExecutionRolePullECRImage(t *testing.T) {
@@ -1883,7 +2843,7 @@ func TestPullStoppedAtWasSetCorrectlyWhenPullFail(t *testing.T) {
 		client.EXPECT().PullImage(container.Image, nil).Return(dockerapi.DockerContainerMetadata{}),
 		client.EXPECT().PullImage(container.Image, nil).Return(dockerapi.DockerContainerMetadata{}),
 		client.EXPECT().PullImage(container.Image, nil).Return(
-			dockerapi.DockerContainerMetadata{Error: dockerapi.CannotPullContainerError{fmt.Errorf("error")}}),
+			dockerapi.DockerContainerMetadata{Error: dockerapi.CannotPullContainerError{FromError: fmt.Errorf("error")}}),
 	)
 	imageManager.EXPECT().RecordContainerReference(gomock.Any()).Times(3)
 	imageManager.EXPECT().GetImageStateFromImageName(gomock.Any()).Return(nil, false).Times(3)
@@ -1948,6 +2908,131 @@ func TestSynchronizeContainerStatus(t *testing.T) {
 	assert.Equal(t, volumes, dockerContainer.Container.GetVolumes())
 }
 
+// TestReconcileStateWithDocker tests that the transitions missed while the
+// docker event stream was closed are written to the managed task
+func TestReconcileStateWithDocker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	state := taskEngine.(*DockerTaskEngine).State()
+	testTask := testdata.LoadTask("sleep5")
+	running := testTask.Containers[0]
+	exited := &apicontainer.Container{Name: "exited"}
+	missing := &apicontainer.Container{Name: "missing"}
+	stopped := &apicontainer.Container{Name: "stopped"}
+	testTask.Containers = append(testTask.Containers, exited, missing, stopped)
+	for _, container := range testTask.Containers {
+		container.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	}
+	stopped.SetKnownStatus(apicontainerstatus.ContainerStopped)
+
+	state.AddTask(testTask)
+	for _, container := range testTask.Containers {
+		state.AddContainer(&apicontainer.DockerContainer{
+			DockerID:   container.Name + "-id",
+			DockerName: container.Name,
+			Container:  container,
+		}, testTask)
+	}
+	mtask := &managedTask{
+		Task:           testTask,
+		ctx:            ctx,
+		dockerMessages: make(chan dockerContainerChange, len(testTask.Containers)),
+	}
+	taskEngine.(*DockerTaskEngine).managedTasks[testTask.Arn] = mtask
+
+	exitCode := 1
+	client.EXPECT().ListContainers(gomock.Any(), true, gomock.Any()).Return(dockerapi.ListContainersResponse{
+		DockerIDs: []string{running.Name + "-id", "exited-id", "stopped-id"},
+	})
+	client.EXPECT().DescribeContainer(gomock.Any(), running.Name+"-id").Return(apicontainerstatus.ContainerRunning,
+		dockerapi.DockerContainerMetadata{DockerID: running.Name + "-id"})
+	client.EXPECT().DescribeContainer(gomock.Any(), "exited-id").Return(apicontainerstatus.ContainerStopped,
+		dockerapi.DockerContainerMetadata{DockerID: "exited-id", ExitCode: &exitCode})
+
+	taskEngine.(*DockerTaskEngine).reconcileStateWithDocker()
+
+	require.Len(t, mtask.dockerMessages, 2)
+	change := <-mtask.dockerMessages
+	assert.Equal(t, exited, change.container)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, change.event.Status)
+	assert.Equal(t, &exitCode, change.event.ExitCode)
+	assert.NoError(t, change.event.Error)
+
+	change = <-mtask.dockerMessages
+	assert.Equal(t, missing, change.container)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, change.event.Status)
+	require.NotNil(t, change.event.Error)
+	assert.Equal(t, "container not found after docker daemon restart", change.event.Error.Error())
+}
+
+// TestHandleDockerEventStreamReconnectedEvent tests that the state of the
+// containers is reconciled when the docker event stream reconnects
+func TestHandleDockerEventStreamReconnectedEvent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	listed := make(chan struct{})
+	client.EXPECT().ListContainers(gomock.Any(), true, gomock.Any()).Do(
+		func(interface{}, interface{}, interface{}) {
+			close(listed)
+		}).Return(dockerapi.ListContainersResponse{})
+
+	taskEngine.(*DockerTaskEngine).handleDockerEvent(dockerapi.DockerContainerChangeEvent{
+		Type: apicontainer.EventStreamReconnectedEvent,
+	})
+	<-listed
+}
+
+// TestRemoveOrphanedContainers tests that only the containers of tasks missing
+// from the state that weren't created recently are removed
+func TestRemoveOrphanedContainers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, mockTime, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	state := taskEngine.(*DockerTaskEngine).State()
+	testTask := testdata.LoadTask("sleep5")
+	state.AddTask(testTask)
+	state.AddContainer(&apicontainer.DockerContainer{
+		DockerID:   "managed",
+		DockerName: "managed-name",
+		Container:  testTask.Containers[0],
+	}, testTask)
+
+	now := time.Now()
+	orphanedLabels := map[string]string{labelTaskARN: "orphaned-task"}
+	containers := map[string]*docker.Container{
+		"unlabeled": {ID: "unlabeled", Config: &docker.Config{}, Created: now.Add(-time.Hour)},
+		"restored": {ID: "restored", Created: now.Add(-time.Hour),
+			Config: &docker.Config{Labels: map[string]string{labelTaskARN: testTask.Arn}}},
+		"recent": {ID: "recent", Config: &docker.Config{Labels: orphanedLabels}, Created: now.Add(-time.Minute)},
+		"running": {ID: "running", Config: &docker.Config{Labels: orphanedLabels}, Created: now.Add(-time.Hour),
+			State: docker.State{Running: true}},
+		"exited": {ID: "exited", Config: &docker.Config{Labels: orphanedLabels}, Created: now.Add(-time.Hour)},
+	}
+	client.EXPECT().ListContainers(gomock.Any(), true, gomock.Any()).Return(dockerapi.ListContainersResponse{
+		DockerIDs: []string{"managed", "unlabeled", "restored", "recent", "running", "exited"},
+	})
+	for dockerID, container := range containers {
+		client.EXPECT().InspectContainer(gomock.Any(), dockerID, gomock.Any()).Return(container, nil)
+	}
+	mockTime.EXPECT().Now().Return(now).AnyTimes()
+	gomock.InOrder(
+		client.EXPECT().StopContainer(gomock.Any(), "running", defaultConfig.DockerStopTimeout).Return(
+			dockerapi.DockerContainerMetadata{DockerID: "running"}),
+		client.EXPECT().RemoveContainer(gomock.Any(), "running", gomock.Any()).Return(nil),
+	)
+	client.EXPECT().RemoveContainer(gomock.Any(), "exited", gomock.Any()).Return(nil)
+
+	taskEngine.(*DockerTaskEngine).removeOrphanedContainers(ctx)
+}
+
 // TestHandleDockerHealthEvent tests the docker health event will only cause the
 // container health status change
 func TestHandleDockerHealthEvent(t *testing.T) {
@@ -1980,6 +3065,56 @@ func TestHandleDockerHealthEvent(t *testing.T) {
 	assert.Equal(t, testContainer.Health.Status, apicontainerstatus.ContainerHealthy)
 }
 
+// TestHandleDockerHealthEventEmitsHealthChange tests that a transition of the
+// health status of a running container emits a container state change event,
+// while health check runs that don't change the health status don't
+func TestHandleDockerHealthEventEmitsHealthChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, _, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	state := taskEngine.(*DockerTaskEngine).State()
+	testTask := testdata.LoadTask("sleep5")
+	testContainer := testTask.Containers[0]
+	testContainer.HealthCheckType = "docker"
+	testContainer.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	testContainer.SetSentStatus(apicontainerstatus.ContainerRunning)
+
+	state.AddTask(testTask)
+	state.AddContainer(&apicontainer.DockerContainer{DockerID: "id",
+		DockerName: "container_name",
+		Container:  testContainer,
+	}, testTask)
+
+	healthEvent := dockerapi.DockerContainerChangeEvent{
+		Status: apicontainerstatus.ContainerRunning,
+		Type:   apicontainer.ContainerHealthEvent,
+		DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+			DockerID: "id",
+			Health: apicontainer.HealthStatus{
+				Status: apicontainerstatus.ContainerUnhealthy,
+			},
+		},
+	}
+	go taskEngine.(*DockerTaskEngine).handleDockerEvent(healthEvent)
+
+	event := <-taskEngine.StateChangeEvents()
+	containerEvent, ok := event.(api.ContainerStateChange)
+	require.True(t, ok)
+	assert.Equal(t, testContainer.Name, containerEvent.ContainerName)
+	assert.Equal(t, apicontainerstatus.ContainerRunning, containerEvent.Status)
+	assert.Equal(t, apicontainerstatus.ContainerUnhealthy, containerEvent.HealthStatus)
+
+	// The same health status reported again doesn't emit another event
+	taskEngine.(*DockerTaskEngine).handleDockerEvent(healthEvent)
+	select {
+	case event := <-taskEngine.StateChangeEvents():
+		t.Errorf("Unexpected state change event: %v", event)
+	default:
+	}
+}
+
 func TestContainerMetadataUpdatedOnRestart(t *testing.T) {
 	dockerID := "dockerID_created"
 	labels := map[string]string{
@@ -2096,6 +3231,7 @@ func TestContainerProgressParallize(t *testing.T) {
 	defer cancel()
 	ctrl, client, testTime, taskEngine, _, imageManager, _ := mocks(t, ctx, &defaultConfig)
 	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(client)
 
 	stateChangeEvents := taskEngine.StateChangeEvents()
 	eventStream := make(chan dockerapi.DockerContainerChangeEvent)
@@ -2394,3 +3530,173 @@ func TestTaskSSMSecretsEnvironmentVariables(t *testing.T) {
 
 	assert.Nil(t, ret.Error)
 }
+
+func TestExecCommand(t *testing.T) {
+	testCases := []struct {
+		name          string
+		containerName string
+		knownStatus   apicontainerstatus.ContainerStatus
+		expectExec    bool
+		expectedError interface{}
+	}{
+		{
+			name:          "running container",
+			containerName: "sleep5",
+			knownStatus:   apicontainerstatus.ContainerRunning,
+			expectExec:    true,
+		},
+		{
+			name:          "stopped container",
+			containerName: "sleep5",
+			knownStatus:   apicontainerstatus.ContainerStopped,
+			expectedError: &ExecContainerNotRunningError{},
+		},
+		{
+			name:          "unknown container",
+			containerName: "unknown",
+			knownStatus:   apicontainerstatus.ContainerRunning,
+			expectedError: &ExecContainerNotFoundError{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+			defer ctrl.Finish()
+
+			testTask := testdata.LoadTask("sleep5")
+			container := testTask.Containers[0]
+			container.SetKnownStatus(tc.knownStatus)
+			taskEngine.(*DockerTaskEngine).State().AddTask(testTask)
+			taskEngine.(*DockerTaskEngine).State().AddContainer(&apicontainer.DockerContainer{
+				DockerID:   containerID,
+				DockerName: dockerContainerName,
+				Container:  container,
+			}, testTask)
+
+			stdin := strings.NewReader("input")
+			var stdout bytes.Buffer
+			command := []string{"cat"}
+			if tc.expectExec {
+				// The input of the command is forwarded to docker
+				client.EXPECT().ExecCommand(gomock.Any(), containerID, command, stdin, &stdout, nil,
+					dockerclient.ExecCommandTimeout).Return(1, nil)
+			}
+
+			exitCode, err := taskEngine.(*DockerTaskEngine).ExecCommand(ctx, ExecRequest{
+				TaskARN:       testTask.Arn,
+				ContainerName: tc.containerName,
+				Command:       command,
+				Requester:     "127.0.0.1:1234",
+			}, stdin, &stdout, nil)
+			if tc.expectedError != nil {
+				assert.IsType(t, tc.expectedError, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 1, exitCode)
+		})
+	}
+}
+
+func TestWaitForContainerExit(t *testing.T) {
+	testCases := []struct {
+		name            string
+		setExpectations func(client *mock_dockerapi.MockDockerClient)
+	}{
+		{
+			name: "wait succeeds",
+			setExpectations: func(client *mock_dockerapi.MockDockerClient) {
+				gomock.InOrder(
+					client.EXPECT().WaitContainer(gomock.Any(), containerID).Return(
+						dockerapi.DockerContainerMetadata{DockerID: containerID, ExitCode: aws.Int(exitCode)}),
+					client.EXPECT().DescribeContainer(gomock.Any(), containerID).Return(
+						apicontainerstatus.ContainerStopped, dockerapi.DockerContainerMetadata{DockerID: containerID}),
+				)
+			},
+		},
+		{
+			name: "wait fails as the daemon restarts",
+			setExpectations: func(client *mock_dockerapi.MockDockerClient) {
+				waitErr := dockerapi.CannotWaitContainerError{FromError: errors.New("connection reset")}
+				gomock.InOrder(
+					client.EXPECT().WaitContainer(gomock.Any(), containerID).Return(
+						dockerapi.DockerContainerMetadata{Error: waitErr}),
+					client.EXPECT().DescribeContainer(gomock.Any(), containerID).Return(
+						apicontainerstatus.ContainerStopped, dockerapi.DockerContainerMetadata{
+							DockerID: containerID,
+							ExitCode: aws.Int(exitCode),
+						}),
+				)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+			defer ctrl.Finish()
+			tc.setExpectations(client)
+
+			dockerTaskEngine := taskEngine.(*DockerTaskEngine)
+			sleepTask := testdata.LoadTask("sleep5")
+			sleepContainer := sleepTask.Containers[0]
+			dockerTaskEngine.state.AddTask(sleepTask)
+			dockerTaskEngine.state.AddContainer(&apicontainer.DockerContainer{
+				DockerID:  containerID,
+				Container: sleepContainer,
+			}, sleepTask)
+			mTask := &managedTask{
+				Task:           sleepTask,
+				ctx:            ctx,
+				engine:         dockerTaskEngine,
+				dockerMessages: make(chan dockerContainerChange),
+			}
+			dockerTaskEngine.managedTasks[sleepTask.Arn] = mTask
+
+			go dockerTaskEngine.waitForContainerExit(ctx, sleepTask, sleepContainer, containerID)
+			change := <-mTask.dockerMessages
+			assert.Equal(t, sleepContainer, change.container)
+			assert.Equal(t, apicontainerstatus.ContainerStopped, change.event.Status)
+			assert.Equal(t, containerID, change.event.DockerID)
+			require.NotNil(t, change.event.ExitCode)
+			assert.Equal(t, exitCode, aws.IntValue(change.event.ExitCode))
+		})
+	}
+}
+
+func TestWaitForContainerExitRetriesWhileContainerRuns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+
+	waitErr := dockerapi.CannotWaitContainerError{FromError: errors.New("connection reset")}
+	gomock.InOrder(
+		client.EXPECT().WaitContainer(gomock.Any(), containerID).Return(
+			dockerapi.DockerContainerMetadata{Error: waitErr}),
+		client.EXPECT().DescribeContainer(gomock.Any(), containerID).Return(
+			apicontainerstatus.ContainerRunning, dockerapi.DockerContainerMetadata{DockerID: containerID}),
+		// The task is force stopped while waiting again
+		client.EXPECT().WaitContainer(gomock.Any(), containerID).Do(
+			func(ctx context.Context, dockerID string) {
+				cancel()
+			}).Return(dockerapi.DockerContainerMetadata{Error: waitErr}),
+	)
+
+	sleepTask := testdata.LoadTask("sleep5")
+	done := make(chan struct{})
+	go func() {
+		taskEngine.(*DockerTaskEngine).waitForContainerExit(ctx, sleepTask, sleepTask.Containers[0], containerID)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the wait for the exit of the container to end")
+	}
+}
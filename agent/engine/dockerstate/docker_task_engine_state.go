@@ -59,6 +59,8 @@ type TaskEngineState interface {
 	RemoveENIAttachment(mac string)
 	// ENIByMac returns the specific ENIAttachment of the given mac address
 	ENIByMac(mac string) (*apieni.ENIAttachment, bool)
+	// AllENIAttachments returns all of the eni attachments
+	AllENIAttachments() []*apieni.ENIAttachment
 	// RemoveTask removes a task from the state
 	RemoveTask(task *apitask.Task)
 	// Reset resets all the fileds in the state
@@ -334,6 +336,16 @@ func (state *DockerTaskEngineState) AddContainer(container *apicontainer.DockerC
 		state.tasks[task.Arn] = task
 	}
 
+	existingMap, exists := state.taskToID[task.Arn]
+	if exists {
+		// The docker container the container was recorded with is replaced
+		// when the container is recreated
+		if existingContainer, ok := existingMap[container.Container.Name]; ok && container.DockerID != "" &&
+			existingContainer.DockerID != "" && existingContainer.DockerID != container.DockerID {
+			state.removeIDToContainerTaskUnsafe(existingContainer)
+		}
+	}
+
 	state.storeIDToContainerTaskUnsafe(container, task)
 
 	dockerID := container.DockerID
@@ -344,7 +356,6 @@ func (state *DockerTaskEngineState) AddContainer(container *apicontainer.DockerC
 		state.storeV3EndpointIDToDockerIDUnsafe(v3EndpointID, dockerID)
 	}
 
-	existingMap, exists := state.taskToID[task.Arn]
 	if !exists {
 		existingMap = make(map[string]*apicontainer.DockerContainer, len(task.Containers))
 		state.taskToID[task.Arn] = existingMap
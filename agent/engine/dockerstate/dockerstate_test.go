@@ -24,6 +24,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine/image"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateDockerTaskEngineState(t *testing.T) {
@@ -172,6 +173,25 @@ func TestTwophaseAddContainer(t *testing.T) {
 	}
 }
 
+func TestAddRecreatedContainer(t *testing.T) {
+	state := NewTaskEngineState()
+	testTask := &apitask.Task{Arn: "test", Containers: []*apicontainer.Container{{
+		Name: "testContainer",
+	}}}
+	state.AddTask(testTask)
+
+	state.AddContainer(&apicontainer.DockerContainer{DockerName: "dockerName", Container: testTask.Containers[0], DockerID: "did"}, testTask)
+	state.AddContainer(&apicontainer.DockerContainer{DockerName: "dockerName", Container: testTask.Containers[0], DockerID: "did2"}, testTask)
+
+	// The docker container the container was created with first is forgotten
+	_, ok := state.ContainerByID("did")
+	assert.False(t, ok)
+	container, ok := state.ContainerByID("did2")
+	require.True(t, ok)
+	assert.Equal(t, "did2", container.DockerID)
+	assert.Equal(t, []string{"did2"}, state.GetAllContainerIDs())
+}
+
 func TestRemoveTask(t *testing.T) {
 	state := NewTaskEngineState()
 	testContainer1 := &apicontainer.Container{
@@ -100,6 +100,18 @@ func (mr *MockTaskEngineStateMockRecorder) AddTaskIPAddress(arg0, arg1 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTaskIPAddress", reflect.TypeOf((*MockTaskEngineState)(nil).AddTaskIPAddress), arg0, arg1)
 }
 
+// AllENIAttachments mocks base method
+func (m *MockTaskEngineState) AllENIAttachments() []*eni.ENIAttachment {
+	ret := m.ctrl.Call(m, "AllENIAttachments")
+	ret0, _ := ret[0].([]*eni.ENIAttachment)
+	return ret0
+}
+
+// AllENIAttachments indicates an expected call of AllENIAttachments
+func (mr *MockTaskEngineStateMockRecorder) AllENIAttachments() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllENIAttachments", reflect.TypeOf((*MockTaskEngineState)(nil).AllENIAttachments))
+}
+
 // AllImageStates mocks base method
 func (m *MockTaskEngineState) AllImageStates() []*image.ImageState {
 	ret := m.ctrl.Call(m, "AllImageStates")
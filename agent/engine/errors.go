@@ -14,6 +14,9 @@
 package engine
 
 import (
+	"fmt"
+	"os"
+
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
 )
@@ -42,13 +45,31 @@ func (err ContainerVanishedError) Error() string { return "No container matching
 // ErrorName returns the name of the error
 func (err ContainerVanishedError) ErrorName() string { return "ContainerVanishedError" }
 
+// ContainerMissingAfterDaemonRestartError is a type for describing a container
+// that no longer exists after the docker daemon restarted
+type ContainerMissingAfterDaemonRestartError struct{}
+
+func (err ContainerMissingAfterDaemonRestartError) Error() string {
+	return "container not found after docker daemon restart"
+}
+
+// ErrorName returns the name of the error
+func (err ContainerMissingAfterDaemonRestartError) ErrorName() string {
+	return "ContainerMissingAfterDaemonRestartError"
+}
+
 // TaskDependencyError is the error for task that dependencies can't
 // be resolved
 type TaskDependencyError struct {
 	taskArn string
+	// reason describes the dependency that can't be resolved, if known
+	reason string
 }
 
 func (err TaskDependencyError) Error() string {
+	if err.reason != "" {
+		return "Task dependencies cannot be resolved, taskArn: " + err.taskArn + ": " + err.reason
+	}
 	return "Task dependencies cannot be resolved, taskArn: " + err.taskArn
 }
 
@@ -57,6 +78,53 @@ func (err TaskDependencyError) ErrorName() string {
 	return "TaskDependencyError"
 }
 
+// ContainerRestartsExhaustedError is the error for a container that exited
+// after being restarted as many times as its restart policy allows
+type ContainerRestartsExhaustedError struct {
+	attempts int
+}
+
+func (err ContainerRestartsExhaustedError) Error() string {
+	return fmt.Sprintf("Container exited after exhausting its %d restart attempts", err.attempts)
+}
+
+// ErrorName is the name of the error
+func (err ContainerRestartsExhaustedError) ErrorName() string {
+	return "ContainerRestartsExhaustedError"
+}
+
+// ExecContainerNotFoundError is the error for a command to be run in a
+// container that isn't known to the engine
+type ExecContainerNotFoundError struct {
+	taskARN       string
+	containerName string
+}
+
+func (err *ExecContainerNotFoundError) Error() string {
+	return fmt.Sprintf("container %s not found in task %s", err.containerName, err.taskARN)
+}
+
+// ErrorName is the name of the error
+func (err *ExecContainerNotFoundError) ErrorName() string {
+	return "ExecContainerNotFoundError"
+}
+
+// ExecContainerNotRunningError is the error for a command to be run in a
+// container that isn't running
+type ExecContainerNotRunningError struct {
+	containerName string
+	knownStatus   apicontainerstatus.ContainerStatus
+}
+
+func (err *ExecContainerNotRunningError) Error() string {
+	return fmt.Sprintf("container %s is not running, its status is %s", err.containerName, err.knownStatus.String())
+}
+
+// ErrorName is the name of the error
+func (err *ExecContainerNotRunningError) ErrorName() string {
+	return "ExecContainerNotRunningError"
+}
+
 // TaskStoppedBeforePullBeginError is a type for task errors involving pull
 type TaskStoppedBeforePullBeginError struct {
 	taskArn string
@@ -97,3 +165,76 @@ func (err CannotGetDockerClientVersionError) ErrorName() string {
 func (err CannotGetDockerClientVersionError) Error() string {
 	return err.fromError.Error()
 }
+
+// ContainerDeviceError is the error for a container that exposes a host device
+// that can't be accessed on the instance
+type ContainerDeviceError struct {
+	containerName string
+	hostPath      string
+	err           error
+}
+
+func (err ContainerDeviceError) Error() string {
+	if os.IsNotExist(err.err) {
+		return fmt.Sprintf("host device %s required by container %s does not exist on the instance",
+			err.hostPath, err.containerName)
+	}
+	return fmt.Sprintf("host device %s required by container %s can't be accessed: %v",
+		err.hostPath, err.containerName, err.err)
+}
+
+// ErrorName is the name of the error
+func (err ContainerDeviceError) ErrorName() string {
+	return "ContainerDeviceError"
+}
+
+// ContainerGPUAssignmentError is the error for a container that reserves more
+// GPUs than are available on the instance
+type ContainerGPUAssignmentError struct {
+	containerName string
+	err           error
+}
+
+func (err ContainerGPUAssignmentError) Error() string {
+	return fmt.Sprintf("unable to assign gpus to container %s: %v", err.containerName, err.err)
+}
+
+// ErrorName is the name of the error
+func (err ContainerGPUAssignmentError) ErrorName() string {
+	return "ContainerGPUAssignmentError"
+}
+
+// ContainerHostPortAllocationError is the error for a container whose port
+// mappings without a host port can't be allocated host ports from the
+// dynamic host port range
+type ContainerHostPortAllocationError struct {
+	containerName string
+	err           error
+}
+
+func (err ContainerHostPortAllocationError) Error() string {
+	return fmt.Sprintf("unable to allocate host ports to container %s: %v", err.containerName, err.err)
+}
+
+// ErrorName is the name of the error
+func (err ContainerHostPortAllocationError) ErrorName() string {
+	return "ContainerHostPortAllocationError"
+}
+
+// HostPortConflictError is the error for a container with a static host port,
+// or a container port of the host network, already in use by another task or
+// by a process of the host
+type HostPortConflictError struct {
+	port uint16
+	// owner describes what the port is in use by
+	owner string
+}
+
+func (err HostPortConflictError) Error() string {
+	return fmt.Sprintf("host port %d already in use by %s", err.port, err.owner)
+}
+
+// ErrorName is the name of the error
+func (err HostPortConflictError) ErrorName() string {
+	return "HostPortConflictError"
+}
@@ -0,0 +1,198 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package hostport allocates the host ports of the port mappings of containers
+// without a host port from a configured range
+package hostport
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Allocator tracks the allocation of the ports of its range to tasks, so that
+// a port is allocated to a single task at a time
+type Allocator struct {
+	start int
+	end   int
+	// reserved are the ports of the range that are never allocated
+	reserved map[int]struct{}
+	// allocations maps the allocated ports to the arns of the tasks they're
+	// allocated to
+	allocations map[int]string
+	// claims maps the static host ports of running tasks to the arns of the
+	// tasks they're claimed by. Unlike allocations, claims are released as
+	// soon as the tasks stop
+	claims map[int]string
+	// next is the port the search for unallocated ports starts from. Ports
+	// are handed out round robin, so that released ports and ports found to
+	// be in use aren't allocated again right away
+	next int
+	lock sync.Mutex
+}
+
+// NewAllocator returns an Allocator handing out the ports between start and
+// end, both included, except for the reserved ports. The allocator is disabled
+// if the range is empty
+func NewAllocator(start uint16, end uint16, reservedPorts ...[]uint16) *Allocator {
+	reserved := make(map[int]struct{})
+	for _, ports := range reservedPorts {
+		for _, port := range ports {
+			reserved[int(port)] = struct{}{}
+		}
+	}
+	return &Allocator{
+		start:       int(start),
+		end:         int(end),
+		reserved:    reserved,
+		allocations: make(map[int]string),
+		claims:      make(map[int]string),
+		next:        int(start),
+	}
+}
+
+// Enabled returns true if the allocator has a range to allocate ports from
+func (a *Allocator) Enabled() bool {
+	return a.start != 0 && a.start <= a.end
+}
+
+// Allocate allocates the given number of unallocated ports to the task and
+// returns them. No port is allocated if fewer are available
+func (a *Allocator) Allocate(taskArn string, count int) ([]uint16, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.allocateUnsafe(taskArn, count)
+}
+
+func (a *Allocator) allocateUnsafe(taskArn string, count int) ([]uint16, error) {
+	if !a.Enabled() {
+		return nil, errors.New("host port allocator: no host port range configured")
+	}
+	var ports []uint16
+	port := a.next
+	for i := 0; i <= a.end-a.start && len(ports) < count; i++ {
+		_, reserved := a.reserved[port]
+		_, allocated := a.allocations[port]
+		_, claimed := a.claims[port]
+		if !reserved && !allocated && !claimed {
+			ports = append(ports, uint16(port))
+		}
+		port++
+		if port > a.end {
+			port = a.start
+		}
+	}
+	if len(ports) < count {
+		return nil, errors.Errorf("host port allocator: %d host ports requested, %d available", count, len(ports))
+	}
+	for _, port := range ports {
+		a.allocations[int(port)] = taskArn
+	}
+	a.next = port
+	return ports, nil
+}
+
+// Reallocate allocates as many ports to the task as the given ones, which are
+// released. It's used to replace ports that turned out to be in use outside
+// of the agent
+func (a *Allocator) Reallocate(taskArn string, ports []uint16) ([]uint16, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	newPorts, err := a.allocateUnsafe(taskArn, len(ports))
+	if err != nil {
+		return nil, err
+	}
+	for _, port := range ports {
+		if a.allocations[int(port)] == taskArn {
+			delete(a.allocations, int(port))
+		}
+	}
+	return newPorts, nil
+}
+
+// Reserve records the ports as allocated to the task. It's used to restore the
+// allocations of tasks started before the agent restarted
+func (a *Allocator) Reserve(taskArn string, ports []uint16) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, port := range ports {
+		a.allocations[int(port)] = taskArn
+	}
+}
+
+// ClaimedError is the error for a host port claimed or allocated by another
+// task
+type ClaimedError struct {
+	Port    uint16
+	TaskArn string
+}
+
+func (err ClaimedError) Error() string {
+	return fmt.Sprintf("host port allocator: host port %d is used by task %s", err.Port, err.TaskArn)
+}
+
+// Claim records the static host ports as used by the task. No port is claimed
+// if one of them is claimed or allocated by another task, which is returned as
+// a ClaimedError. The ports needn't be in the range of the allocator
+func (a *Allocator) Claim(taskArn string, ports []uint16) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, port := range ports {
+		if claimedTaskArn, ok := a.claims[int(port)]; ok && claimedTaskArn != taskArn {
+			return ClaimedError{Port: port, TaskArn: claimedTaskArn}
+		}
+		if allocatedTaskArn, ok := a.allocations[int(port)]; ok && allocatedTaskArn != taskArn {
+			return ClaimedError{Port: port, TaskArn: allocatedTaskArn}
+		}
+	}
+	for _, port := range ports {
+		a.claims[int(port)] = taskArn
+	}
+	return nil
+}
+
+// ReleaseClaims releases the static host ports claimed by the task, once its
+// containers stopped
+func (a *Allocator) ReleaseClaims(taskArn string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for port, claimedTaskArn := range a.claims {
+		if claimedTaskArn == taskArn {
+			delete(a.claims, port)
+		}
+	}
+}
+
+// Release releases the ports allocated to and claimed by the task
+func (a *Allocator) Release(taskArn string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for port, allocatedTaskArn := range a.allocations {
+		if allocatedTaskArn == taskArn {
+			delete(a.allocations, port)
+		}
+	}
+	for port, claimedTaskArn := range a.claims {
+		if claimedTaskArn == taskArn {
+			delete(a.claims, port)
+		}
+	}
+}
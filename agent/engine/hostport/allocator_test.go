@@ -0,0 +1,177 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hostport
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocate(t *testing.T) {
+	allocator := NewAllocator(40000, 40004, []uint16{40001}, []uint16{40003})
+
+	ports, err := allocator.Allocate("t1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40000, 40002}, ports)
+
+	ports, err = allocator.Allocate("t2", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40004}, ports)
+
+	_, err = allocator.Allocate("t3", 1)
+	assert.Error(t, err)
+}
+
+func TestAllocateNotEnoughPorts(t *testing.T) {
+	allocator := NewAllocator(40000, 40001)
+
+	_, err := allocator.Allocate("t1", 3)
+	assert.Error(t, err)
+
+	// A failed allocation doesn't allocate any port
+	ports, err := allocator.Allocate("t2", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40000, 40001}, ports)
+}
+
+func TestAllocateDisabled(t *testing.T) {
+	allocator := NewAllocator(0, 0)
+
+	assert.False(t, allocator.Enabled())
+	_, err := allocator.Allocate("t1", 1)
+	assert.Error(t, err)
+}
+
+func TestAllocateRoundRobin(t *testing.T) {
+	allocator := NewAllocator(40000, 40002)
+
+	ports, err := allocator.Allocate("t1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40000}, ports)
+	allocator.Release("t1")
+
+	// Released ports are allocated again once the rest of the range is used
+	ports, err = allocator.Allocate("t2", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40001, 40002, 40000}, ports)
+}
+
+func TestAllocateUpToLastPort(t *testing.T) {
+	allocator := NewAllocator(65534, 65535)
+
+	ports, err := allocator.Allocate("t1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{65534, 65535}, ports)
+}
+
+func TestAllocateConcurrently(t *testing.T) {
+	allocator := NewAllocator(40000, 40003)
+
+	var wg sync.WaitGroup
+	results := make([][]uint16, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ports, err := allocator.Allocate(fmt.Sprintf("t%d", i), 1)
+			assert.NoError(t, err)
+			results[i] = ports
+		}(i)
+	}
+	wg.Wait()
+
+	allocated := make(map[uint16]struct{})
+	for _, ports := range results {
+		require.Len(t, ports, 1)
+		allocated[ports[0]] = struct{}{}
+	}
+	assert.Len(t, allocated, 4, "each task should be allocated a different port")
+}
+
+func TestReallocate(t *testing.T) {
+	allocator := NewAllocator(40000, 40003)
+
+	ports, err := allocator.Allocate("t1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40000, 40001}, ports)
+
+	ports, err = allocator.Reallocate("t1", ports)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40002, 40003}, ports)
+
+	// The replaced ports are released
+	ports, err = allocator.Allocate("t2", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40000, 40001}, ports)
+}
+
+func TestReserveAndRelease(t *testing.T) {
+	allocator := NewAllocator(40000, 40002)
+
+	allocator.Reserve("t1", []uint16{40000, 40002})
+	ports, err := allocator.Allocate("t2", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40001}, ports)
+
+	allocator.Release("t1")
+	ports, err = allocator.Allocate("t3", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40002, 40000}, ports)
+}
+
+func TestClaim(t *testing.T) {
+	allocator := NewAllocator(40000, 40001)
+
+	require.NoError(t, allocator.Claim("t1", []uint16{80, 40000}))
+	// Claiming the ports again is a no-op
+	require.NoError(t, allocator.Claim("t1", []uint16{80}))
+
+	err := allocator.Claim("t2", []uint16{443, 80})
+	require.Error(t, err)
+	assert.Equal(t, ClaimedError{Port: 80, TaskArn: "t1"}, err)
+
+	// No port is claimed when one of them is in use, and claimed ports aren't
+	// allocated
+	ports, err := allocator.Allocate("t3", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{40001}, ports)
+	require.NoError(t, allocator.Claim("t4", []uint16{443}))
+
+	// Allocated ports can't be claimed by other tasks
+	err = allocator.Claim("t2", []uint16{40001})
+	assert.Equal(t, ClaimedError{Port: 40001, TaskArn: "t3"}, err)
+}
+
+func TestReleaseClaims(t *testing.T) {
+	allocator := NewAllocator(40000, 40001)
+
+	ports, err := allocator.Allocate("t1", 1)
+	require.NoError(t, err)
+	require.NoError(t, allocator.Claim("t1", []uint16{80}))
+
+	// The claims of a stopped task are released while its allocations are
+	// kept until it's cleaned up
+	allocator.ReleaseClaims("t1")
+	require.NoError(t, allocator.Claim("t2", []uint16{80}))
+	assert.Error(t, allocator.Claim("t2", ports))
+
+	allocator.Release("t2")
+	require.NoError(t, allocator.Claim("t3", []uint16{80}))
+}
@@ -0,0 +1,70 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hostport
+
+import (
+	"net"
+	"os"
+	"strconv"
+)
+
+// Prober checks whether host ports are bound by processes of the host
+type Prober interface {
+	// InUse returns true if the port of the protocol is already bound on the
+	// address, or on any address of the host when the address is empty
+	InUse(bindIP string, port uint16, protocol string) bool
+}
+
+// bindProber probes host ports by binding them
+type bindProber struct{}
+
+// NewProber returns a Prober binding the probed ports
+func NewProber() Prober {
+	return bindProber{}
+}
+
+// InUse binds the port and closes the socket right away. As the listening
+// socket never accepts a connection, it isn't left in the TIME_WAIT state,
+// which would keep the port from being bound by the container
+func (bindProber) InUse(bindIP string, port uint16, protocol string) bool {
+	address := net.JoinHostPort(bindIP, strconv.Itoa(int(port)))
+	if protocol == "udp" {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return isAddrInUse(err)
+		}
+		conn.Close()
+		return false
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return isAddrInUse(err)
+	}
+	listener.Close()
+	return false
+}
+
+// isAddrInUse returns true if the bind failed as the address is in use. Other
+// failures, like the address not being local, are left for docker to report
+func isAddrInUse(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	syscallErr, ok := opErr.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	return syscallErr.Err == errAddrInUse
+}
@@ -0,0 +1,61 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hostport
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProberTCPPortInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+
+	prober := NewProber()
+	assert.True(t, prober.InUse("127.0.0.1", port, "tcp"))
+	assert.True(t, prober.InUse("", port, "tcp"))
+
+	listener.Close()
+	assert.False(t, prober.InUse("127.0.0.1", port, "tcp"))
+	// The probe leaves the port free to bind
+	listener, err = net.Listen("tcp", address)
+	require.NoError(t, err)
+	listener.Close()
+}
+
+func TestProberUDPPortInUse(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	prober := NewProber()
+	assert.True(t, prober.InUse("127.0.0.1", port, "udp"))
+	// The tcp port of the same number is free
+	assert.False(t, prober.InUse("127.0.0.1", port, "tcp"))
+
+	conn.Close()
+	assert.False(t, prober.InUse("127.0.0.1", port, "udp"))
+}
+
+func TestProberUnknownAddress(t *testing.T) {
+	// Failures other than the port being in use are left for docker to report
+	assert.False(t, NewProber().InUse("192.0.2.1", 8080, "tcp"))
+}
@@ -0,0 +1,60 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package image
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchExclusionPattern returns the first of the patterns matching any of the
+// names of an image, and whether one matches. A pattern matches a name if it's
+// the name itself, its repository, a prefix of the name ending with a slash, or
+// a glob matching either the name or its repository
+func MatchExclusionPattern(patterns []string, names []string) (string, bool) {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if matchExclusionPattern(pattern, name) {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+func matchExclusionPattern(pattern string, name string) bool {
+	repository := imageRepository(name)
+	if strings.ContainsAny(pattern, "*?[") {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+		matched, _ := path.Match(pattern, repository)
+		return matched
+	}
+	if pattern == name || pattern == repository {
+		return true
+	}
+	return strings.HasSuffix(pattern, "/") && strings.HasPrefix(name, pattern)
+}
+
+// imageRepository returns the name of the image without its tag or digest
+func imageRepository(name string) string {
+	if i := strings.Index(name, "@"); i != -1 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,60 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchExclusionPattern(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"amazonlinux:2", "amazonlinux:2", true},
+		{"amazonlinux:2", "amazonlinux:latest", false},
+		{"amazonlinux", "amazonlinux:2", true},
+		{"amazonlinux", "amazonlinux@sha256:qwerty", true},
+		{"amazonlinux", "amazonlinux-base:2", false},
+		{"localhost:5000/golden", "localhost:5000/golden:1", true},
+		{"localhost:5000/golden", "localhost:5000/golden", true},
+		{"internal/", "internal/base:1", true},
+		{"internal/", "internal-tools/base:1", false},
+		{"internal/base-*", "internal/base-java:8", true},
+		{"internal/base-*", "internal/base-java", true},
+		{"internal/base-*", "internal/other:8", false},
+		{"internal/*:golden", "internal/base:golden", true},
+		{"internal/*:golden", "internal/base:latest", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.name, func(t *testing.T) {
+			_, matched := MatchExclusionPattern([]string{tc.pattern}, []string{tc.name})
+			assert.Equal(t, tc.matched, matched)
+		})
+	}
+}
+
+func TestMatchExclusionPatternAnyName(t *testing.T) {
+	pattern, matched := MatchExclusionPattern([]string{"busybox", "golden:*"}, []string{"myimage:latest", "golden:1"})
+	assert.True(t, matched, "an image should be excluded if any of its names matches")
+	assert.Equal(t, "golden:*", pattern)
+
+	_, matched = MatchExclusionPattern([]string{"busybox"}, nil)
+	assert.False(t, matched)
+}
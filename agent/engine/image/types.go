@@ -142,6 +142,22 @@ func (imageState *ImageState) GetPullSucceeded() bool {
 	return imageState.PullSucceeded
 }
 
+// SetLastUsedAt sets the time the image was last used
+func (imageState *ImageState) SetLastUsedAt(lastUsedAt time.Time) {
+	imageState.lock.Lock()
+	defer imageState.lock.Unlock()
+
+	imageState.LastUsedAt = lastUsedAt
+}
+
+// GetLastUsedAt safely returns the time the image was last used
+func (imageState *ImageState) GetLastUsedAt() time.Time {
+	imageState.lock.RLock()
+	defer imageState.lock.RUnlock()
+
+	return imageState.LastUsedAt
+}
+
 // MarshalJSON marshals image state
 func (imageState *ImageState) MarshalJSON() ([]byte, error) {
 	imageState.lock.Lock()
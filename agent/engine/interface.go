@@ -18,6 +18,7 @@ import (
 
 	"context"
 
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	"github.com/aws/amazon-ecs-agent/agent/statechange"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
@@ -48,6 +49,10 @@ type TaskEngine interface {
 	// GetTaskByArn gets a managed task, given a task arn.
 	GetTaskByArn(string) (*apitask.Task, bool)
 
+	// StopTasks stops the managed tasks identified by the arns, recording the
+	// cause and reason of their stop.
+	StopTasks(taskARNs []string, reasonCode apireason.ReasonCode, reason string)
+
 	Version() (string, error)
 
 	json.Marshaler
@@ -22,6 +22,7 @@ import (
 	reflect "reflect"
 
 	container "github.com/aws/amazon-ecs-agent/agent/api/container"
+	reason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	task "github.com/aws/amazon-ecs-agent/agent/api/task"
 	image "github.com/aws/amazon-ecs-agent/agent/engine/image"
 	statechange "github.com/aws/amazon-ecs-agent/agent/statechange"
@@ -155,6 +156,16 @@ func (mr *MockTaskEngineMockRecorder) StateChangeEvents() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StateChangeEvents", reflect.TypeOf((*MockTaskEngine)(nil).StateChangeEvents))
 }
 
+// StopTasks mocks base method
+func (m *MockTaskEngine) StopTasks(arg0 []string, arg1 reason.ReasonCode, arg2 string) {
+	m.ctrl.Call(m, "StopTasks", arg0, arg1, arg2)
+}
+
+// StopTasks indicates an expected call of StopTasks
+func (mr *MockTaskEngineMockRecorder) StopTasks(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopTasks", reflect.TypeOf((*MockTaskEngine)(nil).StopTasks), arg0, arg1, arg2)
+}
+
 // UnmarshalJSON mocks base method
 func (m *MockTaskEngine) UnmarshalJSON(arg0 []byte) error {
 	ret := m.ctrl.Call(m, "UnmarshalJSON", arg0)
@@ -238,6 +249,16 @@ func (mr *MockImageManagerMockRecorder) RecordContainerReference(arg0 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordContainerReference", reflect.TypeOf((*MockImageManager)(nil).RecordContainerReference), arg0)
 }
 
+// RecordContainerStopped mocks base method
+func (m *MockImageManager) RecordContainerStopped(arg0 *container.Container) {
+	m.ctrl.Call(m, "RecordContainerStopped", arg0)
+}
+
+// RecordContainerStopped indicates an expected call of RecordContainerStopped
+func (mr *MockImageManagerMockRecorder) RecordContainerStopped(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordContainerStopped", reflect.TypeOf((*MockImageManager)(nil).RecordContainerStopped), arg0)
+}
+
 // RemoveContainerReferenceFromImageState mocks base method
 func (m *MockImageManager) RemoveContainerReferenceFromImageState(arg0 *container.Container) error {
 	ret := m.ctrl.Call(m, "RemoveContainerReferenceFromImageState", arg0)
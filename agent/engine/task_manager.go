@@ -22,6 +22,7 @@ import (
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/config"
@@ -50,6 +51,13 @@ const (
 	stoppedSentWaitInterval               = 30 * time.Second
 	maxStoppedWaitTimes                   = 72 * time.Hour / stoppedSentWaitInterval
 	taskUnableToTransitionToStoppedReason = "TaskStateError: Agent could not progress task's state to stopped"
+	// containerOrderingCheckInterval is how often the containers a container
+	// depends on are checked while it waits for them
+	containerOrderingCheckInterval = 5 * time.Second
+	// minTaskCleanupWaitDuration and maxTaskCleanupWaitDuration bound the
+	// cleanup wait duration a task can override the instance-wide one with
+	minTaskCleanupWaitDuration = 1 * time.Minute
+	maxTaskCleanupWaitDuration = 7 * 24 * time.Hour
 )
 
 var (
@@ -77,6 +85,8 @@ type resourceStateChange struct {
 type acsTransition struct {
 	seqnum        int64
 	desiredStatus apitaskstatus.TaskStatus
+	// stoppedReason is the reason ACS gave for stopping the task
+	stoppedReason string
 }
 
 // containerTransition defines the struct for a container to transition
@@ -155,6 +165,11 @@ type managedTask struct {
 	// This can be used by tests that are looking to ensure that the steady state
 	// verification logic gets executed to set it to a low interval
 	steadyStatePollInterval time.Duration
+
+	// shutdownOrderDeadline is when the containers of the stopping task stop
+	// waiting on the containers depending on them and are all stopped at once.
+	// It's set the first time a container's stop has to wait
+	shutdownOrderDeadline time.Time
 }
 
 // newManagedTask is a method on DockerTaskEngine to create a new managedTask.
@@ -243,9 +258,29 @@ func (mtask *managedTask) overseeTask() {
 			mtask.Arn, mtask.StopSequenceNumber)
 		mtask.taskStopWG.Done(mtask.StopSequenceNumber)
 	}
+	// The static host ports of the task can be used by other tasks once its
+	// containers stopped
+	mtask.engine.hostPortAllocator.ReleaseClaims(mtask.Arn)
 	// TODO: make this idempotent on agent restart
 	go mtask.releaseIPInIPAM()
-	mtask.cleanupTask(mtask.cfg.TaskCleanupWaitDuration)
+	mtask.cleanupTask(mtask.cleanupWaitDuration())
+}
+
+// cleanupWaitDuration returns how long to wait after the task stopped before
+// cleaning it up. The cleanup wait duration of the task is preferred over the
+// instance-wide one, unless it's out of bounds
+func (mtask *managedTask) cleanupWaitDuration() time.Duration {
+	if mtask.CleanupWaitDurationSeconds == 0 {
+		return mtask.cfg.TaskCleanupWaitDuration
+	}
+	duration := time.Duration(mtask.CleanupWaitDurationSeconds) * time.Second
+	if duration < minTaskCleanupWaitDuration || duration > maxTaskCleanupWaitDuration {
+		seelog.Warnf("Managed task [%s]: ignoring cleanup wait duration %s outside of [%s, %s], using %s instead",
+			mtask.Arn, duration.String(), minTaskCleanupWaitDuration.String(),
+			maxTaskCleanupWaitDuration.String(), mtask.cfg.TaskCleanupWaitDuration.String())
+		return mtask.cfg.TaskCleanupWaitDuration
+	}
+	return duration
 }
 
 // emitCurrentStatus emits a container event for every container and a task
@@ -332,7 +367,8 @@ func (mtask *managedTask) waitEvent(stopWaiting <-chan struct{}) bool {
 	select {
 	case acsTransition := <-mtask.acsMessages:
 		seelog.Debugf("Managed task [%s]: got acs event", mtask.Arn)
-		mtask.handleDesiredStatusChange(acsTransition.desiredStatus, acsTransition.seqnum)
+		mtask.handleDesiredStatusChange(acsTransition.desiredStatus, acsTransition.seqnum,
+			acsTransition.stoppedReason)
 		return false
 	case dockerChange := <-mtask.dockerMessages:
 		seelog.Debugf("Managed task [%s]: got container [%s] event: [%s]",
@@ -354,8 +390,10 @@ func (mtask *managedTask) waitEvent(stopWaiting <-chan struct{}) bool {
 // handleDesiredStatusChange updates the desired status on the task. Updates
 // only occur if the new desired status is "compatible" (farther along than the
 // current desired state); "redundant" (less-than or equal desired states) are
-// ignored and dropped.
-func (mtask *managedTask) handleDesiredStatusChange(desiredStatus apitaskstatus.TaskStatus, seqnum int64) {
+// ignored and dropped. The reason ACS gave for stopping the task is recorded
+// alongside a compatible transition to stopped.
+func (mtask *managedTask) handleDesiredStatusChange(desiredStatus apitaskstatus.TaskStatus, seqnum int64,
+	stoppedReason string) {
 	// Handle acs message changes this task's desired status to whatever
 	// acs says it should be if it is compatible
 	seelog.Debugf("Managed task [%s]: new acs transition to: %s; sequence number: %d; task stop sequence number: %d",
@@ -371,6 +409,12 @@ func (mtask *managedTask) handleDesiredStatusChange(desiredStatus apitaskstatus.
 		mtask.SetStopSequenceNumber(seqnum)
 		mtask.taskStopWG.Add(seqnum, 1)
 	}
+	if desiredStatus == apitaskstatus.TaskStopped {
+		mtask.SetTerminalReasonCode(apireason.ReasonCodeUserInitiatedStop)
+		if stoppedReason != "" {
+			mtask.SetStoppedReason(stoppedReason)
+		}
+	}
 	mtask.SetDesiredStatus(desiredStatus)
 	mtask.UpdateDesiredStatus()
 }
@@ -402,6 +446,17 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 		return
 	}
 
+	if event.Status == apicontainerstatus.ContainerStopped {
+		mtask.recordContainerExit(container, event)
+		// Internal container(created by ecs-agent) images aren't managed
+		if !container.IsInternal() {
+			mtask.engine.imageManager.RecordContainerStopped(container)
+		}
+	}
+	if mtask.restartContainerIfAllowed(container, event, containerKnownStatus) {
+		return
+	}
+
 	// Update the container to be known
 	currentKnownStatus := containerKnownStatus
 	container.SetKnownStatus(event.Status)
@@ -414,11 +469,20 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 		}
 	}
 
+	if event.Status == apicontainerstatus.ContainerRunning {
+		mtask.waitForContainerExit(container)
+	}
+
 	// Update the container health status
 	if container.HealthStatusShouldBeReported() {
 		container.SetHealthStatus(event.Health)
 	}
 
+	// Rewrite the metadata file with the new state of the container
+	if mtask.cfg.ContainerMetadataEnabled && !container.IsInternal() && isMetadataFileTransition(event.Status) {
+		mtask.engine.queueMetadataFileUpdate(mtask.Task, container)
+	}
+
 	mtask.RecordExecutionStoppedAt(container)
 	seelog.Debugf("Managed task [%s]: sending container change event to tcs, container: [%s(%s)], status: %s",
 		mtask.Arn, container.Name, event.DockerID, event.Status.String())
@@ -443,6 +507,118 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 		mtask.Arn, container.Name, mtask.GetDesiredStatus().String())
 }
 
+// isMetadataFileTransition returns true if the metadata file of the container
+// is rewritten when the container transitions to the status
+func isMetadataFileTransition(status apicontainerstatus.ContainerStatus) bool {
+	switch status {
+	case apicontainerstatus.ContainerPulled, apicontainerstatus.ContainerCreated,
+		apicontainerstatus.ContainerRunning, apicontainerstatus.ContainerStopped:
+		return true
+	}
+	return false
+}
+
+// recordContainerExit adds the exit described by the event to the exit history
+// of the container. This happens before the container is restarted so that
+// exits followed by a restart are recorded as well
+func (mtask *managedTask) recordContainerExit(container *apicontainer.Container,
+	event dockerapi.DockerContainerChangeEvent) {
+	exit := apicontainer.ContainerExit{
+		Time:     event.FinishedAt,
+		ExitCode: event.ExitCode,
+	}
+	if exit.Time.IsZero() {
+		exit.Time = time.Now()
+	}
+	if event.Error != nil {
+		exit.Reason = event.Error.Error()
+		exit.OutOfMemory = event.Error.ErrorName() == dockerapi.OutOfMemoryErrorName
+	}
+	container.RecordExit(exit)
+}
+
+// waitForContainerExit starts waiting for the exit of the container, which
+// has just started running. The wait ends with the task, so that it doesn't
+// outlive tasks that are force stopped
+func (mtask *managedTask) waitForContainerExit(container *apicontainer.Container) {
+	dockerID := container.GetRuntimeID()
+	if dockerID == "" {
+		seelog.Warnf("Managed task [%s]: unable to wait for the exit of container [%s] without a docker id",
+			mtask.Arn, container.Name)
+		return
+	}
+	go mtask.engine.waitForContainerExit(mtask.ctx, mtask.Task, container, dockerID)
+}
+
+// restartContainerIfAllowed restarts a non-essential container that exited
+// while the task is running, if its restart policy allows it. The container
+// remains known as running while it's restarted. Returns true if the container
+// is being restarted
+func (mtask *managedTask) restartContainerIfAllowed(container *apicontainer.Container,
+	event dockerapi.DockerContainerChangeEvent,
+	currentKnownStatus apicontainerstatus.ContainerStatus) bool {
+	policy := container.RestartPolicy
+	if policy == nil || container.IsEssential() ||
+		event.Status != apicontainerstatus.ContainerStopped ||
+		currentKnownStatus != apicontainerstatus.ContainerRunning ||
+		container.DesiredTerminal() || mtask.GetDesiredStatus().Terminal() {
+		return false
+	}
+
+	if event.ExitCode != nil {
+		for _, exitCode := range policy.IgnoredExitCodes {
+			if *event.ExitCode == exitCode {
+				seelog.Infof("Managed task [%s]: not restarting container [%s], exit code %d is ignored by its restart policy",
+					mtask.Arn, container.Name, exitCode)
+				return false
+			}
+		}
+	}
+
+	restartAttemptPeriod := time.Duration(policy.RestartAttemptPeriod) * time.Second
+	if mtask.time().Now().Sub(container.GetStartedAt()) < restartAttemptPeriod {
+		seelog.Infof("Managed task [%s]: not restarting container [%s], it ran for less than its restart attempt period of %s",
+			mtask.Arn, container.Name, restartAttemptPeriod.String())
+		return false
+	}
+
+	if container.GetRestartCount() >= policy.Attempts {
+		seelog.Warnf("Managed task [%s]: not restarting container [%s], all %d restart attempts were used",
+			mtask.Arn, container.Name, policy.Attempts)
+		container.ApplyingError = apierrors.NewNamedError(ContainerRestartsExhaustedError{attempts: policy.Attempts})
+		return false
+	}
+
+	restartCount := container.IncrementRestartCount()
+	seelog.Infof("Managed task [%s]: restarting container [%s] which exited, restart attempt %d of %d",
+		mtask.Arn, container.Name, restartCount, policy.Attempts)
+	go func() {
+		metadata := mtask.engine.startContainer(mtask.Task, container)
+		if metadata.Error != nil {
+			// The container exits again, which uses up another restart attempt
+			seelog.Warnf("Managed task [%s]: unable to restart container [%s]: %v",
+				mtask.Arn, container.Name, metadata.Error)
+			mtask.emitDockerContainerChange(dockerContainerChange{
+				container: container,
+				event: dockerapi.DockerContainerChangeEvent{
+					Status:                  apicontainerstatus.ContainerStopped,
+					DockerContainerMetadata: metadata,
+				},
+			})
+			return
+		}
+		container.SetStartedAt(metadata.StartedAt)
+		mtask.waitForContainerExit(container)
+		if container.HealthStatusShouldBeReported() {
+			// The health check starts over with the restarted container, so
+			// the health of its previous run doesn't last through the start
+			// period of its health check
+			container.SetHealthStatus(apicontainer.HealthStatus{Status: apicontainerstatus.ContainerHealthUnknown})
+		}
+	}()
+	return true
+}
+
 // handleResourceStateChange attempts to update resource's known status depending on
 // the current status and errors during transition
 func (mtask *managedTask) handleResourceStateChange(resChange resourceStateChange) {
@@ -616,12 +792,13 @@ func (mtask *managedTask) handleEventError(containerChange dockerContainerChange
 	// event.Status is the desired container transition from container's known status
 	// (* -> event.Status)
 	case apicontainerstatus.ContainerPulled:
-		// If the agent pull behavior is always or once, we receive the error because
+		// If the container's pull behavior is always or once, we receive the error because
 		// the image pull fails, the task should fail. If we don't fail task here,
 		// then the cached image will probably be used for creating container, and we
 		// don't want to use cached image for both cases.
-		if mtask.cfg.ImagePullBehavior == config.ImagePullAlwaysBehavior ||
-			mtask.cfg.ImagePullBehavior == config.ImagePullOnceBehavior {
+		pullBehavior := imagePullBehavior(mtask.cfg, container)
+		if pullBehavior == config.ImagePullAlwaysBehavior ||
+			pullBehavior == config.ImagePullOnceBehavior {
 			seelog.Errorf("Managed task [%s]: error while pulling image %s for container %s , moving task to STOPPED: %v",
 				mtask.Arn, container.Image, container.Name, event.Error)
 			// The task should be stopped regardless of whether this container is
@@ -636,6 +813,7 @@ func (mtask *managedTask) handleEventError(containerChange dockerContainerChange
 		// assuming it exists.
 		seelog.Errorf("Managed task [%s]: error while pulling container %s and image %s, will try to run anyway: %v",
 			mtask.Arn, container.Name, container.Image, event.Error)
+		container.SetImageFromCache(true)
 		// proceed anyway
 		return true
 	case apicontainerstatus.ContainerStopped:
@@ -700,6 +878,20 @@ func (mtask *managedTask) handleContainerStoppedTransitionError(event dockerapi.
 		return false
 	}
 
+	// If the container was killed by the kernel for exceeding its memory
+	// limit, it is stopped. The task is stopped along with an essential
+	// container, so let it be known why
+	if event.Error.ErrorName() == dockerapi.OutOfMemoryErrorName {
+		seelog.Infof("Managed task [%s]: container [%s] was killed due to memory usage",
+			mtask.Arn, container.Name)
+		if container.Essential {
+			mtask.Task.SetTerminalReason(apierrors.NewNamedError(event.Error).Error())
+		}
+		container.SetKnownStatus(apicontainerstatus.ContainerStopped)
+		container.SetDesiredStatus(apicontainerstatus.ContainerStopped)
+		return true
+	}
+
 	// If we were trying to transition to stopped and had an error, we
 	// clearly can't just continue trying to transition it to stopped
 	// again and again. In this case, assume it's stopped (or close
@@ -748,7 +940,7 @@ func (mtask *managedTask) progressTask() {
 		})
 
 	if !anyContainerTransition && !anyResourceTransition {
-		if !mtask.waitForExecutionCredentialsFromACS(reasons) {
+		if !mtask.waitForExecutionCredentialsFromACS(reasons) && !mtask.waitForContainerOrdering(reasons) {
 			mtask.onContainersUnableToTransitionState()
 		}
 		return
@@ -801,6 +993,27 @@ func (mtask *managedTask) waitForExecutionCredentialsFromACS(reasons []error) bo
 	return false
 }
 
+// waitForContainerOrdering checks if the container that can't be transitioned
+// was caused by waiting on the containers it depends on, or on the containers
+// depending on it to stop, and waits for them.
+// Health status changes aren't sent to the task, so the dependencies are
+// checked again after an interval if no other event arrives
+func (mtask *managedTask) waitForContainerOrdering(reasons []error) bool {
+	for _, reason := range reasons {
+		if reason == dependencygraph.ErrContainerOrderingNotResolved ||
+			reason == dependencygraph.ErrContainerShutdownOrderNotResolved {
+			seelog.Debugf("Managed task [%s]: waiting for containers to reach the conditions depended on", mtask.Arn)
+
+			timeoutCtx, timeoutCancel := context.WithTimeout(mtask.ctx, containerOrderingCheckInterval)
+			defer timeoutCancel()
+
+			mtask.waitEvent(timeoutCtx.Done())
+			return true
+		}
+	}
+	return false
+}
+
 // startContainerTransitions steps through each container in the task and calls
 // the passed transition function when a transition should occur.
 func (mtask *managedTask) startContainerTransitions(transitionFunc containerTransitionFunc) (bool, map[string]apicontainerstatus.ContainerStatus, []error) {
@@ -956,8 +1169,19 @@ func (mtask *managedTask) containerNextState(container *apicontainer.Container)
 			reason:         dependencygraph.ContainerPastDesiredStatusErr,
 		}
 	}
-	if err := dependencygraph.DependenciesAreResolved(container, mtask.Containers,
-		mtask.Task.GetExecutionCredentialsID(), mtask.credentialsManager, mtask.GetResources()); err != nil {
+	err := dependencygraph.DependenciesAreResolved(container, mtask.Containers,
+		mtask.Task.GetExecutionCredentialsID(), mtask.credentialsManager, mtask.GetResources(), mtask.cfg)
+	if timeoutErr, ok := err.(*dependencygraph.ContainerOrderingTimeoutError); ok {
+		// The container will never start; it's moved to stopped instead
+		mtask.onContainerOrderingTimeout(container, timeoutErr)
+		err = nil
+	}
+	if err == dependencygraph.ErrContainerShutdownOrderNotResolved && mtask.shutdownOrderTimedOut() {
+		seelog.Warnf("Managed task [%s]: stopping container [%s] without waiting for the containers depending on it: task stop timeout elapsed",
+			mtask.Arn, container.Name)
+		err = nil
+	}
+	if err != nil {
 		seelog.Debugf("Managed task [%s]: can't apply state to container [%s] yet due to unresolved dependencies: %v",
 			mtask.Arn, container.Name, err)
 		return &containerTransition{
@@ -1001,6 +1225,38 @@ func (mtask *managedTask) containerNextState(container *apicontainer.Container)
 	}
 }
 
+// onContainerOrderingTimeout stops a container that gave up waiting on a
+// container it depends on, recording the dependency as the reason
+func (mtask *managedTask) onContainerOrderingTimeout(container *apicontainer.Container,
+	err *dependencygraph.ContainerOrderingTimeoutError) {
+	seelog.Warnf("Managed task [%s]: stopping container [%s]: %v", mtask.Arn, container.Name, err)
+	container.ApplyingError = apierrors.NewNamedError(err)
+	container.SetDesiredStatus(apicontainerstatus.ContainerStopped)
+	if container.IsEssential() {
+		mtask.Task.SetTerminalReason(container.ApplyingError.Error())
+	}
+}
+
+// shutdownOrderTimedOut returns true once the containers of the task have waited
+// longer than the task's stop timeout for the containers depending on them to
+// stop. The task's stop timeout is the sum of the stop timeouts of its
+// containers, which bounds stopping them one after another
+func (mtask *managedTask) shutdownOrderTimedOut() bool {
+	now := mtask.time().Now()
+	if mtask.shutdownOrderDeadline.IsZero() {
+		var stopTimeout time.Duration
+		for _, container := range mtask.Containers {
+			containerStopTimeout := container.GetStopTimeout()
+			if containerStopTimeout <= 0 {
+				containerStopTimeout = mtask.cfg.DockerStopTimeout
+			}
+			stopTimeout += containerStopTimeout
+		}
+		mtask.shutdownOrderDeadline = now.Add(stopTimeout)
+	}
+	return now.After(mtask.shutdownOrderDeadline)
+}
+
 func (mtask *managedTask) resourceNextState(resource taskresource.TaskResource) *resourceTransition {
 	if resource.DesiredTerminal() {
 		nextState := resource.TerminalStatus()
@@ -1030,7 +1286,7 @@ func (mtask *managedTask) onContainersUnableToTransitionState() {
 		// TODO we should probably panic here
 	} else {
 		seelog.Criticalf("Managed task [%s]: moving task to stopped due to bad state", mtask.Arn)
-		mtask.handleDesiredStatusChange(apitaskstatus.TaskStopped, 0)
+		mtask.handleDesiredStatusChange(apitaskstatus.TaskStopped, 0, "")
 	}
 }
 
@@ -1126,14 +1382,16 @@ func (mtask *managedTask) waitForStopReported() bool {
 	taskStopped := false
 	go func() {
 		for i := 0; i < _maxStoppedWaitTimes; i++ {
-			// ensure that we block until apitaskstatus.TaskStopped is actually sent
+			// ensure that we block until apitaskstatus.TaskStopped is actually sent,
+			// and that the eventhandler is done with the task once it's sent
 			sentStatus := mtask.GetSentStatus()
-			if sentStatus >= apitaskstatus.TaskStopped {
+			inFlight := mtask.IsStopSubmissionInFlight()
+			if sentStatus >= apitaskstatus.TaskStopped && !inFlight {
 				taskStopped = true
 				break
 			}
-			seelog.Warnf("Managed task [%s]: blocking cleanup until the task has been reported stopped. SentStatus: %s (%d/%d)",
-				mtask.Arn, sentStatus.String(), i+1, _maxStoppedWaitTimes)
+			seelog.Warnf("Managed task [%s]: blocking cleanup until the task has been reported stopped. SentStatus: %s, submission in flight: %t (%d/%d)",
+				mtask.Arn, sentStatus.String(), inFlight, i+1, _maxStoppedWaitTimes)
 			mtask._time.Sleep(_stoppedSentWaitInterval)
 		}
 		stoppedSentBool <- struct{}{}
@@ -34,16 +34,20 @@ import (
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/containermetadata/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dependencygraph"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/engine/hostport"
 	"github.com/aws/amazon-ecs-agent/agent/engine/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/gpu"
 	"github.com/aws/amazon-ecs-agent/agent/statechange"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager/mocks"
@@ -51,6 +55,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/golang/mock/gomock"
 )
@@ -100,6 +105,16 @@ func TestHandleEventError(t *testing.T) {
 			ExpectedContainerDesiredStatusStopped: true,
 			ExpectedOK:                            true,
 		},
+		{
+			Name:                        "Container killed due to memory usage",
+			EventStatus:                 apicontainerstatus.ContainerStopped,
+			CurrentContainerKnownStatus: apicontainerstatus.ContainerRunning,
+			Error: dockerapi.OutOfMemoryError{},
+			ExpectedContainerKnownStatusSet:       true,
+			ExpectedContainerKnownStatus:          apicontainerstatus.ContainerStopped,
+			ExpectedContainerDesiredStatusStopped: true,
+			ExpectedOK:                            true,
+		},
 		{
 			Name:  "Pull failed",
 			Error: &dockerapi.DockerTimeoutError{},
@@ -212,6 +227,45 @@ func TestHandleEventError(t *testing.T) {
 	}
 }
 
+func TestHandleDesiredStatusChangeStopSetsReasonCode(t *testing.T) {
+	mtask := &managedTask{
+		Task: &apitask.Task{
+			Arn:                 "task1",
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		},
+	}
+
+	mtask.handleDesiredStatusChange(apitaskstatus.TaskStopped, 0, "")
+	assert.Equal(t, apitaskstatus.TaskStopped, mtask.GetDesiredStatus())
+	assert.Equal(t, apireason.ReasonCodeUserInitiatedStop, mtask.GetTerminalReasonCode())
+}
+
+func TestHandleDesiredStatusChangeStopRecordsStoppedReason(t *testing.T) {
+	mtask := &managedTask{
+		Task: &apitask.Task{
+			Arn:                 "task1",
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		},
+	}
+
+	mtask.handleDesiredStatusChange(apitaskstatus.TaskStopped, 0, "Task stopped by user")
+	assert.Equal(t, "Task stopped by user", mtask.GetStoppedReason())
+}
+
+func TestHandleDesiredStatusChangeRedundantStopIgnoresStoppedReason(t *testing.T) {
+	mtask := &managedTask{
+		Task: &apitask.Task{
+			Arn:                 "task1",
+			DesiredStatusUnsafe: apitaskstatus.TaskStopped,
+		},
+	}
+
+	// The task was already stopped by the agent, for example because an
+	// essential container exited
+	mtask.handleDesiredStatusChange(apitaskstatus.TaskStopped, 0, "Task stopped by user")
+	assert.Empty(t, mtask.GetStoppedReason())
+}
+
 func TestContainerNextState(t *testing.T) {
 	testCases := []struct {
 		containerCurrentStatus       apicontainerstatus.ContainerStatus
@@ -597,6 +651,98 @@ func TestContainerNextStateWithAvoidingDanglingContainers(t *testing.T) {
 	assert.Equal(t, nil, transition.reason, "Mismatch transition possible")
 }
 
+func TestContainerNextStateWithShutdownOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTime := mock_ttime.NewMockTime(ctrl)
+
+	proxy := &apicontainer.Container{
+		Name:                      "proxy",
+		DesiredStatusUnsafe:       apicontainerstatus.ContainerStopped,
+		KnownStatusUnsafe:         apicontainerstatus.ContainerRunning,
+		TransitionDependenciesMap: make(map[apicontainerstatus.ContainerStatus]apicontainer.TransitionDependencySet),
+		StopTimeout:               10,
+	}
+	app := &apicontainer.Container{
+		Name:                      "app",
+		DesiredStatusUnsafe:       apicontainerstatus.ContainerStopped,
+		KnownStatusUnsafe:         apicontainerstatus.ContainerRunning,
+		TransitionDependenciesMap: make(map[apicontainerstatus.ContainerStatus]apicontainer.TransitionDependencySet),
+		DependsOn: []apicontainer.DependsOn{
+			{ContainerName: "proxy", Condition: apicontainer.DependsOnConditionStart},
+		},
+	}
+	task := &managedTask{
+		Task: &apitask.Task{
+			Containers:          []*apicontainer.Container{proxy, app},
+			DesiredStatusUnsafe: apitaskstatus.TaskStopped,
+		},
+		engine: &DockerTaskEngine{},
+		cfg:    &config.Config{DockerStopTimeout: 30 * time.Second},
+		_time:  mockTime,
+	}
+
+	now := time.Now()
+	gomock.InOrder(
+		mockTime.EXPECT().Now().Return(now),
+		mockTime.EXPECT().Now().Return(now.Add(40*time.Second)),
+		mockTime.EXPECT().Now().Return(now.Add(41*time.Second)),
+	)
+	transition := task.containerNextState(proxy)
+	assert.Equal(t, dependencygraph.ErrContainerShutdownOrderNotResolved, transition.reason)
+	assert.Equal(t, now.Add(40*time.Second), task.shutdownOrderDeadline,
+		"the task's stop timeout should be the sum of the stop timeouts of its containers")
+
+	transition = task.containerNextState(proxy)
+	assert.Equal(t, dependencygraph.ErrContainerShutdownOrderNotResolved, transition.reason)
+
+	transition = task.containerNextState(proxy)
+	assert.NoError(t, transition.reason, "proxy should be stopped once the task's stop timeout elapses")
+	assert.Equal(t, apicontainerstatus.ContainerStopped, transition.nextState)
+	assert.True(t, transition.actionRequired)
+}
+
+func TestContainerNextStateWithContainerOrderingTimeout(t *testing.T) {
+	initContainer := &apicontainer.Container{
+		Name:                "init",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		StartTimeout:        60,
+	}
+	container := &apicontainer.Container{
+		Name:                      "app",
+		Essential:                 true,
+		DesiredStatusUnsafe:       apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:         apicontainerstatus.ContainerCreated,
+		TransitionDependenciesMap: make(map[apicontainerstatus.ContainerStatus]apicontainer.TransitionDependencySet),
+		DependsOn: []apicontainer.DependsOn{
+			{ContainerName: "init", Condition: apicontainer.DependsOnConditionSuccess},
+		},
+	}
+	container.SetCreatedAt(time.Now().Add(-2 * time.Minute))
+
+	task := &managedTask{
+		Task: &apitask.Task{
+			Containers: []*apicontainer.Container{
+				initContainer,
+				container,
+			},
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		},
+		engine: &DockerTaskEngine{},
+		cfg:    &defaultConfig,
+	}
+	transition := task.containerNextState(container)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, transition.nextState)
+	assert.False(t, transition.actionRequired)
+	assert.NoError(t, transition.reason)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, container.GetDesiredStatus())
+	require.NotNil(t, container.ApplyingError)
+	assert.Equal(t, "ContainerOrderingTimeoutError: dependency init did not become SUCCESS within 60s",
+		container.ApplyingError.Error())
+	assert.Equal(t, container.ApplyingError.Error(), task.GetTerminalReason())
+}
+
 func TestStartContainerTransitionsWhenForwardTransitionPossible(t *testing.T) {
 	steadyStates := []apicontainerstatus.ContainerStatus{apicontainerstatus.ContainerRunning, apicontainerstatus.ContainerResourcesProvisioned}
 	for _, steadyState := range steadyStates {
@@ -728,7 +874,13 @@ func TestStartContainerTransitionsInvokesHandleContainerChange(t *testing.T) {
 
 	stateChangeEvents := make(chan statechange.Event)
 
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	imageManager := mock_engine.NewMockImageManager(ctrl)
+	imageManager.EXPECT().RecordContainerStopped(firstContainer)
+
 	task := &managedTask{
+		cfg: &config.Config{},
 		Task: &apitask.Task{
 			Containers: []*apicontainer.Container{
 				firstContainer,
@@ -738,6 +890,7 @@ func TestStartContainerTransitionsInvokesHandleContainerChange(t *testing.T) {
 		engine: &DockerTaskEngine{
 			containerChangeEventStream: containerChangeEventStream,
 			stateChangeEvents:          stateChangeEvents,
+			imageManager:               imageManager,
 		},
 		stateChangeEvents:          stateChangeEvents,
 		containerChangeEventStream: containerChangeEventStream,
@@ -1017,12 +1170,14 @@ func TestCleanupTask(t *testing.T) {
 	defer cancel()
 
 	taskEngine := &DockerTaskEngine{
-		ctx:          ctx,
-		cfg:          &cfg,
-		saver:        statemanager.NewNoopStateManager(),
-		state:        mockState,
-		client:       mockClient,
-		imageManager: mockImageManager,
+		ctx:               ctx,
+		cfg:               &cfg,
+		saver:             statemanager.NewNoopStateManager(),
+		state:             mockState,
+		client:            mockClient,
+		imageManager:      mockImageManager,
+		gpuManager:        gpu.NewManager(nil),
+		hostPortAllocator: hostport.NewAllocator(0, 0),
 	}
 	mTask := &managedTask{
 		ctx:                      ctx,
@@ -1078,12 +1233,14 @@ func TestCleanupTaskWaitsForStoppedSent(t *testing.T) {
 	defer cancel()
 
 	taskEngine := &DockerTaskEngine{
-		ctx:          ctx,
-		cfg:          &cfg,
-		saver:        statemanager.NewNoopStateManager(),
-		state:        mockState,
-		client:       mockClient,
-		imageManager: mockImageManager,
+		ctx:               ctx,
+		cfg:               &cfg,
+		saver:             statemanager.NewNoopStateManager(),
+		state:             mockState,
+		client:            mockClient,
+		imageManager:      mockImageManager,
+		gpuManager:        gpu.NewManager(nil),
+		hostPortAllocator: hostport.NewAllocator(0, 0),
 	}
 	mTask := &managedTask{
 		ctx:                      ctx,
@@ -1135,6 +1292,80 @@ func TestCleanupTaskWaitsForStoppedSent(t *testing.T) {
 	assert.Equal(t, apitaskstatus.TaskStopped, mTask.GetSentStatus())
 }
 
+// TestCleanupTaskWaitsForStopSubmission tests that the containers of the task
+// aren't removed while the eventhandler is still submitting the STOPPED state
+// change of the task, even though the sent status has already been recorded
+func TestCleanupTaskWaitsForStopSubmission(t *testing.T) {
+	cfg := getTestConfig()
+	ctrl := gomock.NewController(t)
+	mockTime := mock_ttime.NewMockTime(ctrl)
+	mockState := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+	mockImageManager := mock_engine.NewMockImageManager(ctrl)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	taskEngine := &DockerTaskEngine{
+		ctx:               ctx,
+		cfg:               &cfg,
+		saver:             statemanager.NewNoopStateManager(),
+		state:             mockState,
+		client:            mockClient,
+		imageManager:      mockImageManager,
+		gpuManager:        gpu.NewManager(nil),
+		hostPortAllocator: hostport.NewAllocator(0, 0),
+	}
+	mTask := &managedTask{
+		ctx:                      ctx,
+		cancel:                   cancel,
+		Task:                     testdata.LoadTask("sleep5"),
+		_time:                    mockTime,
+		engine:                   taskEngine,
+		acsMessages:              make(chan acsTransition),
+		dockerMessages:           make(chan dockerContainerChange),
+		resourceStateChangeEvent: make(chan resourceStateChange),
+		cfg:                      taskEngine.cfg,
+		saver:                    taskEngine.saver,
+	}
+	mTask.SetKnownStatus(apitaskstatus.TaskStopped)
+	// The eventhandler records the sent status before it's done submitting
+	// the state change
+	mTask.StartStopSubmission()
+	mTask.SetSentStatus(apitaskstatus.TaskStopped)
+	container := mTask.Containers[0]
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerName: "dockerContainer",
+	}
+
+	// Expectations for triggering cleanup
+	now := mTask.GetKnownStatusTime()
+	taskStoppedDuration := 1 * time.Minute
+	mockTime.EXPECT().Now().Return(now).AnyTimes()
+	cleanupTimeTrigger := make(chan time.Time)
+	mockTime.EXPECT().After(gomock.Any()).Return(cleanupTimeTrigger)
+	go func() {
+		cleanupTimeTrigger <- now
+	}()
+	// The submission completes while cleanup is waiting for it
+	mockTime.EXPECT().Sleep(gomock.Any()).Do(func(_ interface{}) {
+		mTask.CompleteStopSubmission()
+	})
+
+	// Expectations to verify that the task gets removed
+	mockState.EXPECT().ContainerMapByArn(mTask.Arn).Return(
+		map[string]*apicontainer.DockerContainer{container.Name: dockerContainer}, true)
+	mockClient.EXPECT().RemoveContainer(gomock.Any(), dockerContainer.DockerName, gomock.Any()).Do(
+		func(_ interface{}, _ string, _ time.Duration) {
+			assert.False(t, mTask.IsStopSubmissionInFlight(),
+				"containers shouldn't be removed while the STOPPED state change is being submitted")
+		}).Return(nil)
+	mockImageManager.EXPECT().RemoveContainerReferenceFromImageState(container).Return(nil)
+	mockState.EXPECT().RemoveTask(mTask.Task)
+	mTask.cleanupTask(taskStoppedDuration)
+}
+
 func TestCleanupTaskGivesUpIfWaitingTooLong(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	mockTime := mock_ttime.NewMockTime(ctrl)
@@ -1147,12 +1378,14 @@ func TestCleanupTaskGivesUpIfWaitingTooLong(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
 	taskEngine := &DockerTaskEngine{
-		ctx:          ctx,
-		cfg:          &cfg,
-		saver:        statemanager.NewNoopStateManager(),
-		state:        mockState,
-		client:       mockClient,
-		imageManager: mockImageManager,
+		ctx:               ctx,
+		cfg:               &cfg,
+		saver:             statemanager.NewNoopStateManager(),
+		state:             mockState,
+		client:            mockClient,
+		imageManager:      mockImageManager,
+		gpuManager:        gpu.NewManager(nil),
+		hostPortAllocator: hostport.NewAllocator(0, 0),
 	}
 	mTask := &managedTask{
 		ctx:            ctx,
@@ -1202,12 +1435,14 @@ func TestCleanupTaskENIs(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
 	taskEngine := &DockerTaskEngine{
-		ctx:          ctx,
-		cfg:          &cfg,
-		saver:        statemanager.NewNoopStateManager(),
-		state:        mockState,
-		client:       mockClient,
-		imageManager: mockImageManager,
+		ctx:               ctx,
+		cfg:               &cfg,
+		saver:             statemanager.NewNoopStateManager(),
+		state:             mockState,
+		client:            mockClient,
+		imageManager:      mockImageManager,
+		gpuManager:        gpu.NewManager(nil),
+		hostPortAllocator: hostport.NewAllocator(0, 0),
 	}
 	mTask := &managedTask{
 		ctx:                      ctx,
@@ -1332,12 +1567,14 @@ func TestCleanupTaskWithInvalidInterval(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
 	taskEngine := &DockerTaskEngine{
-		ctx:          ctx,
-		cfg:          &cfg,
-		saver:        statemanager.NewNoopStateManager(),
-		state:        mockState,
-		client:       mockClient,
-		imageManager: mockImageManager,
+		ctx:               ctx,
+		cfg:               &cfg,
+		saver:             statemanager.NewNoopStateManager(),
+		state:             mockState,
+		client:            mockClient,
+		imageManager:      mockImageManager,
+		gpuManager:        gpu.NewManager(nil),
+		hostPortAllocator: hostport.NewAllocator(0, 0),
 	}
 	mTask := &managedTask{
 		ctx:                      ctx,
@@ -1390,12 +1627,14 @@ func TestCleanupTaskWithResourceHappyPath(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
 	taskEngine := &DockerTaskEngine{
-		ctx:          ctx,
-		cfg:          &cfg,
-		saver:        statemanager.NewNoopStateManager(),
-		state:        mockState,
-		client:       mockClient,
-		imageManager: mockImageManager,
+		ctx:               ctx,
+		cfg:               &cfg,
+		saver:             statemanager.NewNoopStateManager(),
+		state:             mockState,
+		client:            mockClient,
+		imageManager:      mockImageManager,
+		gpuManager:        gpu.NewManager(nil),
+		hostPortAllocator: hostport.NewAllocator(0, 0),
 	}
 	mockResource := mock_taskresource.NewMockTaskResource(ctrl)
 	mTask := &managedTask{
@@ -1452,12 +1691,14 @@ func TestCleanupTaskWithResourceErrorPath(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
 	taskEngine := &DockerTaskEngine{
-		ctx:          ctx,
-		cfg:          &cfg,
-		saver:        statemanager.NewNoopStateManager(),
-		state:        mockState,
-		client:       mockClient,
-		imageManager: mockImageManager,
+		ctx:               ctx,
+		cfg:               &cfg,
+		saver:             statemanager.NewNoopStateManager(),
+		state:             mockState,
+		client:            mockClient,
+		imageManager:      mockImageManager,
+		gpuManager:        gpu.NewManager(nil),
+		hostPortAllocator: hostport.NewAllocator(0, 0),
 	}
 	mockResource := mock_taskresource.NewMockTaskResource(ctrl)
 	mTask := &managedTask{
@@ -1512,6 +1753,7 @@ func TestHandleContainerChangeUpdateContainerHealth(t *testing.T) {
 		Task: testdata.LoadTask("sleep5TaskCgroup"),
 		containerChangeEventStream: containerChangeEventStream,
 		stateChangeEvents:          make(chan statechange.Event),
+		cfg:                        &config.Config{},
 	}
 	// Disgard all the statechange events
 	defer discardEvents(mTask.stateChangeEvents)()
@@ -1542,6 +1784,389 @@ func TestHandleContainerChangeUpdateContainerHealth(t *testing.T) {
 	assert.Equal(t, containerHealth.Output, "health check succeed")
 }
 
+// TestHandleContainerChangeOutOfMemory tests that the reason of an essential
+// container killed due to memory usage is propagated to the task state change
+func TestHandleContainerChangeOutOfMemory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	containerChangeEventStream := eventstream.NewEventStream("TestHandleContainerChangeOutOfMemory", ctx)
+	containerChangeEventStream.StartListening()
+
+	container := &apicontainer.Container{
+		Name:                "essential",
+		Essential:           true,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		SentStatusUnsafe:    apicontainerstatus.ContainerRunning,
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	imageManager := mock_engine.NewMockImageManager(ctrl)
+	// The stop of the container updates the last used time of its image
+	imageManager.EXPECT().RecordContainerStopped(container)
+	mTask := &managedTask{
+		cfg:    &config.Config{},
+		engine: &DockerTaskEngine{imageManager: imageManager},
+		Task: &apitask.Task{
+			Arn:                 "task1",
+			KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+			SentStatusUnsafe:    apitaskstatus.TaskRunning,
+			Containers:          []*apicontainer.Container{container},
+		},
+		containerChangeEventStream: containerChangeEventStream,
+		stateChangeEvents:          make(chan statechange.Event, 2),
+	}
+
+	exitCode := 137
+	mTask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: dockerapi.DockerContainerChangeEvent{
+			Status: apicontainerstatus.ContainerStopped,
+			DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+				DockerID: "dockerID",
+				ExitCode: &exitCode,
+				Error:    dockerapi.OutOfMemoryError{},
+			},
+		},
+	})
+
+	require.Len(t, mTask.stateChangeEvents, 2)
+	containerEvent, ok := (<-mTask.stateChangeEvents).(api.ContainerStateChange)
+	require.True(t, ok, "expected a container state change")
+	assert.Equal(t, apicontainerstatus.ContainerStopped, containerEvent.Status)
+	assert.Equal(t, "OutOfMemoryError: Container killed due to memory usage", containerEvent.Reason)
+	assert.Equal(t, apireason.ReasonCodeOutOfMemory, containerEvent.ReasonCode)
+	latestExit, ok := container.GetLatestExit()
+	require.True(t, ok, "expected the exit to be recorded")
+	assert.True(t, latestExit.OutOfMemory)
+	assert.Equal(t, "Container killed due to memory usage", latestExit.Reason)
+	assert.Equal(t, latestExit.ExitCode, containerEvent.ExitCode)
+
+	taskEvent, ok := (<-mTask.stateChangeEvents).(api.TaskStateChange)
+	require.True(t, ok, "expected a task state change")
+	assert.Equal(t, apitaskstatus.TaskStopped, taskEvent.Status)
+	assert.Equal(t, "OutOfMemoryError: Container killed due to memory usage", taskEvent.Reason)
+}
+
+// TestHandleContainerChangeUpdatesMetadataFile tests that the metadata file of
+// the container is rewritten as the container transitions
+func TestHandleContainerChangeUpdatesMetadataFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	containerChangeEventStream := eventstream.NewEventStream("TestHandleContainerChangeUpdatesMetadataFile", ctx)
+	containerChangeEventStream.StartListening()
+
+	container := &apicontainer.Container{
+		Name:                "container",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerPulled,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	task := &apitask.Task{
+		Arn:                 "task1",
+		KnownStatusUnsafe:   apitaskstatus.TaskPulled,
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		Containers:          []*apicontainer.Container{container},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	metadataManager := mock_containermetadata.NewMockManager(ctrl)
+	mTask := &managedTask{
+		Task: task,
+		cfg:  &config.Config{ContainerMetadataEnabled: true},
+		engine: &DockerTaskEngine{
+			ctx:                 ctx,
+			state:               state,
+			metadataManager:     metadataManager,
+			metadataFileUpdates: make(map[*apicontainer.Container]bool),
+		},
+		containerChangeEventStream: containerChangeEventStream,
+		stateChangeEvents:          make(chan statechange.Event, 2),
+	}
+
+	updated := make(chan struct{})
+	state.EXPECT().ContainerMapByArn(task.Arn).Return(map[string]*apicontainer.DockerContainer{
+		container.Name: {DockerID: "dockerID", Container: container},
+	}, true)
+	metadataManager.EXPECT().Update(gomock.Any(), "dockerID", task, container).Do(
+		func(ctx context.Context, dockerID string, task *apitask.Task, container *apicontainer.Container) {
+			assert.Equal(t, apicontainerstatus.ContainerCreated, container.GetKnownStatus())
+			close(updated)
+		}).Return(nil)
+
+	mTask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: dockerapi.DockerContainerChangeEvent{
+			Status: apicontainerstatus.ContainerCreated,
+			DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+				DockerID: "dockerID",
+			},
+		},
+	})
+
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("expected the metadata file of the container to be updated")
+	}
+	// The metadata file isn't known to be updated until the container runs
+	assert.False(t, container.IsMetadataFileUpdated())
+}
+
+func TestHandleContainerChangeRestartsContainer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(client)
+
+	container := &apicontainer.Container{
+		Name:                "sidecar",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		SentStatusUnsafe:    apicontainerstatus.ContainerRunning,
+		RestartPolicy:       &apicontainer.RestartPolicy{Attempts: 1},
+	}
+	app := &apicontainer.Container{
+		Name:                "app",
+		Essential:           true,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		SentStatusUnsafe:    apicontainerstatus.ContainerRunning,
+	}
+	task := &apitask.Task{
+		Arn:                 "task1",
+		KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		SentStatusUnsafe:    apitaskstatus.TaskRunning,
+		Containers:          []*apicontainer.Container{container, app},
+	}
+	engine := taskEngine.(*DockerTaskEngine)
+	engine.State().AddTask(task)
+	engine.State().AddContainer(&apicontainer.DockerContainer{
+		DockerID:  "dockerID",
+		Container: container,
+	}, task)
+	containerChangeEventStream := eventstream.NewEventStream("TestHandleContainerChangeRestartsContainer", ctx)
+	containerChangeEventStream.StartListening()
+	mTask := &managedTask{
+		cfg:                        &config.Config{},
+		Task:                       task,
+		ctx:                        ctx,
+		engine:                     engine,
+		containerChangeEventStream: containerChangeEventStream,
+		stateChangeEvents:          make(chan statechange.Event, 1),
+	}
+
+	restarted := make(chan struct{})
+	client.EXPECT().StartContainer(gomock.Any(), "dockerID", defaultConfig.ContainerStartTimeout).Do(
+		func(ctx interface{}, id string, timeout time.Duration) {
+			close(restarted)
+		}).Return(dockerapi.DockerContainerMetadata{DockerID: "dockerID"})
+
+	exitCode := 1
+	exitEvent := dockerContainerChange{
+		container: container,
+		event: dockerapi.DockerContainerChangeEvent{
+			Status: apicontainerstatus.ContainerStopped,
+			DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+				DockerID: "dockerID",
+				ExitCode: &exitCode,
+			},
+		},
+	}
+	mTask.handleContainerChange(exitEvent)
+	<-restarted
+	assert.Equal(t, apicontainerstatus.ContainerRunning, container.GetKnownStatus())
+	assert.Equal(t, 1, container.GetRestartCount())
+	assert.Len(t, mTask.stateChangeEvents, 0, "restarting the container shouldn't be reported")
+	assert.Len(t, container.GetExitHistory(), 1, "exits followed by a restart should be recorded")
+
+	// The container isn't restarted again once its restart attempts are used
+	mTask.handleContainerChange(exitEvent)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, container.GetKnownStatus())
+	assert.Equal(t, 1, container.GetRestartCount())
+	assert.Len(t, container.GetExitHistory(), 2)
+	require.Len(t, mTask.stateChangeEvents, 1)
+	containerEvent, ok := (<-mTask.stateChangeEvents).(api.ContainerStateChange)
+	require.True(t, ok, "expected a container state change")
+	assert.Equal(t, apicontainerstatus.ContainerStopped, containerEvent.Status)
+	assert.Equal(t, "ContainerRestartsExhaustedError: Container exited after exhausting its 1 restart attempts",
+		containerEvent.Reason)
+	assert.Equal(t, apitaskstatus.TaskRunning, task.GetKnownStatus())
+}
+
+func TestRestartedContainerHealthStartsOver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctrl, client, _, taskEngine, _, _, _ := mocks(t, ctx, &defaultConfig)
+	defer ctrl.Finish()
+	expectContainerWaitUntilTaskEnds(client)
+
+	container := &apicontainer.Container{
+		Name:                "sidecar",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		RestartPolicy:       &apicontainer.RestartPolicy{Attempts: 1},
+		HealthCheck: &apicontainer.HealthCheck{
+			Command:     []string{"CMD-SHELL", "exit 0"},
+			StartPeriod: 60,
+		},
+	}
+	container.SetHealthStatus(apicontainer.HealthStatus{Status: apicontainerstatus.ContainerUnhealthy})
+	task := &apitask.Task{
+		Arn:                 "task1",
+		KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		Containers:          []*apicontainer.Container{container},
+	}
+	engine := taskEngine.(*DockerTaskEngine)
+	engine.State().AddTask(task)
+	engine.State().AddContainer(&apicontainer.DockerContainer{
+		DockerID:  "dockerID",
+		Container: container,
+	}, task)
+	mTask := &managedTask{
+		Task:   task,
+		ctx:    ctx,
+		engine: engine,
+	}
+
+	client.EXPECT().StartContainer(gomock.Any(), "dockerID", gomock.Any()).Return(
+		dockerapi.DockerContainerMetadata{DockerID: "dockerID"})
+
+	require.True(t, mTask.restartContainerIfAllowed(container, dockerapi.DockerContainerChangeEvent{
+		Status: apicontainerstatus.ContainerStopped,
+	}, apicontainerstatus.ContainerRunning))
+	// The unhealthy status of the previous run is forgotten once the container
+	// is restarted
+	for container.GetHealthStatus().Status != apicontainerstatus.ContainerHealthUnknown {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRestartContainerIfAllowed(t *testing.T) {
+	ignoredExitCode := 0
+	failedExitCode := 1
+	testCases := []struct {
+		name              string
+		essential         bool
+		policy            *apicontainer.RestartPolicy
+		exitCode          *int
+		startedAt         time.Time
+		desiredStatus     apicontainerstatus.ContainerStatus
+		taskDesiredStatus apitaskstatus.TaskStatus
+	}{
+		{
+			name:              "no restart policy",
+			exitCode:          &failedExitCode,
+			desiredStatus:     apicontainerstatus.ContainerRunning,
+			taskDesiredStatus: apitaskstatus.TaskRunning,
+		},
+		{
+			name:              "essential container",
+			essential:         true,
+			policy:            &apicontainer.RestartPolicy{Attempts: 1},
+			exitCode:          &failedExitCode,
+			desiredStatus:     apicontainerstatus.ContainerRunning,
+			taskDesiredStatus: apitaskstatus.TaskRunning,
+		},
+		{
+			name:              "ignored exit code",
+			policy:            &apicontainer.RestartPolicy{Attempts: 1, IgnoredExitCodes: []int{ignoredExitCode}},
+			exitCode:          &ignoredExitCode,
+			desiredStatus:     apicontainerstatus.ContainerRunning,
+			taskDesiredStatus: apitaskstatus.TaskRunning,
+		},
+		{
+			name:              "exited within restart attempt period",
+			policy:            &apicontainer.RestartPolicy{Attempts: 1, RestartAttemptPeriod: 60},
+			exitCode:          &failedExitCode,
+			startedAt:         time.Now(),
+			desiredStatus:     apicontainerstatus.ContainerRunning,
+			taskDesiredStatus: apitaskstatus.TaskRunning,
+		},
+		{
+			name:              "container being stopped",
+			policy:            &apicontainer.RestartPolicy{Attempts: 1},
+			exitCode:          &failedExitCode,
+			desiredStatus:     apicontainerstatus.ContainerStopped,
+			taskDesiredStatus: apitaskstatus.TaskRunning,
+		},
+		{
+			name:              "task being stopped",
+			policy:            &apicontainer.RestartPolicy{Attempts: 1},
+			exitCode:          &failedExitCode,
+			desiredStatus:     apicontainerstatus.ContainerRunning,
+			taskDesiredStatus: apitaskstatus.TaskStopped,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			container := &apicontainer.Container{
+				Name:                "container",
+				Essential:           tc.essential,
+				RestartPolicy:       tc.policy,
+				DesiredStatusUnsafe: tc.desiredStatus,
+			}
+			container.SetStartedAt(tc.startedAt)
+			mTask := &managedTask{
+				Task: &apitask.Task{
+					Arn:                 "task1",
+					DesiredStatusUnsafe: tc.taskDesiredStatus,
+					Containers:          []*apicontainer.Container{container},
+				},
+			}
+			event := dockerapi.DockerContainerChangeEvent{
+				Status: apicontainerstatus.ContainerStopped,
+				DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+					ExitCode: tc.exitCode,
+				},
+			}
+			assert.False(t, mTask.restartContainerIfAllowed(container, event, apicontainerstatus.ContainerRunning))
+			assert.Equal(t, 0, container.GetRestartCount())
+			assert.Nil(t, container.ApplyingError)
+		})
+	}
+}
+
+func TestRestartContainerIfAllowedUsesTaskClock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTime := mock_ttime.NewMockTime(ctrl)
+
+	failedExitCode := 1
+	// The container started long ago by the wall clock, but only 30 seconds
+	// ago by the clock of the task
+	startedAt := time.Now().Add(-time.Hour)
+	container := &apicontainer.Container{
+		Name:                "container",
+		RestartPolicy:       &apicontainer.RestartPolicy{Attempts: 1, RestartAttemptPeriod: 60},
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	container.SetStartedAt(startedAt)
+	mTask := &managedTask{
+		Task: &apitask.Task{
+			Arn:                 "task1",
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+			Containers:          []*apicontainer.Container{container},
+		},
+		_time: mockTime,
+	}
+	mockTime.EXPECT().Now().Return(startedAt.Add(30 * time.Second))
+
+	event := dockerapi.DockerContainerChangeEvent{
+		Status: apicontainerstatus.ContainerStopped,
+		DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+			ExitCode: &failedExitCode,
+		},
+	}
+	assert.False(t, mTask.restartContainerIfAllowed(container, event, apicontainerstatus.ContainerRunning))
+	assert.Equal(t, 0, container.GetRestartCount())
+}
+
 func TestWaitForHostResources(t *testing.T) {
 	taskStopWG := utilsync.NewSequentialWaitGroup()
 	taskStopWG.Add(1, 1)
@@ -1755,6 +2380,38 @@ func TestHandleVolumeResourceStateChangeNoSave(t *testing.T) {
 	}
 }
 
+func TestHandleVolumeResourceCreationErrorSetsTerminalReason(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockSaver := mock_statemanager.NewMockStateManager(ctrl)
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	client.EXPECT().CreateVolume(gomock.Any(), "dockerVolume", "driver", nil, nil, dockerapi.CreateVolumeTimeout).Return(
+		dockerapi.VolumeResponse{Error: errors.New("driver error")})
+	mockSaver.EXPECT().Save()
+
+	res, err := volume.NewVolumeResource(context.TODO(), "vol", "dockerVolume", volume.TaskScope, false,
+		"driver", nil, nil, client)
+	require.NoError(t, err)
+	mtask := managedTask{
+		Task: &apitask.Task{
+			Arn:                 "task1",
+			ResourcesMapUnsafe:  make(map[string][]taskresource.TaskResource),
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		},
+		engine: &DockerTaskEngine{},
+	}
+	mtask.AddResource("vol", res)
+	mtask.engine.SetSaver(mockSaver)
+
+	createErr := res.ApplyTransition(resourcestatus.ResourceStatus(volume.VolumeCreated))
+	mtask.handleResourceStateChange(resourceStateChange{
+		res, resourcestatus.ResourceStatus(volume.VolumeCreated), createErr,
+	})
+	assert.Equal(t, apitaskstatus.TaskStopped, mtask.GetDesiredStatus())
+	assert.Contains(t, mtask.GetTerminalReason(), "driver error")
+}
+
 func TestVolumeResourceNextState(t *testing.T) {
 	testCases := []struct {
 		Name             string
@@ -1957,3 +2614,61 @@ func TestContainerNextStateDependsStoppedContainer(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanupWaitDuration(t *testing.T) {
+	cfg := getTestConfig()
+	cfg.TaskCleanupWaitDuration = 3 * time.Hour
+	testCases := []struct {
+		name             string
+		durationSeconds  int64
+		expectedDuration time.Duration
+	}{
+		{
+			name:             "not set",
+			expectedDuration: 3 * time.Hour,
+		},
+		{
+			name:             "within bounds",
+			durationSeconds:  600,
+			expectedDuration: 10 * time.Minute,
+		},
+		{
+			name:             "minimum",
+			durationSeconds:  60,
+			expectedDuration: time.Minute,
+		},
+		{
+			name:             "maximum",
+			durationSeconds:  7 * 24 * 60 * 60,
+			expectedDuration: 7 * 24 * time.Hour,
+		},
+		{
+			name:             "too short",
+			durationSeconds:  59,
+			expectedDuration: 3 * time.Hour,
+		},
+		{
+			name:             "too long",
+			durationSeconds:  7*24*60*60 + 1,
+			expectedDuration: 3 * time.Hour,
+		},
+		{
+			name:             "negative",
+			durationSeconds:  -1,
+			expectedDuration: 3 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mTask := &managedTask{
+				Task: &apitask.Task{
+					Arn:                        "task1",
+					CleanupWaitDurationSeconds: tc.durationSeconds,
+				},
+				cfg: &cfg,
+			}
+			assert.Equal(t, tc.expectedDuration, mTask.cleanupWaitDuration())
+		})
+	}
+}
@@ -72,3 +72,15 @@ func (m *MockNetLink) LinkList() ([]netlink.Link, error) {
 func (mr *MockNetLinkMockRecorder) LinkList() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkList", reflect.TypeOf((*MockNetLink)(nil).LinkList))
 }
+
+// LinkSubscribe mocks base method
+func (m *MockNetLink) LinkSubscribe(arg0 chan<- netlink.LinkUpdate, arg1 <-chan struct{}) error {
+	ret := m.ctrl.Call(m, "LinkSubscribe", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkSubscribe indicates an expected call of LinkSubscribe
+func (mr *MockNetLinkMockRecorder) LinkSubscribe(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkSubscribe", reflect.TypeOf((*MockNetLink)(nil).LinkSubscribe), arg0, arg1)
+}
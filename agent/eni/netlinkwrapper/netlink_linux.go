@@ -21,6 +21,7 @@ import "github.com/vishvananda/netlink"
 type NetLink interface {
 	LinkByName(name string) (netlink.Link, error)
 	LinkList() ([]netlink.Link, error)
+	LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error
 }
 
 // NetLinkClient helps invoke the actual netlink methods
@@ -40,3 +41,9 @@ func (NetLinkClient) LinkByName(name string) (netlink.Link, error) {
 func (NetLinkClient) LinkList() ([]netlink.Link, error) {
 	return netlink.LinkList()
 }
+
+// LinkSubscribe sends the updates of link devices to the channel until done is
+// closed. Equivalent to: `ip monitor link`
+func (NetLinkClient) LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+	return netlink.LinkSubscribe(ch, done)
+}
@@ -29,15 +29,47 @@ import (
 	"github.com/pkg/errors"
 )
 
-// LoadImage helps load the pause container image for the agent
+// LoadImage helps load the pause container image for the agent. The image is
+// only loaded from the tarball if it isn't already present locally
 func (*loader) LoadImage(ctx context.Context, cfg *config.Config, dockerClient dockerapi.DockerClient) (*docker.Image, error) {
+	return loadImage(ctx, cfg, dockerClient, os.Default)
+}
+
+func loadImage(ctx context.Context, cfg *config.Config, dockerClient dockerapi.DockerClient, fs os.FileSystem) (*docker.Image, error) {
+	image, err := getPauseContainerImage(cfg.PauseContainerImageName, cfg.PauseContainerTag, dockerClient)
+	if err == nil {
+		log.Infof("Pause container image %s:%s is present locally, skipping the load of the tarball",
+			cfg.PauseContainerImageName, cfg.PauseContainerTag)
+		verifyImageID(cfg, image)
+		return image, nil
+	}
+
 	log.Debugf("Loading pause container tarball: %s", cfg.PauseContainerTarballPath)
-	if err := loadFromFile(ctx, cfg.PauseContainerTarballPath, dockerClient, os.Default); err != nil {
+	if err := loadFromFile(ctx, cfg.PauseContainerTarballPath, dockerClient, fs); err != nil {
+		return nil, err
+	}
+
+	image, err = getPauseContainerImage(cfg.PauseContainerImageName, cfg.PauseContainerTag, dockerClient)
+	if err != nil {
 		return nil, err
 	}
+	verifyImageID(cfg, image)
+	return image, nil
+}
 
-	return getPauseContainerImage(
-		config.DefaultPauseContainerImageName, config.DefaultPauseContainerTag, dockerClient)
+// verifyImageID logs a warning if the id of the pause container image differs
+// from the id of the image bundled with the agent. Images other than the
+// bundled one aren't verified
+func verifyImageID(cfg *config.Config, image *docker.Image) {
+	if config.DefaultPauseContainerImageID == "" || image == nil ||
+		cfg.PauseContainerImageName != config.DefaultPauseContainerImageName ||
+		cfg.PauseContainerTag != config.DefaultPauseContainerTag {
+		return
+	}
+	if image.ID != config.DefaultPauseContainerImageID {
+		log.Warnf("Pause container image %s:%s has id %s, expected %s",
+			cfg.PauseContainerImageName, cfg.PauseContainerTag, image.ID, config.DefaultPauseContainerImageID)
+	}
 }
 
 func loadFromFile(ctx context.Context, path string, dockerClient dockerapi.DockerClient, fs os.FileSystem) error {
@@ -26,6 +26,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockeriface/mocks"
 
+	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
@@ -141,3 +142,134 @@ func TestGetPauseContainerHappyPath(t *testing.T) {
 	_, err = getPauseContainerImage(pauseName, pauseTag, client)
 	assert.NoError(t, err)
 }
+
+// TestLoadImageLocalImage tests that the tarball isn't loaded when the pause
+// container image is present locally
+func TestLoadImageLocalImage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDocker := mock_dockeriface.NewMockClient(ctrl)
+	mockDocker.EXPECT().Ping().AnyTimes().Return(nil)
+	factory := mock_clientfactory.NewMockFactory(ctrl)
+	factory.EXPECT().GetDefaultClient().AnyTimes().Return(mockDocker, nil)
+	client, err := dockerapi.NewDockerGoClient(factory, &defaultConfig)
+	assert.NoError(t, err)
+
+	mockDocker.EXPECT().InspectImage(pauseName+":"+pauseTag).Return(&docker.Image{ID: "id"}, nil)
+	mockfs := mock_os.NewMockFileSystem(ctrl)
+
+	cfg := &config.Config{
+		PauseContainerTarballPath: pauseTarballPath,
+		PauseContainerImageName:   pauseName,
+		PauseContainerTag:         pauseTag,
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	image, err := loadImage(ctx, cfg, client, mockfs)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", image.ID)
+}
+
+// TestLoadImageFromTarball tests that the tarball is loaded when the pause
+// container image isn't present locally
+func TestLoadImageFromTarball(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDocker := mock_dockeriface.NewMockClient(ctrl)
+	mockDocker.EXPECT().Ping().AnyTimes().Return(nil)
+	factory := mock_clientfactory.NewMockFactory(ctrl)
+	factory.EXPECT().GetDefaultClient().AnyTimes().Return(mockDocker, nil)
+	client, err := dockerapi.NewDockerGoClient(factory, &defaultConfig)
+	assert.NoError(t, err)
+
+	mockfs := mock_os.NewMockFileSystem(ctrl)
+	gomock.InOrder(
+		mockDocker.EXPECT().InspectImage(pauseName+":"+pauseTag).Return(nil, docker.ErrNoSuchImage),
+		mockfs.EXPECT().Open(pauseTarballPath).Return(nil, nil),
+		mockDocker.EXPECT().LoadImage(gomock.Any()).Return(nil),
+		mockDocker.EXPECT().InspectImage(pauseName+":"+pauseTag).Return(&docker.Image{ID: "id"}, nil),
+	)
+
+	cfg := &config.Config{
+		PauseContainerTarballPath: pauseTarballPath,
+		PauseContainerImageName:   pauseName,
+		PauseContainerTag:         pauseTag,
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	image, err := loadImage(ctx, cfg, client, mockfs)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", image.ID)
+}
+
+// TestLoadImageNotFound tests that a NoSuchFileError is returned when the pause
+// container image is neither present locally nor bundled in a tarball
+func TestLoadImageNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDocker := mock_dockeriface.NewMockClient(ctrl)
+	mockDocker.EXPECT().Ping().AnyTimes().Return(nil)
+	factory := mock_clientfactory.NewMockFactory(ctrl)
+	factory.EXPECT().GetDefaultClient().AnyTimes().Return(mockDocker, nil)
+	client, err := dockerapi.NewDockerGoClient(factory, &defaultConfig)
+	assert.NoError(t, err)
+
+	mockfs := mock_os.NewMockFileSystem(ctrl)
+	gomock.InOrder(
+		mockDocker.EXPECT().InspectImage(pauseName+":"+pauseTag).Return(nil, docker.ErrNoSuchImage),
+		mockfs.EXPECT().Open(pauseTarballPath).Return(nil, errors.New(noSuchFile)),
+	)
+
+	cfg := &config.Config{
+		PauseContainerTarballPath: pauseTarballPath,
+		PauseContainerImageName:   pauseName,
+		PauseContainerTag:         pauseTag,
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err = loadImage(ctx, cfg, client, mockfs)
+	assert.Error(t, err)
+	assert.True(t, IsNoSuchFileError(err))
+}
+
+// TestLoadImageUnexpectedImageID tests that an image with an unexpected id is
+// still used
+func TestLoadImageUnexpectedImageID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	defaultName, defaultTag, defaultID := config.DefaultPauseContainerImageName,
+		config.DefaultPauseContainerTag, config.DefaultPauseContainerImageID
+	config.DefaultPauseContainerImageName = pauseName
+	config.DefaultPauseContainerTag = pauseTag
+	config.DefaultPauseContainerImageID = "expected"
+	defer func() {
+		config.DefaultPauseContainerImageName = defaultName
+		config.DefaultPauseContainerTag = defaultTag
+		config.DefaultPauseContainerImageID = defaultID
+	}()
+
+	mockDocker := mock_dockeriface.NewMockClient(ctrl)
+	mockDocker.EXPECT().Ping().AnyTimes().Return(nil)
+	factory := mock_clientfactory.NewMockFactory(ctrl)
+	factory.EXPECT().GetDefaultClient().AnyTimes().Return(mockDocker, nil)
+	client, err := dockerapi.NewDockerGoClient(factory, &defaultConfig)
+	assert.NoError(t, err)
+
+	mockDocker.EXPECT().InspectImage(pauseName+":"+pauseTag).Return(&docker.Image{ID: "unexpected"}, nil)
+	mockfs := mock_os.NewMockFileSystem(ctrl)
+
+	cfg := &config.Config{
+		PauseContainerTarballPath: pauseTarballPath,
+		PauseContainerImageName:   pauseName,
+		PauseContainerTag:         pauseTag,
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	image, err := loadImage(ctx, cfg, client, mockfs)
+	assert.NoError(t, err)
+	assert.Equal(t, "unexpected", image.ID)
+}
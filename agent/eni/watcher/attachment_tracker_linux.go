@@ -0,0 +1,370 @@
+// +build linux
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eni/netlinkwrapper"
+	"github.com/aws/amazon-ecs-agent/agent/eni/networkutils"
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+)
+
+const (
+	// linkTypeDevice defines the string that's expected to be the output of
+	// netlink.Link.Type() method for netlink.Device type
+	linkTypeDevice = "device"
+
+	// encapTypeLoopback defines the string that's set for the link.Attrs.EncapType
+	// field for localhost devices. The EncapType field defines the link
+	// encapsulation method. For localhost, it's set to "loopback"
+	encapTypeLoopback = "loopback"
+
+	// sendENIStateChangeRetryTimeout specifies the timeout before giving up
+	// when looking for ENI in agent's state. If for whatever reason, the message
+	// from ACS is received after the ENI has been attached to the instance, this
+	// timeout duration will be used to wait for ENI message to be sent from ACS
+	sendENIStateChangeRetryTimeout = 3 * time.Second
+
+	// sendENIStateChangeBackoffMin specifies minimum value for backoff when
+	// waiting for attachment message from ACS
+	sendENIStateChangeBackoffMin = 100 * time.Millisecond
+
+	// sendENIStateChangeBackoffMax specifies maximum value for backoff when
+	// waiting for attachment message from ACS
+	sendENIStateChangeBackoffMax = 250 * time.Millisecond
+
+	// sendENIStateChangeBackoffJitter specifies the jitter multiple percentage
+	// when waiting for attachment message from ACS
+	sendENIStateChangeBackoffJitter = 0.2
+
+	// sendENIStateChangeBackoffMultiple specifies the backoff duration multipler
+	// when waiting for the attachment message from ACS
+	sendENIStateChangeBackoffMultiple = 1.5
+
+	// macAddressRetryTimeout specifies the timeout before giving up when
+	// looking for an ENI's mac address on the host. It takes a few milliseconds
+	// for the host to learn about an ENIs mac address from netlink.LinkList().
+	// We are capping off this duration to 1s assuming worst-case behavior
+	macAddressRetryTimeout = 2 * time.Second
+
+	// deadlineScanInterval is how often the ENI attachments are checked for
+	// attachments that are about to expire without having been found
+	deadlineScanInterval = time.Second
+
+	// deadlineScanWindow is how long before the expiration of an ENI
+	// attachment the network interfaces are scanned from sysfs on every
+	// deadlineScanInterval, in case the event of its interface was missed
+	deadlineScanWindow = 10 * time.Second
+
+	// sysfsNetPath is the directory of the network interfaces in sysfs
+	sysfsNetPath = "/sys/class/net"
+)
+
+// unmanagedENIError is used to indicate that the agent found an ENI, but the agent isn't
+// aware if this ENI is being managed by ECS
+type unmanagedENIError struct {
+	mac string
+}
+
+// Error returns the error string for the unmanagedENIError type
+func (err *unmanagedENIError) Error() string {
+	return fmt.Sprintf("udev watcher send ENI state change: eni not managed by ecs: %s", err.mac)
+}
+
+// attachmentTracker matches the network interfaces attached to the instance
+// with the ENI attachments sent by ACS, and sends the state changes of the
+// attachments once their interfaces are found. It's shared by the watchers of
+// the sources of network interface events
+type attachmentTracker struct {
+	ctx                  context.Context
+	cancel               context.CancelFunc
+	updateIntervalTicker *time.Ticker
+	netlinkClient        netlinkwrapper.NetLink
+	agentState           dockerstate.TaskEngineState
+	eniChangeEvent       chan<- statechange.Event
+	primaryMAC           string
+	// sysfsNetPath is the directory the network interfaces are scanned from
+	// as the deadlines of ENI attachments approach
+	sysfsNetPath string
+}
+
+func newAttachmentTracker(ctx context.Context,
+	primaryMAC string,
+	nlWrap netlinkwrapper.NetLink,
+	state dockerstate.TaskEngineState,
+	stateChangeEvents chan<- statechange.Event) *attachmentTracker {
+
+	derivedContext, cancel := context.WithCancel(ctx)
+	return &attachmentTracker{
+		ctx:            derivedContext,
+		cancel:         cancel,
+		netlinkClient:  nlWrap,
+		agentState:     state,
+		eniChangeEvent: stateChangeEvents,
+		primaryMAC:     primaryMAC,
+		sysfsNetPath:   sysfsNetPath,
+	}
+}
+
+// Init initializes a new ENI Watcher
+func (tracker *attachmentTracker) Init() error {
+	return tracker.reconcileOnce()
+}
+
+// Stop is used to invoke the cancellation routine
+func (tracker *attachmentTracker) Stop() {
+	tracker.cancel()
+}
+
+// performPeriodicReconciliation is used to periodically invoke the
+// reconciliation process based on a ticker. The network interfaces are also
+// scanned from sysfs while ENI attachments are about to expire
+func (tracker *attachmentTracker) performPeriodicReconciliation(updateInterval time.Duration) {
+	tracker.updateIntervalTicker = time.NewTicker(updateInterval)
+	deadlineTicker := time.NewTicker(deadlineScanInterval)
+	for {
+		select {
+		case <-tracker.updateIntervalTicker.C:
+			if err := tracker.reconcileOnce(); err != nil {
+				log.Warnf("Udev watcher reconciliation failed: %v", err)
+			}
+		case <-deadlineTicker.C:
+			if tracker.attachmentsNearingDeadline(time.Now()) {
+				if err := tracker.scanSysfs(); err != nil {
+					log.Warnf("ENI watcher sysfs scan failed: %v", err)
+				}
+			}
+		case <-tracker.ctx.Done():
+			tracker.updateIntervalTicker.Stop()
+			deadlineTicker.Stop()
+			return
+		}
+	}
+}
+
+// reconcileOnce is used to reconcile the state of ENIs attached to the instance
+func (tracker *attachmentTracker) reconcileOnce() error {
+	links, err := tracker.netlinkClient.LinkList()
+	if err != nil {
+		return errors.Wrapf(err, "udev watcher: unable to retrieve network interfaces")
+	}
+
+	// Return on empty list
+	if len(links) == 0 {
+		log.Info("Udev watcher reconciliation: no network interfaces discovered for reconciliation")
+		return nil
+	}
+
+	currentState := tracker.buildState(links)
+
+	// NOTE: For correct semantics, this entire function needs to be locked.
+	// As we postulate the netlinkClient.LinkList() call to be expensive, we allow
+	// the race here. The state would be corrected during the next reconciliation loop.
+
+	// Add new interfaces next
+	for mac := range currentState {
+		if err := tracker.sendENIStateChange(mac); err != nil {
+			log.Warnf("Udev watcher reconciliation: unable to send state change: %v", err)
+		}
+	}
+	return nil
+}
+
+// attachmentsNearingDeadline returns true if an ENI attachment that hasn't
+// been found yet expires within the deadline scan window
+func (tracker *attachmentTracker) attachmentsNearingDeadline(now time.Time) bool {
+	for _, eni := range tracker.agentState.AllENIAttachments() {
+		if eni.IsSent() || eni.HasExpired() {
+			continue
+		}
+		if eni.ExpiresAt.Before(now.Add(deadlineScanWindow)) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanSysfs sends the state changes of the ENI attachments of the physical
+// network interfaces found in sysfs. It doesn't rely on netlink, so that ENIs
+// are still found if netlink is what missed them
+func (tracker *attachmentTracker) scanSysfs() error {
+	devices, err := ioutil.ReadDir(tracker.sysfsNetPath)
+	if err != nil {
+		return errors.Wrapf(err, "eni watcher: unable to list the network interfaces in %s", tracker.sysfsNetPath)
+	}
+	for _, device := range devices {
+		devicePath, err := os.Readlink(filepath.Join(tracker.sysfsNetPath, device.Name()))
+		if err != nil || !networkutils.IsValidNetworkDevice(devicePath) {
+			continue
+		}
+		address, err := ioutil.ReadFile(filepath.Join(tracker.sysfsNetPath, device.Name(), "address"))
+		if err != nil {
+			log.Debugf("ENI watcher sysfs scan: unable to read the mac address of %s: %v", device.Name(), err)
+			continue
+		}
+		mac := strings.TrimSpace(string(address))
+		if mac == "" || mac == tracker.primaryMAC {
+			continue
+		}
+		if err := tracker.sendENIStateChange(mac); err != nil {
+			if _, ok := err.(*unmanagedENIError); !ok {
+				log.Debugf("ENI watcher sysfs scan: unable to send state change: %v", err)
+			}
+			continue
+		}
+		log.Infof("ENI watcher sysfs scan: found interface %s of mac address %s", device.Name(), mac)
+	}
+	return nil
+}
+
+// sendENIStateChange handles the eni event from udev or reconcile phase
+func (tracker *attachmentTracker) sendENIStateChange(mac string) error {
+	if mac == "" {
+		return errors.New("udev watcher send ENI state change: empty mac address")
+	}
+	// check if this is an eni required by a task
+	eni, ok := tracker.agentState.ENIByMac(mac)
+	if !ok {
+		return &unmanagedENIError{mac}
+	}
+	if eni.IsSent() {
+		return errors.Errorf("udev watcher send ENI state change: eni status already sent: %s", eni.String())
+	}
+	if eni.HasExpired() {
+		// Agent is aware of the ENI, but we decide not to ack it
+		// as it's ack timeout has expired
+		tracker.agentState.RemoveENIAttachment(eni.MACAddress)
+		return errors.Errorf(
+			"udev watcher send ENI state change: eni status expired, no longer tracking it: %s",
+			eni.String())
+	}
+
+	// We found an ENI, which has the expiration time set in future and
+	// needs to be acknowledged as having been 'attached' to the Instance
+	go func(eni *apieni.ENIAttachment) {
+		eni.Status = apieni.ENIAttached
+		log.Infof("Emitting ENI change event for: %s", eni.String())
+		tracker.eniChangeEvent <- api.AttachmentStateChange{
+			Attachment: eni,
+		}
+	}(eni)
+	return nil
+}
+
+// buildState is used to build a state of the system for reconciliation
+func (tracker *attachmentTracker) buildState(links []netlink.Link) map[string]string {
+	state := make(map[string]string)
+	for _, link := range links {
+		if macAddress, ok := tracker.eniMACAddress(link); ok {
+			state[macAddress] = link.Attrs().Name
+		}
+	}
+	return state
+}
+
+// eniMACAddress returns the mac address of the link if it can be an ENI
+func (tracker *attachmentTracker) eniMACAddress(link netlink.Link) (string, bool) {
+	if link.Type() != linkTypeDevice {
+		// We only care about netlink.Device types. These are created
+		// by udev like 'lo' and 'eth0'. Ignore other link types
+		return "", false
+	}
+	if link.Attrs().EncapType == encapTypeLoopback {
+		// Ignore localhost
+		return "", false
+	}
+	macAddress := link.Attrs().HardwareAddr.String()
+	if macAddress == "" || macAddress == tracker.primaryMAC {
+		return "", false
+	}
+	return macAddress, true
+}
+
+// handleAddedInterface looks up the mac address of a network interface that
+// was just added, and sends the state change of its ENI attachment once ACS
+// has sent it. It blocks for a few seconds in the worst-case scenario
+func (tracker *attachmentTracker) handleAddedInterface(dev string) {
+	macAddress, err := networkutils.GetMACAddress(tracker.ctx, macAddressRetryTimeout,
+		dev, tracker.netlinkClient)
+	if err != nil {
+		log.Warnf("Udev watcher event-handler: error obtaining MACAddress for interface %s", dev)
+		return
+	}
+	tracker.handleAddedMACAddress(macAddress)
+}
+
+// handleAddedMACAddress sends the state change of the ENI attachment of the mac
+// address of a network interface that was just added, once ACS has sent it
+func (tracker *attachmentTracker) handleAddedMACAddress(macAddress string) {
+	if err := tracker.sendENIStateChangeWithRetries(tracker.ctx, macAddress, sendENIStateChangeRetryTimeout); err != nil {
+		log.Warnf("Udev watcher event-handler: unable to send state change: %v", err)
+	}
+}
+
+// sendENIStateChangeWithRetries invokes the sendENIStateChange method, with backoff and
+// retries. Retries are only effective if sendENIStateChange returns an unmanagedENIError.
+// We're effectively waiting for the ENI attachment message from ACS for a network device
+// at this point of time.
+func (tracker *attachmentTracker) sendENIStateChangeWithRetries(parentCtx context.Context,
+	macAddress string,
+	timeout time.Duration) error {
+	backoff := utils.NewSimpleBackoff(sendENIStateChangeBackoffMin, sendENIStateChangeBackoffMax,
+		sendENIStateChangeBackoffJitter, sendENIStateChangeBackoffMultiple)
+	ctx, cancel := context.WithTimeout(parentCtx, timeout)
+	defer cancel()
+
+	err := utils.RetryWithBackoffCtx(ctx, backoff, func() error {
+		sendErr := tracker.sendENIStateChange(macAddress)
+		if sendErr != nil {
+			if _, ok := sendErr.(*unmanagedENIError); ok {
+				log.Debugf("Unable to send state change for unmanaged ENI: %v", sendErr)
+				return sendErr
+			}
+			// Not unmanagedENIError. Stop retrying when this happens
+			return apierrors.NewRetriableError(apierrors.NewRetriable(false), sendErr)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+	// RetryWithBackoffCtx returns nil when the context is cancelled. Check if there was
+	// a timeout here. TODO: Fix RetryWithBackoffCtx to return ctx.Err() on context Done()
+	if err = ctx.Err(); err != nil {
+		return errors.Wrapf(err,
+			"udev watcher send ENI state change: timed out waiting for eni '%s' in state", macAddress)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+// +build linux,unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
+)
+
+// addSysfsDevice adds a network interface of the mac address to a fake sysfs
+func addSysfsDevice(t *testing.T, sysfs string, devicePath string, name string, mac string) {
+	deviceDir := filepath.Join(sysfs, devicePath, name)
+	require.NoError(t, os.MkdirAll(deviceDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(deviceDir, "address"), []byte(mac+"\n"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join("..", "..", devicePath, name),
+		filepath.Join(sysfs, "class", "net", name)))
+}
+
+func TestScanSysfs(t *testing.T) {
+	sysfs, err := ioutil.TempDir("", "sysfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(sysfs)
+	require.NoError(t, os.MkdirAll(filepath.Join(sysfs, "class", "net"), 0755))
+	addSysfsDevice(t, sysfs, "devices/pci0000:00/0000:00:03.0/net", "eth0", primaryMAC)
+	addSysfsDevice(t, sysfs, "devices/pci0000:00/0000:00:05.0/net", randomDevice, randomMAC)
+	addSysfsDevice(t, sysfs, "devices/virtual/net", "veth0", "00:0a:95:9d:68:17")
+
+	taskEngineState := dockerstate.NewTaskEngineState()
+	taskEngineState.AddENIAttachment(&apieni.ENIAttachment{
+		MACAddress: randomMAC,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	})
+	taskEngineState.AddENIAttachment(&apieni.ENIAttachment{
+		MACAddress: "00:0a:95:9d:68:17",
+		ExpiresAt:  time.Now().Add(time.Minute),
+	})
+	eventChannel := make(chan statechange.Event)
+	tracker := newAttachmentTracker(context.TODO(), primaryMAC, nil, taskEngineState, eventChannel)
+	tracker.sysfsNetPath = filepath.Join(sysfs, "class", "net")
+
+	require.NoError(t, tracker.scanSysfs())
+	eniChangeEvent := <-eventChannel
+	attachmentStateChange, ok := eniChangeEvent.(api.AttachmentStateChange)
+	require.True(t, ok)
+	assert.Equal(t, randomMAC, attachmentStateChange.Attachment.MACAddress)
+
+	select {
+	case <-eventChannel:
+		t.Errorf("Expect no state change event for virtual devices")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestScanSysfsError(t *testing.T) {
+	tracker := newAttachmentTracker(context.TODO(), primaryMAC, nil, dockerstate.NewTaskEngineState(), nil)
+	tracker.sysfsNetPath = "/does/not/exist"
+	assert.Error(t, tracker.scanSysfs())
+}
+
+func TestAttachmentsNearingDeadline(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		name     string
+		eni      *apieni.ENIAttachment
+		expected bool
+	}{
+		{"no attachment", nil, false},
+		{"far from deadline", &apieni.ENIAttachment{ExpiresAt: now.Add(time.Hour)}, false},
+		{"near deadline", &apieni.ENIAttachment{ExpiresAt: now.Add(deadlineScanWindow / 2)}, true},
+		{"already sent", &apieni.ENIAttachment{ExpiresAt: now.Add(deadlineScanWindow / 2), AttachStatusSent: true}, false},
+		{"expired", &apieni.ENIAttachment{ExpiresAt: now.Add(-time.Second)}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			taskEngineState := dockerstate.NewTaskEngineState()
+			if tc.eni != nil {
+				tc.eni.MACAddress = randomMAC
+				taskEngineState.AddENIAttachment(tc.eni)
+			}
+			tracker := newAttachmentTracker(context.TODO(), primaryMAC, nil, taskEngineState, nil)
+			assert.Equal(t, tc.expected, tracker.attachmentsNearingDeadline(now))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// +build linux
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"syscall"
+
+	log "github.com/cihub/seelog"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eni/netlinkwrapper"
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
+)
+
+// NetlinkWatcher maintains the state of the ENIs attached to the instance
+// from the RTM_NEWLINK messages of the kernel. It's used on hosts where the
+// udev monitor is unavailable
+type NetlinkWatcher struct {
+	*attachmentTracker
+	// knownLinks are the indexes of the links that have been seen, as the
+	// kernel sends RTM_NEWLINK messages for changes to existing links too
+	knownLinks map[int]struct{}
+}
+
+// NewNetlinkWatcher returns a NetlinkWatcher
+func NewNetlinkWatcher(ctx context.Context, primaryMAC string,
+	state dockerstate.TaskEngineState, stateChangeEvents chan<- statechange.Event) *NetlinkWatcher {
+	return newNetlinkWatcher(ctx, primaryMAC, netlinkwrapper.New(), state, stateChangeEvents)
+}
+
+func newNetlinkWatcher(ctx context.Context,
+	primaryMAC string,
+	nlWrap netlinkwrapper.NetLink,
+	state dockerstate.TaskEngineState,
+	stateChangeEvents chan<- statechange.Event) *NetlinkWatcher {
+
+	return &NetlinkWatcher{
+		attachmentTracker: newAttachmentTracker(ctx, primaryMAC, nlWrap, state, stateChangeEvents),
+		knownLinks:        make(map[int]struct{}),
+	}
+}
+
+// Start periodically updates the state of ENIs connected to the system
+func (netlinkWatcher *NetlinkWatcher) Start() {
+	updates := make(chan netlink.LinkUpdate)
+	if err := netlinkWatcher.netlinkClient.LinkSubscribe(updates, netlinkWatcher.ctx.Done()); err != nil {
+		// ENIs are still found by the periodic reconciliation and the scans
+		// of sysfs, if more slowly
+		log.Errorf("Netlink watcher: unable to subscribe to link updates: %v", err)
+	} else {
+		go netlinkWatcher.eventHandler(updates)
+	}
+	netlinkWatcher.performPeriodicReconciliation(defaultReconciliationInterval)
+}
+
+// eventHandler handles the link updates until the subscription ends
+func (netlinkWatcher *NetlinkWatcher) eventHandler(updates <-chan netlink.LinkUpdate) {
+	for update := range updates {
+		netlinkWatcher.handleLinkUpdate(update)
+	}
+	log.Info("Stopping netlink event handler")
+}
+
+// handleLinkUpdate sends the state change of the ENI attachment of a link
+// seen for the first time
+func (netlinkWatcher *NetlinkWatcher) handleLinkUpdate(update netlink.LinkUpdate) {
+	if update.Link == nil {
+		return
+	}
+	index := update.Link.Attrs().Index
+	switch update.Header.Type {
+	case syscall.RTM_DELLINK:
+		delete(netlinkWatcher.knownLinks, index)
+		return
+	case syscall.RTM_NEWLINK:
+	default:
+		return
+	}
+	if _, ok := netlinkWatcher.knownLinks[index]; ok {
+		return
+	}
+	netlinkWatcher.knownLinks[index] = struct{}{}
+
+	name := update.Link.Attrs().Name
+	if macAddress, ok := netlinkWatcher.eniMACAddress(update.Link); ok {
+		log.Debugf("Netlink watcher event-handler: add interface: %s", name)
+		go netlinkWatcher.handleAddedMACAddress(macAddress)
+	} else if update.Link.Type() == linkTypeDevice && update.Link.Attrs().HardwareAddr == nil {
+		// The mac address of the interface may not be known yet
+		log.Debugf("Netlink watcher event-handler: add interface without mac address: %s", name)
+		go netlinkWatcher.handleAddedInterface(name)
+	}
+}
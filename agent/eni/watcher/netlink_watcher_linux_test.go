@@ -0,0 +1,153 @@
+// +build linux,unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/eni/netlinkwrapper/mocks"
+)
+
+// newLinkUpdate builds a link update of the type for a device
+func newLinkUpdate(msgType uint16, index int, name string, mac string) netlink.LinkUpdate {
+	attrs := netlink.LinkAttrs{Index: index, Name: name}
+	if mac != "" {
+		attrs.HardwareAddr, _ = net.ParseMAC(mac)
+	}
+	return netlink.LinkUpdate{
+		Header: syscall.NlMsghdr{Type: msgType},
+		Link:   &netlink.Device{LinkAttrs: attrs},
+	}
+}
+
+// TestNetlinkWatcherAddEvent tests adding a device from the link updates of
+// a fake netlink subscription
+func TestNetlinkWatcherAddEvent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockNetlink := mock_netlinkwrapper.NewMockNetLink(mockCtrl)
+	taskEngineState := dockerstate.NewTaskEngineState()
+	taskEngineState.AddENIAttachment(&apieni.ENIAttachment{
+		MACAddress: randomMAC,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	})
+	eventChannel := make(chan statechange.Event)
+	watcher := newNetlinkWatcher(context.TODO(), primaryMAC, mockNetlink, taskEngineState, eventChannel)
+
+	updates := make(chan chan<- netlink.LinkUpdate, 1)
+	mockNetlink.EXPECT().LinkSubscribe(gomock.Any(), gomock.Any()).Do(
+		func(ch chan<- netlink.LinkUpdate, done <-chan struct{}) {
+			go func() {
+				<-done
+				close(ch)
+			}()
+			updates <- ch
+		}).Return(nil)
+
+	started := make(chan struct{})
+	go func() {
+		watcher.Start()
+		close(started)
+	}()
+	linkUpdates := <-updates
+	linkUpdates <- newLinkUpdate(syscall.RTM_NEWLINK, 1, "eth0", primaryMAC)
+	linkUpdates <- newLinkUpdate(syscall.RTM_NEWLINK, 2, randomDevice, randomMAC)
+
+	eniChangeEvent := <-eventChannel
+	attachmentStateChange, ok := eniChangeEvent.(api.AttachmentStateChange)
+	require.True(t, ok)
+	assert.Equal(t, randomMAC, attachmentStateChange.Attachment.MACAddress)
+	assert.Equal(t, apieni.ENIAttached, attachmentStateChange.Attachment.Status)
+
+	watcher.Stop()
+	<-started
+}
+
+// TestNetlinkWatcherKnownLinks checks that only the first update of a link is
+// handled, until the link is deleted
+func TestNetlinkWatcherKnownLinks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStateManager := mock_dockerstate.NewMockTaskEngineState(mockCtrl)
+	eventChannel := make(chan statechange.Event)
+	watcher := newNetlinkWatcher(context.TODO(), primaryMAC, nil, mockStateManager, eventChannel)
+
+	mockStateManager.EXPECT().ENIByMac(randomMAC).Return(&apieni.ENIAttachment{
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, true).Times(2)
+
+	watcher.handleLinkUpdate(newLinkUpdate(syscall.RTM_NEWLINK, 2, randomDevice, randomMAC))
+	<-eventChannel
+	// Updates of the state of a known link aren't interface additions
+	watcher.handleLinkUpdate(newLinkUpdate(syscall.RTM_NEWLINK, 2, randomDevice, randomMAC))
+
+	watcher.handleLinkUpdate(newLinkUpdate(syscall.RTM_DELLINK, 2, randomDevice, randomMAC))
+	watcher.handleLinkUpdate(newLinkUpdate(syscall.RTM_NEWLINK, 2, randomDevice, randomMAC))
+	<-eventChannel
+
+	select {
+	case <-eventChannel:
+		t.Errorf("Expect no more state change event")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// TestNetlinkWatcherAddEventWithoutMACAddress checks that the mac address of
+// a link is looked up when the update doesn't carry it
+func TestNetlinkWatcherAddEventWithoutMACAddress(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockNetlink := mock_netlinkwrapper.NewMockNetLink(mockCtrl)
+	mockStateManager := mock_dockerstate.NewMockTaskEngineState(mockCtrl)
+	eventChannel := make(chan statechange.Event)
+	watcher := newNetlinkWatcher(context.TODO(), primaryMAC, mockNetlink, mockStateManager, eventChannel)
+
+	parsedMAC, _ := net.ParseMAC(randomMAC)
+	gomock.InOrder(
+		mockNetlink.EXPECT().LinkByName(randomDevice).Return(&netlink.Device{
+			LinkAttrs: netlink.LinkAttrs{
+				HardwareAddr: parsedMAC,
+				Name:         randomDevice,
+			},
+		}, nil),
+		mockStateManager.EXPECT().ENIByMac(randomMAC).Return(&apieni.ENIAttachment{
+			ExpiresAt: time.Now().Add(time.Minute),
+		}, true),
+	)
+
+	watcher.handleLinkUpdate(newLinkUpdate(syscall.RTM_NEWLINK, 2, randomDevice, ""))
+	eniChangeEvent := <-eventChannel
+	_, ok := eniChangeEvent.(api.AttachmentStateChange)
+	assert.True(t, ok)
+}
@@ -93,8 +93,8 @@ func TestWatcherInit(t *testing.T) {
 	var event statechange.Event
 	go func() {
 		event = <-eventChannel
-		assert.NotNil(t, event.(api.TaskStateChange).Attachment)
-		assert.Equal(t, randomMAC, event.(api.TaskStateChange).Attachment.MACAddress)
+		assert.NotNil(t, event.(api.AttachmentStateChange).Attachment)
+		assert.Equal(t, randomMAC, event.(api.AttachmentStateChange).Attachment.MACAddress)
 		waitForEvents.Done()
 	}()
 	watcher.Init()
@@ -197,8 +197,8 @@ func TestReconcileENIs(t *testing.T) {
 	watcher.reconcileOnce()
 
 	<-done
-	assert.NotNil(t, event.(api.TaskStateChange).Attachment)
-	assert.Equal(t, randomMAC, event.(api.TaskStateChange).Attachment.MACAddress)
+	assert.NotNil(t, event.(api.AttachmentStateChange).Attachment)
+	assert.Equal(t, randomMAC, event.(api.AttachmentStateChange).Attachment.MACAddress)
 
 	select {
 	case <-eventChannel:
@@ -306,9 +306,9 @@ func TestUdevAddEvent(t *testing.T) {
 	watcher.events <- &event
 
 	eniChangeEvent := <-eventChannel
-	taskStateChange, ok := eniChangeEvent.(api.TaskStateChange)
+	attachmentStateChange, ok := eniChangeEvent.(api.AttachmentStateChange)
 	require.True(t, ok)
-	assert.Equal(t, apieni.ENIAttached, taskStateChange.Attachment.Status)
+	assert.Equal(t, apieni.ENIAttached, attachmentStateChange.Attachment.Status)
 
 	var waitForClose sync.WaitGroup
 	waitForClose.Add(2)
@@ -462,9 +462,9 @@ func TestSendENIStateChange(t *testing.T) {
 	go watcher.sendENIStateChange(randomMAC)
 
 	eniChangeEvent := <-eventChannel
-	taskStateChange, ok := eniChangeEvent.(api.TaskStateChange)
+	attachmentStateChange, ok := eniChangeEvent.(api.AttachmentStateChange)
 	require.True(t, ok)
-	assert.Equal(t, apieni.ENIAttached, taskStateChange.Attachment.Status)
+	assert.Equal(t, apieni.ENIAttached, attachmentStateChange.Attachment.Status)
 }
 
 func TestSendENIStateChangeUnmanaged(t *testing.T) {
@@ -534,9 +534,9 @@ func TestSendENIStateChangeWithRetries(t *testing.T) {
 	go watcher.sendENIStateChangeWithRetries(ctx, randomMAC, sendENIStateChangeRetryTimeout)
 
 	eniChangeEvent := <-eventChannel
-	taskStateChange, ok := eniChangeEvent.(api.TaskStateChange)
+	attachmentStateChange, ok := eniChangeEvent.(api.AttachmentStateChange)
 	require.True(t, ok)
-	assert.Equal(t, apieni.ENIAttached, taskStateChange.Attachment.Status)
+	assert.Equal(t, apieni.ENIAttached, attachmentStateChange.Attachment.Status)
 }
 
 func TestSendENIStateChangeWithRetriesDoesNotRetryExpiredENI(t *testing.T) {
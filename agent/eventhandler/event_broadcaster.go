@@ -0,0 +1,241 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package eventhandler
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// subscriberEventBufferSize is the number of events that are buffered for
+	// each subscriber. Events are dropped for subscribers whose buffer is full
+	subscriberEventBufferSize = 100
+	// subscriberWriteTimeout is the time after which writing an event to a
+	// subscriber is abandoned and the subscriber is disconnected
+	subscriberWriteTimeout = 5 * time.Second
+	// socketDirMode is the mode of the directory of the socket, when it's
+	// created by the broadcaster
+	socketDirMode = 0700
+	// socketMode is the mode of the socket
+	socketMode = 0600
+)
+
+// EventBroadcaster writes the task and container state change events, as JSON
+// lines, to the subscribers connected on a unix domain socket, and queues them
+// for the subscriptions made in process. Writing to the subscribers happens
+// asynchronously, so that slow or disconnected subscribers don't block the
+// submission of state changes to ECS
+type EventBroadcaster struct {
+	listener net.Listener
+	// subscribers is the set of connected subscribers
+	subscribers map[*eventSubscriber]struct{}
+	// subscriptions is the set of the subscriptions made in process
+	subscriptions map[*EventSubscription]struct{}
+	// closed is true once the broadcaster is closed
+	closed bool
+	// droppedEvents is the number of events dropped across all the
+	// subscribers, as their buffers were full
+	droppedEvents uint64
+
+	lock sync.RWMutex
+}
+
+// eventSubscriber is a client connected to the EventBroadcaster's socket
+type eventSubscriber struct {
+	conn   net.Conn
+	events chan []byte
+	// droppedEvents is the number of events dropped for the subscriber
+	droppedEvents uint64
+}
+
+// NewEventBroadcaster listens for subscribers on the unix domain socket at
+// socketPath. Events are only queued for the subscriptions made in process if
+// socketPath is empty. The socket is closed, and the subscribers and the
+// subscriptions disconnected, when the context is cancelled
+func NewEventBroadcaster(ctx context.Context, socketPath string) (*EventBroadcaster, error) {
+	broadcaster := &EventBroadcaster{
+		subscribers:   make(map[*eventSubscriber]struct{}),
+		subscriptions: make(map[*EventSubscription]struct{}),
+	}
+	if socketPath != "" {
+		listener, err := listen(socketPath)
+		if err != nil {
+			return nil, err
+		}
+		broadcaster.listener = listener
+		go broadcaster.acceptSubscribers()
+	}
+	go func() {
+		<-ctx.Done()
+		broadcaster.close()
+	}()
+	return broadcaster, nil
+}
+
+// listen listens on the unix domain socket at socketPath, replacing the
+// socket left behind by a previous run of the agent. Only the user of the agent
+// can connect to the socket, as the events carry the details of every task
+func listen(socketPath string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), socketDirMode); err != nil {
+		return nil, errors.Wrapf(err, "event broadcaster: unable to create directory for socket %s", socketPath)
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "event broadcaster: unable to remove existing socket %s", socketPath)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "event broadcaster: unable to listen on socket %s", socketPath)
+	}
+	if err := os.Chmod(socketPath, socketMode); err != nil {
+		listener.Close()
+		return nil, errors.Wrapf(err, "event broadcaster: unable to restrict access to socket %s", socketPath)
+	}
+	return listener, nil
+}
+
+// Broadcast queues the event to be written to every connected subscriber.
+// Only task and container state change events are broadcast
+func (broadcaster *EventBroadcaster) Broadcast(event statechange.Event) {
+	eventType := event.GetEventType()
+	if eventType != statechange.TaskEvent && eventType != statechange.ContainerEvent {
+		return
+	}
+
+	broadcaster.lock.RLock()
+	defer broadcaster.lock.RUnlock()
+
+	if len(broadcaster.subscribers) == 0 && len(broadcaster.subscriptions) == 0 {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		seelog.Warnf("Event broadcaster: unable to marshal state change event: %v", err)
+		return
+	}
+	for subscription := range broadcaster.subscriptions {
+		subscription.queue(StreamedEvent{Type: streamedEventType(eventType), Event: data})
+	}
+
+	data = append(data, '\n')
+	for subscriber := range broadcaster.subscribers {
+		select {
+		case subscriber.events <- data:
+		default:
+			// The subscriber isn't keeping up, drop the event rather than
+			// blocking the submission of state changes
+			atomic.AddUint64(&subscriber.droppedEvents, 1)
+			atomic.AddUint64(&broadcaster.droppedEvents, 1)
+		}
+	}
+}
+
+// DroppedEvents returns the number of events that have been dropped as the
+// buffers of the subscribers were full
+func (broadcaster *EventBroadcaster) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&broadcaster.droppedEvents)
+}
+
+// acceptSubscribers accepts the connections on the socket until it's closed
+func (broadcaster *EventBroadcaster) acceptSubscribers() {
+	for {
+		conn, err := broadcaster.listener.Accept()
+		if err != nil {
+			seelog.Infof("Event broadcaster: no longer accepting subscribers: %v", err)
+			return
+		}
+		subscriber := &eventSubscriber{
+			conn:   conn,
+			events: make(chan []byte, subscriberEventBufferSize),
+		}
+		broadcaster.lock.Lock()
+		if broadcaster.closed {
+			// The connection was accepted while the broadcaster was being
+			// closed, it wouldn't be disconnected otherwise
+			broadcaster.lock.Unlock()
+			conn.Close()
+			seelog.Info("Event broadcaster: no longer accepting subscribers: broadcaster closed")
+			return
+		}
+		broadcaster.subscribers[subscriber] = struct{}{}
+		broadcaster.lock.Unlock()
+
+		seelog.Debugf("Event broadcaster: subscriber connected")
+		go broadcaster.writeEvents(subscriber)
+	}
+}
+
+// writeEvents writes the events queued for the subscriber to its connection.
+// The subscriber is removed once writing to it fails
+func (broadcaster *EventBroadcaster) writeEvents(subscriber *eventSubscriber) {
+	defer broadcaster.removeSubscriber(subscriber)
+
+	for data := range subscriber.events {
+		subscriber.conn.SetWriteDeadline(time.Now().Add(subscriberWriteTimeout))
+		if _, err := subscriber.conn.Write(data); err != nil {
+			seelog.Debugf("Event broadcaster: unable to write to subscriber: %v", err)
+			return
+		}
+	}
+}
+
+// removeSubscriber stops broadcasting events to the subscriber and closes its
+// connection
+func (broadcaster *EventBroadcaster) removeSubscriber(subscriber *eventSubscriber) {
+	broadcaster.lock.Lock()
+	defer broadcaster.lock.Unlock()
+
+	if _, ok := broadcaster.subscribers[subscriber]; !ok {
+		return
+	}
+	delete(broadcaster.subscribers, subscriber)
+	close(subscriber.events)
+	subscriber.conn.Close()
+	if dropped := atomic.LoadUint64(&subscriber.droppedEvents); dropped != 0 {
+		seelog.Warnf("Event broadcaster: subscriber disconnected; %d events were dropped for it", dropped)
+	}
+}
+
+// close closes the socket and disconnects every subscriber and subscription
+func (broadcaster *EventBroadcaster) close() {
+	if broadcaster.listener != nil {
+		broadcaster.listener.Close()
+	}
+
+	broadcaster.lock.Lock()
+	broadcaster.closed = true
+	subscribers := make([]*eventSubscriber, 0, len(broadcaster.subscribers))
+	for subscriber := range broadcaster.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	for subscription := range broadcaster.subscriptions {
+		delete(broadcaster.subscriptions, subscription)
+		subscription.close()
+	}
+	broadcaster.lock.Unlock()
+
+	for _, subscriber := range subscribers {
+		broadcaster.removeSubscriber(subscriber)
+	}
+}
@@ -0,0 +1,176 @@
+// +build unit,!windows
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package eventhandler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEventBroadcaster(t *testing.T, ctx context.Context) (*EventBroadcaster, string, func()) {
+	dir, err := ioutil.TempDir("", "event-broadcaster")
+	require.NoError(t, err)
+	socketPath := filepath.Join(dir, "events.sock")
+	broadcaster, err := NewEventBroadcaster(ctx, socketPath)
+	require.NoError(t, err)
+	return broadcaster, socketPath, func() { os.RemoveAll(dir) }
+}
+
+// waitForSubscribers waits for the broadcaster to have the number of
+// subscribers specified
+func waitForSubscribers(broadcaster *EventBroadcaster, count int) {
+	for {
+		broadcaster.lock.RLock()
+		subscribers := len(broadcaster.subscribers)
+		broadcaster.lock.RUnlock()
+		if subscribers == count {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEventBroadcasterWritesEventsToSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcaster, socketPath, cleanup := newTestEventBroadcaster(t, ctx)
+	defer cleanup()
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForSubscribers(broadcaster, 1)
+
+	// Attachment events aren't broadcast
+	broadcaster.Broadcast(api.AttachmentStateChange{Attachment: &apieni.ENIAttachment{}})
+	broadcaster.Broadcast(containerEvent(taskARN))
+	broadcaster.Broadcast(taskEvent(taskARN))
+
+	reader := bufio.NewReader(conn)
+	var event map[string]interface{}
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(line, &event))
+	assert.Equal(t, taskARN, event["taskArn"])
+	assert.Equal(t, "containerName", event["containerName"])
+	assert.Equal(t, "RUNNING", event["status"])
+
+	event = nil
+	line, err = reader.ReadBytes('\n')
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(line, &event))
+	assert.Equal(t, taskARN, event["taskArn"])
+	assert.NotContains(t, event, "containerName")
+	assert.Equal(t, "RUNNING", event["status"])
+}
+
+func TestEventBroadcasterDropsEventsForSlowSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcaster, _, cleanup := newTestEventBroadcaster(t, ctx)
+	defer cleanup()
+
+	// The subscriber never reads the events queued for it
+	server, client := net.Pipe()
+	defer client.Close()
+	subscriber := &eventSubscriber{
+		conn:   server,
+		events: make(chan []byte, subscriberEventBufferSize),
+	}
+	broadcaster.lock.Lock()
+	broadcaster.subscribers[subscriber] = struct{}{}
+	broadcaster.lock.Unlock()
+
+	for i := 0; i < subscriberEventBufferSize+5; i++ {
+		broadcaster.Broadcast(taskEvent(taskARN))
+	}
+	assert.Equal(t, uint64(5), broadcaster.DroppedEvents())
+	assert.Equal(t, uint64(5), subscriber.droppedEvents)
+}
+
+func TestEventBroadcasterRemovesDisconnectedSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcaster, socketPath, cleanup := newTestEventBroadcaster(t, ctx)
+	defer cleanup()
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	waitForSubscribers(broadcaster, 1)
+	conn.Close()
+
+	// Writing to the subscriber fails once it has disconnected
+	done := make(chan struct{})
+	go func() {
+		waitForSubscribers(broadcaster, 0)
+		close(done)
+	}()
+	for {
+		broadcaster.Broadcast(taskEvent(taskARN))
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestEventBroadcasterClosedWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	broadcaster, socketPath, cleanup := newTestEventBroadcaster(t, ctx)
+	defer cleanup()
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForSubscribers(broadcaster, 1)
+
+	cancel()
+	waitForSubscribers(broadcaster, 0)
+	_, err = bufio.NewReader(conn).ReadBytes('\n')
+	assert.Error(t, err)
+}
+
+func TestEventBroadcasterRestrictsSocketAccess(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir, err := ioutil.TempDir("", "event-broadcaster")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "ecs", "events.sock")
+	_, err = NewEventBroadcaster(ctx, socketPath)
+	require.NoError(t, err)
+
+	dirInfo, err := os.Stat(filepath.Dir(socketPath))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), dirInfo.Mode().Perm())
+	socketInfo, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), socketInfo.Mode().Perm())
+}
@@ -0,0 +1,128 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package eventhandler
+
+import (
+	"sync/atomic"
+
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+)
+
+// EventStats is a snapshot of the counters of the state change events handled
+// by the TaskHandler since the agent started, along with the number of events
+// currently queued for each task
+type EventStats struct {
+	// Enqueued is the number of events queued for submission
+	Enqueued uint64 `json:"enqueued"`
+	// Submitted is the number of events successfully submitted to ECS
+	Submitted uint64 `json:"submitted"`
+	// Failed is the number of failed submission attempts, including the ones
+	// that are retried
+	Failed uint64 `json:"failed"`
+	// Dropped is the number of events dropped without being submitted
+	Dropped uint64 `json:"dropped"`
+	// QueueDepths is the number of events queued for each task arn
+	QueueDepths map[string]int `json:"queueDepths"`
+}
+
+// eventCounters are the counters of the events handled by the TaskHandler.
+// They are updated atomically and are only ever reset by restarting the agent
+type eventCounters struct {
+	enqueued  uint64
+	submitted uint64
+	failed    uint64
+	dropped   uint64
+}
+
+func (counters *eventCounters) incrementEnqueued() {
+	atomic.AddUint64(&counters.enqueued, 1)
+}
+
+func (counters *eventCounters) incrementSubmitted() {
+	atomic.AddUint64(&counters.submitted, 1)
+}
+
+func (counters *eventCounters) incrementFailed() {
+	atomic.AddUint64(&counters.failed, 1)
+}
+
+func (counters *eventCounters) incrementDropped() {
+	atomic.AddUint64(&counters.dropped, 1)
+}
+
+// GetEventStats returns the counters of the events handled by the TaskHandler
+// and the current depth of the event queue of each task
+func (handler *TaskHandler) GetEventStats() EventStats {
+	stats := EventStats{
+		Enqueued:    atomic.LoadUint64(&handler.counters.enqueued),
+		Submitted:   atomic.LoadUint64(&handler.counters.submitted),
+		Failed:      atomic.LoadUint64(&handler.counters.failed),
+		Dropped:     atomic.LoadUint64(&handler.counters.dropped),
+		QueueDepths: make(map[string]int),
+	}
+
+	handler.lock.RLock()
+	defer handler.lock.RUnlock()
+
+	for taskARN, taskEvents := range handler.tasksToEvents {
+		taskEvents.lock.Lock()
+		depth := taskEvents.events.Len()
+		taskEvents.lock.Unlock()
+		if depth != 0 {
+			stats.QueueDepths[taskARN] = depth
+		}
+	}
+	return stats
+}
+
+// Metrics returns the counters of the events handled by the TaskHandler and
+// the total depth of the event queues. Queue depths aren't labeled with the
+// tasks they belong to, so that the number of time series stays bounded
+func (handler *TaskHandler) Metrics() []*metrics.Family {
+	stats := handler.GetEventStats()
+	queueDepth := 0
+	for _, depth := range stats.QueueDepths {
+		queueDepth += depth
+	}
+
+	events := &metrics.Family{
+		Name: "ecs_agent_state_change_events_total",
+		Help: "Number of state change events by result.",
+		Type: metrics.CounterType,
+	}
+	for _, count := range []struct {
+		result string
+		value  uint64
+	}{
+		{"enqueued", stats.Enqueued},
+		{"submitted", stats.Submitted},
+		{"failed", stats.Failed},
+		{"dropped", stats.Dropped},
+	} {
+		events.Samples = append(events.Samples, metrics.Sample{
+			Labels: []metrics.Label{{Name: "result", Value: count.result}},
+			Value:  float64(count.value),
+		})
+	}
+
+	return []*metrics.Family{
+		events,
+		{
+			Name:    "ecs_agent_state_change_events_queued",
+			Help:    "Number of state change events queued for submission.",
+			Type:    metrics.GaugeType,
+			Samples: []metrics.Sample{{Value: float64(queueDepth)}},
+		},
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package eventhandler
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
+)
+
+const (
+	// TaskStreamedEventType is the type of the streamed task state changes
+	TaskStreamedEventType = "task"
+	// ContainerStreamedEventType is the type of the streamed container state
+	// changes
+	ContainerStreamedEventType = "container"
+)
+
+// StreamedEvent is a state change event received by an EventSubscription
+type StreamedEvent struct {
+	// Type is the type of the state change, 'task' or 'container'
+	Type string `json:"type"`
+	// DroppedEvents is the number of events that were dropped before this
+	// one, as the buffer of the subscription was full
+	DroppedEvents uint64 `json:"droppedEvents"`
+	// Replayed is true for the events describing the state of the tasks and
+	// containers when the subscription was made, rather than a state change
+	Replayed bool `json:"replayed,omitempty"`
+	// Event is the JSON representation of the state change
+	Event json.RawMessage `json:"event"`
+}
+
+// EventSubscription receives the task and container state change events
+// broadcast by an EventBroadcaster in process. Its buffer is bounded: once
+// it's full, the oldest events are dropped, so that slow subscriptions don't
+// block the broadcast. The number of events dropped is reported with the next
+// event received
+type EventSubscription struct {
+	broadcaster *EventBroadcaster
+	bufferSize  int
+	events      []StreamedEvent
+	// droppedEvents is the number of events dropped since the last event
+	// was received
+	droppedEvents uint64
+	// ready is signalled when events are queued or the subscription is closed
+	ready  chan struct{}
+	closed bool
+	lock   sync.Mutex
+}
+
+// Subscribe returns a new subscription to the events broadcast from now on.
+// The subscription must be closed once the events are no longer received
+func (broadcaster *EventBroadcaster) Subscribe() *EventSubscription {
+	return broadcaster.subscribe(subscriberEventBufferSize)
+}
+
+func (broadcaster *EventBroadcaster) subscribe(bufferSize int) *EventSubscription {
+	subscription := &EventSubscription{
+		broadcaster: broadcaster,
+		bufferSize:  bufferSize,
+		ready:       make(chan struct{}, 1),
+	}
+
+	broadcaster.lock.Lock()
+	defer broadcaster.lock.Unlock()
+
+	if broadcaster.closed {
+		subscription.closed = true
+		return subscription
+	}
+	broadcaster.subscriptions[subscription] = struct{}{}
+	return subscription
+}
+
+// Next returns the oldest event queued for the subscription, waiting for one
+// if there's none. It returns false once the subscription is closed, or the
+// context is cancelled
+func (subscription *EventSubscription) Next(ctx context.Context) (StreamedEvent, bool) {
+	for {
+		subscription.lock.Lock()
+		if subscription.closed {
+			subscription.lock.Unlock()
+			return StreamedEvent{}, false
+		}
+		if len(subscription.events) > 0 {
+			event := subscription.events[0]
+			subscription.events = subscription.events[1:]
+			event.DroppedEvents = subscription.droppedEvents
+			subscription.droppedEvents = 0
+			subscription.lock.Unlock()
+			return event, true
+		}
+		subscription.lock.Unlock()
+
+		select {
+		case <-subscription.ready:
+		case <-ctx.Done():
+			return StreamedEvent{}, false
+		}
+	}
+}
+
+// Close stops the broadcast of events to the subscription
+func (subscription *EventSubscription) Close() {
+	subscription.broadcaster.lock.Lock()
+	delete(subscription.broadcaster.subscriptions, subscription)
+	subscription.broadcaster.lock.Unlock()
+
+	subscription.close()
+}
+
+// queue queues the event, dropping the oldest queued event if the buffer of
+// the subscription is full
+func (subscription *EventSubscription) queue(event StreamedEvent) {
+	subscription.lock.Lock()
+	if len(subscription.events) == subscription.bufferSize {
+		subscription.events = subscription.events[1:]
+		subscription.droppedEvents++
+	}
+	subscription.events = append(subscription.events, event)
+	subscription.lock.Unlock()
+
+	subscription.signal()
+}
+
+func (subscription *EventSubscription) close() {
+	subscription.lock.Lock()
+	subscription.closed = true
+	subscription.events = nil
+	subscription.lock.Unlock()
+
+	subscription.signal()
+}
+
+func (subscription *EventSubscription) signal() {
+	select {
+	case subscription.ready <- struct{}{}:
+	default:
+	}
+}
+
+// streamedEventType returns the type of the streamed events of the state
+// change event type
+func streamedEventType(eventType statechange.EventType) string {
+	if eventType == statechange.TaskEvent {
+		return TaskStreamedEventType
+	}
+	return ContainerStreamedEventType
+}
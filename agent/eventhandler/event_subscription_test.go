@@ -0,0 +1,129 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package eventhandler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSubscriptionReceivesEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The broadcaster doesn't need a socket for subscriptions
+	broadcaster, err := NewEventBroadcaster(ctx, "")
+	require.NoError(t, err)
+
+	subscription := broadcaster.Subscribe()
+	defer subscription.Close()
+
+	broadcaster.Broadcast(api.AttachmentStateChange{Attachment: &apieni.ENIAttachment{}})
+	broadcaster.Broadcast(containerEvent(taskARN))
+	broadcaster.Broadcast(taskEvent(taskARN))
+
+	event, ok := subscription.Next(ctx)
+	require.True(t, ok)
+	assert.Equal(t, ContainerStreamedEventType, event.Type)
+	assert.Equal(t, uint64(0), event.DroppedEvents)
+	var change map[string]interface{}
+	require.NoError(t, json.Unmarshal(event.Event, &change))
+	assert.Equal(t, "containerName", change["containerName"])
+
+	event, ok = subscription.Next(ctx)
+	require.True(t, ok)
+	assert.Equal(t, TaskStreamedEventType, event.Type)
+}
+
+func TestEventSubscriptionDropsOldestEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcaster, err := NewEventBroadcaster(ctx, "")
+	require.NoError(t, err)
+
+	subscription := broadcaster.subscribe(2)
+	defer subscription.Close()
+
+	broadcaster.Broadcast(containerEvent(taskARN))
+	broadcaster.Broadcast(taskEvent("task1"))
+	broadcaster.Broadcast(taskEvent("task2"))
+	broadcaster.Broadcast(taskEvent("task3"))
+
+	// The dropped events are reported with the next event received
+	event, ok := subscription.Next(ctx)
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), event.DroppedEvents)
+	assert.Contains(t, string(event.Event), "task2")
+
+	event, ok = subscription.Next(ctx)
+	require.True(t, ok)
+	assert.Equal(t, uint64(0), event.DroppedEvents)
+	assert.Contains(t, string(event.Event), "task3")
+}
+
+func TestEventSubscriptionNextWaitsForEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcaster, err := NewEventBroadcaster(ctx, "")
+	require.NoError(t, err)
+
+	subscription := broadcaster.Subscribe()
+	defer subscription.Close()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		broadcaster.Broadcast(taskEvent(taskARN))
+	}()
+	event, ok := subscription.Next(ctx)
+	require.True(t, ok)
+	assert.Equal(t, TaskStreamedEventType, event.Type)
+
+	nextCtx, nextCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer nextCancel()
+	_, ok = subscription.Next(nextCtx)
+	assert.False(t, ok, "Next should return once the context is cancelled")
+}
+
+func TestEventSubscriptionClosed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	broadcaster, err := NewEventBroadcaster(ctx, "")
+	require.NoError(t, err)
+
+	subscription := broadcaster.Subscribe()
+	subscription.Close()
+	broadcaster.Broadcast(taskEvent(taskARN))
+	_, ok := subscription.Next(context.Background())
+	assert.False(t, ok)
+	broadcaster.lock.RLock()
+	assert.Len(t, broadcaster.subscriptions, 0)
+	broadcaster.lock.RUnlock()
+
+	// The subscriptions are closed with the broadcaster
+	subscription = broadcaster.Subscribe()
+	cancel()
+	_, ok = subscription.Next(context.Background())
+	assert.False(t, ok)
+
+	// Subscriptions made after the broadcaster is closed are closed
+	_, ok = broadcaster.Subscribe().Next(context.Background())
+	assert.False(t, ok)
+}
@@ -19,7 +19,11 @@ import (
 	"github.com/cihub/seelog"
 )
 
-func HandleEngineEvents(taskEngine engine.TaskEngine, client api.ECSClient, eventhandler *TaskHandler) {
+// HandleEngineEvents adds the state change events emitted by the task engine to
+// the task handler, for them to be submitted to ECS. If broadcaster is not nil,
+// the events are also broadcast to its subscribers
+func HandleEngineEvents(taskEngine engine.TaskEngine, client api.ECSClient, eventhandler *TaskHandler,
+	broadcaster *EventBroadcaster) {
 	for {
 		stateChangeEvents := taskEngine.StateChangeEvents()
 
@@ -31,6 +35,9 @@ func HandleEngineEvents(taskEngine engine.TaskEngine, client api.ECSClient, even
 					seelog.Error("Unable to handle state change event. The events channel is closed")
 					break
 				}
+				if broadcaster != nil {
+					broadcaster.Broadcast(event)
+				}
 				err := eventhandler.AddStateChangeEvent(event, client)
 				if err != nil {
 					seelog.Errorf("Handler unable to add state change event %v: %v", event, err)
@@ -33,6 +33,7 @@ import (
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/ecs_client/model/ecs"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/statechange"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
@@ -41,6 +42,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const taskARN = "taskarn"
@@ -52,7 +54,7 @@ func TestSendsEventsOneContainer(t *testing.T) {
 	stateManager := statemanager.NewNoopStateManager()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, stateManager, nil, client)
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
 	defer cancel()
 
 	var wg sync.WaitGroup
@@ -84,7 +86,7 @@ func TestSendsEventsOneEventRetries(t *testing.T) {
 	stateManager := statemanager.NewNoopStateManager()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, stateManager, nil, client)
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
 	defer cancel()
 
 	var wg sync.WaitGroup
@@ -103,6 +105,99 @@ func TestSendsEventsOneEventRetries(t *testing.T) {
 	wg.Wait()
 }
 
+// TestSendsEventsAttachesContainerEventsBatchedDuringRetry tests that container
+// events batched while a task event is being retried are submitted along with
+// the task event, and are only marked as sent once the submission succeeds
+func TestSendsEventsAttachesContainerEventsBatchedDuringRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+	stateManager := statemanager.NewNoopStateManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	retriable := apierrors.NewRetriableError(apierrors.NewRetriable(true), errors.New("test"))
+	taskEvent := taskEvent(taskARN)
+	contEvent := containerEvent(taskARN)
+	container := contEvent.(api.ContainerStateChange).Container
+
+	gomock.InOrder(
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+			assert.Len(t, change.Containers, 0)
+			// Batch a container event while the task event is being submitted
+			handler.AddStateChangeEvent(contEvent, client)
+		}).Return(retriable),
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+			assert.Len(t, change.Containers, 1)
+			// The failed submission should not have marked the container as sent
+			assert.Equal(t, apicontainerstatus.ContainerStatusNone, container.GetSentStatus())
+			wg.Done()
+		}).Return(nil),
+	)
+
+	handler.AddStateChangeEvent(taskEvent, client)
+
+	wg.Wait()
+
+	// Wait for task events to be removed from the tasksToEvents map
+	for {
+		if handler.getTasksToEventsLen() == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, apicontainerstatus.ContainerRunning, container.GetSentStatus())
+}
+
+// TestSendsEventsCollapsesContainerEventsBatchedDuringRetry tests that only the
+// container event with the highest status is submitted for a container when
+// multiple events are batched for it while a task event is being retried
+func TestSendsEventsCollapsesContainerEventsBatchedDuringRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+	stateManager := statemanager.NewNoopStateManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	retriable := apierrors.NewRetriableError(apierrors.NewRetriable(true), errors.New("test"))
+	taskEvent := taskEvent(taskARN)
+	container := &apicontainer.Container{}
+	runningEvent := containerEvent(taskARN).(api.ContainerStateChange)
+	runningEvent.Container = container
+	stoppedEvent := containerEventStopped(taskARN).(api.ContainerStateChange)
+	stoppedEvent.Container = container
+
+	gomock.InOrder(
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+			assert.Len(t, change.Containers, 0)
+			// Batch two events for the same container while the task event
+			// is being submitted
+			handler.AddStateChangeEvent(runningEvent, client)
+			handler.AddStateChangeEvent(stoppedEvent, client)
+		}).Return(retriable),
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+			assert.Len(t, change.Containers, 1)
+			assert.Equal(t, apicontainerstatus.ContainerStopped, change.Containers[0].Status)
+			wg.Done()
+		}).Return(nil),
+	)
+
+	handler.AddStateChangeEvent(taskEvent, client)
+
+	wg.Wait()
+}
+
 func TestSendsEventsInvalidParametersEventsRemoved(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -110,7 +205,7 @@ func TestSendsEventsInvalidParametersEventsRemoved(t *testing.T) {
 	stateManager := statemanager.NewNoopStateManager()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, stateManager, nil, client)
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
 	defer cancel()
 
 	var wg sync.WaitGroup
@@ -126,10 +221,177 @@ func TestSendsEventsInvalidParametersEventsRemoved(t *testing.T) {
 	handler.AddStateChangeEvent(taskEvent, client)
 
 	wg.Wait()
-	// Require the lock to wait for submitFirstEvent to be finished
-	handler.tasksToEvents[taskARN].lock.Lock()
-	assert.Equal(t, 0, handler.tasksToEvents[taskARN].events.Len())
-	handler.tasksToEvents[taskARN].lock.Unlock()
+	// The event should be removed without being retried, which in turn
+	// removes the task from the tasksToEvents map
+	for {
+		if handler.getTasksToEventsLen() == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, apitaskstatus.TaskStatusNone, taskEvent.(api.TaskStateChange).Task.GetSentStatus())
+}
+
+// TestSendsEventsNonRetriableErrorUnblocksQueue tests that an event dropped
+// because of a non retriable error doesn't block the events queued after it for
+// the same task
+func TestSendsEventsNonRetriableErrorUnblocksQueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
+	defer cancel()
+
+	task := &apitask.Task{}
+	events := list.New()
+	rejected := newSendableTaskEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskRunning,
+		Task:    task,
+	})
+	events.PushBack(rejected)
+	events.PushBack(newSendableTaskEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskStopped,
+		Task:    task,
+	}))
+
+	gomock.InOrder(
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+			assert.Equal(t, apitaskstatus.TaskRunning, change.Status)
+		}).Return(awserr.New(ecs.ErrCodeClientException, "", nil)),
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+			assert.Equal(t, apitaskstatus.TaskStopped, change.Status)
+		}).Return(nil),
+	)
+
+	handler.submitTaskEvents(&taskSendableEvents{
+		events:  events,
+		taskARN: taskARN,
+	}, client, taskARN)
+
+	assert.Equal(t, apitaskstatus.TaskStopped, task.GetSentStatus())
+	// The rejected event is recorded as dropped, not as sent
+	assert.True(t, rejected.isDropped())
+	assert.False(t, rejected.taskSent)
+}
+
+// TestSendsEventsDroppedAfterMaxRetryDuration tests that an event failing with
+// retriable errors is dropped once it has been retried for longer than the max
+// submit retry duration
+func TestSendsEventsDroppedAfterMaxRetryDuration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+	stateManager := statemanager.NewNoopStateManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
+	handler.maxSubmitRetryDuration = time.Millisecond
+	defer cancel()
+
+	taskEvent := taskEvent(taskARN)
+
+	client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(interface{}) {
+		time.Sleep(2 * time.Millisecond)
+	}).Return(awserr.New("ThrottlingException", "", nil))
+
+	handler.AddStateChangeEvent(taskEvent, client)
+
+	for {
+		if handler.getTasksToEventsLen() == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, apitaskstatus.TaskStatusNone, taskEvent.(api.TaskStateChange).Task.GetSentStatus())
+}
+
+func TestGetEventStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
+	defer cancel()
+
+	submissionStarted := make(chan struct{})
+	unblockSubmission := make(chan struct{})
+	gomock.InOrder(
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+			close(submissionStarted)
+			<-unblockSubmission
+		}).Return(awserr.New(ecs.ErrCodeClientException, "", nil)),
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Return(nil),
+	)
+
+	task := &apitask.Task{}
+	handler.AddStateChangeEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskRunning,
+		Task:    task,
+	}, client)
+	handler.AddStateChangeEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskStopped,
+		Task:    task,
+	}, client)
+
+	<-submissionStarted
+	assert.Equal(t, EventStats{
+		Enqueued:    2,
+		QueueDepths: map[string]int{taskARN: 2},
+	}, handler.GetEventStats())
+	families := handler.Metrics()
+	require.Len(t, families, 2)
+	assert.Equal(t, "ecs_agent_state_change_events_queued", families[1].Name)
+	assert.Equal(t, []metrics.Sample{{Value: 2}}, families[1].Samples)
+
+	close(unblockSubmission)
+	for {
+		if handler.getTasksToEventsLen() == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, EventStats{
+		Enqueued:    2,
+		Submitted:   1,
+		Failed:      1,
+		Dropped:     1,
+		QueueDepths: map[string]int{},
+	}, handler.GetEventStats())
+	assert.Equal(t, []metrics.Sample{
+		{Labels: []metrics.Label{{Name: "result", Value: "enqueued"}}, Value: 2},
+		{Labels: []metrics.Label{{Name: "result", Value: "submitted"}}, Value: 1},
+		{Labels: []metrics.Label{{Name: "result", Value: "failed"}}, Value: 1},
+		{Labels: []metrics.Label{{Name: "result", Value: "dropped"}}, Value: 1},
+	}, handler.Metrics()[0].Samples)
+}
+
+func TestIsRetriableSubmitError(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		retriable bool
+	}{
+		{"invalid parameter", awserr.New(ecs.ErrCodeInvalidParameterException, "", nil), false},
+		{"client exception", awserr.New(ecs.ErrCodeClientException, "", nil), false},
+		{"server exception", awserr.New(ecs.ErrCodeServerException, "", nil), true},
+		{"throttling", awserr.New("ThrottlingException", "", nil), true},
+		{"connection reset", errors.New("read: connection reset by peer"), true},
+		{"non retriable", apierrors.NewRetriableError(apierrors.NewRetriable(false), errors.New("test")), false},
+		{"retriable", apierrors.NewRetriableError(apierrors.NewRetriable(true), errors.New("test")), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.retriable, isRetriableSubmitError(tc.err))
+		})
+	}
 }
 
 func TestSendsEventsConcurrentLimit(t *testing.T) {
@@ -139,7 +401,7 @@ func TestSendsEventsConcurrentLimit(t *testing.T) {
 	stateManager := statemanager.NewNoopStateManager()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, stateManager, nil, client)
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
 	defer cancel()
 
 	completeStateChange := make(chan bool, concurrentEventCalls+1)
@@ -171,6 +433,61 @@ func TestSendsEventsConcurrentLimit(t *testing.T) {
 	}
 }
 
+// TestSendsEventsHangingSubmissionDoesNotBlockOtherTasks tests that a
+// submission hanging for a task doesn't block the submission of the events of
+// other tasks, and that the events of the task are still submitted in order
+func TestSendsEventsHangingSubmissionDoesNotBlockOtherTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+	stateManager := statemanager.NewNoopStateManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
+	defer cancel()
+
+	taskARNA := "taskarnA"
+	taskARNB := "taskarnB"
+	unblockTaskA := make(chan struct{})
+	taskASubmissions := make(chan apitaskstatus.TaskStatus, 2)
+	taskBSubmitted := make(chan struct{})
+
+	client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+		switch change.TaskARN {
+		case taskARNA:
+			if change.Status == apitaskstatus.TaskRunning {
+				<-unblockTaskA
+			}
+			taskASubmissions <- change.Status
+		case taskARNB:
+			close(taskBSubmitted)
+		}
+	}).Times(3)
+
+	taskA := &apitask.Task{}
+	handler.AddStateChangeEvent(api.TaskStateChange{
+		TaskARN: taskARNA,
+		Status:  apitaskstatus.TaskRunning,
+		Task:    taskA,
+	}, client)
+	handler.AddStateChangeEvent(api.TaskStateChange{
+		TaskARN: taskARNA,
+		Status:  apitaskstatus.TaskStopped,
+		Task:    taskA,
+	}, client)
+	handler.AddStateChangeEvent(taskEventStopped(taskARNB), client)
+
+	select {
+	case <-taskBSubmitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the event of the other task to be submitted")
+	}
+
+	close(unblockTaskA)
+	assert.Equal(t, apitaskstatus.TaskRunning, <-taskASubmissions)
+	assert.Equal(t, apitaskstatus.TaskStopped, <-taskASubmissions)
+}
+
 func TestSendsEventsContainerDifferences(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -178,7 +495,7 @@ func TestSendsEventsContainerDifferences(t *testing.T) {
 	stateManager := statemanager.NewNoopStateManager()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, stateManager, nil, client)
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
 	defer cancel()
 
 	var wg sync.WaitGroup
@@ -212,7 +529,7 @@ func TestSendsEventsTaskDifferences(t *testing.T) {
 	stateManager := statemanager.NewNoopStateManager()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, stateManager, nil, client)
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
 	defer cancel()
 
 	taskARNA := "taskarnA"
@@ -262,7 +579,7 @@ func TestSendsEventsDedupe(t *testing.T) {
 	stateManager := statemanager.NewNoopStateManager()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, stateManager, nil, client)
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
 	defer cancel()
 
 	taskARNA := "taskarnA"
@@ -309,7 +626,7 @@ func TestCleanupTaskEventAfterSubmit(t *testing.T) {
 	client := mock_api.NewMockECSClient(ctrl)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, stateManager, nil, client)
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
 	defer cancel()
 
 	taskARN2 := "taskarn2"
@@ -341,6 +658,89 @@ func TestCleanupTaskEventAfterSubmit(t *testing.T) {
 	}
 }
 
+// TestStopSubmissionInFlightUntilSentStatusRecorded tests that the task is
+// marked as having its STOPPED state change in flight until the eventhandler
+// has recorded the sent status
+func TestStopSubmissionInFlightUntilSentStatusRecorded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stateManager := statemanager.NewNoopStateManager()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, stateManager, nil, client, nil)
+	defer cancel()
+
+	task := &apitask.Task{Arn: taskARN}
+	submitting := make(chan struct{})
+	unblock := make(chan struct{})
+	client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+		close(submitting)
+		<-unblock
+	})
+
+	handler.AddStateChangeEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskStopped,
+		Task:    task,
+	}, client)
+
+	<-submitting
+	assert.True(t, task.IsStopSubmissionInFlight())
+	assert.Equal(t, apitaskstatus.TaskStatusNone, task.GetSentStatus())
+	close(unblock)
+
+	for task.IsStopSubmissionInFlight() {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, apitaskstatus.TaskStopped, task.GetSentStatus())
+}
+
+// TestStopSubmissionInFlightAcrossRetries tests that the task is marked as
+// having its STOPPED state change in flight between the retries of its
+// submission
+func TestStopSubmissionInFlightAcrossRetries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := mock_api.NewMockECSClient(ctrl)
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
+	defer cancel()
+
+	task := &apitask.Task{Arn: taskARN}
+	taskEvents := &taskSendableEvents{events: list.New(),
+		sending:   false,
+		createdAt: time.Now(),
+		taskARN:   taskARN,
+	}
+	taskEvents.events.PushBack(newSendableTaskEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskStopped,
+		Task:    task,
+	}))
+
+	backoff := mock_utils.NewMockBackoff(ctrl)
+	gomock.InOrder(
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Return(errors.New("error")),
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Return(nil),
+		backoff.EXPECT().Reset(),
+	)
+
+	done, err := taskEvents.submitFirstEvent(handler, backoff)
+	assert.Error(t, err)
+	assert.False(t, done)
+	// The submission is retried, it's still in flight
+	assert.True(t, task.IsStopSubmissionInFlight())
+
+	done, err = taskEvents.submitFirstEvent(handler, backoff)
+	assert.NoError(t, err)
+	assert.True(t, done)
+	assert.False(t, task.IsStopSubmissionInFlight())
+	assert.Equal(t, apitaskstatus.TaskStopped, task.GetSentStatus())
+}
+
 func containerEvent(arn string) statechange.Event {
 	return api.ContainerStateChange{TaskArn: arn, ContainerName: "containerName", Status: apicontainerstatus.ContainerRunning, Container: &apicontainer.Container{}}
 }
@@ -388,7 +788,7 @@ func TestENISentStatusChange(t *testing.T) {
 	events := list.New()
 	events.PushBack(sendableTaskEvent)
 	ctx, cancel := context.WithCancel(context.Background())
-	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client)
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
 	defer cancel()
 	handler.submitTaskEvents(&taskSendableEvents{
 		events: events,
@@ -397,6 +797,109 @@ func TestENISentStatusChange(t *testing.T) {
 	assert.True(t, eniAttachment.AttachStatusSent)
 }
 
+func TestAttachmentStateChangeSentStatusChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	eniAttachment := &apieni.ENIAttachment{
+		TaskARN:          taskARN,
+		AttachmentARN:    "attachmentARN",
+		AttachStatusSent: false,
+		ExpiresAt:        time.Now().Add(time.Minute),
+	}
+	assert.NoError(t, eniAttachment.StartTimer(func() {}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	client.EXPECT().SubmitAttachmentStateChange(gomock.Any()).Do(func(change api.AttachmentStateChange) {
+		assert.Equal(t, eniAttachment, change.Attachment)
+		wg.Done()
+	}).Return(nil)
+
+	assert.NoError(t, handler.AddStateChangeEvent(api.AttachmentStateChange{
+		Attachment: eniAttachment,
+	}, client))
+	wg.Wait()
+
+	// Wait for task events to be removed from the tasksToEvents map
+	for handler.getTasksToEventsLen() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, eniAttachment.IsSent())
+}
+
+func TestExpiredAttachmentStateChangeDropped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
+	defer cancel()
+
+	// No calls to the client are expected for the expired attachment
+	assert.NoError(t, handler.AddStateChangeEvent(api.AttachmentStateChange{
+		Attachment: &apieni.ENIAttachment{
+			TaskARN:   taskARN,
+			ExpiresAt: time.Now().Add(-time.Second),
+		},
+	}, client))
+	assert.Equal(t, 0, handler.getTasksToEventsLen())
+}
+
+func TestExpiredTaskAttachmentStateChangeDropped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
+	defer cancel()
+
+	// No calls to the client are expected for the expired attachment
+	assert.NoError(t, handler.AddStateChangeEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Attachment: &apieni.ENIAttachment{
+			TaskARN:   taskARN,
+			ExpiresAt: time.Now().Add(-time.Second),
+		},
+	}, client))
+	assert.Equal(t, 0, handler.getTasksToEventsLen())
+}
+
+// TestRejectedAttachmentStateChangeRecordsSubmissionRejected tests that the
+// attachment of an attachment state change rejected by ECS is recorded as
+// rejected, for the attached status not to be sent again
+func TestRejectedAttachmentStateChangeRecordsSubmissionRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
+	defer cancel()
+
+	eniAttachment := &apieni.ENIAttachment{
+		TaskARN:   taskARN,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	client.EXPECT().SubmitAttachmentStateChange(gomock.Any()).Return(
+		awserr.New(ecs.ErrCodeClientException, "", nil))
+
+	assert.NoError(t, handler.AddStateChangeEvent(api.AttachmentStateChange{
+		Attachment: eniAttachment,
+	}, client))
+	for !eniAttachment.IsSubmissionRejected() {
+		time.Sleep(time.Millisecond)
+	}
+	assert.False(t, eniAttachment.IsSent())
+}
+
 func TestGetBatchedContainerEvents(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -452,6 +955,7 @@ func TestSubmitTaskEventsWhenSubmittingTaskRunningAfterStopped(t *testing.T) {
 		tasksToEvents:          make(map[string]*taskSendableEvents),
 		tasksToContainerStates: make(map[string][]api.ContainerStateChange),
 		stateSaver:             stateManager,
+		pendingStateChanges:    NewPendingStateChanges(),
 		client:                 client,
 	}
 
@@ -516,6 +1020,7 @@ func TestSubmitTaskEventsWhenSubmittingTaskStoppedAfterRunning(t *testing.T) {
 		tasksToEvents:          make(map[string]*taskSendableEvents),
 		tasksToContainerStates: make(map[string][]api.ContainerStateChange),
 		stateSaver:             stateManager,
+		pendingStateChanges:    NewPendingStateChanges(),
 		client:                 client,
 	}
 
@@ -575,3 +1080,318 @@ func TestSubmitTaskEventsWhenSubmittingTaskStoppedAfterRunning(t *testing.T) {
 	assert.NoError(t, err)
 	wg.Wait()
 }
+
+// TestSubmitTaskEventsDropsTaskStateChangeSubmittedOutOfOrder tests that a
+// task state change that completes its retries after a later state change of
+// the task has been submitted is dropped instead of being submitted
+func TestSubmitTaskEventsDropsTaskStateChangeSubmittedOutOfOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := mock_api.NewMockECSClient(ctrl)
+	handler := &TaskHandler{
+		submitSemaphore:        utils.NewSemaphore(concurrentEventCalls),
+		tasksToEvents:          make(map[string]*taskSendableEvents),
+		tasksToContainerStates: make(map[string][]api.ContainerStateChange),
+		stateSaver:             statemanager.NewNoopStateManager(),
+		pendingStateChanges:    NewPendingStateChanges(),
+		client:                 client,
+	}
+	taskEvents := &taskSendableEvents{events: list.New(),
+		sending:   true,
+		createdAt: time.Now(),
+		taskARN:   taskARN,
+	}
+
+	container := &apicontainer.Container{
+		Name:              "c1",
+		KnownStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	task := &apitask.Task{
+		Arn:               taskARN,
+		KnownStatusUnsafe: apitaskstatus.TaskRunning,
+		Containers:        []*apicontainer.Container{container},
+	}
+	running, err := api.NewTaskStateChangeEvent(task, "")
+	require.NoError(t, err)
+	running.Containers = []api.ContainerStateChange{{
+		TaskArn:       taskARN,
+		ContainerName: container.Name,
+		Status:        apicontainerstatus.ContainerRunning,
+		Container:     container,
+	}}
+	task.SetKnownStatus(apitaskstatus.TaskStopped)
+	stopped, err := api.NewTaskStateChangeEvent(task, "")
+	require.NoError(t, err)
+	require.True(t, running.SequenceNumber < stopped.SequenceNumber)
+
+	// The retry of the RUNNING state change completes after the STOPPED
+	// state change has been submitted
+	taskEvents.events.PushBack(newSendableTaskEvent(stopped))
+	taskEvents.events.PushBack(newSendableTaskEvent(running))
+
+	backoff := mock_utils.NewMockBackoff(ctrl)
+	gomock.InOrder(
+		client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+			assert.Equal(t, apitaskstatus.TaskStopped, change.Status)
+		}),
+		backoff.EXPECT().Reset(),
+	)
+	ok, err := taskEvents.submitFirstEvent(handler, backoff)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, stopped.SequenceNumber, task.GetSubmittedStateChangeSequence())
+
+	// The RUNNING state change is dropped without being submitted
+	ok, err = taskEvents.submitFirstEvent(handler, backoff)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, apicontainerstatus.ContainerStatusNone, container.GetSentStatus())
+	assert.Equal(t, uint64(1), handler.GetEventStats().Dropped)
+}
+
+// TestFlushBatchMergesIdenticalQueuedTaskEvents tests that a task event that is
+// identical to one already queued for the task is merged into the queued event
+// instead of being submitted again
+func TestFlushBatchMergesIdenticalQueuedTaskEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := mock_api.NewMockECSClient(ctrl)
+	handler := &TaskHandler{
+		submitSemaphore:        utils.NewSemaphore(concurrentEventCalls),
+		tasksToEvents:          make(map[string]*taskSendableEvents),
+		tasksToContainerStates: make(map[string][]api.ContainerStateChange),
+		stateSaver:             statemanager.NewNoopStateManager(),
+		pendingStateChanges:    NewPendingStateChanges(),
+		client:                 client,
+	}
+
+	task := &apitask.Task{}
+	container := &apicontainer.Container{}
+	queued := newSendableTaskEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskStopped,
+		Reason:  "first reason",
+		Task:    task,
+		Containers: []api.ContainerStateChange{{
+			TaskArn:       taskARN,
+			ContainerName: "containerName",
+			Status:        apicontainerstatus.ContainerStopped,
+			Container:     container,
+		}},
+	})
+	// Pretend that the queued event is being submitted so that flushing the
+	// batch doesn't start another submission
+	taskEvents := &taskSendableEvents{events: list.New(),
+		sending:   true,
+		createdAt: time.Now(),
+		taskARN:   taskARN,
+	}
+	taskEvents.events.PushBack(queued)
+	handler.tasksToEvents[taskARN] = taskEvents
+
+	executionStoppedAt := time.Now()
+	handler.batchContainerEventUnsafe(api.ContainerStateChange{
+		TaskArn:       taskARN,
+		ContainerName: "containerName",
+		Status:        apicontainerstatus.ContainerStopped,
+		Container:     container,
+	})
+	handler.flushBatchUnsafe(&api.TaskStateChange{
+		TaskARN:            taskARN,
+		Status:             apitaskstatus.TaskStopped,
+		Reason:             "second reason",
+		ExecutionStoppedAt: &executionStoppedAt,
+		Task:               task,
+	}, client)
+
+	assert.Equal(t, 1, taskEvents.events.Len())
+	assert.Equal(t, "second reason", queued.taskChange.Reason)
+	assert.Equal(t, &executionStoppedAt, queued.taskChange.ExecutionStoppedAt)
+	assert.Equal(t, task, queued.taskChange.Task)
+	if assert.Len(t, queued.taskChange.Containers, 1) {
+		assert.Equal(t, container, queued.taskChange.Containers[0].Container)
+	}
+
+	// A task event with a different status is queued on its own
+	handler.flushBatchUnsafe(&api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskRunning,
+		Task:    task,
+	}, client)
+	assert.Equal(t, 2, taskEvents.events.Len())
+}
+
+func TestFlushBatchFoldsSupersededContainerEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := mock_api.NewMockECSClient(ctrl)
+	handler := &TaskHandler{
+		submitSemaphore:        utils.NewSemaphore(concurrentEventCalls),
+		tasksToEvents:          make(map[string]*taskSendableEvents),
+		tasksToContainerStates: make(map[string][]api.ContainerStateChange),
+		stateSaver:             statemanager.NewNoopStateManager(),
+		pendingStateChanges:    NewPendingStateChanges(),
+		client:                 client,
+	}
+
+	task := &apitask.Task{}
+	container := &apicontainer.Container{}
+	startedAt := time.Now()
+	queued := newSendableTaskEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskRunning,
+		Task:    task,
+		Containers: []api.ContainerStateChange{{
+			TaskArn:       taskARN,
+			ContainerName: "containerName",
+			Status:        apicontainerstatus.ContainerRunning,
+			StartedAt:     &startedAt,
+			Container:     container,
+		}},
+	})
+	// Pretend that the task's events are being submitted so that flushing the
+	// batch doesn't start another submission
+	taskEvents := &taskSendableEvents{events: list.New(),
+		sending:   true,
+		createdAt: time.Now(),
+		taskARN:   taskARN,
+	}
+	taskEvents.events.PushBack(queued)
+	handler.tasksToEvents[taskARN] = taskEvents
+
+	finishedAt := startedAt.Add(time.Second)
+	handler.batchContainerEventUnsafe(api.ContainerStateChange{
+		TaskArn:       taskARN,
+		ContainerName: "containerName",
+		Status:        apicontainerstatus.ContainerStopped,
+		FinishedAt:    &finishedAt,
+		Container:     container,
+	})
+	handler.flushBatchUnsafe(&api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskStopped,
+		Task:    task,
+	}, client)
+
+	// The RUNNING container state change is folded into the STOPPED one
+	require.Equal(t, 2, taskEvents.events.Len())
+	assert.Empty(t, queued.taskChange.Containers)
+	stopped := taskEvents.events.Back().Value.(*sendableEvent)
+	require.Len(t, stopped.taskChange.Containers, 1)
+	containerChange := stopped.taskChange.Containers[0]
+	assert.Equal(t, apicontainerstatus.ContainerStopped, containerChange.Status)
+	assert.Equal(t, &startedAt, containerChange.StartedAt)
+	assert.Equal(t, &finishedAt, containerChange.FinishedAt)
+}
+
+func TestFlushBatchDoesNotFoldContainerEventsBeingSubmitted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := mock_api.NewMockECSClient(ctrl)
+	handler := &TaskHandler{
+		submitSemaphore:        utils.NewSemaphore(concurrentEventCalls),
+		tasksToEvents:          make(map[string]*taskSendableEvents),
+		tasksToContainerStates: make(map[string][]api.ContainerStateChange),
+		stateSaver:             statemanager.NewNoopStateManager(),
+		pendingStateChanges:    NewPendingStateChanges(),
+		client:                 client,
+	}
+
+	task := &apitask.Task{}
+	container := &apicontainer.Container{}
+	submitting := newSendableTaskEvent(api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskRunning,
+		Task:    task,
+		Containers: []api.ContainerStateChange{{
+			TaskArn:       taskARN,
+			ContainerName: "containerName",
+			Status:        apicontainerstatus.ContainerRunning,
+			Container:     container,
+		}},
+	})
+	taskEvents := &taskSendableEvents{events: list.New(),
+		sending:    true,
+		submitting: submitting,
+		createdAt:  time.Now(),
+		taskARN:    taskARN,
+	}
+	taskEvents.events.PushBack(submitting)
+	handler.tasksToEvents[taskARN] = taskEvents
+
+	handler.flushBatchUnsafe(&api.TaskStateChange{
+		TaskARN: taskARN,
+		Status:  apitaskstatus.TaskStopped,
+		Task:    task,
+		Containers: []api.ContainerStateChange{{
+			TaskArn:       taskARN,
+			ContainerName: "containerName",
+			Status:        apicontainerstatus.ContainerStopped,
+			Container:     container,
+		}},
+	}, client)
+
+	assert.Equal(t, 2, taskEvents.events.Len())
+	assert.Len(t, submitting.taskChange.Containers, 1)
+}
+
+// lockCheckingSaver records the saves of the state, and whether the lock of
+// the task handler was held while saving
+type lockCheckingSaver struct {
+	handler  *TaskHandler
+	lock     sync.Mutex
+	saves    int
+	lockHeld bool
+}
+
+func (saver *lockCheckingSaver) Save() error {
+	acquired := make(chan struct{})
+	go func() {
+		saver.handler.getTasksToEventsLen()
+		close(acquired)
+	}()
+	lockHeld := false
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		lockHeld = true
+	}
+
+	saver.lock.Lock()
+	defer saver.lock.Unlock()
+	saver.saves++
+	saver.lockHeld = saver.lockHeld || lockHeld
+	return nil
+}
+
+func (saver *lockCheckingSaver) ForceSave() error {
+	return saver.Save()
+}
+
+func TestAddStateChangeEventSavesWithoutHoldingLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, nil)
+	saver := &lockCheckingSaver{handler: handler}
+	handler.stateSaver = saver
+
+	assert.NoError(t, handler.AddStateChangeEvent(api.ContainerStateChange{
+		TaskArn:       taskARN,
+		ContainerName: "containerName",
+		Status:        apicontainerstatus.ContainerRunning,
+		Container:     &apicontainer.Container{},
+	}, client))
+
+	saver.lock.Lock()
+	defer saver.lock.Unlock()
+	assert.Equal(t, 1, saver.saves)
+	assert.False(t, saver.lockHeld, "state shouldn't be saved while holding the lock of the handler")
+}
@@ -0,0 +1,219 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package eventhandler
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+)
+
+// PendingStateChanges keeps track of the state changes that have been queued
+// by the TaskHandler but haven't been submitted to ECS yet. It is saved in
+// the state file so that these state changes can be submitted after the agent
+// restarts
+type PendingStateChanges struct {
+	// taskStateChanges is the set of task state changes queued for
+	// submission, keyed by the sendable event that is used to submit them
+	taskStateChanges map[*sendableEvent]pendingTaskStateChange
+	// containerStateChanges is the list of container state changes batched
+	// for each task arn
+	containerStateChanges map[string][]pendingContainerStateChange
+	// restored is the list of state changes loaded from the state file which
+	// haven't been queued again yet
+	restored pendingStateChangesJSON
+	// sequence is used to preserve the order in which task state changes
+	// have been queued
+	sequence uint64
+
+	lock sync.RWMutex
+}
+
+// pendingStateChangesJSON is the json representation of PendingStateChanges
+type pendingStateChangesJSON struct {
+	TaskStateChanges      []pendingTaskStateChange      `json:"TaskStateChanges,omitempty"`
+	ContainerStateChanges []pendingContainerStateChange `json:"ContainerStateChanges,omitempty"`
+}
+
+// pendingTaskStateChange is the saved form of a task state change
+type pendingTaskStateChange struct {
+	TaskARN            string
+	Status             apitaskstatus.TaskStatus
+	Reason             string                        `json:",omitempty"`
+	ReasonCode         apireason.ReasonCode          `json:",omitempty"`
+	SequenceNumber     uint64                        `json:",omitempty"`
+	Containers         []pendingContainerStateChange `json:",omitempty"`
+	PullStartedAt      *time.Time                    `json:",omitempty"`
+	PullStoppedAt      *time.Time                    `json:",omitempty"`
+	ExecutionStoppedAt *time.Time                    `json:",omitempty"`
+
+	sequence uint64
+}
+
+// pendingContainerStateChange is the saved form of a container state change
+type pendingContainerStateChange struct {
+	TaskARN       string
+	ContainerName string
+	RuntimeID     string `json:",omitempty"`
+	Status        apicontainerstatus.ContainerStatus
+	Reason        string               `json:",omitempty"`
+	ReasonCode    apireason.ReasonCode `json:",omitempty"`
+	ExitCode      *int                 `json:",omitempty"`
+}
+
+// NewPendingStateChanges returns an empty PendingStateChanges object
+func NewPendingStateChanges() *PendingStateChanges {
+	return &PendingStateChanges{
+		taskStateChanges:      make(map[*sendableEvent]pendingTaskStateChange),
+		containerStateChanges: make(map[string][]pendingContainerStateChange),
+	}
+}
+
+func newPendingContainerStateChange(change api.ContainerStateChange) pendingContainerStateChange {
+	return pendingContainerStateChange{
+		TaskARN:       change.TaskArn,
+		ContainerName: change.ContainerName,
+		RuntimeID:     change.RuntimeID,
+		Status:        change.Status,
+		Reason:        change.Reason,
+		ReasonCode:    change.ReasonCode,
+		ExitCode:      change.ExitCode,
+	}
+}
+
+// queueTaskStateChange records the task state change of the event as queued
+// for submission. Calling it again for the same event updates the recorded
+// state change, which is needed when container events get attached to it
+func (pending *PendingStateChanges) queueTaskStateChange(event *sendableEvent) {
+	event.lock.RLock()
+	defer event.lock.RUnlock()
+
+	if event.isContainerEvent || event.isAttachmentEvent || event.taskChange.Attachment != nil {
+		// Attachment state changes are resent by the attachment's ack timer
+		// and don't need to be saved
+		return
+	}
+	change := event.taskChange
+	pendingChange := pendingTaskStateChange{
+		TaskARN:            change.TaskARN,
+		Status:             change.Status,
+		Reason:             change.Reason,
+		ReasonCode:         change.ReasonCode,
+		SequenceNumber:     change.SequenceNumber,
+		PullStartedAt:      change.PullStartedAt,
+		PullStoppedAt:      change.PullStoppedAt,
+		ExecutionStoppedAt: change.ExecutionStoppedAt,
+	}
+	for _, containerChange := range change.Containers {
+		pendingChange.Containers = append(pendingChange.Containers,
+			newPendingContainerStateChange(containerChange))
+	}
+
+	pending.lock.Lock()
+	defer pending.lock.Unlock()
+
+	if existing, ok := pending.taskStateChanges[event]; ok {
+		pendingChange.sequence = existing.sequence
+	} else {
+		pending.sequence++
+		pendingChange.sequence = pending.sequence
+	}
+	pending.taskStateChanges[event] = pendingChange
+}
+
+// removeTaskStateChange removes the task state change of the event once it
+// is no longer queued for submission
+func (pending *PendingStateChanges) removeTaskStateChange(event *sendableEvent) {
+	pending.lock.Lock()
+	defer pending.lock.Unlock()
+
+	delete(pending.taskStateChanges, event)
+}
+
+// setContainerStateChanges records the container state changes batched for
+// the task
+func (pending *PendingStateChanges) setContainerStateChanges(taskARN string, changes []api.ContainerStateChange) {
+	pending.lock.Lock()
+	defer pending.lock.Unlock()
+
+	if len(changes) == 0 {
+		delete(pending.containerStateChanges, taskARN)
+		return
+	}
+	pendingChanges := make([]pendingContainerStateChange, 0, len(changes))
+	for _, change := range changes {
+		pendingChanges = append(pendingChanges, newPendingContainerStateChange(change))
+	}
+	pending.containerStateChanges[taskARN] = pendingChanges
+}
+
+// takeRestored returns the state changes loaded from the state file and
+// forgets about them, as they are expected to be queued again by the caller
+func (pending *PendingStateChanges) takeRestored() pendingStateChangesJSON {
+	pending.lock.Lock()
+	defer pending.lock.Unlock()
+
+	restored := pending.restored
+	pending.restored = pendingStateChangesJSON{}
+	return restored
+}
+
+// MarshalJSON marshals the pending state changes, ordering the task state
+// changes in the order in which they were queued
+func (pending *PendingStateChanges) MarshalJSON() ([]byte, error) {
+	pending.lock.RLock()
+	defer pending.lock.RUnlock()
+
+	var taskStateChanges []pendingTaskStateChange
+	for _, change := range pending.taskStateChanges {
+		taskStateChanges = append(taskStateChanges, change)
+	}
+	sort.Slice(taskStateChanges, func(i, j int) bool {
+		return taskStateChanges[i].sequence < taskStateChanges[j].sequence
+	})
+
+	// State changes which haven't been queued again since they were loaded
+	// are saved ahead of the ones queued since
+	toMarshal := pendingStateChangesJSON{
+		TaskStateChanges: append(append([]pendingTaskStateChange{},
+			pending.restored.TaskStateChanges...), taskStateChanges...),
+		ContainerStateChanges: append([]pendingContainerStateChange{},
+			pending.restored.ContainerStateChanges...),
+	}
+	for _, changes := range pending.containerStateChanges {
+		toMarshal.ContainerStateChanges = append(toMarshal.ContainerStateChanges, changes...)
+	}
+	return json.Marshal(&toMarshal)
+}
+
+// UnmarshalJSON loads the pending state changes saved in the state file. They
+// are queued for submission again by TaskHandler.RestorePendingStateChanges
+func (pending *PendingStateChanges) UnmarshalJSON(b []byte) error {
+	var restored pendingStateChangesJSON
+	if err := json.Unmarshal(b, &restored); err != nil {
+		return err
+	}
+
+	pending.lock.Lock()
+	defer pending.lock.Unlock()
+
+	pending.restored = restored
+	return nil
+}
@@ -0,0 +1,234 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package eventhandler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	"github.com/aws/amazon-ecs-agent/agent/api/mocks"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingStateChangesMarshalUnmarshal(t *testing.T) {
+	pending := NewPendingStateChanges()
+	exitCode := 1
+	executionStoppedAt := time.Unix(1500000000, 0).UTC()
+
+	stoppedEvent := newSendableTaskEvent(api.TaskStateChange{
+		TaskARN:            taskARN,
+		Status:             apitaskstatus.TaskStopped,
+		Reason:             "Essential container in task exited",
+		SequenceNumber:     2,
+		ExecutionStoppedAt: aws.Time(executionStoppedAt),
+		Containers: []api.ContainerStateChange{{
+			TaskArn:       taskARN,
+			ContainerName: "containerName",
+			Status:        apicontainerstatus.ContainerStopped,
+			ExitCode:      &exitCode,
+		}},
+	})
+	runningEvent := newSendableTaskEvent(api.TaskStateChange{
+		TaskARN: "runningTaskARN",
+		Status:  apitaskstatus.TaskRunning,
+	})
+	pending.queueTaskStateChange(stoppedEvent)
+	pending.queueTaskStateChange(runningEvent)
+	pending.setContainerStateChanges("runningTaskARN", []api.ContainerStateChange{{
+		TaskArn:       "runningTaskARN",
+		ContainerName: "containerName",
+		Status:        apicontainerstatus.ContainerRunning,
+	}})
+
+	b, err := json.Marshal(pending)
+	require.NoError(t, err)
+
+	loaded := NewPendingStateChanges()
+	require.NoError(t, json.Unmarshal(b, loaded))
+	restored := loaded.takeRestored()
+
+	require.Len(t, restored.TaskStateChanges, 2)
+	stopped := restored.TaskStateChanges[0]
+	assert.Equal(t, taskARN, stopped.TaskARN)
+	assert.Equal(t, apitaskstatus.TaskStopped, stopped.Status)
+	assert.Equal(t, "Essential container in task exited", stopped.Reason)
+	assert.Equal(t, uint64(2), stopped.SequenceNumber)
+	assert.True(t, executionStoppedAt.Equal(aws.TimeValue(stopped.ExecutionStoppedAt)))
+	require.Len(t, stopped.Containers, 1)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, stopped.Containers[0].Status)
+	assert.Equal(t, exitCode, aws.IntValue(stopped.Containers[0].ExitCode))
+	assert.Equal(t, apitaskstatus.TaskRunning, restored.TaskStateChanges[1].Status)
+
+	require.Len(t, restored.ContainerStateChanges, 1)
+	assert.Equal(t, apicontainerstatus.ContainerRunning, restored.ContainerStateChanges[0].Status)
+
+	// The restored state changes are only handed out once
+	assert.Empty(t, loaded.takeRestored().TaskStateChanges)
+}
+
+func TestPendingStateChangesRemovedAfterSubmission(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pending := NewPendingStateChanges()
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), nil, client, pending)
+
+	client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(interface{}) {
+		b, err := json.Marshal(pending)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), taskARN)
+	}).Return(nil)
+
+	handler.AddStateChangeEvent(taskEvent(taskARN), client)
+
+	// Wait for task events to be removed from the tasksToEvents map
+	for handler.getTasksToEventsLen() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	b, err := json.Marshal(pending)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(b))
+}
+
+func TestPendingStateChangesSavedBeforeSubmission(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+
+	dir, err := ioutil.TempDir("", "pending-state-changes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{DataDir: dir}
+
+	pending := NewPendingStateChanges()
+	stateManager, err := statemanager.NewStateManager(cfg,
+		statemanager.AddSaveable("PendingStateChanges", pending))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := NewTaskHandler(ctx, stateManager, nil, client, pending)
+
+	submitted := make(chan struct{})
+	client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(interface{}) {
+		// The agent restarting while the state change is being submitted
+		// finds it in the state file
+		restarted := NewPendingStateChanges()
+		restartedStateManager, err := statemanager.NewStateManager(cfg,
+			statemanager.AddSaveable("PendingStateChanges", restarted))
+		require.NoError(t, err)
+		require.NoError(t, restartedStateManager.Load())
+		restored := restarted.takeRestored()
+		if assert.Len(t, restored.TaskStateChanges, 1) {
+			assert.Equal(t, taskARN, restored.TaskStateChanges[0].TaskARN)
+			assert.Equal(t, apitaskstatus.TaskRunning, restored.TaskStateChanges[0].Status)
+		}
+		close(submitted)
+	}).Return(nil)
+
+	handler.AddStateChangeEvent(taskEvent(taskARN), client)
+	<-submitted
+}
+
+func TestRestorePendingStateChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_api.NewMockECSClient(ctrl)
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+
+	container := &apicontainer.Container{
+		Name:              "containerName",
+		KnownStatusUnsafe: apicontainerstatus.ContainerStopped,
+	}
+	task := &apitask.Task{
+		Arn:               taskARN,
+		KnownStatusUnsafe: apitaskstatus.TaskStopped,
+		SentStatusUnsafe:  apitaskstatus.TaskRunning,
+		Containers:        []*apicontainer.Container{container},
+	}
+	sentTask := &apitask.Task{
+		Arn:               "sentTaskARN",
+		KnownStatusUnsafe: apitaskstatus.TaskStopped,
+		SentStatusUnsafe:  apitaskstatus.TaskStopped,
+	}
+	state.EXPECT().TaskByArn(taskARN).Return(task, true)
+	state.EXPECT().TaskByArn("sentTaskARN").Return(sentTask, true)
+	state.EXPECT().TaskByArn("unknownTaskARN").Return(nil, false)
+
+	pending := NewPendingStateChanges()
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"TaskStateChanges": [
+			{"TaskARN": "sentTaskARN", "Status": "STOPPED"},
+			{"TaskARN": "unknownTaskARN", "Status": "STOPPED"},
+			{
+				"TaskARN": "taskarn",
+				"Status": "STOPPED",
+				"Reason": "Essential container in task exited",
+				"Containers": [
+					{"TaskARN": "taskarn", "ContainerName": "containerName", "Status": "STOPPED", "ExitCode": 137}
+				]
+			}
+		]
+	}`), pending))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := NewTaskHandler(ctx, statemanager.NewNoopStateManager(), state, client, pending)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	client.EXPECT().SubmitTaskStateChange(gomock.Any()).Do(func(change api.TaskStateChange) {
+		assert.Equal(t, taskARN, change.TaskARN)
+		assert.Equal(t, apitaskstatus.TaskStopped, change.Status)
+		assert.Equal(t, "Essential container in task exited", change.Reason)
+		assert.Equal(t, task, change.Task)
+		if assert.Len(t, change.Containers, 1) {
+			assert.Equal(t, container, change.Containers[0].Container)
+			assert.Equal(t, 137, aws.IntValue(change.Containers[0].ExitCode))
+		}
+		wg.Done()
+	}).Return(nil)
+
+	handler.RestorePendingStateChanges()
+	wg.Wait()
+
+	// Wait for task events to be removed from the tasksToEvents map
+	for handler.getTasksToEventsLen() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, apitaskstatus.TaskStopped, task.GetSentStatus())
+	assert.Equal(t, apicontainerstatus.ContainerStopped, container.GetSentStatus())
+}
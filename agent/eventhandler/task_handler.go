@@ -22,6 +22,8 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/ecs_client/model/ecs"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
@@ -32,9 +34,10 @@ import (
 )
 
 const (
-	// concurrentEventCalls is the maximum number of tasks that may be handled at
-	// once by the TaskHandler
-	concurrentEventCalls = 10
+	// concurrentEventCalls is the maximum number of state change submissions
+	// that may be in flight at once across all the tasks handled by the
+	// TaskHandler
+	concurrentEventCalls = 20
 
 	// drainEventsFrequency is the frequency at the which unsent events batched
 	// by the task handler are sent to the backend
@@ -45,12 +48,19 @@ const (
 	submitStateBackoffMax            = 30 * time.Second
 	submitStateBackoffJitterMultiple = 0.20
 	submitStateBackoffMultiple       = 1.3
+
+	// maxSubmitRetryDuration is the duration for which the submission of an
+	// event is retried before the event is dropped. The submit state change
+	// SDK client already retries for roughly a day, so this only protects
+	// against events that would otherwise wedge the queue of the task forever
+	maxSubmitRetryDuration = 24 * time.Hour
 )
 
 // TaskHandler encapsulates the the map of a task arn to task and container events
 // associated with said task
 type TaskHandler struct {
-	// submitSemaphore for the number of tasks that may be handled at once
+	// submitSemaphore for the number of submissions that may be in flight at
+	// once
 	submitSemaphore utils.Semaphore
 	// taskToEvents is arn:*eventList map so events may be serialized per task
 	tasksToEvents map[string]*taskSendableEvents
@@ -67,6 +77,10 @@ type TaskHandler struct {
 	// changes to a task or container's SentStatus
 	stateSaver statemanager.Saver
 
+	// pendingStateChanges keeps track of the state changes which haven't
+	// been submitted yet, so that they can be saved in the state file
+	pendingStateChanges *PendingStateChanges
+
 	// min and max drain events frequency refer to the range of
 	// time over which a call to SubmitTaskStateChange is made.
 	// The actual duration is randomly distributed between these
@@ -74,6 +88,14 @@ type TaskHandler struct {
 	minDrainEventsFrequency time.Duration
 	maxDrainEventsFrequency time.Duration
 
+	// maxSubmitRetryDuration is the duration after which an event that keeps
+	// failing to be submitted with retriable errors is dropped
+	maxSubmitRetryDuration time.Duration
+
+	// counters are the counters of the events handled by the handler, which
+	// are exposed by the introspection server
+	counters eventCounters
+
 	state  dockerstate.TaskEngineState
 	client api.ECSClient
 	ctx    context.Context
@@ -88,6 +110,11 @@ type taskSendableEvents struct {
 	events *list.List
 	// sending will check whether the list is already being handled
 	sending bool
+	// submitting is the event at the front of the queue whose submission is
+	// in flight. The list lock isn't held while submitting the event, so that
+	// events can still be queued for the task, and it must not be modified
+	// by other goroutines until the submission completes
+	submitting *sendableEvent
 	//eventsListLock locks both the list and sending bool
 	lock sync.Mutex
 	// createdAt is a timestamp for when the event list was created
@@ -96,11 +123,17 @@ type taskSendableEvents struct {
 	taskARN string
 }
 
-// NewTaskHandler returns a pointer to TaskHandler
+// NewTaskHandler returns a pointer to TaskHandler. The state changes queued by
+// the handler are tracked in pendingStateChanges, which may be nil if they
+// don't need to be saved
 func NewTaskHandler(ctx context.Context,
 	stateManager statemanager.Saver,
 	state dockerstate.TaskEngineState,
-	client api.ECSClient) *TaskHandler {
+	client api.ECSClient,
+	pendingStateChanges *PendingStateChanges) *TaskHandler {
+	if pendingStateChanges == nil {
+		pendingStateChanges = NewPendingStateChanges()
+	}
 	// Create a handler and start the periodic event drain loop
 	taskHandler := &TaskHandler{
 		ctx:                     ctx,
@@ -108,10 +141,12 @@ func NewTaskHandler(ctx context.Context,
 		submitSemaphore:         utils.NewSemaphore(concurrentEventCalls),
 		tasksToContainerStates:  make(map[string][]api.ContainerStateChange),
 		stateSaver:              stateManager,
+		pendingStateChanges:     pendingStateChanges,
 		state:                   state,
 		client:                  client,
 		minDrainEventsFrequency: minDrainEventsFrequency,
 		maxDrainEventsFrequency: maxDrainEventsFrequency,
+		maxSubmitRetryDuration:  maxSubmitRetryDuration,
 	}
 	go taskHandler.startDrainEventsTicker()
 
@@ -123,8 +158,22 @@ func NewTaskHandler(ctx context.Context,
 // handler.tasksToContainerStates map.
 // If the event is for task state change, it triggers the non-blocking
 // handler.submitTaskEvents method to submit the batched container state
-// changes and the task state change to ECS
+// changes and the task state change to ECS.
+// If the event is for an attachment state change, it triggers the
+// non-blocking handler.submitTaskEvents method to submit it on its own
 func (handler *TaskHandler) AddStateChangeEvent(change statechange.Event, client api.ECSClient) error {
+	queued, err := handler.queueStateChangeEvent(change, client)
+	if queued {
+		// The state is saved once the lock of the handler is released, so
+		// that queueing events isn't held up by writing the state file
+		handler.stateSaver.Save()
+	}
+	return err
+}
+
+// queueStateChangeEvent queues up the state change event to be sent to ECS. It
+// returns true if the pending state changes to be saved have changed
+func (handler *TaskHandler) queueStateChangeEvent(change statechange.Event, client api.ECSClient) (bool, error) {
 	handler.lock.Lock()
 	defer handler.lock.Unlock()
 
@@ -132,27 +181,122 @@ func (handler *TaskHandler) AddStateChangeEvent(change statechange.Event, client
 	case statechange.TaskEvent:
 		event, ok := change.(api.TaskStateChange)
 		if !ok {
-			return errors.New("eventhandler: unable to get task event from state change event")
+			return false, errors.New("eventhandler: unable to get task event from state change event")
+		}
+		if event.Attachment != nil && !event.ShouldBeReported() {
+			seelog.Warnf("TaskHandler: Not submitting task attachment state change whose ack timeout has expired: %s",
+				event.String())
+			handler.counters.incrementDropped()
+			return false, nil
 		}
 		// Task event: gather all the container events and send them
 		// to ECS by invoking the async submitTaskEvents method from
 		// the sendable event list object
 		handler.flushBatchUnsafe(&event, client)
-		return nil
+		return true, nil
 
 	case statechange.ContainerEvent:
 		event, ok := change.(api.ContainerStateChange)
 		if !ok {
-			return errors.New("eventhandler: unable to get container event from state change event")
+			return false, errors.New("eventhandler: unable to get container event from state change event")
 		}
 		handler.batchContainerEventUnsafe(event)
-		return nil
+		return true, nil
+
+	case statechange.AttachmentEvent:
+		event, ok := change.(api.AttachmentStateChange)
+		if !ok {
+			return false, errors.New("eventhandler: unable to get attachment event from state change event")
+		}
+		if !event.ShouldBeReported() {
+			seelog.Warnf("TaskHandler: Not submitting attachment state change whose ack timeout has expired: %s",
+				event.String())
+			handler.counters.incrementDropped()
+			return false, nil
+		}
+		handler.queueAttachmentEventUnsafe(&event, client)
+		return false, nil
 
 	default:
-		return errors.New("eventhandler: unable to determine event type from state change event")
+		return false, errors.New("eventhandler: unable to determine event type from state change event")
 	}
 }
 
+// RestorePendingStateChanges queues the state changes loaded from the state
+// file for submission again. State changes which had already been submitted
+// before the agent restarted, as well as the ones for tasks and containers
+// which are no longer known, are skipped
+func (handler *TaskHandler) RestorePendingStateChanges() {
+	restored := handler.pendingStateChanges.takeRestored()
+
+	for _, change := range restored.TaskStateChanges {
+		task, ok := handler.state.TaskByArn(change.TaskARN)
+		if !ok {
+			seelog.Warnf("TaskHandler: Not restoring state change for unknown task: %s", change.TaskARN)
+			continue
+		}
+		taskChange := api.TaskStateChange{
+			TaskARN:            change.TaskARN,
+			Status:             change.Status,
+			Reason:             change.Reason,
+			ReasonCode:         change.ReasonCode,
+			SequenceNumber:     change.SequenceNumber,
+			PullStartedAt:      change.PullStartedAt,
+			PullStoppedAt:      change.PullStoppedAt,
+			ExecutionStoppedAt: change.ExecutionStoppedAt,
+			Task:               task,
+		}
+		for _, containerChange := range change.Containers {
+			if restoredChange, ok := restoreContainerStateChange(task, containerChange); ok {
+				taskChange.Containers = append(taskChange.Containers, restoredChange)
+			}
+		}
+		if task.GetSentStatus() >= taskChange.Status && len(taskChange.Containers) == 0 {
+			seelog.Infof("TaskHandler: Not restoring state change which has already been sent: %s",
+				taskChange.String())
+			continue
+		}
+		seelog.Infof("TaskHandler: Restoring task state change: %s", taskChange.String())
+		handler.AddStateChangeEvent(taskChange, handler.client)
+	}
+
+	for _, change := range restored.ContainerStateChanges {
+		task, ok := handler.state.TaskByArn(change.TaskARN)
+		if !ok {
+			seelog.Warnf("TaskHandler: Not restoring state change for unknown task: %s", change.TaskARN)
+			continue
+		}
+		if containerChange, ok := restoreContainerStateChange(task, change); ok {
+			seelog.Infof("TaskHandler: Restoring container state change: %s", containerChange.String())
+			handler.AddStateChangeEvent(containerChange, handler.client)
+		}
+	}
+}
+
+// restoreContainerStateChange constructs the container state change loaded
+// from the state file. It returns false if the container is no longer known or
+// if the state change has already been sent
+func restoreContainerStateChange(task *apitask.Task, change pendingContainerStateChange) (api.ContainerStateChange, bool) {
+	container, ok := task.ContainerByName(change.ContainerName)
+	if !ok || container.GetSentStatus() >= change.Status {
+		return api.ContainerStateChange{}, false
+	}
+	containerChange := api.ContainerStateChange{
+		TaskArn:       change.TaskARN,
+		ContainerName: change.ContainerName,
+		RuntimeID:     change.RuntimeID,
+		ImageDigest:   container.GetImageDigest(),
+		Status:        change.Status,
+		Reason:        change.Reason,
+		ReasonCode:    change.ReasonCode,
+		ExitCode:      change.ExitCode,
+		PortBindings:  container.GetKnownPortBindings(),
+		Container:     container,
+	}
+	containerChange.SetContainerTimestamps()
+	return containerChange, true
+}
+
 // startDrainEventsTicker starts a ticker that periodically drains the events queue
 // by submitting state change events to the ECS backend
 func (handler *TaskHandler) startDrainEventsTicker() {
@@ -219,28 +363,57 @@ func (handler *TaskHandler) taskStateChangesToSend() []api.TaskStateChange {
 // batchContainerEventUnsafe collects container state change events for a given task arn
 func (handler *TaskHandler) batchContainerEventUnsafe(event api.ContainerStateChange) {
 	seelog.Infof("TaskHandler: batching container event: %s", event.String())
-	handler.tasksToContainerStates[event.TaskArn] = append(handler.tasksToContainerStates[event.TaskArn], event)
+	handler.tasksToContainerStates[event.TaskArn] = coalesceContainerStateChanges(
+		handler.tasksToContainerStates[event.TaskArn], event)
+	handler.pendingStateChanges.setContainerStateChanges(event.TaskArn, handler.tasksToContainerStates[event.TaskArn])
 }
 
 // flushBatchUnsafe attaches the task arn's container events to TaskStateChange event
 // by creating the sendable event list. It then submits this event to ECS asynchronously
 func (handler *TaskHandler) flushBatchUnsafe(taskStateChange *api.TaskStateChange, client api.ECSClient) {
-	taskStateChange.Containers = append(taskStateChange.Containers,
-		handler.tasksToContainerStates[taskStateChange.TaskARN]...)
+	for _, containerStateChange := range handler.tasksToContainerStates[taskStateChange.TaskARN] {
+		taskStateChange.Containers = coalesceContainerStateChanges(taskStateChange.Containers, containerStateChange)
+	}
 	// All container events for the task have now been copied to the
 	// task state change object. Remove them from the map
 	delete(handler.tasksToContainerStates, taskStateChange.TaskARN)
+	handler.pendingStateChanges.setContainerStateChanges(taskStateChange.TaskARN, nil)
 
 	// Prepare a given event to be sent by adding it to the handler's
 	// eventList
 	event := newSendableTaskEvent(*taskStateChange)
 	taskEvents := handler.getTaskEventsUnsafe(event)
+	// Container state changes of the events already queued for the task that
+	// are superseded by this state change are folded into it, so that they
+	// aren't submitted separately
+	for _, folded := range taskEvents.foldSupersededContainerEvents(&event.taskChange) {
+		seelog.Infof("TaskHandler: Folded superseded container events of queued event: %s", folded.toString())
+		handler.pendingStateChanges.queueTaskStateChange(folded)
+	}
+	if duplicate := taskEvents.mergeDuplicateTaskEvent(event.taskChange); duplicate != nil {
+		// An identical task state change is already queued. It has been
+		// updated with this state change instead of queueing another one
+		seelog.Infof("TaskHandler: Merged event with identical queued event: %s", duplicate.toString())
+		handler.pendingStateChanges.queueTaskStateChange(duplicate)
+		return
+	}
+	// The state change is saved by the caller once queued, so that it's
+	// submitted after a restart of the agent if the submission fails
+	handler.pendingStateChanges.queueTaskStateChange(event)
 
 	// Add the event to the sendable events queue for the task and
 	// start sending it asynchronously if possible
 	taskEvents.sendChange(event, client, handler)
 }
 
+// queueAttachmentEventUnsafe adds the attachment state change to the sendable
+// events queue of its task and submits it to ECS asynchronously
+func (handler *TaskHandler) queueAttachmentEventUnsafe(attachmentStateChange *api.AttachmentStateChange, client api.ECSClient) {
+	event := newSendableAttachmentEvent(*attachmentStateChange)
+	taskEvents := handler.getTaskEventsUnsafe(event)
+	taskEvents.sendChange(event, client, handler)
+}
+
 // getTaskEventsUnsafe gets the event list for the task arn in the sendableEvent
 // from taskToEvent map
 func (handler *TaskHandler) getTaskEventsUnsafe(event *sendableEvent) *taskSendableEvents {
@@ -267,7 +440,7 @@ func (handler *TaskHandler) getTaskEventsUnsafe(event *sendableEvent) *taskSenda
 // Continuously retries sending an event until it succeeds, sleeping between each
 // attempt
 func (handler *TaskHandler) submitTaskEvents(taskEvents *taskSendableEvents, client api.ECSClient, taskARN string) {
-	defer handler.removeTaskEvents(taskARN)
+	defer handler.removeTaskEvents(taskEvents)
 
 	backoff := utils.NewSimpleBackoff(submitStateBackoffMin, submitStateBackoffMax,
 		submitStateBackoffJitterMultiple, submitStateBackoffMultiple)
@@ -287,6 +460,12 @@ func (handler *TaskHandler) submitTaskEvents(taskEvents *taskSendableEvents, cli
 			handler.submitSemaphore.Wait()
 			defer handler.submitSemaphore.Post()
 
+			// Pick up any container events that were batched while this
+			// task's events were waiting to be sent
+			if handler.attachBatchedContainerEvents(taskEvents) {
+				handler.stateSaver.Save()
+			}
+
 			var err error
 			done, err = taskEvents.submitFirstEvent(handler, backoff)
 			return err
@@ -294,11 +473,56 @@ func (handler *TaskHandler) submitTaskEvents(taskEvents *taskSendableEvents, cli
 	}
 }
 
-func (handler *TaskHandler) removeTaskEvents(taskARN string) {
+// attachBatchedContainerEvents moves the container events batched for the task
+// into the task state change event at the front of the task's event queue, so
+// that they are submitted with the next SubmitTaskStateChange call instead of
+// waiting for another task event or the drain ticker. It returns true if the
+// pending state changes to be saved have changed
+func (handler *TaskHandler) attachBatchedContainerEvents(taskEvents *taskSendableEvents) bool {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+
+	taskEvents.lock.Lock()
+	defer taskEvents.lock.Unlock()
+
+	containerEvents, ok := handler.tasksToContainerStates[taskEvents.taskARN]
+	if !ok {
+		return false
+	}
+
+	if taskEvents.events.Len() == 0 {
+		return false
+	}
+
+	event := taskEvents.events.Front().Value.(*sendableEvent)
+	if !event.attachContainerEvents(containerEvents) {
+		return false
+	}
+	// The container events are now owned by the queued task state change.
+	// Remove them from the map
+	delete(handler.tasksToContainerStates, taskEvents.taskARN)
+	handler.pendingStateChanges.setContainerStateChanges(taskEvents.taskARN, nil)
+	handler.pendingStateChanges.queueTaskStateChange(event)
+	return true
+}
+
+// removeTaskEvents removes the event list of the task from the tasksToEvents
+// map once its events have all been submitted. The list is left in place if
+// events were queued again in the meantime, so that the events of the task are
+// still submitted in order by a single goroutine
+func (handler *TaskHandler) removeTaskEvents(taskEvents *taskSendableEvents) {
 	handler.lock.Lock()
 	defer handler.lock.Unlock()
 
-	delete(handler.tasksToEvents, taskARN)
+	taskEvents.lock.Lock()
+	defer taskEvents.lock.Unlock()
+
+	if taskEvents.sending {
+		return
+	}
+	if handler.tasksToEvents[taskEvents.taskARN] == taskEvents {
+		delete(handler.tasksToEvents, taskEvents.taskARN)
+	}
 }
 
 // sendChange adds the change to the sendable events queue. It triggers
@@ -314,6 +538,7 @@ func (taskEvents *taskSendableEvents) sendChange(change *sendableEvent,
 	// Add event to the queue
 	seelog.Infof("TaskHandler: Adding event: %s", change.toString())
 	taskEvents.events.PushBack(change)
+	handler.counters.incrementEnqueued()
 
 	if !taskEvents.sending {
 		// If a send event is not already in progress, trigger the
@@ -327,12 +552,129 @@ func (taskEvents *taskSendableEvents) sendChange(change *sendableEvent,
 	}
 }
 
+// foldSupersededContainerEvents folds the container state changes of the
+// queued events, which are superseded by the ones of the task state change,
+// into the task state change. The event being submitted is left untouched. It
+// returns the queued events that have been updated
+func (taskEvents *taskSendableEvents) foldSupersededContainerEvents(change *api.TaskStateChange) []*sendableEvent {
+	taskEvents.lock.Lock()
+	defer taskEvents.lock.Unlock()
+
+	var folded []*sendableEvent
+	for element := taskEvents.events.Front(); element != nil; element = element.Next() {
+		event := element.Value.(*sendableEvent)
+		if event == taskEvents.submitting {
+			continue
+		}
+		if event.foldSupersededContainerEvents(change) {
+			folded = append(folded, event)
+		}
+	}
+	return folded
+}
+
+// mergeDuplicateTaskEvent looks for an unsent task state change queued for the
+// task with the same status and container state changes as the given one. If
+// found, the given state change is merged into it and the queued event is
+// returned. Else, it returns nil
+func (taskEvents *taskSendableEvents) mergeDuplicateTaskEvent(change api.TaskStateChange) *sendableEvent {
+	taskEvents.lock.Lock()
+	defer taskEvents.lock.Unlock()
+
+	for element := taskEvents.events.Front(); element != nil; element = element.Next() {
+		event := element.Value.(*sendableEvent)
+		if event == taskEvents.submitting {
+			// The event is being submitted and can no longer be updated
+			continue
+		}
+		if event.mergeTaskEvent(change) {
+			return event
+		}
+	}
+	return nil
+}
+
 // submitFirstEvent submits the first event for the task from the event list. It
 // returns true if the list became empty after submitting the event. Else, it returns
 // false. An error is returned if there was an error with submitting the state change
 // to ECS. The error is used by the backoff handler to backoff before retrying the
-// state change submission for the first event
+// state change submission for the first event. The list lock isn't held while the
+// event is being submitted, so that a slow submission doesn't block the queueing of
+// events for this and other tasks
 func (taskEvents *taskSendableEvents) submitFirstEvent(handler *TaskHandler, backoff utils.Backoff) (bool, error) {
+	eventToSubmit, ok := taskEvents.startSubmission()
+	if !ok {
+		return true, nil
+	}
+	// Extract the wrapped event from the list element
+	event := eventToSubmit.Value.(*sendableEvent)
+	stoppedTask := event.stoppedTask()
+	if stoppedTask != nil {
+		// The engine doesn't clean up the task until the submission of its
+		// STOPPED state change completes, so that the task can still be
+		// resolved and marked as sent. The submission remains in flight
+		// across retries, until the event is sent or dropped
+		stoppedTask.StartStopSubmission()
+	}
+
+	var err error
+	redundant := false
+	if event.taskChangeOutOfOrder() {
+		// A later state change of the task has already been submitted.
+		// Submitting this one would regress the task's status in ECS
+		seelog.Warnf("TaskHandler: Dropping task state change submitted out of order: %s", event.toString())
+		handler.counters.incrementDropped()
+		redundant = true
+	} else if event.containerShouldBeSent() {
+		err = event.send(sendContainerStatusToECS, setContainerChangeSent, "container",
+			handler.client, handler.stateSaver, backoff)
+	} else if event.taskShouldBeSent() {
+		err = event.send(sendTaskStatusToECS, setTaskChangeSent, "task",
+			handler.client, handler.stateSaver, backoff)
+	} else if event.taskAttachmentShouldBeSent() {
+		err = event.send(sendTaskStatusToECS, setTaskAttachmentSent, "task attachment",
+			handler.client, handler.stateSaver, backoff)
+	} else if event.attachmentShouldBeSent() {
+		err = event.send(sendAttachmentStatusToECS, setAttachmentChangeSent, "attachment",
+			handler.client, handler.stateSaver, backoff)
+	} else {
+		// Shouldn't be sent as either a task or container change event; must have been already sent
+		seelog.Infof("TaskHandler: Not submitting redundant event; just removing: %s", event.toString())
+		redundant = true
+	}
+
+	if err == nil && !redundant {
+		handler.counters.incrementSubmitted()
+	}
+	if err != nil {
+		handler.counters.incrementFailed()
+		if handler.shouldRetrySubmission(event, err) {
+			taskEvents.completeSubmission(eventToSubmit, false)
+			return false, err
+		}
+		// The event is dropped so that the events queued after it for the
+		// same task can still be submitted
+		handler.counters.incrementDropped()
+		event.setDropped()
+		if !isRetriableSubmitError(err) {
+			event.setSubmissionRejected()
+		}
+		handler.stateSaver.Save()
+	}
+	// The event is no longer queued, as it was either submitted, found to be
+	// redundant or dropped
+	handler.pendingStateChanges.removeTaskStateChange(event)
+	if stoppedTask != nil {
+		stoppedTask.CompleteStopSubmission()
+	}
+
+	return taskEvents.completeSubmission(eventToSubmit, true), nil
+}
+
+// startSubmission returns the element at the front of the event list and
+// records its event as being submitted. It returns false, and the list is
+// no longer marked as being sent, if there are no events left to submit
+func (taskEvents *taskSendableEvents) startSubmission() (*list.Element, bool) {
 	seelog.Debug("TaskHandler: Acquiring lock for sending event...")
 	taskEvents.lock.Lock()
 	defer taskEvents.lock.Unlock()
@@ -342,43 +684,32 @@ func (taskEvents *taskSendableEvents) submitFirstEvent(handler *TaskHandler, bac
 	if taskEvents.events.Len() == 0 {
 		seelog.Debug("TaskHandler: No events left; not retrying more")
 		taskEvents.sending = false
-		return true, nil
+		return nil, false
 	}
 
 	eventToSubmit := taskEvents.events.Front()
-	// Extract the wrapped event from the list element
-	event := eventToSubmit.Value.(*sendableEvent)
+	taskEvents.submitting = eventToSubmit.Value.(*sendableEvent)
+	return eventToSubmit, true
+}
 
-	if event.containerShouldBeSent() {
-		if err := event.send(sendContainerStatusToECS, setContainerChangeSent, "container",
-			handler.client, eventToSubmit, handler.stateSaver, backoff, taskEvents); err != nil {
-			return false, err
-		}
-	} else if event.taskShouldBeSent() {
-		if err := event.send(sendTaskStatusToECS, setTaskChangeSent, "task",
-			handler.client, eventToSubmit, handler.stateSaver, backoff, taskEvents); err != nil {
-			handleInvalidParamException(err, taskEvents.events, eventToSubmit)
-			return false, err
-		}
-	} else if event.taskAttachmentShouldBeSent() {
-		if err := event.send(sendTaskStatusToECS, setTaskAttachmentSent, "task attachment",
-			handler.client, eventToSubmit, handler.stateSaver, backoff, taskEvents); err != nil {
-			handleInvalidParamException(err, taskEvents.events, eventToSubmit)
-			return false, err
-		}
-	} else {
-		// Shouldn't be sent as either a task or container change event; must have been already sent
-		seelog.Infof("TaskHandler: Not submitting redundant event; just removing: %s", event.toString())
+// completeSubmission records the submission of the element's event as
+// completed, removing the element from the event list if it's no longer
+// queued. It returns true, and the list is no longer marked as being sent, if
+// the list became empty
+func (taskEvents *taskSendableEvents) completeSubmission(eventToSubmit *list.Element, remove bool) bool {
+	taskEvents.lock.Lock()
+	defer taskEvents.lock.Unlock()
+
+	taskEvents.submitting = nil
+	if remove {
 		taskEvents.events.Remove(eventToSubmit)
 	}
-
 	if taskEvents.events.Len() == 0 {
 		seelog.Debug("TaskHandler: Removed the last element, no longer sending")
 		taskEvents.sending = false
-		return true, nil
+		return true
 	}
-
-	return false, nil
+	return false
 }
 
 func (taskEvents *taskSendableEvents) toStringUnsafe() string {
@@ -395,12 +726,38 @@ func (handler *TaskHandler) getTasksToEventsLen() int {
 	return len(handler.tasksToEvents)
 }
 
-// handleInvalidParamException removes the event from event queue when its parameters are
-// invalid to reduce redundant API call
-func handleInvalidParamException(err error, events *list.List, eventToSubmit *list.Element) {
+// shouldRetrySubmission returns true if the submission of the event should be
+// retried after it failed with the error. Events failing with non retriable
+// errors, or failing for longer than the max retry duration, should be dropped
+func (handler *TaskHandler) shouldRetrySubmission(event *sendableEvent, err error) bool {
 	if utils.IsAWSErrorCodeEqual(err, ecs.ErrCodeInvalidParameterException) {
-		event := eventToSubmit.Value.(*sendableEvent)
 		seelog.Warnf("TaskHandler: Event is sent with invalid parameters; just removing: %s", event.toString())
-		events.Remove(eventToSubmit)
+		return false
+	}
+	if !isRetriableSubmitError(err) {
+		seelog.Criticalf("TaskHandler: Dropping event which failed to be submitted with a non retriable error [%s]: %v",
+			event.toString(), err)
+		return false
+	}
+	if time.Since(event.getFirstSubmitAttempt()) > handler.maxSubmitRetryDuration {
+		seelog.Criticalf("TaskHandler: Dropping event which failed to be submitted for more than %s [%s]: %v",
+			handler.maxSubmitRetryDuration.String(), event.toString(), err)
+		return false
+	}
+	return true
+}
+
+// isRetriableSubmitError returns false if the error returned by the
+// Submit*StateChange APIs indicates that the request would never succeed, for
+// example because of invalid parameters. Throttling, server side and connection
+// errors are all retriable
+func isRetriableSubmitError(err error) bool {
+	if utils.IsAWSErrorCodeEqual(err, ecs.ErrCodeInvalidParameterException) ||
+		utils.IsAWSErrorCodeEqual(err, ecs.ErrCodeClientException) {
+		return false
+	}
+	if retriableErr, ok := err.(apierrors.Retriable); ok {
+		return retriableErr.Retry()
 	}
+	return true
 }
@@ -14,12 +14,17 @@
 package eventhandler
 
 import (
-	"container/list"
+	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/cihub/seelog"
@@ -37,6 +42,19 @@ type sendableEvent struct {
 	taskSent   bool
 	taskChange api.TaskStateChange
 
+	isAttachmentEvent bool
+	attachmentSent    bool
+	attachmentChange  api.AttachmentStateChange
+
+	// firstSubmitAttempt is the time at which the event was first attempted
+	// to be submitted
+	firstSubmitAttempt time.Time
+
+	// dropped is set when the event is given up on without having been
+	// submitted, either because it was rejected or because its submission kept
+	// failing
+	dropped bool
+
 	lock sync.RWMutex
 }
 
@@ -56,10 +74,21 @@ func newSendableTaskEvent(event api.TaskStateChange) *sendableEvent {
 	}
 }
 
+func newSendableAttachmentEvent(event api.AttachmentStateChange) *sendableEvent {
+	return &sendableEvent{
+		isAttachmentEvent: true,
+		attachmentSent:    false,
+		attachmentChange:  event,
+	}
+}
+
 func (event *sendableEvent) taskArn() string {
 	if event.isContainerEvent {
 		return event.containerChange.TaskArn
 	}
+	if event.isAttachmentEvent {
+		return event.attachmentChange.Attachment.TaskARN
+	}
 	return event.taskChange.TaskARN
 }
 
@@ -69,7 +98,7 @@ func (event *sendableEvent) taskShouldBeSent() bool {
 	event.lock.RLock()
 	defer event.lock.RUnlock()
 
-	if event.isContainerEvent {
+	if event.isContainerEvent || event.isAttachmentEvent {
 		return false
 	}
 	tevent := event.taskChange
@@ -95,22 +124,56 @@ func (event *sendableEvent) taskShouldBeSent() bool {
 			// change to be sent to ECS.
 			return true
 		}
+		if healthStatusShouldBeSent(containerStateChange) {
+			// We found a container that needs its health
+			// status change to be sent to ECS.
+			return true
+		}
 	}
 
 	return false
 }
 
+// taskChangeOutOfOrder returns true if the event's task state change is older
+// than the latest task state change submitted for the task
+func (event *sendableEvent) taskChangeOutOfOrder() bool {
+	event.lock.RLock()
+	defer event.lock.RUnlock()
+
+	if event.isContainerEvent || event.isAttachmentEvent || event.taskSent {
+		return false
+	}
+	tevent := event.taskChange
+	if tevent.Task == nil || tevent.SequenceNumber == 0 {
+		return false
+	}
+	return tevent.SequenceNumber < tevent.Task.GetSubmittedStateChangeSequence()
+}
+
 func (event *sendableEvent) taskAttachmentShouldBeSent() bool {
 	event.lock.RLock()
 	defer event.lock.RUnlock()
-	if event.isContainerEvent {
+	if event.isContainerEvent || event.isAttachmentEvent {
 		return false
 	}
 	tevent := event.taskChange
 	return tevent.Status == apitaskstatus.TaskStatusNone && // Task Status is not set for attachments as task record has yet to be streamed down
 		tevent.Attachment != nil && // Task has attachment records
 		!tevent.Attachment.HasExpired() && // ENI attachment ack timestamp hasn't expired
-		!tevent.Attachment.IsSent() // Task status hasn't already been sent
+		!tevent.Attachment.IsAcked() && // The attached status is sent until ECS acknowledges it
+		!tevent.Attachment.IsSubmissionRejected() // ECS hasn't rejected the attached status
+}
+
+func (event *sendableEvent) attachmentShouldBeSent() bool {
+	event.lock.RLock()
+	defer event.lock.RUnlock()
+	if !event.isAttachmentEvent {
+		return false
+	}
+	aevent := event.attachmentChange
+	return !event.attachmentSent && // Attachment status hasn't already been sent with this event
+		aevent.ShouldBeReported() && // ENI attachment ack timestamp hasn't expired
+		!aevent.Attachment.IsSent() // Attachment status hasn't already been sent
 }
 
 func (event *sendableEvent) containerShouldBeSent() bool {
@@ -120,38 +183,333 @@ func (event *sendableEvent) containerShouldBeSent() bool {
 		return false
 	}
 	cevent := event.containerChange
-	if event.containerSent || (cevent.Container != nil && cevent.Container.GetSentStatus() >= cevent.Status) {
+	if event.containerSent || containerChangeAlreadySent(cevent) {
 		return false
 	}
 	return true
 }
 
+// containerChangeAlreadySent returns true if both the status and the health
+// status of the container state change have already been sent
+func containerChangeAlreadySent(change api.ContainerStateChange) bool {
+	container := change.Container
+	if container == nil {
+		return false
+	}
+	return container.GetSentStatus() >= change.Status && !healthStatusShouldBeSent(change)
+}
+
+// healthStatusShouldBeSent returns true if the container state change carries
+// a health status that is different from the one last sent for the container
+func healthStatusShouldBeSent(change api.ContainerStateChange) bool {
+	if change.HealthStatus == apicontainerstatus.ContainerHealthUnknown || change.Container == nil {
+		return false
+	}
+	return change.Container.GetSentHealthStatus() != change.HealthStatus
+}
+
+// attachContainerEvents adds the container events, which haven't been sent
+// yet, to the task state change. It returns false if the event is not an
+// unsent task event and the container events were therefore not attached
+func (event *sendableEvent) attachContainerEvents(containerEvents []api.ContainerStateChange) bool {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	if event.isContainerEvent || event.isAttachmentEvent || event.taskSent {
+		return false
+	}
+	// Attachment state changes are submitted without container events
+	if event.taskChange.Attachment != nil {
+		return false
+	}
+
+	for _, containerEvent := range containerEvents {
+		if containerChangeAlreadySent(containerEvent) {
+			// Container status has already been sent as part of some
+			// other event
+			continue
+		}
+		event.addContainerEventUnsafe(containerEvent)
+	}
+	return true
+}
+
+// addContainerEventUnsafe adds the container event to the task state change,
+// keeping only the event with the highest status for each container
+func (event *sendableEvent) addContainerEventUnsafe(containerEvent api.ContainerStateChange) {
+	event.taskChange.Containers = coalesceContainerStateChanges(event.taskChange.Containers, containerEvent)
+}
+
+// coalesceContainerStateChanges adds the container state change to the list,
+// keeping only the state change with the highest status for each container.
+// State changes are only coalesced if they refer to the same container object,
+// so that the sent status is recorded on every container involved. A state
+// change with the same status replaces the existing one if it carries
+// a newer health status. The state change being replaced is folded into the
+// one replacing it
+func coalesceContainerStateChanges(changes []api.ContainerStateChange,
+	change api.ContainerStateChange) []api.ContainerStateChange {
+	for i, existing := range changes {
+		if existing.ContainerName != change.ContainerName || existing.Container != change.Container {
+			continue
+		}
+		if supersedesContainerStateChange(change, existing) {
+			changes[i] = foldContainerStateChange(existing, change)
+		}
+		return changes
+	}
+	return append(changes, change)
+}
+
+// supersedesContainerStateChange returns true if the newer state change of a
+// container makes the older one redundant, as it has a higher status or the
+// same status with a newer health status
+func supersedesContainerStateChange(newer api.ContainerStateChange, older api.ContainerStateChange) bool {
+	if newer.ContainerName != older.ContainerName {
+		return false
+	}
+	if newer.Status > older.Status {
+		return true
+	}
+	return newer.Status == older.Status && newer.HealthStatus != apicontainerstatus.ContainerHealthUnknown
+}
+
+// foldContainerStateChange returns the newer state change of a container
+// updated with the details of the older state change it supersedes. The start
+// time of the older state change is only kept if the newer one doesn't carry
+// its own, as a restarted container reports the start of its latest run. The
+// latest finish time is kept, and the health status of the older state change
+// is kept unless the newer one carries its own
+func foldContainerStateChange(older api.ContainerStateChange, newer api.ContainerStateChange) api.ContainerStateChange {
+	if newer.StartedAt == nil {
+		newer.StartedAt = older.StartedAt
+	}
+	newer.FinishedAt = latestTime(older.FinishedAt, newer.FinishedAt)
+	if newer.HealthStatus == apicontainerstatus.ContainerHealthUnknown {
+		newer.HealthStatus = older.HealthStatus
+	}
+	return newer
+}
+
+// latestTime returns the latest of the timestamps that are set
+func latestTime(a *time.Time, b *time.Time) *time.Time {
+	if a == nil {
+		return b
+	}
+	if b == nil || a.After(*b) {
+		return a
+	}
+	return b
+}
+
+// foldSupersededContainerEvents removes the container state changes of the
+// event that are superseded by the ones of the given task state change, and
+// folds them into the given task state change. It returns false if the event
+// is not an unsent task event, or if none of its container state changes were
+// superseded
+func (event *sendableEvent) foldSupersededContainerEvents(change *api.TaskStateChange) bool {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	if event.isContainerEvent || event.isAttachmentEvent || event.taskSent {
+		return false
+	}
+	if event.taskChange.Attachment != nil || change.Attachment != nil {
+		return false
+	}
+
+	folded := false
+	remaining := event.taskChange.Containers[:0]
+	for _, existing := range event.taskChange.Containers {
+		superseded := false
+		for i, newer := range change.Containers {
+			if newer.Container == existing.Container && supersedesContainerStateChange(newer, existing) {
+				change.Containers[i] = foldContainerStateChange(existing, newer)
+				superseded = true
+				break
+			}
+		}
+		if superseded {
+			folded = true
+			continue
+		}
+		remaining = append(remaining, existing)
+	}
+	event.taskChange.Containers = remaining
+	return folded
+}
+
+// mergeTaskEvent merges the task state change into the event if the event is
+// an unsent task state change with the same task arn, status and container
+// state changes. The newer reason and timestamps are preferred. State changes
+// are only merged if they refer to the same task and container objects, so
+// that the sent status is still recorded on every object involved. It returns
+// false if the state changes are not identical
+func (event *sendableEvent) mergeTaskEvent(change api.TaskStateChange) bool {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	if event.isContainerEvent || event.isAttachmentEvent || event.taskSent {
+		return false
+	}
+	existing := &event.taskChange
+	if existing.Attachment != nil || change.Attachment != nil {
+		return false
+	}
+	if existing.TaskARN != change.TaskARN || existing.Status != change.Status || existing.Task != change.Task {
+		return false
+	}
+	if !sameContainerStateChanges(existing.Containers, change.Containers) {
+		return false
+	}
+
+	if change.Reason != "" {
+		existing.Reason = change.Reason
+	}
+	if change.ReasonCode != apireason.ReasonCodeNone {
+		existing.ReasonCode = change.ReasonCode
+	}
+	if change.SequenceNumber > existing.SequenceNumber {
+		existing.SequenceNumber = change.SequenceNumber
+	}
+	if change.PullStartedAt != nil {
+		existing.PullStartedAt = change.PullStartedAt
+	}
+	if change.PullStoppedAt != nil {
+		existing.PullStoppedAt = change.PullStoppedAt
+	}
+	if change.ExecutionStoppedAt != nil {
+		existing.ExecutionStoppedAt = change.ExecutionStoppedAt
+	}
+	for _, containerChange := range change.Containers {
+		if containerChange.Reason == "" {
+			continue
+		}
+		for i := range existing.Containers {
+			if existing.Containers[i].ContainerName == containerChange.ContainerName {
+				existing.Containers[i].Reason = containerChange.Reason
+			}
+		}
+	}
+	return true
+}
+
+// sameContainerStateChanges returns true if both lists contain state changes
+// for the same set of containers with the same statuses
+func sameContainerStateChanges(a []api.ContainerStateChange, b []api.ContainerStateChange) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	changes := make(map[string]api.ContainerStateChange, len(a))
+	for _, change := range a {
+		changes[change.ContainerName] = change
+	}
+	for _, change := range b {
+		existing, ok := changes[change.ContainerName]
+		if !ok || existing.Status != change.Status || existing.HealthStatus != change.HealthStatus ||
+			existing.Container != change.Container {
+			return false
+		}
+		delete(changes, change.ContainerName)
+	}
+	return len(changes) == 0
+}
+
+// stoppedTask returns the task of the event if the event is a STOPPED task
+// state change, and nil otherwise
+func (event *sendableEvent) stoppedTask() *apitask.Task {
+	event.lock.RLock()
+	defer event.lock.RUnlock()
+
+	if event.isContainerEvent || event.isAttachmentEvent {
+		return nil
+	}
+	if event.taskChange.Status < apitaskstatus.TaskStopped {
+		return nil
+	}
+	return event.taskChange.Task
+}
+
+// getFirstSubmitAttempt returns the time at which the event was first
+// attempted to be submitted
+func (event *sendableEvent) getFirstSubmitAttempt() time.Time {
+	event.lock.RLock()
+	defer event.lock.RUnlock()
+
+	return event.firstSubmitAttempt
+}
+
+// recordSubmitAttempt records the time of the first submission attempt of the
+// event
+func (event *sendableEvent) recordSubmitAttempt() {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	if event.firstSubmitAttempt.IsZero() {
+		event.firstSubmitAttempt = time.Now()
+	}
+}
+
 func (event *sendableEvent) setSent() {
 	event.lock.Lock()
 	defer event.lock.Unlock()
 	if event.isContainerEvent {
 		event.containerSent = true
+	} else if event.isAttachmentEvent {
+		event.attachmentSent = true
 	} else {
 		event.taskSent = true
 	}
 }
 
-// send tries to send an event, specified by 'eventToSubmit', of type
-// 'eventType' to ECS
+// setDropped records that the event was given up on without being submitted
+func (event *sendableEvent) setDropped() {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	event.dropped = true
+}
+
+// isDropped returns true if the event was given up on without being submitted
+func (event *sendableEvent) isDropped() bool {
+	event.lock.RLock()
+	defer event.lock.RUnlock()
+
+	return event.dropped
+}
+
+// setSubmissionRejected records that the attachment of the event was rejected
+// by ECS, so that its attached status isn't sent again
+func (event *sendableEvent) setSubmissionRejected() {
+	event.lock.RLock()
+	defer event.lock.RUnlock()
+
+	if event.isAttachmentEvent && event.attachmentChange.Attachment != nil {
+		event.attachmentChange.Attachment.SetSubmissionRejected()
+	} else if !event.isContainerEvent && event.taskChange.Attachment != nil {
+		event.taskChange.Attachment.SetSubmissionRejected()
+	}
+}
+
+// send tries to send the event, of type 'eventType', to ECS. The caller
+// removes the event from the task's event list once it has been sent
 func (event *sendableEvent) send(
 	sendStatusToECS sendStatusChangeToECS,
 	setChangeSent setStatusSent,
 	eventType string,
 	client api.ECSClient,
-	eventToSubmit *list.Element,
 	stateSaver statemanager.Saver,
-	backoff utils.Backoff,
-	taskEvents *taskSendableEvents) error {
+	backoff utils.Backoff) error {
 
-	seelog.Infof("TaskHandler: Sending %s change: %s", eventType, event.toString())
+	if logger.IsJSONFormat() {
+		seelog.Infof("TaskHandler: Sending %s change: %s", eventType, event.toJSON())
+	} else {
+		seelog.Infof("TaskHandler: Sending %s change: %s", eventType, event.toString())
+	}
+	event.recordSubmitAttempt()
 	// Try submitting the change to ECS
 	if err := sendStatusToECS(client, event); err != nil {
-		seelog.Errorf("TaskHandler: Unretriable error submitting %s state change [%s]: %v",
+		seelog.Errorf("TaskHandler: Error submitting %s state change [%s]: %v",
 			eventType, event.toString(), err)
 		return err
 	}
@@ -162,7 +520,6 @@ func (event *sendableEvent) send(
 	// Update the state file
 	stateSaver.Save()
 	seelog.Debugf("TaskHandler: Submitted task state change: %s", event.toString())
-	taskEvents.events.Remove(eventToSubmit)
 	backoff.Reset()
 	return nil
 }
@@ -182,6 +539,12 @@ func sendTaskStatusToECS(client api.ECSClient, event *sendableEvent) error {
 	return client.SubmitTaskStateChange(event.taskChange)
 }
 
+// sendAttachmentStatusToECS invokes the SubmitAttachmentStateChanges API to
+// send an attachment status change to ECS
+func sendAttachmentStatusToECS(client api.ECSClient, event *sendableEvent) error {
+	return client.SubmitAttachmentStateChange(event.attachmentChange)
+}
+
 // setStatusSent defines a function type to mark the event as sent
 type setStatusSent func(event *sendableEvent)
 
@@ -192,6 +555,15 @@ func setContainerChangeSent(event *sendableEvent) {
 	if container != nil && container.GetSentStatus() < containerChangeStatus {
 		container.SetSentStatus(containerChangeStatus)
 	}
+	setHealthStatusSent(event.containerChange)
+}
+
+// setHealthStatusSent records the health status of the container state change
+// as sent
+func setHealthStatusSent(change api.ContainerStateChange) {
+	if healthStatusShouldBeSent(change) {
+		change.Container.SetSentHealthStatus(change.HealthStatus)
+	}
 }
 
 // setTaskChangeSent sets the event's task change object as sent
@@ -201,12 +573,24 @@ func setTaskChangeSent(event *sendableEvent) {
 	if task != nil && task.GetSentStatus() < taskChangeStatus {
 		task.SetSentStatus(taskChangeStatus)
 	}
+	if task != nil {
+		task.SetSubmittedStateChangeSequence(event.taskChange.SequenceNumber)
+	}
 	for _, containerStateChange := range event.taskChange.Containers {
 		container := containerStateChange.Container
 		containerChangeStatus := containerStateChange.Status
 		if container.GetSentStatus() < containerChangeStatus {
 			container.SetSentStatus(containerStateChange.Status)
 		}
+		setHealthStatusSent(containerStateChange)
+	}
+}
+
+// setAttachmentChangeSent sets the event's attachment change object as sent
+func setAttachmentChangeSent(event *sendableEvent) {
+	if event.attachmentChange.Attachment != nil {
+		event.attachmentChange.Attachment.SetSentStatus()
+		event.attachmentChange.Attachment.StopAckTimer()
 	}
 }
 
@@ -218,12 +602,35 @@ func setTaskAttachmentSent(event *sendableEvent) {
 	}
 }
 
+// toJSON returns the JSON form of the state change of the event, for it to be
+// parsed by log pipelines
+func (event *sendableEvent) toJSON() string {
+	event.lock.RLock()
+	defer event.lock.RUnlock()
+
+	var change interface{}
+	if event.isContainerEvent {
+		change = event.containerChange
+	} else if event.isAttachmentEvent {
+		change = event.attachmentChange
+	} else {
+		change = event.taskChange
+	}
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Sprintf("unable to marshal state change: %v", err)
+	}
+	return string(data)
+}
+
 func (event *sendableEvent) toString() string {
 	event.lock.RLock()
 	defer event.lock.RUnlock()
 
 	if event.isContainerEvent {
 		return "ContainerChange: [" + event.containerChange.String() + fmt.Sprintf("] sent: %t", event.containerSent)
+	} else if event.isAttachmentEvent {
+		return "AttachmentChange: [" + event.attachmentChange.String() + fmt.Sprintf("] sent: %t", event.attachmentSent)
 	} else {
 		return "TaskChange: [" + event.taskChange.String() + fmt.Sprintf("] sent: %t", event.taskSent)
 	}
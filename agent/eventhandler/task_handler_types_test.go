@@ -27,6 +27,7 @@ import (
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShouldContainerEventBeSent(t *testing.T) {
@@ -196,10 +197,8 @@ func TestShouldTaskAttachmentEventBeSent(t *testing.T) {
 			taskShouldBeSent:       false,
 		},
 		{
-			// ENI Attachment is only sent if task status == NONE and if
-			// the event has a non nil attachment object and if expiration
-			// ack timeout is set for future and if attachment status hasn't
-			// already been sent
+			// ENI Attachment is sent again if its attached status has
+			// already been sent, but hasn't been acknowledged by ECS
 			event: newSendableTaskEvent(api.TaskStateChange{
 				Status: apitaskstatus.TaskStatusNone,
 				Attachment: &apieni.ENIAttachment{
@@ -207,6 +206,37 @@ func TestShouldTaskAttachmentEventBeSent(t *testing.T) {
 					AttachStatusSent: true,
 				},
 			}),
+			attachmentShouldBeSent: true,
+			taskShouldBeSent:       false,
+		},
+		{
+			// ENI Attachment isn't sent once ECS has acknowledged it
+			event: newSendableTaskEvent(api.TaskStateChange{
+				Status: apitaskstatus.TaskStatusNone,
+				Attachment: func() *apieni.ENIAttachment {
+					attachment := &apieni.ENIAttachment{
+						ExpiresAt:        time.Unix(time.Now().Unix()+10, 0),
+						AttachStatusSent: true,
+					}
+					attachment.SetAcked()
+					return attachment
+				}(),
+			}),
+			attachmentShouldBeSent: false,
+			taskShouldBeSent:       false,
+		},
+		{
+			// ENI Attachment isn't sent once ECS has rejected it
+			event: newSendableTaskEvent(api.TaskStateChange{
+				Status: apitaskstatus.TaskStatusNone,
+				Attachment: func() *apieni.ENIAttachment {
+					attachment := &apieni.ENIAttachment{
+						ExpiresAt: time.Unix(time.Now().Unix()+10, 0),
+					}
+					attachment.SetSubmissionRejected()
+					return attachment
+				}(),
+			}),
 			attachmentShouldBeSent: false,
 			taskShouldBeSent:       false,
 		},
@@ -282,3 +312,252 @@ func TestSetContainerSentStatus(t *testing.T) {
 	setContainerChangeSent(containerRunningStateChange)
 	assert.Equal(t, testContainer.GetSentStatus(), apicontainerstatus.ContainerStopped)
 }
+
+func TestMergeTaskEvent(t *testing.T) {
+	testTask := &apitask.Task{}
+	testContainer := &apicontainer.Container{}
+	containerStopped := api.ContainerStateChange{
+		ContainerName: "c1",
+		Status:        apicontainerstatus.ContainerStopped,
+		Container:     testContainer,
+	}
+	change := api.TaskStateChange{
+		TaskARN:    "t1",
+		Status:     apitaskstatus.TaskStopped,
+		Reason:     "new reason",
+		Task:       testTask,
+		Containers: []api.ContainerStateChange{containerStopped},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		event       *sendableEvent
+		shouldMerge bool
+	}{
+		{
+			name: "identical task event",
+			event: newSendableTaskEvent(api.TaskStateChange{
+				TaskARN:    "t1",
+				Status:     apitaskstatus.TaskStopped,
+				Reason:     "old reason",
+				Task:       testTask,
+				Containers: []api.ContainerStateChange{containerStopped},
+			}),
+			shouldMerge: true,
+		},
+		{
+			name: "different task status",
+			event: newSendableTaskEvent(api.TaskStateChange{
+				TaskARN:    "t1",
+				Status:     apitaskstatus.TaskRunning,
+				Task:       testTask,
+				Containers: []api.ContainerStateChange{containerStopped},
+			}),
+		},
+		{
+			name: "different task object",
+			event: newSendableTaskEvent(api.TaskStateChange{
+				TaskARN:    "t1",
+				Status:     apitaskstatus.TaskStopped,
+				Task:       &apitask.Task{},
+				Containers: []api.ContainerStateChange{containerStopped},
+			}),
+		},
+		{
+			name: "different container status",
+			event: newSendableTaskEvent(api.TaskStateChange{
+				TaskARN: "t1",
+				Status:  apitaskstatus.TaskStopped,
+				Task:    testTask,
+				Containers: []api.ContainerStateChange{{
+					ContainerName: "c1",
+					Status:        apicontainerstatus.ContainerRunning,
+					Container:     testContainer,
+				}},
+			}),
+		},
+		{
+			name: "different container set",
+			event: newSendableTaskEvent(api.TaskStateChange{
+				TaskARN: "t1",
+				Status:  apitaskstatus.TaskStopped,
+				Task:    testTask,
+			}),
+		},
+		{
+			name:  "container event",
+			event: newSendableContainerEvent(containerStopped),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.shouldMerge, tc.event.mergeTaskEvent(change))
+			if tc.shouldMerge {
+				assert.Equal(t, "new reason", tc.event.taskChange.Reason)
+			}
+		})
+	}
+}
+
+func TestMergeTaskEventAlreadySent(t *testing.T) {
+	change := api.TaskStateChange{
+		TaskARN: "t1",
+		Status:  apitaskstatus.TaskStopped,
+		Task:    &apitask.Task{},
+	}
+	event := newSendableTaskEvent(change)
+	event.setSent()
+
+	assert.False(t, event.mergeTaskEvent(change))
+}
+
+func TestShouldContainerHealthEventBeSent(t *testing.T) {
+	testContainer := &apicontainer.Container{
+		SentStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	event := newSendableContainerEvent(api.ContainerStateChange{
+		Status:       apicontainerstatus.ContainerRunning,
+		HealthStatus: apicontainerstatus.ContainerUnhealthy,
+		Container:    testContainer,
+	})
+	assert.True(t, event.containerShouldBeSent())
+
+	setContainerChangeSent(event)
+	assert.Equal(t, apicontainerstatus.ContainerUnhealthy, testContainer.GetSentHealthStatus())
+	assert.False(t, event.containerShouldBeSent())
+}
+
+func TestShouldTaskEventWithContainerHealthBeSent(t *testing.T) {
+	testTask := &apitask.Task{
+		KnownStatusUnsafe: apitaskstatus.TaskRunning,
+		SentStatusUnsafe:  apitaskstatus.TaskRunning,
+	}
+	testContainer := &apicontainer.Container{
+		KnownStatusUnsafe: apicontainerstatus.ContainerRunning,
+		SentStatusUnsafe:  apicontainerstatus.ContainerRunning,
+	}
+	event := newSendableTaskEvent(api.TaskStateChange{
+		Status: apitaskstatus.TaskRunning,
+		Task:   testTask,
+		Containers: []api.ContainerStateChange{{
+			Status:       apicontainerstatus.ContainerRunning,
+			HealthStatus: apicontainerstatus.ContainerHealthy,
+			Container:    testContainer,
+		}},
+	})
+	assert.True(t, event.taskShouldBeSent())
+
+	setTaskChangeSent(event)
+	assert.Equal(t, apicontainerstatus.ContainerHealthy, testContainer.GetSentHealthStatus())
+	assert.False(t, event.taskShouldBeSent())
+}
+
+func TestSendableEventToJSON(t *testing.T) {
+	exitCode := 1
+	taskChange := newSendableTaskEvent(api.TaskStateChange{
+		TaskARN: "t1",
+		Status:  apitaskstatus.TaskStopped,
+		Task:    &apitask.Task{},
+		Containers: []api.ContainerStateChange{{
+			TaskArn:       "t1",
+			ContainerName: "c1",
+			Status:        apicontainerstatus.ContainerStopped,
+			ExitCode:      &exitCode,
+			Container:     &apicontainer.Container{},
+		}},
+	})
+	assert.JSONEq(t, `{
+		"taskArn": "t1",
+		"status": "STOPPED",
+		"containers": [{"taskArn": "t1", "containerName": "c1", "status": "STOPPED", "exitCode": 1}]
+	}`, taskChange.toJSON())
+
+	containerChange := newSendableContainerEvent(api.ContainerStateChange{
+		TaskArn:       "t1",
+		ContainerName: "c1",
+		Status:        apicontainerstatus.ContainerRunning,
+	})
+	assert.JSONEq(t, `{"taskArn": "t1", "containerName": "c1", "status": "RUNNING"}`, containerChange.toJSON())
+
+	attachmentChange := newSendableAttachmentEvent(api.AttachmentStateChange{
+		Attachment: &apieni.ENIAttachment{
+			TaskARN:       "t1",
+			AttachmentARN: "a1",
+			Status:        apieni.ENIAttached,
+		},
+	})
+	assert.JSONEq(t, `{"taskArn": "t1", "attachmentArn": "a1", "status": "ATTACHED"}`, attachmentChange.toJSON())
+}
+
+func TestCoalesceContainerStateChanges(t *testing.T) {
+	container := &apicontainer.Container{}
+	startedAt := time.Now()
+	running := api.ContainerStateChange{
+		ContainerName: "c1",
+		Status:        apicontainerstatus.ContainerRunning,
+		StartedAt:     &startedAt,
+		Container:     container,
+	}
+	stopped := api.ContainerStateChange{
+		ContainerName: "c1",
+		Status:        apicontainerstatus.ContainerStopped,
+		Container:     container,
+	}
+	other := api.ContainerStateChange{
+		ContainerName: "c2",
+		Status:        apicontainerstatus.ContainerRunning,
+		Container:     &apicontainer.Container{},
+	}
+
+	changes := coalesceContainerStateChanges(nil, running)
+	changes = coalesceContainerStateChanges(changes, other)
+	changes = coalesceContainerStateChanges(changes, stopped)
+	require.Len(t, changes, 2)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, changes[0].Status)
+	assert.Equal(t, &startedAt, changes[0].StartedAt, "start time should be preserved")
+	assert.Equal(t, "c2", changes[1].ContainerName)
+
+	// An older state change arriving late doesn't replace the newer one
+	changes = coalesceContainerStateChanges(changes, running)
+	require.Len(t, changes, 2)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, changes[0].Status)
+}
+
+func TestCoalesceContainerStateChangesOfRestartedContainer(t *testing.T) {
+	container := &apicontainer.Container{}
+	firstStartedAt := time.Now()
+	firstFinishedAt := firstStartedAt.Add(time.Second)
+	secondStartedAt := firstFinishedAt.Add(time.Second)
+	secondFinishedAt := secondStartedAt.Add(time.Second)
+	firstRun := api.ContainerStateChange{
+		ContainerName: "c1",
+		Status:        apicontainerstatus.ContainerRunning,
+		StartedAt:     &firstStartedAt,
+		FinishedAt:    &firstFinishedAt,
+		Container:     container,
+	}
+	secondRun := api.ContainerStateChange{
+		ContainerName: "c1",
+		Status:        apicontainerstatus.ContainerStopped,
+		StartedAt:     &secondStartedAt,
+		FinishedAt:    &secondFinishedAt,
+		Container:     container,
+	}
+
+	changes := coalesceContainerStateChanges(nil, firstRun)
+	changes = coalesceContainerStateChanges(changes, secondRun)
+	require.Len(t, changes, 1)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, changes[0].Status)
+	assert.Equal(t, &secondStartedAt, changes[0].StartedAt, "start time of the latest run should be reported")
+	assert.Equal(t, &secondFinishedAt, changes[0].FinishedAt, "latest finish time should be reported")
+}
+
+func TestLatestTime(t *testing.T) {
+	earlier := time.Now()
+	later := earlier.Add(time.Second)
+
+	assert.Nil(t, latestTime(nil, nil))
+	assert.Equal(t, &earlier, latestTime(&earlier, nil))
+	assert.Equal(t, &earlier, latestTime(nil, &earlier))
+	assert.Equal(t, &later, latestTime(&earlier, &later))
+	assert.Equal(t, &later, latestTime(&later, &earlier))
+}
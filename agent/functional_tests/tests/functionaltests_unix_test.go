@@ -500,6 +500,31 @@ func TestMemoryOvercommit(t *testing.T) {
 			containerMetaData.HostConfig.MemoryReservation, memoryReservation*1024*1024))
 }
 
+// TestReadonlyRootfsAndMountPropagation tests that the root filesystem of a
+// container can be made read only and that the propagation mode of its mount
+// points can be configured in the task definition
+func TestReadonlyRootfsAndMountPropagation(t *testing.T) {
+	agent := RunAgent(t, nil)
+	defer agent.Cleanup()
+
+	task, err := agent.StartTask(t, "mount-propagation")
+	require.NoError(t, err, "Error starting task")
+	defer task.Stop()
+
+	err = task.WaitRunning(waitTaskStateChangeDuration)
+	require.NoError(t, err, "Error waiting for running task")
+
+	containerId, err := agent.ResolveTaskDockerID(task, "mount-propagation")
+	require.NoError(t, err, "Error resolving docker id for container in task")
+
+	containerMetaData, err := agent.DockerClient.InspectContainer(containerId)
+	require.NoError(t, err, "Could not inspect container for task")
+
+	assert.True(t, containerMetaData.HostConfig.ReadonlyRootfs, "Expected the root filesystem to be read only")
+	assert.Contains(t, containerMetaData.HostConfig.Binds, "/:/host:ro,rshared",
+		"Expected the host root to be mounted with shared propagation")
+}
+
 // TestNetworkModeHost tests the container network can be configured
 // as host mode in task definition
 func TestNetworkModeHost(t *testing.T) {
@@ -0,0 +1,55 @@
+// +build linux
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gpu
+
+import (
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DiscoverGPUIDs returns the IDs of the NVIDIA GPUs of the instance, ordered by
+// their minor numbers
+func DiscoverGPUIDs() ([]string, error) {
+	files, err := ioutil.ReadDir(devicesDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "gpu discovery: unable to list the devices in %s", devicesDir)
+	}
+	var minors []int
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, deviceNamePrefix) {
+			continue
+		}
+		// The device files of the driver, such as 'nvidiactl', aren't suffixed
+		// with a minor number
+		minor, err := strconv.Atoi(strings.TrimPrefix(name, deviceNamePrefix))
+		if err != nil {
+			continue
+		}
+		minors = append(minors, minor)
+	}
+	sort.Ints(minors)
+
+	var gpuIDs []string
+	for _, minor := range minors {
+		gpuIDs = append(gpuIDs, strconv.Itoa(minor))
+	}
+	return gpuIDs, nil
+}
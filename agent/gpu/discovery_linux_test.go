@@ -0,0 +1,55 @@
+// +build linux,unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gpu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverGPUIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpu-discovery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"nvidia10", "nvidia2", "nvidiactl", "nvidia-uvm", "nvidia0", "null"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	defer func(dir string) { devicesDir = dir }(devicesDir)
+	devicesDir = dir
+
+	gpuIDs, err := DiscoverGPUIDs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0", "2", "10"}, gpuIDs)
+}
+
+func TestDiscoverGPUIDsWithoutGPUs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpu-discovery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(dir string) { devicesDir = dir }(devicesDir)
+	devicesDir = dir
+
+	gpuIDs, err := DiscoverGPUIDs()
+	require.NoError(t, err)
+	assert.Empty(t, gpuIDs)
+}
@@ -0,0 +1,116 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package gpu discovers the NVIDIA GPUs of the instance and tracks their
+// assignment to the containers of tasks
+package gpu
+
+import (
+	"path"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// deviceNamePrefix is the prefix of the names of the NVIDIA GPU device
+	// files, which are suffixed with the GPU's minor number. The minor number
+	// is used as the ID of the GPU
+	deviceNamePrefix = "nvidia"
+
+	// VisibleDevicesEnvironmentVariable is the environment variable the NVIDIA
+	// container runtime reads the IDs of the GPUs exposed to a container from
+	VisibleDevicesEnvironmentVariable = "NVIDIA_VISIBLE_DEVICES"
+)
+
+// devicesDir is the directory of the NVIDIA device files
+var devicesDir = "/dev"
+
+// DevicePath returns the path of the device file of the GPU
+func DevicePath(gpuID string) string {
+	return path.Join(devicesDir, deviceNamePrefix+gpuID)
+}
+
+// ControlDevicePaths returns the paths of the device files of the NVIDIA
+// driver, which containers using GPUs need besides the GPUs' device files
+func ControlDevicePaths() []string {
+	return []string{
+		path.Join(devicesDir, "nvidiactl"),
+		path.Join(devicesDir, "nvidia-uvm"),
+	}
+}
+
+// Manager tracks the assignment of the GPUs of the instance to tasks, so that
+// a GPU is assigned to a single task at a time
+type Manager struct {
+	gpuIDs []string
+	// assignments maps the IDs of the assigned GPUs to the arns of the tasks
+	// they are assigned to
+	assignments map[string]string
+	lock        sync.Mutex
+}
+
+// NewManager returns a Manager assigning the GPUs with the given IDs
+func NewManager(gpuIDs []string) *Manager {
+	return &Manager{
+		gpuIDs:      gpuIDs,
+		assignments: make(map[string]string),
+	}
+}
+
+// Assign assigns the given number of unassigned GPUs to the task and returns
+// their IDs. No GPU is assigned if fewer are available
+func (m *Manager) Assign(taskArn string, count int) ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var gpuIDs []string
+	for _, gpuID := range m.gpuIDs {
+		if len(gpuIDs) == count {
+			break
+		}
+		if _, ok := m.assignments[gpuID]; !ok {
+			gpuIDs = append(gpuIDs, gpuID)
+		}
+	}
+	if len(gpuIDs) < count {
+		return nil, errors.Errorf("gpu manager: %d gpus requested, %d available", count, len(gpuIDs))
+	}
+	for _, gpuID := range gpuIDs {
+		m.assignments[gpuID] = taskArn
+	}
+	return gpuIDs, nil
+}
+
+// Reserve records the GPUs as assigned to the task. It's used to restore the
+// assignments of tasks started before the agent restarted
+func (m *Manager) Reserve(taskArn string, gpuIDs []string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, gpuID := range gpuIDs {
+		m.assignments[gpuID] = taskArn
+	}
+}
+
+// Release releases the GPUs assigned to the task
+func (m *Manager) Release(taskArn string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for gpuID, assignedTaskArn := range m.assignments {
+		if assignedTaskArn == taskArn {
+			delete(m.assignments, gpuID)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gpu
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssign(t *testing.T) {
+	manager := NewManager([]string{"0", "1", "2"})
+
+	gpuIDs, err := manager.Assign("t1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0", "1"}, gpuIDs)
+
+	gpuIDs, err = manager.Assign("t2", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, gpuIDs)
+
+	_, err = manager.Assign("t3", 1)
+	assert.Error(t, err)
+}
+
+func TestAssignNotEnoughGPUs(t *testing.T) {
+	manager := NewManager([]string{"0", "1"})
+
+	_, err := manager.Assign("t1", 3)
+	assert.Error(t, err)
+
+	// A failed assignment doesn't assign any GPU
+	gpuIDs, err := manager.Assign("t2", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0", "1"}, gpuIDs)
+}
+
+func TestAssignConcurrently(t *testing.T) {
+	manager := NewManager([]string{"0", "1", "2", "3"})
+
+	var wg sync.WaitGroup
+	results := make([][]string, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			gpuIDs, err := manager.Assign(fmt.Sprintf("t%d", i), 1)
+			assert.NoError(t, err)
+			results[i] = gpuIDs
+		}(i)
+	}
+	wg.Wait()
+
+	assigned := make(map[string]struct{})
+	for _, gpuIDs := range results {
+		require.Len(t, gpuIDs, 1)
+		assigned[gpuIDs[0]] = struct{}{}
+	}
+	assert.Len(t, assigned, 4, "each task should be assigned a different gpu")
+}
+
+func TestReserveAndRelease(t *testing.T) {
+	manager := NewManager([]string{"0", "1"})
+	manager.Reserve("t1", []string{"1"})
+
+	gpuIDs, err := manager.Assign("t2", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0"}, gpuIDs)
+	_, err = manager.Assign("t3", 1)
+	assert.Error(t, err)
+
+	manager.Release("t1")
+	gpuIDs, err = manager.Assign("t3", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, gpuIDs)
+}
+
+func TestDevicePath(t *testing.T) {
+	assert.Equal(t, "/dev/nvidia1", DevicePath("1"))
+}
@@ -0,0 +1,103 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/logger/audit"
+	"github.com/aws/amazon-ecs-agent/agent/logger/audit/request"
+)
+
+// v3PathPrefix is the prefix of the paths of the v3 endpoints, which is
+// followed by the v3 endpoint ID of the container making the request
+const v3PathPrefix = "/v3/"
+
+// auditHandler records when the requests to the task server are received, so
+// that the audit log records their latency, and logs the requests to the
+// metadata endpoints in the audit log. Requests for credentials are logged by
+// their handlers, which know the credentials they're for
+type auditHandler struct {
+	h           http.Handler
+	auditLogger audit.AuditLogger
+}
+
+func newAuditHandler(handler http.Handler, auditLogger audit.AuditLogger) auditHandler {
+	return auditHandler{h: handler, auditLogger: auditLogger}
+}
+
+// ServeHTTP serves the request and logs it in the audit log with the status
+// code of its response.
+func (ah auditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = request.WithStartTime(r, time.Now())
+	statusWriter := &statusResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	ah.h.ServeHTTP(statusWriter, r)
+	if isCredentialsRequest(r) {
+		return
+	}
+	ah.auditLogger.Log(request.LogRequest{Request: r}, statusWriter.statusCode, audit.MetadataEventType)
+}
+
+// statusResponseWriter records the status code of the response
+type statusResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// taskAttributor attributes the requests to the task server to the tasks and
+// the containers making them. Requests to the v3 endpoints are attributed by
+// the v3 endpoint ID in their path. Other requests are attributed by their
+// source address, which only identifies the task of awsvpc tasks, as their
+// containers share the address of the task's ENI
+type taskAttributor struct {
+	state dockerstate.TaskEngineState
+}
+
+// Attribute returns the arn of the task and the name of the container that
+// made the request, which are empty if they can't be resolved
+func (attributor taskAttributor) Attribute(r *http.Request) (string, string) {
+	if strings.HasPrefix(r.URL.Path, v3PathPrefix) {
+		v3EndpointID := strings.SplitN(strings.TrimPrefix(r.URL.Path, v3PathPrefix), "/", 2)[0]
+		return attributor.attributeByV3EndpointID(v3EndpointID)
+	}
+
+	sourceIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", ""
+	}
+	taskARN, _ := attributor.state.GetTaskByIPAddress(sourceIP)
+	return taskARN, ""
+}
+
+func (attributor taskAttributor) attributeByV3EndpointID(v3EndpointID string) (string, string) {
+	taskARN, _ := attributor.state.TaskARNByV3EndpointID(v3EndpointID)
+	dockerID, ok := attributor.state.DockerIDByV3EndpointID(v3EndpointID)
+	if !ok {
+		return taskARN, ""
+	}
+	dockerContainer, ok := attributor.state.ContainerByID(dockerID)
+	if !ok || dockerContainer.Container == nil {
+		return taskARN, ""
+	}
+	return taskARN, dockerContainer.Container.Name
+}
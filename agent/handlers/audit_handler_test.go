@@ -0,0 +1,129 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	mock_dockerstate "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/logger/audit"
+	mock_audit "github.com/aws/amazon-ecs-agent/agent/logger/audit/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/logger/audit/request"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditHandlerLogsMetadataRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	handler := newAuditHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := request.StartTime(r)
+		assert.True(t, ok, "the start of the request should be recorded")
+		w.WriteHeader(http.StatusNotFound)
+	}), auditLog)
+
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusNotFound, audit.MetadataEventType).Do(
+		func(logRequest request.LogRequest, statusCode int, eventType string) {
+			_, ok := request.StartTime(logRequest.Request)
+			assert.True(t, ok, "the start of the request should be logged")
+		})
+
+	req, _ := http.NewRequest("GET", "/v3/v3-endpoint-id/task", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestAuditHandlerSkipsCredentialsRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	handler := newAuditHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), auditLog)
+
+	// The credentials handlers log their requests
+	auditLog.EXPECT().Log(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	for _, path := range []string{credentials.V1CredentialsPath, credentials.V2CredentialsPath + "/creds-id"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestTaskAttributorV3EndpointID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	attributor := taskAttributor{state: state}
+
+	state.EXPECT().TaskARNByV3EndpointID(v3EndpointID).Return(taskARN, true).Times(2)
+	state.EXPECT().DockerIDByV3EndpointID(v3EndpointID).Return(containerID, true).Times(2)
+	state.EXPECT().ContainerByID(containerID).Return(&apicontainer.DockerContainer{
+		DockerID:  containerID,
+		Container: &apicontainer.Container{Name: containerName},
+	}, true).Times(2)
+
+	for _, path := range []string{"/v3/" + v3EndpointID, "/v3/" + v3EndpointID + "/task/stats"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		attributedTaskARN, attributedContainerName := attributor.Attribute(req)
+		assert.Equal(t, taskARN, attributedTaskARN)
+		assert.Equal(t, containerName, attributedContainerName)
+	}
+}
+
+func TestTaskAttributorSourceAddress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	attributor := taskAttributor{state: state}
+
+	state.EXPECT().GetTaskByIPAddress(remoteIP).Return(taskARN, true)
+
+	req, _ := http.NewRequest("GET", "/v2/metadata", nil)
+	req.RemoteAddr = remoteIP + ":" + remotePort
+	attributedTaskARN, attributedContainerName := attributor.Attribute(req)
+	assert.Equal(t, taskARN, attributedTaskARN)
+	assert.Equal(t, "", attributedContainerName, "the containers of a task share its address")
+}
+
+func TestTaskAttributorUnresolved(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	attributor := taskAttributor{state: state}
+
+	state.EXPECT().GetTaskByIPAddress(remoteIP).Return("", false)
+	state.EXPECT().TaskARNByV3EndpointID("unknown").Return("", false)
+	state.EXPECT().DockerIDByV3EndpointID("unknown").Return("", false)
+
+	req, _ := http.NewRequest("GET", credentials.V1CredentialsPath, nil)
+	req.RemoteAddr = remoteIP + ":" + remotePort
+	attributedTaskARN, attributedContainerName := attributor.Attribute(req)
+	assert.Equal(t, "", attributedTaskARN)
+	assert.Equal(t, "", attributedContainerName)
+
+	req, _ = http.NewRequest("GET", "/v3/unknown/task", nil)
+	attributedTaskARN, attributedContainerName = attributor.Attribute(req)
+	assert.Equal(t, "", attributedTaskARN)
+	assert.Equal(t, "", attributedContainerName)
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine"
 	handlersutils "github.com/aws/amazon-ecs-agent/agent/handlers/utils"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/cihub/seelog"
 )
@@ -33,8 +34,29 @@ type rootResponse struct {
 	AvailableCommands []string
 }
 
-func introspectionServerSetup(containerInstanceArn *string, taskEngine handlersutils.DockerStateResolver, cfg *config.Config) *http.Server {
-	paths := []string{v1.AgentMetadataPath, v1.TaskContainerMetadataPath, v1.LicensePath}
+func introspectionServerSetup(containerInstanceArn *string,
+	taskEngine handlersutils.DockerStateResolver,
+	executor handlersutils.ContainerExecutor,
+	authConfigStatus handlersutils.AuthConfigStatusProvider,
+	eventStats handlersutils.EventStatsProvider,
+	agentHealth handlersutils.AgentHealthProvider,
+	credentialsStatus handlersutils.CredentialsStatusProvider,
+	acsConnectionStatus handlersutils.ACSConnectionStatusProvider,
+	instanceStatus handlersutils.InstanceStatusProvider,
+	stateChangeEvents handlersutils.StateChangeEventsProvider,
+	metricsSources []metrics.Source,
+	cfg *config.Config) *http.Server {
+	paths := []string{v1.AgentMetadataPath, v1.TaskContainerMetadataPath, v1.LicensePath, v1.EventStatsPath,
+		v1.AgentHealthPath, v1.CredentialsStatusPath}
+	if execEnabled(cfg) {
+		paths = append(paths, v1.ExecPath)
+	}
+	if cfg.PrometheusMetricsEnabled {
+		paths = append(paths, v1.MetricsPath)
+	}
+	if stateChangeEvents != nil {
+		paths = append(paths, v1.EventsPath)
+	}
 	availableCommands := &rootResponse{paths}
 	// Autogenerated list of the above serverFunctions paths
 	availableCommandResponse, _ := json.Marshal(&availableCommands)
@@ -46,7 +68,8 @@ func introspectionServerSetup(containerInstanceArn *string, taskEngine handlersu
 	serverMux := http.NewServeMux()
 	serverMux.HandleFunc("/", defaultHandler)
 
-	v1HandlersSetup(serverMux, containerInstanceArn, taskEngine, cfg)
+	v1HandlersSetup(serverMux, containerInstanceArn, taskEngine, executor, authConfigStatus, eventStats, agentHealth,
+		credentialsStatus, acsConnectionStatus, instanceStatus, stateChangeEvents, metricsSources, cfg)
 
 	// Log all requests and then pass through to serverMux
 	loggingServeMux := http.NewServeMux()
@@ -59,6 +82,9 @@ func introspectionServerSetup(containerInstanceArn *string, taskEngine handlersu
 		WriteTimeout: writeTimeout,
 	}
 
+	// Throttle the requests before they're logged
+	newRequestLimiter(metrics.IntrospectionServer, introspectionServerLimits(cfg), nil).limitServer(server)
+
 	return server
 }
 
@@ -66,21 +92,60 @@ func introspectionServerSetup(containerInstanceArn *string, taskEngine handlersu
 func v1HandlersSetup(serverMux *http.ServeMux,
 	containerInstanceArn *string,
 	taskEngine handlersutils.DockerStateResolver,
+	executor handlersutils.ContainerExecutor,
+	authConfigStatus handlersutils.AuthConfigStatusProvider,
+	eventStats handlersutils.EventStatsProvider,
+	agentHealth handlersutils.AgentHealthProvider,
+	credentialsStatus handlersutils.CredentialsStatusProvider,
+	acsConnectionStatus handlersutils.ACSConnectionStatusProvider,
+	instanceStatus handlersutils.InstanceStatusProvider,
+	stateChangeEvents handlersutils.StateChangeEventsProvider,
+	metricsSources []metrics.Source,
 	cfg *config.Config) {
-	serverMux.HandleFunc(v1.AgentMetadataPath, v1.AgentMetadataHandler(containerInstanceArn, cfg))
+	serverMux.HandleFunc(v1.AgentMetadataPath, v1.AgentMetadataHandler(containerInstanceArn, cfg, authConfigStatus,
+		acsConnectionStatus, instanceStatus))
 	serverMux.HandleFunc(v1.TaskContainerMetadataPath, v1.TaskContainerMetadataHandler(taskEngine))
 	serverMux.HandleFunc(v1.LicensePath, v1.LicenseHandler)
+	serverMux.HandleFunc(v1.EventStatsPath, v1.EventStatsHandler(eventStats))
+	serverMux.HandleFunc(v1.AgentHealthPath, v1.AgentHealthHandler(agentHealth))
+	serverMux.HandleFunc(v1.CredentialsStatusPath, v1.CredentialsStatusHandler(credentialsStatus))
+	if execEnabled(cfg) {
+		serverMux.HandleFunc(v1.ExecPath, v1.ExecHandler(executor, cfg.IntrospectionExecToken.Contents()))
+	}
+	if cfg.PrometheusMetricsEnabled {
+		serverMux.HandleFunc(v1.MetricsPath, v1.MetricsHandler(metricsSources))
+	}
+	if stateChangeEvents != nil {
+		serverMux.HandleFunc(v1.EventsPath, v1.EventsHandler(stateChangeEvents, taskEngine))
+	}
+}
+
+// execEnabled returns true if an exec token is configured, exec requests
+// aren't served otherwise
+func execEnabled(cfg *config.Config) bool {
+	return cfg.IntrospectionExecToken != nil && len(cfg.IntrospectionExecToken.Contents()) > 0
 }
 
 // ServeIntrospectionHTTPEndpoint serves information about this agent/containerInstance and tasks
 // running on it. "V1" here indicates the hostname version of this server instead
 // of the handler versions, i.e. "V1" server can include "V1" and "V2" handlers.
-func ServeIntrospectionHTTPEndpoint(containerInstanceArn *string, taskEngine engine.TaskEngine, cfg *config.Config) {
+func ServeIntrospectionHTTPEndpoint(containerInstanceArn *string,
+	taskEngine engine.TaskEngine,
+	eventStats handlersutils.EventStatsProvider,
+	agentHealth handlersutils.AgentHealthProvider,
+	credentialsStatus handlersutils.CredentialsStatusProvider,
+	acsConnectionStatus handlersutils.ACSConnectionStatusProvider,
+	instanceStatus handlersutils.InstanceStatusProvider,
+	stateChangeEvents handlersutils.StateChangeEventsProvider,
+	metricsSources []metrics.Source,
+	cfg *config.Config) {
 	// Is this the right level to type assert, assuming we'd abstract multiple taskengines here?
 	// Revisit if we ever add another type..
 	dockerTaskEngine := taskEngine.(*engine.DockerTaskEngine)
 
-	server := introspectionServerSetup(containerInstanceArn, dockerTaskEngine, cfg)
+	server := introspectionServerSetup(containerInstanceArn, dockerTaskEngine, dockerTaskEngine, dockerTaskEngine,
+		eventStats, agentHealth, credentialsStatus, acsConnectionStatus, instanceStatus, stateChangeEvents, metricsSources,
+		cfg)
 	for {
 		once := sync.Once{}
 		utils.RetryWithBackoff(utils.NewSimpleBackoff(time.Second, time.Minute, 0.2, 2), func() error {
@@ -16,20 +16,33 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-ecs-agent/agent/agenthealth"
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/mocks"
+	handlersutils "github.com/aws/amazon-ecs-agent/agent/handlers/utils"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -43,7 +56,17 @@ const (
 )
 
 func TestMetadataHandler(t *testing.T) {
-	metadataHandler := v1.AgentMetadataHandler(utils.Strptr(testContainerInstanceArn), &config.Config{Cluster: testClusterArn})
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	acsConnectionStatus := mock_utils.NewMockACSConnectionStatusProvider(ctrl)
+	acsConnectionStatus.EXPECT().Reconnects().Return(0)
+	acsConnectionStatus.EXPECT().LastMessageReceivedAt().Return(time.Time{})
+	instanceStatus := mock_utils.NewMockInstanceStatusProvider(ctrl)
+	instanceStatus.EXPECT().Draining().Return(false)
+	metadataHandler := v1.AgentMetadataHandler(utils.Strptr(testContainerInstanceArn),
+		&config.Config{Cluster: testClusterArn}, mock_utils.NewMockAuthConfigStatusProvider(ctrl), acsConnectionStatus,
+		instanceStatus)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "http://localhost:"+strconv.Itoa(config.AgentIntrospectionPort), nil)
@@ -58,6 +81,81 @@ func TestMetadataHandler(t *testing.T) {
 	if *resp.ContainerInstanceArn != testContainerInstanceArn {
 		t.Error("Metadata returned the wrong cluster arn")
 	}
+	assert.Nil(t, resp.ACSLastMessageReceivedAt)
+	assert.False(t, resp.Draining)
+}
+
+func TestMetadataHandlerAuthConfigFileLoadedAt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	loadedAt := time.Date(2018, time.June, 1, 12, 0, 0, 0, time.UTC)
+	authConfigStatus := mock_utils.NewMockAuthConfigStatusProvider(ctrl)
+	authConfigStatus.EXPECT().AuthConfigFileLoadedAt().Return(loadedAt)
+	acsConnectionStatus := mock_utils.NewMockACSConnectionStatusProvider(ctrl)
+	acsConnectionStatus.EXPECT().Reconnects().Return(0)
+	acsConnectionStatus.EXPECT().LastMessageReceivedAt().Return(time.Time{})
+	instanceStatus := mock_utils.NewMockInstanceStatusProvider(ctrl)
+	instanceStatus.EXPECT().Draining().Return(false)
+	metadataHandler := v1.AgentMetadataHandler(utils.Strptr(testContainerInstanceArn),
+		&config.Config{Cluster: testClusterArn, EngineAuthConfigFile: "/etc/ecs/docker/config.json"}, authConfigStatus,
+		acsConnectionStatus, instanceStatus)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:"+strconv.Itoa(config.AgentIntrospectionPort), nil)
+	metadataHandler(w, req)
+
+	var resp v1.MetadataResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.EngineAuthConfigFileLoadedAt)
+	assert.True(t, loadedAt.Equal(*resp.EngineAuthConfigFileLoadedAt))
+}
+
+func TestMetadataHandlerACSConnectionStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	receivedAt := time.Date(2018, time.June, 1, 12, 0, 0, 0, time.UTC)
+	acsConnectionStatus := mock_utils.NewMockACSConnectionStatusProvider(ctrl)
+	acsConnectionStatus.EXPECT().Reconnects().Return(3)
+	acsConnectionStatus.EXPECT().LastMessageReceivedAt().Return(receivedAt)
+	instanceStatus := mock_utils.NewMockInstanceStatusProvider(ctrl)
+	instanceStatus.EXPECT().Draining().Return(false)
+	metadataHandler := v1.AgentMetadataHandler(utils.Strptr(testContainerInstanceArn),
+		&config.Config{Cluster: testClusterArn}, mock_utils.NewMockAuthConfigStatusProvider(ctrl), acsConnectionStatus,
+		instanceStatus)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:"+strconv.Itoa(config.AgentIntrospectionPort), nil)
+	metadataHandler(w, req)
+
+	var resp v1.MetadataResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.ACSLastMessageReceivedAt)
+	assert.True(t, receivedAt.Equal(*resp.ACSLastMessageReceivedAt))
+	assert.Equal(t, 3, resp.ACSReconnects)
+}
+
+func TestMetadataHandlerDraining(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	acsConnectionStatus := mock_utils.NewMockACSConnectionStatusProvider(ctrl)
+	acsConnectionStatus.EXPECT().Reconnects().Return(0)
+	acsConnectionStatus.EXPECT().LastMessageReceivedAt().Return(time.Time{})
+	instanceStatus := mock_utils.NewMockInstanceStatusProvider(ctrl)
+	instanceStatus.EXPECT().Draining().Return(true)
+	metadataHandler := v1.AgentMetadataHandler(utils.Strptr(testContainerInstanceArn),
+		&config.Config{Cluster: testClusterArn}, mock_utils.NewMockAuthConfigStatusProvider(ctrl), acsConnectionStatus,
+		instanceStatus)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:"+strconv.Itoa(config.AgentIntrospectionPort), nil)
+	metadataHandler(w, req)
+
+	var resp v1.MetadataResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Draining)
 }
 
 func TestListMultipleTasks(t *testing.T) {
@@ -398,6 +496,171 @@ func stateSetupHelper(state dockerstate.TaskEngineState, tasks []*apitask.Task)
 	}
 }
 
+func TestEventStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEventStats := mock_utils.NewMockEventStatsProvider(ctrl)
+	mockEventStats.EXPECT().GetEventStats().Return(eventhandler.EventStats{
+		Enqueued:    5,
+		Submitted:   3,
+		Failed:      2,
+		Dropped:     1,
+		QueueDepths: map[string]int{"task1": 1},
+	})
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockDockerStateResolver(ctrl), mock_utils.NewMockContainerExecutor(ctrl),
+		mock_utils.NewMockAuthConfigStatusProvider(ctrl), mockEventStats,
+		mock_utils.NewMockAgentHealthProvider(ctrl), mock_utils.NewMockCredentialsStatusProvider(ctrl),
+		mock_utils.NewMockACSConnectionStatusProvider(ctrl),
+		mock_utils.NewMockInstanceStatusProvider(ctrl), nil, nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.EventStatsPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"enqueued": 5,
+		"submitted": 3,
+		"failed": 2,
+		"dropped": 1,
+		"queueDepths": {"task1": 1}
+	}`, recorder.Body.String())
+}
+
+func TestAgentHealth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAgentHealth := mock_utils.NewMockAgentHealthProvider(ctrl)
+	mockAgentHealth.EXPECT().Health().Return(agenthealth.Health{
+		Timestamp:           time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		Goroutines:          42,
+		HeapInUseBytes:      1024,
+		GCPauseTotalMs:      1.5,
+		NumGC:               3,
+		DockerPingLatencyMs: 2,
+		DockerPingFailures:  3,
+		DockerPingError:     "unreachable",
+		Degraded:            true,
+	})
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockDockerStateResolver(ctrl), mock_utils.NewMockContainerExecutor(ctrl),
+		mock_utils.NewMockAuthConfigStatusProvider(ctrl), mock_utils.NewMockEventStatsProvider(ctrl),
+		mockAgentHealth, mock_utils.NewMockCredentialsStatusProvider(ctrl),
+		mock_utils.NewMockACSConnectionStatusProvider(ctrl),
+		mock_utils.NewMockInstanceStatusProvider(ctrl), nil, nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.AgentHealthPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"timestamp": "2018-01-02T03:04:05Z",
+		"goroutines": 42,
+		"heapInUseBytes": 1024,
+		"gcPauseTotalMs": 1.5,
+		"numGC": 3,
+		"dockerPingLatencyMs": 2,
+		"dockerPingFailures": 3,
+		"dockerPingError": "unreachable",
+		"degraded": true
+	}`, recorder.Body.String())
+}
+
+func TestCredentialsStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCredentialsStatus := mock_utils.NewMockCredentialsStatusProvider(ctrl)
+	mockCredentialsStatus.EXPECT().GetCredentialsStatus().Return([]credentials.CredentialsStatus{{
+		CredentialsID: "credsId",
+		TaskARN:       "taskArn",
+		RoleType:      credentials.ApplicationRoleType,
+		RefreshedAt:   time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		ExpiresAt:     time.Date(2018, 1, 2, 9, 4, 5, 0, time.UTC),
+	}})
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockDockerStateResolver(ctrl), mock_utils.NewMockContainerExecutor(ctrl),
+		mock_utils.NewMockAuthConfigStatusProvider(ctrl), mock_utils.NewMockEventStatsProvider(ctrl),
+		mock_utils.NewMockAgentHealthProvider(ctrl), mockCredentialsStatus,
+		mock_utils.NewMockACSConnectionStatusProvider(ctrl),
+		mock_utils.NewMockInstanceStatusProvider(ctrl), nil, nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.CredentialsStatusPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `[{
+		"TaskARN": "taskArn",
+		"RoleType": "TaskApplication",
+		"RefreshedAt": "2018-01-02T03:04:05Z",
+		"ExpiresAt": "2018-01-02T09:04:05Z"
+	}]`, recorder.Body.String())
+	assert.NotContains(t, recorder.Body.String(), "credsId")
+}
+
+type testMetricsSource struct{}
+
+func (testMetricsSource) Metrics() []*metrics.Family {
+	return []*metrics.Family{{
+		Name:    "test_total",
+		Help:    "Test counter.",
+		Type:    metrics.CounterType,
+		Samples: []metrics.Sample{{Value: 2}},
+	}}
+}
+
+func TestMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockDockerStateResolver(ctrl), mock_utils.NewMockContainerExecutor(ctrl),
+		mock_utils.NewMockAuthConfigStatusProvider(ctrl), mock_utils.NewMockEventStatsProvider(ctrl),
+		mock_utils.NewMockAgentHealthProvider(ctrl), mock_utils.NewMockCredentialsStatusProvider(ctrl),
+		mock_utils.NewMockACSConnectionStatusProvider(ctrl),
+		mock_utils.NewMockInstanceStatusProvider(ctrl), nil, []metrics.Source{testMetricsSource{}},
+		&config.Config{Cluster: testClusterArn, PrometheusMetricsEnabled: true})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.MetricsPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, metrics.ContentType, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "# HELP test_total Test counter.\n# TYPE test_total counter\ntest_total 2\n",
+		recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	assert.Contains(t, recorder.Body.String(), v1.MetricsPath)
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockDockerStateResolver(ctrl), mock_utils.NewMockContainerExecutor(ctrl),
+		mock_utils.NewMockAuthConfigStatusProvider(ctrl), mock_utils.NewMockEventStatsProvider(ctrl),
+		mock_utils.NewMockAgentHealthProvider(ctrl), mock_utils.NewMockCredentialsStatusProvider(ctrl),
+		mock_utils.NewMockACSConnectionStatusProvider(ctrl),
+		mock_utils.NewMockInstanceStatusProvider(ctrl), nil, []metrics.Source{testMetricsSource{}},
+		&config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.MetricsPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+
+	assert.NotContains(t, recorder.Body.String(), "test_total")
+	assert.NotContains(t, recorder.Body.String(), v1.MetricsPath)
+}
+
 func performMockRequest(t *testing.T, path string) *httptest.ResponseRecorder {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -408,7 +671,12 @@ func performMockRequest(t *testing.T, path string) *httptest.ResponseRecorder {
 	stateSetupHelper(state, testTasks)
 
 	mockStateResolver.EXPECT().State().Return(state)
-	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn), mockStateResolver, &config.Config{Cluster: testClusterArn})
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn), mockStateResolver,
+		mock_utils.NewMockContainerExecutor(ctrl), mock_utils.NewMockAuthConfigStatusProvider(ctrl),
+		mock_utils.NewMockEventStatsProvider(ctrl), mock_utils.NewMockAgentHealthProvider(ctrl),
+		mock_utils.NewMockCredentialsStatusProvider(ctrl), mock_utils.NewMockACSConnectionStatusProvider(ctrl),
+		mock_utils.NewMockInstanceStatusProvider(ctrl), nil, nil,
+		&config.Config{Cluster: testClusterArn})
 
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", path, nil)
@@ -416,3 +684,290 @@ func performMockRequest(t *testing.T, path string) *httptest.ResponseRecorder {
 
 	return recorder
 }
+
+func execServerSetup(ctrl *gomock.Controller, executor handlersutils.ContainerExecutor, token string) *http.Server {
+	return introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockDockerStateResolver(ctrl), executor, mock_utils.NewMockAuthConfigStatusProvider(ctrl),
+		mock_utils.NewMockEventStatsProvider(ctrl), mock_utils.NewMockAgentHealthProvider(ctrl),
+		mock_utils.NewMockCredentialsStatusProvider(ctrl), mock_utils.NewMockACSConnectionStatusProvider(ctrl),
+		mock_utils.NewMockInstanceStatusProvider(ctrl), nil, nil,
+		&config.Config{Cluster: testClusterArn, IntrospectionExecToken: config.NewSensitiveRawMessage([]byte(token))})
+}
+
+func newExecRequest(t *testing.T, url string, token string) *http.Request {
+	body, err := json.Marshal(&v1.ExecRequest{
+		TaskArn:       "taskArn",
+		ContainerName: "container",
+		Command:       []string{"ls", "/"},
+	})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.RemoteAddr = "127.0.0.1:1234"
+	return req
+}
+
+func TestExecDisabledWithoutToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := execServerSetup(ctrl, mock_utils.NewMockContainerExecutor(ctrl), "")
+
+	recorder := httptest.NewRecorder()
+	requestHandler.Handler.ServeHTTP(recorder, newExecRequest(t, v1.ExecPath, ""))
+
+	var availableCommands rootResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &availableCommands))
+	assert.NotContains(t, availableCommands.AvailableCommands, v1.ExecPath)
+}
+
+func TestExecInvalidToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := execServerSetup(ctrl, mock_utils.NewMockContainerExecutor(ctrl), "secret")
+
+	recorder := httptest.NewRecorder()
+	requestHandler.Handler.ServeHTTP(recorder, newExecRequest(t, v1.ExecPath, "guess"))
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestExecInvalidRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := execServerSetup(ctrl, mock_utils.NewMockContainerExecutor(ctrl), "secret")
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, v1.ExecPath, bytes.NewReader([]byte(`{"TaskArn": "taskArn"}`)))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.RemoteAddr = "127.0.0.1:1234"
+	requestHandler.Handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestExecContainerNotRunning(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executor := mock_utils.NewMockContainerExecutor(ctrl)
+	executor.EXPECT().ExecCommand(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(-1,
+		&engine.ExecContainerNotRunningError{})
+	requestHandler := execServerSetup(ctrl, executor, "secret")
+
+	recorder := httptest.NewRecorder()
+	requestHandler.Handler.ServeHTTP(recorder, newExecRequest(t, v1.ExecPath, "secret"))
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	var errorMessage handlersutils.ErrorMessage
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &errorMessage))
+	assert.Equal(t, v1.ErrExecContainerNotRunning, errorMessage.Code)
+}
+
+func TestExecStreamsOutput(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executor := mock_utils.NewMockContainerExecutor(ctrl)
+	executor.EXPECT().ExecCommand(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(ctx context.Context, request engine.ExecRequest, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+			assert.Equal(t, "taskArn", request.TaskARN)
+			assert.Equal(t, "container", request.ContainerName)
+			assert.Equal(t, []string{"ls", "/"}, request.Command)
+			assert.NotEmpty(t, request.Requester)
+			stdout.Write([]byte("bin\n"))
+			stderr.Write([]byte("ls: etc: Permission denied\n"))
+		}).Return(2, nil)
+	server := httptest.NewServer(execServerSetup(ctrl, executor, "secret").Handler)
+	defer server.Close()
+
+	resp, err := http.DefaultClient.Do(newExecRequest(t, server.URL+v1.ExecPath, "secret"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	output, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "bin\nls: etc: Permission denied\n", string(output))
+	assert.Equal(t, "2", resp.Trailer.Get(v1.ExecExitCodeTrailer))
+}
+
+func TestExecWithoutOutput(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executor := mock_utils.NewMockContainerExecutor(ctrl)
+	executor.EXPECT().ExecCommand(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil)
+	server := httptest.NewServer(execServerSetup(ctrl, executor, "secret").Handler)
+	defer server.Close()
+
+	resp, err := http.DefaultClient.Do(newExecRequest(t, server.URL+v1.ExecPath, "secret"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	output, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, output)
+	assert.Equal(t, "0", resp.Trailer.Get(v1.ExecExitCodeTrailer))
+}
+
+// sendExecRequest sends the exec request to the server on a new connection,
+// followed by the input of the command
+func sendExecRequest(t *testing.T, server *httptest.Server, input string) (net.Conn, *http.Request) {
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	req := newExecRequest(t, server.URL+v1.ExecPath, "secret")
+	require.NoError(t, req.Write(conn))
+	_, err = conn.Write([]byte(input))
+	require.NoError(t, err)
+	return conn, req
+}
+
+func TestExecForwardsInput(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executor := mock_utils.NewMockContainerExecutor(ctrl)
+	executor.EXPECT().ExecCommand(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(ctx context.Context, request engine.ExecRequest, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+			input := make([]byte, len("hello\n"))
+			_, err := io.ReadFull(stdin, input)
+			assert.NoError(t, err)
+			stdout.Write(input)
+		}).Return(0, nil)
+	server := httptest.NewServer(execServerSetup(ctrl, executor, "secret").Handler)
+	defer server.Close()
+
+	conn, req := sendExecRequest(t, server, "hello\n")
+	defer conn.Close()
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	output, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(output))
+	assert.Equal(t, "0", resp.Trailer.Get(v1.ExecExitCodeTrailer))
+}
+
+func TestExecRefusedOutsideLoopback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := execServerSetup(ctrl, mock_utils.NewMockContainerExecutor(ctrl), "secret")
+
+	recorder := httptest.NewRecorder()
+	req := newExecRequest(t, v1.ExecPath, "secret")
+	req.RemoteAddr = "10.0.0.1:1234"
+	requestHandler.Handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	var errorMessage handlersutils.ErrorMessage
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &errorMessage))
+	assert.Equal(t, v1.ErrExecForbidden, errorMessage.Code)
+}
+
+func TestExecInputEndsWithoutCancellingCommand(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executor := mock_utils.NewMockContainerExecutor(ctrl)
+	executor.EXPECT().ExecCommand(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(ctx context.Context, request engine.ExecRequest, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+			input, err := ioutil.ReadAll(stdin)
+			assert.NoError(t, err)
+			assert.NoError(t, ctx.Err(), "command shouldn't be cancelled when its input ends")
+			stdout.Write(input)
+		}).Return(0, nil)
+	server := httptest.NewServer(execServerSetup(ctrl, executor, "secret").Handler)
+	defer server.Close()
+
+	conn, req := sendExecRequest(t, server, "hello\n")
+	defer conn.Close()
+	require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	output, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(output))
+	assert.Equal(t, "0", resp.Trailer.Get(v1.ExecExitCodeTrailer))
+}
+
+func TestExecCancelledWhenConnectionLost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cancelled := make(chan struct{})
+	executor := mock_utils.NewMockContainerExecutor(ctrl)
+	executor.EXPECT().ExecCommand(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(ctx context.Context, request engine.ExecRequest, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+			io.Copy(ioutil.Discard, stdin)
+			select {
+			case <-ctx.Done():
+				close(cancelled)
+			case <-time.After(5 * time.Second):
+			}
+		}).Return(-1, context.Canceled)
+	server := httptest.NewServer(execServerSetup(ctrl, executor, "secret").Handler)
+	defer server.Close()
+
+	conn, req := sendExecRequest(t, server, "")
+	// The response is started once the command reads its input, the
+	// connection is only reset after it has been taken from the server
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, conn.(*net.TCPConn).SetLinger(0))
+	conn.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("command should be cancelled when the connection is lost")
+	}
+}
+
+func TestExecOutlivesServerReadTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executor := mock_utils.NewMockContainerExecutor(ctrl)
+	executor.EXPECT().ExecCommand(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(ctx context.Context, request engine.ExecRequest, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+			// The input is read for the whole run of the command, as docker
+			// does
+			go io.Copy(ioutil.Discard, stdin)
+			time.Sleep(readTimeout + time.Second)
+			assert.NoError(t, ctx.Err(), "command shouldn't be cancelled by the read timeout of the server")
+			stdout.Write([]byte("done\n"))
+		}).Return(0, nil)
+	// The server is set up with its real timeouts
+	server := execServerSetup(ctrl, executor, "secret")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	req := newExecRequest(t, "http://"+listener.Addr().String()+v1.ExecPath, "secret")
+	require.NoError(t, req.Write(conn))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	output, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", string(output))
+	assert.Equal(t, "0", resp.Trailer.Get(v1.ExecExitCodeTrailer))
+}
@@ -12,18 +12,213 @@
 // permissions and limitations under the License.
 
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/aws/amazon-ecs-agent/agent/handlers/utils (interfaces: DockerStateResolver)
+// Source: github.com/aws/amazon-ecs-agent/agent/handlers/utils (interfaces: ACSConnectionStatusProvider,AgentHealthProvider,AuthConfigStatusProvider,ContainerExecutor,CredentialsStatusProvider,DockerStateResolver,EventStatsProvider)
 
 // Package mock_utils is a generated GoMock package.
 package mock_utils
 
 import (
+	context "context"
+	io "io"
 	reflect "reflect"
+	time "time"
 
+	agenthealth "github.com/aws/amazon-ecs-agent/agent/agenthealth"
+	credentials "github.com/aws/amazon-ecs-agent/agent/credentials"
+	engine "github.com/aws/amazon-ecs-agent/agent/engine"
 	dockerstate "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	eventhandler "github.com/aws/amazon-ecs-agent/agent/eventhandler"
 	gomock "github.com/golang/mock/gomock"
 )
 
+// MockACSConnectionStatusProvider is a mock of ACSConnectionStatusProvider interface
+type MockACSConnectionStatusProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockACSConnectionStatusProviderMockRecorder
+}
+
+// MockACSConnectionStatusProviderMockRecorder is the mock recorder for MockACSConnectionStatusProvider
+type MockACSConnectionStatusProviderMockRecorder struct {
+	mock *MockACSConnectionStatusProvider
+}
+
+// NewMockACSConnectionStatusProvider creates a new mock instance
+func NewMockACSConnectionStatusProvider(ctrl *gomock.Controller) *MockACSConnectionStatusProvider {
+	mock := &MockACSConnectionStatusProvider{ctrl: ctrl}
+	mock.recorder = &MockACSConnectionStatusProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockACSConnectionStatusProvider) EXPECT() *MockACSConnectionStatusProviderMockRecorder {
+	return m.recorder
+}
+
+// LastMessageReceivedAt mocks base method
+func (m *MockACSConnectionStatusProvider) LastMessageReceivedAt() time.Time {
+	ret := m.ctrl.Call(m, "LastMessageReceivedAt")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// LastMessageReceivedAt indicates an expected call of LastMessageReceivedAt
+func (mr *MockACSConnectionStatusProviderMockRecorder) LastMessageReceivedAt() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastMessageReceivedAt", reflect.TypeOf((*MockACSConnectionStatusProvider)(nil).LastMessageReceivedAt))
+}
+
+// Reconnects mocks base method
+func (m *MockACSConnectionStatusProvider) Reconnects() int {
+	ret := m.ctrl.Call(m, "Reconnects")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Reconnects indicates an expected call of Reconnects
+func (mr *MockACSConnectionStatusProviderMockRecorder) Reconnects() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reconnects", reflect.TypeOf((*MockACSConnectionStatusProvider)(nil).Reconnects))
+}
+
+// MockAgentHealthProvider is a mock of AgentHealthProvider interface
+type MockAgentHealthProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockAgentHealthProviderMockRecorder
+}
+
+// MockAgentHealthProviderMockRecorder is the mock recorder for MockAgentHealthProvider
+type MockAgentHealthProviderMockRecorder struct {
+	mock *MockAgentHealthProvider
+}
+
+// NewMockAgentHealthProvider creates a new mock instance
+func NewMockAgentHealthProvider(ctrl *gomock.Controller) *MockAgentHealthProvider {
+	mock := &MockAgentHealthProvider{ctrl: ctrl}
+	mock.recorder = &MockAgentHealthProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAgentHealthProvider) EXPECT() *MockAgentHealthProviderMockRecorder {
+	return m.recorder
+}
+
+// Health mocks base method
+func (m *MockAgentHealthProvider) Health() agenthealth.Health {
+	ret := m.ctrl.Call(m, "Health")
+	ret0, _ := ret[0].(agenthealth.Health)
+	return ret0
+}
+
+// Health indicates an expected call of Health
+func (mr *MockAgentHealthProviderMockRecorder) Health() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Health", reflect.TypeOf((*MockAgentHealthProvider)(nil).Health))
+}
+
+// MockAuthConfigStatusProvider is a mock of AuthConfigStatusProvider interface
+type MockAuthConfigStatusProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthConfigStatusProviderMockRecorder
+}
+
+// MockAuthConfigStatusProviderMockRecorder is the mock recorder for MockAuthConfigStatusProvider
+type MockAuthConfigStatusProviderMockRecorder struct {
+	mock *MockAuthConfigStatusProvider
+}
+
+// NewMockAuthConfigStatusProvider creates a new mock instance
+func NewMockAuthConfigStatusProvider(ctrl *gomock.Controller) *MockAuthConfigStatusProvider {
+	mock := &MockAuthConfigStatusProvider{ctrl: ctrl}
+	mock.recorder = &MockAuthConfigStatusProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAuthConfigStatusProvider) EXPECT() *MockAuthConfigStatusProviderMockRecorder {
+	return m.recorder
+}
+
+// AuthConfigFileLoadedAt mocks base method
+func (m *MockAuthConfigStatusProvider) AuthConfigFileLoadedAt() time.Time {
+	ret := m.ctrl.Call(m, "AuthConfigFileLoadedAt")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// AuthConfigFileLoadedAt indicates an expected call of AuthConfigFileLoadedAt
+func (mr *MockAuthConfigStatusProviderMockRecorder) AuthConfigFileLoadedAt() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthConfigFileLoadedAt", reflect.TypeOf((*MockAuthConfigStatusProvider)(nil).AuthConfigFileLoadedAt))
+}
+
+// MockContainerExecutor is a mock of ContainerExecutor interface
+type MockContainerExecutor struct {
+	ctrl     *gomock.Controller
+	recorder *MockContainerExecutorMockRecorder
+}
+
+// MockContainerExecutorMockRecorder is the mock recorder for MockContainerExecutor
+type MockContainerExecutorMockRecorder struct {
+	mock *MockContainerExecutor
+}
+
+// NewMockContainerExecutor creates a new mock instance
+func NewMockContainerExecutor(ctrl *gomock.Controller) *MockContainerExecutor {
+	mock := &MockContainerExecutor{ctrl: ctrl}
+	mock.recorder = &MockContainerExecutorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockContainerExecutor) EXPECT() *MockContainerExecutorMockRecorder {
+	return m.recorder
+}
+
+// ExecCommand mocks base method
+func (m *MockContainerExecutor) ExecCommand(arg0 context.Context, arg1 engine.ExecRequest, arg2 io.Reader, arg3, arg4 io.Writer) (int, error) {
+	ret := m.ctrl.Call(m, "ExecCommand", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecCommand indicates an expected call of ExecCommand
+func (mr *MockContainerExecutorMockRecorder) ExecCommand(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecCommand", reflect.TypeOf((*MockContainerExecutor)(nil).ExecCommand), arg0, arg1, arg2, arg3, arg4)
+}
+
+// MockCredentialsStatusProvider is a mock of CredentialsStatusProvider interface
+type MockCredentialsStatusProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockCredentialsStatusProviderMockRecorder
+}
+
+// MockCredentialsStatusProviderMockRecorder is the mock recorder for MockCredentialsStatusProvider
+type MockCredentialsStatusProviderMockRecorder struct {
+	mock *MockCredentialsStatusProvider
+}
+
+// NewMockCredentialsStatusProvider creates a new mock instance
+func NewMockCredentialsStatusProvider(ctrl *gomock.Controller) *MockCredentialsStatusProvider {
+	mock := &MockCredentialsStatusProvider{ctrl: ctrl}
+	mock.recorder = &MockCredentialsStatusProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockCredentialsStatusProvider) EXPECT() *MockCredentialsStatusProviderMockRecorder {
+	return m.recorder
+}
+
+// GetCredentialsStatus mocks base method
+func (m *MockCredentialsStatusProvider) GetCredentialsStatus() []credentials.CredentialsStatus {
+	ret := m.ctrl.Call(m, "GetCredentialsStatus")
+	ret0, _ := ret[0].([]credentials.CredentialsStatus)
+	return ret0
+}
+
+// GetCredentialsStatus indicates an expected call of GetCredentialsStatus
+func (mr *MockCredentialsStatusProviderMockRecorder) GetCredentialsStatus() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCredentialsStatus", reflect.TypeOf((*MockCredentialsStatusProvider)(nil).GetCredentialsStatus))
+}
+
 // MockDockerStateResolver is a mock of DockerStateResolver interface
 type MockDockerStateResolver struct {
 	ctrl     *gomock.Controller
@@ -58,3 +253,73 @@ func (m *MockDockerStateResolver) State() dockerstate.TaskEngineState {
 func (mr *MockDockerStateResolverMockRecorder) State() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "State", reflect.TypeOf((*MockDockerStateResolver)(nil).State))
 }
+
+// MockEventStatsProvider is a mock of EventStatsProvider interface
+type MockEventStatsProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventStatsProviderMockRecorder
+}
+
+// MockEventStatsProviderMockRecorder is the mock recorder for MockEventStatsProvider
+type MockEventStatsProviderMockRecorder struct {
+	mock *MockEventStatsProvider
+}
+
+// NewMockEventStatsProvider creates a new mock instance
+func NewMockEventStatsProvider(ctrl *gomock.Controller) *MockEventStatsProvider {
+	mock := &MockEventStatsProvider{ctrl: ctrl}
+	mock.recorder = &MockEventStatsProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockEventStatsProvider) EXPECT() *MockEventStatsProviderMockRecorder {
+	return m.recorder
+}
+
+// GetEventStats mocks base method
+func (m *MockEventStatsProvider) GetEventStats() eventhandler.EventStats {
+	ret := m.ctrl.Call(m, "GetEventStats")
+	ret0, _ := ret[0].(eventhandler.EventStats)
+	return ret0
+}
+
+// GetEventStats indicates an expected call of GetEventStats
+func (mr *MockEventStatsProviderMockRecorder) GetEventStats() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventStats", reflect.TypeOf((*MockEventStatsProvider)(nil).GetEventStats))
+}
+
+// MockInstanceStatusProvider is a mock of InstanceStatusProvider interface
+type MockInstanceStatusProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockInstanceStatusProviderMockRecorder
+}
+
+// MockInstanceStatusProviderMockRecorder is the mock recorder for MockInstanceStatusProvider
+type MockInstanceStatusProviderMockRecorder struct {
+	mock *MockInstanceStatusProvider
+}
+
+// NewMockInstanceStatusProvider creates a new mock instance
+func NewMockInstanceStatusProvider(ctrl *gomock.Controller) *MockInstanceStatusProvider {
+	mock := &MockInstanceStatusProvider{ctrl: ctrl}
+	mock.recorder = &MockInstanceStatusProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockInstanceStatusProvider) EXPECT() *MockInstanceStatusProviderMockRecorder {
+	return m.recorder
+}
+
+// Draining mocks base method
+func (m *MockInstanceStatusProvider) Draining() bool {
+	ret := m.ctrl.Call(m, "Draining")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Draining indicates an expected call of Draining
+func (mr *MockInstanceStatusProviderMockRecorder) Draining() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Draining", reflect.TypeOf((*MockInstanceStatusProvider)(nil).Draining))
+}
@@ -0,0 +1,174 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/didip/tollbooth"
+	"github.com/didip/tollbooth/limiter"
+)
+
+const (
+	// retryAfterSeconds is the delay clients are asked to wait for before
+	// retrying throttled requests. The token buckets of the sources refill
+	// at least once per second
+	retryAfterSeconds = "1"
+
+	// throttledResponse is the body of the responses to throttled requests
+	throttledResponse = `"Too many requests, please retry later"`
+)
+
+// requestLimits are the limits of the requests to an http server. Limits that
+// aren't set don't throttle requests
+type requestLimits struct {
+	// steadyStateRate and burstRate limit the requests of each source ip
+	steadyStateRate int
+	burstRate       int
+	// credentialsSteadyStateRate and credentialsBurstRate limit the requests
+	// for credentials of each source ip separately, if set
+	credentialsSteadyStateRate int
+	credentialsBurstRate       int
+	// maxConnections caps the number of concurrent connections to the server
+	maxConnections int
+}
+
+// taskServerLimits returns the limits of the requests to the task metadata
+// and credentials endpoint
+func taskServerLimits(cfg *config.Config) requestLimits {
+	return requestLimits{
+		steadyStateRate:            cfg.TaskMetadataSteadyStateRate,
+		burstRate:                  cfg.TaskMetadataBurstRate,
+		credentialsSteadyStateRate: cfg.TaskCredentialsSteadyStateRate,
+		credentialsBurstRate:       cfg.TaskCredentialsBurstRate,
+		maxConnections:             cfg.HTTPMaxConnections,
+	}
+}
+
+// introspectionServerLimits returns the limits of the requests to the
+// introspection endpoint
+func introspectionServerLimits(cfg *config.Config) requestLimits {
+	return requestLimits{
+		steadyStateRate: cfg.IntrospectionSteadyStateRate,
+		burstRate:       cfg.IntrospectionBurstRate,
+		maxConnections:  cfg.HTTPMaxConnections,
+	}
+}
+
+// requestLimiter throttles the requests to an http server past its limits,
+// which are answered with a 429 and a Retry-After header
+type requestLimiter struct {
+	// server is the server label of the metrics of the throttled requests
+	server             string
+	limits             requestLimits
+	limiter            *limiter.Limiter
+	credentialsLimiter *limiter.Limiter
+	// onLimitReached is called with the requests that are throttled, if set
+	onLimitReached func(http.ResponseWriter, *http.Request)
+	// connections is the number of open connections to the server
+	connections int64
+}
+
+func newRequestLimiter(server string, limits requestLimits,
+	onLimitReached func(http.ResponseWriter, *http.Request)) *requestLimiter {
+	requestLimiter := &requestLimiter{
+		server:         server,
+		limits:         limits,
+		onLimitReached: onLimitReached,
+	}
+	if limits.steadyStateRate > 0 && limits.burstRate > 0 {
+		requestLimiter.limiter = tollbooth.NewLimiter(int64(limits.steadyStateRate), nil).
+			SetBurst(limits.burstRate)
+	}
+	if limits.credentialsSteadyStateRate > 0 && limits.credentialsBurstRate > 0 {
+		requestLimiter.credentialsLimiter = tollbooth.NewLimiter(int64(limits.credentialsSteadyStateRate), nil).
+			SetBurst(limits.credentialsBurstRate)
+	}
+	return requestLimiter
+}
+
+// limitServer tracks the connections to the server and throttles the requests
+// to its handler
+func (requestLimiter *requestLimiter) limitServer(server *http.Server) {
+	server.Handler = requestLimiter.handler(server.Handler)
+	server.ConnState = requestLimiter.trackConnection
+}
+
+// trackConnection counts the open connections to the server
+func (requestLimiter *requestLimiter) trackConnection(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		atomic.AddInt64(&requestLimiter.connections, 1)
+	case http.StateHijacked, http.StateClosed:
+		atomic.AddInt64(&requestLimiter.connections, -1)
+	}
+}
+
+// handler returns the handler throttling the requests to the next handler
+func (requestLimiter *requestLimiter) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit, throttled := requestLimiter.throttled(r); throttled {
+			metrics.RecordThrottledRequest(requestLimiter.server, limit)
+			if requestLimiter.onLimitReached != nil {
+				requestLimiter.onLimitReached(w, r)
+			}
+			if limit == metrics.ConnectionLimit {
+				// Closing the connection frees it for other clients
+				w.Header().Set("Connection", "close")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", retryAfterSeconds)
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(throttledResponse))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// throttled returns whether the request is past a limit, and which one
+func (requestLimiter *requestLimiter) throttled(r *http.Request) (string, bool) {
+	if requestLimiter.limits.maxConnections > 0 &&
+		atomic.LoadInt64(&requestLimiter.connections) > int64(requestLimiter.limits.maxConnections) {
+		return metrics.ConnectionLimit, true
+	}
+
+	sourceLimiter, limit := requestLimiter.limiter, metrics.RateLimit
+	if requestLimiter.credentialsLimiter != nil && isCredentialsRequest(r) {
+		sourceLimiter, limit = requestLimiter.credentialsLimiter, metrics.CredentialsRateLimit
+	}
+	if sourceLimiter == nil {
+		return "", false
+	}
+	// The source ip is the remote address of the connection. Forwarding
+	// headers are set by the clients, which could evade their limit with them
+	sourceIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		sourceIP = r.RemoteAddr
+	}
+	return limit, sourceLimiter.LimitReached(sourceIP)
+}
+
+// isCredentialsRequest returns true if the request is for the credentials of
+// a task
+func isCredentialsRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, credentials.V1CredentialsPath) ||
+		strings.HasPrefix(r.URL.Path, credentials.V2CredentialsPath)
+}
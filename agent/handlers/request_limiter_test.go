@@ -0,0 +1,121 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveLimited(requestLimiter *requestLimiter, path string, remoteAddr string) *httptest.ResponseRecorder {
+	handler := requestLimiter.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	req.RemoteAddr = remoteAddr
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRequestLimiterRateLimit(t *testing.T) {
+	requestLimiter := newRequestLimiter(metrics.TaskServer, requestLimits{steadyStateRate: 1, burstRate: 1}, nil)
+
+	assert.Equal(t, http.StatusOK, serveLimited(requestLimiter, "/v2/metadata", "10.0.0.2:1234").Code)
+	// The limit is shared by the paths and the ports of the source
+	recorder := serveLimited(requestLimiter, "/v2/stats", "10.0.0.2:1235")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, retryAfterSeconds, recorder.Header().Get("Retry-After"))
+	assert.Empty(t, recorder.Header().Get("Connection"))
+
+	// Other sources have their own limit
+	assert.Equal(t, http.StatusOK, serveLimited(requestLimiter, "/v2/metadata", "10.0.0.3:1234").Code)
+}
+
+func TestRequestLimiterCredentialsRateLimit(t *testing.T) {
+	var limitReached int
+	requestLimiter := newRequestLimiter(metrics.TaskServer, requestLimits{
+		steadyStateRate:            1,
+		burstRate:                  1,
+		credentialsSteadyStateRate: 2,
+		credentialsBurstRate:       2,
+	}, func(http.ResponseWriter, *http.Request) {
+		limitReached++
+	})
+
+	assert.Equal(t, http.StatusOK, serveLimited(requestLimiter, "/v2/metadata", "10.0.0.2:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveLimited(requestLimiter, "/v2/metadata", "10.0.0.2:1234").Code)
+
+	// Requests for credentials aren't throttled by the other requests
+	assert.Equal(t, http.StatusOK, serveLimited(requestLimiter, credentials.V2CredentialsPath+"/id", "10.0.0.2:1234").Code)
+	assert.Equal(t, http.StatusOK, serveLimited(requestLimiter, credentials.V1CredentialsPath+"?id=id", "10.0.0.2:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests,
+		serveLimited(requestLimiter, credentials.V2CredentialsPath+"/id", "10.0.0.2:1234").Code)
+	assert.Equal(t, 2, limitReached)
+}
+
+func TestRequestLimiterConnectionLimit(t *testing.T) {
+	requestLimiter := newRequestLimiter(metrics.IntrospectionServer, requestLimits{maxConnections: 1}, nil)
+	server := &http.Server{}
+	requestLimiter.limitServer(server)
+
+	server.ConnState(nil, http.StateNew)
+	assert.Equal(t, http.StatusOK, serveLimited(requestLimiter, "/v1/metadata", "127.0.0.1:1234").Code)
+
+	server.ConnState(nil, http.StateNew)
+	recorder := serveLimited(requestLimiter, "/v1/metadata", "127.0.0.1:1235")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, retryAfterSeconds, recorder.Header().Get("Retry-After"))
+	assert.Equal(t, "close", recorder.Header().Get("Connection"))
+
+	server.ConnState(nil, http.StateClosed)
+	assert.Equal(t, http.StatusOK, serveLimited(requestLimiter, "/v1/metadata", "127.0.0.1:1234").Code)
+}
+
+func TestRequestLimiterNoLimits(t *testing.T) {
+	requestLimiter := newRequestLimiter(metrics.IntrospectionServer, requestLimits{}, nil)
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, http.StatusOK, serveLimited(requestLimiter, "/v1/metadata", "127.0.0.1:1234").Code)
+	}
+}
+
+func TestRequestLimiterRecordsThrottledRequests(t *testing.T) {
+	throttled := func() float64 {
+		for _, family := range metrics.AgentMetrics.Metrics() {
+			if family.Name != "ecs_agent_http_requests_throttled_total" {
+				continue
+			}
+			for _, sample := range family.Samples {
+				if sample.Labels[0].Value == metrics.TaskServer && sample.Labels[1].Value == metrics.RateLimit {
+					return sample.Value
+				}
+			}
+		}
+		return 0
+	}
+	before := throttled()
+
+	requestLimiter := newRequestLimiter(metrics.TaskServer, requestLimits{steadyStateRate: 1, burstRate: 1}, nil)
+	serveLimited(requestLimiter, "/v2/metadata", "10.0.0.4:1234")
+	serveLimited(requestLimiter, "/v2/metadata", "10.0.0.4:1234")
+	assert.Equal(t, before+1, throttled())
+}
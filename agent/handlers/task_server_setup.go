@@ -26,10 +26,10 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/handlers/v2"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/v3"
 	"github.com/aws/amazon-ecs-agent/agent/logger/audit"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/cihub/seelog"
-	"github.com/didip/tollbooth"
 	"github.com/gorilla/mux"
 )
 
@@ -48,8 +48,7 @@ func taskServerSetup(credentialsManager credentials.Manager,
 	state dockerstate.TaskEngineState,
 	cluster string,
 	statsEngine stats.Engine,
-	steadyStateRate int,
-	burstRate int) *http.Server {
+	limits requestLimits) *http.Server {
 	muxRouter := mux.NewRouter()
 
 	// Set this so that for request like "/v3//metadata/task", the Agent will pass
@@ -63,17 +62,13 @@ func taskServerSetup(credentialsManager credentials.Manager,
 
 	v3HandlersSetup(muxRouter, state, statsEngine, cluster)
 
-	limiter := tollbooth.NewLimiter(int64(steadyStateRate), nil)
-	limiter.SetOnLimitReached(handlersutils.LimitReachedHandler(auditLogger))
-	limiter.SetBurst(burstRate)
-
-	// Log all requests and then pass through to muxRouter.
+	// Log all requests, and the requests to the metadata endpoints in the audit
+	// log, and then pass through to muxRouter.
 	loggingMuxRouter := mux.NewRouter()
 
 	// rootPath is a path for any traffic to this endpoint, "root" mux name will not be used.
 	rootPath := "/" + handlersutils.ConstructMuxVar("root", handlersutils.AnythingRegEx)
-	loggingMuxRouter.Handle(rootPath, tollbooth.LimitHandler(
-		limiter, NewLoggingHandler(muxRouter)))
+	loggingMuxRouter.Handle(rootPath, NewLoggingHandler(newAuditHandler(muxRouter, auditLogger)))
 
 	loggingMuxRouter.SkipClean(true)
 
@@ -84,6 +79,10 @@ func taskServerSetup(credentialsManager credentials.Manager,
 		WriteTimeout: writeTimeout,
 	}
 
+	// Throttle the requests before they're logged
+	newRequestLimiter(metrics.TaskServer, limits, handlersutils.LimitReachedHandler(auditLogger)).
+		limitServer(&server)
+
 	return &server
 }
 
@@ -130,10 +129,10 @@ func ServeTaskHTTPEndpoint(credentialsManager credentials.Manager,
 		logger = seelog.Disabled
 	}
 
-	auditLogger := audit.NewAuditLog(containerInstanceArn, cfg, logger)
+	auditLogger := audit.NewAuditLog(containerInstanceArn, cfg, logger, taskAttributor{state: state})
 
 	server := taskServerSetup(credentialsManager, auditLogger, state, cfg.Cluster, statsEngine,
-		cfg.TaskMetadataSteadyStateRate, cfg.TaskMetadataBurstRate)
+		taskServerLimits(cfg))
 
 	for {
 		utils.RetryWithBackoff(utils.NewSimpleBackoff(time.Second, time.Minute, 0.2, 2), func() error {
@@ -39,6 +39,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/v2"
+	"github.com/aws/amazon-ecs-agent/agent/logger/audit"
 	mock_audit "github.com/aws/amazon-ecs-agent/agent/logger/audit/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/stats/mock"
 	"github.com/aws/aws-sdk-go/aws"
@@ -76,6 +77,13 @@ const (
 )
 
 var (
+	defaultTaskServerLimits = requestLimits{
+		steadyStateRate:            config.DefaultTaskMetadataSteadyStateRate,
+		burstRate:                  config.DefaultTaskMetadataBurstRate,
+		credentialsSteadyStateRate: config.DefaultTaskCredentialsSteadyStateRate,
+		credentialsBurstRate:       config.DefaultTaskCredentialsBurstRate,
+		maxConnections:             config.DefaultHTTPMaxConnections,
+	}
 	now  = time.Now()
 	task = &apitask.Task{
 		Arn:                 taskARN,
@@ -144,10 +152,12 @@ var (
 				HostPort:      containerPort,
 			},
 		},
-		Networks: []containermetadata.Network{
+		Networks: []v2.NetworkResponse{
 			{
-				NetworkMode:   utils.NetworkModeAWSVPC,
-				IPv4Addresses: []string{eniIPv4Address},
+				Network: containermetadata.Network{
+					NetworkMode:   utils.NetworkModeAWSVPC,
+					IPv4Addresses: []string{eniIPv4Address},
+				},
 			},
 		},
 	}
@@ -310,20 +320,21 @@ func testErrorResponsesFromServer(t *testing.T, path string, expectedErrorMessag
 
 	credentialsManager := mock_credentials.NewMockManager(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
-	server := taskServerSetup(credentialsManager, auditLog, nil, "", nil, config.DefaultTaskMetadataSteadyStateRate,
-		config.DefaultTaskMetadataBurstRate)
+	server := taskServerSetup(credentialsManager, auditLog, nil, "", nil, defaultTaskServerLimits)
 
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", path, nil)
+	HTTPErrorCode := http.StatusNotFound
+	if expectedErrorMessage != nil {
+		HTTPErrorCode = expectedErrorMessage.HTTPErrorCode
+	}
 	if path == credentials.V1CredentialsPath || path == credentials.V2CredentialsPath+"/" {
 		auditLog.EXPECT().Log(gomock.Any(), gomock.Any(), gomock.Any())
+	} else {
+		auditLog.EXPECT().Log(gomock.Any(), HTTPErrorCode, audit.MetadataEventType)
 	}
 
 	server.Handler.ServeHTTP(recorder, req)
-	HTTPErrorCode := http.StatusNotFound
-	if expectedErrorMessage != nil {
-		HTTPErrorCode = expectedErrorMessage.HTTPErrorCode
-	}
 	assert.Equal(t, HTTPErrorCode, recorder.Code, "Incorrect return code")
 
 	// Only paths that are equal to /v1/credentials will return valid error responses.
@@ -344,8 +355,7 @@ func getResponseForCredentialsRequest(t *testing.T, expectedStatus int,
 	defer ctrl.Finish()
 	credentialsManager := mock_credentials.NewMockManager(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
-	server := taskServerSetup(credentialsManager, auditLog, nil, "", nil, config.DefaultTaskMetadataSteadyStateRate,
-		config.DefaultTaskMetadataBurstRate)
+	server := taskServerSetup(credentialsManager, auditLog, nil, "", nil, defaultTaskServerLimits)
 	recorder := httptest.NewRecorder()
 
 	creds, ok := getCredentials()
@@ -404,6 +414,7 @@ func TestV2TaskMetadata(t *testing.T) {
 		t.Run(fmt.Sprintf("Testing path: %s", tc.path), func(t *testing.T) {
 			state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 			auditLog := mock_audit.NewMockAuditLogger(ctrl)
+			auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
 			statsEngine := mock_stats.NewMockEngine(ctrl)
 
 			gomock.InOrder(
@@ -412,7 +423,7 @@ func TestV2TaskMetadata(t *testing.T) {
 				state.EXPECT().ContainerMapByArn(taskARN).Return(containerNameToDockerContainer, true),
 			)
 			server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-				config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+				defaultTaskServerLimits)
 			recorder := httptest.NewRecorder()
 			req, _ := http.NewRequest("GET", tc.path, nil)
 			req.RemoteAddr = remoteIP + ":" + remotePort
@@ -435,6 +446,7 @@ func TestV2ContainerMetadata(t *testing.T) {
 
 	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
 	statsEngine := mock_stats.NewMockEngine(ctrl)
 
 	gomock.InOrder(
@@ -443,7 +455,7 @@ func TestV2ContainerMetadata(t *testing.T) {
 		state.EXPECT().TaskByID(containerID).Return(task, true),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+		defaultTaskServerLimits)
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", v2BaseMetadataPath+"/"+containerID, nil)
 	req.RemoteAddr = remoteIP + ":" + remotePort
@@ -463,15 +475,16 @@ func TestV2ContainerStats(t *testing.T) {
 
 	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
 	statsEngine := mock_stats.NewMockEngine(ctrl)
 
 	dockerStats := &docker.Stats{NumProcs: 2}
 	gomock.InOrder(
 		state.EXPECT().GetTaskByIPAddress(remoteIP).Return(taskARN, true),
-		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, false, nil),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+		defaultTaskServerLimits)
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", v2BaseStatsPath+"/"+containerID, nil)
 	req.RemoteAddr = remoteIP + ":" + remotePort
@@ -485,6 +498,38 @@ func TestV2ContainerStats(t *testing.T) {
 	assert.Equal(t, dockerStats.NumProcs, statsFromResult.NumProcs)
 }
 
+// TestV2ContainerStatsStoppedContainer tests that the last stats of stopped
+// containers are returned with the stopped flag.
+func TestV2ContainerStatsStoppedContainer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
+	statsEngine := mock_stats.NewMockEngine(ctrl)
+
+	dockerStats := &docker.Stats{NumProcs: 2}
+	gomock.InOrder(
+		state.EXPECT().GetTaskByIPAddress(remoteIP).Return(taskARN, true),
+		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, true, nil),
+	)
+	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
+		defaultTaskServerLimits)
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v2BaseStatsPath+"/"+containerID, nil)
+	req.RemoteAddr = remoteIP + ":" + remotePort
+	server.Handler.ServeHTTP(recorder, req)
+	res, err := ioutil.ReadAll(recorder.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var statsFromResult v2.ContainerStatsResponse
+	err = json.Unmarshal(res, &statsFromResult)
+	assert.NoError(t, err)
+	assert.True(t, statsFromResult.Stopped)
+	assert.Equal(t, dockerStats.NumProcs, statsFromResult.NumProcs)
+}
+
 func TestV2TaskStats(t *testing.T) {
 	testCases := []struct {
 		path string
@@ -504,6 +549,7 @@ func TestV2TaskStats(t *testing.T) {
 		t.Run(fmt.Sprintf("Testing path: %s", tc.path), func(t *testing.T) {
 			state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 			auditLog := mock_audit.NewMockAuditLogger(ctrl)
+			auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
 			statsEngine := mock_stats.NewMockEngine(ctrl)
 
 			dockerStats := &docker.Stats{NumProcs: 2}
@@ -515,10 +561,10 @@ func TestV2TaskStats(t *testing.T) {
 			gomock.InOrder(
 				state.EXPECT().GetTaskByIPAddress(remoteIP).Return(taskARN, true),
 				state.EXPECT().ContainerMapByArn(taskARN).Return(containerMap, true),
-				statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+				statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, false, nil),
 			)
 			server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-				config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+				defaultTaskServerLimits)
 			recorder := httptest.NewRecorder()
 			req, _ := http.NewRequest("GET", tc.path, nil)
 			req.RemoteAddr = remoteIP + ":" + remotePort
@@ -542,6 +588,7 @@ func TestV3TaskMetadata(t *testing.T) {
 
 	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
 	statsEngine := mock_stats.NewMockEngine(ctrl)
 
 	gomock.InOrder(
@@ -550,7 +597,7 @@ func TestV3TaskMetadata(t *testing.T) {
 		state.EXPECT().ContainerMapByArn(taskARN).Return(containerNameToDockerContainer, true),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+		defaultTaskServerLimits)
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", v3BasePath+v3EndpointID+"/task", nil)
 	server.Handler.ServeHTTP(recorder, req)
@@ -569,6 +616,7 @@ func TestV3ContainerMetadata(t *testing.T) {
 
 	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
 	statsEngine := mock_stats.NewMockEngine(ctrl)
 
 	gomock.InOrder(
@@ -577,7 +625,7 @@ func TestV3ContainerMetadata(t *testing.T) {
 		state.EXPECT().TaskByID(containerID).Return(task, true),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+		defaultTaskServerLimits)
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", v3BasePath+v3EndpointID, nil)
 	server.Handler.ServeHTTP(recorder, req)
@@ -596,6 +644,7 @@ func TestV3TaskStats(t *testing.T) {
 
 	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
 	statsEngine := mock_stats.NewMockEngine(ctrl)
 
 	dockerStats := &docker.Stats{NumProcs: 2}
@@ -609,10 +658,10 @@ func TestV3TaskStats(t *testing.T) {
 	gomock.InOrder(
 		state.EXPECT().TaskARNByV3EndpointID(v3EndpointID).Return(taskARN, true),
 		state.EXPECT().ContainerMapByArn(taskARN).Return(containerMap, true),
-		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, false, nil),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+		defaultTaskServerLimits)
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", v3BasePath+v3EndpointID+"/task/stats", nil)
 	server.Handler.ServeHTTP(recorder, req)
@@ -633,6 +682,7 @@ func TestV3ContainerStats(t *testing.T) {
 
 	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
 	statsEngine := mock_stats.NewMockEngine(ctrl)
 
 	dockerStats := &docker.Stats{NumProcs: 2}
@@ -640,10 +690,10 @@ func TestV3ContainerStats(t *testing.T) {
 	gomock.InOrder(
 		state.EXPECT().TaskARNByV3EndpointID(v3EndpointID).Return(taskARN, true),
 		state.EXPECT().DockerIDByV3EndpointID(v3EndpointID).Return(containerID, true),
-		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, false, nil),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+		defaultTaskServerLimits)
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", v3BasePath+v3EndpointID+"/stats", nil)
 	server.Handler.ServeHTTP(recorder, req)
@@ -656,6 +706,157 @@ func TestV3ContainerStats(t *testing.T) {
 	assert.Equal(t, dockerStats.NumProcs, statsFromResult.NumProcs)
 }
 
+// TestV3MetadataBridgeTask tests the v3 task and container metadata of tasks
+// in the bridge network mode, whose ports are the host ports docker bound and
+// which have no awsvpc network.
+func TestV3MetadataBridgeTask(t *testing.T) {
+	bridgeTask := &apitask.Task{
+		Arn:                      taskARN,
+		Family:                   family,
+		Version:                  version,
+		DesiredStatusUnsafe:      apitaskstatus.TaskRunning,
+		KnownStatusUnsafe:        apitaskstatus.TaskRunning,
+		CPU:                      cpu,
+		Memory:                   memory,
+		PullStartedAtUnsafe:      now,
+		PullStoppedAtUnsafe:      now,
+		ExecutionStoppedAtUnsafe: now,
+	}
+	bridgeContainer := &apicontainer.Container{
+		Name:                containerName,
+		Image:               imageName,
+		ImageID:             imageID,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		CPU:                 cpu,
+		Memory:              memory,
+		Type:                apicontainer.ContainerNormal,
+		Ports: []apicontainer.PortBinding{
+			{
+				ContainerPort: containerPort,
+				HostPort:      32768,
+				Protocol:      apicontainer.TransportProtocolTCP,
+			},
+		},
+	}
+	bridgeContainer.SetLabels(labels)
+	bridgeDockerContainer := &apicontainer.DockerContainer{
+		DockerID:   containerID,
+		DockerName: containerName,
+		Container:  bridgeContainer,
+	}
+	expectedBridgeContainerResponse := expectedContainerResponse
+	expectedBridgeContainerResponse.Ports = []v1.PortResponse{
+		{
+			ContainerPort: containerPort,
+			Protocol:      containerPortProtocol,
+			HostPort:      32768,
+		},
+	}
+	expectedBridgeContainerResponse.Networks = nil
+	expectedBridgeTaskResponse := expectedTaskResponse
+	expectedBridgeTaskResponse.Containers = []v2.ContainerResponse{expectedBridgeContainerResponse}
+
+	t.Run("task metadata", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+		auditLog := mock_audit.NewMockAuditLogger(ctrl)
+		auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
+		statsEngine := mock_stats.NewMockEngine(ctrl)
+
+		gomock.InOrder(
+			state.EXPECT().TaskARNByV3EndpointID(v3EndpointID).Return(taskARN, true),
+			state.EXPECT().TaskByArn(taskARN).Return(bridgeTask, true),
+			state.EXPECT().ContainerMapByArn(taskARN).Return(map[string]*apicontainer.DockerContainer{
+				containerName: bridgeDockerContainer,
+			}, true),
+		)
+		server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
+			defaultTaskServerLimits)
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", v3BasePath+v3EndpointID+"/task", nil)
+		server.Handler.ServeHTTP(recorder, req)
+		res, err := ioutil.ReadAll(recorder.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var taskResponse v2.TaskResponse
+		err = json.Unmarshal(res, &taskResponse)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedBridgeTaskResponse, taskResponse)
+	})
+
+	t.Run("container metadata", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+		auditLog := mock_audit.NewMockAuditLogger(ctrl)
+		auditLog.EXPECT().Log(gomock.Any(), http.StatusOK, audit.MetadataEventType)
+		statsEngine := mock_stats.NewMockEngine(ctrl)
+
+		gomock.InOrder(
+			state.EXPECT().DockerIDByV3EndpointID(v3EndpointID).Return(containerID, true),
+			state.EXPECT().ContainerByID(containerID).Return(bridgeDockerContainer, true),
+			state.EXPECT().TaskByID(containerID).Return(bridgeTask, true),
+		)
+		server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
+			defaultTaskServerLimits)
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", v3BasePath+v3EndpointID, nil)
+		server.Handler.ServeHTTP(recorder, req)
+		res, err := ioutil.ReadAll(recorder.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var containerResponse v2.ContainerResponse
+		err = json.Unmarshal(res, &containerResponse)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedBridgeContainerResponse, containerResponse)
+	})
+}
+
+// TestV3UnknownEndpointID tests that requests with unknown v3 endpoint IDs get
+// a 404, whose body doesn't depend on the ID.
+func TestV3UnknownEndpointID(t *testing.T) {
+	testPaths := []string{
+		"/v3/%s",
+		"/v3/%s/stats",
+		"/v3/%s/task",
+		"/v3/%s/task/stats",
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusNotFound, audit.MetadataEventType).Times(3 * len(testPaths))
+	statsEngine := mock_stats.NewMockEngine(ctrl)
+
+	state.EXPECT().TaskARNByV3EndpointID(gomock.Any()).Return("", false).AnyTimes()
+	state.EXPECT().DockerIDByV3EndpointID(gomock.Any()).Return("", false).AnyTimes()
+
+	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
+		defaultTaskServerLimits)
+
+	for _, testPath := range testPaths {
+		t.Run(fmt.Sprintf("Test path: %s", testPath), func(t *testing.T) {
+			var bodies []string
+			for _, id := range []string{"wrong-v3-endpoint-id", "other-v3-endpoint-id", ""} {
+				recorder := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", fmt.Sprintf(testPath, id), nil)
+				server.Handler.ServeHTTP(recorder, req)
+				assert.Equal(t, http.StatusNotFound, recorder.Code)
+				bodies = append(bodies, recorder.Body.String())
+			}
+			assert.NotContains(t, bodies[0], "wrong-v3-endpoint-id")
+			assert.Equal(t, bodies[0], bodies[1])
+			assert.Equal(t, bodies[0], bodies[2])
+		})
+	}
+}
+
 func TestTaskHTTPEndpointErrorCode404(t *testing.T) {
 	testPaths := []string{
 		"/",
@@ -675,10 +876,11 @@ func TestTaskHTTPEndpointErrorCode404(t *testing.T) {
 
 	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusNotFound, audit.MetadataEventType).Times(len(testPaths))
 	statsEngine := mock_stats.NewMockEngine(ctrl)
 
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+		defaultTaskServerLimits)
 
 	for _, testPath := range testPaths {
 		t.Run(fmt.Sprintf("Test path: %s", testPath), func(t *testing.T) {
@@ -700,14 +902,6 @@ func TestTaskHTTPEndpointErrorCode400(t *testing.T) {
 		"/v2/stats/",
 		"/v2/stats/wrong-container-id",
 		"/v2/stats/container-id/other-path",
-		"/v3/wrong-v3-endpoint-id",
-		"/v3/",
-		"/v3/wrong-v3-endpoint-id/stats",
-		"/v3//stats",
-		"/v3/wrong-v3-endpoint-id/task",
-		"/v3//task",
-		"/v3/wrong-v3-endpoint-id/task/stats",
-		"/v3//task/stats",
 	}
 
 	ctrl := gomock.NewController(t)
@@ -715,17 +909,15 @@ func TestTaskHTTPEndpointErrorCode400(t *testing.T) {
 
 	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
 	auditLog := mock_audit.NewMockAuditLogger(ctrl)
+	auditLog.EXPECT().Log(gomock.Any(), http.StatusBadRequest, audit.MetadataEventType).Times(len(testPaths))
 	statsEngine := mock_stats.NewMockEngine(ctrl)
 
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
-		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
+		defaultTaskServerLimits)
 
 	for _, testPath := range testPaths {
 		t.Run(fmt.Sprintf("Test path: %s", testPath), func(t *testing.T) {
 			// Make every possible call to state fail
-			state.EXPECT().TaskARNByV3EndpointID(gomock.Any()).Return("", false).AnyTimes()
-			state.EXPECT().DockerIDByV3EndpointID(gomock.Any()).Return("", false).AnyTimes()
-			state.EXPECT().TaskARNByV3EndpointID(gomock.Any()).Return("", false).AnyTimes()
 			state.EXPECT().GetTaskByIPAddress(gomock.Any()).Return("", false).AnyTimes()
 
 			recorder := httptest.NewRecorder()
@@ -130,10 +130,12 @@ var (
 				HostPort:      containerPort,
 			},
 		},
-		Networks: []containermetadata.Network{
+		Networks: []v2.NetworkResponse{
 			{
-				NetworkMode:   "awsvpc",
-				IPv4Addresses: []string{eniIPv4Address},
+				Network: containermetadata.Network{
+					NetworkMode:   "awsvpc",
+					IPv4Addresses: []string{eniIPv4Address},
+				},
 			},
 		},
 	}
@@ -226,7 +228,7 @@ func TestContainerStats(t *testing.T) {
 	dockerStats := &docker.Stats{NumProcs: 2}
 	gomock.InOrder(
 		state.EXPECT().GetTaskByIPAddress(remoteIP).Return(taskARN, true),
-		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, false, nil),
 	)
 	server := setupServer(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
 		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
@@ -260,7 +262,7 @@ func TestTaskStats(t *testing.T) {
 	gomock.InOrder(
 		state.EXPECT().GetTaskByIPAddress(remoteIP).Return(taskARN, true),
 		state.EXPECT().ContainerMapByArn(taskARN).Return(containerMap, true),
-		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, false, nil),
 	)
 	server := setupServer(credentials.NewManager(), auditLog, state, clusterName, statsEngine,
 		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate)
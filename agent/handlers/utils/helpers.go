@@ -44,6 +44,18 @@ const (
 	// RequestTypeAgentMetadata specifies the Agent metadata request type of AgentMetadataHandler.
 	RequestTypeAgentMetadata = "agent metadata"
 
+	// RequestTypeEventStats specifies the event stats request type of EventStatsHandler.
+	RequestTypeEventStats = "event stats"
+
+	// RequestTypeExec specifies the exec request type of ExecHandler.
+	RequestTypeExec = "exec"
+
+	// RequestTypeAgentHealth specifies the agent health request type of AgentHealthHandler.
+	RequestTypeAgentHealth = "agent health"
+
+	// RequestTypeCredentialsStatus specifies the credentials status request type of CredentialsStatusHandler.
+	RequestTypeCredentialsStatus = "credentials status"
+
 	// AnythingButSlashRegEx is a regex pattern that matches any string without slash.
 	AnythingButSlashRegEx = "[^/]*"
 
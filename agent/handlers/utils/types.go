@@ -13,10 +13,70 @@
 
 package utils
 
-import "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/agenthealth"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
+)
+
+// ACSConnectionStatusProvider is a sub-interface for the status of the
+// connection to ACS to make it easy to test code in this package
+type ACSConnectionStatusProvider interface {
+	LastMessageReceivedAt() time.Time
+	Reconnects() int
+}
+
+// AgentHealthProvider is a sub-interface for the agenthealth.Monitor to make
+// it easy to test code in this package
+type AgentHealthProvider interface {
+	Health() agenthealth.Health
+}
+
+// CredentialsStatusProvider is a sub-interface for the credentials.Manager
+// to make it easy to test code in this package
+type CredentialsStatusProvider interface {
+	GetCredentialsStatus() []credentials.CredentialsStatus
+}
 
 // DockerStateResolver is a sub-interface for the engine.TaskEngine interface
 // to make it easy to test code in this package
 type DockerStateResolver interface {
 	State() dockerstate.TaskEngineState
 }
+
+// AuthConfigStatusProvider is a sub-interface for the engine.DockerTaskEngine
+// to make it easy to test code in this package
+type AuthConfigStatusProvider interface {
+	AuthConfigFileLoadedAt() time.Time
+}
+
+// ContainerExecutor is a sub-interface for the engine.DockerTaskEngine to
+// make it easy to test code in this package
+type ContainerExecutor interface {
+	ExecCommand(ctx context.Context, request engine.ExecRequest, stdin io.Reader, stdout io.Writer,
+		stderr io.Writer) (int, error)
+}
+
+// EventStatsProvider is a sub-interface for the eventhandler.TaskHandler to
+// make it easy to test code in this package
+type EventStatsProvider interface {
+	GetEventStats() eventhandler.EventStats
+}
+
+// StateChangeEventsProvider is a sub-interface for the
+// eventhandler.EventBroadcaster to make it easy to test code in this package
+type StateChangeEventsProvider interface {
+	Subscribe() *eventhandler.EventSubscription
+}
+
+// InstanceStatusProvider is a sub-interface for the status of the container
+// instance sent by ACS to make it easy to test code in this package
+type InstanceStatusProvider interface {
+	Draining() bool
+}
@@ -0,0 +1,32 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+)
+
+// AgentHealthPath is the path of the health of the agent for v1 handler.
+const AgentHealthPath = "/v1/agenthealth"
+
+// AgentHealthHandler creates response for 'v1/agenthealth' API.
+func AgentHealthHandler(agentHealth utils.AgentHealthProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseJSON, _ := json.Marshal(agentHealth.Health())
+		utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeAgentHealth)
+	}
+}
@@ -26,12 +26,26 @@ import (
 const AgentMetadataPath = "/v1/metadata"
 
 // AgentMetadataHandler creates response for 'v1/metadata' API.
-func AgentMetadataHandler(containerInstanceArn *string, cfg *config.Config) func(http.ResponseWriter, *http.Request) {
+func AgentMetadataHandler(containerInstanceArn *string,
+	cfg *config.Config,
+	authConfigStatus utils.AuthConfigStatusProvider,
+	acsConnectionStatus utils.ACSConnectionStatusProvider,
+	instanceStatus utils.InstanceStatusProvider) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		resp := &MetadataResponse{
 			Cluster:              cfg.Cluster,
 			ContainerInstanceArn: containerInstanceArn,
 			Version:              agentversion.String(),
+			ACSReconnects:        acsConnectionStatus.Reconnects(),
+			Draining:             instanceStatus.Draining(),
+		}
+		if receivedAt := acsConnectionStatus.LastMessageReceivedAt(); !receivedAt.IsZero() {
+			resp.ACSLastMessageReceivedAt = &receivedAt
+		}
+		if cfg.EngineAuthConfigFile != "" {
+			if loadedAt := authConfigStatus.AuthConfigFileLoadedAt(); !loadedAt.IsZero() {
+				resp.EngineAuthConfigFileLoadedAt = &loadedAt
+			}
 		}
 		responseJSON, _ := json.Marshal(resp)
 		utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeAgentMetadata)
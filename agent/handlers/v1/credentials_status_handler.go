@@ -0,0 +1,54 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+)
+
+// CredentialsStatusPath is the path of the status of the credentials of the
+// tasks for v1 handler.
+const CredentialsStatusPath = "/v1/credentialsstatus"
+
+// CredentialsStatusResponse is the schema of the status of the credentials of
+// a task. The id of the credentials is left out, as it's what the credentials
+// are fetched with from the credentials endpoint
+type CredentialsStatusResponse struct {
+	TaskARN     string    `json:"TaskARN"`
+	RoleType    string    `json:"RoleType"`
+	RefreshedAt time.Time `json:"RefreshedAt"`
+	ExpiresAt   time.Time `json:"ExpiresAt"`
+}
+
+// CredentialsStatusHandler creates response for 'v1/credentialsstatus' API.
+func CredentialsStatusHandler(credentialsStatus utils.CredentialsStatusProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := credentialsStatus.GetCredentialsStatus()
+		response := make([]CredentialsStatusResponse, 0, len(statuses))
+		for _, status := range statuses {
+			response = append(response, CredentialsStatusResponse{
+				TaskARN:     status.TaskARN,
+				RoleType:    status.RoleType,
+				RefreshedAt: status.RefreshedAt,
+				ExpiresAt:   status.ExpiresAt,
+			})
+		}
+		responseJSON, _ := json.Marshal(response)
+		utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeCredentialsStatus)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+)
+
+// EventStatsPath is the path of the state change event stats for v1 handler.
+const EventStatsPath = "/v1/stats/events"
+
+// EventStatsHandler creates response for 'v1/stats/events' API.
+func EventStatsHandler(eventStats utils.EventStatsProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseJSON, _ := json.Marshal(eventStats.GetEventStats())
+		utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeEventStats)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
+	handlersutils "github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/cihub/seelog"
+)
+
+const (
+	// EventsPath is the path of the stream of the task and container state
+	// change events
+	EventsPath = "/v1/events"
+	// eventsFormatQueryParameter selects the format of the stream, which is
+	// newline delimited JSON unless it's 'sse', for server-sent events
+	eventsFormatQueryParameter = "format"
+	// eventsReplayQueryParameter, if true, makes the stream start with the
+	// current state of the tasks and containers
+	eventsReplayQueryParameter = "replay"
+	sseEventsFormat            = "sse"
+	sseContentType             = "text/event-stream"
+	jsonLinesContentType       = "application/x-ndjson"
+	// maxEventStreams is the maximum number of concurrent event streams. The
+	// connections of the streams are hijacked from the server, and aren't
+	// counted against its connection limit
+	maxEventStreams = 16
+	// eventWriteTimeout is the time after which writing an event to a stream
+	// is abandoned and the stream is closed
+	eventWriteTimeout = 5 * time.Second
+)
+
+// EventsHandler creates response for '/v1/events' API, streaming the task and
+// container state change events from the time of the request. The events are
+// written as newline delimited JSON, or as server-sent events, until the
+// client disconnects. Events are dropped for clients that don't keep up, and
+// the number of events dropped is reported with the next event written
+func EventsHandler(stateChangeEvents handlersutils.StateChangeEventsProvider,
+	taskEngine handlersutils.DockerStateResolver) func(http.ResponseWriter, *http.Request) {
+	var streams int64
+	return func(w http.ResponseWriter, r *http.Request) {
+		sse := r.URL.Query().Get(eventsFormatQueryParameter) == sseEventsFormat ||
+			r.Header.Get("Accept") == sseContentType
+		replay := false
+		if value := r.URL.Query().Get(eventsReplayQueryParameter); value != "" {
+			var err error
+			if replay, err = strconv.ParseBool(value); err != nil {
+				http.Error(w, "invalid value for "+eventsReplayQueryParameter, http.StatusBadRequest)
+				return
+			}
+		}
+		if atomic.AddInt64(&streams, 1) > maxEventStreams {
+			atomic.AddInt64(&streams, -1)
+			http.Error(w, "too many event streams", http.StatusTooManyRequests)
+			return
+		}
+		defer atomic.AddInt64(&streams, -1)
+
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "event streams are not supported", http.StatusInternalServerError)
+			return
+		}
+		// The subscription is made before the state is replayed, so that no
+		// state change is missed
+		subscription := stateChangeEvents.Subscribe()
+		defer subscription.Close()
+
+		// The connection is hijacked as the write timeout of the server would
+		// end the stream
+		conn, bufrw, err := hijacker.Hijack()
+		if err != nil {
+			seelog.Errorf("Events handler: unable to hijack the connection: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Time{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// Reading from the connection fails once the client disconnects
+		go func() {
+			io.Copy(ioutil.Discard, bufrw.Reader)
+			cancel()
+		}()
+
+		stream := &eventStream{conn: conn, writer: bufrw.Writer, sse: sse}
+		if err := stream.writeHeader(); err != nil {
+			return
+		}
+		if replay {
+			for _, event := range replayedEvents(taskEngine.State()) {
+				if err := stream.write(event); err != nil {
+					return
+				}
+			}
+		}
+		for {
+			event, ok := subscription.Next(ctx)
+			if !ok {
+				return
+			}
+			if err := stream.write(event); err != nil {
+				seelog.Debugf("Events handler: unable to write to the client: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// eventStream writes the events to the hijacked connection of a client
+type eventStream struct {
+	conn   net.Conn
+	writer *bufio.Writer
+	sse    bool
+}
+
+// writeHeader writes the response header. The body of the response ends when
+// the connection is closed
+func (stream *eventStream) writeHeader() error {
+	contentType := jsonLinesContentType
+	if stream.sse {
+		contentType = sseContentType
+	}
+	fmt.Fprintf(stream.writer, "HTTP/1.1 200 OK\r\nContent-Type: %s\r\nCache-Control: no-cache\r\nConnection: close\r\n\r\n",
+		contentType)
+	return stream.flush()
+}
+
+func (stream *eventStream) write(event eventhandler.StreamedEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		seelog.Warnf("Events handler: unable to marshal event: %v", err)
+		return nil
+	}
+	if stream.sse {
+		fmt.Fprintf(stream.writer, "event: %s\ndata: %s\n\n", event.Type, data)
+	} else {
+		stream.writer.Write(data)
+		stream.writer.WriteByte('\n')
+	}
+	return stream.flush()
+}
+
+func (stream *eventStream) flush() error {
+	stream.conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+	return stream.writer.Flush()
+}
+
+// replayedEvents returns the events describing the current state of the tasks
+// and of their containers
+func replayedEvents(state dockerstate.TaskEngineState) []eventhandler.StreamedEvent {
+	var events []eventhandler.StreamedEvent
+	for _, task := range state.AllTasks() {
+		containerMap, _ := state.ContainerMapByArn(task.Arn)
+		for _, dockerContainer := range containerMap {
+			container := dockerContainer.Container
+			if container.IsInternal() {
+				continue
+			}
+			events = appendReplayedEvent(events, eventhandler.ContainerStreamedEventType, api.ContainerStateChange{
+				TaskArn:       task.Arn,
+				ContainerName: container.Name,
+				RuntimeID:     dockerContainer.DockerID,
+				ImageDigest:   container.GetImageDigest(),
+				Status:        container.GetKnownStatus(),
+				ExitCode:      container.GetKnownExitCode(),
+				PortBindings:  container.GetKnownPortBindings(),
+			})
+		}
+		events = appendReplayedEvent(events, eventhandler.TaskStreamedEventType, api.TaskStateChange{
+			TaskARN: task.Arn,
+			Status:  task.GetKnownStatus(),
+		})
+	}
+	return events
+}
+
+func appendReplayedEvent(events []eventhandler.StreamedEvent, eventType string,
+	change json.Marshaler) []eventhandler.StreamedEvent {
+	data, err := change.MarshalJSON()
+	if err != nil {
+		seelog.Warnf("Events handler: unable to marshal replayed %s event: %v", eventType, err)
+		return events
+	}
+	return append(events, eventhandler.StreamedEvent{Type: eventType, Replayed: true, Event: data})
+}
@@ -0,0 +1,162 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
+	mock_utils "github.com/aws/amazon-ecs-agent/agent/handlers/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEventsTestServer(t *testing.T, ctx context.Context, ctrl *gomock.Controller) (*httptest.Server, *eventhandler.EventBroadcaster) {
+	broadcaster, err := eventhandler.NewEventBroadcaster(ctx, "")
+	require.NoError(t, err)
+
+	task := &apitask.Task{
+		Arn:               taskARN,
+		KnownStatusUnsafe: apitaskstatus.TaskRunning,
+	}
+	container := &apicontainer.Container{
+		Name:              containerName,
+		KnownStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	state := dockerstate.NewTaskEngineState()
+	state.AddTask(task)
+	state.AddContainer(&apicontainer.DockerContainer{DockerID: containerID, Container: container}, task)
+	taskEngine := mock_utils.NewMockDockerStateResolver(ctrl)
+	taskEngine.EXPECT().State().Return(state).AnyTimes()
+
+	serverMux := http.NewServeMux()
+	serverMux.HandleFunc(EventsPath, EventsHandler(broadcaster, taskEngine))
+	return httptest.NewServer(serverMux), broadcaster
+}
+
+func readStreamedEvent(t *testing.T, reader *bufio.Reader) eventhandler.StreamedEvent {
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+	var event eventhandler.StreamedEvent
+	require.NoError(t, json.Unmarshal(line, &event))
+	return event
+}
+
+func TestEventsHandlerStreamsJSONLines(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, broadcaster := newEventsTestServer(t, ctx, ctrl)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + EventsPath + "?replay=true")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, jsonLinesContentType, resp.Header.Get("Content-Type"))
+
+	// The current state is replayed, containers first
+	reader := bufio.NewReader(resp.Body)
+	event := readStreamedEvent(t, reader)
+	assert.Equal(t, eventhandler.ContainerStreamedEventType, event.Type)
+	assert.True(t, event.Replayed)
+	var change map[string]interface{}
+	require.NoError(t, json.Unmarshal(event.Event, &change))
+	assert.Equal(t, containerName, change["containerName"])
+	assert.Equal(t, containerID, change["runtimeId"])
+	assert.Equal(t, "RUNNING", change["status"])
+
+	event = readStreamedEvent(t, reader)
+	assert.Equal(t, eventhandler.TaskStreamedEventType, event.Type)
+	assert.True(t, event.Replayed)
+
+	// The state changes follow, as the subscription is made before the
+	// replay
+	broadcaster.Broadcast(api.TaskStateChange{TaskARN: taskARN, Status: apitaskstatus.TaskStopped})
+	event = readStreamedEvent(t, reader)
+	assert.Equal(t, eventhandler.TaskStreamedEventType, event.Type)
+	assert.False(t, event.Replayed)
+	change = nil
+	require.NoError(t, json.Unmarshal(event.Event, &change))
+	assert.Equal(t, "STOPPED", change["status"])
+}
+
+func TestEventsHandlerStreamsServerSentEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, _ := newEventsTestServer(t, ctx, ctrl)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + EventsPath + "?format=sse&replay=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, sseContentType, resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: container\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "data: {"), "unexpected data line: %s", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "\n", line)
+}
+
+func TestEventsHandlerInvalidReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, _ := newEventsTestServer(t, ctx, ctrl)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + EventsPath + "?replay=maybe")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestEventsHandlerWithoutHijacking(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcaster, err := eventhandler.NewEventBroadcaster(ctx, "")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", EventsPath, nil)
+	EventsHandler(broadcaster, mock_utils.NewMockDockerStateResolver(ctrl))(recorder, req)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
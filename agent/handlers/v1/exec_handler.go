@@ -0,0 +1,318 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExecPath is the path of the API to run a command in a container of a task
+	ExecPath = "/v1/exec"
+
+	// ExecExitCodeTrailer is the trailer of the exec response carrying the
+	// exit code of the command
+	ExecExitCodeTrailer = "Exec-Exit-Code"
+
+	// ExecErrorTrailer is the trailer of the exec response carrying the error
+	// the command failed with after its output started streaming
+	ExecErrorTrailer = "Exec-Error"
+
+	// ErrExecForbidden is the error code indicating that the exec request
+	// didn't come from the instance itself
+	ErrExecForbidden = "Forbidden"
+
+	// ErrExecUnauthorized is the error code indicating that the exec token
+	// in the request is missing or invalid
+	ErrExecUnauthorized = "Unauthorized"
+
+	// ErrInvalidExecRequest is the error code indicating that the exec
+	// request couldn't be parsed or is missing fields
+	ErrInvalidExecRequest = "InvalidExecRequest"
+
+	// ErrExecContainerNotFound is the error code indicating that the
+	// container of the exec request isn't known to the agent
+	ErrExecContainerNotFound = "ContainerNotFound"
+
+	// ErrExecContainerNotRunning is the error code indicating that the
+	// container of the exec request isn't running
+	ErrExecContainerNotRunning = "ContainerNotRunning"
+
+	// ErrExecFailed is the error code indicating that the command couldn't be
+	// run in the container
+	ErrExecFailed = "ExecFailed"
+
+	execAuthorizationPrefix = "Bearer "
+	// maxExecRequestSize bounds the size of the body of exec requests
+	maxExecRequestSize = 64 * 1024
+	// execStreamWriteTimeout bounds writing each chunk of the output of the
+	// command once the connection has been taken from the server
+	execStreamWriteTimeout = 30 * time.Second
+)
+
+// ExecRequest is the schema of the body of exec requests
+type ExecRequest struct {
+	TaskArn       string   `json:"TaskArn"`
+	ContainerName string   `json:"ContainerName"`
+	Command       []string `json:"Command"`
+}
+
+// ExecHandler creates the handler for the 'v1/exec' API. Requests must carry
+// the exec token as a bearer token, and are only served over the loopback
+// interface as the introspection server doesn't use TLS. Whatever the client sends on the connection
+// after the body of the request is streamed as the input of the command. The
+// merged stdout and stderr of the command is streamed as the body of the
+// response, and its exit code is sent in the Exec-Exit-Code trailer. Closing
+// the input ends the input of the command, the command is cancelled once the
+// connection is lost
+func ExecHandler(executor utils.ContainerExecutor, token []byte) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeExecErrorResponse(w, http.StatusMethodNotAllowed, ErrInvalidExecRequest,
+				"Exec requests must use "+http.MethodPost)
+			return
+		}
+		if !loopbackRequest(r) {
+			seelog.Warnf("Refusing exec request from outside the instance. Request IP Address: %s", r.RemoteAddr)
+			writeExecErrorResponse(w, http.StatusForbidden, ErrExecForbidden,
+				"Exec requests are only served over the loopback interface")
+			return
+		}
+		if !validExecToken(r, token) {
+			seelog.Warnf("Refusing exec request with an invalid token. Request IP Address: %s", r.RemoteAddr)
+			writeExecErrorResponse(w, http.StatusUnauthorized, ErrExecUnauthorized, "Invalid exec token")
+			return
+		}
+
+		var execRequest ExecRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxExecRequestSize)).Decode(&execRequest); err != nil {
+			writeExecErrorResponse(w, http.StatusBadRequest, ErrInvalidExecRequest,
+				fmt.Sprintf("Unable to parse exec request: %v", err))
+			return
+		}
+		if execRequest.TaskArn == "" || execRequest.ContainerName == "" || len(execRequest.Command) == 0 {
+			writeExecErrorResponse(w, http.StatusBadRequest, ErrInvalidExecRequest,
+				"Exec requests require TaskArn, ContainerName and Command")
+			return
+		}
+		// The rest of the body would otherwise be read as the input of the
+		// command once the connection is taken from the server
+		io.Copy(ioutil.Discard, io.LimitReader(r.Body, maxExecRequestSize))
+
+		// The context of the request is cancelled as soon as the client closes
+		// its side of the connection, which only ends the input of the
+		// command. The stream cancels the command once the connection is lost
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream := &execStream{w: w, cancel: cancel}
+		exitCode, err := executor.ExecCommand(ctx, engine.ExecRequest{
+			TaskARN:       execRequest.TaskArn,
+			ContainerName: execRequest.ContainerName,
+			Command:       execRequest.Command,
+			Requester:     r.RemoteAddr,
+		}, stream, stream, stream)
+		if stream.finish(exitCode, err) {
+			return
+		}
+
+		// Nothing was streamed by the command, the response can still carry
+		// the status of the request
+		if err != nil {
+			switch err.(type) {
+			case *engine.ExecContainerNotFoundError:
+				writeExecErrorResponse(w, http.StatusNotFound, ErrExecContainerNotFound, err.Error())
+			case *engine.ExecContainerNotRunningError:
+				writeExecErrorResponse(w, http.StatusConflict, ErrExecContainerNotRunning, err.Error())
+			default:
+				writeExecErrorResponse(w, http.StatusInternalServerError, ErrExecFailed, err.Error())
+			}
+			return
+		}
+		w.Header().Set("Trailer", ExecExitCodeTrailer)
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set(ExecExitCodeTrailer, strconv.Itoa(exitCode))
+	}
+}
+
+// loopbackRequest returns true if the request was sent over the loopback
+// interface, where the token can't be read on the wire
+func loopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func validExecToken(r *http.Request, token []byte) bool {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, execAuthorizationPrefix) {
+		return false
+	}
+	requestToken := []byte(strings.TrimPrefix(authorization, execAuthorizationPrefix))
+	return len(token) > 0 && subtle.ConstantTimeCompare(requestToken, token) == 1
+}
+
+func writeExecErrorResponse(w http.ResponseWriter, httpStatusCode int, code string, message string) {
+	responseJSON, _ := json.Marshal(&utils.ErrorMessage{
+		Code:          code,
+		Message:       message,
+		HTTPErrorCode: httpStatusCode,
+	})
+	utils.WriteJSONToResponse(w, httpStatusCode, responseJSON, utils.RequestTypeExec)
+}
+
+// execStream streams the input of the command from the connection of the
+// request, and the output of the command as the chunked body of the response.
+// The connection is taken from the server when the command first reads its
+// input or writes its output, as the write timeout of the introspection server
+// is far shorter than the time commands are allowed to run
+type execStream struct {
+	lock   sync.Mutex
+	w      http.ResponseWriter
+	cancel context.CancelFunc
+	conn   net.Conn
+	buf    *bufio.ReadWriter
+	body   io.WriteCloser
+	// finished is set once the command is done, the connection can't be
+	// taken from the server after the request has been handled
+	finished bool
+}
+
+// Read reads the input of the command from the connection. The input of the
+// command ends when the client closes its side of the connection, the command
+// is cancelled if the connection can't be read anymore
+func (stream *execStream) Read(p []byte) (int, error) {
+	stream.lock.Lock()
+	if stream.finished {
+		stream.lock.Unlock()
+		return 0, io.EOF
+	}
+	if stream.body == nil {
+		if err := stream.startUnsafe(); err != nil {
+			stream.lock.Unlock()
+			stream.cancel()
+			return 0, err
+		}
+	}
+	input := stream.buf.Reader
+	stream.lock.Unlock()
+
+	// The lock isn't held while waiting for the input, as the output of the
+	// command is written meanwhile. The reader and the writer of the
+	// connection are independent
+	n, err := input.Read(p)
+	if err != nil && err != io.EOF {
+		seelog.Debugf("Exec handler: cancelling the command as its connection is lost: %v", err)
+		stream.cancel()
+	}
+	return n, err
+}
+
+func (stream *execStream) Write(p []byte) (int, error) {
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+
+	if stream.finished {
+		return 0, errors.New("exec handler: the command is done")
+	}
+	if stream.body == nil {
+		if err := stream.startUnsafe(); err != nil {
+			return 0, err
+		}
+	}
+	stream.conn.SetWriteDeadline(time.Now().Add(execStreamWriteTimeout))
+	n, err := stream.body.Write(p)
+	if err == nil {
+		err = stream.buf.Flush()
+	}
+	if err != nil {
+		seelog.Debugf("Exec handler: cancelling the command as its connection is lost: %v", err)
+		stream.cancel()
+	}
+	return n, err
+}
+
+func (stream *execStream) startUnsafe() error {
+	hijacker, ok := stream.w.(http.Hijacker)
+	if !ok {
+		return errors.New("exec handler: streaming the command is not supported by the response writer")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return errors.Wrap(err, "exec handler: unable to take the connection of the request")
+	}
+	// The read timeout of the server would otherwise end the input of the
+	// command shortly after the request
+	conn.SetReadDeadline(time.Time{})
+	stream.conn = conn
+	stream.buf = buf
+	buf.WriteString("HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"Trailer: " + ExecExitCodeTrailer + ", " + ExecErrorTrailer + "\r\n" +
+		"Connection: close\r\n\r\n")
+	stream.body = httputil.NewChunkedWriter(buf)
+	conn.SetWriteDeadline(time.Now().Add(execStreamWriteTimeout))
+	return buf.Flush()
+}
+
+// finish ends the streamed response with the outcome of the command. It
+// returns false if the command didn't read its input nor write any output, in
+// which case the response hasn't been started
+func (stream *execStream) finish(exitCode int, execErr error) bool {
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+
+	stream.finished = true
+	if stream.body == nil {
+		return false
+	}
+	defer stream.conn.Close()
+
+	stream.conn.SetWriteDeadline(time.Now().Add(execStreamWriteTimeout))
+	stream.body.Close()
+	if execErr != nil {
+		// Trailer values can't span lines
+		message := strings.NewReplacer("\r", " ", "\n", " ").Replace(execErr.Error())
+		fmt.Fprintf(stream.buf, "%s: %s\r\n", ExecErrorTrailer, message)
+	} else {
+		fmt.Fprintf(stream.buf, "%s: %d\r\n", ExecExitCodeTrailer, exitCode)
+	}
+	stream.buf.WriteString("\r\n")
+	if err := stream.buf.Flush(); err != nil {
+		seelog.Warnf("Unable to write the end of the exec response: %v", err)
+	}
+	return true
+}
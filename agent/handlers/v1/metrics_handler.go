@@ -0,0 +1,44 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/cihub/seelog"
+)
+
+// MetricsPath is the path of the metrics of the agent in the Prometheus text
+// exposition format
+const MetricsPath = "/metrics"
+
+// MetricsHandler creates response for '/metrics' API, gathering the metrics of
+// the sources on each request.
+func MetricsHandler(sources []metrics.Source) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := metrics.WriteText(&buf, metrics.Gather(sources...)); err != nil {
+			seelog.Errorf("Unable to write the metrics: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", metrics.ContentType)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			seelog.Errorf("Unable to write the metrics response: %v", err)
+		}
+	}
+}
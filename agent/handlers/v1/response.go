@@ -14,6 +14,8 @@
 package v1
 
 import (
+	"time"
+
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
@@ -27,6 +29,18 @@ type MetadataResponse struct {
 	Cluster              string  `json:"Cluster"`
 	ContainerInstanceArn *string `json:"ContainerInstanceArn"`
 	Version              string  `json:"Version"`
+	// EngineAuthConfigFileLoadedAt is when the credentials of registries were
+	// last loaded from the docker config file configured for the agent
+	EngineAuthConfigFileLoadedAt *time.Time `json:"EngineAuthConfigFileLoadedAt,omitempty"`
+	// ACSLastMessageReceivedAt is when the last message, heartbeats
+	// included, was received from ACS
+	ACSLastMessageReceivedAt *time.Time `json:"ACSLastMessageReceivedAt,omitempty"`
+	// ACSReconnects is how many times the agent connected to ACS again
+	// after its first connection
+	ACSReconnects int `json:"ACSReconnects"`
+	// Draining is true while ACS is draining the container instance, which
+	// refuses new tasks
+	Draining bool `json:"Draining"`
 }
 
 // TaskResponse is the schema for the task response JSON object
@@ -46,12 +60,33 @@ type TasksResponse struct {
 
 // ContainerResponse is the schema for the container response JSON object
 type ContainerResponse struct {
-	DockerID   string                      `json:"DockerId"`
-	DockerName string                      `json:"DockerName"`
-	Name       string                      `json:"Name"`
-	Ports      []PortResponse              `json:"Ports,omitempty"`
-	Networks   []containermetadata.Network `json:"Networks,omitempty"`
-	Volumes    []VolumeResponse            `json:"Volumes,omitempty"`
+	DockerID         string                      `json:"DockerId"`
+	DockerName       string                      `json:"DockerName"`
+	Name             string                      `json:"Name"`
+	Ports            []PortResponse              `json:"Ports,omitempty"`
+	Networks         []containermetadata.Network `json:"Networks,omitempty"`
+	Volumes          []VolumeResponse            `json:"Volumes,omitempty"`
+	RestartCount     int                         `json:"RestartCount,omitempty"`
+	ExitHistory      []ContainerExitResponse     `json:"ExitHistory,omitempty"`
+	Ulimits          []UlimitResponse            `json:"Ulimits,omitempty"`
+	SystemControls   map[string]string           `json:"SystemControls,omitempty"`
+	MetadataFilePath string                      `json:"MetadataFilePath,omitempty"`
+}
+
+// ContainerExitResponse is the schema for the container exit response JSON
+// object
+type ContainerExitResponse struct {
+	Time        time.Time `json:"Time"`
+	ExitCode    *int      `json:"ExitCode,omitempty"`
+	Reason      string    `json:"Reason,omitempty"`
+	OutOfMemory bool      `json:"OutOfMemory,omitempty"`
+}
+
+// UlimitResponse is the schema for the ulimit response JSON object
+type UlimitResponse struct {
+	Name string `json:"Name"`
+	Soft int64  `json:"Soft"`
+	Hard int64  `json:"Hard"`
 }
 
 // VolumeResponse is the schema for the volume response JSON object
@@ -103,13 +138,18 @@ func NewTaskResponse(task *apitask.Task, containerMap map[string]*apicontainer.D
 func NewContainerResponse(dockerContainer *apicontainer.DockerContainer, eni *apieni.ENI) ContainerResponse {
 	container := dockerContainer.Container
 	resp := ContainerResponse{
-		Name:       container.Name,
-		DockerID:   dockerContainer.DockerID,
-		DockerName: dockerContainer.DockerName,
+		Name:             container.Name,
+		DockerID:         dockerContainer.DockerID,
+		DockerName:       dockerContainer.DockerName,
+		RestartCount:     container.GetRestartCount(),
+		MetadataFilePath: container.GetMetadataFilePath(),
 	}
 
 	resp.Ports = NewPortBindingsResponse(dockerContainer, eni)
 	resp.Volumes = NewVolumesResponse(dockerContainer)
+	resp.ExitHistory = NewExitHistoryResponse(container)
+	resp.Ulimits = NewUlimitsResponse(container)
+	resp.SystemControls = container.SystemControls
 
 	if eni != nil {
 		resp.Networks = []containermetadata.Network{
@@ -172,6 +212,34 @@ func NewVolumesResponse(dockerContainer *apicontainer.DockerContainer) []VolumeR
 	return resp
 }
 
+// NewExitHistoryResponse creates ContainerExitResponse for the recorded exits
+// of a container, oldest first.
+func NewExitHistoryResponse(container *apicontainer.Container) []ContainerExitResponse {
+	var resp []ContainerExitResponse
+	for _, exit := range container.GetExitHistory() {
+		resp = append(resp, ContainerExitResponse{
+			Time:        exit.Time.UTC(),
+			ExitCode:    exit.ExitCode,
+			Reason:      exit.Reason,
+			OutOfMemory: exit.OutOfMemory,
+		})
+	}
+	return resp
+}
+
+// NewUlimitsResponse creates UlimitResponse for the ulimits of a container.
+func NewUlimitsResponse(container *apicontainer.Container) []UlimitResponse {
+	var resp []UlimitResponse
+	for _, ulimit := range container.Ulimits {
+		resp = append(resp, UlimitResponse{
+			Name: ulimit.Name,
+			Soft: ulimit.Soft,
+			Hard: ulimit.Hard,
+		})
+	}
+	return resp
+}
+
 // NewTasksResponse creates TasksResponse for all the tasks.
 func NewTasksResponse(state dockerstate.TaskEngineState) *TasksResponse {
 	allTasks := state.AllTasks()
@@ -18,6 +18,7 @@ package v1
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
@@ -28,15 +29,16 @@ import (
 )
 
 const (
-	taskARN        = "t1"
-	family         = "sleep"
-	version        = "1"
-	containerID    = "cid"
-	containerName  = "sleepy"
-	eniIPv4Address = "10.0.0.2"
-	volName        = "volume1"
-	volSource      = "/var/lib/volume1"
-	volDestination = "/volume"
+	taskARN          = "t1"
+	family           = "sleep"
+	version          = "1"
+	containerID      = "cid"
+	containerName    = "sleepy"
+	eniIPv4Address   = "10.0.0.2"
+	volName          = "volume1"
+	volSource        = "/var/lib/volume1"
+	volDestination   = "/volume"
+	metadataFilePath = "/var/lib/ecs/data/metadata/task-id/sleepy/ecs-container-metadata.json"
 )
 
 func TestTaskResponse(t *testing.T) {
@@ -155,8 +157,29 @@ func TestContainerResponse(t *testing.T) {
 				"Destination": volDestination,
 			},
 		},
+		"RestartCount": float64(1),
+		"ExitHistory": []interface{}{
+			map[string]interface{}{
+				"Time":        "2017-07-14T02:40:00Z",
+				"ExitCode":    float64(137),
+				"Reason":      "OutOfMemoryError: Container killed due to memory usage",
+				"OutOfMemory": true,
+			},
+		},
+		"Ulimits": []interface{}{
+			map[string]interface{}{
+				"Name": "nofile",
+				"Soft": float64(1024),
+				"Hard": float64(4096),
+			},
+		},
+		"SystemControls": map[string]interface{}{
+			"net.core.somaxconn": "1024",
+		},
+		"MetadataFilePath": metadataFilePath,
 	}
 
+	exitCode := 137
 	container := &apicontainer.Container{
 		Name: containerName,
 		Ports: []apicontainer.PortBinding{
@@ -172,6 +195,18 @@ func TestContainerResponse(t *testing.T) {
 				Destination: volDestination,
 			},
 		},
+		RestartCountUnsafe: 1,
+		ExitHistoryUnsafe: []apicontainer.ContainerExit{
+			{
+				Time:        time.Unix(1500000000, 0),
+				ExitCode:    &exitCode,
+				Reason:      "OutOfMemoryError: Container killed due to memory usage",
+				OutOfMemory: true,
+			},
+		},
+		Ulimits:          []apicontainer.Ulimit{{Name: "nofile", Soft: 1024, Hard: 4096}},
+		SystemControls:   map[string]string{"net.core.somaxconn": "1024"},
+		MetadataFilePath: metadataFilePath,
 	}
 
 	dockerContainer := &apicontainer.DockerContainer{
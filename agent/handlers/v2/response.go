@@ -27,42 +27,55 @@ import (
 	"github.com/pkg/errors"
 )
 
+const bytesPerMiB = 1024 * 1024
+
 // TaskResponse defines the schema for the task response JSON object
 type TaskResponse struct {
-	Cluster            string              `json:"Cluster"`
-	TaskARN            string              `json:"TaskARN"`
-	Family             string              `json:"Family"`
-	Revision           string              `json:"Revision"`
-	DesiredStatus      string              `json:"DesiredStatus,omitempty"`
-	KnownStatus        string              `json:"KnownStatus"`
-	Containers         []ContainerResponse `json:"Containers,omitempty"`
-	Limits             *LimitsResponse     `json:"Limits,omitempty"`
-	PullStartedAt      *time.Time          `json:"PullStartedAt,omitempty"`
-	PullStoppedAt      *time.Time          `json:"PullStoppedAt,omitempty"`
-	ExecutionStoppedAt *time.Time          `json:"ExecutionStoppedAt,omitempty"`
+	Cluster            string                    `json:"Cluster"`
+	TaskARN            string                    `json:"TaskARN"`
+	Family             string                    `json:"Family"`
+	Revision           string                    `json:"Revision"`
+	DesiredStatus      string                    `json:"DesiredStatus,omitempty"`
+	KnownStatus        string                    `json:"KnownStatus"`
+	Containers         []ContainerResponse       `json:"Containers,omitempty"`
+	Limits             *LimitsResponse           `json:"Limits,omitempty"`
+	EphemeralStorage   *EphemeralStorageResponse `json:"EphemeralStorage,omitempty"`
+	PullStartedAt      *time.Time                `json:"PullStartedAt,omitempty"`
+	PullStoppedAt      *time.Time                `json:"PullStoppedAt,omitempty"`
+	ExecutionStoppedAt *time.Time                `json:"ExecutionStoppedAt,omitempty"`
+	DNS                *DNSResponse              `json:"DNS,omitempty"`
 }
 
 // ContainerResponse defines the schema for the container response
 // JSON object
 type ContainerResponse struct {
-	ID            string                      `json:"DockerId"`
-	Name          string                      `json:"Name"`
-	DockerName    string                      `json:"DockerName"`
-	Image         string                      `json:"Image"`
-	ImageID       string                      `json:"ImageID"`
-	Ports         []v1.PortResponse           `json:"Ports,omitempty"`
-	Labels        map[string]string           `json:"Labels,omitempty"`
-	DesiredStatus string                      `json:"DesiredStatus"`
-	KnownStatus   string                      `json:"KnownStatus"`
-	ExitCode      *int                        `json:"ExitCode,omitempty"`
-	Limits        LimitsResponse              `json:"Limits"`
-	CreatedAt     *time.Time                  `json:"CreatedAt,omitempty"`
-	StartedAt     *time.Time                  `json:"StartedAt,omitempty"`
-	FinishedAt    *time.Time                  `json:"FinishedAt,omitempty"`
-	Type          string                      `json:"Type"`
-	Networks      []containermetadata.Network `json:"Networks,omitempty"`
-	Health        *apicontainer.HealthStatus  `json:"Health,omitempty"`
-	Volumes       []v1.VolumeResponse         `json:"Volumes,omitempty"`
+	ID             string                     `json:"DockerId"`
+	Name           string                     `json:"Name"`
+	DockerName     string                     `json:"DockerName"`
+	Image          string                     `json:"Image"`
+	ImageID        string                     `json:"ImageID"`
+	ImageDigest    string                     `json:"ImageDigest,omitempty"`
+	Ports          []v1.PortResponse          `json:"Ports,omitempty"`
+	Labels         map[string]string          `json:"Labels,omitempty"`
+	DesiredStatus  string                     `json:"DesiredStatus"`
+	KnownStatus    string                     `json:"KnownStatus"`
+	ExitCode       *int                       `json:"ExitCode,omitempty"`
+	Limits         LimitsResponse             `json:"Limits"`
+	CreatedAt      *time.Time                 `json:"CreatedAt,omitempty"`
+	StartedAt      *time.Time                 `json:"StartedAt,omitempty"`
+	FinishedAt     *time.Time                 `json:"FinishedAt,omitempty"`
+	Type           string                     `json:"Type"`
+	Networks       []NetworkResponse          `json:"Networks,omitempty"`
+	Health         *apicontainer.HealthStatus `json:"Health,omitempty"`
+	Volumes        []v1.VolumeResponse        `json:"Volumes,omitempty"`
+	RestartCount   int                        `json:"RestartCount,omitempty"`
+	ExitHistory    []v1.ContainerExitResponse `json:"ExitHistory,omitempty"`
+	CachedImage    bool                       `json:"CachedImage,omitempty"`
+	PullStartedAt  *time.Time                 `json:"PullStartedAt,omitempty"`
+	PullStoppedAt  *time.Time                 `json:"PullStoppedAt,omitempty"`
+	PullDuration   *int64                     `json:"PullDurationMs,omitempty"`
+	Ulimits        []v1.UlimitResponse        `json:"Ulimits,omitempty"`
+	SystemControls map[string]string          `json:"SystemControls,omitempty"`
 }
 
 // LimitsResponse defines the schema for task/cpu limits response
@@ -70,6 +83,36 @@ type ContainerResponse struct {
 type LimitsResponse struct {
 	CPU    *float64 `json:"CPU,omitempty"`
 	Memory *int64   `json:"Memory,omitempty"`
+	// EgressBandwidth is the limit, in Mbps, on the rate of the traffic sent
+	// by awsvpc tasks
+	EgressBandwidth *int64 `json:"EgressBandwidth,omitempty"`
+}
+
+// NetworkResponse defines the schema for the network response JSON object of
+// the containers of awsvpc tasks, which is the configuration of the ENI of the
+// task. The subnet and the VPC of the ENI can be looked up in the instance
+// metadata by its MAC address
+type NetworkResponse struct {
+	containermetadata.Network
+	MACAddress               string `json:"MACAddress,omitempty"`
+	PrivateDNSName           string `json:"PrivateDNSName,omitempty"`
+	SubnetGatewayIPv4Address string `json:"SubnetGatewayIPv4Address,omitempty"`
+	SubnetGatewayIPv6Address string `json:"SubnetGatewayIPv6Address,omitempty"`
+}
+
+// EphemeralStorageResponse defines the schema for the task ephemeral storage
+// response JSON object. Both the limit and the usage are in MiB
+type EphemeralStorageResponse struct {
+	Limit int64 `json:"Limit"`
+	Usage int64 `json:"Usage"`
+}
+
+// DNSResponse defines the schema for the DNS configuration of the network
+// namespace of awsvpc tasks, shared by their containers
+type DNSResponse struct {
+	Servers       []string `json:"Servers,omitempty"`
+	SearchDomains []string `json:"SearchDomains,omitempty"`
+	ExtraHosts    []string `json:"ExtraHosts,omitempty"`
 }
 
 // NewTaskResponse creates a new response object for the task
@@ -92,7 +135,8 @@ func NewTaskResponse(taskARN string,
 
 	taskCPU := task.CPU
 	taskMemory := task.Memory
-	if taskCPU != 0 || taskMemory != 0 {
+	taskEgressBandwidth := task.EgressBandwidthLimit
+	if taskCPU != 0 || taskMemory != 0 || taskEgressBandwidth != 0 {
 		taskLimits := &LimitsResponse{}
 		if taskCPU != 0 {
 			taskLimits.CPU = &taskCPU
@@ -100,9 +144,19 @@ func NewTaskResponse(taskARN string,
 		if taskMemory != 0 {
 			taskLimits.Memory = &taskMemory
 		}
+		if taskEgressBandwidth != 0 {
+			taskLimits.EgressBandwidth = &taskEgressBandwidth
+		}
 		resp.Limits = taskLimits
 	}
 
+	if task.EphemeralStorageLimit != 0 {
+		resp.EphemeralStorage = &EphemeralStorageResponse{
+			Limit: task.EphemeralStorageLimit,
+			Usage: task.GetEphemeralStorageUsage() / bytesPerMiB,
+		}
+	}
+
 	if timestamp := task.GetPullStartedAt(); !timestamp.IsZero() {
 		resp.PullStartedAt = aws.Time(timestamp.UTC())
 	}
@@ -112,6 +166,14 @@ func NewTaskResponse(taskARN string,
 	if timestamp := task.GetExecutionStoppedAt(); !timestamp.IsZero() {
 		resp.ExecutionStoppedAt = aws.Time(timestamp.UTC())
 	}
+	if dnsConfig := task.GetAWSVPCDNSConfig(); dnsConfig != nil &&
+		len(dnsConfig.DNSServers)+len(dnsConfig.DNSSearchDomains)+len(dnsConfig.ExtraHosts) > 0 {
+		resp.DNS = &DNSResponse{
+			Servers:       dnsConfig.DNSServers,
+			SearchDomains: dnsConfig.DNSSearchDomains,
+			ExtraHosts:    dnsConfig.ExtraHosts,
+		}
+	}
 	containerNameToDockerContainer, ok := state.ContainerMapByArn(task.Arn)
 	if !ok {
 		seelog.Warnf("V2 task response: unable to get container name mapping for task '%s'",
@@ -156,15 +218,18 @@ func newContainerResponse(dockerContainer *apicontainer.DockerContainer,
 		DockerName:    dockerContainer.DockerName,
 		Image:         container.Image,
 		ImageID:       container.ImageID,
+		ImageDigest:   container.GetImageDigest(),
 		DesiredStatus: container.GetDesiredStatus().String(),
 		KnownStatus:   container.GetKnownStatus().String(),
 		Limits: LimitsResponse{
 			CPU:    aws.Float64(float64(container.CPU)),
 			Memory: aws.Int64(int64(container.Memory)),
 		},
-		Type:     container.Type.String(),
-		ExitCode: container.GetKnownExitCode(),
-		Labels:   container.GetLabels(),
+		Type:         container.Type.String(),
+		ExitCode:     container.GetKnownExitCode(),
+		Labels:       container.GetLabels(),
+		RestartCount: container.GetRestartCount(),
+		CachedImage:  container.GetImageFromCache(),
 	}
 
 	// Write the container health status inside the container
@@ -185,6 +250,14 @@ func newContainerResponse(dockerContainer *apicontainer.DockerContainer,
 		finishedAt = finishedAt.UTC()
 		resp.FinishedAt = &finishedAt
 	}
+	// The pull duration of containers using the cached image is zero
+	if pullDuration, ok := container.GetPullDuration(); ok {
+		pullStartedAt := container.GetPullStartedAt().UTC()
+		pullStoppedAt := container.GetPullStoppedAt().UTC()
+		resp.PullStartedAt = &pullStartedAt
+		resp.PullStoppedAt = &pullStoppedAt
+		resp.PullDuration = aws.Int64(int64(pullDuration / time.Millisecond))
+	}
 
 	for _, binding := range container.Ports {
 		port := v1.PortResponse{
@@ -200,15 +273,24 @@ func newContainerResponse(dockerContainer *apicontainer.DockerContainer,
 		resp.Ports = append(resp.Ports, port)
 	}
 	if eni != nil {
-		resp.Networks = []containermetadata.Network{
+		resp.Networks = []NetworkResponse{
 			{
-				NetworkMode:   utils.NetworkModeAWSVPC,
-				IPv4Addresses: eni.GetIPV4Addresses(),
-				IPv6Addresses: eni.GetIPV6Addresses(),
+				Network: containermetadata.Network{
+					NetworkMode:   utils.NetworkModeAWSVPC,
+					IPv4Addresses: eni.GetIPV4Addresses(),
+					IPv6Addresses: eni.GetIPV6Addresses(),
+				},
+				MACAddress:               eni.MacAddress,
+				PrivateDNSName:           eni.GetHostname(),
+				SubnetGatewayIPv4Address: eni.GetSubnetGatewayIPV4Address(),
+				SubnetGatewayIPv6Address: eni.GetSubnetGatewayIPV6Address(),
 			},
 		}
 	}
 
 	resp.Volumes = v1.NewVolumesResponse(dockerContainer)
+	resp.ExitHistory = v1.NewExitHistoryResponse(container)
+	resp.Ulimits = v1.NewUlimitsResponse(container)
+	resp.SystemControls = container.SystemControls
 	return resp
 }
@@ -18,6 +18,8 @@ package v2
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -27,10 +29,12 @@ import (
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/v1"
 	"github.com/aws/aws-sdk-go/aws"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -121,6 +125,100 @@ func TestTaskResponse(t *testing.T) {
 	_, err = json.Marshal(taskResponse)
 	assert.NoError(t, err)
 	assert.Equal(t, created.UTC().String(), taskResponse.Containers[0].CreatedAt.String())
+	assert.Nil(t, taskResponse.EphemeralStorage)
+}
+
+func TestTaskResponseDNS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	task := &apitask.Task{
+		Arn:                 taskARN,
+		Family:              family,
+		Version:             version,
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+		ENI: &apieni.ENI{
+			IPV4Addresses: []*apieni.ENIIPV4Address{
+				{
+					Primary: true,
+					Address: eniIPv4Address,
+				},
+			},
+			PrivateDNSName:       "eni.ip.region.compute.internal",
+			DomainNameSearchList: []string{"us-west-2.compute.internal"},
+		},
+		Containers: []*apicontainer.Container{
+			{
+				Name: containerName,
+				DockerConfig: apicontainer.DockerConfig{
+					HostConfig: aws.String(`{"Dns":["10.0.0.53"]}`),
+				},
+			},
+		},
+	}
+	gomock.InOrder(
+		state.EXPECT().TaskByArn(taskARN).Return(task, true),
+		state.EXPECT().ContainerMapByArn(taskARN).Return(map[string]*apicontainer.DockerContainer{}, true),
+	)
+
+	taskResponse, err := NewTaskResponse(taskARN, state, cluster)
+	require.NoError(t, err)
+	require.NotNil(t, taskResponse.DNS)
+	assert.Equal(t, []string{"10.0.0.53"}, taskResponse.DNS.Servers)
+	assert.Equal(t, []string{"us-west-2.compute.internal"}, taskResponse.DNS.SearchDomains)
+	assert.Equal(t, []string{"eni.ip.region.compute.internal:" + eniIPv4Address}, taskResponse.DNS.ExtraHosts)
+}
+
+func TestTaskResponseEphemeralStorage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	task := &apitask.Task{
+		Arn:                   taskARN,
+		Family:                family,
+		Version:               version,
+		DesiredStatusUnsafe:   apitaskstatus.TaskRunning,
+		KnownStatusUnsafe:     apitaskstatus.TaskRunning,
+		EphemeralStorageLimit: 1024,
+	}
+	task.SetEphemeralStorageUsage(300 * 1024 * 1024)
+	gomock.InOrder(
+		state.EXPECT().TaskByArn(taskARN).Return(task, true),
+		state.EXPECT().ContainerMapByArn(taskARN).Return(map[string]*apicontainer.DockerContainer{}, true),
+	)
+
+	taskResponse, err := NewTaskResponse(taskARN, state, cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, &EphemeralStorageResponse{Limit: 1024, Usage: 300}, taskResponse.EphemeralStorage)
+}
+
+func TestTaskResponseEgressBandwidthLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	task := &apitask.Task{
+		Arn:                  taskARN,
+		Family:               family,
+		Version:              version,
+		DesiredStatusUnsafe:  apitaskstatus.TaskRunning,
+		KnownStatusUnsafe:    apitaskstatus.TaskRunning,
+		EgressBandwidthLimit: 100,
+	}
+	gomock.InOrder(
+		state.EXPECT().TaskByArn(taskARN).Return(task, true),
+		state.EXPECT().ContainerMapByArn(taskARN).Return(map[string]*apicontainer.DockerContainer{}, true),
+	)
+
+	taskResponse, err := NewTaskResponse(taskARN, state, cluster)
+	assert.NoError(t, err)
+	require.NotNil(t, taskResponse.Limits)
+	assert.Equal(t, aws.Int64(100), taskResponse.Limits.EgressBandwidth)
+	assert.Nil(t, taskResponse.Limits.CPU)
+	assert.Nil(t, taskResponse.Limits.Memory)
 }
 
 func TestContainerResponse(t *testing.T) {
@@ -207,6 +305,38 @@ func TestContainerResponse(t *testing.T) {
 	}
 }
 
+func TestContainerResponseExitHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	exitCode := 1
+	container := &apicontainer.Container{
+		Name:               containerName,
+		RestartCountUnsafe: 1,
+	}
+	container.RecordExit(apicontainer.ContainerExit{
+		Time:     time.Unix(1500000000, 0),
+		ExitCode: &exitCode,
+	})
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerID:   containerID,
+		DockerName: containerName,
+		Container:  container,
+	}
+	gomock.InOrder(
+		state.EXPECT().ContainerByID(containerID).Return(dockerContainer, true),
+		state.EXPECT().TaskByID(containerID).Return(&apitask.Task{}, true),
+	)
+
+	containerResponse, err := NewContainerResponse(containerID, state)
+	assert.NoError(t, err)
+	assert.Equal(t, []v1.ContainerExitResponse{
+		{Time: time.Unix(1500000000, 0).UTC(), ExitCode: &exitCode},
+	}, containerResponse.ExitHistory)
+	assert.Equal(t, &exitCode, containerResponse.ExitCode)
+}
+
 func TestTaskResponseMarshal(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -344,6 +474,21 @@ func TestContainerResponseMarshal(t *testing.T) {
 			"statusSince": timeRFC3339.Format(time.RFC3339),
 			"status":      "HEALTHY",
 		},
+		"RestartCount":   float64(2),
+		"CachedImage":    true,
+		"PullStartedAt":  timeRFC3339.Format(time.RFC3339),
+		"PullStoppedAt":  timeRFC3339.Format(time.RFC3339),
+		"PullDurationMs": float64(0),
+		"Ulimits": []interface{}{
+			map[string]interface{}{
+				"Name": "nproc",
+				"Soft": float64(512),
+				"Hard": float64(1024),
+			},
+		},
+		"SystemControls": map[string]interface{}{
+			"net.ipv4.ip_local_port_range": "1024 65000",
+		},
 	}
 
 	ctrl := gomock.NewController(t)
@@ -370,6 +515,12 @@ func TestContainerResponseMarshal(t *testing.T) {
 				Protocol:      apicontainer.TransportProtocolTCP,
 			},
 		},
+		RestartCountUnsafe:   2,
+		ImageFromCacheUnsafe: true,
+		PullStartedAtUnsafe:  timeRFC3339,
+		PullStoppedAtUnsafe:  timeRFC3339,
+		Ulimits:              []apicontainer.Ulimit{{Name: "nproc", Soft: 512, Hard: 1024}},
+		SystemControls:       map[string]string{"net.ipv4.ip_local_port_range": "1024 65000"},
 	}
 
 	container.SetCreatedAt(timeRFC3339)
@@ -406,3 +557,106 @@ func TestContainerResponseMarshal(t *testing.T) {
 	json.Unmarshal(containerResponseJSON, &containerResponseMap)
 	assert.Equal(t, expectedContainerResponseMap, containerResponseMap)
 }
+
+// TestTaskResponseGolden tests that the task responses of bridge, host and
+// awsvpc tasks are byte for byte the golden files in testdata, which existing
+// consumers of the responses rely on
+func TestTaskResponseGolden(t *testing.T) {
+	timestamp, _ := time.Parse(time.RFC3339, "2018-11-12T11:45:26Z")
+	testCases := []struct {
+		name       string
+		hostConfig string
+		hostPort   uint16
+		eni        *apieni.ENI
+	}{
+		{
+			name:     "bridge",
+			hostPort: 32768,
+		},
+		{
+			name:       "host",
+			hostConfig: `{"NetworkMode":"host"}`,
+			hostPort:   80,
+		},
+		{
+			name: "awsvpc",
+			eni: &apieni.ENI{
+				ID: "eni-1",
+				IPV4Addresses: []*apieni.ENIIPV4Address{
+					{
+						Primary: true,
+						Address: eniIPv4Address,
+					},
+				},
+				MacAddress:               "06:96:9a:ce:a6:ce",
+				PrivateDNSName:           "ip-10-0-0-2.ec2.internal",
+				SubnetGatewayIPV4Address: "10.0.0.1/24",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+			task := &apitask.Task{
+				Arn:                      taskARN,
+				Family:                   family,
+				Version:                  version,
+				DesiredStatusUnsafe:      apitaskstatus.TaskRunning,
+				KnownStatusUnsafe:        apitaskstatus.TaskRunning,
+				ENI:                      tc.eni,
+				CPU:                      cpu,
+				Memory:                   memory,
+				PullStartedAtUnsafe:      timestamp,
+				PullStoppedAtUnsafe:      timestamp,
+				ExecutionStoppedAtUnsafe: timestamp,
+			}
+			container := &apicontainer.Container{
+				Name:                containerName,
+				Image:               imageName,
+				ImageID:             imageID,
+				ImageDigest:         "sha256:7d246653d0511db2a6b2e0436cfd0e52ac8c066000264b3ce63331ac66dca625",
+				DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+				KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+				CPU:                 cpu / 2,
+				Memory:              memory / 2,
+				Type:                apicontainer.ContainerNormal,
+				Ports: []apicontainer.PortBinding{
+					{
+						ContainerPort: 80,
+						HostPort:      tc.hostPort,
+						Protocol:      apicontainer.TransportProtocolTCP,
+					},
+				},
+			}
+			if tc.hostConfig != "" {
+				container.DockerConfig.HostConfig = aws.String(tc.hostConfig)
+			}
+			container.SetCreatedAt(timestamp)
+			container.SetStartedAt(timestamp)
+			containerNameToDockerContainer := map[string]*apicontainer.DockerContainer{
+				containerName: {
+					DockerID:   containerID,
+					DockerName: containerName,
+					Container:  container,
+				},
+			}
+			gomock.InOrder(
+				state.EXPECT().TaskByArn(taskARN).Return(task, true),
+				state.EXPECT().ContainerMapByArn(taskARN).Return(containerNameToDockerContainer, true),
+			)
+
+			taskResponse, err := NewTaskResponse(taskARN, state, cluster)
+			require.NoError(t, err)
+			taskResponseJSON, err := json.MarshalIndent(taskResponse, "", "  ")
+			require.NoError(t, err)
+
+			golden, err := ioutil.ReadFile(filepath.Join("testdata", tc.name+"_task_response.json"))
+			require.NoError(t, err)
+			assert.Equal(t, string(golden), string(taskResponseJSON)+"\n")
+		})
+	}
+}
@@ -21,10 +21,33 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ContainerStatsResponse is the raw docker stats of a container. They're the
+// last stats collected before the container stopped if Stopped is set
+type ContainerStatsResponse struct {
+	*docker.Stats
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// NewContainerStatsResponse returns a new container stats response object
+func NewContainerStatsResponse(taskARN string,
+	containerID string,
+	statsEngine stats.Engine) (*ContainerStatsResponse, error) {
+
+	dockerStats, stopped, err := statsEngine.ContainerDockerStats(taskARN, containerID)
+	if err != nil {
+		return nil, err
+	}
+	// No stats were collected yet
+	if dockerStats == nil && !stopped {
+		return nil, nil
+	}
+	return &ContainerStatsResponse{Stats: dockerStats, Stopped: stopped}, nil
+}
+
 // NewTaskStatsResponse returns a new task stats response object
 func NewTaskStatsResponse(taskARN string,
 	state dockerstate.TaskEngineState,
-	statsEngine stats.Engine) (map[string]*docker.Stats, error) {
+	statsEngine stats.Engine) (map[string]*ContainerStatsResponse, error) {
 
 	containerMap, ok := state.ContainerMapByArn(taskARN)
 	if !ok {
@@ -33,10 +56,10 @@ func NewTaskStatsResponse(taskARN string,
 			taskARN)
 	}
 
-	resp := make(map[string]*docker.Stats)
+	resp := make(map[string]*ContainerStatsResponse)
 	for _, dockerContainer := range containerMap {
 		containerID := dockerContainer.DockerID
-		dockerStats, err := statsEngine.ContainerDockerStats(taskARN, containerID)
+		containerStats, err := NewContainerStatsResponse(taskARN, containerID, statsEngine)
 		if err != nil {
 			seelog.Warnf("V2 task stats response: Unable to get stats for container '%s' for task '%s': %v",
 				containerID, taskARN, err)
@@ -44,7 +67,7 @@ func NewTaskStatsResponse(taskARN string,
 			continue
 		}
 
-		resp[containerID] = dockerStats
+		resp[containerID] = containerStats
 	}
 
 	return resp, nil
@@ -16,6 +16,7 @@
 package v2
 
 import (
+	"encoding/json"
 	"testing"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
@@ -41,7 +42,7 @@ func TestTaskStatsResponseSuccess(t *testing.T) {
 	}
 	gomock.InOrder(
 		state.EXPECT().ContainerMapByArn(taskARN).Return(containerMap, true),
-		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, false, nil),
 	)
 
 	resp, err := NewTaskStatsResponse(taskARN, state, statsEngine)
@@ -51,6 +52,34 @@ func TestTaskStatsResponseSuccess(t *testing.T) {
 	assert.Equal(t, dockerStats.NumProcs, containerStats.NumProcs)
 }
 
+func TestTaskStatsResponseStoppedContainer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	statsEngine := mock_stats.NewMockEngine(ctrl)
+
+	dockerStats := &docker.Stats{NumProcs: 2}
+	containerMap := map[string]*apicontainer.DockerContainer{
+		containerName: {
+			DockerID: containerID,
+		},
+	}
+	gomock.InOrder(
+		state.EXPECT().ContainerMapByArn(taskARN).Return(containerMap, true),
+		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, true, nil),
+	)
+
+	resp, err := NewTaskStatsResponse(taskARN, state, statsEngine)
+	assert.NoError(t, err)
+	assert.Equal(t, &ContainerStatsResponse{Stats: dockerStats, Stopped: true}, resp[containerID])
+
+	responseJSON, err := json.Marshal(resp[containerID])
+	assert.NoError(t, err)
+	assert.Contains(t, string(responseJSON), `"stopped":true`)
+	assert.Contains(t, string(responseJSON), `"num_procs":2`)
+}
+
 func TestTaskStatsResponseError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
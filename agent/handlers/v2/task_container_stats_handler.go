@@ -83,13 +83,14 @@ func WriteContainerStatsResponse(w http.ResponseWriter,
 	taskARN string,
 	containerID string,
 	statsEngine stats.Engine) {
-	dockerStats, err := statsEngine.ContainerDockerStats(taskARN, containerID)
+	containerStatsResponse, err := NewContainerStatsResponse(taskARN, containerID, statsEngine)
 	if err != nil {
+		seelog.Warnf("Unable to get container stats for container '%s': %v", containerID, err)
 		errResponseJSON, _ := json.Marshal("Unable to get container stats for: " + containerID)
 		utils.WriteJSONToResponse(w, http.StatusBadRequest, errResponseJSON, utils.RequestTypeContainerStats)
 		return
 	}
 
-	responseJSON, _ := json.Marshal(dockerStats)
+	responseJSON, _ := json.Marshal(containerStatsResponse)
 	utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeContainerStats)
 }
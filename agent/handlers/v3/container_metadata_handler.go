@@ -14,8 +14,6 @@
 package v3
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
@@ -32,9 +30,7 @@ func ContainerMetadataHandler(state dockerstate.TaskEngineState) func(http.Respo
 	return func(w http.ResponseWriter, r *http.Request) {
 		containerID, err := getContainerIDByRequest(r, state)
 		if err != nil {
-			responseJSON, _ := json.Marshal(
-				fmt.Sprintf("V3 container metadata handler: unable to get container ID from request: %s", err.Error()))
-			utils.WriteJSONToResponse(w, http.StatusBadRequest, responseJSON, utils.RequestTypeContainerMetadata)
+			writeUnknownV3EndpointIDResponse(w, "V3 container metadata handler", utils.RequestTypeContainerMetadata, err)
 			return
 		}
 
@@ -14,8 +14,6 @@
 package v3
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
@@ -33,17 +31,13 @@ func ContainerStatsHandler(state dockerstate.TaskEngineState, statsEngine stats.
 	return func(w http.ResponseWriter, r *http.Request) {
 		taskARN, err := getTaskARNByRequest(r, state)
 		if err != nil {
-			errResponseJSON, _ := json.Marshal(
-				fmt.Sprintf("V3 container stats handler: unable to get task arn from request: %s", err.Error()))
-			utils.WriteJSONToResponse(w, http.StatusBadRequest, errResponseJSON, utils.RequestTypeTaskStats)
+			writeUnknownV3EndpointIDResponse(w, "V3 container stats handler", utils.RequestTypeContainerStats, err)
 			return
 		}
 
 		containerID, err := getContainerIDByRequest(r, state)
 		if err != nil {
-			responseJSON, _ := json.Marshal(
-				fmt.Sprintf("V3 container stats handler: unable to get container ID from request: %s", err.Error()))
-			utils.WriteJSONToResponse(w, http.StatusBadRequest, responseJSON, utils.RequestTypeContainerStats)
+			writeUnknownV3EndpointIDResponse(w, "V3 container stats handler", utils.RequestTypeContainerStats, err)
 			return
 		}
 
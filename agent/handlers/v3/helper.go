@@ -14,10 +14,13 @@
 package v3
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/cihub/seelog"
 	"github.com/pkg/errors"
 )
 
@@ -50,3 +53,13 @@ func getContainerIDByRequest(r *http.Request, state dockerstate.TaskEngineState)
 
 	return dockerID, nil
 }
+
+// writeUnknownV3EndpointIDResponse writes the response for a request whose v3
+// endpoint ID doesn't identify a container. The ID is only logged, and the
+// response is the same for every unknown ID, so that requests can't tell
+// whether other IDs exist.
+func writeUnknownV3EndpointIDResponse(w http.ResponseWriter, handlerName string, requestType string, err error) {
+	seelog.Warnf("%s: %s", handlerName, err.Error())
+	responseJSON, _ := json.Marshal(fmt.Sprintf("%s: unknown v3 endpoint ID", handlerName))
+	utils.WriteJSONToResponse(w, http.StatusNotFound, responseJSON, requestType)
+}
@@ -14,8 +14,6 @@
 package v3
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
@@ -35,9 +33,7 @@ func TaskMetadataHandler(state dockerstate.TaskEngineState, cluster string) func
 	return func(w http.ResponseWriter, r *http.Request) {
 		taskARN, err := getTaskARNByRequest(r, state)
 		if err != nil {
-			responseJSON, _ := json.Marshal(
-				fmt.Sprintf("V3 task metadata handler: unable to get task arn from request: %s", err.Error()))
-			utils.WriteJSONToResponse(w, http.StatusBadRequest, responseJSON, utils.RequestTypeTaskMetadata)
+			writeUnknownV3EndpointIDResponse(w, "V3 task metadata handler", utils.RequestTypeTaskMetadata, err)
 			return
 		}
 
@@ -14,8 +14,6 @@
 package v3
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
@@ -33,9 +31,7 @@ func TaskStatsHandler(state dockerstate.TaskEngineState, statsEngine stats.Engin
 	return func(w http.ResponseWriter, r *http.Request) {
 		taskARN, err := getTaskARNByRequest(r, state)
 		if err != nil {
-			errResponseJSON, _ := json.Marshal(
-				fmt.Sprintf("V3 task stats handler: unable to get task arn from request: %s", err.Error()))
-			utils.WriteJSONToResponse(w, http.StatusBadRequest, errResponseJSON, utils.RequestTypeTaskStats)
+			writeUnknownV3EndpointIDResponse(w, "V3 task stats handler", utils.RequestTypeTaskStats, err)
 			return
 		}
 
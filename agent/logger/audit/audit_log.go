@@ -14,10 +14,9 @@
 package audit
 
 import (
-	"fmt"
-
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/logger/audit/request"
+	log "github.com/cihub/seelog"
 )
 
 type auditLog struct {
@@ -25,14 +24,16 @@ type auditLog struct {
 	cluster              string
 	logger               InfoLogger
 	cfg                  *config.Config
+	attributor           Attributor
 }
 
-func NewAuditLog(containerInstanceArn string, cfg *config.Config, logger InfoLogger) AuditLogger {
+func NewAuditLog(containerInstanceArn string, cfg *config.Config, logger InfoLogger, attributor Attributor) AuditLogger {
 	return &auditLog{
 		cluster:              cfg.Cluster,
 		containerInstanceArn: containerInstanceArn,
 		logger:               logger,
 		cfg:                  cfg,
+		attributor:           attributor,
 	}
 }
 
@@ -40,21 +41,17 @@ func NewAuditLog(containerInstanceArn string, cfg *config.Config, logger InfoLog
 // using the underlying logger (which implements the audit.InfoLogger interface).
 func (a *auditLog) Log(r request.LogRequest, httpResponseCode int, eventType string) {
 	if !a.cfg.CredentialsAuditLogDisabled {
-		auditLogEntry := constructAuditLogEntry(r, httpResponseCode, eventType, a.GetCluster(),
-			a.GetContainerInstanceArn())
+		auditLogEntry, err := newAuditLogEntry(r, httpResponseCode, eventType, a.GetCluster(),
+			a.GetContainerInstanceArn(), a.attributor).string()
+		if err != nil {
+			log.Errorf("Unable to write the audit log entry of the request from %s: %v", r.Request.RemoteAddr, err)
+			return
+		}
 
 		a.logger.Info(auditLogEntry)
 	}
 }
 
-func constructAuditLogEntry(r request.LogRequest, httpResponseCode int, eventType string,
-	cluster string, containerInstanceArn string) string {
-	commonAuditLogFields := constructCommonAuditLogEntryFields(r, httpResponseCode)
-	auditLogTypeFields := constructAuditLogEntryByType(eventType, cluster, containerInstanceArn)
-
-	return fmt.Sprintf("%s %s", commonAuditLogFields, auditLogTypeFields)
-}
-
 func (a *auditLog) GetCluster() string {
 	return a.cluster
 }
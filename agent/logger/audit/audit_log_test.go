@@ -16,12 +16,11 @@
 package audit
 
 import (
-	"fmt"
+	"encoding/json"
 	"net/http"
 	"net/url"
-	"strconv"
-	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
@@ -29,6 +28,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/logger/audit/request"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -39,13 +39,14 @@ const (
 	dummyURL                  = "http://foo.com" + dummyURLPath + "?id=foo"
 	dummyURLPath              = "/urlPath"
 	dummyURLV2                = "http://foo.com" + credentials.V2CredentialsPath + "/" + taskARN
+	dummyURLV3Path            = "/v3/endpoint-id/task"
+	dummyURLV3                = "http://foo.com" + dummyURLV3Path
 	dummyUserAgent            = "userAgent"
+	dummyContainerName        = "container"
+	dummyLatency              = 20 * time.Millisecond
 	dummyResponseCode         = 400
 	dummyRoleType             = "TaskExecution"
 	taskARN                   = "task-arn-1"
-
-	commonAuditLogEntryFieldCount = 6
-	getCredentialsEntryFieldCount = 4
 )
 
 func TestWritingToAuditLog(t *testing.T) {
@@ -68,7 +69,7 @@ func TestWritingToAuditLog(t *testing.T) {
 		CredentialsAuditLogFile: "foo.txt",
 	}
 
-	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger)
+	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger, nil)
 	assert.Equal(t, dummyCluster, auditLogger.GetCluster(), "Cluster is not initialized properly")
 	assert.Equal(t, dummyContainerInstanceArn, auditLogger.GetContainerInstanceArn(), "ContainerInstanceArn is not initialized properly")
 
@@ -99,7 +100,7 @@ func TestWritingToAuditLogV2(t *testing.T) {
 		CredentialsAuditLogFile: "foo.txt",
 	}
 
-	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger)
+	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger, nil)
 	assert.Equal(t, dummyCluster, auditLogger.GetCluster(), "Cluster is not initialized properly")
 	assert.Equal(t, dummyContainerInstanceArn, auditLogger.GetContainerInstanceArn(), "ContainerInstanceArn is not initialized properly")
 
@@ -130,12 +131,12 @@ func TestWritingErrorsToAuditLog(t *testing.T) {
 		CredentialsAuditLogFile: "foo.txt",
 	}
 
-	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger)
+	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger, nil)
 	assert.Equal(t, dummyCluster, auditLogger.GetCluster(), "Cluster is not initialized properly")
 	assert.Equal(t, dummyContainerInstanceArn, auditLogger.GetContainerInstanceArn(), "ContainerInstanceArn is not initialized properly")
 
 	mockInfoLogger.EXPECT().Info(gomock.Any()).Do(func(logLine string) {
-		verifyAuditLogEntryResult(logLine, "-", dummyURLPath, t)
+		verifyAuditLogEntryResult(logLine, "", dummyURLPath, t)
 	})
 
 	auditLogger.Log(request.LogRequest{Request: req, ARN: ""}, dummyResponseCode, GetCredentialsEventType(dummyRoleType))
@@ -155,7 +156,7 @@ func TestWritingToAuditLogWhenDisabled(t *testing.T) {
 		CredentialsAuditLogDisabled: true,
 	}
 
-	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger)
+	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger, nil)
 	assert.Equal(t, dummyCluster, auditLogger.GetCluster(), "Cluster is not initialized properly")
 	assert.Equal(t, dummyContainerInstanceArn, auditLogger.GetContainerInstanceArn(), "ContainerInstanceArn is not initialized properly")
 
@@ -164,59 +165,129 @@ func TestWritingToAuditLogWhenDisabled(t *testing.T) {
 	auditLogger.Log(request.LogRequest{Request: req, ARN: taskARN}, dummyResponseCode, GetCredentialsEventType(dummyRoleType))
 }
 
-func TestConstructCommonAuditLogEntryFields(t *testing.T) {
-	req, _ := http.NewRequest("GET", "foo", nil)
+func TestWritingAttributedRequestToAuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockInfoLogger := mock_infologger.NewMockInfoLogger(ctrl)
+	mockAttributor := mock_infologger.NewMockAttributor(ctrl)
+
+	req, _ := http.NewRequest("GET", dummyURLV3, nil)
 	req.RemoteAddr = dummyRemoteAddress
-	parsedURL, err := url.Parse(dummyURL)
-	if err != nil {
-		t.Fatal("error parsing dummyUrl")
-	}
-	req.URL = parsedURL
 	req.Header.Set("User-Agent", dummyUserAgent)
+	req = request.WithStartTime(req, time.Now().Add(-dummyLatency))
 
-	result := constructCommonAuditLogEntryFields(request.LogRequest{Request: req, ARN: taskARN}, dummyResponseCode)
+	cfg := &config.Config{
+		Cluster:                 dummyCluster,
+		CredentialsAuditLogFile: "foo.txt",
+	}
 
-	verifyCommonAuditLogEntryFieldResult(result, taskARN, dummyURLPath, t)
+	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger, mockAttributor)
+
+	var logLine string
+	gomock.InOrder(
+		mockAttributor.EXPECT().Attribute(req).Return(taskARN, dummyContainerName),
+		mockInfoLogger.EXPECT().Info(gomock.Any()).Do(func(line string) {
+			logLine = line
+		}),
+	)
+
+	auditLogger.Log(request.LogRequest{Request: req}, http.StatusOK, MetadataEventType)
+
+	entry := parseAuditLogEntry(t, logLine)
+	assert.Equal(t, http.StatusOK, entry.ResponseCode)
+	assert.Equal(t, dummyRemoteAddress, entry.SrcAddr)
+	assert.Equal(t, dummyURLV3Path, entry.URL)
+	assert.Equal(t, "", entry.ARN)
+	assert.Equal(t, MetadataEventType, entry.EventType)
+	assert.Equal(t, taskARN, entry.TaskARN)
+	assert.Equal(t, dummyContainerName, entry.ContainerName)
+	assert.True(t, entry.LatencyMs >= float64(dummyLatency/time.Millisecond),
+		"latency should be measured from the start of the request")
 }
 
-func TestConstructAuditLogEntryByTypeGetCredentials(t *testing.T) {
-	result := constructAuditLogEntryByType(GetCredentialsEventType(dummyRoleType), dummyCluster,
-		dummyContainerInstanceArn)
-	verifyConstructAuditLogEntryGetCredentialsResult(result, t)
-}
+func TestWritingUnattributedRequestToAuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
 
-func verifyAuditLogEntryResult(logLine string, expectedTaskArn string, expectedURLPath string, t *testing.T) {
-	tokens := strings.Split(logLine, " ")
-	assert.Equal(t, commonAuditLogEntryFieldCount+getCredentialsEntryFieldCount, len(tokens), "Incorrect number of tokens in audit log entry")
-	verifyCommonAuditLogEntryFieldResult(strings.Join(tokens[:commonAuditLogEntryFieldCount], " "), expectedTaskArn, expectedURLPath, t)
-	verifyConstructAuditLogEntryGetCredentialsResult(strings.Join(tokens[commonAuditLogEntryFieldCount:], " "), t)
-}
+	mockInfoLogger := mock_infologger.NewMockInfoLogger(ctrl)
+	mockAttributor := mock_infologger.NewMockAttributor(ctrl)
+
+	req, _ := http.NewRequest("GET", dummyURL, nil)
+	req.RemoteAddr = dummyRemoteAddress
+
+	cfg := &config.Config{
+		Cluster:                 dummyCluster,
+		CredentialsAuditLogFile: "foo.txt",
+	}
+
+	auditLogger := NewAuditLog(dummyContainerInstanceArn, cfg, mockInfoLogger, mockAttributor)
+
+	var logLine string
+	mockAttributor.EXPECT().Attribute(req).Return("", "")
+	mockInfoLogger.EXPECT().Info(gomock.Any()).Do(func(line string) {
+		logLine = line
+	})
 
-func verifyCommonAuditLogEntryFieldResult(result string, expectedTaskArn string, expectedURLPath string, t *testing.T) {
-	tokens := strings.Split(result, " ")
-	assert.Equal(t, commonAuditLogEntryFieldCount, len(tokens), "Incorrect number of tokens in common audit log entry")
+	auditLogger.Log(request.LogRequest{Request: req}, http.StatusNotFound, MetadataEventType)
 
-	respCode, _ := strconv.Atoi(tokens[1])
-	assert.Equal(t, dummyResponseCode, respCode, "response code does not match")
-	assert.Equal(t, dummyRemoteAddress, tokens[2], "remoted address does not match")
-	assert.Equal(t, fmt.Sprintf(`"%s"`, expectedURLPath), tokens[3], "URL path does not match")
-	assert.Equal(t, fmt.Sprintf(`"%s"`, dummyUserAgent), tokens[4], "User Agent does not match")
-	assert.Equal(t, expectedTaskArn, tokens[5], "ARN for credentials does not match")
+	entry := parseAuditLogEntry(t, logLine)
+	assert.Equal(t, dummyRemoteAddress, entry.SrcAddr, "the source address should be logged")
+	assert.Equal(t, "", entry.TaskARN)
+	assert.Equal(t, "", entry.ContainerName)
+	assert.Equal(t, float64(0), entry.LatencyMs)
 }
 
-func verifyConstructAuditLogEntryGetCredentialsResult(result string, t *testing.T) {
-	tokens := strings.Split(result, " ")
+// TestAuditLogEntryFormat locks the format of the audit log, which is parsed
+// by the tools of customers
+func TestAuditLogEntryFormat(t *testing.T) {
+	req, _ := http.NewRequest("GET", dummyURLV2, nil)
+	req.RemoteAddr = dummyRemoteAddress
+	req.Header.Set("User-Agent", dummyUserAgent)
 
-	assert.Equal(t, getCredentialsEntryFieldCount, len(tokens), "Incorrect number of tokens in GetCredentials audit log entry")
-	assert.Equal(t, GetCredentialsEventType(dummyRoleType), tokens[0], "event type does not match")
+	logLine, err := newAuditLogEntry(request.LogRequest{Request: req, ARN: taskARN}, dummyResponseCode,
+		GetCredentialsEventType(dummyRoleType), dummyCluster, dummyContainerInstanceArn, nil).string()
+	require.NoError(t, err)
+	assert.NotContains(t, logLine, "\n", "an entry should be a single line")
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(logLine), &fields))
+	eventTime, err := time.Parse(time.RFC3339, fields["eventTime"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), eventTime, time.Minute)
+	delete(fields, "eventTime")
+	assert.Equal(t, map[string]interface{}{
+		"responseCode":         float64(dummyResponseCode),
+		"srcAddr":              dummyRemoteAddress,
+		"url":                  credentials.V2CredentialsPath,
+		"userAgent":            dummyUserAgent,
+		"arn":                  taskARN,
+		"eventType":            GetCredentialsEventType(dummyRoleType),
+		"version":              float64(3),
+		"cluster":              dummyCluster,
+		"containerInstanceArn": dummyContainerInstanceArn,
+		"taskArn":              "",
+		"containerName":        "",
+		"latencyMs":            float64(0),
+	}, fields)
+}
 
-	auditLogVersion, _ := strconv.Atoi(tokens[1])
-	assert.Equal(t, getCredentialsAuditLogVersion, auditLogVersion, "version does not match")
-	assert.Equal(t, dummyCluster, tokens[2], "cluster does not match")
-	assert.Equal(t, dummyContainerInstanceArn, tokens[3], "containerInstanceArn does not match")
+func parseAuditLogEntry(t *testing.T, logLine string) *auditLogEntry {
+	entry := &auditLogEntry{}
+	require.NoError(t, json.Unmarshal([]byte(logLine), entry), "audit log entry should be a JSON object")
+	return entry
 }
 
-func TestConstructAuditLogEntryByTypeUnknownType(t *testing.T) {
-	result := constructAuditLogEntryByType("unknownEvent", dummyCluster, dummyContainerInstanceArn)
-	assert.Equal(t, "", result, "unknown event type should not return an entry")
+func verifyAuditLogEntryResult(logLine string, expectedTaskArn string, expectedURLPath string, t *testing.T) {
+	entry := parseAuditLogEntry(t, logLine)
+
+	assert.Equal(t, dummyResponseCode, entry.ResponseCode, "response code does not match")
+	assert.Equal(t, dummyRemoteAddress, entry.SrcAddr, "remoted address does not match")
+	assert.Equal(t, expectedURLPath, entry.URL, "URL path does not match")
+	assert.Equal(t, dummyUserAgent, entry.UserAgent, "User Agent does not match")
+	assert.Equal(t, expectedTaskArn, entry.ARN, "ARN for credentials does not match")
+	assert.Equal(t, GetCredentialsEventType(dummyRoleType), entry.EventType, "event type does not match")
+	assert.Equal(t, auditLogVersion, entry.Version, "version does not match")
+	assert.Equal(t, dummyCluster, entry.Cluster, "cluster does not match")
+	assert.Equal(t, dummyContainerInstanceArn, entry.ContainerInstanceArn, "containerInstanceArn does not match")
 }
@@ -14,13 +14,12 @@
 package audit
 
 import (
-	"fmt"
+	"encoding/json"
 	"strings"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/logger/audit/request"
-	log "github.com/cihub/seelog"
 )
 
 const (
@@ -28,7 +27,11 @@ const (
 	getCredentialsTaskExecutionEventType   = "GetCredentialsExecutionRole"
 	getCredentialsInvalidRoleTypeEventType = "GetCredentialsInvalidRoleType"
 
-	// getCredentialsAuditLogVersion is the version of the audit log
+	// MetadataEventType is the type for a request to the task metadata and
+	// stats endpoints
+	MetadataEventType = "GetMetadata"
+
+	// auditLogVersion is the version of the audit log
 	// Version '1', the fields are:
 	// 1. event time
 	// 2. response code
@@ -44,16 +47,32 @@ const (
 	// Version '2', following fields were modified
 	// 7. event type ('GetCredentials, GetCredentialsExecutionRole')
 
-	getCredentialsAuditLogVersion = 2
+	// Version '3', the entry is a JSON object with the fields of version 2,
+	// requests to the metadata endpoints are logged with the 'GetMetadata'
+	// event type, and the following fields were added
+	// 11. arn of the task the request is attributed to
+	// 12. name of the container the request is attributed to
+	// 13. latency of the request in milliseconds
+
+	auditLogVersion = 3
 )
 
-type commonAuditLogEntryFields struct {
-	eventTime    string
-	responseCode int
-	srcAddr      string
-	theURL       string
-	userAgent    string
-	arn          string
+// auditLogEntry is the JSON object written to the audit log for a request.
+// Fields that are unknown are empty
+type auditLogEntry struct {
+	EventTime            string  `json:"eventTime"`
+	ResponseCode         int     `json:"responseCode"`
+	SrcAddr              string  `json:"srcAddr"`
+	URL                  string  `json:"url"`
+	UserAgent            string  `json:"userAgent"`
+	ARN                  string  `json:"arn"`
+	EventType            string  `json:"eventType"`
+	Version              int     `json:"version"`
+	Cluster              string  `json:"cluster"`
+	ContainerInstanceArn string  `json:"containerInstanceArn"`
+	TaskARN              string  `json:"taskArn"`
+	ContainerName        string  `json:"containerName"`
+	LatencyMs            float64 `json:"latencyMs"`
 }
 
 // GetCredentialsEventType is the type for a GetCredentials request
@@ -68,66 +87,39 @@ func GetCredentialsEventType(roleType string) string {
 	}
 }
 
-func (c *commonAuditLogEntryFields) string() string {
-	return fmt.Sprintf("%s %d %s %s %s %s", c.eventTime, c.responseCode, c.srcAddr, c.theURL, c.userAgent, c.arn)
-}
-
-type getCredentialsAuditLogEntryFields struct {
-	eventType            string
-	version              int
-	cluster              string
-	containerInstanceArn string
-}
-
-func (g *getCredentialsAuditLogEntryFields) string() string {
-	return fmt.Sprintf("%s %d %s %s", g.eventType, g.version, g.cluster, g.containerInstanceArn)
+func (entry *auditLogEntry) string() (string, error) {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	return string(entryJSON), nil
 }
 
-func constructCommonAuditLogEntryFields(r request.LogRequest, httpResponseCode int) string {
+func newAuditLogEntry(r request.LogRequest, httpResponseCode int, eventType string,
+	cluster string, containerInstanceArn string, attributor Attributor) *auditLogEntry {
 	httpRequest := r.Request
 	url := httpRequest.URL.Path
 	// V2CredentialsPath contains the credentials ID, which should not be logged
 	if strings.HasPrefix(url, credentials.V2CredentialsPath+"/") {
 		url = credentials.V2CredentialsPath
 	}
-	fields := &commonAuditLogEntryFields{
-		eventTime:    time.Now().UTC().Format(time.RFC3339),
-		responseCode: httpResponseCode,
-		srcAddr:      populateField(httpRequest.RemoteAddr),
-		theURL:       populateField(fmt.Sprintf(`"%s"`, url)),
-		userAgent:    populateField(fmt.Sprintf(`"%s"`, httpRequest.UserAgent())),
-		arn:          populateField(r.ARN),
+	entry := &auditLogEntry{
+		EventTime:            time.Now().UTC().Format(time.RFC3339),
+		ResponseCode:         httpResponseCode,
+		SrcAddr:              httpRequest.RemoteAddr,
+		URL:                  url,
+		UserAgent:            httpRequest.UserAgent(),
+		ARN:                  r.ARN,
+		EventType:            eventType,
+		Version:              auditLogVersion,
+		Cluster:              cluster,
+		ContainerInstanceArn: containerInstanceArn,
 	}
-	return fields.string()
-}
-
-func constructAuditLogEntryByType(eventType string, cluster string, containerInstanceArn string) string {
-	switch eventType {
-	case getCredentialsEventType:
-		fields := &getCredentialsAuditLogEntryFields{
-			eventType:            eventType,
-			version:              getCredentialsAuditLogVersion,
-			cluster:              populateField(cluster),
-			containerInstanceArn: populateField(containerInstanceArn),
-		}
-		return fields.string()
-	case getCredentialsTaskExecutionEventType:
-		fields := &getCredentialsAuditLogEntryFields{
-			eventType:            eventType,
-			version:              getCredentialsAuditLogVersion,
-			cluster:              populateField(cluster),
-			containerInstanceArn: populateField(containerInstanceArn),
-		}
-		return fields.string()
-	default:
-		log.Warn(fmt.Sprintf("Unknown eventType: %s", eventType))
-		return ""
+	if attributor != nil {
+		entry.TaskARN, entry.ContainerName = attributor.Attribute(httpRequest)
 	}
-}
-
-func populateField(logField string) string {
-	if logField == "" {
-		logField = "-"
+	if start, ok := request.StartTime(httpRequest); ok {
+		entry.LatencyMs = float64(time.Since(start)) / float64(time.Millisecond)
 	}
-	return logField
+	return entry
 }
@@ -13,7 +13,11 @@
 
 package audit
 
-import "github.com/aws/amazon-ecs-agent/agent/logger/audit/request"
+import (
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/logger/audit/request"
+)
 
 type AuditLogger interface {
 	Log(r request.LogRequest, httpResponseCode int, eventType string)
@@ -24,3 +28,9 @@ type AuditLogger interface {
 type InfoLogger interface {
 	Info(i ...interface{})
 }
+
+// Attributor resolves the task and the container that made a request, which
+// are empty when the request can't be attributed to them
+type Attributor interface {
+	Attribute(r *http.Request) (taskARN string, containerName string)
+}
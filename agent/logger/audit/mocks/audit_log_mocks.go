@@ -12,12 +12,13 @@
 // permissions and limitations under the License.
 
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/aws/amazon-ecs-agent/agent/logger/audit (interfaces: AuditLogger,InfoLogger)
+// Source: github.com/aws/amazon-ecs-agent/agent/logger/audit (interfaces: AuditLogger,InfoLogger,Attributor)
 
 // Package mock_audit is a generated GoMock package.
 package mock_audit
 
 import (
+	http "net/http"
 	reflect "reflect"
 
 	request "github.com/aws/amazon-ecs-agent/agent/logger/audit/request"
@@ -117,3 +118,39 @@ func (m *MockInfoLogger) Info(arg0 ...interface{}) {
 func (mr *MockInfoLoggerMockRecorder) Info(arg0 ...interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Info", reflect.TypeOf((*MockInfoLogger)(nil).Info), arg0...)
 }
+
+// MockAttributor is a mock of Attributor interface
+type MockAttributor struct {
+	ctrl     *gomock.Controller
+	recorder *MockAttributorMockRecorder
+}
+
+// MockAttributorMockRecorder is the mock recorder for MockAttributor
+type MockAttributorMockRecorder struct {
+	mock *MockAttributor
+}
+
+// NewMockAttributor creates a new mock instance
+func NewMockAttributor(ctrl *gomock.Controller) *MockAttributor {
+	mock := &MockAttributor{ctrl: ctrl}
+	mock.recorder = &MockAttributorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAttributor) EXPECT() *MockAttributorMockRecorder {
+	return m.recorder
+}
+
+// Attribute mocks base method
+func (m *MockAttributor) Attribute(arg0 *http.Request) (string, string) {
+	ret := m.ctrl.Call(m, "Attribute", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// Attribute indicates an expected call of Attribute
+func (mr *MockAttributorMockRecorder) Attribute(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Attribute", reflect.TypeOf((*MockAttributor)(nil).Attribute), arg0)
+}
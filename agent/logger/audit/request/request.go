@@ -13,9 +13,27 @@
 
 package request
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"time"
+)
 
 type LogRequest struct {
 	Request *http.Request
 	ARN     string
 }
+
+type startTimeKey struct{}
+
+// WithStartTime returns a copy of the request recording when it was received,
+// from which the audit log computes the latency of the request
+func WithStartTime(r *http.Request, start time.Time) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), startTimeKey{}, start))
+}
+
+// StartTime returns when the request was received, if it was recorded
+func StartTime(r *http.Request) (time.Time, bool) {
+	start, ok := r.Context().Value(startTimeKey{}).(time.Time)
+	return start, ok
+}
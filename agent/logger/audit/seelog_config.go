@@ -13,7 +13,18 @@
 
 package audit
 
-import "github.com/aws/amazon-ecs-agent/agent/config"
+import (
+	"strconv"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+const (
+	// auditLogMaxSize is the size in bytes past which the audit log is rolled
+	auditLogMaxSize = 10 * 1024 * 1024
+	// auditLogMaxRolls is the number of rolled audit logs that are kept
+	auditLogMaxRolls = 24
+)
 
 func AuditLoggerConfig(cfg *config.Config) string {
 	config := `
@@ -21,8 +32,8 @@ func AuditLoggerConfig(cfg *config.Config) string {
 		<outputs formatid="main">
 			<console />`
 	if cfg.CredentialsAuditLogFile != "" {
-		config += `<rollingfile filename="` + cfg.CredentialsAuditLogFile + `" type="date"
-			 datepattern="2006-01-02-15" archivetype="none" maxrolls="24" />`
+		config += `<rollingfile filename="` + cfg.CredentialsAuditLogFile + `" type="size"
+			 maxsize="` + strconv.Itoa(auditLogMaxSize) + `" archivetype="none" maxrolls="` + strconv.Itoa(auditLogMaxRolls) + `" />`
 	}
 	config += `
 		</outputs>
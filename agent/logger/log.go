@@ -14,6 +14,7 @@
 package logger
 
 import (
+	"encoding/json"
 	"os"
 	"strings"
 	"sync"
@@ -22,14 +23,21 @@ import (
 )
 
 const (
-	LOGLEVEL_ENV_VAR = "ECS_LOGLEVEL"
-	LOGFILE_ENV_VAR  = "ECS_LOGFILE"
+	LOGLEVEL_ENV_VAR          = "ECS_LOGLEVEL"
+	LOGFILE_ENV_VAR           = "ECS_LOGFILE"
+	LOG_OUTPUT_FORMAT_ENV_VAR = "ECS_LOG_OUTPUT_FORMAT"
 
 	DEFAULT_LOGLEVEL = "info"
+
+	// logFmt is the default, human readable, log output format
+	logFmt = "logfmt"
+	// jsonFmt is the log output format where each log line is a JSON object
+	jsonFmt = "json"
 )
 
 var logfile string
 var level string
+var outputFormat string
 var levelLock sync.RWMutex
 var levels map[string]string
 var logger OldLogger
@@ -58,6 +66,13 @@ func initLogger() {
 	envLevel := os.Getenv(LOGLEVEL_ENV_VAR)
 
 	logfile = os.Getenv(LOGFILE_ENV_VAR)
+	outputFormat = logFmt
+	if strings.ToLower(os.Getenv(LOG_OUTPUT_FORMAT_ENV_VAR)) == jsonFmt {
+		outputFormat = jsonFmt
+	}
+	if err := log.RegisterCustomFormatter("EcsEscapedMsg", escapedMsgFormatter); err != nil {
+		log.Error(err)
+	}
 	SetLevel(envLevel)
 	registerPlatformLogger()
 	reloadConfig()
@@ -84,6 +99,23 @@ func SetLevel(logLevel string) {
 	}
 }
 
+// IsJSONFormat returns true if each log line is written as a JSON object
+func IsJSONFormat() bool {
+	return outputFormat == jsonFmt
+}
+
+// escapedMsgFormatter formats the log message as a JSON string, so that it can
+// be embedded in the JSON log lines
+func escapedMsgFormatter(params string) log.FormatterFunc {
+	return func(message string, level log.LogLevel, context log.LogContextInterface) interface{} {
+		escaped, err := json.Marshal(message)
+		if err != nil {
+			return `""`
+		}
+		return string(escaped)
+	}
+}
+
 // GetLevel gets the log level
 func GetLevel() string {
 	levelLock.RLock()
@@ -0,0 +1,47 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	log "github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapedMsgFormatter(t *testing.T) {
+	message := `TaskHandler: Sending task change: {"taskArn": "t1"}` + "\n"
+	formatted := escapedMsgFormatter("")(message, log.InfoLvl, nil)
+
+	var unescaped string
+	assert.NoError(t, json.Unmarshal([]byte(formatted.(string)), &unescaped))
+	assert.Equal(t, message, unescaped)
+}
+
+func TestMainLogFormat(t *testing.T) {
+	defer func(format string) { outputFormat = format }(outputFormat)
+
+	outputFormat = logFmt
+	assert.False(t, IsJSONFormat())
+	assert.Equal(t, "%UTCDate(2006-01-02T15:04:05Z07:00) [%LEVEL] %Msg%n", mainLogFormat())
+
+	outputFormat = jsonFmt
+	assert.True(t, IsJSONFormat())
+	assert.True(t, strings.HasPrefix(mainLogFormat(), "{"))
+	assert.Contains(t, mainLogFormat(), "%EcsEscapedMsg")
+}
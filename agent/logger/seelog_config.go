@@ -26,10 +26,19 @@ func loggerConfig() string {
 	config += `
 		</outputs>
 		<formats>
-			<format id="main" format="%UTCDate(2006-01-02T15:04:05Z07:00) [%LEVEL] %Msg%n" />
+			<format id="main" format="` + mainLogFormat() + `" />
 			<format id="windows" format="%Msg" />
 		</formats>
 	</seelog>
 `
 	return config
 }
+
+// mainLogFormat returns the format of the log lines written to the console
+// and the log file
+func mainLogFormat() string {
+	if IsJSONFormat() {
+		return `{&quot;level&quot;: &quot;%LEVEL&quot;, &quot;time&quot;: &quot;%UTCDate(2006-01-02T15:04:05Z07:00)&quot;, &quot;msg&quot;: %EcsEscapedMsg}%n`
+	}
+	return `%UTCDate(2006-01-02T15:04:05Z07:00) [%LEVEL] %Msg%n`
+}
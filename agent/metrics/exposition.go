@@ -0,0 +1,132 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics exports metrics of the agent in the Prometheus text
+// exposition format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContentType is the content type of the text exposition format
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// CounterType is the type of metrics that only increase
+	CounterType = "counter"
+	// GaugeType is the type of metrics that can go up and down
+	GaugeType = "gauge"
+	// SummaryType is the type of metrics made of the sum and count of
+	// observations
+	SummaryType = "summary"
+)
+
+// Label is a dimension of a sample. The values of labels must be bounded, as
+// every combination of them is a separate time series
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value of a metric family
+type Sample struct {
+	// Suffix is appended to the name of the family, like the _sum and
+	// _count samples of summaries
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a set of samples sharing a name, a description and a type
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Source provides metric families to export
+type Source interface {
+	Metrics() []*Family
+}
+
+// Gather returns the metric families of the sources, sorted by name
+func Gather(sources ...Source) []*Family {
+	var families []*Family
+	for _, source := range sources {
+		families = append(families, source.Metrics()...)
+	}
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families
+}
+
+// WriteText writes the metric families in the text exposition format
+func WriteText(w io.Writer, families []*Family) error {
+	buf := bufio.NewWriter(w)
+	for _, family := range families {
+		fmt.Fprintf(buf, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(buf, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			buf.WriteString(family.Name)
+			buf.WriteString(sample.Suffix)
+			if len(sample.Labels) != 0 {
+				buf.WriteString("{")
+				for i, label := range sample.Labels {
+					if i != 0 {
+						buf.WriteString(",")
+					}
+					fmt.Fprintf(buf, "%s=\"%s\"", label.Name, escapeLabelValue(label.Value))
+				}
+				buf.WriteString("}")
+			}
+			buf.WriteString(" ")
+			buf.WriteString(formatValue(sample.Value))
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Flush()
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,66 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSource []*Family
+
+func (source testSource) Metrics() []*Family {
+	return source
+}
+
+func TestWriteText(t *testing.T) {
+	families := Gather(
+		testSource{{
+			Name: "requests_total",
+			Help: "Number of requests.\nMultiline \\ help.",
+			Type: CounterType,
+			Samples: []Sample{
+				{Labels: []Label{{Name: "code", Value: "200"}, {Name: "path", Value: "a\"b\\c\nd"}}, Value: 3},
+				{Labels: []Label{{Name: "code", Value: "500"}, {Name: "path", Value: "/"}}, Value: 1.5},
+			},
+		}},
+		testSource{{
+			Name: "latency_seconds",
+			Help: "Latency.",
+			Type: SummaryType,
+			Samples: []Sample{
+				{Suffix: "_sum", Value: math.NaN()},
+				{Suffix: "_count", Value: math.Inf(1)},
+			},
+		}},
+	)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, families))
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds summary
+latency_seconds_sum NaN
+latency_seconds_count +Inf
+# HELP requests_total Number of requests.\nMultiline \\ help.
+# TYPE requests_total counter
+requests_total{code="200",path="a\"b\\c\nd"} 3
+requests_total{code="500",path="/"} 1.5
+`, buf.String())
+}
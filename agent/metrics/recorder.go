@@ -0,0 +1,340 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ACSEndpoint is the endpoint label of the ACS connection state
+	ACSEndpoint = "acs"
+	// TCSEndpoint is the endpoint label of the TCS connection state
+	TCSEndpoint = "tcs"
+
+	// TaskServer is the server label of the requests to the task metadata
+	// and credentials endpoint
+	TaskServer = "task"
+	// IntrospectionServer is the server label of the requests to the
+	// introspection endpoint
+	IntrospectionServer = "introspection"
+
+	// RateLimit is the limit label of the requests throttled by the rate
+	// limit of their source
+	RateLimit = "rate"
+	// CredentialsRateLimit is the limit label of the requests for credentials
+	// throttled by the rate limit of their source
+	CredentialsRateLimit = "credentials_rate"
+	// ConnectionLimit is the limit label of the requests throttled because
+	// the server has too many connections
+	ConnectionLimit = "connections"
+)
+
+// summary accumulates observations in seconds
+type summary struct {
+	sum   float64
+	count uint64
+}
+
+func (s *summary) observe(duration time.Duration) {
+	s.sum += duration.Seconds()
+	s.count++
+}
+
+// messageKey identifies the messages of a type received from an endpoint
+type messageKey struct {
+	endpoint    string
+	messageType string
+}
+
+// messageStats counts the messages of a type received from an endpoint and
+// accumulates how long handling them took
+type messageStats struct {
+	received uint64
+	handled  uint64
+	errored  uint64
+	handling summary
+}
+
+// throttleKey identifies the requests to a server throttled by a limit
+type throttleKey struct {
+	server string
+	limit  string
+}
+
+// recorder holds the metrics recorded by the components of the agent as they
+// run
+type recorder struct {
+	lock        sync.Mutex
+	dockerCalls map[string]*summary
+	pulls       summary
+	connected   map[string]bool
+	messages    map[messageKey]*messageStats
+	throttled   map[throttleKey]uint64
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		dockerCalls: make(map[string]*summary),
+		messages:    make(map[messageKey]*messageStats),
+		throttled:   make(map[throttleKey]uint64),
+		connected: map[string]bool{
+			ACSEndpoint: false,
+			TCSEndpoint: false,
+		},
+	}
+}
+
+var defaultRecorder = newRecorder()
+
+// AgentMetrics is the source of the metrics recorded with RecordDockerCall,
+// RecordImagePull, SetConnected, RecordMessageReceived, RecordMessageHandled
+// and RecordThrottledRequest
+var AgentMetrics Source = defaultRecorder
+
+// RecordDockerCall records the latency of a call to the docker API that
+// started at the given time. The operations must be a fixed set of names,
+// like the names of the methods of the docker client
+func RecordDockerCall(operation string, start time.Time) {
+	defaultRecorder.recordDockerCall(operation, time.Since(start))
+}
+
+// RecordImagePull records how long pulling an image took
+func RecordImagePull(duration time.Duration) {
+	defaultRecorder.recordImagePull(duration)
+}
+
+// SetConnected records whether the agent is connected to the endpoint
+func SetConnected(endpoint string, connected bool) {
+	defaultRecorder.setConnected(endpoint, connected)
+}
+
+// RecordMessageReceived records a message of the type received from the
+// endpoint. The types must be a fixed set of names, like the types of the
+// messages the agent recognizes
+func RecordMessageReceived(endpoint string, messageType string) {
+	defaultRecorder.recordMessageReceived(endpoint, messageType)
+}
+
+// RecordMessageHandled records how long handling a message of the type
+// received from the endpoint took, and whether handling it failed
+func RecordMessageHandled(endpoint string, messageType string, duration time.Duration, err error) {
+	defaultRecorder.recordMessageHandled(endpoint, messageType, duration, err)
+}
+
+// RecordThrottledRequest records a request to the server throttled by the
+// limit, one of RateLimit, CredentialsRateLimit and ConnectionLimit
+func RecordThrottledRequest(server string, limit string) {
+	defaultRecorder.recordThrottledRequest(server, limit)
+}
+
+func (r *recorder) recordDockerCall(operation string, duration time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	calls, ok := r.dockerCalls[operation]
+	if !ok {
+		calls = &summary{}
+		r.dockerCalls[operation] = calls
+	}
+	calls.observe(duration)
+}
+
+func (r *recorder) recordImagePull(duration time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.pulls.observe(duration)
+}
+
+func (r *recorder) recordMessageReceived(endpoint string, messageType string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.messageStatsUnsafe(endpoint, messageType).received++
+}
+
+func (r *recorder) recordMessageHandled(endpoint string, messageType string, duration time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	stats := r.messageStatsUnsafe(endpoint, messageType)
+	if err != nil {
+		stats.errored++
+	} else {
+		stats.handled++
+	}
+	stats.handling.observe(duration)
+}
+
+func (r *recorder) messageStatsUnsafe(endpoint string, messageType string) *messageStats {
+	key := messageKey{endpoint: endpoint, messageType: messageType}
+	stats, ok := r.messages[key]
+	if !ok {
+		stats = &messageStats{}
+		r.messages[key] = stats
+	}
+	return stats
+}
+
+func (r *recorder) recordThrottledRequest(server string, limit string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.throttled[throttleKey{server: server, limit: limit}]++
+}
+
+func (r *recorder) setConnected(endpoint string, connected bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.connected[endpoint] = connected
+}
+
+// Metrics returns the recorded metrics
+func (r *recorder) Metrics() []*Family {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	dockerCalls := &Family{
+		Name: "ecs_agent_docker_api_call_duration_seconds",
+		Help: "Latency of the calls to the docker API by operation.",
+		Type: SummaryType,
+	}
+	for _, operation := range sortedKeys(r.dockerCalls) {
+		calls := r.dockerCalls[operation]
+		labels := []Label{{Name: "operation", Value: operation}}
+		dockerCalls.Samples = append(dockerCalls.Samples,
+			Sample{Suffix: "_sum", Labels: labels, Value: calls.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(calls.count)})
+	}
+
+	connected := &Family{
+		Name: "ecs_agent_connected",
+		Help: "Whether the agent is connected to the endpoint.",
+		Type: GaugeType,
+	}
+	endpoints := make([]string, 0, len(r.connected))
+	for endpoint := range r.connected {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		value := float64(0)
+		if r.connected[endpoint] {
+			value = 1
+		}
+		connected.Samples = append(connected.Samples, Sample{
+			Labels: []Label{{Name: "endpoint", Value: endpoint}},
+			Value:  value,
+		})
+	}
+
+	messagesReceived := &Family{
+		Name: "ecs_agent_backend_messages_received_total",
+		Help: "Number of messages received from the backend by endpoint and type.",
+		Type: CounterType,
+	}
+	messagesHandled := &Family{
+		Name: "ecs_agent_backend_messages_handled_total",
+		Help: "Number of messages from the backend handled successfully by endpoint and type.",
+		Type: CounterType,
+	}
+	messagesErrored := &Family{
+		Name: "ecs_agent_backend_messages_errored_total",
+		Help: "Number of messages from the backend whose handling failed by endpoint and type.",
+		Type: CounterType,
+	}
+	messagesHandling := &Family{
+		Name: "ecs_agent_backend_message_handling_duration_seconds",
+		Help: "Duration of the handling of the messages from the backend by endpoint and type.",
+		Type: SummaryType,
+	}
+	for _, key := range sortedMessageKeys(r.messages) {
+		stats := r.messages[key]
+		labels := []Label{{Name: "endpoint", Value: key.endpoint}, {Name: "type", Value: key.messageType}}
+		messagesReceived.Samples = append(messagesReceived.Samples, Sample{Labels: labels, Value: float64(stats.received)})
+		messagesHandled.Samples = append(messagesHandled.Samples, Sample{Labels: labels, Value: float64(stats.handled)})
+		messagesErrored.Samples = append(messagesErrored.Samples, Sample{Labels: labels, Value: float64(stats.errored)})
+		messagesHandling.Samples = append(messagesHandling.Samples,
+			Sample{Suffix: "_sum", Labels: labels, Value: stats.handling.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(stats.handling.count)})
+	}
+
+	throttled := &Family{
+		Name: "ecs_agent_http_requests_throttled_total",
+		Help: "Number of requests to the http servers of the agent throttled by server and limit.",
+		Type: CounterType,
+	}
+	throttleKeys := make([]throttleKey, 0, len(r.throttled))
+	for key := range r.throttled {
+		throttleKeys = append(throttleKeys, key)
+	}
+	sort.Slice(throttleKeys, func(i, j int) bool {
+		if throttleKeys[i].server != throttleKeys[j].server {
+			return throttleKeys[i].server < throttleKeys[j].server
+		}
+		return throttleKeys[i].limit < throttleKeys[j].limit
+	})
+	for _, key := range throttleKeys {
+		throttled.Samples = append(throttled.Samples, Sample{
+			Labels: []Label{{Name: "server", Value: key.server}, {Name: "limit", Value: key.limit}},
+			Value:  float64(r.throttled[key]),
+		})
+	}
+
+	return []*Family{
+		dockerCalls,
+		{
+			Name: "ecs_agent_image_pull_duration_seconds",
+			Help: "Duration of the image pulls of containers, excluding cached images.",
+			Type: SummaryType,
+			Samples: []Sample{
+				{Suffix: "_sum", Value: r.pulls.sum},
+				{Suffix: "_count", Value: float64(r.pulls.count)},
+			},
+		},
+		connected,
+		messagesReceived,
+		messagesHandled,
+		messagesErrored,
+		messagesHandling,
+		throttled,
+	}
+}
+
+func sortedKeys(m map[string]*summary) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedMessageKeys(m map[messageKey]*messageStats) []messageKey {
+	keys := make([]messageKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].messageType < keys[j].messageType
+	})
+	return keys
+}
@@ -0,0 +1,116 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderMetrics(t *testing.T) {
+	r := newRecorder()
+	r.recordDockerCall("StartContainer", 2*time.Second)
+	r.recordDockerCall("StartContainer", time.Second)
+	r.recordDockerCall("CreateContainer", 500*time.Millisecond)
+	r.recordImagePull(10 * time.Second)
+	r.setConnected(ACSEndpoint, true)
+
+	families := r.Metrics()
+	require.Len(t, families, 8)
+
+	assert.Equal(t, "ecs_agent_docker_api_call_duration_seconds", families[0].Name)
+	assert.Equal(t, []Sample{
+		{Suffix: "_sum", Labels: []Label{{Name: "operation", Value: "CreateContainer"}}, Value: 0.5},
+		{Suffix: "_count", Labels: []Label{{Name: "operation", Value: "CreateContainer"}}, Value: 1},
+		{Suffix: "_sum", Labels: []Label{{Name: "operation", Value: "StartContainer"}}, Value: 3},
+		{Suffix: "_count", Labels: []Label{{Name: "operation", Value: "StartContainer"}}, Value: 2},
+	}, families[0].Samples)
+
+	assert.Equal(t, "ecs_agent_image_pull_duration_seconds", families[1].Name)
+	assert.Equal(t, []Sample{
+		{Suffix: "_sum", Value: 10},
+		{Suffix: "_count", Value: 1},
+	}, families[1].Samples)
+
+	assert.Equal(t, "ecs_agent_connected", families[2].Name)
+	assert.Equal(t, []Sample{
+		{Labels: []Label{{Name: "endpoint", Value: ACSEndpoint}}, Value: 1},
+		{Labels: []Label{{Name: "endpoint", Value: TCSEndpoint}}, Value: 0},
+	}, families[2].Samples)
+}
+
+func TestRecorderMessageMetrics(t *testing.T) {
+	r := newRecorder()
+	r.recordMessageReceived(TCSEndpoint, "HeartbeatMessage")
+	r.recordMessageReceived(ACSEndpoint, "PayloadMessage")
+	r.recordMessageReceived(ACSEndpoint, "PayloadMessage")
+	r.recordMessageHandled(ACSEndpoint, "PayloadMessage", 2*time.Second, nil)
+	r.recordMessageHandled(ACSEndpoint, "PayloadMessage", time.Second, errors.New("oops"))
+
+	families := r.Metrics()
+	require.Len(t, families, 8)
+	acsLabels := []Label{{Name: "endpoint", Value: ACSEndpoint}, {Name: "type", Value: "PayloadMessage"}}
+	tcsLabels := []Label{{Name: "endpoint", Value: TCSEndpoint}, {Name: "type", Value: "HeartbeatMessage"}}
+
+	assert.Equal(t, "ecs_agent_backend_messages_received_total", families[3].Name)
+	assert.Equal(t, []Sample{
+		{Labels: acsLabels, Value: 2},
+		{Labels: tcsLabels, Value: 1},
+	}, families[3].Samples)
+
+	assert.Equal(t, "ecs_agent_backend_messages_handled_total", families[4].Name)
+	assert.Equal(t, []Sample{
+		{Labels: acsLabels, Value: 1},
+		{Labels: tcsLabels, Value: 0},
+	}, families[4].Samples)
+
+	assert.Equal(t, "ecs_agent_backend_messages_errored_total", families[5].Name)
+	assert.Equal(t, []Sample{
+		{Labels: acsLabels, Value: 1},
+		{Labels: tcsLabels, Value: 0},
+	}, families[5].Samples)
+
+	assert.Equal(t, "ecs_agent_backend_message_handling_duration_seconds", families[6].Name)
+	assert.Equal(t, []Sample{
+		{Suffix: "_sum", Labels: acsLabels, Value: 3},
+		{Suffix: "_count", Labels: acsLabels, Value: 2},
+		{Suffix: "_sum", Labels: tcsLabels, Value: 0},
+		{Suffix: "_count", Labels: tcsLabels, Value: 0},
+	}, families[6].Samples)
+}
+
+func TestRecorderThrottledRequestMetrics(t *testing.T) {
+	r := newRecorder()
+	r.recordThrottledRequest(TaskServer, RateLimit)
+	r.recordThrottledRequest(TaskServer, RateLimit)
+	r.recordThrottledRequest(TaskServer, ConnectionLimit)
+	r.recordThrottledRequest(IntrospectionServer, RateLimit)
+
+	families := r.Metrics()
+	require.Len(t, families, 8)
+
+	assert.Equal(t, "ecs_agent_http_requests_throttled_total", families[7].Name)
+	assert.Equal(t, CounterType, families[7].Type)
+	assert.Equal(t, []Sample{
+		{Labels: []Label{{Name: "server", Value: IntrospectionServer}, {Name: "limit", Value: RateLimit}}, Value: 1},
+		{Labels: []Label{{Name: "server", Value: TaskServer}, {Name: "limit", Value: ConnectionLimit}}, Value: 1},
+		{Labels: []Label{{Name: "server", Value: TaskServer}, {Name: "limit", Value: RateLimit}}, Value: 2},
+	}, families[7].Samples)
+}
@@ -21,12 +21,16 @@ const (
 	// TaskEvent is used to define the task state transition events emitted by
 	// the engine
 	TaskEvent
+
+	// AttachmentEvent is used to define the attachment state transition events
+	// emitted by the engine
+	AttachmentEvent
 )
 
 // Event defines the type of state change event
 type EventType int32
 
-// Event is used to abstract away the two transition event types
+// Event is used to abstract away the transition event types
 // passed up through a single channel from the the engine
 type Event interface {
 
@@ -70,7 +70,41 @@ const (
 	// 17)
 	//   a) Add 'secrets' field to 'apicontainer.Container'
 	//   b) Add 'ssmsecret' field to 'resources'
-	ECSDataVersion = 17
+	// 18) Add 'RuntimeID' field to 'Container' struct
+	// 19) Add 'PendingStateChanges' to the saved state
+	// 20) Add 'ImageDigest' field to 'Container' struct
+	// 21) Add 'StateChangeSequence' and 'SubmittedStateChangeSequence' fields to 'Task' struct
+	// 22) Add 'DependsOn' field to 'Container' struct
+	// 23) Add 'StartTimeout' and 'StopTimeout' fields to 'Container' struct
+	// 24) Add 'RestartPolicy' and 'RestartCount' fields to 'Container' struct
+	// 25) Add 'Tmpfs', 'ShmSize' and 'MemorySwappiness' fields to 'Container' struct
+	// 26) Add 'CapAdd' and 'CapDrop' fields to 'Container' struct
+	// 27) Add 'Devices' field to 'Container' struct
+	// 28) Add 'Ulimits' field to 'Container' struct
+	// 29) Add 'SystemControls' field to 'Container' struct
+	// 30) Add 'GPUCount' and 'GPUIDs' fields to 'Container' struct
+	// 31) Add 'EphemeralStorageLimit' field to 'Task' struct
+	// 32) Add 'InitProcessEnabled' field to 'Container' struct
+	// 33) Add 'ReadonlyRootFilesystem' field to 'Container' struct and
+	//     'Propagation' field to 'MountPoint' struct
+	// 34) Add 'StopSignal' field to 'Container' struct
+	// 35) Add 'ExitHistory' field to 'Container' struct
+	// 36) Add 'CleanupWaitDurationSeconds' field to 'Task' struct
+	// 37) Add 'AllocatedHostPorts' field to 'Container' struct
+	// 38) Add 'HealthCheck' field to 'Container' struct
+	// 39) Add 'FailingStreak' and 'LastCheckedAt' fields to 'HealthStatus' struct
+	// 40) Add 'ACSSequenceNumbers' to the saved state
+	// 41) Add 'StoppedReason' field to 'Task' struct
+	// 42) Add 'SubnetGatewayIPV6Address' field to 'ENI' struct and 'IPV6Addresses'
+	//     field to 'ENIAttachment' struct
+	// 43) Add 'AppMesh' field to 'Task' struct
+	// 44) Add 'BlockInstanceMetadata' field to 'Task' struct
+	// 45) Add 'EgressBandwidthLimit' field to 'Task' struct
+	// 46) Add 'MetadataFilePath' field to 'Container' struct
+	// 47) Add 'ImagePullBehavior' and 'ImageFromCache' fields to 'Container' struct
+	// 48) Add 'PullStartedAt' and 'PullStoppedAt' fields to 'Container' struct
+	// 49) Add 'TerminalReasonCode' field to 'Task' struct
+	ECSDataVersion = 49
 
 	// ecsDataFile specifies the filename in the ECS_DATADIR
 	ecsDataFile = "ecs_agent_data.json"
@@ -20,6 +20,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/engine"
@@ -85,6 +87,75 @@ func TestStateManager(t *testing.T) {
 	assert.Equal(t, "test-arn", tasks[0].Arn, "Wrong arn")
 }
 
+func TestStateManagerRoundTripsContainerMountSettings(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	cfg := &config.Config{DataDir: tmpDir}
+
+	taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+		nil, nil)
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", taskEngine))
+	require.Nil(t, err)
+
+	mountPoint := apicontainer.MountPoint{
+		SourceVolume:  "data",
+		ContainerPath: "/data",
+		Propagation:   apicontainer.MountPropagationRShared,
+	}
+	taskEngine.(*engine.DockerTaskEngine).State().AddTask(&apitask.Task{
+		Arn: "test-arn",
+		Containers: []*apicontainer.Container{{
+			Name:                   "c1",
+			ReadonlyRootFilesystem: true,
+			MountPoints:            []apicontainer.MountPoint{mountPoint},
+		}},
+	})
+	require.Nil(t, manager.Save())
+
+	loadedTaskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+		nil, nil)
+	manager, err = statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", &loadedTaskEngine))
+	require.Nil(t, err)
+	require.Nil(t, manager.Load())
+
+	tasks, err := loadedTaskEngine.ListTasks()
+	require.Nil(t, err)
+	require.Len(t, tasks, 1)
+	require.Len(t, tasks[0].Containers, 1)
+	container := tasks[0].Containers[0]
+	assert.True(t, container.ReadonlyRootFilesystem)
+	assert.Equal(t, []apicontainer.MountPoint{mountPoint}, container.MountPoints)
+}
+
+func TestStateManagerRoundTripsTerminalReasonCode(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	cfg := &config.Config{DataDir: tmpDir}
+
+	taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+		nil, nil)
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", taskEngine))
+	require.Nil(t, err)
+
+	task := &apitask.Task{Arn: "test-arn"}
+	task.SetTerminalReasonCode(apireason.ReasonCodeUserInitiatedStop)
+	taskEngine.(*engine.DockerTaskEngine).State().AddTask(task)
+	require.Nil(t, manager.Save())
+
+	loadedTaskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+		nil, nil)
+	manager, err = statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", &loadedTaskEngine))
+	require.Nil(t, err)
+	require.Nil(t, manager.Load())
+
+	tasks, err := loadedTaskEngine.ListTasks()
+	require.Nil(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, apireason.ReasonCodeUserInitiatedStop, tasks[0].GetTerminalReasonCode())
+}
+
 func assertFileMode(t *testing.T, path string) {
 	info, err := os.Stat(path)
 	assert.Nil(t, err)
@@ -19,6 +19,8 @@ import (
 	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apireason "github.com/aws/amazon-ecs-agent/agent/api/reason"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/ecs_client/model/ecs"
@@ -125,6 +127,16 @@ func (resolver *IntegContainerMetadataResolver) ResolveContainer(containerID str
 	return container, nil
 }
 
+func (resolver *IntegContainerMetadataResolver) RunningContainerIDs() ([]string, error) {
+	var dockerIDs []string
+	for dockerID, container := range resolver.containerIDToDockerContainer {
+		if container.Container.GetKnownStatus() == apicontainerstatus.ContainerRunning {
+			dockerIDs = append(dockerIDs, dockerID)
+		}
+	}
+	return dockerIDs, nil
+}
+
 func validateInstanceMetrics(t *testing.T, engine *DockerStatsEngine) {
 	metadata, taskMetrics, err := engine.GetInstanceMetrics()
 	assert.NoError(t, err, "gettting instance metrics failed")
@@ -242,8 +254,8 @@ func validateEmptyTaskHealthMetrics(t *testing.T, engine *DockerStatsEngine) {
 
 func createFakeContainerStats() []*ContainerStats {
 	return []*ContainerStats{
-		{22400432, 1839104, parseNanoTime("2015-02-12T21:22:05.131117533Z")},
-		{116499979, 3649536, parseNanoTime("2015-02-12T21:22:05.232291187Z")},
+		{cpuUsage: 22400432, memoryUsage: 1839104, timestamp: parseNanoTime("2015-02-12T21:22:05.131117533Z")},
+		{cpuUsage: 116499979, memoryUsage: 3649536, timestamp: parseNanoTime("2015-02-12T21:22:05.232291187Z")},
 	}
 }
 
@@ -278,6 +290,9 @@ func (engine *MockTaskEngine) GetTaskByArn(arn string) (*apitask.Task, bool) {
 	return nil, false
 }
 
+func (engine *MockTaskEngine) StopTasks([]string, apireason.ReasonCode, string) {
+}
+
 func (engine *MockTaskEngine) UnmarshalJSON([]byte) error {
 	return nil
 }
@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
 	"github.com/aws/amazon-ecs-agent/agent/stats/resolver"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/cihub/seelog"
 )
 
@@ -31,6 +32,13 @@ const (
 	// ContainerStatsBufferLength is the number of usage metrics stored in memory for a container. It is calculated as
 	// Number of usage metrics gathered in a second (1) * 60 * Time duration in minutes to store the data for (2)
 	ContainerStatsBufferLength = 120
+
+	// The stats stream of a running container is reopened with a backoff
+	// once it ends
+	statsStreamRestartBackoffMin      = 500 * time.Millisecond
+	statsStreamRestartBackoffMax      = 30 * time.Second
+	statsStreamRestartBackoffJitter   = 0.2
+	statsStreamRestartBackoffMultiple = 2
 )
 
 func newStatsContainer(dockerID string, client dockerapi.DockerClient, resolver resolver.ContainerMetadataResolver) *StatsContainer {
@@ -59,13 +67,15 @@ func (container *StatsContainer) StopStatsCollection() {
 
 func (container *StatsContainer) collect() {
 	dockerID := container.containerMetadata.DockerID
+	backoff := utils.NewSimpleBackoff(statsStreamRestartBackoffMin, statsStreamRestartBackoffMax,
+		statsStreamRestartBackoffJitter, statsStreamRestartBackoffMultiple)
 	for {
 		select {
 		case <-container.ctx.Done():
 			seelog.Debugf("Stopping stats collection for container %s", dockerID)
 			return
 		default:
-			err := container.processStatsStream()
+			received, err := container.processStatsStream()
 			if err != nil {
 				// Currently, the only error that we get here is if go-dockerclient is unable
 				// to decode the stats payload properly. Other errors such as
@@ -90,27 +100,53 @@ func (container *StatsContainer) collect() {
 			} else if terminal {
 				seelog.Infof("Container %s is terminal, stopping stats collection", dockerID)
 				container.StopStatsCollection()
+			} else {
+				// The stream broke while the container is running, it's
+				// reopened after a delay that grows while it keeps breaking
+				// without delivering stats
+				if received {
+					backoff.Reset()
+				}
+				delay := backoff.Duration()
+				seelog.Warnf("Stats stream of running container %s ended, reopening it in %s", dockerID, delay.String())
+				select {
+				case <-container.ctx.Done():
+				case <-time.After(delay):
+				}
 			}
 		}
 	}
 }
 
-func (container *StatsContainer) processStatsStream() error {
+// processStatsStream adds the stats of the stats stream of the container to
+// its queue until the stream ends. It returns true if the stream delivered
+// stats
+func (container *StatsContainer) processStatsStream() (bool, error) {
 	dockerID := container.containerMetadata.DockerID
 	seelog.Debugf("Collecting stats for container %s", dockerID)
 	if container.client == nil {
-		return errors.New("container processStatsStream: Client is not set.")
+		return false, errors.New("container processStatsStream: Client is not set.")
 	}
 	dockerStats, err := container.client.Stats(dockerID, container.ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
+	received := false
 	for rawStat := range dockerStats {
+		received = true
+		if container.taskNetworkStats != nil {
+			container.taskNetworkStats.sample(container.ctx, time.Now())
+		}
 		if err := container.statsQueue.Add(rawStat); err != nil {
 			seelog.Warnf("Error converting stats for container %s: %v", dockerID, err)
 		}
 	}
-	return nil
+	return received, nil
+}
+
+// collecting returns false once the stats collection of the container stopped
+func (container *StatsContainer) collecting() bool {
+	return container.ctx.Err() == nil
 }
 
 func (container *StatsContainer) terminal() (bool, error) {
@@ -43,6 +43,9 @@ const (
 	containerChangeHandler = "DockerStatsEngineDockerEventsHandler"
 	listContainersTimeout  = 10 * time.Minute
 	queueResetThreshold    = 2 * dockerapi.StatsInactivityTimeout
+	// collectorWatchdogInterval is the interval at which the stats collection
+	// of the running containers is checked
+	collectorWatchdogInterval = time.Minute
 )
 
 var (
@@ -64,10 +67,19 @@ type DockerContainerMetadataResolver struct {
 // defined to make testing easier.
 type Engine interface {
 	GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs.TaskMetric, error)
-	ContainerDockerStats(taskARN string, containerID string) (*docker.Stats, error)
+	// ContainerDockerStats returns the last raw docker stats of the container,
+	// and whether the container stopped, in which case they're the last ones
+	// collected before it stopped
+	ContainerDockerStats(taskARN string, containerID string) (stats *docker.Stats, stopped bool, err error)
 	GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error)
 }
 
+// DockerHealthProvider reports whether the docker daemon is reachable, which is
+// published along with the health of the tasks
+type DockerHealthProvider interface {
+	DockerReachable() bool
+}
+
 // DockerStatsEngine is used to monitor docker container events and to report
 // utlization metrics of the same.
 type DockerStatsEngine struct {
@@ -86,6 +98,24 @@ type DockerStatsEngine struct {
 	tasksToHealthCheckContainers map[string]map[string]*StatsContainer
 	// tasksToDefinitions maps task arns to task definition name and family metadata objects.
 	tasksToDefinitions map[string]*taskDefinition
+	// tasksToPullStats maps task arns to the stats of the image pulls of the
+	// containers that started being watched since the metrics of the task
+	// were last reported
+	tasksToPullStats map[string]*pullStats
+	// tasksToStoppedContainerUsage maps task arns to the usage of the
+	// containers that stopped since the metrics of the task were last
+	// reported, which is part of the usage of the task
+	tasksToStoppedContainerUsage map[string][]*containerUsage
+	// tasksToNetworkStats maps the arns of awsvpc tasks to their network
+	// counters, which are reported for the task
+	tasksToNetworkStats map[string]*taskNetworkStats
+	// tasksToStoppedContainerStats maps task arns to the last raw docker stats
+	// of their stopped containers, which are kept until the containers are
+	// cleaned up
+	tasksToStoppedContainerStats map[string]map[string]*docker.Stats
+	// dockerHealth is the source of the docker status of the health metrics,
+	// which don't report it if it's not set
+	dockerHealth DockerHealthProvider
 }
 
 // ResolveTask resolves the api task object, given container id.
@@ -114,6 +144,29 @@ func (resolver *DockerContainerMetadataResolver) ResolveContainer(dockerID strin
 	return container, nil
 }
 
+// RunningContainerIDs returns the docker ids of the containers known to be
+// running.
+func (resolver *DockerContainerMetadataResolver) RunningContainerIDs() ([]string, error) {
+	if resolver.dockerTaskEngine == nil {
+		return nil, fmt.Errorf("Docker task engine uninitialized")
+	}
+	state := resolver.dockerTaskEngine.State()
+	var dockerIDs []string
+	for _, task := range state.AllTasks() {
+		containerMap, ok := state.ContainerMapByArn(task.Arn)
+		if !ok {
+			continue
+		}
+		for _, dockerContainer := range containerMap {
+			if dockerContainer.DockerID != "" &&
+				dockerContainer.Container.GetKnownStatus() == apicontainerstatus.ContainerRunning {
+				dockerIDs = append(dockerIDs, dockerContainer.DockerID)
+			}
+		}
+	}
+	return dockerIDs, nil
+}
+
 // NewDockerStatsEngine creates a new instance of the DockerStatsEngine object.
 // MustInit() must be called to initialize the fields of the new event listener.
 func NewDockerStatsEngine(cfg *config.Config, client dockerapi.DockerClient, containerChangeEventStream *eventstream.EventStream) *DockerStatsEngine {
@@ -124,6 +177,10 @@ func NewDockerStatsEngine(cfg *config.Config, client dockerapi.DockerClient, con
 		tasksToContainers:            make(map[string]map[string]*StatsContainer),
 		tasksToHealthCheckContainers: make(map[string]map[string]*StatsContainer),
 		tasksToDefinitions:           make(map[string]*taskDefinition),
+		tasksToPullStats:             make(map[string]*pullStats),
+		tasksToStoppedContainerUsage: make(map[string][]*containerUsage),
+		tasksToNetworkStats:          make(map[string]*taskNetworkStats),
+		tasksToStoppedContainerStats: make(map[string]map[string]*docker.Stats),
 		containerChangeEventStream:   containerChangeEventStream,
 	}
 }
@@ -145,6 +202,10 @@ func (engine *DockerStatsEngine) synchronizeState() error {
 func (engine *DockerStatsEngine) addAndStartStatsContainer(containerID string) {
 	engine.lock.Lock()
 	defer engine.lock.Unlock()
+	engine.addAndStartStatsContainerUnsafe(containerID)
+}
+
+func (engine *DockerStatsEngine) addAndStartStatsContainerUnsafe(containerID string) {
 	statsContainer, err := engine.addContainerUnsafe(containerID)
 	if err != nil {
 		seelog.Debugf("Adding container to stats watch list failed, container: %s, err: %v", containerID, err)
@@ -187,9 +248,86 @@ func (engine *DockerStatsEngine) MustInit(ctx context.Context, taskEngine ecseng
 	}
 
 	go engine.waitToStop()
+	go engine.watchCollectors(derivedCtx)
 	return nil
 }
 
+// watchCollectors periodically restarts the stats collection of running
+// containers that isn't active, in case docker events were missed or the
+// collection stopped while the container was still running
+func (engine *DockerStatsEngine) watchCollectors(ctx context.Context) {
+	ticker := time.NewTicker(collectorWatchdogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			engine.restartInactiveCollectors()
+			engine.pruneStoppedContainerStats()
+		}
+	}
+}
+
+// restartInactiveCollectors starts collecting the stats of the running
+// containers without an active stats collection
+func (engine *DockerStatsEngine) restartInactiveCollectors() {
+	dockerIDs, err := engine.resolver.RunningContainerIDs()
+	if err != nil {
+		seelog.Warnf("Unable to list the running containers to check their stats collection: %v", err)
+		return
+	}
+
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+
+	if engine.disableMetrics {
+		return
+	}
+	collectors := make(map[string]*StatsContainer)
+	collectorTasks := make(map[string]string)
+	for taskArn, containerMap := range engine.tasksToContainers {
+		for dockerID, statsContainer := range containerMap {
+			collectors[dockerID] = statsContainer
+			collectorTasks[dockerID] = taskArn
+		}
+	}
+	for _, dockerID := range dockerIDs {
+		statsContainer, ok := collectors[dockerID]
+		if ok && statsContainer.collecting() {
+			continue
+		}
+		seelog.Warnf("Stats collection of running container %s is not active, starting it", dockerID)
+		if !ok {
+			engine.addAndStartStatsContainerUnsafe(dockerID)
+			continue
+		}
+		// The container is still tracked, only its collection is replaced
+		restarted := newStatsContainer(dockerID, engine.client, engine.resolver)
+		restarted.taskNetworkStats = statsContainer.taskNetworkStats
+		engine.tasksToContainers[collectorTasks[dockerID]][dockerID] = restarted
+		restarted.StartStatsCollection()
+	}
+}
+
+// pruneStoppedContainerStats forgets the last stats of the stopped containers
+// that were cleaned up
+func (engine *DockerStatsEngine) pruneStoppedContainerStats() {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+
+	for taskArn, containerStats := range engine.tasksToStoppedContainerStats {
+		for dockerID := range containerStats {
+			if _, err := engine.resolver.ResolveContainer(dockerID); err != nil {
+				delete(containerStats, dockerID)
+			}
+		}
+		if len(containerStats) == 0 {
+			delete(engine.tasksToStoppedContainerStats, taskArn)
+		}
+	}
+}
+
 // Shutdown cleans up the resources after the statas engine.
 func (engine *DockerStatsEngine) Shutdown() {
 	engine.stopEngine()
@@ -223,6 +361,7 @@ func (engine *DockerStatsEngine) removeAll() {
 			statsContainer.StopStatsCollection()
 		}
 		delete(engine.tasksToContainers, task)
+		delete(engine.tasksToNetworkStats, task)
 	}
 
 	for task := range engine.tasksToHealthCheckContainers {
@@ -247,22 +386,35 @@ func (engine *DockerStatsEngine) addContainerUnsafe(dockerID string) (*StatsCont
 		return nil, errors.Errorf("stats add container: task is terminal, ignoring container: %s, task: %s", dockerID, task.Arn)
 	}
 
+	dockerContainer, err := engine.resolver.ResolveContainer(dockerID)
+	if err == nil && dockerContainer.Container.IsInternal() {
+		// The containers the agent adds to tasks, like the pause container of
+		// awsvpc tasks, aren't reported
+		return nil, errors.Errorf("stats add container: ignoring internal container: %s, task: %s", dockerID, task.Arn)
+	}
+
 	seelog.Debugf("Adding container to stats watch list, id: %s, task: %s", dockerID, task.Arn)
 	statsContainer := newStatsContainer(dockerID, engine.client, engine.resolver)
 	engine.tasksToDefinitions[task.Arn] = &taskDefinition{family: task.Family, version: task.Version}
 
 	watchStatsContainer := false
 	if !engine.disableMetrics {
+		statsContainer.taskNetworkStats = engine.taskNetworkStatsUnsafe(task)
 		// Adding container to the map for collecting stats
 		watchStatsContainer = engine.addToStatsContainerMapUnsafe(task.Arn, dockerID, statsContainer, engine.containerMetricsMapUnsafe)
 	}
 
-	if dockerContainer, err := engine.resolver.ResolveContainer(dockerID); err != nil {
+	if err != nil {
 		seelog.Debugf("Could not map container ID to container, container: %s, err: %s", dockerID, err)
-	} else if dockerContainer.Container.HealthStatusShouldBeReported() {
-		// Track the container health status
-		engine.addToStatsContainerMapUnsafe(task.Arn, dockerID, statsContainer, engine.healthCheckContainerMapUnsafe)
-		seelog.Debugf("Adding container to stats health check watch list, id: %s, task: %s", dockerID, task.Arn)
+	} else {
+		if watchStatsContainer {
+			engine.recordPullUnsafe(task.Arn, dockerContainer.Container)
+		}
+		if dockerContainer.Container.HealthStatusShouldBeReported() {
+			// Track the container health status
+			engine.addToStatsContainerMapUnsafe(task.Arn, dockerID, statsContainer, engine.healthCheckContainerMapUnsafe)
+			seelog.Debugf("Adding container to stats health check watch list, id: %s, task: %s", dockerID, task.Arn)
+		}
 	}
 
 	if !watchStatsContainer {
@@ -271,6 +423,40 @@ func (engine *DockerStatsEngine) addContainerUnsafe(dockerID string) (*StatsCont
 	return statsContainer, nil
 }
 
+// taskNetworkStatsUnsafe returns the network counters of the task if it's an
+// awsvpc task, whose containers use the network namespace of the pause
+// container. The counters are shared by the containers of the task
+func (engine *DockerStatsEngine) taskNetworkStatsUnsafe(task *apitask.Task) *taskNetworkStats {
+	if task.GetTaskENI() == nil {
+		return nil
+	}
+	if stats, ok := engine.tasksToNetworkStats[task.Arn]; ok {
+		return stats
+	}
+	pauseContainer, ok := task.ContainerByName(apitask.NetworkPauseContainerName)
+	if !ok || pauseContainer.GetRuntimeID() == "" {
+		return nil
+	}
+	stats := newTaskNetworkStats(pauseContainer.GetRuntimeID(), engine.client)
+	engine.tasksToNetworkStats[task.Arn] = stats
+	return stats
+}
+
+// recordPullUnsafe adds the image pull of the container to the pull stats of
+// its task
+func (engine *DockerStatsEngine) recordPullUnsafe(taskARN string, container *apicontainer.Container) {
+	duration, ok := container.GetPullDuration()
+	if !ok {
+		return
+	}
+	stats, ok := engine.tasksToPullStats[taskARN]
+	if !ok {
+		stats = &pullStats{}
+		engine.tasksToPullStats[taskARN] = stats
+	}
+	stats.record(duration, container.GetImageFromCache())
+}
+
 func (engine *DockerStatsEngine) containerMetricsMapUnsafe() map[string]map[string]*StatsContainer {
 	return engine.tasksToContainers
 }
@@ -329,7 +515,7 @@ func (engine *DockerStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata,
 	defer engine.lock.Unlock()
 
 	for taskArn := range engine.tasksToContainers {
-		containerMetrics, err := engine.taskContainerMetricsUnsafe(taskArn)
+		containerMetrics, containerUsages, err := engine.taskContainerMetricsUnsafe(taskArn)
 		if err != nil {
 			seelog.Debugf("Error getting container metrics for task: %s, err: %v", taskArn, err)
 			continue
@@ -352,6 +538,21 @@ func (engine *DockerStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata,
 			TaskDefinitionFamily:  &taskDef.family,
 			TaskDefinitionVersion: &taskDef.version,
 			ContainerMetrics:      containerMetrics,
+			TaskStatsSet: taskStatsSet(append(containerUsages,
+				engine.tasksToStoppedContainerUsage[taskArn]...)),
+		}
+		if networkStats, ok := engine.tasksToNetworkStats[taskArn]; ok {
+			if networkStatsSet, err := networkStats.queue.GetNetworkStatsSet(); err != nil {
+				seelog.Debugf("Network stats not available for task %s: %v", taskArn, err)
+			} else {
+				taskMetric.NetworkStatsSet = networkStatsSet
+			}
+		}
+		// The pulls are only reported once
+		if stats, ok := engine.tasksToPullStats[taskArn]; ok {
+			taskMetric.PullDurationStatsSet = stats.statsSet()
+			taskMetric.CachedPullCount = aws.Int64(stats.cacheHits)
+			delete(engine.tasksToPullStats, taskArn)
 		}
 		taskMetrics = append(taskMetrics, taskMetric)
 	}
@@ -367,6 +568,12 @@ func (engine *DockerStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata,
 	return metricsMetadata, taskMetrics, nil
 }
 
+// SetDockerHealth sets the source of the docker status reported with the
+// health metrics. It must be called before the engine is initialized
+func (engine *DockerStatsEngine) SetDockerHealth(dockerHealth DockerHealthProvider) {
+	engine.dockerHealth = dockerHealth
+}
+
 // GetTaskHealthMetrics returns the container health metrics
 func (engine *DockerStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
 	var taskHealths []*ecstcs.TaskHealth
@@ -375,6 +582,12 @@ func (engine *DockerStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata,
 		ContainerInstance: aws.String(engine.containerInstanceArn),
 		MessageId:         aws.String(uuid.NewRandom().String()),
 	}
+	if engine.dockerHealth != nil {
+		metadata.DockerStatus = aws.String(ecstcs.HealthStatusHealthy)
+		if !engine.dockerHealth.DockerReachable() {
+			metadata.DockerStatus = aws.String(ecstcs.HealthStatusUnhealthy)
+		}
+	}
 
 	if !engine.containerHealthsToMonitor() {
 		return metadata, taskHealths, nil
@@ -476,9 +689,13 @@ func (engine *DockerStatsEngine) getTaskHealthUnsafe(taskARN string) *ecstcs.Tas
 			healthInfo.Since = aws.Time(time.Now())
 		}
 		containerHealth := &ecstcs.ContainerHealth{
-			ContainerName: aws.String(dockerContainer.Container.Name),
-			HealthStatus:  aws.String(healthInfo.Status.BackendStatus()),
-			StatusSince:   aws.Time(healthInfo.Since.UTC()),
+			ConsecutiveFailures: aws.Int64(int64(healthInfo.FailingStreak)),
+			ContainerName:       aws.String(dockerContainer.Container.Name),
+			HealthStatus:        aws.String(healthInfo.Status.BackendStatus()),
+			StatusSince:         aws.Time(healthInfo.Since.UTC()),
+		}
+		if healthInfo.LastCheckedAt != nil {
+			containerHealth.LastCheckedAt = aws.Time(healthInfo.LastCheckedAt.UTC())
 		}
 		containerHealths = append(containerHealths, containerHealth)
 	}
@@ -564,14 +781,16 @@ func newDockerContainerMetadataResolver(taskEngine ecsengine.TaskEngine) (*Docke
 	return resolver, nil
 }
 
-// taskContainerMetricsUnsafe gets all container metrics for a task arn.
-func (engine *DockerStatsEngine) taskContainerMetricsUnsafe(taskArn string) ([]*ecstcs.ContainerMetric, error) {
+// taskContainerMetricsUnsafe gets all container metrics for a task arn, and
+// the usage of the containers for the task metrics.
+func (engine *DockerStatsEngine) taskContainerMetricsUnsafe(taskArn string) ([]*ecstcs.ContainerMetric, []*containerUsage, error) {
 	containerMap, taskExists := engine.tasksToContainers[taskArn]
 	if !taskExists {
-		return nil, fmt.Errorf("Task not found")
+		return nil, nil, fmt.Errorf("Task not found")
 	}
 
 	var containerMetrics []*ecstcs.ContainerMetric
+	var containerUsages []*containerUsage
 	for _, container := range containerMap {
 		dockerID := container.containerMetadata.DockerID
 		// Check if the container is terminal. If it is, make sure that it is
@@ -602,19 +821,65 @@ func (engine *DockerStatsEngine) taskContainerMetricsUnsafe(taskArn string) ([]*
 			continue
 		}
 
-		containerMetrics = append(containerMetrics, &ecstcs.ContainerMetric{
+		containerMetric := &ecstcs.ContainerMetric{
 			CpuStatsSet:    cpuStatsSet,
 			MemoryStatsSet: memoryStatsSet,
-		})
+		}
+		memoryWorkingSetStatsSet, err := container.statsQueue.GetMemoryWorkingSetStatsSet()
+		if err != nil {
+			seelog.Warnf("Error getting memory working set stats, err: %v, container: %v", err, dockerID)
+			continue
+		}
+		containerMetric.MemoryWorkingSetStatsSet = memoryWorkingSetStatsSet
+		// Throttling is unknown on Windows
+		if cpuThrottlingStatsSet, err := container.statsQueue.GetCPUThrottlingStatsSet(); err != nil {
+			seelog.Debugf("CPU throttling stats not available for container %s: %v", dockerID, err)
+		} else {
+			containerMetric.CpuThrottlingStatsSet = cpuThrottlingStatsSet
+		}
+		// Network stats are unknown for the containers of the host network
+		// mode, which are reported without them
+		if networkStatsSet, err := container.statsQueue.GetNetworkStatsSet(); err != nil {
+			seelog.Debugf("Network stats not available for container %s: %v", dockerID, err)
+		} else {
+			containerMetric.NetworkStatsSet = networkStatsSet
+		}
+		storageStatsSet, err := container.statsQueue.GetStorageStatsSet()
+		if err != nil {
+			seelog.Warnf("Error getting storage stats, err: %v, container: %v", err, dockerID)
+			continue
+		}
+		containerMetric.StorageStatsSet = storageStatsSet
+		containerMetrics = append(containerMetrics, containerMetric)
 
+		usage := &containerUsage{cpu: cpuStatsSet, memory: memoryStatsSet}
+		if memoryUtilizationStatsSet, err := container.statsQueue.GetMemoryUtilizationStatsSet(); err == nil {
+			usage.memoryUtilization = memoryUtilizationStatsSet
+		}
+		containerUsages = append(containerUsages, usage)
 	}
 
-	return containerMetrics, nil
+	return containerMetrics, containerUsages, nil
 }
 
 func (engine *DockerStatsEngine) doRemoveContainerUnsafe(container *StatsContainer, taskArn string) {
 	container.StopStatsCollection()
 	dockerID := container.containerMetadata.DockerID
+	if _, ok := engine.tasksToContainers[taskArn][dockerID]; ok {
+		// Keep the usage of the container until it stopped for the task
+		// metrics
+		if usage, err := newContainerUsage(container.statsQueue); err == nil {
+			engine.tasksToStoppedContainerUsage[taskArn] = append(engine.tasksToStoppedContainerUsage[taskArn], usage)
+		}
+		// Keep the last stats of the container for the task metadata
+		// endpoint
+		if lastStat := container.statsQueue.GetLastStat(); lastStat != nil {
+			if _, ok := engine.tasksToStoppedContainerStats[taskArn]; !ok {
+				engine.tasksToStoppedContainerStats[taskArn] = make(map[string]*docker.Stats)
+			}
+			engine.tasksToStoppedContainerStats[taskArn][dockerID] = lastStat
+		}
+	}
 	delete(engine.tasksToContainers[taskArn], dockerID)
 	seelog.Debugf("Deleted container from tasks, id: %s", dockerID)
 
@@ -624,6 +889,9 @@ func (engine *DockerStatsEngine) doRemoveContainerUnsafe(container *StatsContain
 		// No need to verify if the key exists in tasksToDefinitions.
 		// Delete will do nothing if the specified key doesn't exist.
 		delete(engine.tasksToDefinitions, taskArn)
+		delete(engine.tasksToPullStats, taskArn)
+		delete(engine.tasksToStoppedContainerUsage, taskArn)
+		delete(engine.tasksToNetworkStats, taskArn)
 		seelog.Debugf("Deleted task from tasks, arn: %s", taskArn)
 	}
 
@@ -639,32 +907,41 @@ func (engine *DockerStatsEngine) doRemoveContainerUnsafe(container *StatsContain
 	}
 }
 
-// resetStatsUnsafe resets stats for all watched containers.
+// resetStatsUnsafe resets stats for all watched containers, and forgets the
+// usage of the containers that stopped.
 func (engine *DockerStatsEngine) resetStatsUnsafe() {
 	for _, containerMap := range engine.tasksToContainers {
 		for _, container := range containerMap {
 			container.statsQueue.Reset()
 		}
 	}
+	for _, networkStats := range engine.tasksToNetworkStats {
+		networkStats.queue.Reset()
+	}
+	engine.tasksToStoppedContainerUsage = make(map[string][]*containerUsage)
 }
 
-// ContainerDockerStats returns the last stored raw docker stats object for a container
-func (engine *DockerStatsEngine) ContainerDockerStats(taskARN string, containerID string) (*docker.Stats, error) {
+// ContainerDockerStats returns the last stored raw docker stats object for a
+// container. The stats of stopped containers are the last ones collected
+// before they stopped
+func (engine *DockerStatsEngine) ContainerDockerStats(taskARN string, containerID string) (*docker.Stats, bool, error) {
 	engine.lock.RLock()
 	defer engine.lock.RUnlock()
 
-	containerIDToStatsContainer, ok := engine.tasksToContainers[taskARN]
-	if !ok {
-		return nil, errors.Errorf("stats engine: task '%s' for container '%s' not found",
-			taskARN, containerID)
+	if container, ok := engine.tasksToContainers[taskARN][containerID]; ok {
+		return container.statsQueue.GetLastStat(), false, nil
 	}
-
-	container, ok := containerIDToStatsContainer[containerID]
-	if !ok {
-		return nil, errors.Errorf("stats engine: container not found: %s", containerID)
+	if lastStat, ok := engine.tasksToStoppedContainerStats[taskARN][containerID]; ok {
+		return lastStat, true, nil
 	}
-	return container.statsQueue.GetLastStat(), nil
 
+	_, running := engine.tasksToContainers[taskARN]
+	_, stopped := engine.tasksToStoppedContainerStats[taskARN]
+	if !running && !stopped {
+		return nil, false, errors.Errorf("stats engine: task '%s' for container '%s' not found",
+			taskARN, containerID)
+	}
+	return nil, false, errors.Errorf("stats engine: container not found: %s", containerID)
 }
 
 // newMetricsMetadata creates the singleton metadata object.
@@ -23,18 +23,91 @@ import (
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
 	mock_resolver "github.com/aws/amazon-ecs-agent/agent/stats/resolver/mock"
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
 
 	"github.com/aws/aws-sdk-go/aws"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func TestStatsEngineReportsPullStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	mockDockerClient := mock_dockerapi.NewMockDockerClient(ctrl)
+	t1 := &apitask.Task{Arn: "t1", Family: "f1"}
+	pullStartedAt := time.Now()
+	pulledContainer := &apicontainer.Container{
+		PullStartedAtUnsafe: pullStartedAt,
+		PullStoppedAtUnsafe: pullStartedAt.Add(2 * time.Second),
+	}
+	cachedContainer := &apicontainer.Container{
+		ImageFromCacheUnsafe: true,
+		PullStartedAtUnsafe:  pullStartedAt,
+		PullStoppedAtUnsafe:  pullStartedAt,
+	}
+	resolver.EXPECT().ResolveTask(gomock.Any()).AnyTimes().Return(t1, nil)
+	resolver.EXPECT().ResolveContainer("c1").AnyTimes().Return(&apicontainer.DockerContainer{
+		Container: pulledContainer,
+	}, nil)
+	resolver.EXPECT().ResolveContainer("c2").AnyTimes().Return(&apicontainer.DockerContainer{
+		Container: cachedContainer,
+	}, nil)
+	mockStatsChannel := make(chan *docker.Stats)
+	defer close(mockStatsChannel)
+	mockDockerClient.EXPECT().Stats(gomock.Any(), gomock.Any()).Return(mockStatsChannel, nil).AnyTimes()
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineReportsPullStats"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	engine.ctx = ctx
+	engine.resolver = resolver
+	engine.client = mockDockerClient
+	engine.cluster = defaultCluster
+	engine.containerInstanceArn = defaultContainerInstance
+	defer engine.removeAll()
+
+	engine.addAndStartStatsContainer("c1")
+	engine.addAndStartStatsContainer("c2")
+	// Containers that are already watched aren't counted again
+	engine.addAndStartStatsContainer("c1")
+
+	addStats := func() {
+		for _, statsContainer := range engine.tasksToContainers["t1"] {
+			for _, fakeContainerStats := range createFakeContainerStats() {
+				statsContainer.statsQueue.add(fakeContainerStats)
+			}
+		}
+	}
+	addStats()
+	_, taskMetrics, err := engine.GetInstanceMetrics()
+	assert.NoError(t, err)
+	assert.Len(t, taskMetrics, 1)
+	pullStatsSet := taskMetrics[0].PullDurationStatsSet
+	assert.NotNil(t, pullStatsSet)
+	assert.Equal(t, float64(0), aws.Float64Value(pullStatsSet.Min))
+	assert.Equal(t, float64(2000), aws.Float64Value(pullStatsSet.Max))
+	assert.Equal(t, float64(2000), aws.Float64Value(pullStatsSet.Sum))
+	assert.Equal(t, int64(2), aws.Int64Value(pullStatsSet.SampleCount))
+	assert.Equal(t, int64(1), aws.Int64Value(taskMetrics[0].CachedPullCount))
+
+	// The pulls are only reported once
+	addStats()
+	_, taskMetrics, err = engine.GetInstanceMetrics()
+	assert.NoError(t, err)
+	assert.Len(t, taskMetrics, 1)
+	assert.Nil(t, taskMetrics[0].PullDurationStatsSet)
+	assert.Nil(t, taskMetrics[0].CachedPullCount)
+}
+
 func TestStatsEngineAddRemoveContainers(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -96,7 +169,7 @@ func TestStatsEngineAddRemoveContainers(t *testing.T) {
 	}
 
 	// Ensure task shows up in metrics.
-	containerMetrics, err := engine.taskContainerMetricsUnsafe("t1")
+	containerMetrics, _, err := engine.taskContainerMetricsUnsafe("t1")
 	if err != nil {
 		t.Errorf("Error getting container metrics: %v", err)
 	}
@@ -126,7 +199,7 @@ func TestStatsEngineAddRemoveContainers(t *testing.T) {
 	}
 
 	// Ensure that only valid task shows up in metrics.
-	_, err = engine.taskContainerMetricsUnsafe("t2")
+	_, _, err = engine.taskContainerMetricsUnsafe("t2")
 	if err == nil {
 		t.Error("Expected non-empty error for non existent task")
 	}
@@ -191,8 +264,20 @@ func TestStatsEngineMetadataInStatsSets(t *testing.T) {
 	ts1 := parseNanoTime("2015-02-12T21:22:05.131117533Z")
 	ts2 := parseNanoTime("2015-02-12T21:22:05.232291187Z")
 	containerStats := []*ContainerStats{
-		{22400432, 1839104, ts1},
-		{116499979, 3649536, ts2},
+		{
+			cpuUsage:     22400432,
+			memoryUsage:  1839104,
+			timestamp:    ts1,
+			networkStats: &networkStats{rxBytes: 1000, rxPackets: 10, txBytes: 500, txPackets: 5},
+			storageStats: &storageStats{readBytes: 4096, readOps: 1},
+		},
+		{
+			cpuUsage:     116499979,
+			memoryUsage:  3649536,
+			timestamp:    ts2,
+			networkStats: &networkStats{rxBytes: 3000, rxPackets: 30, txBytes: 600, txPackets: 6},
+			storageStats: &storageStats{readBytes: 12288, readOps: 3},
+		},
 	}
 	dockerStats := []*docker.Stats{
 		{
@@ -223,19 +308,243 @@ func TestStatsEngineMetadataInStatsSets(t *testing.T) {
 	if *taskMetrics[0].TaskArn != "t1" {
 		t.Errorf("Incorrect task arn. Expected: t1, got: %s", *taskMetrics[0].TaskArn)
 	}
+	networkStatsSet := taskMetrics[0].ContainerMetrics[0].NetworkStatsSet
+	require.NotNil(t, networkStatsSet)
+	assert.Equal(t, float64(2000), aws.Float64Value(networkStatsSet.RxBytes.Sum))
+	assert.Equal(t, float64(100), aws.Float64Value(networkStatsSet.TxBytes.Sum))
+	storageStatsSet := taskMetrics[0].ContainerMetrics[0].StorageStatsSet
+	require.NotNil(t, storageStatsSet)
+	assert.Equal(t, float64(8192), aws.Float64Value(storageStatsSet.ReadBytes.Sum))
+	assert.Equal(t, float64(2), aws.Float64Value(storageStatsSet.ReadOps.Sum))
+	assert.Equal(t, float64(0), aws.Float64Value(storageStatsSet.WriteBytes.Sum))
+	assert.NotNil(t, taskMetrics[0].ContainerMetrics[0].MemoryWorkingSetStatsSet)
+	assert.Nil(t, taskMetrics[0].ContainerMetrics[0].CpuThrottlingStatsSet)
 	err = validateMetricsMetadata(metadata)
 	if err != nil {
 		t.Errorf("Error validating metadata: %v", err)
 	}
 
-	dockerStat, err := engine.ContainerDockerStats("t1", "c1")
+	dockerStat, stopped, err := engine.ContainerDockerStats("t1", "c1")
 	assert.NoError(t, err)
+	assert.False(t, stopped)
 	assert.Equal(t, ts2, dockerStat.Read)
 
 	engine.removeContainer("c1")
 	validateIdleContainerMetrics(t, engine)
 }
 
+func TestStatsEngineTaskStatsSet(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(mockCtrl)
+	mockDockerClient := mock_dockerapi.NewMockDockerClient(mockCtrl)
+	t1 := &apitask.Task{Arn: "t1", Family: "f1"}
+	resolver.EXPECT().ResolveTask(gomock.Any()).AnyTimes().Return(t1, nil)
+	resolver.EXPECT().ResolveContainer(gomock.Any()).AnyTimes().Return(&apicontainer.DockerContainer{
+		Container: &apicontainer.Container{},
+	}, nil)
+	mockDockerClient.EXPECT().Stats(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineTaskStatsSet"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	engine.ctx = ctx
+	engine.resolver = resolver
+	engine.cluster = defaultCluster
+	engine.containerInstanceArn = defaultContainerInstance
+	engine.client = mockDockerClient
+	for _, dockerID := range []string{"c1", "c2", "c3"} {
+		engine.addAndStartStatsContainer(dockerID)
+		for _, containerStats := range createFakeContainerStats() {
+			if dockerID == "c1" {
+				containerStats.memoryLimit = 2 * 3649536
+			}
+			engine.tasksToContainers["t1"][dockerID].statsQueue.add(containerStats)
+		}
+	}
+	// c3 stops before the metrics are reported
+	engine.removeContainer("c3")
+
+	_, taskMetrics, err := engine.GetInstanceMetrics()
+	require.NoError(t, err)
+	require.Len(t, taskMetrics, 1)
+	assert.Len(t, taskMetrics[0].ContainerMetrics, 2)
+
+	// The memory usage of each container is 1 and 3 MiB, including c3
+	taskStatsSet := taskMetrics[0].TaskStatsSet
+	require.NotNil(t, taskStatsSet)
+	assert.Equal(t, float64(3), aws.Float64Value(taskStatsSet.MemoryStatsSet.Min))
+	assert.Equal(t, float64(9), aws.Float64Value(taskStatsSet.MemoryStatsSet.Max))
+	assert.Equal(t, float64(12), aws.Float64Value(taskStatsSet.MemoryStatsSet.Sum))
+	assert.Equal(t, int64(2), aws.Int64Value(taskStatsSet.MemoryStatsSet.SampleCount))
+	containerCPU := aws.Float64Value(taskMetrics[0].ContainerMetrics[0].CpuStatsSet.Sum)
+	assert.InDelta(t, 3*containerCPU, aws.Float64Value(taskStatsSet.CpuStatsSet.Sum), 0.001)
+	// Only c1 has a memory limit
+	assert.Equal(t, float64(50), aws.Float64Value(taskStatsSet.MemoryUtilizationStatsSet.Max))
+
+	// The usage of stopped containers is only reported once
+	assert.Empty(t, engine.tasksToStoppedContainerUsage)
+
+	// The task drops out once none of its containers is running
+	engine.removeContainer("c1")
+	engine.removeContainer("c2")
+	assert.Empty(t, engine.tasksToContainers)
+	assert.Empty(t, engine.tasksToStoppedContainerUsage)
+}
+
+func TestStatsEngineAwsvpcTaskNetworkStats(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(mockCtrl)
+	mockDockerClient := mock_dockerapi.NewMockDockerClient(mockCtrl)
+	t1 := &apitask.Task{
+		Arn:    "t1",
+		Family: "f1",
+		Containers: []*apicontainer.Container{
+			{Name: apitask.NetworkPauseContainerName, Type: apicontainer.ContainerCNIPause, RuntimeID: "pause"},
+		},
+	}
+	t1.SetTaskENI(&apieni.ENI{ID: "eni-1"})
+	resolver.EXPECT().ResolveTask(gomock.Any()).AnyTimes().Return(t1, nil)
+	resolver.EXPECT().ResolveContainer("pause").AnyTimes().Return(&apicontainer.DockerContainer{
+		DockerID:  "pause",
+		Container: t1.Containers[0],
+	}, nil)
+	resolver.EXPECT().ResolveContainer(gomock.Any()).AnyTimes().Return(&apicontainer.DockerContainer{
+		Container: &apicontainer.Container{},
+	}, nil)
+	mockDockerClient.EXPECT().Stats(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineAwsvpcTaskNetworkStats"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	engine.ctx = ctx
+	engine.resolver = resolver
+	engine.cluster = defaultCluster
+	engine.containerInstanceArn = defaultContainerInstance
+	engine.client = mockDockerClient
+	for _, dockerID := range []string{"pause", "c1", "c2"} {
+		engine.addAndStartStatsContainer(dockerID)
+	}
+
+	// The pause container isn't reported, the containers of the task share
+	// its network counters
+	require.Len(t, engine.tasksToContainers["t1"], 2)
+	taskNetworkStats := engine.tasksToNetworkStats["t1"]
+	require.NotNil(t, taskNetworkStats)
+	assert.Equal(t, taskNetworkStats, engine.tasksToContainers["t1"]["c1"].taskNetworkStats)
+	assert.Equal(t, taskNetworkStats, engine.tasksToContainers["t1"]["c2"].taskNetworkStats)
+	assert.Equal(t, "pause", taskNetworkStats.reader.pauseDockerID)
+
+	for _, dockerID := range []string{"c1", "c2"} {
+		for _, containerStats := range createFakeContainerStats() {
+			engine.tasksToContainers["t1"][dockerID].statsQueue.add(containerStats)
+		}
+	}
+	for i, containerStats := range createFakeContainerStats() {
+		containerStats.networkStats = &networkStats{rxBytes: uint64(1000 * (i + 1)), txBytes: uint64(500 * (i + 1))}
+		taskNetworkStats.queue.add(containerStats)
+	}
+
+	_, taskMetrics, err := engine.GetInstanceMetrics()
+	require.NoError(t, err)
+	require.Len(t, taskMetrics, 1)
+	require.Len(t, taskMetrics[0].ContainerMetrics, 2)
+	for _, containerMetric := range taskMetrics[0].ContainerMetrics {
+		assert.Nil(t, containerMetric.NetworkStatsSet)
+	}
+	networkStatsSet := taskMetrics[0].NetworkStatsSet
+	require.NotNil(t, networkStatsSet)
+	assert.Equal(t, float64(1000), aws.Float64Value(networkStatsSet.RxBytes.Sum))
+	assert.Equal(t, float64(500), aws.Float64Value(networkStatsSet.TxBytes.Sum))
+
+	engine.removeContainer("c1")
+	engine.removeContainer("c2")
+	assert.Empty(t, engine.tasksToNetworkStats)
+}
+
+func TestRestartInactiveCollectors(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(mockCtrl)
+	mockDockerClient := mock_dockerapi.NewMockDockerClient(mockCtrl)
+	t1 := &apitask.Task{Arn: "t1", Family: "f1"}
+	resolver.EXPECT().ResolveTask(gomock.Any()).AnyTimes().Return(t1, nil)
+	resolver.EXPECT().ResolveContainer(gomock.Any()).AnyTimes().Return(&apicontainer.DockerContainer{
+		Container: &apicontainer.Container{KnownStatusUnsafe: apicontainerstatus.ContainerRunning},
+	}, nil)
+	mockStatsChannel := make(chan *docker.Stats)
+	defer close(mockStatsChannel)
+	mockDockerClient.EXPECT().Stats(gomock.Any(), gomock.Any()).Return(mockStatsChannel, nil).AnyTimes()
+	resolver.EXPECT().RunningContainerIDs().Return([]string{"c1", "c2", "c3"}, nil)
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestRestartInactiveCollectors"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	engine.ctx = ctx
+	engine.resolver = resolver
+	engine.client = mockDockerClient
+	defer engine.removeAll()
+
+	engine.addAndStartStatsContainer("c1")
+	engine.addAndStartStatsContainer("c2")
+	// The collection of c2 stopped while it's still running, and c3 was
+	// never added
+	stoppedCollector := engine.tasksToContainers["t1"]["c2"]
+	stoppedCollector.StopStatsCollection()
+	activeCollector := engine.tasksToContainers["t1"]["c1"]
+
+	engine.restartInactiveCollectors()
+
+	containers := engine.tasksToContainers["t1"]
+	require.Len(t, containers, 3)
+	assert.Equal(t, activeCollector, containers["c1"], "active collections should be left alone")
+	assert.NotEqual(t, stoppedCollector, containers["c2"])
+	for dockerID, statsContainer := range containers {
+		assert.True(t, statsContainer.collecting(), "stats of %s should be collected", dockerID)
+	}
+}
+
+func TestContainerDockerStatsStoppedContainer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(mockCtrl)
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestContainerDockerStatsStoppedContainer"))
+	engine.resolver = resolver
+
+	lastStat := &docker.Stats{NumProcs: 2}
+	container := newStatsContainer("c1", nil, resolver)
+	container.statsQueue = NewQueue(ContainerStatsBufferLength)
+	container.statsQueue.setLastStat(lastStat)
+	engine.tasksToContainers["t1"] = map[string]*StatsContainer{"c1": container}
+
+	dockerStats, stopped, err := engine.ContainerDockerStats("t1", "c1")
+	require.NoError(t, err)
+	assert.False(t, stopped)
+	assert.Equal(t, lastStat, dockerStats)
+
+	engine.doRemoveContainerUnsafe(container, "t1")
+	dockerStats, stopped, err = engine.ContainerDockerStats("t1", "c1")
+	require.NoError(t, err)
+	assert.True(t, stopped, "the last stats of the stopped container should be returned")
+	assert.Equal(t, lastStat, dockerStats)
+	_, _, err = engine.ContainerDockerStats("t1", "c2")
+	assert.Error(t, err)
+
+	// The stats are kept until the container is cleaned up
+	gomock.InOrder(
+		resolver.EXPECT().ResolveContainer("c1").Return(&apicontainer.DockerContainer{}, nil),
+		resolver.EXPECT().ResolveContainer("c1").Return(nil, fmt.Errorf("container not found")),
+	)
+	engine.pruneStoppedContainerStats()
+	_, _, err = engine.ContainerDockerStats("t1", "c1")
+	assert.NoError(t, err)
+	engine.pruneStoppedContainerStats()
+	_, _, err = engine.ContainerDockerStats("t1", "c1")
+	assert.Error(t, err)
+}
+
 func TestStatsEngineInvalidTaskEngine(t *testing.T) {
 	statsEngine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineInvalidTaskEngine"))
 	taskEngine := &MockTaskEngine{}
@@ -286,6 +595,7 @@ func TestGetTaskHealthMetrics(t *testing.T) {
 	defer mockCtrl.Finish()
 
 	containerID := "containerID"
+	lastCheckedAt := time.Now().Add(-time.Second)
 	resolver := mock_resolver.NewMockContainerMetadataResolver(mockCtrl)
 	resolver.EXPECT().ResolveContainer(containerID).Return(&apicontainer.DockerContainer{
 		DockerID: containerID,
@@ -293,8 +603,10 @@ func TestGetTaskHealthMetrics(t *testing.T) {
 			KnownStatusUnsafe: apicontainerstatus.ContainerRunning,
 			HealthCheckType:   "docker",
 			Health: apicontainer.HealthStatus{
-				Status: apicontainerstatus.ContainerHealthy,
-				Since:  aws.Time(time.Now()),
+				Status:        apicontainerstatus.ContainerHealthy,
+				Since:         aws.Time(time.Now()),
+				FailingStreak: 1,
+				LastCheckedAt: aws.Time(lastCheckedAt),
 			},
 		},
 	}, nil).Times(2)
@@ -321,6 +633,8 @@ func TestGetTaskHealthMetrics(t *testing.T) {
 	assert.Len(t, taskHealth, 1)
 	assert.Len(t, taskHealth[0].Containers, 1)
 	assert.Equal(t, aws.StringValue(taskHealth[0].Containers[0].HealthStatus), "HEALTHY")
+	assert.Equal(t, int64(1), aws.Int64Value(taskHealth[0].Containers[0].ConsecutiveFailures))
+	assert.Equal(t, lastCheckedAt.UTC(), aws.TimeValue(taskHealth[0].Containers[0].LastCheckedAt))
 }
 
 func TestGetTaskHealthMetricsStoppedContainer(t *testing.T) {
@@ -360,6 +674,30 @@ func TestGetTaskHealthMetricsStoppedContainer(t *testing.T) {
 	assert.Error(t, err, "empty metrics should cause an error")
 }
 
+type fakeDockerHealth bool
+
+func (reachable fakeDockerHealth) DockerReachable() bool {
+	return bool(reachable)
+}
+
+func TestGetTaskHealthMetricsDockerStatus(t *testing.T) {
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestGetTaskHealthMetricsDockerStatus"))
+
+	metadata, _, err := engine.GetTaskHealthMetrics()
+	require.NoError(t, err)
+	assert.Nil(t, metadata.DockerStatus, "the docker status shouldn't be reported without a source")
+
+	engine.SetDockerHealth(fakeDockerHealth(true))
+	metadata, _, err = engine.GetTaskHealthMetrics()
+	require.NoError(t, err)
+	assert.Equal(t, ecstcs.HealthStatusHealthy, aws.StringValue(metadata.DockerStatus))
+
+	engine.SetDockerHealth(fakeDockerHealth(false))
+	metadata, _, err = engine.GetTaskHealthMetrics()
+	require.NoError(t, err)
+	assert.Equal(t, ecstcs.HealthStatusUnhealthy, aws.StringValue(metadata.DockerStatus))
+}
+
 // TestMetricsDisabled tests container won't call docker api to collect stats
 // but will track container health when metrics is disabled in agent.
 func TestMetricsDisabled(t *testing.T) {
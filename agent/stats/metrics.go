@@ -0,0 +1,123 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/cihub/seelog"
+)
+
+// familyContainer identifies the containers of the same name in the tasks of
+// a task definition family, which are exported as a single time series
+type familyContainer struct {
+	family    string
+	container string
+}
+
+// Metrics returns the latest cpu and memory usage of the containers, summed
+// over the containers of the same name in the tasks of each task definition
+// family. Task arns aren't used as labels, so that the number of time series
+// stays bounded as tasks are replaced
+func (engine *DockerStatsEngine) Metrics() []*metrics.Family {
+	engine.lock.RLock()
+	defer engine.lock.RUnlock()
+
+	tasks := make(map[string]float64)
+	cpuUsage := make(map[familyContainer]float64)
+	memoryUsage := make(map[familyContainer]float64)
+	for taskARN, containerMap := range engine.tasksToContainers {
+		taskDef, ok := engine.tasksToDefinitions[taskARN]
+		if !ok {
+			continue
+		}
+		tasks[taskDef.family]++
+		for dockerID, container := range containerMap {
+			usageStats, err := container.statsQueue.GetRawUsageStats(1)
+			if err != nil {
+				continue
+			}
+			dockerContainer, err := engine.resolver.ResolveContainer(dockerID)
+			if err != nil {
+				seelog.Debugf("Unable to resolve the container %s of the metrics: %v", dockerID, err)
+				continue
+			}
+			key := familyContainer{family: taskDef.family, container: dockerContainer.Container.Name}
+			// The cpu usage is unknown until the second stats of the container
+			if cpu := float64(usageStats[0].CPUUsagePerc); !math.IsNaN(cpu) {
+				cpuUsage[key] += cpu
+			}
+			memoryUsage[key] += float64(usageStats[0].MemoryUsageInMegs) * BytesInMiB
+		}
+	}
+
+	taskFamilies := make([]string, 0, len(tasks))
+	for family := range tasks {
+		taskFamilies = append(taskFamilies, family)
+	}
+	sort.Strings(taskFamilies)
+	taskCount := &metrics.Family{
+		Name: "ecs_agent_tasks",
+		Help: "Number of tasks whose containers are monitored by task definition family.",
+		Type: metrics.GaugeType,
+	}
+	for _, family := range taskFamilies {
+		taskCount.Samples = append(taskCount.Samples, metrics.Sample{
+			Labels: []metrics.Label{{Name: "task_family", Value: family}},
+			Value:  tasks[family],
+		})
+	}
+
+	return []*metrics.Family{
+		taskCount,
+		{
+			Name:    "ecs_agent_container_cpu_usage_percent",
+			Help:    "CPU usage of the containers by task definition family and container name, in percent of a cpu.",
+			Type:    metrics.GaugeType,
+			Samples: containerSamples(cpuUsage),
+		},
+		{
+			Name:    "ecs_agent_container_memory_usage_bytes",
+			Help:    "Memory usage of the containers by task definition family and container name.",
+			Type:    metrics.GaugeType,
+			Samples: containerSamples(memoryUsage),
+		},
+	}
+}
+
+func containerSamples(values map[familyContainer]float64) []metrics.Sample {
+	keys := make([]familyContainer, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].family != keys[j].family {
+			return keys[i].family < keys[j].family
+		}
+		return keys[i].container < keys[j].container
+	})
+	samples := make([]metrics.Sample, 0, len(keys))
+	for _, key := range keys {
+		samples = append(samples, metrics.Sample{
+			Labels: []metrics.Label{
+				{Name: "task_family", Value: key.family},
+				{Name: "container", Value: key.container},
+			},
+			Value: values[key],
+		})
+	}
+	return samples
+}
@@ -0,0 +1,89 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"context"
+	"testing"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	mock_resolver "github.com/aws/amazon-ecs-agent/agent/stats/resolver/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsEngineMetrics(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(mockCtrl)
+	mockDockerClient := mock_dockerapi.NewMockDockerClient(mockCtrl)
+	containers := []struct {
+		dockerID  string
+		task      *apitask.Task
+		container string
+	}{
+		{"c1", &apitask.Task{Arn: "t1", Family: "f1", Version: "1"}, "web"},
+		{"c2", &apitask.Task{Arn: "t2", Family: "f1", Version: "2"}, "web"},
+		{"c3", &apitask.Task{Arn: "t3", Family: "f2", Version: "1"}, "db"},
+	}
+	for _, c := range containers {
+		resolver.EXPECT().ResolveTask(c.dockerID).AnyTimes().Return(c.task, nil)
+		resolver.EXPECT().ResolveContainer(c.dockerID).AnyTimes().Return(&apicontainer.DockerContainer{
+			Container: &apicontainer.Container{Name: c.container},
+		}, nil)
+	}
+	mockDockerClient.EXPECT().Stats(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestStatsEngineMetrics"))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	engine.ctx = ctx
+	engine.resolver = resolver
+	engine.client = mockDockerClient
+	for _, c := range containers {
+		engine.addAndStartStatsContainer(c.dockerID)
+		for _, containerStats := range createFakeContainerStats() {
+			engine.tasksToContainers[c.task.Arn][c.dockerID].statsQueue.add(containerStats)
+		}
+	}
+
+	families := engine.Metrics()
+	require.Len(t, families, 3)
+	assert.Equal(t, []metrics.Sample{
+		{Labels: []metrics.Label{{Name: "task_family", Value: "f1"}}, Value: 2},
+		{Labels: []metrics.Label{{Name: "task_family", Value: "f2"}}, Value: 1},
+	}, families[0].Samples)
+
+	require.Len(t, families[1].Samples, 2)
+	cpu := engine.tasksToContainers["t3"]["c3"].statsQueue.buffer[1].CPUUsagePerc
+	assert.InDelta(t, 2*cpu, families[1].Samples[0].Value, 0.001)
+	assert.InDelta(t, cpu, families[1].Samples[1].Value, 0.001)
+
+	assert.Equal(t, []metrics.Sample{
+		{
+			Labels: []metrics.Label{{Name: "task_family", Value: "f1"}, {Name: "container", Value: "web"}},
+			Value:  2 * 3 * BytesInMiB,
+		},
+		{
+			Labels: []metrics.Label{{Name: "task_family", Value: "f2"}, {Name: "container", Value: "db"}},
+			Value:  3 * BytesInMiB,
+		},
+	}, families[2].Samples)
+}
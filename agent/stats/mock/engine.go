@@ -49,11 +49,12 @@ func (m *MockEngine) EXPECT() *MockEngineMockRecorder {
 }
 
 // ContainerDockerStats mocks base method
-func (m *MockEngine) ContainerDockerStats(arg0, arg1 string) (*go_dockerclient.Stats, error) {
+func (m *MockEngine) ContainerDockerStats(arg0, arg1 string) (*go_dockerclient.Stats, bool, error) {
 	ret := m.ctrl.Call(m, "ContainerDockerStats", arg0, arg1)
 	ret0, _ := ret[0].(*go_dockerclient.Stats)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // ContainerDockerStats indicates an expected call of ContainerDockerStats
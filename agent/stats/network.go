@@ -0,0 +1,199 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/cihub/seelog"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+)
+
+const (
+	// hostProcNetDevFormat is the format of the path of the counters of the
+	// network interfaces of the namespace of a process of the host
+	hostProcNetDevFormat  = "/host/proc/%d/net/dev"
+	loopbackInterfaceName = "lo"
+	// taskNetworkStatsInterval is the minimum time between two reads of the
+	// network counters of an awsvpc task. It's a little shorter than the
+	// interval of docker stats, so that the stats of a single container are
+	// enough to read the counters each time
+	taskNetworkStatsInterval = 900 * time.Millisecond
+)
+
+// networkStats are the counters of the network interfaces of a container,
+// summed across interfaces
+type networkStats struct {
+	rxBytes   uint64
+	rxPackets uint64
+	txBytes   uint64
+	txPackets uint64
+}
+
+// dockerStatsToNetworkStats returns the network counters of the docker stats,
+// or nil if docker didn't report any network interface, as for the containers
+// of the host network mode
+func dockerStatsToNetworkStats(dockerStats *docker.Stats) *networkStats {
+	if len(dockerStats.Networks) == 0 {
+		return nil
+	}
+	stats := &networkStats{}
+	for _, network := range dockerStats.Networks {
+		stats.rxBytes += network.RxBytes
+		stats.rxPackets += network.RxPackets
+		stats.txBytes += network.TxBytes
+		stats.txPackets += network.TxPackets
+	}
+	return stats
+}
+
+// taskNetworkStats collects the network counters of an awsvpc task, which are
+// reported for the task rather than for its containers. Docker doesn't report
+// them for the containers of the task, which share the namespace of the pause
+// container, so they're read once for all the containers as their stats are
+// received
+type taskNetworkStats struct {
+	reader   *taskNetworkStatsReader
+	queue    *Queue
+	lastRead time.Time
+	lock     sync.Mutex
+}
+
+func newTaskNetworkStats(pauseDockerID string, client dockerapi.DockerClient) *taskNetworkStats {
+	queue := NewQueue(ContainerStatsBufferLength)
+	queue.Reset()
+	return &taskNetworkStats{
+		reader: newTaskNetworkStatsReader(pauseDockerID, client),
+		queue:  queue,
+	}
+}
+
+// sample adds the network counters of the task to its queue, unless they were
+// read less than taskNetworkStatsInterval ago for another container
+func (stats *taskNetworkStats) sample(ctx context.Context, now time.Time) {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	if now.Sub(stats.lastRead) < taskNetworkStatsInterval {
+		return
+	}
+	stats.lastRead = now
+	networks, err := stats.reader.read(ctx)
+	if err != nil {
+		seelog.Debugf("Error reading network stats: %v", err)
+		return
+	}
+	stats.queue.add(&ContainerStats{
+		timestamp:    now,
+		networkStats: dockerStatsToNetworkStats(&docker.Stats{Networks: networks}),
+	})
+}
+
+// taskNetworkStatsReader reads the counters of the network interfaces of the
+// namespace of the pause container of an awsvpc task
+type taskNetworkStatsReader struct {
+	pauseDockerID    string
+	client           dockerapi.DockerClient
+	procNetDevFormat string
+	// pid is the pid of the pause container once it's inspected. It's
+	// guarded by the lock of the taskNetworkStats reading it
+	pid int
+}
+
+func newTaskNetworkStatsReader(pauseDockerID string, client dockerapi.DockerClient) *taskNetworkStatsReader {
+	return &taskNetworkStatsReader{
+		pauseDockerID:    pauseDockerID,
+		client:           client,
+		procNetDevFormat: hostProcNetDevFormat,
+	}
+}
+
+// read returns the counters of the interfaces of the namespace, except for the
+// loopback interface, keyed by interface name like docker stats
+func (reader *taskNetworkStatsReader) read(ctx context.Context) (map[string]docker.NetworkStats, error) {
+	if reader.pid == 0 {
+		pauseContainer, err := reader.client.InspectContainer(ctx, reader.pauseDockerID, dockerclient.InspectContainerTimeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "stats: unable to inspect pause container %s", reader.pauseDockerID)
+		}
+		if pauseContainer.State.Pid == 0 {
+			return nil, errors.Errorf("stats: pause container %s is not running", reader.pauseDockerID)
+		}
+		reader.pid = pauseContainer.State.Pid
+	}
+	file, err := os.Open(fmt.Sprintf(reader.procNetDevFormat, reader.pid))
+	if err != nil {
+		// The pause container may have been restarted with another pid
+		reader.pid = 0
+		return nil, errors.Wrapf(err, "stats: unable to read the network counters of pause container %s", reader.pauseDockerID)
+	}
+	defer file.Close()
+	return parseProcNetDev(file)
+}
+
+// parseProcNetDev parses the counters of the network interfaces in the format
+// of /proc/net/dev, skipping the loopback interface
+func parseProcNetDev(r io.Reader) (map[string]docker.NetworkStats, error) {
+	networks := make(map[string]docker.NetworkStats)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.Index(line, ":")
+		if i == -1 {
+			// Header lines
+			continue
+		}
+		name := strings.TrimSpace(line[:i])
+		if name == loopbackInterfaceName {
+			continue
+		}
+		fields := strings.Fields(line[i+1:])
+		if len(fields) < 16 {
+			return nil, errors.Errorf("stats: invalid network counters of interface %s: %q", name, line)
+		}
+		var counters [16]uint64
+		for j := range counters {
+			value, err := strconv.ParseUint(fields[j], 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "stats: invalid network counters of interface %s", name)
+			}
+			counters[j] = value
+		}
+		networks[name] = docker.NetworkStats{
+			RxBytes:   counters[0],
+			RxPackets: counters[1],
+			RxErrors:  counters[2],
+			RxDropped: counters[3],
+			TxBytes:   counters[8],
+			TxPackets: counters[9],
+			TxErrors:  counters[10],
+			TxDropped: counters[11],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
@@ -0,0 +1,124 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
+	"github.com/aws/aws-sdk-go/aws"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const procNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0:    2000      20    1    2    0     0          0         0      500       5    3    4    0     0       0          0
+  eth1:     300       3    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+`
+
+func TestDockerStatsToNetworkStats(t *testing.T) {
+	dockerStats := &docker.Stats{
+		Networks: map[string]docker.NetworkStats{
+			"eth0": {RxBytes: 2000, RxPackets: 20, TxBytes: 500, TxPackets: 5},
+			"eth1": {RxBytes: 300, RxPackets: 3, TxBytes: 100, TxPackets: 1},
+		},
+	}
+	assert.Equal(t, &networkStats{rxBytes: 2300, rxPackets: 23, txBytes: 600, txPackets: 6},
+		dockerStatsToNetworkStats(dockerStats))
+	assert.Nil(t, dockerStatsToNetworkStats(&docker.Stats{}))
+}
+
+func TestParseProcNetDev(t *testing.T) {
+	networks, err := parseProcNetDev(strings.NewReader(procNetDev))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]docker.NetworkStats{
+		"eth0": {
+			RxBytes: 2000, RxPackets: 20, RxErrors: 1, RxDropped: 2,
+			TxBytes: 500, TxPackets: 5, TxErrors: 3, TxDropped: 4,
+		},
+		"eth1": {RxBytes: 300, RxPackets: 3, TxBytes: 100, TxPackets: 1},
+	}, networks)
+}
+
+func TestParseProcNetDevInvalidCounters(t *testing.T) {
+	_, err := parseProcNetDev(strings.NewReader("  eth0: 2000 20 1 2\n"))
+	assert.Error(t, err)
+}
+
+func TestTaskNetworkStatsReaderRead(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	procDir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(procDir)
+	require.NoError(t, os.MkdirAll(filepath.Join(procDir, "42", "net"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(procDir, "42", "net", "dev"), []byte(procNetDev), 0644))
+
+	reader := newTaskNetworkStatsReader("pause", client)
+	reader.procNetDevFormat = filepath.Join(procDir, "%d", "net", "dev")
+	// The pause container is only inspected once
+	client.EXPECT().InspectContainer(gomock.Any(), "pause", dockerclient.InspectContainerTimeout).Return(
+		&docker.Container{State: docker.State{Pid: 42}}, nil)
+
+	for i := 0; i < 2; i++ {
+		networks, err := reader.read(context.TODO())
+		require.NoError(t, err)
+		assert.Len(t, networks, 2)
+	}
+}
+
+func TestTaskNetworkStatsSample(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	procDir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(procDir)
+	require.NoError(t, os.MkdirAll(filepath.Join(procDir, "42", "net"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(procDir, "42", "net", "dev"), []byte(procNetDev), 0644))
+
+	stats := newTaskNetworkStats("pause", client)
+	stats.reader.procNetDevFormat = filepath.Join(procDir, "%d", "net", "dev")
+	client.EXPECT().InspectContainer(gomock.Any(), "pause", dockerclient.InspectContainerTimeout).Return(
+		&docker.Container{State: docker.State{Pid: 42}}, nil)
+
+	// The counters are read once for the stats of the containers of the task
+	// received at the same time
+	now := time.Now()
+	stats.sample(context.TODO(), now)
+	stats.sample(context.TODO(), now.Add(100*time.Millisecond))
+	assert.Len(t, stats.queue.buffer, 1)
+
+	stats.sample(context.TODO(), now.Add(time.Second))
+	assert.Len(t, stats.queue.buffer, 2)
+	networkStatsSet, err := stats.queue.GetNetworkStatsSet()
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), aws.Float64Value(networkStatsSet.RxBytes.Sum))
+}
@@ -0,0 +1,60 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// pullStats aggregates the durations of the image pulls of the containers of
+// a task, in milliseconds. Containers using the cached image are counted as
+// pulls that took no time
+type pullStats struct {
+	min       float64
+	max       float64
+	sum       float64
+	count     int64
+	cacheHits int64
+}
+
+// record adds the pull of a container to the aggregated stats
+func (stats *pullStats) record(duration time.Duration, cacheHit bool) {
+	durationMs := float64(duration) / float64(time.Millisecond)
+	if stats.count == 0 {
+		stats.min = durationMs
+		stats.max = durationMs
+	} else {
+		stats.min = math.Min(stats.min, durationMs)
+		stats.max = math.Max(stats.max, durationMs)
+	}
+	stats.sum += durationMs
+	stats.count++
+	if cacheHit {
+		stats.cacheHits++
+	}
+}
+
+// statsSet returns the aggregated pull durations
+func (stats *pullStats) statsSet() *ecstcs.CWStatsSet {
+	return &ecstcs.CWStatsSet{
+		Min:         aws.Float64(stats.min),
+		Max:         aws.Float64(stats.max),
+		Sum:         aws.Float64(stats.sum),
+		SampleCount: aws.Int64(stats.count),
+	}
+}
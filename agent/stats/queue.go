@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/cihub/seelog"
 	docker "github.com/fsouza/go-dockerclient"
 )
@@ -80,10 +81,28 @@ func (queue *Queue) add(rawStat *ContainerStats) {
 
 	queueLength := len(queue.buffer)
 	stat := UsageStats{
-		CPUUsagePerc:      float32(nan32()),
-		MemoryUsageInMegs: uint32(rawStat.memoryUsage / BytesInMiB),
-		Timestamp:         rawStat.timestamp,
-		cpuUsage:          rawStat.cpuUsage,
+		CPUUsagePerc:           float32(nan32()),
+		MemoryUsageInMegs:      uint32(rawStat.memoryUsage / BytesInMiB),
+		Timestamp:              rawStat.timestamp,
+		cpuUsage:               rawStat.cpuUsage,
+		memoryUtilization:      math.NaN(),
+		memoryWorkingSetInMegs: float64(rawStat.memoryWorkingSet) / float64(BytesInMiB),
+		cpuThrottling:          rawStat.cpuThrottling,
+		throttledPeriods:       math.NaN(),
+		throttledTimeInMs:      math.NaN(),
+		networkStats:           rawStat.networkStats,
+		rxBytes:                math.NaN(),
+		rxPackets:              math.NaN(),
+		txBytes:                math.NaN(),
+		txPackets:              math.NaN(),
+		storageStats:           rawStat.storageStats,
+		readBytes:              math.NaN(),
+		readOps:                math.NaN(),
+		writeBytes:             math.NaN(),
+		writeOps:               math.NaN(),
+	}
+	if rawStat.memoryLimit != 0 {
+		stat.memoryUtilization = 100 * float64(rawStat.memoryUsage) / float64(rawStat.memoryLimit)
 	}
 	if queueLength != 0 {
 		// % utilization can be calculated only when queue is non-empty.
@@ -98,6 +117,23 @@ func (queue *Queue) add(rawStat *ContainerStats) {
 			// float32(1) / float32(0) = +Inf
 			seelog.Debugf("time since last stat is zero. Ignoring cpu stat")
 		}
+		if rawStat.cpuThrottling != nil && lastStat.cpuThrottling != nil {
+			stat.throttledPeriods = counterIncrease(lastStat.cpuThrottling.throttledPeriods, rawStat.cpuThrottling.throttledPeriods)
+			stat.throttledTimeInMs = counterIncrease(lastStat.cpuThrottling.throttledTime, rawStat.cpuThrottling.throttledTime) /
+				float64(time.Millisecond)
+		}
+		if rawStat.networkStats != nil && lastStat.networkStats != nil {
+			stat.rxBytes = counterIncrease(lastStat.networkStats.rxBytes, rawStat.networkStats.rxBytes)
+			stat.rxPackets = counterIncrease(lastStat.networkStats.rxPackets, rawStat.networkStats.rxPackets)
+			stat.txBytes = counterIncrease(lastStat.networkStats.txBytes, rawStat.networkStats.txBytes)
+			stat.txPackets = counterIncrease(lastStat.networkStats.txPackets, rawStat.networkStats.txPackets)
+		}
+		if rawStat.storageStats != nil && lastStat.storageStats != nil {
+			stat.readBytes = counterIncrease(lastStat.storageStats.readBytes, rawStat.storageStats.readBytes)
+			stat.readOps = counterIncrease(lastStat.storageStats.readOps, rawStat.storageStats.readOps)
+			stat.writeBytes = counterIncrease(lastStat.storageStats.writeBytes, rawStat.storageStats.writeBytes)
+			stat.writeOps = counterIncrease(lastStat.storageStats.writeOps, rawStat.storageStats.writeOps)
+		}
 		if queue.maxSize == queueLength {
 			// Remove first element if queue is full.
 			queue.buffer = queue.buffer[1:queueLength]
@@ -125,6 +161,112 @@ func (queue *Queue) GetMemoryStatsSet() (*ecstcs.CWStatsSet, error) {
 	return queue.getCWStatsSet(getMemoryUsagePerc)
 }
 
+// GetMemoryWorkingSetStatsSet gets the stats set for the memory usage without
+// the page cache that can be reclaimed, in MiB.
+func (queue *Queue) GetMemoryWorkingSetStatsSet() (*ecstcs.CWStatsSet, error) {
+	return queue.getCWStatsSet(getMemoryWorkingSet)
+}
+
+// GetCPUThrottlingStatsSet gets the stats sets for the cpu throttling between
+// consecutive stats. It returns an error if the throttling of the container is
+// unknown
+func (queue *Queue) GetCPUThrottlingStatsSet() (*ecstcs.CpuThrottlingStatsSet, error) {
+	throttledPeriods, err := queue.getCWStatsSet(getThrottledPeriods)
+	if err != nil {
+		return nil, err
+	}
+	if aws.Int64Value(throttledPeriods.SampleCount) == 0 {
+		return nil, fmt.Errorf("No cpu throttling in the queue")
+	}
+	throttledTime, err := queue.getCWStatsSet(getThrottledTime)
+	if err != nil {
+		return nil, err
+	}
+	return &ecstcs.CpuThrottlingStatsSet{
+		ThrottledPeriods: throttledPeriods,
+		ThrottledTime:    throttledTime,
+	}, nil
+}
+
+// GetMemoryUtilizationStatsSet gets the stats set for the percentage of the
+// memory limit of the container in use. It returns an error if the limit is
+// unknown
+func (queue *Queue) GetMemoryUtilizationStatsSet() (*ecstcs.CWStatsSet, error) {
+	statsSet, err := queue.getCWStatsSet(getMemoryUtilization)
+	if err != nil {
+		return nil, err
+	}
+	if aws.Int64Value(statsSet.SampleCount) == 0 {
+		return nil, fmt.Errorf("No memory utilization in the queue")
+	}
+	return statsSet, nil
+}
+
+// GetNetworkStatsSet gets the stats sets for the network traffic between
+// consecutive stats. It returns an error if the network counters of the
+// container are unknown
+func (queue *Queue) GetNetworkStatsSet() (*ecstcs.NetworkStatsSet, error) {
+	rxBytes, err := queue.getCWStatsSet(getRxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if aws.Int64Value(rxBytes.SampleCount) == 0 {
+		return nil, fmt.Errorf("No network stats in the queue")
+	}
+	rxPackets, err := queue.getCWStatsSet(getRxPackets)
+	if err != nil {
+		return nil, err
+	}
+	txBytes, err := queue.getCWStatsSet(getTxBytes)
+	if err != nil {
+		return nil, err
+	}
+	txPackets, err := queue.getCWStatsSet(getTxPackets)
+	if err != nil {
+		return nil, err
+	}
+	return &ecstcs.NetworkStatsSet{
+		RxBytes:   rxBytes,
+		RxPackets: rxPackets,
+		TxBytes:   txBytes,
+		TxPackets: txPackets,
+	}, nil
+}
+
+// GetStorageStatsSet gets the stats sets for the block I/O between consecutive
+// stats. The stats sets are zero rather than empty if no I/O was measured, so
+// that containers without I/O report it explicitly
+func (queue *Queue) GetStorageStatsSet() (*ecstcs.StorageStatsSet, error) {
+	readBytes, err := queue.getCWStatsSet(getReadBytes)
+	if err != nil {
+		return nil, err
+	}
+	readOps, err := queue.getCWStatsSet(getReadOps)
+	if err != nil {
+		return nil, err
+	}
+	writeBytes, err := queue.getCWStatsSet(getWriteBytes)
+	if err != nil {
+		return nil, err
+	}
+	writeOps, err := queue.getCWStatsSet(getWriteOps)
+	if err != nil {
+		return nil, err
+	}
+	for _, statsSet := range []*ecstcs.CWStatsSet{readBytes, readOps, writeBytes, writeOps} {
+		if aws.Int64Value(statsSet.SampleCount) == 0 {
+			statsSet.Min = aws.Float64(0)
+			statsSet.Max = aws.Float64(0)
+		}
+	}
+	return &ecstcs.StorageStatsSet{
+		ReadBytes:  readBytes,
+		ReadOps:    readOps,
+		WriteBytes: writeBytes,
+		WriteOps:   writeOps,
+	}, nil
+}
+
 // GetRawUsageStats gets the array of most recent raw UsageStats, in descending
 // order of timestamps.
 func (queue *Queue) GetRawUsageStats(numStats int) ([]UsageStats, error) {
@@ -162,6 +304,64 @@ func getMemoryUsagePerc(s *UsageStats) float64 {
 	return float64(s.MemoryUsageInMegs)
 }
 
+func getMemoryWorkingSet(s *UsageStats) float64 {
+	return s.memoryWorkingSetInMegs
+}
+
+func getThrottledPeriods(s *UsageStats) float64 {
+	return s.throttledPeriods
+}
+
+func getThrottledTime(s *UsageStats) float64 {
+	return s.throttledTimeInMs
+}
+
+func getMemoryUtilization(s *UsageStats) float64 {
+	return s.memoryUtilization
+}
+
+func getRxBytes(s *UsageStats) float64 {
+	return s.rxBytes
+}
+
+func getRxPackets(s *UsageStats) float64 {
+	return s.rxPackets
+}
+
+func getTxBytes(s *UsageStats) float64 {
+	return s.txBytes
+}
+
+func getTxPackets(s *UsageStats) float64 {
+	return s.txPackets
+}
+
+func getReadBytes(s *UsageStats) float64 {
+	return s.readBytes
+}
+
+func getReadOps(s *UsageStats) float64 {
+	return s.readOps
+}
+
+func getWriteBytes(s *UsageStats) float64 {
+	return s.writeBytes
+}
+
+func getWriteOps(s *UsageStats) float64 {
+	return s.writeOps
+}
+
+// counterIncrease returns the increase of a network counter between two stats.
+// It's NaN if the counter decreased, which happens when it wraps or when the
+// container restarts, rather than a negative increase
+func counterIncrease(previous uint64, current uint64) float64 {
+	if current < previous {
+		return math.NaN()
+	}
+	return float64(current - previous)
+}
+
 type getUsageFunc func(*UsageStats) float64
 
 func (queue *Queue) resetThresholdElapsed(timeout time.Duration) bool {
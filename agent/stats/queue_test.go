@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/stretchr/testify/assert"
 )
@@ -363,3 +364,132 @@ func TestEnoughDatapointsInBuffer(t *testing.T) {
 	enoughDataPoints = queue.enoughDatapointsInBuffer()
 	assert.False(t, enoughDataPoints, "Queue is expected to not have enough data points right after RESET")
 }
+
+func TestGetNetworkStatsSet(t *testing.T) {
+	timestamps := getTimestamps()[:4]
+	networks := []*networkStats{
+		{rxBytes: 1000, rxPackets: 10, txBytes: 500, txPackets: 5},
+		{rxBytes: 3000, rxPackets: 30, txBytes: 600, txPackets: 6},
+		// The counters of the container restarted
+		{rxBytes: 100, rxPackets: 1, txBytes: 50, txPackets: 1},
+		{rxBytes: 1100, rxPackets: 11, txBytes: 350, txPackets: 4},
+	}
+
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.add(&ContainerStats{cpuUsage: uint64(i), timestamp: timestamp, networkStats: networks[i]})
+	}
+	networkStatsSet, err := queue.GetNetworkStatsSet()
+	assert.NoError(t, err)
+
+	assertCWStatsSet := func(name string, statsSet *ecstcs.CWStatsSet, min float64, max float64, sum float64) {
+		assert.Equal(t, int64(2), aws.Int64Value(statsSet.SampleCount), name)
+		assert.Equal(t, min, aws.Float64Value(statsSet.Min), name)
+		assert.Equal(t, max, aws.Float64Value(statsSet.Max), name)
+		assert.Equal(t, sum, aws.Float64Value(statsSet.Sum), name)
+	}
+	assertCWStatsSet("rxBytes", networkStatsSet.RxBytes, 1000, 2000, 3000)
+	assertCWStatsSet("rxPackets", networkStatsSet.RxPackets, 10, 20, 30)
+	assertCWStatsSet("txBytes", networkStatsSet.TxBytes, 100, 300, 400)
+	assertCWStatsSet("txPackets", networkStatsSet.TxPackets, 1, 3, 4)
+}
+
+func TestGetNetworkStatsSetWithoutNetworkStats(t *testing.T) {
+	timestamps := getTimestamps()[:3]
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.add(&ContainerStats{cpuUsage: uint64(i), timestamp: timestamp})
+	}
+	_, err := queue.GetNetworkStatsSet()
+	assert.Error(t, err)
+}
+
+func TestGetStorageStatsSet(t *testing.T) {
+	timestamps := getTimestamps()[:3]
+	storage := []*storageStats{
+		{readBytes: 4096, readOps: 1, writeBytes: 8192, writeOps: 2},
+		{readBytes: 4096, readOps: 1, writeBytes: 16384, writeOps: 4},
+		{readBytes: 12288, readOps: 3, writeBytes: 16384, writeOps: 4},
+	}
+
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.add(&ContainerStats{cpuUsage: uint64(i), timestamp: timestamp, storageStats: storage[i]})
+	}
+	storageStatsSet, err := queue.GetStorageStatsSet()
+	assert.NoError(t, err)
+
+	assert.Equal(t, float64(8192), aws.Float64Value(storageStatsSet.ReadBytes.Sum))
+	assert.Equal(t, float64(0), aws.Float64Value(storageStatsSet.ReadBytes.Min))
+	assert.Equal(t, float64(8192), aws.Float64Value(storageStatsSet.ReadBytes.Max))
+	assert.Equal(t, float64(2), aws.Float64Value(storageStatsSet.ReadOps.Sum))
+	assert.Equal(t, float64(8192), aws.Float64Value(storageStatsSet.WriteBytes.Sum))
+	assert.Equal(t, float64(2), aws.Float64Value(storageStatsSet.WriteOps.Sum))
+	assert.Equal(t, int64(2), aws.Int64Value(storageStatsSet.WriteOps.SampleCount))
+}
+
+func TestGetStorageStatsSetWithoutIO(t *testing.T) {
+	timestamps := getTimestamps()[:3]
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.add(&ContainerStats{cpuUsage: uint64(i), timestamp: timestamp})
+	}
+	storageStatsSet, err := queue.GetStorageStatsSet()
+	assert.NoError(t, err)
+
+	// Containers without I/O report zeros rather than omitting the stats
+	for _, statsSet := range []*ecstcs.CWStatsSet{storageStatsSet.ReadBytes, storageStatsSet.ReadOps,
+		storageStatsSet.WriteBytes, storageStatsSet.WriteOps} {
+		assert.Equal(t, float64(0), aws.Float64Value(statsSet.Min))
+		assert.Equal(t, float64(0), aws.Float64Value(statsSet.Max))
+		assert.Equal(t, float64(0), aws.Float64Value(statsSet.Sum))
+	}
+}
+
+func TestGetCPUThrottlingStatsSet(t *testing.T) {
+	timestamps := getTimestamps()[:3]
+	throttling := []*cpuThrottlingStats{
+		{throttledPeriods: 10, throttledTime: 100 * uint64(time.Millisecond)},
+		{throttledPeriods: 15, throttledTime: 350 * uint64(time.Millisecond)},
+		{throttledPeriods: 15, throttledTime: 350 * uint64(time.Millisecond)},
+	}
+
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.add(&ContainerStats{cpuUsage: uint64(i), timestamp: timestamp, cpuThrottling: throttling[i]})
+	}
+	cpuThrottlingStatsSet, err := queue.GetCPUThrottlingStatsSet()
+	assert.NoError(t, err)
+
+	assert.Equal(t, float64(5), aws.Float64Value(cpuThrottlingStatsSet.ThrottledPeriods.Sum))
+	assert.Equal(t, float64(0), aws.Float64Value(cpuThrottlingStatsSet.ThrottledPeriods.Min))
+	assert.Equal(t, int64(2), aws.Int64Value(cpuThrottlingStatsSet.ThrottledPeriods.SampleCount))
+	assert.Equal(t, float64(250), aws.Float64Value(cpuThrottlingStatsSet.ThrottledTime.Max))
+	assert.Equal(t, float64(250), aws.Float64Value(cpuThrottlingStatsSet.ThrottledTime.Sum))
+}
+
+func TestGetCPUThrottlingStatsSetWithoutThrottling(t *testing.T) {
+	timestamps := getTimestamps()[:3]
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.add(&ContainerStats{cpuUsage: uint64(i), timestamp: timestamp})
+	}
+	_, err := queue.GetCPUThrottlingStatsSet()
+	assert.Error(t, err)
+}
+
+func TestGetMemoryWorkingSetStatsSet(t *testing.T) {
+	timestamps := getTimestamps()[:2]
+	workingSets := []uint64{10 * BytesInMiB, 30 * BytesInMiB}
+
+	queue := NewQueue(len(timestamps))
+	for i, timestamp := range timestamps {
+		queue.add(&ContainerStats{timestamp: timestamp, memoryWorkingSet: workingSets[i]})
+	}
+	workingSetStatsSet, err := queue.GetMemoryWorkingSetStatsSet()
+	assert.NoError(t, err)
+
+	assert.Equal(t, float64(10), aws.Float64Value(workingSetStatsSet.Min))
+	assert.Equal(t, float64(30), aws.Float64Value(workingSetStatsSet.Max))
+	assert.Equal(t, float64(40), aws.Float64Value(workingSetStatsSet.Sum))
+}
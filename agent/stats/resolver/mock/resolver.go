@@ -73,3 +73,16 @@ func (m *MockContainerMetadataResolver) ResolveTask(arg0 string) (*task.Task, er
 func (mr *MockContainerMetadataResolverMockRecorder) ResolveTask(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveTask", reflect.TypeOf((*MockContainerMetadataResolver)(nil).ResolveTask), arg0)
 }
+
+// RunningContainerIDs mocks base method
+func (m *MockContainerMetadataResolver) RunningContainerIDs() ([]string, error) {
+	ret := m.ctrl.Call(m, "RunningContainerIDs")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RunningContainerIDs indicates an expected call of RunningContainerIDs
+func (mr *MockContainerMetadataResolverMockRecorder) RunningContainerIDs() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunningContainerIDs", reflect.TypeOf((*MockContainerMetadataResolver)(nil).RunningContainerIDs))
+}
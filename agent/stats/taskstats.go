@@ -0,0 +1,124 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"math"
+
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// containerUsage is the usage of a container since the metrics were last
+// reported, which is aggregated into the usage of its task
+type containerUsage struct {
+	cpu    *ecstcs.CWStatsSet
+	memory *ecstcs.CWStatsSet
+	// memoryUtilization is nil if the memory limit of the container is unknown
+	memoryUtilization *ecstcs.CWStatsSet
+}
+
+// newContainerUsage returns the usage of the container in the stats queue
+func newContainerUsage(queue *Queue) (*containerUsage, error) {
+	cpu, err := queue.GetCPUStatsSet()
+	if err != nil {
+		return nil, err
+	}
+	memory, err := queue.GetMemoryStatsSet()
+	if err != nil {
+		return nil, err
+	}
+	usage := &containerUsage{cpu: cpu, memory: memory}
+	if memoryUtilization, err := queue.GetMemoryUtilizationStatsSet(); err == nil {
+		usage.memoryUtilization = memoryUtilization
+	}
+	return usage, nil
+}
+
+// taskStatsSet aggregates the usage of the containers of a task. The usage of
+// the containers is summed, while the utilization of their limits is the
+// largest utilization of a container
+func taskStatsSet(usages []*containerUsage) *ecstcs.TaskStatsSet {
+	var cpu, memory, memoryUtilization []*ecstcs.CWStatsSet
+	for _, usage := range usages {
+		cpu = append(cpu, usage.cpu)
+		memory = append(memory, usage.memory)
+		if usage.memoryUtilization != nil {
+			memoryUtilization = append(memoryUtilization, usage.memoryUtilization)
+		}
+	}
+	statsSet := &ecstcs.TaskStatsSet{
+		CpuStatsSet:    aggregateStatsSets(cpu, sumAggregation),
+		MemoryStatsSet: aggregateStatsSets(memory, sumAggregation),
+	}
+	if len(memoryUtilization) != 0 {
+		statsSet.MemoryUtilizationStatsSet = aggregateStatsSets(memoryUtilization, maxAggregation)
+	}
+	return statsSet
+}
+
+// aggregation combines the values of the stats sets of the containers
+type aggregation func(values []float64) float64
+
+func sumAggregation(values []float64) float64 {
+	sum := float64(0)
+	for _, value := range values {
+		sum += value
+	}
+	return sum
+}
+
+func maxAggregation(values []float64) float64 {
+	max := -math.MaxFloat64
+	for _, value := range values {
+		max = math.Max(max, value)
+	}
+	return max
+}
+
+// aggregateStatsSets combines the stats sets of the containers of a task. The
+// samples of the containers aren't taken at the same time, so the minimums,
+// maximums and averages of the containers are combined. The sample count is
+// the largest sample count of the containers, and the sum is the combined
+// average over that many samples. Stats sets without samples are ignored
+func aggregateStatsSets(statsSets []*ecstcs.CWStatsSet, aggregate aggregation) *ecstcs.CWStatsSet {
+	var mins, maxs, averages []float64
+	sampleCount := int64(0)
+	for _, statsSet := range statsSets {
+		count := aws.Int64Value(statsSet.SampleCount)
+		if count == 0 {
+			continue
+		}
+		mins = append(mins, aws.Float64Value(statsSet.Min))
+		maxs = append(maxs, aws.Float64Value(statsSet.Max))
+		averages = append(averages, aws.Float64Value(statsSet.Sum)/float64(count))
+		if count > sampleCount {
+			sampleCount = count
+		}
+	}
+	if sampleCount == 0 {
+		return &ecstcs.CWStatsSet{
+			Min:         aws.Float64(0),
+			Max:         aws.Float64(0),
+			Sum:         aws.Float64(0),
+			SampleCount: aws.Int64(0),
+		}
+	}
+	return &ecstcs.CWStatsSet{
+		Min:         aws.Float64(aggregate(mins)),
+		Max:         aws.Float64(aggregate(maxs)),
+		Sum:         aws.Float64(aggregate(averages) * float64(sampleCount)),
+		SampleCount: aws.Int64(sampleCount),
+	}
+}
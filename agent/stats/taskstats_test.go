@@ -0,0 +1,85 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func statsSet(min float64, max float64, sum float64, sampleCount int64) *ecstcs.CWStatsSet {
+	return &ecstcs.CWStatsSet{
+		Min:         aws.Float64(min),
+		Max:         aws.Float64(max),
+		Sum:         aws.Float64(sum),
+		SampleCount: aws.Int64(sampleCount),
+	}
+}
+
+func TestTaskStatsSet(t *testing.T) {
+	usages := []*containerUsage{
+		{
+			cpu:               statsSet(10, 30, 80, 4),
+			memory:            statsSet(100, 200, 600, 4),
+			memoryUtilization: statsSet(40, 80, 240, 4),
+		},
+		{
+			// A container that stopped after two samples
+			cpu:    statsSet(5, 15, 20, 2),
+			memory: statsSet(50, 150, 200, 2),
+		},
+	}
+
+	taskStatsSet := taskStatsSet(usages)
+	// The averages of the containers are summed over the largest sample count
+	assert.Equal(t, statsSet(15, 45, 120, 4), taskStatsSet.CpuStatsSet)
+	assert.Equal(t, statsSet(150, 350, 1000, 4), taskStatsSet.MemoryStatsSet)
+	assert.Equal(t, statsSet(40, 80, 240, 4), taskStatsSet.MemoryUtilizationStatsSet)
+}
+
+func TestTaskStatsSetMaxUtilization(t *testing.T) {
+	usages := []*containerUsage{
+		{
+			cpu:               statsSet(0, 0, 0, 1),
+			memory:            statsSet(0, 0, 0, 1),
+			memoryUtilization: statsSet(40, 80, 240, 4),
+		},
+		{
+			cpu:               statsSet(0, 0, 0, 1),
+			memory:            statsSet(0, 0, 0, 1),
+			memoryUtilization: statsSet(70, 90, 160, 2),
+		},
+	}
+
+	assert.Equal(t, statsSet(70, 90, 320, 4), taskStatsSet(usages).MemoryUtilizationStatsSet)
+}
+
+func TestTaskStatsSetWithoutSamples(t *testing.T) {
+	usages := []*containerUsage{
+		{
+			cpu:    statsSet(0, 0, 0, 0),
+			memory: statsSet(100, 200, 600, 4),
+		},
+	}
+
+	taskStatsSet := taskStatsSet(usages)
+	assert.Equal(t, statsSet(0, 0, 0, 0), taskStatsSet.CpuStatsSet)
+	assert.Equal(t, statsSet(100, 200, 600, 4), taskStatsSet.MemoryStatsSet)
+	assert.Nil(t, taskStatsSet.MemoryUtilizationStatsSet)
+}
@@ -0,0 +1,23 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+// cpuThrottlingStats are the counters of the periods in which the container
+// used up its cpu quota and was throttled
+type cpuThrottlingStats struct {
+	throttledPeriods uint64
+	// throttledTime is the time the container was throttled for, in
+	// nanoseconds
+	throttledTime uint64
+}
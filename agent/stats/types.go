@@ -26,7 +26,17 @@ import (
 type ContainerStats struct {
 	cpuUsage    uint64
 	memoryUsage uint64
-	timestamp   time.Time
+	// memoryLimit is zero if the memory limit of the container is unknown
+	memoryLimit uint64
+	// memoryWorkingSet is the memory usage without the page cache that can
+	// be reclaimed
+	memoryWorkingSet uint64
+	timestamp        time.Time
+	// cpuThrottling is nil if the throttling of the container is unknown
+	cpuThrottling *cpuThrottlingStats
+	// networkStats is nil if the network counters of the container are unknown
+	networkStats *networkStats
+	storageStats *storageStats
 }
 
 // UsageStats abstracts the format in which the queue stores data.
@@ -35,6 +45,31 @@ type UsageStats struct {
 	MemoryUsageInMegs uint32    `json:"memoryUsageInMegs"`
 	Timestamp         time.Time `json:"timestamp"`
 	cpuUsage          uint64
+	// memoryUtilization is the percentage of the memory limit of the
+	// container in use, NaN if the limit is unknown
+	memoryUtilization float64
+	// memoryWorkingSetInMegs is the memory usage without the page cache that
+	// can be reclaimed
+	memoryWorkingSetInMegs float64
+	cpuThrottling          *cpuThrottlingStats
+	// throttledPeriods and throttledTimeInMs are the cpu throttling since the
+	// previous stats, NaN if it's unknown
+	throttledPeriods  float64
+	throttledTimeInMs float64
+	networkStats      *networkStats
+	// rxBytes, rxPackets, txBytes and txPackets are the network traffic since
+	// the previous stats, NaN if it's unknown
+	rxBytes      float64
+	rxPackets    float64
+	txBytes      float64
+	txPackets    float64
+	storageStats *storageStats
+	// readBytes, readOps, writeBytes and writeOps are the block I/O since the
+	// previous stats, NaN if it's unknown
+	readBytes  float64
+	readOps    float64
+	writeBytes float64
+	writeOps   float64
 }
 
 // ContainerMetadata contains meta-data information for a container.
@@ -50,6 +85,9 @@ type StatsContainer struct {
 	client            dockerapi.DockerClient
 	statsQueue        *Queue
 	resolver          resolver.ContainerMetadataResolver
+	// taskNetworkStats collects the network counters of the awsvpc task of
+	// the container, which docker doesn't report
+	taskNetworkStats *taskNetworkStats
 }
 
 // taskDefinition encapsulates family and version strings for a task definition
@@ -16,6 +16,7 @@ package stats
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/cihub/seelog"
 	docker "github.com/fsouza/go-dockerclient"
@@ -32,8 +33,58 @@ func dockerStatsToContainerStats(dockerStats *docker.Stats) (*ContainerStats, er
 	cpuUsage := dockerStats.CPUStats.CPUUsage.TotalUsage / numCores
 	memoryUsage := dockerStats.MemoryStats.Usage - dockerStats.MemoryStats.Stats.Cache
 	return &ContainerStats{
-		cpuUsage:    cpuUsage,
-		memoryUsage: memoryUsage,
-		timestamp:   dockerStats.Read,
+		memoryWorkingSet: memoryWorkingSet(dockerStats),
+		cpuThrottling: &cpuThrottlingStats{
+			throttledPeriods: dockerStats.CPUStats.ThrottlingData.ThrottledPeriods,
+			throttledTime:    dockerStats.CPUStats.ThrottlingData.ThrottledTime,
+		},
+		cpuUsage:     cpuUsage,
+		memoryUsage:  memoryUsage,
+		memoryLimit:  dockerStats.MemoryStats.Limit,
+		timestamp:    dockerStats.Read,
+		networkStats: dockerStatsToNetworkStats(dockerStats),
+		storageStats: dockerStatsToStorageStats(dockerStats),
 	}, nil
 }
+
+// memoryWorkingSet returns the memory usage of the docker stats without the
+// inactive file cache, which the kernel reclaims before the container runs out
+// of memory
+func memoryWorkingSet(dockerStats *docker.Stats) uint64 {
+	// cgroup v1 reports the inactive file cache of the hierarchy of the
+	// cgroup as total_inactive_file, cgroup v2 as inactive_file
+	inactiveFile := dockerStats.MemoryStats.Stats.TotalInactiveFile
+	if inactiveFile == 0 {
+		inactiveFile = dockerStats.MemoryStats.Stats.InactiveFile
+	}
+	if inactiveFile > dockerStats.MemoryStats.Usage {
+		return 0
+	}
+	return dockerStats.MemoryStats.Usage - inactiveFile
+}
+
+// dockerStatsToStorageStats returns the block I/O counters of the docker stats,
+// summed across the devices the container accessed, including the devices
+// passed through to it. The counters are zero if the container didn't do any
+// I/O
+func dockerStatsToStorageStats(dockerStats *docker.Stats) *storageStats {
+	stats := &storageStats{}
+	for _, entry := range dockerStats.BlkioStats.IOServiceBytesRecursive {
+		// cgroup v1 reports capitalized operations, cgroup v2 lowercase ones
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.readBytes += entry.Value
+		case "write":
+			stats.writeBytes += entry.Value
+		}
+	}
+	for _, entry := range dockerStats.BlkioStats.IOServicedRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.readOps += entry.Value
+		case "write":
+			stats.writeOps += entry.Value
+		}
+	}
+	return stats
+}
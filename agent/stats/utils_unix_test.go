@@ -65,3 +65,79 @@ func TestDockerStatsToContainerStatsCpuUsage(t *testing.T) {
 	require.NotNil(t, containerStats, "containerStats should not be nil")
 	assert.Equal(t, uint64(25), containerStats.cpuUsage, "unexpected value for cpuUsage", containerStats.cpuUsage)
 }
+
+func TestDockerStatsToStorageStats(t *testing.T) {
+	// The stats of a container accessing two devices, reported by cgroup v1
+	// for the first one and cgroup v2 for the second one
+	jsonStat := `
+		{
+			"blkio_stats":{
+				"io_service_bytes_recursive":[
+					{"major":202,"minor":0,"op":"Read","value":4096},
+					{"major":202,"minor":0,"op":"Write","value":8192},
+					{"major":202,"minor":0,"op":"Total","value":12288},
+					{"major":259,"minor":1,"op":"read","value":1024},
+					{"major":259,"minor":1,"op":"write","value":2048}
+				],
+				"io_serviced_recursive":[
+					{"major":202,"minor":0,"op":"Read","value":1},
+					{"major":202,"minor":0,"op":"Write","value":2},
+					{"major":202,"minor":0,"op":"Total","value":3},
+					{"major":259,"minor":1,"op":"read","value":1},
+					{"major":259,"minor":1,"op":"write","value":1}
+				]
+			}
+		}`
+	dockerStat := &docker.Stats{}
+	require.NoError(t, json.Unmarshal([]byte(jsonStat), dockerStat))
+	assert.Equal(t, &storageStats{readBytes: 5120, readOps: 2, writeBytes: 10240, writeOps: 3},
+		dockerStatsToStorageStats(dockerStat))
+
+	// Containers without I/O have zero counters
+	assert.Equal(t, &storageStats{}, dockerStatsToStorageStats(&docker.Stats{}))
+}
+
+func TestDockerStatsToContainerStatsThrottlingAndWorkingSet(t *testing.T) {
+	// The stats of a container reported by docker with cgroup v1
+	jsonStat := `
+		{
+			"cpu_stats":{
+				"cpu_usage":{
+					"percpu_usage":[1, 2],
+					"total_usage":3
+				},
+				"throttling_data":{
+					"periods":120,
+					"throttled_periods":45,
+					"throttled_time":3200000000
+				}
+			},
+			"memory_stats":{
+				"usage":104857600,
+				"limit":536870912,
+				"stats":{
+					"cache":41943040,
+					"inactive_file":10485760,
+					"total_cache":41943040,
+					"total_inactive_file":31457280
+				}
+			}
+		}`
+	numCores = 2
+	dockerStat := &docker.Stats{}
+	require.NoError(t, json.Unmarshal([]byte(jsonStat), dockerStat))
+	containerStats, err := dockerStatsToContainerStats(dockerStat)
+	require.NoError(t, err)
+
+	assert.Equal(t, &cpuThrottlingStats{throttledPeriods: 45, throttledTime: 3200000000}, containerStats.cpuThrottling)
+	// The inactive file cache of the hierarchy of the cgroup is reclaimable
+	assert.Equal(t, uint64(73400320), containerStats.memoryWorkingSet)
+	assert.Equal(t, uint64(536870912), containerStats.memoryLimit)
+}
+
+func TestMemoryWorkingSetInactiveFileAboveUsage(t *testing.T) {
+	dockerStat := &docker.Stats{}
+	dockerStat.MemoryStats.Usage = 1024
+	dockerStat.MemoryStats.Stats.InactiveFile = 2048
+	assert.Equal(t, uint64(0), memoryWorkingSet(dockerStat))
+}
@@ -30,9 +30,26 @@ func dockerStatsToContainerStats(dockerStats *docker.Stats) (*ContainerStats, er
 
 	cpuUsage := (dockerStats.CPUStats.CPUUsage.TotalUsage * 100) / numCores
 	memoryUsage := dockerStats.MemoryStats.PrivateWorkingSet
+	// Windows doesn't report the throttling of containers, and the private
+	// working set doesn't include the file cache
 	return &ContainerStats{
-		cpuUsage:    cpuUsage,
-		memoryUsage: memoryUsage,
-		timestamp:   dockerStats.Read,
+		memoryWorkingSet: memoryUsage,
+		cpuUsage:         cpuUsage,
+		memoryUsage:      memoryUsage,
+		memoryLimit:      dockerStats.MemoryStats.Limit,
+		timestamp:        dockerStats.Read,
+		networkStats:     dockerStatsToNetworkStats(dockerStats),
+		storageStats:     dockerStatsToStorageStats(dockerStats),
 	}, nil
 }
+
+// dockerStatsToStorageStats returns the storage counters of the docker stats.
+// The counters are zero if the container didn't do any I/O
+func dockerStatsToStorageStats(dockerStats *docker.Stats) *storageStats {
+	return &storageStats{
+		readBytes:  dockerStats.StorageStats.ReadSizeBytes,
+		readOps:    dockerStats.StorageStats.ReadCountNormalized,
+		writeBytes: dockerStats.StorageStats.WriteSizeBytes,
+		writeOps:   dockerStats.StorageStats.WriteCountNormalized,
+	}
+}
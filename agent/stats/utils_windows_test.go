@@ -62,3 +62,19 @@ func TestDockerStatsToContainerStatsCpuUsage(t *testing.T) {
 	require.NotNil(t, containerStats, "containerStats should not be nil")
 	assert.Equal(t, uint64(2500), containerStats.cpuUsage, "unexpected value for cpuUsage", containerStats.cpuUsage)
 }
+
+func TestDockerStatsToStorageStats(t *testing.T) {
+	jsonStat := `
+		{
+			"storage_stats":{
+				"read_count_normalized":1,
+				"read_size_bytes":4096,
+				"write_count_normalized":2,
+				"write_size_bytes":8192
+			}
+		}`
+	dockerStat := &docker.Stats{}
+	require.NoError(t, json.Unmarshal([]byte(jsonStat), dockerStat))
+	assert.Equal(t, &storageStats{readBytes: 4096, readOps: 1, writeBytes: 8192, writeOps: 2},
+		dockerStatsToStorageStats(dockerStat))
+}
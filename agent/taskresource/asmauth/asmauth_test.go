@@ -73,14 +73,12 @@ func TestCreateAndGet(t *testing.T) {
 	mockASMClient := mock_secretsmanageriface.NewMockSecretsManagerAPI(ctrl)
 
 	iamRoleCreds := credentials.IAMRoleCredentials{}
-	creds := credentials.TaskIAMRoleCredentials{
-		IAMRoleCredentials: iamRoleCreds,
-	}
 	asmSecretValue := &secretsmanager.GetSecretValueOutput{
 		SecretString: aws.String(asmAuthDataVal),
 	}
 	gomock.InOrder(
-		credentialsManager.EXPECT().GetTaskCredentials(executionCredentialsID).Return(creds, true),
+		credentialsManager.EXPECT().GetTaskCredentials(executionCredentialsID).Return(
+			credentials.TaskIAMRoleCredentials{IAMRoleCredentials: iamRoleCreds}, true),
 		asmClientCreator.EXPECT().NewASMClient(region, iamRoleCreds).Return(mockASMClient),
 		mockASMClient.EXPECT().GetSecretValue(gomock.Any()).Do(func(in *secretsmanager.GetSecretValueInput) {
 			assert.Equal(t, aws.StringValue(in.SecretId), secretID)
@@ -99,6 +97,54 @@ func TestCreateAndGet(t *testing.T) {
 	assert.Equal(t, dac.Password, password)
 }
 
+func TestCreateInvalidSecretValue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	credentialsManager := mock_credentials.NewMockManager(ctrl)
+	asmClientCreator := mock_factory.NewMockClientCreator(ctrl)
+	mockASMClient := mock_secretsmanageriface.NewMockSecretsManagerAPI(ctrl)
+
+	iamRoleCreds := credentials.IAMRoleCredentials{}
+	// The secret is missing the username
+	asmSecretValue := &secretsmanager.GetSecretValueOutput{
+		SecretString: aws.String(`{"password":"` + password + `"}`),
+	}
+	gomock.InOrder(
+		credentialsManager.EXPECT().GetTaskCredentials(executionCredentialsID).Return(
+			credentials.TaskIAMRoleCredentials{IAMRoleCredentials: iamRoleCreds}, true),
+		asmClientCreator.EXPECT().NewASMClient(region, iamRoleCreds).Return(mockASMClient),
+		mockASMClient.EXPECT().GetSecretValue(gomock.Any()).Return(asmSecretValue, nil),
+	)
+	asmRes := &ASMAuthResource{
+		executionCredentialsID: executionCredentialsID,
+		requiredASMResources:   requiredASMResources,
+		credentialsManager:     credentialsManager,
+		asmClientCreator:       asmClientCreator,
+	}
+	require.Error(t, asmRes.Create())
+	_, ok := asmRes.GetASMDockerAuthConfig(secretID)
+	assert.False(t, ok)
+	assert.Contains(t, asmRes.GetTerminalReason(), secretID)
+	assert.NotContains(t, asmRes.GetTerminalReason(), password)
+}
+
+func TestCreateWithoutExecutionCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	credentialsManager := mock_credentials.NewMockManager(ctrl)
+	credentialsManager.EXPECT().GetTaskCredentials(executionCredentialsID).Return(
+		credentials.TaskIAMRoleCredentials{}, false)
+	asmRes := &ASMAuthResource{
+		executionCredentialsID: executionCredentialsID,
+		requiredASMResources:   requiredASMResources,
+		credentialsManager:     credentialsManager,
+	}
+	require.Error(t, asmRes.Create())
+	assert.Contains(t, asmRes.GetTerminalReason(), secretID)
+}
+
 func TestMarshalUnmarshalJSON(t *testing.T) {
 	asmResIn := &ASMAuthResource{
 		taskARN:                taskARN,
@@ -39,6 +39,7 @@ const (
 	rootReadOnlyPermissions   = os.FileMode(400)
 	resourceName              = "cgroup"
 	resourceProvisioningError = "CgroupError: Agent could not create task's platform resources"
+	memoryCgroupMissingError  = "CgroupError: memory cgroup is not mounted on the instance, task memory limits can't be enforced"
 )
 
 var (
@@ -62,6 +63,9 @@ type CgroupResource struct {
 	// used while progressing resource states in progressTask() of task manager
 	appliedStatus       resourcestatus.ResourceStatus
 	statusToTransitions map[resourcestatus.ResourceStatus]func() error
+	// terminalReason is the reason the cgroup couldn't be created, when it's
+	// more specific than the default reason
+	terminalReason string
 	// lock is used for fields that are accessed and updated concurrently
 	lock sync.RWMutex
 }
@@ -88,11 +92,24 @@ func NewCgroupResource(taskARN string,
 // GetTerminalReason returns an error string to propagate up through to task
 // state change messages
 func (cgroup *CgroupResource) GetTerminalReason() string {
+	cgroup.lock.RLock()
+	defer cgroup.lock.RUnlock()
+
+	if cgroup.terminalReason != "" {
+		return cgroup.terminalReason
+	}
 	// for cgroups we can send up a static string because this is an
 	// implementation detail and unrelated to customer resources
 	return resourceProvisioningError
 }
 
+func (cgroup *CgroupResource) setTerminalReason(reason string) {
+	cgroup.lock.Lock()
+	defer cgroup.lock.Unlock()
+
+	cgroup.terminalReason = reason
+}
+
 func (cgroup *CgroupResource) initializeResourceStatusToTransitionFunction() {
 	resourceStatusToTransitionFunction := map[resourcestatus.ResourceStatus]func() error{
 		resourcestatus.ResourceStatus(CgroupCreated): cgroup.Create,
@@ -259,6 +276,13 @@ func (cgroup *CgroupResource) setupTaskCgroup() error {
 		return nil
 	}
 
+	// Without the memory cgroup the cgroup would be created without enforcing
+	// the task's memory limits
+	if !cgroup.control.SubsystemMounted(cgroups.Memory) {
+		cgroup.setTerminalReason(memoryCgroupMissingError)
+		return errors.Errorf("cgroup resource [%s]: setup cgroup: memory cgroup is not mounted", cgroup.taskARN)
+	}
+
 	cgroupSpec := control.Spec{
 		Root:  cgroupRoot,
 		Specs: &cgroup.resourceSpec,
@@ -53,6 +53,7 @@ func TestCreateHappyPath(t *testing.T) {
 
 	gomock.InOrder(
 		mockControl.EXPECT().Exists(gomock.Any()).Return(false),
+		mockControl.EXPECT().SubsystemMounted(cgroups.Memory).Return(true),
 		mockControl.EXPECT().Create(gomock.Any()).Return(nil, nil),
 		mockIO.EXPECT().WriteFile(cgroupMemoryPath, gomock.Any(), gomock.Any()).Return(nil),
 	)
@@ -89,6 +90,7 @@ func TestCreateCgroupError(t *testing.T) {
 
 	gomock.InOrder(
 		mockControl.EXPECT().Exists(gomock.Any()).Return(false),
+		mockControl.EXPECT().SubsystemMounted(cgroups.Memory).Return(true),
 		mockControl.EXPECT().Create(gomock.Any()).Return(mockCgroup, errors.New("cgroup create error")),
 	)
 
@@ -96,6 +98,26 @@ func TestCreateCgroupError(t *testing.T) {
 	assert.Error(t, cgroupResource.Create())
 }
 
+func TestCreateMemoryCgroupNotMounted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockControl := mock_control.NewMockControl(ctrl)
+	mockIO := mock_ioutilwrapper.NewMockIOUtil(ctrl)
+
+	cgroupRoot := fmt.Sprintf("/ecs/%s", taskID)
+
+	gomock.InOrder(
+		mockControl.EXPECT().Exists(gomock.Any()).Return(false),
+		mockControl.EXPECT().SubsystemMounted(cgroups.Memory).Return(false),
+	)
+
+	cgroupResource := NewCgroupResource("taskArn", mockControl, mockIO, cgroupRoot, cgroupMountPath, specs.LinuxResources{})
+	assert.Equal(t, resourceProvisioningError, cgroupResource.GetTerminalReason())
+	assert.Error(t, cgroupResource.Create())
+	assert.Equal(t, memoryCgroupMissingError, cgroupResource.GetTerminalReason())
+}
+
 func TestCleanupHappyPath(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
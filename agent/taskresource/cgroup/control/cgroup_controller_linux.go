@@ -88,6 +88,23 @@ func (c *control) Exists(cgroupPath string) bool {
 	return true
 }
 
+// SubsystemMounted is used to verify that a cgroup subsystem is mounted, without
+// which cgroups don't enforce the limits of the subsystem
+func (c *control) SubsystemMounted(subsystem cgroups.Name) bool {
+	subsystems, err := c.Subsystems()
+	if err != nil {
+		seelog.Warnf("Unable to list the mounted cgroup subsystems: %v", err)
+		return false
+	}
+
+	for _, mounted := range subsystems {
+		if mounted.Name() == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
 // validateCgroupSpec checks the cgroup spec for valid path and specifications
 func validateCgroupSpec(cgroupSpec *Spec) error {
 	if cgroupSpec == nil {
@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/cgroup/control/factory/mock"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/cgroup/control/factory/mock_factory"
+	"github.com/containerd/cgroups"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 
@@ -180,3 +181,38 @@ func TestExistsErrorPathWithLoadError(t *testing.T) {
 
 	assert.False(t, control.Exists(testCgroupRoot))
 }
+
+// testSubsystem is a cgroup subsystem that's only named
+type testSubsystem cgroups.Name
+
+func (s testSubsystem) Name() cgroups.Name {
+	return cgroups.Name(s)
+}
+
+func TestSubsystemMounted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCgroupFactory := mock_factory.NewMockCgroupFactory(ctrl)
+	mockCgroupFactory.EXPECT().Subsystems().Return([]cgroups.Subsystem{
+		testSubsystem(cgroups.Cpu),
+		testSubsystem(cgroups.Memory),
+	}, nil).Times(2)
+
+	control := newControl(mockCgroupFactory)
+
+	assert.True(t, control.SubsystemMounted(cgroups.Memory))
+	assert.False(t, control.SubsystemMounted(cgroups.Pids))
+}
+
+func TestSubsystemMountedErrorPath(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCgroupFactory := mock_factory.NewMockCgroupFactory(ctrl)
+	mockCgroupFactory.EXPECT().Subsystems().Return(nil, errors.New("cgroups error"))
+
+	control := newControl(mockCgroupFactory)
+
+	assert.False(t, control.SubsystemMounted(cgroups.Memory))
+}
@@ -26,6 +26,8 @@ type CgroupFactory interface {
 	New(hierarchy cgroups.Hierarchy, path cgroups.Path, specs *specs.LinuxResources) (cgroups.Cgroup, error)
 	// Load is used to load the cgroup based off the cgroup path
 	Load(hierarchy cgroups.Hierarchy, path cgroups.Path) (cgroups.Cgroup, error)
+	// Subsystems is used to list the cgroup subsystems mounted on the instance
+	Subsystems() ([]cgroups.Subsystem, error)
 }
 
 // GlobalCgroupFactory calls the cgroups library global functions
@@ -40,3 +42,8 @@ func (c *GlobalCgroupFactory) Load(hierarchy cgroups.Hierarchy, path cgroups.Pat
 func (c *GlobalCgroupFactory) New(hierarchy cgroups.Hierarchy, path cgroups.Path, specs *specs.LinuxResources) (cgroups.Cgroup, error) {
 	return cgroups.New(hierarchy, path, specs)
 }
+
+// Subsystems is used to list the cgroup subsystems mounted on the instance
+func (c *GlobalCgroupFactory) Subsystems() ([]cgroups.Subsystem, error) {
+	return cgroups.V1()
+}
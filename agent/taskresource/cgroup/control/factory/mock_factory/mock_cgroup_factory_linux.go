@@ -73,3 +73,16 @@ func (m *MockCgroupFactory) New(arg0 cgroups.Hierarchy, arg1 cgroups.Path, arg2
 func (mr *MockCgroupFactoryMockRecorder) New(arg0, arg1, arg2 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "New", reflect.TypeOf((*MockCgroupFactory)(nil).New), arg0, arg1, arg2)
 }
+
+// Subsystems mocks base method
+func (m *MockCgroupFactory) Subsystems() ([]cgroups.Subsystem, error) {
+	ret := m.ctrl.Call(m, "Subsystems")
+	ret0, _ := ret[0].([]cgroups.Subsystem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subsystems indicates an expected call of Subsystems
+func (mr *MockCgroupFactoryMockRecorder) Subsystems() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subsystems", reflect.TypeOf((*MockCgroupFactory)(nil).Subsystems))
+}
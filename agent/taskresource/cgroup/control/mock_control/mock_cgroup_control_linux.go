@@ -96,3 +96,15 @@ func (m *MockControl) Remove(arg0 string) error {
 func (mr *MockControlMockRecorder) Remove(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockControl)(nil).Remove), arg0)
 }
+
+// SubsystemMounted mocks base method
+func (m *MockControl) SubsystemMounted(arg0 cgroups.Name) bool {
+	ret := m.ctrl.Call(m, "SubsystemMounted", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SubsystemMounted indicates an expected call of SubsystemMounted
+func (mr *MockControlMockRecorder) SubsystemMounted(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubsystemMounted", reflect.TypeOf((*MockControl)(nil).SubsystemMounted), arg0)
+}
@@ -33,5 +33,6 @@ type Control interface {
 	Create(cgroupSpec *Spec) (cgroups.Cgroup, error)
 	Remove(cgroupPath string) error
 	Exists(cgroupPath string) bool
+	SubsystemMounted(subsystem cgroups.Name) bool
 	Init() error
 }
@@ -0,0 +1,334 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/aws/amazon-ecs-agent/agent/taskresource"
+	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ResourceName is the name of the docker network resource
+	ResourceName = "dockerNetwork"
+)
+
+const resourceProvisioningError = "NetworkError: Agent could not create task's network resources"
+
+// NetworkResource represents the docker bridge network created for a task, on
+// which its containers reach each other by their container names
+type NetworkResource struct {
+	taskARN string
+	// networkName is the name of the docker network
+	networkName string
+	// networkIDUnsafe is the id docker assigned the network on its creation
+	networkIDUnsafe     string
+	createdAtUnsafe     time.Time
+	desiredStatusUnsafe resourcestatus.ResourceStatus
+	knownStatusUnsafe   resourcestatus.ResourceStatus
+	// appliedStatusUnsafe is the status that has been "applied" (e.g., we've called some
+	// operation such as 'Create' on the resource) but we don't yet know that the
+	// application was successful, which may then change the known status. This is
+	// used while progressing resource states in progressTask() of task manager
+	appliedStatusUnsafe resourcestatus.ResourceStatus
+	statusToTransitions map[resourcestatus.ResourceStatus]func() error
+	client              dockerapi.DockerClient
+	ctx                 context.Context
+	// lock is used for fields that are accessed and updated concurrently
+	lock sync.RWMutex
+}
+
+// NewNetworkResource returns a docker network resource of the task
+func NewNetworkResource(ctx context.Context,
+	taskARN string,
+	networkName string,
+	client dockerapi.DockerClient) *NetworkResource {
+
+	n := &NetworkResource{
+		taskARN:     taskARN,
+		networkName: networkName,
+		client:      client,
+		ctx:         ctx,
+	}
+	n.initStatusToTransitions()
+	return n
+}
+
+// Initialize initializes the fields of the resource that aren't saved in the
+// state file
+func (n *NetworkResource) Initialize(resourceFields *taskresource.ResourceFields,
+	taskKnownStatus status.TaskStatus,
+	taskDesiredStatus status.TaskStatus) {
+
+	n.ctx = resourceFields.Ctx
+	n.client = resourceFields.DockerClient
+	n.initStatusToTransitions()
+}
+
+func (n *NetworkResource) initStatusToTransitions() {
+	statusToTransitions := map[resourcestatus.ResourceStatus]func() error{
+		resourcestatus.ResourceStatus(NetworkCreated): n.Create,
+	}
+
+	n.statusToTransitions = statusToTransitions
+}
+
+// GetName returns the name of the network resource
+func (n *NetworkResource) GetName() string {
+	return ResourceName
+}
+
+// GetNetworkName returns the name of the docker network
+func (n *NetworkResource) GetNetworkName() string {
+	return n.networkName
+}
+
+// GetNetworkID returns the id of the docker network. It's empty until the
+// network is created
+func (n *NetworkResource) GetNetworkID() string {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.networkIDUnsafe
+}
+
+func (n *NetworkResource) setNetworkID(networkID string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.networkIDUnsafe = networkID
+}
+
+// DesiredTerminal returns true if the network's desired status is REMOVED
+func (n *NetworkResource) DesiredTerminal() bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.desiredStatusUnsafe == resourcestatus.ResourceStatus(NetworkRemoved)
+}
+
+// GetTerminalReason returns an error string to propagate up through to task
+// state change messages
+func (n *NetworkResource) GetTerminalReason() string {
+	return resourceProvisioningError
+}
+
+// SetDesiredStatus safely sets the desired status of the resource
+func (n *NetworkResource) SetDesiredStatus(status resourcestatus.ResourceStatus) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.desiredStatusUnsafe = status
+}
+
+// GetDesiredStatus safely returns the desired status of the resource
+func (n *NetworkResource) GetDesiredStatus() resourcestatus.ResourceStatus {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.desiredStatusUnsafe
+}
+
+// SetKnownStatus safely sets the currently known status of the resource
+func (n *NetworkResource) SetKnownStatus(status resourcestatus.ResourceStatus) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.knownStatusUnsafe = status
+}
+
+// GetKnownStatus safely returns the currently known status of the resource
+func (n *NetworkResource) GetKnownStatus() resourcestatus.ResourceStatus {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.knownStatusUnsafe
+}
+
+// KnownCreated returns true if the network's known status is CREATED
+func (n *NetworkResource) KnownCreated() bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.knownStatusUnsafe == resourcestatus.ResourceStatus(NetworkCreated)
+}
+
+// TerminalStatus returns the last transition state of network
+func (n *NetworkResource) TerminalStatus() resourcestatus.ResourceStatus {
+	return resourcestatus.ResourceStatus(NetworkRemoved)
+}
+
+// NextKnownState returns the state that the resource should
+// progress to based on its `KnownState`.
+func (n *NetworkResource) NextKnownState() resourcestatus.ResourceStatus {
+	return n.GetKnownStatus() + 1
+}
+
+// SteadyState returns the transition state of the resource defined as "ready"
+func (n *NetworkResource) SteadyState() resourcestatus.ResourceStatus {
+	return resourcestatus.ResourceStatus(NetworkCreated)
+}
+
+// ApplyTransition calls the function required to move to the specified status
+func (n *NetworkResource) ApplyTransition(nextState resourcestatus.ResourceStatus) error {
+	transitionFunc, ok := n.statusToTransitions[nextState]
+	if !ok {
+		return errors.Errorf("network [%s]: transition to %s impossible", n.networkName,
+			n.StatusString(nextState))
+	}
+	return transitionFunc()
+}
+
+// SetAppliedStatus sets the applied status of resource and returns whether
+// the resource is already in a transition
+func (n *NetworkResource) SetAppliedStatus(status resourcestatus.ResourceStatus) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.appliedStatusUnsafe != resourcestatus.ResourceStatus(NetworkStatusNone) {
+		// return false to indicate the set operation failed
+		return false
+	}
+
+	n.appliedStatusUnsafe = status
+	return true
+}
+
+// StatusString returns the string of the network resource status
+func (n *NetworkResource) StatusString(status resourcestatus.ResourceStatus) string {
+	return NetworkStatus(status).String()
+}
+
+// SetCreatedAt sets the timestamp for resource's creation time
+func (n *NetworkResource) SetCreatedAt(createdAt time.Time) {
+	if createdAt.IsZero() {
+		return
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.createdAtUnsafe = createdAt
+}
+
+// GetCreatedAt sets the timestamp for resource's creation time
+func (n *NetworkResource) GetCreatedAt() time.Time {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.createdAtUnsafe
+}
+
+// Create performs resource creation
+func (n *NetworkResource) Create() error {
+	seelog.Debugf("Creating network with name %s for task %s", n.networkName, n.taskARN)
+	networkResponse := n.client.CreateNetwork(n.ctx, n.networkName,
+		map[string]string{taskresource.TaskARNLabel: n.taskARN}, dockerapi.CreateNetworkTimeout)
+	if networkResponse.Error != nil {
+		// The network may have been created before the agent restarted, in
+		// which case it's reused
+		existing := n.client.InspectNetwork(n.ctx, n.networkName, dockerapi.InspectNetworkTimeout)
+		if existing.Error != nil || existing.DockerNetwork.Labels[taskresource.TaskARNLabel] != n.taskARN {
+			return networkResponse.Error
+		}
+		networkResponse = existing
+	}
+
+	n.setNetworkID(networkResponse.DockerNetwork.ID)
+	return nil
+}
+
+// Cleanup performs resource cleanup. Containers of the task that couldn't be
+// removed are still connected to the network, which docker refuses to remove
+// until they're disconnected
+func (n *NetworkResource) Cleanup() error {
+	networkID := n.GetNetworkID()
+	if networkID == "" {
+		seelog.Debugf("Network [%s] was not created, not removing", n.networkName)
+		return nil
+	}
+
+	seelog.Debugf("Removing network with name %s", n.networkName)
+	err := n.client.RemoveNetwork(n.ctx, networkID, dockerapi.RemoveNetworkTimeout)
+	if err == nil {
+		return nil
+	}
+
+	networkResponse := n.client.InspectNetwork(n.ctx, networkID, dockerapi.InspectNetworkTimeout)
+	if networkResponse.Error != nil {
+		return err
+	}
+	for dockerID := range networkResponse.DockerNetwork.Containers {
+		seelog.Infof("Disconnecting container %s from network [%s] in order to remove it", dockerID, n.networkName)
+		if disconnectErr := n.client.DisconnectNetwork(n.ctx, networkID, dockerID,
+			dockerapi.ConnectNetworkTimeout); disconnectErr != nil {
+			seelog.Warnf("Unable to disconnect container %s from network [%s]: %v",
+				dockerID, n.networkName, disconnectErr)
+		}
+	}
+	return n.client.RemoveNetwork(n.ctx, networkID, dockerapi.RemoveNetworkTimeout)
+}
+
+// networkResourceJSON duplicates NetworkResource fields, only for marshalling and unmarshalling purposes
+type networkResourceJSON struct {
+	TaskARN       string         `json:"taskARN"`
+	NetworkName   string         `json:"networkName"`
+	NetworkID     string         `json:"networkID"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	DesiredStatus *NetworkStatus `json:"desiredStatus"`
+	KnownStatus   *NetworkStatus `json:"knownStatus"`
+}
+
+// MarshalJSON marshals NetworkResource object using duplicate struct networkResourceJSON
+func (n *NetworkResource) MarshalJSON() ([]byte, error) {
+	if n == nil {
+		return nil, nil
+	}
+	return json.Marshal(networkResourceJSON{
+		n.taskARN,
+		n.networkName,
+		n.GetNetworkID(),
+		n.GetCreatedAt(),
+		func() *NetworkStatus { desiredState := NetworkStatus(n.GetDesiredStatus()); return &desiredState }(),
+		func() *NetworkStatus { knownState := NetworkStatus(n.GetKnownStatus()); return &knownState }(),
+	})
+}
+
+// UnmarshalJSON unmarshals NetworkResource object using duplicate struct networkResourceJSON
+func (n *NetworkResource) UnmarshalJSON(b []byte) error {
+	temp := &networkResourceJSON{}
+
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+
+	n.taskARN = temp.TaskARN
+	n.networkName = temp.NetworkName
+	n.setNetworkID(temp.NetworkID)
+	n.SetCreatedAt(temp.CreatedAt)
+	if temp.DesiredStatus != nil {
+		n.SetDesiredStatus(resourcestatus.ResourceStatus(*temp.DesiredStatus))
+	}
+	if temp.KnownStatus != nil {
+		n.SetKnownStatus(resourcestatus.ResourceStatus(*temp.KnownStatus))
+	}
+	return nil
+}
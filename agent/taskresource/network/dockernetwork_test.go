@@ -0,0 +1,177 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/taskresource"
+	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	taskARN     = "arn:aws:ecs:us-west-2:123456789012:task/cluster/task-id"
+	networkName = "ecs-task-task-id"
+	networkID   = "networkID"
+)
+
+func TestCreateSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	mockClient.EXPECT().CreateNetwork(gomock.Any(), networkName, map[string]string{taskresource.TaskARNLabel: taskARN},
+		dockerapi.CreateNetworkTimeout).Return(dockerapi.NetworkResponse{
+		DockerNetwork: &docker.Network{ID: networkID, Name: networkName},
+	})
+
+	network := NewNetworkResource(context.TODO(), taskARN, networkName, mockClient)
+	err := network.Create()
+	assert.NoError(t, err)
+	assert.Equal(t, networkID, network.GetNetworkID())
+}
+
+func TestCreateReusesNetworkOfTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	gomock.InOrder(
+		mockClient.EXPECT().CreateNetwork(gomock.Any(), networkName, gomock.Any(), gomock.Any()).Return(
+			dockerapi.NetworkResponse{Error: errors.New("network with name already exists")}),
+		mockClient.EXPECT().InspectNetwork(gomock.Any(), networkName, dockerapi.InspectNetworkTimeout).Return(
+			dockerapi.NetworkResponse{DockerNetwork: &docker.Network{
+				ID:     networkID,
+				Labels: map[string]string{taskresource.TaskARNLabel: taskARN},
+			}}),
+	)
+
+	network := NewNetworkResource(context.TODO(), taskARN, networkName, mockClient)
+	err := network.Create()
+	assert.NoError(t, err)
+	assert.Equal(t, networkID, network.GetNetworkID())
+}
+
+func TestCreateError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	createErr := errors.New("some error")
+	gomock.InOrder(
+		mockClient.EXPECT().CreateNetwork(gomock.Any(), networkName, gomock.Any(), gomock.Any()).Return(
+			dockerapi.NetworkResponse{Error: createErr}),
+		// A network of another task with the same name isn't reused
+		mockClient.EXPECT().InspectNetwork(gomock.Any(), networkName, gomock.Any()).Return(
+			dockerapi.NetworkResponse{DockerNetwork: &docker.Network{
+				ID:     networkID,
+				Labels: map[string]string{taskresource.TaskARNLabel: "another-task"},
+			}}),
+	)
+
+	network := NewNetworkResource(context.TODO(), taskARN, networkName, mockClient)
+	err := network.Create()
+	assert.Equal(t, createErr, err)
+	assert.Empty(t, network.GetNetworkID())
+}
+
+func TestCleanupSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	mockClient.EXPECT().RemoveNetwork(gomock.Any(), networkID, dockerapi.RemoveNetworkTimeout).Return(nil)
+
+	network := NewNetworkResource(context.TODO(), taskARN, networkName, mockClient)
+	network.setNetworkID(networkID)
+	assert.NoError(t, network.Cleanup())
+}
+
+func TestCleanupNetworkNotCreated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	network := NewNetworkResource(context.TODO(), taskARN, networkName, mockClient)
+	assert.NoError(t, network.Cleanup())
+}
+
+func TestCleanupDisconnectsRemainingEndpoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	gomock.InOrder(
+		mockClient.EXPECT().RemoveNetwork(gomock.Any(), networkID, gomock.Any()).Return(
+			errors.New("network has active endpoints")),
+		mockClient.EXPECT().InspectNetwork(gomock.Any(), networkID, gomock.Any()).Return(
+			dockerapi.NetworkResponse{DockerNetwork: &docker.Network{
+				ID:         networkID,
+				Containers: map[string]docker.Endpoint{"dockerID": {}},
+			}}),
+		mockClient.EXPECT().DisconnectNetwork(gomock.Any(), networkID, "dockerID",
+			dockerapi.ConnectNetworkTimeout).Return(nil),
+		mockClient.EXPECT().RemoveNetwork(gomock.Any(), networkID, gomock.Any()).Return(nil),
+	)
+
+	network := NewNetworkResource(context.TODO(), taskARN, networkName, mockClient)
+	network.setNetworkID(networkID)
+	assert.NoError(t, network.Cleanup())
+}
+
+func TestCleanupInspectError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	removeErr := errors.New("network has active endpoints")
+	gomock.InOrder(
+		mockClient.EXPECT().RemoveNetwork(gomock.Any(), networkID, gomock.Any()).Return(removeErr),
+		mockClient.EXPECT().InspectNetwork(gomock.Any(), networkID, gomock.Any()).Return(
+			dockerapi.NetworkResponse{Error: errors.New("some error")}),
+	)
+
+	network := NewNetworkResource(context.TODO(), taskARN, networkName, mockClient)
+	network.setNetworkID(networkID)
+	assert.Equal(t, removeErr, network.Cleanup())
+}
+
+func TestMarshalUnmarshalJSON(t *testing.T) {
+	network := NewNetworkResource(context.TODO(), taskARN, networkName, nil)
+	network.setNetworkID(networkID)
+	network.SetDesiredStatus(resourcestatus.ResourceStatus(NetworkCreated))
+	network.SetKnownStatus(resourcestatus.ResourceStatus(NetworkStatusNone))
+
+	bytes, err := json.Marshal(network)
+	require.NoError(t, err)
+
+	unmarshalledNetwork := &NetworkResource{}
+	require.NoError(t, json.Unmarshal(bytes, unmarshalledNetwork))
+	assert.Equal(t, networkName, unmarshalledNetwork.GetNetworkName())
+	assert.Equal(t, networkID, unmarshalledNetwork.GetNetworkID())
+	assert.Equal(t, taskARN, unmarshalledNetwork.taskARN)
+	assert.Equal(t, resourcestatus.ResourceStatus(NetworkCreated), unmarshalledNetwork.GetDesiredStatus())
+	assert.Equal(t, resourcestatus.ResourceStatus(NetworkStatusNone), unmarshalledNetwork.GetKnownStatus())
+}
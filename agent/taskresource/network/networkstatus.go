@@ -0,0 +1,79 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"errors"
+	"strings"
+
+	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+)
+
+// NetworkStatus defines resource statuses for docker network
+type NetworkStatus resourcestatus.ResourceStatus
+
+const (
+	// NetworkStatusNone is the zero state of a task resource
+	NetworkStatusNone NetworkStatus = iota
+	// NetworkCreated represents a task resource which has been created
+	NetworkCreated
+	// NetworkRemoved represents a task resource which has been Removed
+	NetworkRemoved
+)
+
+var resourceStatusMap = map[string]NetworkStatus{
+	"NONE":    NetworkStatusNone,
+	"CREATED": NetworkCreated,
+	"REMOVED": NetworkRemoved,
+}
+
+// StatusString returns a human readable string representation of this object
+func (ns NetworkStatus) String() string {
+	for k, v := range resourceStatusMap {
+		if v == ns {
+			return k
+		}
+	}
+	return "NONE"
+}
+
+// MarshalJSON overrides the logic for JSON-encoding the ResourceStatus type
+func (ns *NetworkStatus) MarshalJSON() ([]byte, error) {
+	if ns == nil {
+		return nil, nil
+	}
+	return []byte(`"` + ns.String() + `"`), nil
+}
+
+// UnmarshalJSON overrides the logic for parsing the JSON-encoded ResourceStatus data
+func (ns *NetworkStatus) UnmarshalJSON(b []byte) error {
+	if strings.ToLower(string(b)) == "null" {
+		*ns = NetworkStatusNone
+		return nil
+	}
+
+	if b[0] != '"' || b[len(b)-1] != '"' {
+		*ns = NetworkStatusNone
+		return errors.New("resource status unmarshal: status must be a string or null; Got " + string(b))
+	}
+
+	strStatus := string(b[1 : len(b)-1])
+	stat, ok := resourceStatusMap[strStatus]
+	if !ok {
+		*ns = NetworkStatusNone
+		return errors.New("resource status unmarshal: unrecognized status")
+	}
+	*ns = stat
+	return nil
+}
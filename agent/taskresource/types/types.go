@@ -20,6 +20,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	asmauthres "github.com/aws/amazon-ecs-agent/agent/taskresource/asmauth"
 	cgroupres "github.com/aws/amazon-ecs-agent/agent/taskresource/cgroup"
+	networkres "github.com/aws/amazon-ecs-agent/agent/taskresource/network"
 	ssmsecretres "github.com/aws/amazon-ecs-agent/agent/taskresource/ssmsecret"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
 )
@@ -33,6 +34,8 @@ const (
 	ASMAuthKey = asmauthres.ResourceName
 	// SSMSecretKey is the string used in resources map to represent ssm secret
 	SSMSecretKey = ssmsecretres.ResourceName
+	// DockerNetworkKey is the string used in resources map to represent docker network
+	DockerNetworkKey = networkres.ResourceName
 )
 
 // ResourcesMap represents the map of resource type to the corresponding resource
@@ -65,6 +68,10 @@ func (rm *ResourcesMap) UnmarshalJSON(data []byte) error {
 			if unmarshalSSMSecretKey(key, value, result) != nil {
 				return err
 			}
+		case DockerNetworkKey:
+			if unmarshalDockerNetwork(key, value, result) != nil {
+				return err
+			}
 		default:
 			return errors.New("Unsupported resource type")
 		}
@@ -142,3 +149,21 @@ func unmarshalSSMSecretKey(key string, value json.RawMessage, result map[string]
 	}
 	return nil
 }
+
+func unmarshalDockerNetwork(key string, value json.RawMessage, result map[string][]taskresource.TaskResource) error {
+	var networks []json.RawMessage
+	err := json.Unmarshal(value, &networks)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range networks {
+		network := &networkres.NetworkResource{}
+		err := network.UnmarshalJSON(n)
+		if err != nil {
+			return err
+		}
+		result[key] = append(result[key], network)
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
+	"github.com/aws/amazon-ecs-agent/agent/taskresource/network"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
 
@@ -74,3 +75,18 @@ func TestMarshalUnmarshalSSMSecretResource(t *testing.T) {
 	assert.Equal(t, resourcestatus.ResourceCreated, ssmRes.GetDesiredStatus())
 	assert.Equal(t, resourcestatus.ResourceRemoved, ssmRes.GetKnownStatus())
 }
+
+func TestMarshalUnmarshalNetworkResource(t *testing.T) {
+	bytes := []byte(`{"dockerNetwork":[{"taskARN":"task_arn","networkName":"ecs-task-id","networkID":"network_id","createdAt":"0001-01-01T00:00:00Z","desiredStatus":"CREATED","knownStatus":"NONE"}]}`)
+
+	unmarshalledMap := make(ResourcesMap)
+	err := unmarshalledMap.UnmarshalJSON(bytes)
+	assert.NoError(t, err)
+
+	networkRes := unmarshalledMap["dockerNetwork"][0].(*network.NetworkResource)
+	assert.Equal(t, "dockerNetwork", networkRes.GetName())
+	assert.Equal(t, "ecs-task-id", networkRes.GetNetworkName())
+	assert.Equal(t, "network_id", networkRes.GetNetworkID())
+	assert.Equal(t, resourcestatus.ResourceCreated, networkRes.GetDesiredStatus())
+	assert.Equal(t, resourcestatus.ResourceStatusNone, networkRes.GetKnownStatus())
+}
@@ -20,6 +20,10 @@ import (
 	ssmfactory "github.com/aws/amazon-ecs-agent/agent/ssm/factory"
 )
 
+// TaskARNLabel is the label of the docker resources created for a task, like
+// the containers of the task, carrying the arn of the task
+const TaskARNLabel = "com.amazonaws.ecs.task-arn"
+
 type ResourceFieldsCommon struct {
 	IOUtil             ioutilwrapper.IOUtil
 	ASMClientCreator   asmfactory.ClientCreator
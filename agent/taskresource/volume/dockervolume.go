@@ -16,6 +16,7 @@ package volume
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
@@ -55,6 +56,12 @@ type VolumeResource struct {
 	statusToTransitions map[resourcestatus.ResourceStatus]func() error
 	client              dockerapi.DockerClient
 	ctx                 context.Context
+
+	// terminalReason should be set for resource creation failures. This ensures
+	// the resource object carries some context for why provisioning failed.
+	terminalReason     string
+	terminalReasonOnce sync.Once
+
 	// lock is used for fields that are accessed and updated concurrently
 	lock sync.RWMutex
 }
@@ -143,10 +150,21 @@ func (vol *VolumeResource) DesiredTerminal() bool {
 	return vol.desiredStatusUnsafe == resourcestatus.ResourceStatus(VolumeRemoved)
 }
 
+func (vol *VolumeResource) setTerminalReason(reason string) {
+	vol.terminalReasonOnce.Do(func() {
+		seelog.Infof("Volume [%s]: setting terminal reason for volume resource", vol.Name)
+		vol.terminalReason = reason
+	})
+}
+
 // GetTerminalReason returns an error string to propagate up through to task
-// state change messages
+// state change messages. It carries the error of the volume driver if the
+// volume couldn't be created
 func (vol *VolumeResource) GetTerminalReason() string {
-	return resourceProvisioningError
+	if vol.terminalReason == "" {
+		return resourceProvisioningError
+	}
+	return vol.terminalReason
 }
 
 // SetDesiredStatus safely sets the desired status of the resource
@@ -288,6 +306,7 @@ func (vol *VolumeResource) Create() error {
 		dockerapi.CreateVolumeTimeout)
 
 	if volumeResponse.Error != nil {
+		vol.setTerminalReason(fmt.Sprintf("%s: %s", resourceProvisioningError, volumeResponse.Error.Error()))
 		return volumeResponse.Error
 	}
 
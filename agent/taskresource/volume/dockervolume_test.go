@@ -84,6 +84,8 @@ func TestCreateError(t *testing.T) {
 	volume, _ := NewVolumeResource(ctx, name, name, scope, autoprovision, driver, nil, labels, mockClient)
 	err := volume.Create()
 	assert.NotNil(t, err)
+	assert.Equal(t, resourceProvisioningError+": some error", volume.GetTerminalReason(),
+		"the terminal reason should carry the error of the volume driver")
 }
 
 func TestCleanupSuccess(t *testing.T) {
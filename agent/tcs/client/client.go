@@ -19,9 +19,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
@@ -39,33 +41,62 @@ const (
 	tasksInHealthMessage = 10
 )
 
+// ClientServer is the client/server of the metrics backend
+type ClientServer interface {
+	wsclient.ClientServer
+	// SetPublishMetricsInterval changes the interval at which metrics are
+	// published, without reconnecting. The interval the client was created
+	// with is restored if it's zero
+	SetPublishMetricsInterval(interval time.Duration)
+}
+
 // clientServer implements wsclient.ClientServer interface for metrics backend.
 type clientServer struct {
-	statsEngine            stats.Engine
-	publishTicker          *time.Ticker
-	publishHealthTicker    *time.Ticker
+	statsEngine         stats.Engine
+	publishTicker       *time.Ticker
+	publishHealthTicker *time.Ticker
+	// publishTickerLock guards replacing the publish ticker when the publish
+	// interval changes against stopping it when the client is closed
+	publishTickerLock      sync.Mutex
 	ctx                    context.Context
 	cancel                 context.CancelFunc
 	disableResourceMetrics bool
 	publishMetricsInterval time.Duration
+	// defaultPublishMetricsInterval is the interval the client was created
+	// with, which is used until the backend asks for another one
+	defaultPublishMetricsInterval time.Duration
+	// publishIntervalUpdates passes the changes of the publish interval to
+	// the goroutine publishing metrics
+	publishIntervalUpdates chan time.Duration
+	// metricsBuffer holds the metrics that couldn't be published, they're
+	// dropped if it's nil
+	metricsBuffer *MetricsBuffer
+	// reportedDockerStatus is the docker status of the last health metrics
+	// published. It's only accessed by the goroutine publishing them
+	reportedDockerStatus string
 	wsclient.ClientServerImpl
 }
 
 // New returns a client/server to bidirectionally communicate with the backend.
 // The returned struct should have both 'Connect' and 'Serve' called upon it
-// before being used.
+// before being used. The metrics buffered while disconnected are published
+// first, and the metrics that can't be published are buffered
 func New(url string,
 	cfg *config.Config,
 	credentialProvider *credentials.Credentials,
 	statsEngine stats.Engine,
 	publishMetricsInterval time.Duration,
 	rwTimeout time.Duration,
-	disableResourceMetrics bool) wsclient.ClientServer {
+	disableResourceMetrics bool,
+	metricsBuffer *MetricsBuffer) ClientServer {
 	cs := &clientServer{
-		statsEngine:            statsEngine,
-		publishTicker:          nil,
-		publishHealthTicker:    nil,
-		publishMetricsInterval: publishMetricsInterval,
+		statsEngine:                   statsEngine,
+		publishTicker:                 nil,
+		publishHealthTicker:           nil,
+		publishMetricsInterval:        publishMetricsInterval,
+		defaultPublishMetricsInterval: publishMetricsInterval,
+		publishIntervalUpdates:        make(chan time.Duration, 1),
+		metricsBuffer:                 metricsBuffer,
 	}
 	cs.URL = url
 	cs.AgentConfig = cfg
@@ -75,6 +106,7 @@ func New(url string,
 	cs.MakeRequestHook = signRequestFunc(url, cs.AgentConfig.AWSRegion, credentialProvider)
 	cs.TypeDecoder = NewTCSDecoder()
 	cs.RWTimeout = rwTimeout
+	cs.MetricsEndpoint = metrics.TCSEndpoint
 	cs.disableResourceMetrics = disableResourceMetrics
 	// TODO make this context inherited from the handler
 	cs.ctx, cs.cancel = context.WithCancel(context.TODO())
@@ -95,7 +127,9 @@ func (cs *clientServer) Serve() error {
 	}
 
 	// Start the timer function to publish metrics to the backend.
+	cs.publishTickerLock.Lock()
 	cs.publishTicker = time.NewTicker(cs.publishMetricsInterval)
+	cs.publishTickerLock.Unlock()
 	cs.publishHealthTicker = time.NewTicker(cs.publishMetricsInterval)
 
 	if !cs.disableResourceMetrics {
@@ -108,9 +142,11 @@ func (cs *clientServer) Serve() error {
 
 // Close closes the underlying connection.
 func (cs *clientServer) Close() error {
+	cs.publishTickerLock.Lock()
 	if cs.publishTicker != nil {
 		cs.publishTicker.Stop()
 	}
+	cs.publishTickerLock.Unlock()
 	if cs.publishHealthTicker != nil {
 		cs.publishHealthTicker.Stop()
 	}
@@ -155,25 +191,98 @@ func (cs *clientServer) publishMetrics() {
 
 	// Publish metrics immediately after we connect and wait for ticks. This makes
 	// sure that there is no data loss when a scheduled metrics publishing fails
-	// due to a connection reset.
+	// due to a connection reset. The metrics buffered while disconnected are
+	// published before them.
+	if cs.metricsBuffer != nil {
+		err := cs.metricsBuffer.Flush(func(request *ecstcs.PublishMetricsRequest) error {
+			return cs.MakeRequest(request)
+		})
+		if err != nil {
+			seelog.Warnf("Error publishing buffered metrics: %v", err)
+		}
+	}
 	err := cs.publishMetricsOnce()
 	if err != nil && err != stats.EmptyMetricsError {
 		seelog.Warnf("Error publishing metrics: %v", err)
 	}
+	lastPublished := time.Now()
+	// catchUp fires when the first metrics are due after the publish interval
+	// changed, the ticker is restarted at the new interval from then on
+	var catchUp <-chan time.Time
 	// don't simply range over the ticker since its channel doesn't ever get closed
 	for {
 		select {
 		case <-cs.publishTicker.C:
+			lastPublished = time.Now()
+			err := cs.publishMetricsOnce()
+			if err != nil {
+				seelog.Warnf("Error publishing metrics: %v", err)
+			}
+		case <-catchUp:
+			catchUp = nil
+			lastPublished = time.Now()
+			cs.replacePublishTicker(time.NewTicker(cs.publishMetricsInterval))
 			err := cs.publishMetricsOnce()
 			if err != nil {
 				seelog.Warnf("Error publishing metrics: %v", err)
 			}
+		case interval := <-cs.publishIntervalUpdates:
+			if interval == cs.publishMetricsInterval {
+				continue
+			}
+			seelog.Infof("Changing the interval at which metrics are published to TCS from %s to %s",
+				cs.publishMetricsInterval.String(), interval.String())
+			cs.publishMetricsInterval = interval
+			// The stopped ticker doesn't tick until it's replaced. The next
+			// metrics are published once the new interval has passed since
+			// the last ones, or right away if it already has, so that no
+			// cycle is skipped
+			cs.publishTickerLock.Lock()
+			cs.publishTicker.Stop()
+			cs.publishTickerLock.Unlock()
+			catchUp = time.After(lastPublished.Add(interval).Sub(time.Now()))
 		case <-cs.ctx.Done():
 			return
 		}
 	}
 }
 
+// replacePublishTicker replaces the ticker publishing metrics, unless the
+// client was closed
+func (cs *clientServer) replacePublishTicker(ticker *time.Ticker) {
+	cs.publishTickerLock.Lock()
+	defer cs.publishTickerLock.Unlock()
+
+	cs.publishTicker.Stop()
+	if cs.ctx.Err() != nil {
+		ticker.Stop()
+		return
+	}
+	cs.publishTicker = ticker
+}
+
+// SetPublishMetricsInterval changes the interval at which metrics are
+// published. The interval the client was created with is restored if it's
+// zero
+func (cs *clientServer) SetPublishMetricsInterval(interval time.Duration) {
+	if interval == 0 {
+		interval = cs.defaultPublishMetricsInterval
+	}
+	for {
+		select {
+		case cs.publishIntervalUpdates <- interval:
+			return
+		default:
+			// Only the latest change matters, replace the one that hasn't
+			// been applied yet
+			select {
+			case <-cs.publishIntervalUpdates:
+			default:
+			}
+		}
+	}
+}
+
 // publishMetricsOnce is invoked by the ticker to periodically publish metrics to backend.
 func (cs *clientServer) publishMetricsOnce() error {
 	// Get the list of objects to send to backend.
@@ -183,19 +292,40 @@ func (cs *clientServer) publishMetricsOnce() error {
 	}
 
 	// Make the publish metrics request to the backend.
-	for _, request := range requests {
-		err = cs.MakeRequest(request)
+	for i, request := range requests {
+		err = cs.publishMetricsRequest(request)
 		if err != nil {
+			// The stats engine doesn't keep the metrics it returned, the
+			// metrics that weren't published are buffered to be published
+			// after reconnecting
+			if cs.metricsBuffer != nil {
+				cs.metricsBuffer.Add(requests[i:]...)
+			}
 			return err
 		}
 	}
 	return nil
 }
 
+// publishMetricsRequest publishes the request with the number of metrics
+// messages dropped so far
+func (cs *clientServer) publishMetricsRequest(request *ecstcs.PublishMetricsRequest) error {
+	if cs.metricsBuffer != nil {
+		request.Metadata.DroppedMessageCount = aws.Int64(cs.metricsBuffer.Dropped())
+	}
+	return cs.MakeRequest(request)
+}
+
 // metricsToPublishMetricRequests gets task metrics and converts them to a list of PublishMetricRequest
 // objects.
 func (cs *clientServer) metricsToPublishMetricRequests() ([]*ecstcs.PublishMetricsRequest, error) {
-	metadata, taskMetrics, err := cs.statsEngine.GetInstanceMetrics()
+	return newPublishMetricsRequests(cs.statsEngine)
+}
+
+// newPublishMetricsRequests gets the task metrics of the stats engine and
+// splits them into PublishMetricRequest objects.
+func newPublishMetricsRequests(statsEngine stats.Engine) ([]*ecstcs.PublishMetricsRequest, error) {
+	metadata, taskMetrics, err := statsEngine.GetInstanceMetrics()
 	if err != nil {
 		return nil, err
 	}
@@ -275,6 +405,7 @@ func (cs *clientServer) publishHealthMetricsOnce() error {
 		if err != nil {
 			return err
 		}
+		cs.reportedDockerStatus = aws.StringValue(request.Metadata.DockerStatus)
 	}
 	return nil
 }
@@ -286,10 +417,22 @@ func (cs *clientServer) createPublishHealthRequests() ([]*ecstcs.PublishHealthRe
 		return nil, err
 	}
 
-	if metadata == nil || taskHealthMetrics == nil {
+	if metadata == nil {
 		seelog.Debug("No container health metrics to report")
 		return nil, nil
 	}
+	if len(taskHealthMetrics) == 0 {
+		// The docker status is reported without the health of tasks while
+		// docker is unreachable, and once when it changes
+		dockerStatus := aws.StringValue(metadata.DockerStatus)
+		if dockerStatus != ecstcs.HealthStatusUnhealthy && dockerStatus == cs.reportedDockerStatus {
+			seelog.Debug("No container health metrics to report")
+			return nil, nil
+		}
+		return []*ecstcs.PublishHealthRequest{
+			ecstcs.NewPublishHealthMetricsRequest(copyHealthMetadata(metadata, true), nil),
+		}, nil
+	}
 
 	var requests []*ecstcs.PublishHealthRequest
 	var taskHealths []*ecstcs.TaskHealth
@@ -341,6 +484,7 @@ func copyHealthMetadata(metadata *ecstcs.HealthMetadata, fin bool) *ecstcs.Healt
 	return &ecstcs.HealthMetadata{
 		Cluster:           aws.String(aws.StringValue(metadata.Cluster)),
 		ContainerInstance: aws.String(aws.StringValue(metadata.ContainerInstance)),
+		DockerStatus:      metadata.DockerStatus,
 		Fin:               aws.Bool(fin),
 		MessageId:         aws.String(aws.StringValue(metadata.MessageId)),
 	}
@@ -38,6 +38,7 @@ import (
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -56,8 +57,8 @@ func (*mockStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs
 	return nil, nil, fmt.Errorf("uninitialized")
 }
 
-func (*mockStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, error) {
-	return nil, fmt.Errorf("not implemented")
+func (*mockStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, bool, error) {
+	return nil, false, fmt.Errorf("not implemented")
 }
 
 func (*mockStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
@@ -70,8 +71,8 @@ func (*emptyStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstc
 	return nil, nil, fmt.Errorf("empty stats")
 }
 
-func (*emptyStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, error) {
-	return nil, fmt.Errorf("not implemented")
+func (*emptyStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, bool, error) {
+	return nil, false, fmt.Errorf("not implemented")
 }
 
 func (*emptyStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
@@ -90,8 +91,8 @@ func (*idleStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs
 	return metadata, []*ecstcs.TaskMetric{}, nil
 }
 
-func (*idleStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, error) {
-	return nil, fmt.Errorf("not implemented")
+func (*idleStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, bool, error) {
+	return nil, false, fmt.Errorf("not implemented")
 }
 
 func (*idleStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
@@ -118,8 +119,8 @@ func (engine *nonIdleStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata,
 	return metadata, taskMetrics, nil
 }
 
-func (*nonIdleStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, error) {
-	return nil, fmt.Errorf("not implemented")
+func (*nonIdleStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, bool, error) {
+	return nil, false, fmt.Errorf("not implemented")
 }
 
 func (*nonIdleStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
@@ -240,13 +241,94 @@ func TestPublishOnceNonIdleStatsEngine(t *testing.T) {
 	}
 }
 
+func TestPublishMetricsOnceBuffersUnpublishedMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := mock_wsconn.NewMockWebsocketConn(ctrl)
+	metricsBuffer := NewMetricsBuffer(time.Minute, 10)
+	cfg := &config.Config{
+		AWSRegion:          "us-east-1",
+		AcceptInsecureCert: true,
+	}
+	cs := New("https://aws.amazon.com/ecs", cfg, testCreds, newNonIdleStatsEngine(2*tasksInMetricMessage+1),
+		testPublishMetricsInterval, rwTimeout, false, metricsBuffer).(*clientServer)
+	cs.SetConnection(conn)
+
+	// The first request is published, the connection breaks while publishing
+	// the second one
+	conn.EXPECT().SetWriteDeadline(gomock.Any()).Return(nil).Times(2)
+	gomock.InOrder(
+		conn.EXPECT().WriteMessage(gomock.Any(), gomock.Any()).Return(nil),
+		conn.EXPECT().WriteMessage(gomock.Any(), gomock.Any()).Return(fmt.Errorf("broken pipe")),
+	)
+
+	err := cs.publishMetricsOnce()
+	assert.Error(t, err)
+	assert.Equal(t, 2, metricsBuffer.Len(), "the unpublished requests should be buffered")
+}
+
+func TestSetPublishMetricsInterval(t *testing.T) {
+	cs := New("", &config.Config{}, testCreds, &mockStatsEngine{},
+		testPublishMetricsInterval, rwTimeout, false, nil).(*clientServer)
+
+	// Only the latest change that wasn't applied yet is kept
+	cs.SetPublishMetricsInterval(time.Minute)
+	cs.SetPublishMetricsInterval(2 * time.Minute)
+	assert.Equal(t, 2*time.Minute, <-cs.publishIntervalUpdates)
+
+	cs.SetPublishMetricsInterval(0)
+	assert.Equal(t, testPublishMetricsInterval, <-cs.publishIntervalUpdates,
+		"the interval the client was created with should be restored")
+}
+
+func TestPublishMetricsIntervalChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := mock_wsconn.NewMockWebsocketConn(ctrl)
+	cfg := &config.Config{
+		AWSRegion:          "us-east-1",
+		AcceptInsecureCert: true,
+	}
+	cs := New("https://aws.amazon.com/ecs", cfg, testCreds, newNonIdleStatsEngine(1),
+		time.Hour, rwTimeout, false, nil).(*clientServer)
+	cs.SetConnection(conn)
+
+	published := make(chan struct{}, 100)
+	conn.EXPECT().SetWriteDeadline(gomock.Any()).Return(nil).AnyTimes()
+	conn.EXPECT().WriteMessage(gomock.Any(), gomock.Any()).Do(func(int, []byte) {
+		select {
+		case published <- struct{}{}:
+		default:
+		}
+	}).Return(nil).AnyTimes()
+	conn.EXPECT().Close().AnyTimes()
+
+	cs.publishTicker = time.NewTicker(cs.publishMetricsInterval)
+	go cs.publishMetrics()
+	defer cs.Close()
+	// Metrics are published right away
+	<-published
+
+	// The metrics are published at the new interval without reconnecting
+	cs.SetPublishMetricsInterval(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-published:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the metrics to be published at the new interval")
+		}
+	}
+}
+
 func testCS(conn *mock_wsconn.MockWebsocketConn) wsclient.ClientServer {
 	cfg := &config.Config{
 		AWSRegion:          "us-east-1",
 		AcceptInsecureCert: true,
 	}
 	cs := New("https://aws.amazon.com/ecs", cfg, testCreds, &mockStatsEngine{},
-		testPublishMetricsInterval, rwTimeout, false).(*clientServer)
+		testPublishMetricsInterval, rwTimeout, false, nil).(*clientServer)
 	cs.SetConnection(conn)
 	return cs
 }
@@ -313,7 +395,7 @@ func TestMetricsDisabled(t *testing.T) {
 
 	cfg := config.DefaultConfig()
 
-	cs := New("", &cfg, testCreds, mockStatsEngine, testPublishMetricsInterval, rwTimeout, true)
+	cs := New("", &cfg, testCreds, mockStatsEngine, testPublishMetricsInterval, rwTimeout, true, nil)
 	cs.SetConnection(conn)
 
 	published := make(chan struct{})
@@ -348,7 +430,7 @@ func TestCreatePublishHealthRequestsEmpty(t *testing.T) {
 	mockStatsEngine := mock_stats.NewMockEngine(ctrl)
 	cfg := config.DefaultConfig()
 
-	cs := New("", &cfg, testCreds, mockStatsEngine, testPublishMetricsInterval, rwTimeout, true)
+	cs := New("", &cfg, testCreds, mockStatsEngine, testPublishMetricsInterval, rwTimeout, true, nil)
 	cs.SetConnection(conn)
 
 	mockStatsEngine.EXPECT().GetTaskHealthMetrics().Return(nil, nil, stats.EmptyHealthMetricsError)
@@ -368,7 +450,7 @@ func TestCreatePublishHealthRequests(t *testing.T) {
 	mockStatsEngine := mock_stats.NewMockEngine(ctrl)
 	cfg := config.DefaultConfig()
 
-	cs := New("", &cfg, testCreds, mockStatsEngine, testPublishMetricsInterval, rwTimeout, true)
+	cs := New("", &cfg, testCreds, mockStatsEngine, testPublishMetricsInterval, rwTimeout, true, nil)
 	cs.SetConnection(conn)
 
 	testMetadata := &ecstcs.HealthMetadata{
@@ -416,6 +498,51 @@ func TestCreatePublishHealthRequests(t *testing.T) {
 	assert.Equal(t, request[0].Tasks, testHealthMetrics)
 }
 
+func TestCreatePublishHealthRequestsDockerStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := mock_wsconn.NewMockWebsocketConn(ctrl)
+	mockStatsEngine := mock_stats.NewMockEngine(ctrl)
+	cfg := config.DefaultConfig()
+
+	cs := New("", &cfg, testCreds, mockStatsEngine, testPublishMetricsInterval, rwTimeout, true, nil)
+	cs.SetConnection(conn)
+	client := cs.(*clientServer)
+
+	metadata := func(dockerStatus string) *ecstcs.HealthMetadata {
+		return &ecstcs.HealthMetadata{
+			Cluster:           aws.String("TestCreatePublishHealthRequestsDockerStatus"),
+			ContainerInstance: aws.String("container_instance"),
+			DockerStatus:      aws.String(dockerStatus),
+			MessageId:         aws.String("message_id"),
+		}
+	}
+
+	// The status of docker is reported without tasks while it's unhealthy
+	client.reportedDockerStatus = ecstcs.HealthStatusUnhealthy
+	mockStatsEngine.EXPECT().GetTaskHealthMetrics().Return(metadata(ecstcs.HealthStatusUnhealthy), nil, nil)
+	requests, err := client.createPublishHealthRequests()
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Empty(t, requests[0].Tasks)
+	assert.Equal(t, ecstcs.HealthStatusUnhealthy, aws.StringValue(requests[0].Metadata.DockerStatus))
+	assert.True(t, aws.BoolValue(requests[0].Metadata.Fin))
+
+	// The recovery is reported once
+	mockStatsEngine.EXPECT().GetTaskHealthMetrics().Return(metadata(ecstcs.HealthStatusHealthy), nil, nil)
+	requests, err = client.createPublishHealthRequests()
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, ecstcs.HealthStatusHealthy, aws.StringValue(requests[0].Metadata.DockerStatus))
+
+	client.reportedDockerStatus = ecstcs.HealthStatusHealthy
+	mockStatsEngine.EXPECT().GetTaskHealthMetrics().Return(metadata(ecstcs.HealthStatusHealthy), nil, nil)
+	requests, err = client.createPublishHealthRequests()
+	require.NoError(t, err)
+	assert.Empty(t, requests)
+}
+
 func TestSessionClosed(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -0,0 +1,132 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tcsclient
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/stats"
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/cihub/seelog"
+)
+
+// MetricsBuffer holds the metrics that couldn't be published while the agent
+// was disconnected from the backend, so that they're published in order once
+// it reconnects. It outlives the connections to the backend. Once the buffer
+// is full or its metrics get older than its maximum age, the oldest metrics
+// are dropped
+type MetricsBuffer struct {
+	maxAge      time.Duration
+	maxRequests int
+	requests    []*ecstcs.PublishMetricsRequest
+	// dropped is the number of requests dropped since the agent started,
+	// which is published with the metrics
+	dropped int64
+	lock    sync.Mutex
+}
+
+// NewMetricsBuffer returns a MetricsBuffer holding up to maxRequests requests
+// of metrics that are at most maxAge old
+func NewMetricsBuffer(maxAge time.Duration, maxRequests int) *MetricsBuffer {
+	return &MetricsBuffer{
+		maxAge:      maxAge,
+		maxRequests: maxRequests,
+	}
+}
+
+// Collect adds the metrics of the stats engine to the buffer. It's used to
+// keep collecting the metrics while the agent is disconnected, as the stats
+// engine only keeps the latest container stats
+func (buffer *MetricsBuffer) Collect(statsEngine stats.Engine) error {
+	requests, err := newPublishMetricsRequests(statsEngine)
+	if err != nil {
+		return err
+	}
+	buffer.Add(requests...)
+	return nil
+}
+
+// Add queues the requests after the ones already buffered
+func (buffer *MetricsBuffer) Add(requests ...*ecstcs.PublishMetricsRequest) {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	buffer.requests = append(buffer.requests, requests...)
+	dropped := buffer.dropUnsafe(time.Now())
+	if dropped != 0 {
+		seelog.Warnf("Dropped %d metrics messages that couldn't be published, %d dropped in total",
+			dropped, buffer.dropped)
+	}
+}
+
+// dropUnsafe drops the oldest requests until the buffer isn't full and its
+// requests aren't older than its maximum age. It returns the number of
+// dropped requests
+func (buffer *MetricsBuffer) dropUnsafe(now time.Time) int {
+	drop := 0
+	if len(buffer.requests) > buffer.maxRequests {
+		drop = len(buffer.requests) - buffer.maxRequests
+	}
+	for drop < len(buffer.requests) &&
+		now.Sub(aws.TimeValue(buffer.requests[drop].Timestamp)) > buffer.maxAge {
+		drop++
+	}
+	if drop == 0 {
+		return 0
+	}
+	// Clear the dropped requests so that they can be garbage collected
+	for i := 0; i < drop; i++ {
+		buffer.requests[i] = nil
+	}
+	buffer.requests = buffer.requests[drop:]
+	buffer.dropped += int64(drop)
+	return drop
+}
+
+// Flush publishes the buffered requests in order, until publishing one of
+// them fails. The requests that weren't published stay buffered
+func (buffer *MetricsBuffer) Flush(publish func(*ecstcs.PublishMetricsRequest) error) error {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	buffer.dropUnsafe(time.Now())
+	for len(buffer.requests) != 0 {
+		request := buffer.requests[0]
+		request.Metadata.DroppedMessageCount = aws.Int64(buffer.dropped)
+		if err := publish(request); err != nil {
+			return err
+		}
+		buffer.requests[0] = nil
+		buffer.requests = buffer.requests[1:]
+	}
+	return nil
+}
+
+// Dropped returns the number of requests dropped since the agent started
+func (buffer *MetricsBuffer) Dropped() int64 {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	return buffer.dropped
+}
+
+// Len returns the number of buffered requests
+func (buffer *MetricsBuffer) Len() int {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	return len(buffer.requests)
+}
@@ -0,0 +1,113 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tcsclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMetricsRequest(messageID string, timestamp time.Time) *ecstcs.PublishMetricsRequest {
+	return &ecstcs.PublishMetricsRequest{
+		Metadata:  &ecstcs.MetricsMetadata{MessageId: aws.String(messageID)},
+		Timestamp: aws.Time(timestamp),
+	}
+}
+
+func flushedMessageIDs(t *testing.T, buffer *MetricsBuffer) []string {
+	var messageIDs []string
+	err := buffer.Flush(func(request *ecstcs.PublishMetricsRequest) error {
+		messageIDs = append(messageIDs, aws.StringValue(request.Metadata.MessageId))
+		return nil
+	})
+	require.NoError(t, err)
+	return messageIDs
+}
+
+func TestMetricsBufferFlushInOrder(t *testing.T) {
+	buffer := NewMetricsBuffer(time.Minute, 10)
+	now := time.Now()
+	buffer.Add(testMetricsRequest("1", now), testMetricsRequest("2", now))
+	buffer.Add(testMetricsRequest("3", now))
+
+	assert.Equal(t, 3, buffer.Len())
+	assert.Equal(t, []string{"1", "2", "3"}, flushedMessageIDs(t, buffer))
+	assert.Equal(t, 0, buffer.Len())
+	assert.Zero(t, buffer.Dropped())
+}
+
+func TestMetricsBufferDropsOldestWhenFull(t *testing.T) {
+	buffer := NewMetricsBuffer(time.Minute, 2)
+	now := time.Now()
+	buffer.Add(testMetricsRequest("1", now), testMetricsRequest("2", now))
+	buffer.Add(testMetricsRequest("3", now))
+
+	assert.Equal(t, int64(1), buffer.Dropped())
+	var dropped []int64
+	err := buffer.Flush(func(request *ecstcs.PublishMetricsRequest) error {
+		dropped = append(dropped, aws.Int64Value(request.Metadata.DroppedMessageCount))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 1}, dropped, "the dropped count should be published")
+
+	buffer.Add(testMetricsRequest("4", now))
+	assert.Equal(t, []string{"4"}, flushedMessageIDs(t, buffer))
+}
+
+func TestMetricsBufferDropsExpired(t *testing.T) {
+	buffer := NewMetricsBuffer(time.Minute, 10)
+	now := time.Now()
+	buffer.Add(testMetricsRequest("1", now.Add(-2*time.Minute)),
+		testMetricsRequest("2", now.Add(-30*time.Second)))
+
+	assert.Equal(t, int64(1), buffer.Dropped())
+	assert.Equal(t, []string{"2"}, flushedMessageIDs(t, buffer))
+}
+
+func TestMetricsBufferFlushFailureKeepsRemaining(t *testing.T) {
+	buffer := NewMetricsBuffer(time.Minute, 10)
+	now := time.Now()
+	buffer.Add(testMetricsRequest("1", now), testMetricsRequest("2", now), testMetricsRequest("3", now))
+
+	published := 0
+	err := buffer.Flush(func(request *ecstcs.PublishMetricsRequest) error {
+		if published == 1 {
+			return errors.New("error")
+		}
+		published++
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"2", "3"}, flushedMessageIDs(t, buffer))
+	assert.Zero(t, buffer.Dropped())
+}
+
+func TestMetricsBufferCollect(t *testing.T) {
+	buffer := NewMetricsBuffer(time.Minute, 10)
+
+	require.NoError(t, buffer.Collect(newNonIdleStatsEngine(tasksInMetricMessage+1)))
+	assert.Equal(t, 2, buffer.Len())
+
+	assert.Error(t, buffer.Collect(&emptyStatsEngine{}))
+	assert.Equal(t, 2, buffer.Len())
+}
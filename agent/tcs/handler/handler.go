@@ -21,11 +21,13 @@ import (
 
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/tcs/client"
 	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/wsclient"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/cihub/seelog"
 )
@@ -42,6 +44,33 @@ const (
 	// websocket connection
 	wsRWTimeout                        = 2*defaultHeartbeatTimeout + defaultHeartbeatJitter
 	deregisterContainerInstanceHandler = "TCSDeregisterContainerInstanceHandler"
+
+	// The backoff between attempts to connect to the backend after errors
+	connectionBackoffMin        = time.Second
+	connectionBackoffMax        = 1 * time.Minute
+	connectionBackoffJitter     = 0.2
+	connectionBackoffMultiplier = 2
+	// minStableSessionDuration is how long a session has to last for the
+	// backoff to be reset when it's closed, so that a backend closing
+	// connections right away isn't reconnected to in a tight loop
+	minStableSessionDuration = 1 * time.Minute
+	// reconnectJitter is the maximum wait before reconnecting after a session
+	// was closed for a valid reason, or the jitter added to the wait the
+	// backend asked for, so that agents don't all reconnect at once
+	reconnectJitter = 5 * time.Second
+	// maxRetryAfter bounds how long the backend can ask the agent to wait
+	// before reconnecting
+	maxRetryAfter = 30 * time.Minute
+
+	// maxBufferedMetricsAge and maxBufferedMetricsRequests bound the metrics
+	// buffered while disconnected from the backend
+	maxBufferedMetricsAge      = 15 * time.Minute
+	maxBufferedMetricsRequests = 500
+
+	// minPublishMetricsInterval and maxPublishMetricsInterval bound the
+	// interval at which the backend can ask for metrics to be published
+	minPublishMetricsInterval = 5 * time.Second
+	maxPublishMetricsInterval = 5 * time.Minute
 )
 
 // StartMetricsSession starts a metric session. It initializes the stats engine
@@ -65,20 +94,81 @@ func StartMetricsSession(params TelemetrySessionParams) {
 // The engine is expected to initialized and gathering container metrics by
 // the time the websocket client starts using it.
 func StartSession(params TelemetrySessionParams, statsEngine stats.Engine) error {
-	backoff := utils.NewSimpleBackoff(time.Second, 1*time.Minute, 0.2, 2)
+	backoff := utils.NewSimpleBackoff(connectionBackoffMin, connectionBackoffMax,
+		connectionBackoffJitter, connectionBackoffMultiplier)
+	metricsBuffer := tcsclient.NewMetricsBuffer(maxBufferedMetricsAge, maxBufferedMetricsRequests)
+	lastCollected := params.time().Now()
 	for {
-		tcsError := startTelemetrySession(params, statsEngine)
+		sessionStart := params.time().Now()
+		tcsError := startTelemetrySession(params, statsEngine, metricsBuffer)
+		sessionDuration := params.time().Now().Sub(sessionStart)
+		if sessionDuration >= minStableSessionDuration {
+			// The metrics were published until the session was closed
+			lastCollected = params.time().Now()
+		}
 		if tcsError == nil || tcsError == io.EOF {
 			seelog.Info("TCS Websocket connection closed for a valid reason")
-			backoff.Reset()
 		} else {
 			seelog.Infof("Error from tcs; backing off: %v", tcsError)
-			params.time().Sleep(backoff.Duration())
 		}
+		delay := reconnectDelay(tcsError, sessionDuration, backoff)
+		lastCollected = params.waitToReconnect(statsEngine, metricsBuffer, delay, lastCollected)
+	}
+}
+
+// reconnectDelay returns how long to wait before reconnecting to the backend
+// after a session ended with the given error. The wait the backend asked for
+// is honored, and the backoff is only reset by sessions that lasted long
+// enough to be considered stable
+func reconnectDelay(tcsError error, sessionDuration time.Duration, backoff utils.Backoff) time.Duration {
+	if retryAfterErr, ok := tcsError.(*wsclient.RetryAfterError); ok {
+		retryAfter := retryAfterErr.RetryAfter
+		if retryAfter > maxRetryAfter {
+			retryAfter = maxRetryAfter
+		}
+		seelog.Infof("TCS asked to wait %s before reconnecting", retryAfter.String())
+		return utils.AddJitter(retryAfter, reconnectJitter)
+	}
+	if (tcsError == nil || tcsError == io.EOF) && sessionDuration >= minStableSessionDuration {
+		backoff.Reset()
+		return utils.AddJitter(0, reconnectJitter)
 	}
+	return backoff.Duration()
 }
 
-func startTelemetrySession(params TelemetrySessionParams, statsEngine stats.Engine) error {
+// waitToReconnect waits for the given delay, collecting the metrics of the
+// stats engine into the buffer at the publish interval in the meantime, so
+// that they can be published after reconnecting. It returns when the metrics
+// were last collected
+func (params *TelemetrySessionParams) waitToReconnect(statsEngine stats.Engine,
+	metricsBuffer *tcsclient.MetricsBuffer,
+	delay time.Duration,
+	lastCollected time.Time) time.Time {
+	reconnectAt := params.time().Now().Add(delay)
+	for {
+		now := params.time().Now()
+		if !now.Before(reconnectAt) {
+			return lastCollected
+		}
+		nextCollection := lastCollected.Add(defaultPublishMetricsInterval)
+		if params.Cfg.DisableMetrics || nextCollection.After(reconnectAt) {
+			params.time().Sleep(reconnectAt.Sub(now))
+			continue
+		}
+		if now.Before(nextCollection) {
+			params.time().Sleep(nextCollection.Sub(now))
+		}
+		err := metricsBuffer.Collect(statsEngine)
+		if err != nil && err != stats.EmptyMetricsError {
+			seelog.Warnf("Error collecting metrics while disconnected from TCS: %v", err)
+		}
+		lastCollected = params.time().Now()
+	}
+}
+
+func startTelemetrySession(params TelemetrySessionParams,
+	statsEngine stats.Engine,
+	metricsBuffer *tcsclient.MetricsBuffer) error {
 	tcsEndpoint, err := params.ECSClient.DiscoverTelemetryEndpoint(params.ContainerInstanceArn)
 	if err != nil {
 		seelog.Errorf("tcs: unable to discover poll endpoint: %v", err)
@@ -87,7 +177,7 @@ func startTelemetrySession(params TelemetrySessionParams, statsEngine stats.Engi
 	url := formatURL(tcsEndpoint, params.Cfg.Cluster, params.ContainerInstanceArn)
 	return startSession(url, params.Cfg, params.CredentialProvider, statsEngine,
 		defaultHeartbeatTimeout, defaultHeartbeatJitter, defaultPublishMetricsInterval,
-		params.DeregisterInstanceEventStream)
+		params.DeregisterInstanceEventStream, metricsBuffer)
 }
 
 func startSession(url string,
@@ -96,9 +186,10 @@ func startSession(url string,
 	statsEngine stats.Engine,
 	heartbeatTimeout, heartbeatJitter,
 	publishMetricsInterval time.Duration,
-	deregisterInstanceEventStream *eventstream.EventStream) error {
+	deregisterInstanceEventStream *eventstream.EventStream,
+	metricsBuffer *tcsclient.MetricsBuffer) error {
 	client := tcsclient.New(url, cfg, credentialProvider, statsEngine,
-		publishMetricsInterval, wsRWTimeout, cfg.DisableMetrics)
+		publishMetricsInterval, wsRWTimeout, cfg.DisableMetrics, metricsBuffer)
 	defer client.Close()
 
 	err := deregisterInstanceEventStream.Subscribe(deregisterContainerInstanceHandler, client.Disconnect)
@@ -113,6 +204,8 @@ func startSession(url string,
 		return err
 	}
 	seelog.Info("Connected to TCS endpoint")
+	metrics.SetConnected(metrics.TCSEndpoint, true)
+	defer metrics.SetConnected(metrics.TCSEndpoint, false)
 	// start a timer and listens for tcs heartbeats/acks. The timer is reset when
 	// we receive a heartbeat from the server or when a publish metrics message
 	// is acked.
@@ -123,28 +216,56 @@ func startSession(url string,
 		client.Disconnect()
 	})
 	defer timer.Stop()
-	client.AddRequestHandler(heartbeatHandler(timer))
-	client.AddRequestHandler(ackPublishMetricHandler(timer))
+	client.AddRequestHandler(heartbeatHandler(timer, client))
+	client.AddRequestHandler(ackPublishMetricHandler(timer, client))
 	client.AddRequestHandler(ackPublishHealthMetricHandler(timer))
 	client.SetAnyRequestHandler(anyMessageHandler(client))
 	return client.Serve()
 }
 
 // heartbeatHandler resets the heartbeat timer when HeartbeatMessage message is received from tcs.
-func heartbeatHandler(timer *time.Timer) func(*ecstcs.HeartbeatMessage) {
-	return func(*ecstcs.HeartbeatMessage) {
+// It also applies the publish interval the message carries
+func heartbeatHandler(timer *time.Timer, client tcsclient.ClientServer) func(*ecstcs.HeartbeatMessage) {
+	return func(message *ecstcs.HeartbeatMessage) {
 		seelog.Debug("Received HeartbeatMessage from tcs")
 		timer.Reset(utils.AddJitter(defaultHeartbeatTimeout, defaultHeartbeatJitter))
+		updatePublishMetricsInterval(client, message.PublishMetricsIntervalSeconds)
 	}
 }
 
 // ackPublishMetricHandler consumes the ack message from the backend. THe backend sends
-// the ack each time it processes a metric message.
-func ackPublishMetricHandler(timer *time.Timer) func(*ecstcs.AckPublishMetric) {
-	return func(*ecstcs.AckPublishMetric) {
+// the ack each time it processes a metric message. It also applies the publish interval
+// the message carries
+func ackPublishMetricHandler(timer *time.Timer, client tcsclient.ClientServer) func(*ecstcs.AckPublishMetric) {
+	return func(message *ecstcs.AckPublishMetric) {
 		seelog.Debug("Received AckPublishMetric from tcs")
 		timer.Reset(utils.AddJitter(defaultHeartbeatTimeout, defaultHeartbeatJitter))
+		updatePublishMetricsInterval(client, message.PublishMetricsIntervalSeconds)
+	}
+}
+
+// updatePublishMetricsInterval changes the interval at which the client publishes
+// metrics to the one the backend asked for, if any. The default interval is restored
+// if the backend asks for an interval of 0
+func updatePublishMetricsInterval(client tcsclient.ClientServer, intervalSeconds *int64) {
+	if intervalSeconds == nil {
+		return
+	}
+	interval := time.Duration(aws.Int64Value(intervalSeconds)) * time.Second
+	if interval <= 0 {
+		client.SetPublishMetricsInterval(0)
+		return
+	}
+	if interval < minPublishMetricsInterval || interval > maxPublishMetricsInterval {
+		seelog.Warnf("TCS asked to publish metrics every %s, limiting it between %s and %s",
+			interval.String(), minPublishMetricsInterval.String(), maxPublishMetricsInterval.String())
+		if interval < minPublishMetricsInterval {
+			interval = minPublishMetricsInterval
+		} else {
+			interval = maxPublishMetricsInterval
+		}
 	}
+	client.SetPublishMetricsInterval(interval)
 }
 
 // ackPublishHealthMetricHandler consumes the ack message from backend. The backend sends
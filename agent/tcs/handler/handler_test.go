@@ -33,8 +33,11 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
 	"github.com/aws/amazon-ecs-agent/agent/tcs/client"
 	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 	"github.com/aws/amazon-ecs-agent/agent/wsclient"
 	wsmock "github.com/aws/amazon-ecs-agent/agent/wsclient/mock/utils"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/mock/gomock"
@@ -65,8 +68,8 @@ func (*mockStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs
 	return req.Metadata, req.TaskMetrics, nil
 }
 
-func (*mockStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, error) {
-	return nil, fmt.Errorf("not implemented")
+func (*mockStatsEngine) ContainerDockerStats(taskARN string, id string) (*docker.Stats, bool, error) {
+	return nil, false, fmt.Errorf("not implemented")
 }
 
 func (*mockStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
@@ -123,7 +126,7 @@ func TestStartSession(t *testing.T) {
 	// Start a session with the test server.
 	go startSession(server.URL, testCfg, testCreds, &mockStatsEngine{},
 		defaultHeartbeatTimeout, defaultHeartbeatJitter,
-		testPublishMetricsInterval, deregisterInstanceEventStream)
+		testPublishMetricsInterval, deregisterInstanceEventStream, nil)
 
 	// startSession internally starts publishing metrics from the mockStatsEngine object.
 	time.Sleep(testPublishMetricsInterval)
@@ -187,7 +190,7 @@ func TestSessionConnectionClosedByRemote(t *testing.T) {
 	// Start a session with the test server.
 	err = startSession(server.URL, testCfg, testCreds, &mockStatsEngine{},
 		defaultHeartbeatTimeout, defaultHeartbeatJitter,
-		testPublishMetricsInterval, deregisterInstanceEventStream)
+		testPublishMetricsInterval, deregisterInstanceEventStream, nil)
 
 	if err == nil {
 		t.Error("Expected io.EOF on closed connection")
@@ -224,7 +227,7 @@ func TestConnectionInactiveTimeout(t *testing.T) {
 	// Start a session with the test server.
 	err = startSession(server.URL, testCfg, testCreds, &mockStatsEngine{},
 		50*time.Millisecond, 100*time.Millisecond,
-		testPublishMetricsInterval, deregisterInstanceEventStream)
+		testPublishMetricsInterval, deregisterInstanceEventStream, nil)
 	// if we are not blocked here, then the test pass as it will reconnect in StartSession
 	assert.Error(t, err, "Close the connection should cause the tcs client return error")
 
@@ -241,12 +244,114 @@ func TestDiscoverEndpointAndStartSession(t *testing.T) {
 	mockEcs := mock_api.NewMockECSClient(ctrl)
 	mockEcs.EXPECT().DiscoverTelemetryEndpoint(gomock.Any()).Return("", errors.New("error"))
 
-	err := startTelemetrySession(TelemetrySessionParams{ECSClient: mockEcs}, nil)
+	err := startTelemetrySession(TelemetrySessionParams{ECSClient: mockEcs}, nil, nil)
 	if err == nil {
 		t.Error("Expected error from startTelemetrySession when DiscoverTelemetryEndpoint returns error")
 	}
 }
 
+func TestReconnectDelay(t *testing.T) {
+	backoff := utils.NewSimpleBackoff(time.Second, time.Minute, 0, 2)
+
+	// Errors back off exponentially
+	assert.Equal(t, time.Second, reconnectDelay(errors.New("error"), time.Hour, backoff))
+	assert.Equal(t, 2*time.Second, reconnectDelay(errors.New("error"), time.Hour, backoff))
+
+	// Sessions closed right away don't reset the backoff
+	assert.Equal(t, 4*time.Second, reconnectDelay(io.EOF, time.Second, backoff))
+
+	// The wait asked for by the backend is honored, up to a limit
+	delay := reconnectDelay(&wsclient.RetryAfterError{RetryAfter: 5 * time.Minute}, 0, backoff)
+	assert.True(t, delay >= 5*time.Minute && delay <= 5*time.Minute+reconnectJitter, "unexpected delay %s", delay)
+	delay = reconnectDelay(&wsclient.RetryAfterError{RetryAfter: 24 * time.Hour}, 0, backoff)
+	assert.True(t, delay >= maxRetryAfter && delay <= maxRetryAfter+reconnectJitter, "unexpected delay %s", delay)
+
+	// Stable sessions closed for a valid reason reset the backoff
+	delay = reconnectDelay(nil, time.Hour, backoff)
+	assert.True(t, delay <= reconnectJitter, "unexpected delay %s", delay)
+	assert.Equal(t, time.Second, reconnectDelay(errors.New("error"), time.Hour, backoff))
+}
+
+// fakeTime is a clock that only advances when sleeping
+type fakeTime struct {
+	now time.Time
+}
+
+func (clock *fakeTime) Now() time.Time {
+	return clock.now
+}
+
+func (clock *fakeTime) Sleep(d time.Duration) {
+	clock.now = clock.now.Add(d)
+}
+
+func (clock *fakeTime) After(d time.Duration) <-chan time.Time {
+	clock.Sleep(d)
+	c := make(chan time.Time, 1)
+	c <- clock.now
+	return c
+}
+
+func (clock *fakeTime) AfterFunc(d time.Duration, f func()) ttime.Timer {
+	return time.AfterFunc(d, f)
+}
+
+func TestWaitToReconnectCollectsMetrics(t *testing.T) {
+	clock := &fakeTime{now: time.Now()}
+	params := &TelemetrySessionParams{Cfg: testCfg, _time: clock}
+	metricsBuffer := tcsclient.NewMetricsBuffer(time.Hour, 10)
+	start := clock.Now()
+
+	lastCollected := params.waitToReconnect(&mockStatsEngine{}, metricsBuffer, 70*time.Second, start)
+	assert.Equal(t, start.Add(70*time.Second), clock.Now())
+	assert.Equal(t, start.Add(3*defaultPublishMetricsInterval), lastCollected)
+	assert.Equal(t, 3, metricsBuffer.Len())
+
+	// The metrics aren't collected before the publish interval has passed
+	// since they were last collected
+	lastCollected = params.waitToReconnect(&mockStatsEngine{}, metricsBuffer, 5*time.Second, lastCollected)
+	assert.Equal(t, start.Add(3*defaultPublishMetricsInterval), lastCollected)
+	assert.Equal(t, 3, metricsBuffer.Len())
+}
+
+func TestWaitToReconnectMetricsDisabled(t *testing.T) {
+	clock := &fakeTime{now: time.Now()}
+	params := &TelemetrySessionParams{Cfg: &config.Config{DisableMetrics: true}, _time: clock}
+	metricsBuffer := tcsclient.NewMetricsBuffer(time.Hour, 10)
+	start := clock.Now()
+
+	lastCollected := params.waitToReconnect(&mockStatsEngine{}, metricsBuffer, time.Minute, start)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+	assert.Equal(t, start, lastCollected)
+	assert.Equal(t, 0, metricsBuffer.Len())
+}
+
+// intervalClientServer records the publish intervals it's asked to use
+type intervalClientServer struct {
+	wsclient.ClientServer
+	intervals []time.Duration
+}
+
+func (client *intervalClientServer) SetPublishMetricsInterval(interval time.Duration) {
+	client.intervals = append(client.intervals, interval)
+}
+
+func TestUpdatePublishMetricsInterval(t *testing.T) {
+	client := &intervalClientServer{}
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	heartbeatHandler(timer, client)(&ecstcs.HeartbeatMessage{})
+	assert.Empty(t, client.intervals, "the interval shouldn't change if the backend doesn't specify one")
+
+	heartbeatHandler(timer, client)(&ecstcs.HeartbeatMessage{PublishMetricsIntervalSeconds: aws.Int64(60)})
+	ackPublishMetricHandler(timer, client)(&ecstcs.AckPublishMetric{PublishMetricsIntervalSeconds: aws.Int64(1)})
+	ackPublishMetricHandler(timer, client)(&ecstcs.AckPublishMetric{PublishMetricsIntervalSeconds: aws.Int64(3600)})
+	heartbeatHandler(timer, client)(&ecstcs.HeartbeatMessage{PublishMetricsIntervalSeconds: aws.Int64(0)})
+	assert.Equal(t, []time.Duration{time.Minute, minPublishMetricsInterval, maxPublishMetricsInterval, 0},
+		client.intervals)
+}
+
 func getPayloadFromRequest(request string) (string, error) {
 	lines := strings.Split(request, "\r\n")
 	if len(lines) > 0 {
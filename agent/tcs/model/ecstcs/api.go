@@ -39,6 +39,8 @@ type AckPublishMetric struct {
 	_ struct{} `type:"structure"`
 
 	Message *string `locationName:"message" type:"string"`
+
+	PublishMetricsIntervalSeconds *int64 `locationName:"publishMetricsIntervalSeconds" type:"integer"`
 }
 
 // String returns the string representation
@@ -92,10 +94,14 @@ func (s CWStatsSet) GoString() string {
 type ContainerHealth struct {
 	_ struct{} `type:"structure"`
 
+	ConsecutiveFailures *int64 `locationName:"consecutiveFailures" type:"integer"`
+
 	ContainerName *string `locationName:"containerName" type:"string"`
 
 	HealthStatus *string `locationName:"healthStatus" type:"string" enum:"HealthStatus"`
 
+	LastCheckedAt *time.Time `locationName:"lastCheckedAt" type:"timestamp"`
+
 	StatusSince *time.Time `locationName:"statusSince" type:"timestamp"`
 }
 
@@ -114,7 +120,15 @@ type ContainerMetric struct {
 
 	CpuStatsSet *CWStatsSet `locationName:"cpuStatsSet" type:"structure"`
 
+	CpuThrottlingStatsSet *CpuThrottlingStatsSet `locationName:"cpuThrottlingStatsSet" type:"structure"`
+
 	MemoryStatsSet *CWStatsSet `locationName:"memoryStatsSet" type:"structure"`
+
+	MemoryWorkingSetStatsSet *CWStatsSet `locationName:"memoryWorkingSetStatsSet" type:"structure"`
+
+	NetworkStatsSet *NetworkStatsSet `locationName:"networkStatsSet" type:"structure"`
+
+	StorageStatsSet *StorageStatsSet `locationName:"storageStatsSet" type:"structure"`
 }
 
 // String returns the string representation
@@ -127,6 +141,24 @@ func (s ContainerMetric) GoString() string {
 	return s.String()
 }
 
+type CpuThrottlingStatsSet struct {
+	_ struct{} `type:"structure"`
+
+	ThrottledPeriods *CWStatsSet `locationName:"throttledPeriods" type:"structure"`
+
+	ThrottledTime *CWStatsSet `locationName:"throttledTime" type:"structure"`
+}
+
+// String returns the string representation
+func (s CpuThrottlingStatsSet) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s CpuThrottlingStatsSet) GoString() string {
+	return s.String()
+}
+
 type HealthMetadata struct {
 	_ struct{} `type:"structure"`
 
@@ -134,6 +166,8 @@ type HealthMetadata struct {
 
 	ContainerInstance *string `locationName:"containerInstance" type:"string"`
 
+	DockerStatus *string `locationName:"dockerStatus" type:"string" enum:"HealthStatus"`
+
 	Fin *bool `locationName:"fin" type:"boolean"`
 
 	MessageId *string `locationName:"messageId" type:"string"`
@@ -169,6 +203,8 @@ type HeartbeatMessage struct {
 	_ struct{} `type:"structure"`
 
 	Healthy *bool `locationName:"healthy" type:"boolean"`
+
+	PublishMetricsIntervalSeconds *int64 `locationName:"publishMetricsIntervalSeconds" type:"integer"`
 }
 
 // String returns the string representation
@@ -218,6 +254,8 @@ type MetricsMetadata struct {
 
 	ContainerInstance *string `locationName:"containerInstance" type:"string"`
 
+	DroppedMessageCount *int64 `locationName:"droppedMessageCount" type:"integer"`
+
 	Fin *bool `locationName:"fin" type:"boolean"`
 
 	Idle *bool `locationName:"idle" type:"boolean"`
@@ -235,6 +273,28 @@ func (s MetricsMetadata) GoString() string {
 	return s.String()
 }
 
+type NetworkStatsSet struct {
+	_ struct{} `type:"structure"`
+
+	RxBytes *CWStatsSet `locationName:"rxBytes" type:"structure"`
+
+	RxPackets *CWStatsSet `locationName:"rxPackets" type:"structure"`
+
+	TxBytes *CWStatsSet `locationName:"txBytes" type:"structure"`
+
+	TxPackets *CWStatsSet `locationName:"txPackets" type:"structure"`
+}
+
+// String returns the string representation
+func (s NetworkStatsSet) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s NetworkStatsSet) GoString() string {
+	return s.String()
+}
+
 type PublishHealthInput struct {
 	_ struct{} `type:"structure"`
 
@@ -447,6 +507,28 @@ func (s StopTelemetrySessionMessage) GoString() string {
 	return s.String()
 }
 
+type StorageStatsSet struct {
+	_ struct{} `type:"structure"`
+
+	ReadBytes *CWStatsSet `locationName:"readBytes" type:"structure"`
+
+	ReadOps *CWStatsSet `locationName:"readOps" type:"structure"`
+
+	WriteBytes *CWStatsSet `locationName:"writeBytes" type:"structure"`
+
+	WriteOps *CWStatsSet `locationName:"writeOps" type:"structure"`
+}
+
+// String returns the string representation
+func (s StorageStatsSet) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s StorageStatsSet) GoString() string {
+	return s.String()
+}
+
 type TaskHealth struct {
 	_ struct{} `type:"structure"`
 
@@ -472,13 +554,21 @@ func (s TaskHealth) GoString() string {
 type TaskMetric struct {
 	_ struct{} `type:"structure"`
 
+	CachedPullCount *int64 `locationName:"cachedPullCount" type:"integer"`
+
 	ContainerMetrics []*ContainerMetric `locationName:"containerMetrics" type:"list"`
 
+	NetworkStatsSet *NetworkStatsSet `locationName:"networkStatsSet" type:"structure"`
+
+	PullDurationStatsSet *CWStatsSet `locationName:"pullDurationStatsSet" type:"structure"`
+
 	TaskArn *string `locationName:"taskArn" type:"string"`
 
 	TaskDefinitionFamily *string `locationName:"taskDefinitionFamily" type:"string"`
 
 	TaskDefinitionVersion *string `locationName:"taskDefinitionVersion" type:"string"`
+
+	TaskStatsSet *TaskStatsSet `locationName:"taskStatsSet" type:"structure"`
 }
 
 // String returns the string representation
@@ -490,3 +580,34 @@ func (s TaskMetric) String() string {
 func (s TaskMetric) GoString() string {
 	return s.String()
 }
+
+type TaskStatsSet struct {
+	_ struct{} `type:"structure"`
+
+	CpuStatsSet *CWStatsSet `locationName:"cpuStatsSet" type:"structure"`
+
+	MemoryStatsSet *CWStatsSet `locationName:"memoryStatsSet" type:"structure"`
+
+	MemoryUtilizationStatsSet *CWStatsSet `locationName:"memoryUtilizationStatsSet" type:"structure"`
+}
+
+// String returns the string representation
+func (s TaskStatsSet) String() string {
+	return awsutil.Prettify(s)
+}
+
+// GoString returns the string representation
+func (s TaskStatsSet) GoString() string {
+	return s.String()
+}
+
+const (
+	// HealthStatusHealthy is a HealthStatus enum value
+	HealthStatusHealthy = "HEALTHY"
+
+	// HealthStatusUnhealthy is a HealthStatus enum value
+	HealthStatusUnhealthy = "UNHEALTHY"
+
+	// HealthStatusUnknown is a HealthStatus enum value
+	HealthStatusUnknown = "UNKNOWN"
+)
@@ -16,8 +16,20 @@ package cipher
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
 )
 
+// tlsVersions maps the names of the TLS versions that can be configured as
+// the minimum version of connections to their values
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+}
+
 // Only support a subset of ciphers, corresponding cipher suite names can be found here: https://golang.org/pkg/crypto/tls/#Config
 var SupportedCipherSuites = []uint16{
 	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
@@ -35,3 +47,30 @@ var SupportedCipherSuites = []uint16{
 func WithSupportedCipherSuites(config *tls.Config) {
 	config.CipherSuites = SupportedCipherSuites
 }
+
+// ParseTLSVersion returns the TLS version named like "1.2"
+func ParseTLSVersion(name string) (uint16, error) {
+	version, ok := tlsVersions[name]
+	if !ok {
+		return 0, errors.Errorf("unsupported TLS version %q, supported versions are 1.0, 1.1 and 1.2", name)
+	}
+	return version, nil
+}
+
+// LoadCertPool returns the pool of the system root CAs with the certificates
+// of the PEM bundle at the path added. The pool only holds the certificates of
+// the bundle on platforms without a system pool
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	bundle, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read the CA bundle %s", path)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.Errorf("no PEM encoded certificate found in the CA bundle %s", path)
+	}
+	return pool, nil
+}
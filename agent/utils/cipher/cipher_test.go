@@ -0,0 +1,69 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cipher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	version, err := ParseTLSVersion("1.2")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), version)
+
+	_, err = ParseTLSVersion("TLS1.2")
+	assert.Error(t, err)
+}
+
+func TestLoadCertPool(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	defer server.Close()
+
+	bundle, err := ioutil.TempFile("", "ca-bundle")
+	require.NoError(t, err)
+	defer os.Remove(bundle.Name())
+	pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	bundle.Close()
+
+	pool, err := LoadCertPool(bundle.Name())
+	require.NoError(t, err)
+	_, err = server.Certificate().Verify(x509.VerifyOptions{Roots: pool})
+	assert.NoError(t, err, "the certificate of the bundle should be trusted")
+}
+
+func TestLoadCertPoolInvalidBundle(t *testing.T) {
+	_, err := LoadCertPool("/does/not/exist.pem")
+	assert.Error(t, err)
+
+	bundle, err := ioutil.TempFile("", "ca-bundle")
+	require.NoError(t, err)
+	defer os.Remove(bundle.Name())
+	bundle.WriteString("not a certificate")
+	bundle.Close()
+
+	_, err = LoadCertPool(bundle.Name())
+	assert.Error(t, err)
+}
@@ -29,11 +29,13 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/wsclient/wsconn"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
@@ -115,7 +117,16 @@ type ClientServerImpl struct {
 	// RequestHandlers is a map from message types to handler functions of the
 	// form:
 	//     "FooMessage": func(message *ecsacs.FooMessage)
+	// or:
+	//     "FooMessage": func(message *ecsacs.FooMessage) error
 	RequestHandlers map[string]RequestHandler
+	// UnrecognizedMessageHandler is an optional callback that, if set, is
+	// called with the type and the raw body of the messages of types the
+	// client doesn't recognize. The connection keeps being served either way
+	UnrecognizedMessageHandler func(messageType string, message json.RawMessage)
+	// MetricsEndpoint is the endpoint the metrics of the messages received
+	// from the backend are recorded for, like metrics.ACSEndpoint
+	MetricsEndpoint string
 	// AnyRequestHandler is a request handler that, if set, is called on every
 	// message with said message. It will be called before a RequestHandler is
 	// called. It must take a single interface{} argument.
@@ -167,8 +178,10 @@ func (cs *ClientServerImpl) Connect() error {
 	}
 
 	timeoutDialer := &net.Dialer{Timeout: wsConnectTimeout}
-	tlsConfig := &tls.Config{ServerName: parsedURL.Host, InsecureSkipVerify: cs.AgentConfig.AcceptInsecureCert}
-	cipher.WithSupportedCipherSuites(tlsConfig)
+	tlsConfig, err := cs.tlsConfig(parsedURL)
+	if err != nil {
+		return err
+	}
 
 	// Ensure that NO_PROXY gets set
 	noProxy := os.Getenv("NO_PROXY")
@@ -183,11 +196,19 @@ func (cs *ClientServerImpl) Connect() error {
 		}
 	}
 
+	proxyURL, err := websocketProxy(cs.AgentConfig.WebsocketProxy, parsedURL)
+	if err != nil {
+		return err
+	}
+	if proxyURL != nil {
+		seelog.Infof("Establishing the Websocket connection to %s through the proxy %s", parsedURL.Host, proxyURL.Host)
+	}
+
 	dialer := websocket.Dialer{
 		ReadBufferSize:   readBufSize,
 		WriteBufferSize:  writeBufSize,
 		TLSClientConfig:  tlsConfig,
-		Proxy:            http.ProxyFromEnvironment,
+		Proxy:            http.ProxyURL(proxyURL),
 		NetDial:          timeoutDialer.Dial,
 		HandshakeTimeout: wsHandshakeTimeout,
 	}
@@ -213,8 +234,12 @@ func (cs *ClientServerImpl) Connect() error {
 			}
 		}
 		seelog.Warnf("Error creating a websocket client: %v", err)
-		return errors.Wrapf(err, "websocket client: unable to dial %s response: %s",
+		err = errors.Wrapf(err, "websocket client: unable to dial %s response: %s",
 			parsedURL.Host, string(resp))
+		if retryAfter, ok := retryAfterHint(httpResponse, time.Now()); ok {
+			return &RetryAfterError{RetryAfter: retryAfter, err: err}
+		}
+		return err
 	}
 
 	cs.writeLock.Lock()
@@ -225,6 +250,27 @@ func (cs *ClientServerImpl) Connect() error {
 	return nil
 }
 
+// tlsConfig returns the TLS configuration of the connection to the url
+func (cs *ClientServerImpl) tlsConfig(parsedURL *url.URL) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: parsedURL.Hostname(), InsecureSkipVerify: cs.AgentConfig.AcceptInsecureCert}
+	cipher.WithSupportedCipherSuites(tlsConfig)
+	if cs.AgentConfig.WebsocketTLSMinVersion != "" {
+		minVersion, err := cipher.ParseTLSVersion(cs.AgentConfig.WebsocketTLSMinVersion)
+		if err != nil {
+			return nil, errors.Wrap(err, "websocket client")
+		}
+		tlsConfig.MinVersion = minVersion
+	}
+	if cs.AgentConfig.WebsocketCABundlePath != "" {
+		rootCAs, err := cipher.LoadCertPool(cs.AgentConfig.WebsocketCABundlePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "websocket client")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	return tlsConfig, nil
+}
+
 // IsReady gives a boolean response that informs the caller if the websocket
 // connection is fully established.
 func (cs *ClientServerImpl) IsReady() bool {
@@ -304,16 +350,25 @@ func (cs *ClientServerImpl) Disconnect(...interface{}) error {
 
 // AddRequestHandler adds a request handler to this client.
 // A request handler *must* be a function taking a single argument, and that
-// argument *must* be a pointer to a recognized 'ecsacs' struct.
+// argument *must* be a pointer to a recognized 'ecsacs' struct. It may return
+// an error, which is logged and recorded in the metrics of the messages.
 // E.g. if you desired to handle messages from acs of type 'FooMessage', you
 // would pass the following handler in:
 //     func(message *ecsacs.FooMessage)
 // This function will panic if the passed in function does not have one pointer
-// argument or the argument is not a recognized type.
-// Additionally, the request handler will block processing of further messages
-// on this connection so it's important that it return quickly.
+// argument, the argument is not a recognized type or the function returns
+// anything but an error.
+// Each request handler is called on its own goroutine with the messages of its
+// type in order. The messages of the type are queued up while the handler
+// runs, and processing of further messages on this connection blocks once the
+// queue is full, so it's still important that it return quickly.
 func (cs *ClientServerImpl) AddRequestHandler(f RequestHandler) {
-	firstArg := reflect.TypeOf(f).In(0)
+	handlerType := reflect.TypeOf(f)
+	if handlerType.NumOut() > 1 ||
+		(handlerType.NumOut() == 1 && handlerType.Out(0) != reflect.TypeOf((*error)(nil)).Elem()) {
+		panic("AddRequestHandler called with invalid function; it may only return an error")
+	}
+	firstArg := handlerType.In(0)
 	firstArgTypeStr := firstArg.Elem().Name()
 	recognizedTypes := cs.GetRecognizedTypes()
 	_, ok := recognizedTypes[firstArgTypeStr]
@@ -364,8 +419,12 @@ func (cs *ClientServerImpl) WriteMessage(send []byte) error {
 }
 
 // ConsumeMessages reads messages from the websocket connection and handles read
-// messages from an active connection.
+// messages from an active connection. The request handlers added before the
+// call handle the messages until it returns.
 func (cs *ClientServerImpl) ConsumeMessages() error {
+	router := newRequestRouter(cs.RequestHandlers, cs.MetricsEndpoint)
+	defer router.stop()
+
 	for {
 		if err := cs.SetReadDeadline(time.Now().Add(cs.RWTimeout)); err != nil {
 			return err
@@ -378,7 +437,7 @@ func (cs *ClientServerImpl) ConsumeMessages() error {
 				// maybe not fatal though, we'll try to process it anyways
 				seelog.Errorf("Unexpected messageType: %v", messageType)
 			}
-			cs.handleMessage(message)
+			cs.handleMessage(router, message)
 
 		case permissibleCloseCode(err):
 			seelog.Debugf("Connection closed for a valid reason: %s", err)
@@ -425,26 +484,45 @@ func (cs *ClientServerImpl) CreateRequestMessage(input interface{}) ([]byte, err
 
 // handleMessage dispatches a message to the correct 'requestHandler' for its
 // type. If no request handler is found, the message is discarded.
-func (cs *ClientServerImpl) handleMessage(data []byte) {
+func (cs *ClientServerImpl) handleMessage(router *requestRouter, data []byte) {
 	typedMessage, typeStr, err := DecodeData(data, cs.TypeDecoder)
+	if _, ok := err.(*UnrecognizedWSRequestType); ok && typeStr != "" {
+		cs.handleUnrecognizedMessage(typeStr, data)
+		return
+	}
 	if err != nil {
 		seelog.Warnf("Unable to handle message from backend: %v", err)
 		return
 	}
 
 	seelog.Debugf("Received message of type: %s", typeStr)
+	metrics.RecordMessageReceived(cs.MetricsEndpoint, typeStr)
 
 	if cs.AnyRequestHandler != nil {
 		reflect.ValueOf(cs.AnyRequestHandler).Call([]reflect.Value{reflect.ValueOf(typedMessage)})
 	}
 
-	if handler, ok := cs.RequestHandlers[typeStr]; ok {
-		reflect.ValueOf(handler).Call([]reflect.Value{reflect.ValueOf(typedMessage)})
-	} else {
+	if !router.route(typeStr, typedMessage) {
 		seelog.Infof("No handler for message type: %s", typeStr)
 	}
 }
 
+// handleUnrecognizedMessage passes a message of a type the client doesn't
+// recognize to the UnrecognizedMessageHandler, if any
+func (cs *ClientServerImpl) handleUnrecognizedMessage(typeStr string, data []byte) {
+	seelog.Warnf("Received message of unrecognized type: %s", typeStr)
+	metrics.RecordMessageReceived(cs.MetricsEndpoint, unrecognizedMessageType)
+	if cs.UnrecognizedMessageHandler == nil {
+		return
+	}
+	raw := &ReceivedMessage{}
+	if err := json.Unmarshal(data, raw); err != nil {
+		seelog.Warnf("Unable to parse message of unrecognized type %s: %v", typeStr, err)
+		return
+	}
+	cs.UnrecognizedMessageHandler(typeStr, raw.Message)
+}
+
 func websocketScheme(httpScheme string) (string, error) {
 	// gorilla/websocket expects the websocket scheme (ws[s]://)
 	var wsScheme string
@@ -459,6 +537,32 @@ func websocketScheme(httpScheme string) (string, error) {
 	return wsScheme, nil
 }
 
+// retryAfterHint returns how long the Retry-After header of the response asks
+// the client to wait, from either a number of seconds or a date
+func retryAfterHint(httpResponse *http.Response, now time.Time) (time.Duration, bool) {
+	if httpResponse == nil {
+		return 0, false
+	}
+	retryAfter := strings.TrimSpace(httpResponse.Header.Get("Retry-After"))
+	if retryAfter == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(retryAfter)
+	if err != nil {
+		return 0, false
+	}
+	if date.Before(now) {
+		return 0, true
+	}
+	return date.Sub(now), true
+}
+
 // See https://github.com/gorilla/websocket/blob/87f6f6a22ebfbc3f89b9ccdc7fddd1b914c095f9/conn.go#L650
 func permissibleCloseCode(err error) bool {
 	return websocket.IsCloseError(err,
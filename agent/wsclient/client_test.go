@@ -16,11 +16,19 @@
 package wsclient
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -267,3 +275,238 @@ func TestSetReadDeadlineError(t *testing.T) {
 	)
 	assert.Error(t, cs.ConsumeMessages())
 }
+
+func TestRetryAfterHint(t *testing.T) {
+	now := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		name       string
+		retryAfter string
+		expected   time.Duration
+		ok         bool
+	}{
+		{"no header", "", 0, false},
+		{"seconds", "120", 2 * time.Minute, true},
+		{"negative seconds", "-1", 0, false},
+		{"date", now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{"past date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"invalid", "soon", 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			response := &http.Response{Header: http.Header{}}
+			if tc.retryAfter != "" {
+				response.Header.Set("Retry-After", tc.retryAfter)
+			}
+			retryAfter, ok := retryAfterHint(response, now)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, retryAfter)
+		})
+	}
+
+	_, ok := retryAfterHint(nil, now)
+	assert.False(t, ok)
+}
+
+func TestConsumeMessagesHandlesTypesConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := mock_wsconn.NewMockWebsocketConn(ctrl)
+	cs := &ClientServerImpl{
+		conn:            conn,
+		TypeDecoder:     BuildTypeDecoder([]interface{}{ecsacs.PayloadMessage{}, ecsacs.HeartbeatMessage{}}),
+		RequestHandlers: make(map[string]RequestHandler),
+		MetricsEndpoint: "concurrent-test",
+	}
+
+	payloadStarted := make(chan struct{})
+	releasePayload := make(chan struct{})
+	payloadHandled := make(chan struct{})
+	cs.AddRequestHandler(func(message *ecsacs.PayloadMessage) {
+		close(payloadStarted)
+		<-releasePayload
+		close(payloadHandled)
+	})
+	heartbeatHandled := make(chan struct{})
+	cs.AddRequestHandler(func(message *ecsacs.HeartbeatMessage) error {
+		close(heartbeatHandled)
+		return nil
+	})
+
+	conn.EXPECT().SetReadDeadline(gomock.Any()).Return(nil).Times(3)
+	gomock.InOrder(
+		conn.EXPECT().ReadMessage().Return(websocket.TextMessage,
+			[]byte(`{"type":"PayloadMessage","message":{"messageId":"1"}}`), nil),
+		conn.EXPECT().ReadMessage().Return(websocket.TextMessage,
+			[]byte(`{"type":"HeartbeatMessage","message":{"healthy":true}}`), nil),
+		// The connection is closed once both messages are picked up, as the
+		// messages still queued are dropped when the connection is closed
+		conn.EXPECT().ReadMessage().Do(func() {
+			<-payloadStarted
+			select {
+			case <-heartbeatHandled:
+			case <-time.After(time.Second):
+				t.Error("heartbeat should be handled while the payload handler is busy")
+			}
+		}).Return(0, nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}),
+	)
+
+	assert.Equal(t, io.EOF, cs.ConsumeMessages())
+	close(releasePayload)
+	<-payloadHandled
+}
+
+func TestConsumeMessagesUnrecognizedType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := mock_wsconn.NewMockWebsocketConn(ctrl)
+	var unrecognizedType string
+	var unrecognizedMessage []byte
+	cs := &ClientServerImpl{
+		conn:            conn,
+		TypeDecoder:     BuildTypeDecoder([]interface{}{ecsacs.HeartbeatMessage{}}),
+		RequestHandlers: make(map[string]RequestHandler),
+		MetricsEndpoint: "unrecognized-test",
+		UnrecognizedMessageHandler: func(messageType string, message json.RawMessage) {
+			unrecognizedType = messageType
+			unrecognizedMessage = message
+		},
+	}
+	heartbeatHandled := make(chan struct{})
+	cs.AddRequestHandler(func(message *ecsacs.HeartbeatMessage) {
+		close(heartbeatHandled)
+	})
+
+	unrecognizedBefore := messageSample("unrecognized-test", unrecognizedMessageType,
+		"ecs_agent_backend_messages_received_total", "")
+	heartbeatsBefore := messageSample("unrecognized-test", "HeartbeatMessage",
+		"ecs_agent_backend_messages_received_total", "")
+
+	conn.EXPECT().SetReadDeadline(gomock.Any()).Return(nil).Times(3)
+	gomock.InOrder(
+		conn.EXPECT().ReadMessage().Return(websocket.TextMessage,
+			[]byte(`{"type":"FutureMessage","message":{"messageId":"1"}}`), nil),
+		conn.EXPECT().ReadMessage().Return(websocket.TextMessage,
+			[]byte(`{"type":"HeartbeatMessage","message":{"healthy":true}}`), nil),
+		conn.EXPECT().ReadMessage().Do(func() {
+			<-heartbeatHandled
+		}).Return(0, nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}),
+	)
+
+	// The connection keeps being served after the unrecognized message
+	assert.Equal(t, io.EOF, cs.ConsumeMessages())
+	assert.Equal(t, "FutureMessage", unrecognizedType)
+	assert.JSONEq(t, `{"messageId":"1"}`, string(unrecognizedMessage))
+	assert.Equal(t, float64(1), messageSample("unrecognized-test", unrecognizedMessageType,
+		"ecs_agent_backend_messages_received_total", "")-unrecognizedBefore)
+	assert.Equal(t, float64(1), messageSample("unrecognized-test", "HeartbeatMessage",
+		"ecs_agent_backend_messages_received_total", "")-heartbeatsBefore)
+}
+
+// TestConsumeMessagesReconnectsDontLeakGoroutines tests that the goroutines
+// handling the messages of a connection exit once the connection is closed,
+// when the consumers of the request handlers are stopped with it
+func TestConsumeMessagesReconnectsDontLeakGoroutines(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	goroutinesBefore := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		conn := mock_wsconn.NewMockWebsocketConn(ctrl)
+		cs := &ClientServerImpl{
+			conn:            conn,
+			TypeDecoder:     BuildTypeDecoder([]interface{}{ecsacs.HeartbeatMessage{}}),
+			RequestHandlers: make(map[string]RequestHandler),
+			MetricsEndpoint: "reconnect-test",
+		}
+		// The handler pushes the messages to a consumer, which is stopped
+		// along with the connection without reading them
+		ctx, cancel := context.WithCancel(context.Background())
+		messageBuffer := make(chan *ecsacs.HeartbeatMessage)
+		cs.AddRequestHandler(func(message *ecsacs.HeartbeatMessage) {
+			select {
+			case messageBuffer <- message:
+			case <-ctx.Done():
+			}
+		})
+
+		conn.EXPECT().SetReadDeadline(gomock.Any()).Return(nil).Times(4)
+		gomock.InOrder(
+			conn.EXPECT().ReadMessage().Return(websocket.TextMessage,
+				[]byte(`{"type":"HeartbeatMessage","message":{"healthy":true}}`), nil).Times(3),
+			conn.EXPECT().ReadMessage().Return(0, nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}),
+		)
+		assert.Equal(t, io.EOF, cs.ConsumeMessages())
+		cancel()
+	}
+
+	for start := time.Now(); runtime.NumGoroutine() > goroutinesBefore; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("goroutines leaked across reconnects: %d before, %d after",
+				goroutinesBefore, runtime.NumGoroutine())
+		}
+	}
+}
+
+func TestAddRequestHandlerInvalidResult(t *testing.T) {
+	cs := &ClientServerImpl{
+		TypeDecoder:     BuildTypeDecoder([]interface{}{ecsacs.HeartbeatMessage{}}),
+		RequestHandlers: make(map[string]RequestHandler),
+	}
+	assert.Panics(t, func() {
+		cs.AddRequestHandler(func(message *ecsacs.HeartbeatMessage) bool { return true })
+	})
+}
+
+// writeCABundle writes the certificate of the server to a PEM bundle, returning
+// its path
+func writeCABundle(t *testing.T, server *httptest.Server) string {
+	bundle, err := ioutil.TempFile("", "ca-bundle")
+	require.NoError(t, err)
+	defer bundle.Close()
+	require.NoError(t, pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	return bundle.Name()
+}
+
+func TestConnectCABundle(t *testing.T) {
+	closeWS := make(chan []byte)
+	defer close(closeWS)
+	mockServer, _, _, _, _ := utils.GetMockServer(closeWS)
+	mockServer.StartTLS()
+	defer mockServer.Close()
+
+	cs := getClientServer(mockServer.URL)
+	cs.AgentConfig.AcceptInsecureCert = false
+	assert.Error(t, cs.Connect(), "the certificate of the server shouldn't be trusted without the bundle")
+
+	caBundle := writeCABundle(t, mockServer)
+	defer os.Remove(caBundle)
+	cs.AgentConfig.WebsocketCABundlePath = caBundle
+	require.NoError(t, cs.Connect())
+	cs.Close()
+}
+
+func TestConnectTLSMinVersion(t *testing.T) {
+	closeWS := make(chan []byte)
+	defer close(closeWS)
+	mockServer, _, _, _, _ := utils.GetMockServer(closeWS)
+	mockServer.StartTLS()
+	defer mockServer.Close()
+
+	cs := getClientServer(mockServer.URL)
+	cs.AgentConfig.WebsocketTLSMinVersion = "1.2"
+	require.NoError(t, cs.Connect())
+	cs.Close()
+
+	legacyServer, _, _, _, _ := utils.GetMockServer(closeWS)
+	legacyServer.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}
+	legacyServer.StartTLS()
+	defer legacyServer.Close()
+
+	cs = getClientServer(legacyServer.URL)
+	cs.AgentConfig.WebsocketTLSMinVersion = "1.2"
+	assert.Error(t, cs.Connect(), "connections below the minimum TLS version should fail")
+
+	cs.AgentConfig.WebsocketTLSMinVersion = "1.3"
+	assert.Error(t, cs.Connect(), "unsupported TLS versions should fail")
+}
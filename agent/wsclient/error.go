@@ -13,7 +13,10 @@
 
 package wsclient
 
-import "reflect"
+import (
+	"reflect"
+	"time"
+)
 
 // WSUnretriableErrors defines methods to retrieve the list of unretriable
 // errors.
@@ -71,3 +74,16 @@ func (err *WSError) Retry() bool {
 	}
 	return true
 }
+
+// RetryAfterError is returned when the backend refused the connection with a
+// Retry-After header, asking the client to wait before reconnecting
+type RetryAfterError struct {
+	// RetryAfter is how long the backend asked the client to wait
+	RetryAfter time.Duration
+	err        error
+}
+
+// Error returns the error of the refused connection
+func (err *RetryAfterError) Error() string {
+	return err.err.Error()
+}
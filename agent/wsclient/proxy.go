@@ -0,0 +1,129 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wsclient
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// websocketProxy returns the url of the HTTP proxy the websocket connection to
+// the target is tunneled through with a CONNECT request, or nil if the
+// connection is established directly. The proxy configured in the agent config
+// takes precedence over the HTTPS_PROXY and HTTP_PROXY environment variables,
+// and hosts matching NO_PROXY are never proxied. Unlike
+// http.ProxyFromEnvironment, the environment is read on every connection, as
+// the agent sets NO_PROXY itself when it's not set
+func websocketProxy(configuredProxy string, target *url.URL) (*url.URL, error) {
+	if excludedFromProxy(target, getEnvAny("NO_PROXY", "no_proxy")) {
+		return nil, nil
+	}
+	proxy := configuredProxy
+	if proxy == "" {
+		switch target.Scheme {
+		case "https", "wss":
+			proxy = getEnvAny("HTTPS_PROXY", "https_proxy")
+		default:
+			proxy = getEnvAny("HTTP_PROXY", "http_proxy")
+		}
+	}
+	if proxy == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		// Proxies are often set as host:port, without a scheme
+		proxyURL, err = url.Parse("http://" + proxy)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "websocket client: invalid proxy")
+	}
+	if proxyURL.Scheme != "http" {
+		return nil, errors.Errorf("websocket client: unsupported proxy scheme %q, only http proxies are supported",
+			proxyURL.Scheme)
+	}
+	return proxyURL, nil
+}
+
+// excludedFromProxy returns true if the host of the target matches an entry of
+// the comma separated NO_PROXY list. Entries are host names, which also match
+// their subdomains, IP addresses or CIDR blocks, optionally followed by a port.
+// A single '*' matches every host
+func excludedFromProxy(target *url.URL, noProxy string) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+	if port == "" {
+		port = defaultPort(target.Scheme)
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, block, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && block.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost = strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "https", "wss":
+		return "443"
+	default:
+		return "80"
+	}
+}
+
+// getEnvAny returns the value of the first of the environment variables that's
+// set, as proxy variables are set upper or lower case
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,253 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wsclient
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/wsclient/mock/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectProxy is a stub of an HTTP proxy tunneling CONNECT requests
+type connectProxy struct {
+	listener net.Listener
+	// credentials are the basic auth credentials the proxy requires, if any
+	credentials string
+
+	lock    sync.Mutex
+	tunnels []string
+}
+
+func startConnectProxy(t *testing.T, credentials string) *connectProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	proxy := &connectProxy{listener: listener, credentials: credentials}
+	go proxy.serve()
+	return proxy
+}
+
+func (proxy *connectProxy) serve() {
+	for {
+		conn, err := proxy.listener.Accept()
+		if err != nil {
+			return
+		}
+		go proxy.handle(conn)
+	}
+}
+
+func (proxy *connectProxy) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return
+	}
+	if proxy.credentials != "" &&
+		req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(proxy.credentials)) {
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return
+	}
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer target.Close()
+	proxy.lock.Lock()
+	proxy.tunnels = append(proxy.tunnels, req.Host)
+	proxy.lock.Unlock()
+
+	io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	go io.Copy(target, reader)
+	io.Copy(conn, target)
+}
+
+func (proxy *connectProxy) url(userinfo string) string {
+	if userinfo != "" {
+		userinfo += "@"
+	}
+	return "http://" + userinfo + proxy.listener.Addr().String()
+}
+
+func (proxy *connectProxy) tunneledHosts() []string {
+	proxy.lock.Lock()
+	defer proxy.lock.Unlock()
+	return append([]string{}, proxy.tunnels...)
+}
+
+// setProxyEnv sets the proxy environment variables for the test, returning a
+// function restoring them
+func setProxyEnv(values map[string]string) func() {
+	names := []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"}
+	previous := make(map[string]string)
+	for _, name := range names {
+		previous[name] = os.Getenv(name)
+		os.Setenv(name, values[name])
+	}
+	return func() {
+		for _, name := range names {
+			os.Setenv(name, previous[name])
+		}
+	}
+}
+
+func TestConnectThroughProxy(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		credentials string
+		userinfo    string
+	}{
+		{"unauthenticated", "", ""},
+		{"authenticated", "user:password", "user:password"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			closeWS := make(chan []byte)
+			defer close(closeWS)
+			mockServer, _, requests, _, _ := utils.GetMockServer(closeWS)
+			mockServer.StartTLS()
+			defer mockServer.Close()
+
+			proxy := startConnectProxy(t, tc.credentials)
+			defer proxy.listener.Close()
+			defer setProxyEnv(map[string]string{"HTTPS_PROXY": proxy.url(tc.userinfo), "NO_PROXY": "example.com"})()
+
+			cs := getClientServer(mockServer.URL)
+			require.NoError(t, cs.Connect())
+			defer cs.Close()
+
+			serverURL, _ := url.Parse(mockServer.URL)
+			assert.Equal(t, []string{serverURL.Host}, proxy.tunneledHosts())
+			go cs.MakeRequest(&ecsacs.AckRequest{MessageId: aws.String("123")})
+			assert.Contains(t, <-requests, "123", "requests should be sent through the tunnel")
+		})
+	}
+}
+
+func TestConnectThroughProxyAuthenticationFailure(t *testing.T) {
+	closeWS := make(chan []byte)
+	defer close(closeWS)
+	mockServer, _, _, _, _ := utils.GetMockServer(closeWS)
+	mockServer.StartTLS()
+	defer mockServer.Close()
+
+	proxy := startConnectProxy(t, "user:password")
+	defer proxy.listener.Close()
+	defer setProxyEnv(map[string]string{"NO_PROXY": "example.com"})()
+
+	cs := getClientServer(mockServer.URL)
+	cs.AgentConfig.WebsocketProxy = proxy.url("user:wrong")
+	assert.Error(t, cs.Connect())
+	assert.Empty(t, proxy.tunneledHosts())
+}
+
+func TestConnectConfiguredProxyOverridesEnvironment(t *testing.T) {
+	closeWS := make(chan []byte)
+	defer close(closeWS)
+	mockServer, _, _, _, _ := utils.GetMockServer(closeWS)
+	mockServer.StartTLS()
+	defer mockServer.Close()
+
+	proxy := startConnectProxy(t, "")
+	defer proxy.listener.Close()
+	defer setProxyEnv(map[string]string{"HTTPS_PROXY": "http://127.0.0.1:1", "NO_PROXY": "example.com"})()
+
+	cs := getClientServer(mockServer.URL)
+	cs.AgentConfig.WebsocketProxy = proxy.url("")
+	require.NoError(t, cs.Connect())
+	defer cs.Close()
+	assert.Len(t, proxy.tunneledHosts(), 1)
+}
+
+func TestConnectNoProxyExclusion(t *testing.T) {
+	closeWS := make(chan []byte)
+	defer close(closeWS)
+	mockServer, _, _, _, _ := utils.GetMockServer(closeWS)
+	mockServer.StartTLS()
+	defer mockServer.Close()
+
+	proxy := startConnectProxy(t, "")
+	defer proxy.listener.Close()
+	defer setProxyEnv(map[string]string{"HTTPS_PROXY": proxy.url(""), "NO_PROXY": "example.com,127.0.0.0/8"})()
+
+	cs := getClientServer(mockServer.URL)
+	require.NoError(t, cs.Connect())
+	defer cs.Close()
+	assert.Empty(t, proxy.tunneledHosts(), "hosts matching NO_PROXY should be connected to directly")
+}
+
+func TestWebsocketProxy(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		configured string
+		env        map[string]string
+		target     string
+		expected   string
+		err        bool
+	}{
+		{"no proxy", "", nil, "wss://ecs.us-west-2.amazonaws.com", "", false},
+		{"https proxy", "", map[string]string{"HTTPS_PROXY": "http://proxy:3128"}, "wss://ecs.us-west-2.amazonaws.com", "http://proxy:3128", false},
+		{"lower case https proxy", "", map[string]string{"https_proxy": "http://proxy:3128"}, "wss://ecs.us-west-2.amazonaws.com", "http://proxy:3128", false},
+		{"http proxy ignored for wss", "", map[string]string{"HTTP_PROXY": "http://proxy:3128"}, "wss://ecs.us-west-2.amazonaws.com", "", false},
+		{"http proxy for ws", "", map[string]string{"HTTP_PROXY": "http://proxy:3128"}, "ws://ecs.us-west-2.amazonaws.com", "http://proxy:3128", false},
+		{"proxy without scheme", "", map[string]string{"HTTPS_PROXY": "proxy:3128"}, "wss://ecs.us-west-2.amazonaws.com", "http://proxy:3128", false},
+		{"configured proxy", "http://configured:8080", map[string]string{"HTTPS_PROXY": "http://proxy:3128"}, "wss://ecs.us-west-2.amazonaws.com", "http://configured:8080", false},
+		{"unsupported scheme", "socks5://proxy:1080", nil, "wss://ecs.us-west-2.amazonaws.com", "", true},
+		{"no proxy wildcard", "http://proxy:3128", map[string]string{"NO_PROXY": "*"}, "wss://ecs.us-west-2.amazonaws.com", "", false},
+		{"no proxy domain", "http://proxy:3128", map[string]string{"NO_PROXY": "169.254.169.254, amazonaws.com"}, "wss://ecs.us-west-2.amazonaws.com", "", false},
+		{"no proxy leading dot", "http://proxy:3128", map[string]string{"no_proxy": ".amazonaws.com"}, "wss://ecs.us-west-2.amazonaws.com", "", false},
+		{"no proxy other domain", "http://proxy:3128", map[string]string{"NO_PROXY": "ws.amazonaws.com"}, "wss://ecs.us-west-2.amazonaws.com", "http://proxy:3128", false},
+		{"no proxy port", "http://proxy:3128", map[string]string{"NO_PROXY": "amazonaws.com:443"}, "wss://ecs.us-west-2.amazonaws.com", "", false},
+		{"no proxy other port", "http://proxy:3128", map[string]string{"NO_PROXY": "amazonaws.com:8443"}, "wss://ecs.us-west-2.amazonaws.com", "http://proxy:3128", false},
+		{"no proxy ip", "http://proxy:3128", map[string]string{"NO_PROXY": "10.0.0.1"}, "wss://10.0.0.1:8443", "", false},
+		{"no proxy cidr", "http://proxy:3128", map[string]string{"NO_PROXY": "10.0.0.0/16"}, "wss://10.0.1.1", "", false},
+		{"no proxy cidr miss", "http://proxy:3128", map[string]string{"NO_PROXY": "10.0.0.0/16"}, "wss://10.1.0.1", "http://proxy:3128", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setProxyEnv(tc.env)()
+			target, err := url.Parse(tc.target)
+			require.NoError(t, err)
+
+			proxyURL, err := websocketProxy(tc.configured, target)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expected == "" {
+				assert.Nil(t, proxyURL)
+			} else if assert.NotNil(t, proxyURL) {
+				assert.Equal(t, tc.expected, proxyURL.String())
+			}
+		})
+	}
+}
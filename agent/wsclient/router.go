@@ -0,0 +1,123 @@
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wsclient
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/cihub/seelog"
+)
+
+const (
+	// requestHandlerQueueSize is the number of messages of a type that are
+	// queued up without having been handled before reading further messages
+	// from the connection blocks
+	requestHandlerQueueSize = 10
+
+	// unrecognizedMessageType is the type recorded in the metrics of the
+	// messages of types the client doesn't recognize, as their names come
+	// from the backend
+	unrecognizedMessageType = "Unrecognized"
+)
+
+// requestRouter dispatches the messages read from the connection to the
+// request handlers registered for their type. Each handler runs on its own
+// goroutine, fed by a bounded queue, so that a slow handler only delays the
+// messages of its type, which are still handled in order
+type requestRouter struct {
+	endpoint string
+	queues   map[string]chan interface{}
+	// stopped is closed when the router is stopped
+	stopped chan struct{}
+}
+
+// newRequestRouter starts a goroutine handling the messages of each type that
+// has a request handler. The goroutines run until the router is stopped
+func newRequestRouter(handlers map[string]RequestHandler, endpoint string) *requestRouter {
+	router := &requestRouter{
+		endpoint: endpoint,
+		queues:   make(map[string]chan interface{}),
+		stopped:  make(chan struct{}),
+	}
+	for messageType, handler := range handlers {
+		queue := make(chan interface{}, requestHandlerQueueSize)
+		router.queues[messageType] = queue
+		go router.handleMessages(messageType, handler, queue)
+	}
+	return router
+}
+
+// route queues the message for the request handler of its type. It blocks
+// while the queue of the handler is full, and returns false if no handler is
+// registered for the type
+func (router *requestRouter) route(messageType string, message interface{}) bool {
+	queue, ok := router.queues[messageType]
+	if !ok {
+		return false
+	}
+	queue <- message
+	return true
+}
+
+// stop stops the goroutines of the request handlers, dropping the messages
+// still queued, as the consumers of the request handlers are stopped with the
+// connection. No message can be routed after stop is called
+func (router *requestRouter) stop() {
+	close(router.stopped)
+	for _, queue := range router.queues {
+		close(queue)
+	}
+}
+
+// handleMessages calls the request handler with each message of the queue and
+// records the outcome of the calls, until the router is stopped
+func (router *requestRouter) handleMessages(messageType string, handler RequestHandler, queue <-chan interface{}) {
+	handlerValue := reflect.ValueOf(handler)
+	for {
+		var message interface{}
+		var ok bool
+		select {
+		case <-router.stopped:
+			return
+		case message, ok = <-queue:
+			if !ok {
+				return
+			}
+		}
+		select {
+		case <-router.stopped:
+			// Both the queue and the router were ready, the messages still
+			// queued are dropped
+			return
+		default:
+		}
+		start := time.Now()
+		err := handlerError(handlerValue.Call([]reflect.Value{reflect.ValueOf(message)}))
+		if err != nil {
+			seelog.Warnf("Error handling message of type %s: %v", messageType, err)
+		}
+		metrics.RecordMessageHandled(router.endpoint, messageType, time.Since(start), err)
+	}
+}
+
+// handlerError returns the error returned by a request handler. Request
+// handlers that don't return anything never fail
+func handlerError(results []reflect.Value) error {
+	if len(results) == 0 || results[0].IsNil() {
+		return nil
+	}
+	return results[0].Interface().(error)
+}
@@ -0,0 +1,125 @@
+// +build unit
+
+// Copyright 2014-2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wsclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterRecordsHandledMessages(t *testing.T) {
+	// A label of its own keeps the samples of the test apart from the ones
+	// of the other tests of the package
+	endpoint := "router-test"
+	router := &requestRouter{endpoint: endpoint}
+	calls := 0
+	handler := func(message *ecsacs.HeartbeatMessage) error {
+		calls++
+		if calls == 1 {
+			return errors.New("error")
+		}
+		return nil
+	}
+
+	// The samples are recorded before the messages are handled, as the
+	// metrics are kept across the runs of the tests
+	handledBefore := messageSample(endpoint, "HeartbeatMessage", "ecs_agent_backend_messages_handled_total", "")
+	erroredBefore := messageSample(endpoint, "HeartbeatMessage", "ecs_agent_backend_messages_errored_total", "")
+	durationsBefore := messageSample(endpoint, "HeartbeatMessage", "ecs_agent_backend_message_handling_duration_seconds", "_count")
+
+	queue := make(chan interface{}, 3)
+	for i := 0; i < 3; i++ {
+		queue <- &ecsacs.HeartbeatMessage{}
+	}
+	close(queue)
+	router.handleMessages("HeartbeatMessage", handler, queue)
+
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, float64(2), messageSample(endpoint, "HeartbeatMessage", "ecs_agent_backend_messages_handled_total", "")-handledBefore)
+	assert.Equal(t, float64(1), messageSample(endpoint, "HeartbeatMessage", "ecs_agent_backend_messages_errored_total", "")-erroredBefore)
+	assert.Equal(t, float64(3), messageSample(endpoint, "HeartbeatMessage", "ecs_agent_backend_message_handling_duration_seconds", "_count")-durationsBefore)
+}
+
+func TestRouterHandlerWithoutResult(t *testing.T) {
+	endpoint := "router-no-result-test"
+	router := newRequestRouter(map[string]RequestHandler{}, endpoint)
+	defer router.stop()
+	assert.False(t, router.route("HeartbeatMessage", &ecsacs.HeartbeatMessage{}),
+		"messages without handlers shouldn't be routed")
+
+	handledBefore := messageSample(endpoint, "HeartbeatMessage", "ecs_agent_backend_messages_handled_total", "")
+	handled := false
+	queue := make(chan interface{}, 1)
+	queue <- &ecsacs.HeartbeatMessage{}
+	close(queue)
+	router.handleMessages("HeartbeatMessage", func(message *ecsacs.HeartbeatMessage) { handled = true }, queue)
+
+	assert.True(t, handled)
+	assert.Equal(t, float64(1), messageSample(endpoint, "HeartbeatMessage", "ecs_agent_backend_messages_handled_total", "")-handledBefore)
+}
+
+func TestRouterDropsQueuedMessagesOnStop(t *testing.T) {
+	router := newRequestRouter(map[string]RequestHandler{}, "router-stop-test")
+	queue := make(chan interface{}, 3)
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	handler := func(message *ecsacs.HeartbeatMessage) {
+		calls++
+		if calls == 1 {
+			close(handling)
+			<-release
+		}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		router.handleMessages("HeartbeatMessage", handler, queue)
+		close(stopped)
+	}()
+
+	for i := 0; i < 3; i++ {
+		queue <- &ecsacs.HeartbeatMessage{}
+	}
+	<-handling
+	router.stop()
+	close(queue)
+	close(release)
+
+	// The messages queued when the router was stopped aren't handled
+	<-stopped
+	assert.Equal(t, 1, calls)
+}
+
+// messageSample returns the value of the sample of the metric family for the
+// messages of the type received from the endpoint
+func messageSample(endpoint string, messageType string, familyName string, suffix string) float64 {
+	for _, family := range metrics.AgentMetrics.Metrics() {
+		if family.Name != familyName {
+			continue
+		}
+		for _, sample := range family.Samples {
+			if sample.Suffix == suffix && len(sample.Labels) == 2 &&
+				sample.Labels[0].Value == endpoint && sample.Labels[1].Value == messageType {
+				return sample.Value
+			}
+		}
+	}
+	return 0
+}